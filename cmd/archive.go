@@ -0,0 +1,47 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	witness "github.com/testifysec/go-witness"
+	"github.com/testifysec/go-witness/cryptoutil"
+)
+
+// checkArchiveSubjects returns an error unless the archive, or every file contained in it, matches a subject of the
+// verified evidence.
+func checkArchiveSubjects(evidence []witness.CollectionEnvelope, archiveDigest cryptoutil.DigestSet, fileDigests map[string]cryptoutil.DigestSet) error {
+	index := evidenceIndex(evidence)
+	if index.MatchesSubject(archiveDigest) {
+		return nil
+	}
+
+	unmatched := make([]string, 0)
+	for name, ds := range fileDigests {
+		if !index.MatchesSubject(ds) {
+			unmatched = append(unmatched, name)
+		}
+	}
+
+	if len(unmatched) > 0 {
+		sort.Strings(unmatched)
+		return fmt.Errorf("archive files do not match any attestation subject: %v", strings.Join(unmatched, ", "))
+	}
+
+	return nil
+}
@@ -0,0 +1,104 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"crypto"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	witness "github.com/testifysec/go-witness"
+	"github.com/testifysec/go-witness/log"
+	"github.com/testifysec/witness/pkg/digest"
+	"github.com/testifysec/witness/pkg/network"
+	"github.com/testifysec/witness/pkg/search"
+)
+
+// reportArchivist records what an Archivista server returned for the artifact. With a pinned key, the references are
+// every attestation the server holding the key listed for the digest at QueriedAt.
+type reportArchivist struct {
+	Server     string    `json:"server"`
+	PinnedKey  string    `json:"pinnedKey,omitempty"`
+	Digest     string    `json:"digest"`
+	QueriedAt  time.Time `json:"queriedAt"`
+	References []string  `json:"references"`
+}
+
+// newSearchArchivist returns a client for the Archivista server. If keyPath is set, the client only connects to the
+// server if its TLS certificate holds the PEM encoded public key or certificate at keyPath.
+func newSearchArchivist(server, keyPath string) (*search.Archivist, error) {
+	archivist := search.NewArchivist(server)
+	if keyPath == "" {
+		return archivist, nil
+	}
+
+	if !strings.HasPrefix(archivist.URL, "https://") {
+		return nil, fmt.Errorf("pinning the archivist server's key requires an https server, got %v", server)
+	}
+
+	keyPEM, err := os.ReadFile(keyPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read archivist public key: %w", err)
+	}
+
+	if err := archivist.Pin(keyPEM, network.NewTransport(0)); err != nil {
+		return nil, err
+	}
+
+	return archivist, nil
+}
+
+// loadEnvelopesFromArchivist searches the Archivista server for the attestations of the artifact file's sha256
+// digest, and records what the server returned.
+func loadEnvelopesFromArchivist(ctx context.Context, server, keyPath, artifactFilePath string) ([]witness.CollectionEnvelope, *reportArchivist, error) {
+	if artifactFilePath == "" {
+		return nil, nil, fmt.Errorf("an artifact file is required to fetch attestations from archivist")
+	}
+
+	archivist, err := newSearchArchivist(server, keyPath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	digestSet, err := digest.CalculateFile(artifactFilePath, []crypto.Hash{crypto.SHA256})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to calculate artifact file's hash: %w", err)
+	}
+
+	report := &reportArchivist{
+		Server:     archivist.URL,
+		PinnedKey:  archivist.PinnedKey,
+		Digest:     "sha256:" + digestSet[crypto.SHA256],
+		QueriedAt:  time.Now().UTC(),
+		References: []string{},
+	}
+
+	found, err := archivist.Search(ctx, search.Query{Subject: report.Digest})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	envelopes := make([]witness.CollectionEnvelope, 0, len(found))
+	for _, f := range found {
+		envelopes = append(envelopes, witness.CollectionEnvelope{Envelope: f.Envelope, Reference: f.Reference})
+		report.References = append(report.References, f.Reference)
+	}
+
+	log.Debugf("(archivist) found %d attestations for %v", len(envelopes), report.Digest)
+	return envelopes, report, nil
+}
@@ -0,0 +1,68 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"github.com/spf13/cobra"
+	"github.com/testifysec/witness/options"
+)
+
+func AttestCmd() *cobra.Command {
+	o := options.AttestOptions{}
+	cmd := &cobra.Command{
+		Use:   "attest",
+		Short: "Records and signs attestations without running a command",
+		Long: "Runs the selected attestors against the current environment and working directory and signs the result, " +
+			"for example to attest a source checkout or a snapshot of the environment outside of any build step",
+		SilenceErrors:     true,
+		SilenceUsage:      true,
+		DisableAutoGenTag: true,
+		Args:              cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runAttest(o)
+		},
+	}
+
+	o.AddFlags(cmd)
+	return cmd
+}
+
+// runAttest records attestations the same way as witness run, but without a command so the command-run, material,
+// and product attestors are not added.
+func runAttest(ao options.AttestOptions) error {
+	return runRun(options.RunOptions{
+		KeyOptions:         ao.KeyOptions,
+		WorkingDir:         ao.WorkingDir,
+		Attestations:       ao.Attestations,
+		OutFilePath:        ao.OutFilePath,
+		StepName:           ao.StepName,
+		RekorServers:       ao.RekorServers,
+		RekorEntryType:     ao.RekorEntryType,
+		RekorBundlePath:    ao.RekorBundlePath,
+		RekorRetries:       ao.RekorRetries,
+		PublishReportPath:  ao.PublishReportPath,
+		Ephemeral:          ao.Ephemeral,
+		EphemeralTTL:       ao.EphemeralTTL,
+		Obfuscate:          ao.Obfuscate,
+		Labels:             ao.Labels,
+		Matrix:             ao.Matrix,
+		DirtyWorktree:      ao.DirtyWorktree,
+		FailOnSecrets:      ao.FailOnSecrets,
+		OutputFormat:       ao.OutputFormat,
+		TektonChainsKey:    ao.TektonChainsKey,
+		KeyAttestationPath: ao.KeyAttestationPath,
+		Archivist:          ao.Archivist,
+	}, nil)
+}
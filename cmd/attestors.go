@@ -0,0 +1,178 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+
+	"github.com/spf13/cobra"
+	"github.com/testifysec/go-witness/log"
+	"github.com/testifysec/witness/options"
+	"github.com/testifysec/witness/pkg/replay"
+	"github.com/testifysec/witness/pkg/witness"
+)
+
+func AttestorsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "attestors",
+		Short: "Works with witness attestors",
+		Long: "Lists the attestors that ship with witness and whether each is available on the current platform. " +
+			"Attestors are unavailable if they aren't built for the platform, or if the host lacks something they need",
+		SilenceErrors:     true,
+		SilenceUsage:      true,
+		DisableAutoGenTag: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			writeAttestors(os.Stdout, witness.Attestors())
+			return nil
+		},
+	}
+
+	cmd.AddCommand(AttestorsTestCmd())
+	return cmd
+}
+
+func writeAttestors(w io.Writer, attestors []witness.AttestorInfo) {
+	fmt.Fprintf(w, "attestors available on %v/%v:\n", runtime.GOOS, runtime.GOARCH)
+	for _, attestor := range attestors {
+		status := "yes"
+		detail := attestor.Type
+		if !attestor.Available {
+			status = "no"
+			detail = attestor.Reason
+		}
+
+		fmt.Fprintf(w, "%-20s  %-3s  %s\n", attestor.Name, status, detail)
+	}
+}
+
+func AttestorsTestCmd() *cobra.Command {
+	o := options.AttestorsTestOptions{}
+	cmd := &cobra.Command{
+		Use:   "test [fixtures]",
+		Short: "Records attestor fixtures and replays them",
+		Long: "With --record, runs the attestors and records the environment, host files, commands, and http responses " +
+			"they read into a fixture along with the attestations they produced. Otherwise replays each fixture offline and " +
+			"fails if the attestors produce different attestations than were recorded",
+		SilenceErrors:     true,
+		SilenceUsage:      true,
+		DisableAutoGenTag: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if o.RecordPath != "" {
+				return runAttestorsRecord(o)
+			}
+
+			return runAttestorsTest(o, args)
+		},
+	}
+
+	o.AddFlags(cmd)
+	return cmd
+}
+
+func runAttestorsRecord(ao options.AttestorsTestOptions) error {
+	if len(ao.Attestations) == 0 {
+		return fmt.Errorf("at least one attestor is required to record a fixture")
+	}
+
+	fixture, err := replay.RecordFixture(context.Background(), ao.Attestations, ao.WorkingDir)
+	if err != nil {
+		return fmt.Errorf("failed to record fixture: %w", err)
+	}
+
+	if err := writeFixture(ao.RecordPath, fixture); err != nil {
+		return err
+	}
+
+	log.Infof("Recorded fixture for %v to %v", ao.Attestations, ao.RecordPath)
+	return nil
+}
+
+func runAttestorsTest(ao options.AttestorsTestOptions, fixturePaths []string) error {
+	if len(fixturePaths) == 0 {
+		return fmt.Errorf("at least one fixture is required")
+	}
+
+	failed := 0
+	for _, path := range fixturePaths {
+		if err := testFixture(path, ao); err != nil {
+			log.Errorf("FAIL %v: %v", path, err)
+			failed++
+			continue
+		}
+
+		log.Infof("PASS %v", path)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%v of %v fixtures failed", failed, len(fixturePaths))
+	}
+
+	return nil
+}
+
+func testFixture(path string, ao options.AttestorsTestOptions) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read fixture: %w", err)
+	}
+
+	fixture := &replay.Fixture{}
+	if err := json.Unmarshal(data, fixture); err != nil {
+		return fmt.Errorf("failed to unmarshal fixture: %w", err)
+	}
+
+	attestations, err := replay.Check(context.Background(), fixture, ao.WorkingDir)
+	mismatch := replay.ErrMismatch{}
+	if !errors.As(err, &mismatch) {
+		return err
+	}
+
+	for _, attestationType := range mismatch.Types {
+		expected, _ := json.MarshalIndent(fixture.Attestations[attestationType], "", "  ")
+		got, _ := json.MarshalIndent(attestations[attestationType], "", "  ")
+		log.Infof("%v expected:\n%s\ngot:\n%s", attestationType, expected, got)
+	}
+
+	if !ao.Update {
+		return err
+	}
+
+	fixture.Attestations = attestations
+	if err := writeFixture(path, fixture); err != nil {
+		return err
+	}
+
+	log.Infof("Updated attestations in %v", path)
+	return nil
+}
+
+func writeFixture(path string, fixture *replay.Fixture) error {
+	data, err := json.MarshalIndent(fixture, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal fixture: %w", err)
+	}
+
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write fixture: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,109 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/testifysec/go-witness/dsse"
+	"github.com/testifysec/go-witness/log"
+	"github.com/testifysec/go-witness/rekor"
+	"github.com/testifysec/witness/options"
+	"github.com/testifysec/witness/pkg/auditlog"
+)
+
+const auditAnchorPayloadType = "https://witness.dev/audit-log/anchor/v0.1"
+
+// openAuditLog opens the audit log and, if an anchor interval is set, starts publishing its head to Rekor signed by
+// the anchor key.
+func openAuditLog(ao options.AuditOptions, rekorServer string, rc rekor.RekorClient) (*auditlog.Log, error) {
+	var anchor auditlog.AnchorFunc
+	if ao.AnchorInterval > 0 {
+		if ao.AnchorKeyPath == "" {
+			return nil, fmt.Errorf("a key is required to anchor the audit log")
+		}
+
+		signers, errors := loadSigners(context.Background(), options.KeyOptions{KeyPath: ao.AnchorKeyPath})
+		if len(errors) > 0 {
+			return nil, fmt.Errorf("failed to load audit anchor key: %w", errors[0])
+		}
+
+		signer := signers[0]
+		verifier, err := signer.Verifier()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get verifier from signer: %w", err)
+		}
+
+		pubKeyBytes, err := verifier.Bytes()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get bytes from verifier: %w", err)
+		}
+
+		anchor = func(ctx context.Context, sequence uint64, head string) (string, error) {
+			payload, err := json.Marshal(auditlog.Anchor{Sequence: sequence, Head: head})
+			if err != nil {
+				return "", err
+			}
+
+			env, err := dsse.Sign(auditAnchorPayloadType, bytes.NewReader(payload), signer)
+			if err != nil {
+				return "", fmt.Errorf("failed to sign anchor: %w", err)
+			}
+
+			envBytes, err := json.Marshal(&env)
+			if err != nil {
+				return "", err
+			}
+
+			resp, err := rc.StoreArtifact(envBytes, pubKeyBytes)
+			if err != nil {
+				return "", fmt.Errorf("failed to store anchor in rekor: %w", err)
+			}
+
+			return fmt.Sprintf("%v%v", rekorServer, resp.Location), nil
+		}
+	}
+
+	audit, err := auditlog.Open(ao.LogPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if anchor != nil {
+		go audit.RunAnchor(context.Background(), ao.AnchorInterval, anchor)
+	}
+
+	return audit, nil
+}
+
+func auditDecision(audit *auditlog.Log, subject, policyDigest string, inputs []string, verifyErr error) {
+	decision := auditlog.Decision{
+		Subject:      subject,
+		Inputs:       inputs,
+		PolicyDigest: policyDigest,
+		Allowed:      verifyErr == nil,
+	}
+
+	if verifyErr != nil {
+		decision.Error = verifyErr.Error()
+	}
+
+	if _, err := audit.Append(decision); err != nil {
+		log.Errorf("failed to record decision for %v in audit log: %v", subject, err)
+	}
+}
@@ -0,0 +1,137 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"crypto"
+	"encoding/json"
+	"fmt"
+
+	witness "github.com/testifysec/go-witness"
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/dsse"
+	"github.com/testifysec/witness/options"
+	"github.com/testifysec/witness/pkg/fips"
+	"github.com/testifysec/witness/pkg/groups"
+	"github.com/testifysec/witness/pkg/policy"
+	"github.com/testifysec/witness/pkg/progress"
+	"github.com/testifysec/witness/pkg/rekorentry"
+)
+
+// maxBaseImageDepth limits how many base images are followed, since each base image policy may constrain the base
+// image of the images it verifies.
+const maxBaseImageDepth = 8
+
+// verifyBaseImages verifies the base images recorded by the verified evidence for each of the policy's steps with a
+// base image constraint against the constraint's base image policy, then the base images of those images against
+// the base image policies' own constraints. The base images' attestations are found among the candidates, and in Rekor
+// when a server is given. The evidence that satisfied the base image policies is returned.
+func verifyBaseImages(ctx context.Context, vo options.VerifyOptions, p policy.Policy, verified, candidates []witness.CollectionEnvelope, resolver groups.Resolver, bundles []rekorentry.Bundle, depth int) ([]witness.CollectionEnvelope, error) {
+	steps := p.BaseImageSteps()
+	if len(steps) == 0 {
+		return nil, nil
+	}
+
+	if depth >= maxBaseImageDepth {
+		return nil, fmt.Errorf("base images are nested more than %v deep", maxBaseImageDepth)
+	}
+
+	verifiedEnvelopes := make([]dsse.Envelope, 0, len(verified))
+	for _, e := range verified {
+		verifiedEnvelopes = append(verifiedEnvelopes, e.Envelope)
+	}
+
+	evidence := make([]witness.CollectionEnvelope, 0)
+	for _, step := range steps {
+		constraint := p.Steps[step].BaseImage
+		digests, err := policy.BaseImageDigests(step, verifiedEnvelopes)
+		if err != nil {
+			return nil, policy.ErrConstraintFailed{Step: step, Reason: err.Error()}
+		}
+
+		data, err := readFileOrURL(ctx, constraint.Policy, "downloading a base image policy")
+		if err != nil {
+			return nil, fmt.Errorf("failed to read base image policy for step %v: %w", step, err)
+		}
+
+		env := dsse.Envelope{}
+		if err := json.Unmarshal(data, &env); err != nil {
+			return nil, fmt.Errorf("could not unmarshal base image policy envelope for step %v: %w", step, err)
+		}
+
+		if err := p.VerifyBaseImagePolicy(step, env); err != nil {
+			return nil, err
+		}
+
+		if fips.Enabled() {
+			if err := checkPolicyFIPS(env); err != nil {
+				return nil, err
+			}
+		}
+
+		basePolicy, err := policy.Parse(env.Payload)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(basePolicy.Delegations()) > 0 {
+			return nil, fmt.Errorf("base image policy for step %v delegates steps, which is not supported", step)
+		}
+
+		signed, verifier, err := signEphemeralPolicy(env.Payload)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, digest := range digests {
+			digestSet := cryptoutil.DigestSet{crypto.SHA256: digest}
+			baseEvidence, err := findBaseImageEvidence(vo, digestSet, signed, verifier, candidates)
+			if err != nil {
+				return nil, fmt.Errorf("base image sha256:%v of step %v failed base image policy %v: %w", digest, step, constraint.Policy, err)
+			}
+
+			if !evidenceIndex(baseEvidence).MatchesSubject(digestSet) {
+				return nil, fmt.Errorf("base image sha256:%v of step %v does not match any subject of the evidence verified by base image policy %v", digest, step, constraint.Policy)
+			}
+
+			if _, err := verifyPolicyConstraints(signed, baseEvidence, resolver, bundles); err != nil {
+				return nil, fmt.Errorf("base image sha256:%v of step %v failed base image policy %v: %w", digest, step, constraint.Policy, err)
+			}
+
+			nested, err := verifyBaseImages(ctx, vo, basePolicy, baseEvidence, candidates, resolver, bundles, depth+1)
+			if err != nil {
+				return nil, fmt.Errorf("base image sha256:%v of step %v: %w", digest, step, err)
+			}
+
+			evidence = mergeEvidence(evidence, mergeEvidence(baseEvidence, nested))
+		}
+	}
+
+	return evidence, nil
+}
+
+// findBaseImageEvidence verifies the candidates, and the attestations in Rekor for the base image when a server is
+// given, against the base image policy.
+func findBaseImageEvidence(vo options.VerifyOptions, digestSet cryptoutil.DigestSet, policyEnvelope dsse.Envelope, verifier cryptoutil.Verifier, candidates []witness.CollectionEnvelope) ([]witness.CollectionEnvelope, error) {
+	verifiers := []cryptoutil.Verifier{verifier}
+	if vo.RekorServer == "" {
+		return witness.Verify(policyEnvelope, verifiers, witness.VerifyWithCollectionEnvelopes(candidates))
+	}
+
+	doneSearching := progress.Start("Searching Rekor for base image evidence")
+	defer doneSearching()
+	return searchRekor(vo, []cryptoutil.DigestSet{digestSet}, policyEnvelope, verifiers, candidates)
+}
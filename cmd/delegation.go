@@ -0,0 +1,135 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	witness "github.com/testifysec/go-witness"
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/dsse"
+	"github.com/testifysec/witness/pkg/fips"
+	"github.com/testifysec/witness/pkg/groups"
+	"github.com/testifysec/witness/pkg/policy"
+	"github.com/testifysec/witness/pkg/rekorentry"
+)
+
+// stripDelegatedSteps checks the policy's signature and removes its delegated steps, so go-witness verifies the rest of
+// the policy without requiring functionaries for steps that delegated policies verify. The stripped policy is signed
+// with an ephemeral key. Policies without delegations are returned unchanged.
+func stripDelegatedSteps(policyEnvelope dsse.Envelope, verifier cryptoutil.Verifier) (dsse.Envelope, cryptoutil.Verifier, policy.Policy, error) {
+	p, err := policy.Parse(policyEnvelope.Payload)
+	if err != nil {
+		return dsse.Envelope{}, nil, policy.Policy{}, err
+	}
+
+	delegations := p.Delegations()
+	if len(delegations) == 0 {
+		return policyEnvelope, verifier, p, nil
+	}
+
+	if verifier == nil {
+		return dsse.Envelope{}, nil, policy.Policy{}, fmt.Errorf("policies with delegated steps must be verified with a public key")
+	}
+
+	if _, err := policyEnvelope.Verify(dsse.WithVerifiers([]cryptoutil.Verifier{verifier})); err != nil {
+		return dsse.Envelope{}, nil, policy.Policy{}, fmt.Errorf("could not verify policy: %w", err)
+	}
+
+	payload, err := policy.WithoutSteps(policyEnvelope.Payload, delegations)
+	if err != nil {
+		return dsse.Envelope{}, nil, policy.Policy{}, err
+	}
+
+	stripped, ephemeralVerifier, err := signEphemeralPolicy(payload)
+	if err != nil {
+		return dsse.Envelope{}, nil, policy.Policy{}, err
+	}
+
+	return stripped, ephemeralVerifier, p, nil
+}
+
+// verifyDelegations verifies the candidate attestations against the delegated policy of each of the parent's
+// delegated steps, returning the evidence that satisfied them.
+func verifyDelegations(ctx context.Context, parent policy.Policy, candidates []witness.CollectionEnvelope, resolver groups.Resolver, bundles []rekorentry.Bundle) ([]witness.CollectionEnvelope, error) {
+	evidence := make([]witness.CollectionEnvelope, 0)
+	for _, step := range parent.Delegations() {
+		delegation := parent.Steps[step].Delegation
+		data, err := readFileOrURL(ctx, delegation.Policy, "downloading a delegated policy")
+		if err != nil {
+			return nil, fmt.Errorf("failed to read delegated policy for step %v: %w", step, err)
+		}
+
+		env := dsse.Envelope{}
+		if err := json.Unmarshal(data, &env); err != nil {
+			return nil, fmt.Errorf("could not unmarshal delegated policy envelope for step %v: %w", step, err)
+		}
+
+		if err := parent.VerifyDelegatedPolicy(step, env); err != nil {
+			return nil, err
+		}
+
+		if fips.Enabled() {
+			if err := checkPolicyFIPS(env); err != nil {
+				return nil, err
+			}
+		}
+
+		delegated, err := policy.Parse(env.Payload)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(delegated.Delegations()) > 0 {
+			return nil, fmt.Errorf("delegated policy for step %v delegates steps itself, which is not supported", step)
+		}
+
+		signed, verifier, err := signEphemeralPolicy(env.Payload)
+		if err != nil {
+			return nil, err
+		}
+
+		verified, err := witness.Verify(signed, []cryptoutil.Verifier{verifier}, witness.VerifyWithCollectionEnvelopes(candidates))
+		if err != nil {
+			return nil, fmt.Errorf("step %v failed delegated policy %v: %w", step, delegation.Policy, err)
+		}
+
+		if _, err := verifyPolicyConstraints(signed, verified, resolver, bundles); err != nil {
+			return nil, fmt.Errorf("step %v failed delegated policy %v: %w", step, delegation.Policy, err)
+		}
+
+		evidence = mergeEvidence(evidence, verified)
+	}
+
+	return evidence, nil
+}
+
+// mergeEvidence returns existing followed by the evidence not already in it, by reference.
+func mergeEvidence(existing, evidence []witness.CollectionEnvelope) []witness.CollectionEnvelope {
+	merged := make([]witness.CollectionEnvelope, 0, len(existing)+len(evidence))
+	seen := make(map[string]bool, len(existing))
+	for _, envelopes := range [][]witness.CollectionEnvelope{existing, evidence} {
+		for _, e := range envelopes {
+			if !seen[e.Reference] {
+				seen[e.Reference] = true
+				merged = append(merged, e)
+			}
+		}
+	}
+
+	return merged
+}
@@ -0,0 +1,100 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	witness "github.com/testifysec/go-witness"
+	"github.com/testifysec/go-witness/dsse"
+	"github.com/testifysec/go-witness/log"
+	"github.com/testifysec/witness/options"
+	"github.com/testifysec/witness/pkg/fips"
+	"github.com/testifysec/witness/pkg/payload"
+	"github.com/testifysec/witness/pkg/sslib"
+)
+
+// runVerifyEnvelope checks the signature of an envelope of any payload type, such as an SBOM or custom JSON signed with
+// witness sign, and that its payload can be read as its payload type declares.
+func runVerifyEnvelope(vo options.VerifyOptions) error {
+	if vo.KeyPath == "" {
+		return fmt.Errorf("the public key the envelope is signed with is required")
+	}
+
+	if vo.PolicyFilePath != "" || len(vo.AttestationFilePaths) > 0 || len(vo.Requirements) > 0 || vo.RekorServer != "" || vo.ArtifactFilePath != "" {
+		return fmt.Errorf("--envelope can not be used with a policy, attestations, requirements, rekor, or an artifact")
+	}
+
+	envelopeBytes, err := os.ReadFile(vo.EnvelopePath)
+	if err != nil {
+		return fmt.Errorf("failed to read envelope: %w", err)
+	}
+
+	env, err := sslib.ParseEnvelope(envelopeBytes)
+	if err != nil {
+		return fmt.Errorf("failed to parse envelope: %w", err)
+	}
+
+	if vo.PayloadType != "" && env.PayloadType != vo.PayloadType {
+		return fmt.Errorf("envelope payload type is %v, expected %v", env.PayloadType, vo.PayloadType)
+	}
+
+	verifiers, err := loadVerifiers(vo.KeyPath)
+	if err != nil {
+		return err
+	}
+
+	if fips.Enabled() {
+		for _, verifier := range verifiers {
+			if err := fips.CheckVerifier(verifier); err != nil {
+				return fmt.Errorf("verifier is not usable in fips mode: %w", err)
+			}
+		}
+	}
+
+	if _, err := env.Verify(dsse.WithVerifiers(verifiers)); err != nil {
+		return fmt.Errorf("failed to verify envelope signature: %w", err)
+	}
+
+	if err := payload.Check(env.PayloadType, env.Payload); err != nil {
+		return fmt.Errorf("failed to read envelope payload: %w", err)
+	}
+
+	if vo.PayloadOutPath != "" {
+		if err := os.WriteFile(vo.PayloadOutPath, env.Payload, 0644); err != nil {
+			return fmt.Errorf("failed to write payload: %w", err)
+		}
+	}
+
+	log.Infof("Verification succeeded: %v payload of type %v", payload.KindOf(env.PayloadType), env.PayloadType)
+	return nil
+}
+
+// statementEnvelopes drops the envelopes that don't hold in-toto statements, such as signed SBOMs or policies, so only
+// attestations are evaluated against the policy.
+func statementEnvelopes(envelopes []witness.CollectionEnvelope) []witness.CollectionEnvelope {
+	statements := make([]witness.CollectionEnvelope, 0, len(envelopes))
+	for _, env := range envelopes {
+		if payload.KindOf(env.Envelope.PayloadType) != payload.KindStatement {
+			log.Debugf("(verify) skipping %v: payload type %v is not an in-toto statement", env.Reference, env.Envelope.PayloadType)
+			continue
+		}
+
+		statements = append(statements, env)
+	}
+
+	return statements
+}
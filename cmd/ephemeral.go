@@ -0,0 +1,95 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"crypto"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/witness/options"
+	"github.com/testifysec/witness/pkg/ephemeral"
+)
+
+// loadEphemeralSigner creates a signer for a key that only exists for this run. The key in ko is used as a CA to
+// certify the ephemeral key for ttl and never signs attestations itself.
+func loadEphemeralSigner(ko options.KeyOptions, stepName string, ttl time.Duration) ([]cryptoutil.Signer, []error) {
+	if ko.KeyPath == "" || ko.CertPath == "" {
+		return nil, []error{fmt.Errorf("a key and certificate are required to certify an ephemeral key")}
+	}
+
+	if ko.FulcioURL != "" || ko.SpiffePath != "" || ko.KMSRef != "" || ko.NullSigner {
+		return nil, []error{fmt.Errorf("ephemeral keys can only be certified by a key file")}
+	}
+
+	key, err := loadPEMFile(ko.KeyPath)
+	if err != nil {
+		return nil, []error{fmt.Errorf("failed to load key: %w", err)}
+	}
+
+	caKey, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, []error{fmt.Errorf("%v is not a private key", ko.KeyPath)}
+	}
+
+	caCert, err := loadCertificateFile(ko.CertPath)
+	if err != nil {
+		return nil, []error{err}
+	}
+
+	intermediates := make([]*x509.Certificate, 0, len(ko.IntermediatePaths))
+	for _, path := range ko.IntermediatePaths {
+		intermediate, err := loadCertificateFile(path)
+		if err != nil {
+			return nil, []error{err}
+		}
+
+		intermediates = append(intermediates, intermediate)
+	}
+
+	signer, err := ephemeral.NewSigner(caKey, caCert, intermediates, stepName, ttl)
+	if err != nil {
+		return nil, []error{fmt.Errorf("failed to create ephemeral signer: %w", err)}
+	}
+
+	return []cryptoutil.Signer{signer}, nil
+}
+
+func loadPEMFile(path string) (interface{}, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+	return cryptoutil.TryParseKeyFromReader(f)
+}
+
+func loadCertificateFile(path string) (*x509.Certificate, error) {
+	parsed, err := loadPEMFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load certificate %v: %w", path, err)
+	}
+
+	cert, ok := parsed.(*x509.Certificate)
+	if !ok {
+		return nil, fmt.Errorf("%v is not a certificate", path)
+	}
+
+	return cert, nil
+}
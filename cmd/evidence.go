@@ -0,0 +1,77 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	witness "github.com/testifysec/go-witness"
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/dsse"
+	"github.com/testifysec/witness/options"
+	"github.com/testifysec/witness/pkg/evidence"
+)
+
+func writeEvidenceBundle(vo options.VerifyOptions, policyEnvelope dsse.Envelope, policyVerifier cryptoutil.Verifier, verifiedEvidence []witness.CollectionEnvelope, artifactDigestSet cryptoutil.DigestSet) error {
+	bundle := evidence.Bundle{
+		Policy: policyEnvelope,
+		Result: evidence.Result{
+			Verified:   true,
+			VerifiedAt: time.Now().UTC(),
+		},
+	}
+
+	// the verifier's key is recorded rather than the --publickey file, which may be an allowed_signers file
+	if vo.KeyPath != "" {
+		keyBytes, err := policyVerifier.Bytes()
+		if err != nil {
+			return fmt.Errorf("failed to encode policy key: %w", err)
+		}
+
+		bundle.PolicyKey = keyBytes
+	}
+
+	for _, caPath := range vo.CAPaths {
+		caBytes, err := os.ReadFile(caPath)
+		if err != nil {
+			return fmt.Errorf("failed to read policy ca: %w", err)
+		}
+
+		bundle.PolicyCAs = append(bundle.PolicyCAs, caBytes)
+	}
+
+	if artifactDigestSet != nil {
+		digest, err := artifactDigestSet.ToNameMap()
+		if err != nil {
+			return err
+		}
+
+		bundle.Result.ArtifactDigest = digest
+	}
+
+	for _, e := range verifiedEvidence {
+		bundle.Evidence = append(bundle.Evidence, evidence.Envelope{Envelope: e.Envelope, Reference: e.Reference})
+	}
+
+	out, err := loadOutfile(vo.EvidenceOutPath)
+	if err != nil {
+		return err
+	}
+
+	defer out.Close()
+	return evidence.Write(out, bundle)
+}
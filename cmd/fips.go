@@ -0,0 +1,62 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/testifysec/go-witness/dsse"
+	"github.com/testifysec/go-witness/policy"
+	"github.com/testifysec/witness/pkg/fips"
+)
+
+// checkPolicyFIPS rejects policies that trust keys or roots that aren't approved in fips mode
+func checkPolicyFIPS(policyEnvelope dsse.Envelope) error {
+	pol := policy.Policy{}
+	if err := json.Unmarshal(policyEnvelope.Payload, &pol); err != nil {
+		return fmt.Errorf("failed to unmarshal policy from envelope: %w", err)
+	}
+
+	verifiers, err := pol.PublicKeyVerifiers()
+	if err != nil {
+		return fmt.Errorf("failed to get public keys from policy: %w", err)
+	}
+
+	for keyID, verifier := range verifiers {
+		if err := fips.CheckVerifier(verifier); err != nil {
+			return fmt.Errorf("policy public key %v: %w", keyID, err)
+		}
+	}
+
+	trustBundles, err := pol.TrustBundles()
+	if err != nil {
+		return fmt.Errorf("failed to load policy trust bundles: %w", err)
+	}
+
+	for rootID, bundle := range trustBundles {
+		if err := fips.CheckCertificate(bundle.Root); err != nil {
+			return fmt.Errorf("policy root %v: %w", rootID, err)
+		}
+
+		for _, intermediate := range bundle.Intermediates {
+			if err := fips.CheckCertificate(intermediate); err != nil {
+				return fmt.Errorf("policy root %v: %w", rootID, err)
+			}
+		}
+	}
+
+	return nil
+}
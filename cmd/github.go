@@ -0,0 +1,63 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"crypto"
+	"fmt"
+	"os"
+
+	witness "github.com/testifysec/go-witness"
+	"github.com/testifysec/go-witness/log"
+	"github.com/testifysec/witness/pkg/digest"
+	"github.com/testifysec/witness/pkg/github"
+)
+
+// loadEnvelopesFromGitHub fetches the artifact attestations GitHub holds for the artifact in repository.
+// Attestations that are not DSSE envelopes are skipped.
+func loadEnvelopesFromGitHub(ctx context.Context, repository, artifactFilePath string) ([]witness.CollectionEnvelope, error) {
+	if artifactFilePath == "" {
+		return nil, fmt.Errorf("an artifact file is required to fetch attestations from github")
+	}
+
+	digestSet, err := digest.CalculateFile(artifactFilePath, []crypto.Hash{crypto.SHA256})
+	if err != nil {
+		return nil, fmt.Errorf("failed to calculate artifact file's hash: %w", err)
+	}
+
+	digest := "sha256:" + digestSet[crypto.SHA256]
+	client := github.Client{Token: os.Getenv("GITHUB_TOKEN")}
+	attestations, err := client.FetchAttestations(ctx, repository, digest)
+	if err != nil {
+		return nil, err
+	}
+
+	envelopes := make([]witness.CollectionEnvelope, 0, len(attestations))
+	for i, attestation := range attestations {
+		env, err := attestation.Bundle.Envelope()
+		if err != nil {
+			log.Debugf("(github) skipping attestation: %v", err)
+			continue
+		}
+
+		envelopes = append(envelopes, witness.CollectionEnvelope{
+			Envelope:  env,
+			Reference: fmt.Sprintf("github:%s/attestations/%s#%d", repository, digest, i),
+		})
+	}
+
+	return envelopes, nil
+}
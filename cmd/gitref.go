@@ -0,0 +1,52 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"crypto"
+	"fmt"
+
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/log"
+	"github.com/testifysec/witness/pkg/digest"
+	"github.com/testifysec/witness/pkg/gitref"
+)
+
+// loadArtifactDigestSet returns the digests of the artifact file, or the commit hash of a git artifact reference such
+// as git+https://github.com/org/repo@v1.2.3. Commit hashes match the subjects recorded by the git attestor.
+func loadArtifactDigestSet(ctx context.Context, artifact string) (cryptoutil.DigestSet, error) {
+	if !gitref.IsRef(artifact) {
+		digestSet, err := digest.CalculateFile(artifact, []crypto.Hash{crypto.SHA256})
+		if err != nil {
+			return nil, fmt.Errorf("failed to calculate artifact file's hash: %w", err)
+		}
+
+		return digestSet, nil
+	}
+
+	ref, err := gitref.Parse(artifact)
+	if err != nil {
+		return nil, err
+	}
+
+	commit, err := gitref.Resolve(ctx, ref)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve %v: %w", ref, err)
+	}
+
+	log.Infof("Resolved %v to commit %v", ref, commit)
+	return cryptoutil.DigestSet{crypto.SHA1: commit}, nil
+}
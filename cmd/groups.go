@@ -0,0 +1,64 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/testifysec/witness/options"
+	"github.com/testifysec/witness/pkg/groups"
+)
+
+// loadGroups returns a cache resolving groups with the configured directory. Without a directory the cache only holds
+// the memberships in the snapshot, if there is one.
+func loadGroups(o options.GroupOptions) (*groups.Cache, error) {
+	if o.SCIMURL != "" && o.LDAPURL != "" {
+		return nil, fmt.Errorf("only one of --groups-scim-url and --groups-ldap-url may be set")
+	}
+
+	var resolver groups.Resolver
+	switch {
+	case o.SCIMURL != "":
+		resolver = groups.SCIM{URL: o.SCIMURL, Token: os.Getenv("SCIM_TOKEN")}
+	case o.LDAPURL != "":
+		resolver = groups.LDAP{
+			URL:       o.LDAPURL,
+			Base:      o.LDAPBase,
+			GroupBase: o.LDAPGroupBase,
+			BindDN:    o.LDAPBindDN,
+			Password:  os.Getenv("LDAP_BIND_PASSWORD"),
+		}
+	}
+
+	cache := groups.NewCache(resolver, o.CacheTTL, o.MaxStale)
+	if resolver == nil && o.SnapshotPath != "" {
+		if err := cache.LoadSnapshot(o.SnapshotPath); err != nil {
+			return nil, err
+		}
+	}
+
+	return cache, nil
+}
+
+// saveGroupSnapshot writes the memberships resolved from the directory to the snapshot so later verifications can
+// run offline.
+func saveGroupSnapshot(o options.GroupOptions, cache *groups.Cache) error {
+	if o.SnapshotPath == "" || cache.Resolver == nil {
+		return nil
+	}
+
+	return cache.WriteSnapshot(o.SnapshotPath)
+}
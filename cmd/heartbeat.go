@@ -0,0 +1,99 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"crypto"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/testifysec/go-witness/attestation"
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/log"
+	"github.com/testifysec/witness/options"
+	"github.com/testifysec/witness/pkg/attestation/heartbeat"
+)
+
+// startHeartbeats writes a signed heartbeat collection to the heartbeat directory every interval until the returned
+// function is called.
+func startHeartbeats(ro options.RunOptions, signer cryptoutil.Signer) (func(), error) {
+	workingDir := ro.WorkingDir
+	if workingDir == "" {
+		wd, err := os.Getwd()
+		if err != nil {
+			return nil, err
+		}
+
+		workingDir = wd
+	}
+
+	if err := os.MkdirAll(ro.Heartbeat.Directory, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create heartbeat directory: %w", err)
+	}
+
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	startedAt := time.Now()
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(ro.Heartbeat.Interval)
+		defer ticker.Stop()
+		for sequence := 0; ; sequence++ {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				path, err := writeHeartbeat(ro, signer, workingDir, sequence, startedAt)
+				if err != nil {
+					log.Errorf("failed to write heartbeat: %v", err)
+				} else {
+					log.Infof("Wrote heartbeat %v", path)
+				}
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-stopped
+	}, nil
+}
+
+func writeHeartbeat(ro options.RunOptions, signer cryptoutil.Signer, workingDir string, sequence int, startedAt time.Time) (string, error) {
+	hb, err := heartbeat.Record(sequence, startedAt, workingDir, []crypto.Hash{crypto.SHA256})
+	if err != nil {
+		return "", err
+	}
+
+	env, err := signCollection(attestation.NewCollection(ro.StepName, []attestation.Attestor{hb}), signer)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign heartbeat: %w", err)
+	}
+
+	envBytes, err := json.Marshal(&env)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal heartbeat: %w", err)
+	}
+
+	path := filepath.Join(ro.Heartbeat.Directory, fmt.Sprintf("%s-heartbeat-%d.json", ro.StepName, sequence))
+	if err := os.WriteFile(path, envBytes, 0644); err != nil {
+		return "", fmt.Errorf("failed to write heartbeat: %w", err)
+	}
+
+	return path, nil
+}
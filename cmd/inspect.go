@@ -0,0 +1,137 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/testifysec/go-witness/dsse"
+	"github.com/testifysec/go-witness/intoto"
+	"github.com/testifysec/witness/options"
+	"github.com/testifysec/witness/pkg/policy"
+	"github.com/testifysec/witness/pkg/subjects"
+)
+
+func InspectCmd() *cobra.Command {
+	o := options.InspectOptions{}
+	cmd := &cobra.Command{
+		Use:   "inspect [attestation file]...",
+		Short: "Summarizes the collections in attestation files",
+		Long: "Writes a JSON summary of each envelope in the attestation files: its step, signers, attestation types, and " +
+			"subjects along with the attestors that contributed each of them. Signatures are not checked; use witness " +
+			"verify to evaluate the attestations against a policy",
+		SilenceErrors:     true,
+		SilenceUsage:      true,
+		DisableAutoGenTag: true,
+		Args:              cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runInspect(o, args)
+		},
+	}
+
+	o.AddFlags(cmd)
+	return cmd
+}
+
+// inspection summarizes an envelope. Subjects of collections recorded before witness recorded the attestors of
+// subjects have no attestors.
+type inspection struct {
+	Reference     string             `json:"reference"`
+	PayloadType   string             `json:"payloadType"`
+	PredicateType string             `json:"predicateType,omitempty"`
+	Step          string             `json:"step,omitempty"`
+	KeyIDs        []string           `json:"keyids"`
+	Attestations  []string           `json:"attestations,omitempty"`
+	Subjects      []subjects.Subject `json:"subjects"`
+}
+
+func runInspect(o options.InspectOptions, paths []string) error {
+	inspections := make([]inspection, 0, len(paths))
+	for _, path := range paths {
+		fileBytes, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read %v: %w", path, err)
+		}
+
+		envelopes := parseEnvelopes(fileBytes, path, nil)
+		if len(envelopes) == 0 {
+			return fmt.Errorf("%v does not hold any envelopes", path)
+		}
+
+		for _, env := range envelopes {
+			inspected, err := inspectEnvelope(env.Envelope)
+			if err != nil {
+				return fmt.Errorf("failed to inspect %v: %w", env.Reference, err)
+			}
+
+			inspected.Reference = env.Reference
+			inspections = append(inspections, inspected)
+		}
+	}
+
+	out, err := loadOutfile(o.OutFilePath)
+	if err != nil {
+		return fmt.Errorf("failed to open out file: %w", err)
+	}
+
+	defer out.Close()
+	return writeInspections(out, inspections)
+}
+
+func writeInspections(w io.Writer, inspections []inspection) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(inspections)
+}
+
+// inspectEnvelope summarizes the envelope's statement, and its collection if the statement holds one.
+func inspectEnvelope(env dsse.Envelope) (inspection, error) {
+	inspected := inspection{PayloadType: env.PayloadType, KeyIDs: make([]string, 0, len(env.Signatures)), Subjects: []subjects.Subject{}}
+	for _, sig := range env.Signatures {
+		inspected.KeyIDs = append(inspected.KeyIDs, sig.KeyID)
+	}
+
+	statement := intoto.Statement{}
+	if err := json.Unmarshal(env.Payload, &statement); err != nil {
+		return inspected, fmt.Errorf("failed to unmarshal statement: %w", err)
+	}
+
+	inspected.PredicateType = statement.PredicateType
+	collection, err := policy.CollectionFromEnvelope(env)
+	if err == nil {
+		inspected.Step = collection.Name
+		for _, attestation := range collection.Attestations {
+			inspected.Attestations = append(inspected.Attestations, attestation.Type)
+		}
+	}
+
+	for _, subject := range statement.Subject {
+		inspectedSubject := subjects.Subject{Name: subject.Name, Digest: subject.Digest}
+		for _, recorded := range collection.Subjects {
+			if recorded.Name == subject.Name {
+				inspectedSubject.Attestors = recorded.Attestors
+				break
+			}
+		}
+
+		inspected.Subjects = append(inspected.Subjects, inspectedSubject)
+	}
+
+	return inspected, nil
+}
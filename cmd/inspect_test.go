@@ -0,0 +1,61 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/testifysec/go-witness/attestation"
+	"github.com/testifysec/witness/options"
+	"github.com/testifysec/witness/pkg/attestation/labels"
+)
+
+func TestInspect(t *testing.T) {
+	signer, _, _, _, err := createTestRSAKey()
+	require.NoError(t, err)
+
+	labeled, _ := labels.Collection(attestation.NewCollection("build", nil), map[string]string{"team": "payments"})
+	env, err := signCollection(labeled, signer)
+	require.NoError(t, err)
+
+	envBytes, err := json.Marshal(env)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	attestationPath := filepath.Join(dir, "build.json")
+	require.NoError(t, os.WriteFile(attestationPath, envBytes, 0644))
+
+	outPath := filepath.Join(dir, "inspect.json")
+	require.NoError(t, runInspect(options.InspectOptions{OutFilePath: outPath}, []string{attestationPath}))
+
+	out, err := os.ReadFile(outPath)
+	require.NoError(t, err)
+
+	inspections := []inspection{}
+	require.NoError(t, json.Unmarshal(out, &inspections))
+	require.Len(t, inspections, 1)
+	require.Equal(t, "build", inspections[0].Step)
+	require.Equal(t, []string{labels.Type}, inspections[0].Attestations)
+	require.Len(t, inspections[0].Subjects, 1)
+	require.Equal(t, labels.Type+"/label:team=payments", inspections[0].Subjects[0].Name)
+	require.Equal(t, []string{labels.Type}, inspections[0].Subjects[0].Attestors)
+
+	require.NoError(t, os.WriteFile(attestationPath, []byte("{}"), 0644))
+	require.Error(t, runInspect(options.InspectOptions{OutFilePath: outPath}, []string{attestationPath}))
+}
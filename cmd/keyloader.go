@@ -19,10 +19,14 @@ import (
 	"fmt"
 
 	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/log"
 	"github.com/testifysec/go-witness/signer/file"
-	"github.com/testifysec/go-witness/signer/fulcio"
 	"github.com/testifysec/go-witness/signer/spiffe"
 	"github.com/testifysec/witness/options"
+	"github.com/testifysec/witness/pkg/fulcio"
+	"github.com/testifysec/witness/pkg/kms"
+	"github.com/testifysec/witness/pkg/network"
+	"github.com/testifysec/witness/pkg/nullsigner"
 )
 
 func loadSigners(ctx context.Context, ko options.KeyOptions) ([]cryptoutil.Signer, []error) {
@@ -31,8 +35,14 @@ func loadSigners(ctx context.Context, ko options.KeyOptions) ([]cryptoutil.Signe
 
 	//Load key from fulcio
 	if ko.FulcioURL != "" {
-		fulcioSigner, err := fulcio.Signer(ctx, ko.FulcioURL, ko.OIDCClientID, ko.OIDCIssuer)
-		if err != nil {
+		if err := network.Check("signing with Fulcio"); err != nil {
+			errors = append(errors, err)
+		} else if fulcioSigner, err := fulcio.Signer(ctx, fulcio.Options{
+			URL:          ko.FulcioURL,
+			Token:        ko.FulcioToken,
+			OIDCIssuer:   ko.OIDCIssuer,
+			OIDCClientID: ko.OIDCClientID,
+		}); err != nil {
 			err := fmt.Errorf("failed to create signer from Fulcio: %w", err)
 			errors = append(errors, err)
 		} else {
@@ -62,5 +72,27 @@ func loadSigners(ctx context.Context, ko options.KeyOptions) ([]cryptoutil.Signe
 		}
 	}
 
+	//Load key from kms
+	if ko.KMSRef != "" {
+		if err := network.Check("signing with a KMS key"); err != nil {
+			errors = append(errors, err)
+		} else if kmsSigner, err := kms.Get(ctx, ko.KMSRef); err != nil {
+			err := fmt.Errorf("failed to create signer from kms: %w", err)
+			errors = append(errors, err)
+		} else {
+			signers = append(signers, kmsSigner)
+		}
+	}
+
+	//Generate a throwaway key
+	if ko.NullSigner {
+		log.Warn("Signing with the null signer. Its key is generated for this run and discarded, so nothing it signs can be trusted")
+		if nullSigner, err := nullsigner.NewSigner(); err != nil {
+			errors = append(errors, fmt.Errorf("failed to create null signer: %w", err))
+		} else {
+			signers = append(signers, nullSigner)
+		}
+	}
+
 	return signers, errors
 }
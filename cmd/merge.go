@@ -0,0 +1,83 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/testifysec/go-witness/dsse"
+	"github.com/testifysec/witness/options"
+	"github.com/testifysec/witness/pkg/merge"
+)
+
+func MergeCmd() *cobra.Command {
+	mo := options.MergeOptions{}
+	cmd := &cobra.Command{
+		Use:   "merge [attestation files]",
+		Short: "Merges signed attestations for the same step",
+		Long: "Combines signed attestation collections for the same step, such as those recorded by parallel jobs, into " +
+			"a single file that can be passed to witness verify. The original envelopes and their signatures are kept unchanged",
+		SilenceErrors:     true,
+		SilenceUsage:      true,
+		DisableAutoGenTag: true,
+		Args:              cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runMerge(mo, args)
+		},
+	}
+
+	mo.AddFlags(cmd)
+	return cmd
+}
+
+func runMerge(mo options.MergeOptions, paths []string) error {
+	envs := make([]dsse.Envelope, 0, len(paths))
+	for _, path := range paths {
+		fileBytes, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read %v: %w", path, err)
+		}
+
+		// merged files can be merged again
+		if bundle, ok := merge.Parse(fileBytes); ok {
+			envs = append(envs, bundle.Envelopes...)
+			continue
+		}
+
+		env := dsse.Envelope{}
+		if err := json.Unmarshal(fileBytes, &env); err != nil {
+			return fmt.Errorf("failed to unmarshal envelope from %v: %w", path, err)
+		}
+
+		envs = append(envs, env)
+	}
+
+	bundle, err := merge.Merge(envs)
+	if err != nil {
+		return fmt.Errorf("failed to merge attestations: %w", err)
+	}
+
+	out, err := loadOutfile(mo.OutFilePath)
+	if err != nil {
+		return fmt.Errorf("failed to open out file: %w", err)
+	}
+
+	defer out.Close()
+	encoder := json.NewEncoder(out)
+	return encoder.Encode(&bundle)
+}
@@ -0,0 +1,126 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"crypto"
+	"encoding/json"
+	"fmt"
+
+	witness "github.com/testifysec/go-witness"
+	"github.com/testifysec/go-witness/dsse"
+	"github.com/testifysec/go-witness/intoto"
+	"github.com/testifysec/go-witness/log"
+	"github.com/testifysec/witness/pkg/digest"
+	"github.com/testifysec/witness/pkg/ocistore"
+)
+
+// storeInOCIRegistry attaches the signed collection to the image in the repository reference. Without a digest in
+// the reference, the collection is attached to each of its subjects that is an image in the repository. envBytes is
+// the envelope as written to the out file, timestamps included, and is what's attached.
+func storeInOCIRegistry(ctx context.Context, storage string, env dsse.Envelope, envBytes []byte) ([]string, error) {
+	ref, err := ocistore.ParseReference(storage)
+	if err != nil {
+		return nil, err
+	}
+
+	client := ocistore.NewClient()
+	images := []ocistore.Reference{ref}
+	if ref.Digest == "" {
+		images, err = subjectImages(ctx, client, ref, env)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(images) == 0 {
+			return nil, fmt.Errorf("none of the collection's subjects are images in %v", ref)
+		}
+	}
+
+	references := make([]string, 0, len(images))
+	for _, image := range images {
+		reference, err := client.PushData(ctx, image, envBytes)
+		if err != nil {
+			return nil, err
+		}
+
+		references = append(references, reference)
+	}
+
+	return references, nil
+}
+
+// subjectImages returns the references of the envelope's subjects that are manifests in the repository.
+func subjectImages(ctx context.Context, client *ocistore.Client, repository ocistore.Reference, env dsse.Envelope) ([]ocistore.Reference, error) {
+	statement := intoto.Statement{}
+	if err := json.Unmarshal(env.Payload, &statement); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal statement: %w", err)
+	}
+
+	seen := make(map[string]bool)
+	images := make([]ocistore.Reference, 0)
+	for _, subject := range statement.Subject {
+		digest, ok := subject.Digest["sha256"]
+		if !ok || seen[digest] {
+			continue
+		}
+
+		seen[digest] = true
+		image := repository.WithDigest("sha256:" + digest)
+		if _, exists, err := client.Resolve(ctx, image); err != nil {
+			return nil, fmt.Errorf("failed to resolve %v: %w", image, err)
+		} else if exists {
+			log.Debugf("(oci) subject %v is image %v", subject.Name, image)
+			images = append(images, image)
+		}
+	}
+
+	return images, nil
+}
+
+// loadEnvelopesFromOCIRegistry fetches the envelopes attached to the image in the repository reference, or to the
+// artifact file's sha256 digest if the reference has no digest.
+func loadEnvelopesFromOCIRegistry(ctx context.Context, storage, artifactFilePath string) ([]witness.CollectionEnvelope, error) {
+	ref, err := ocistore.ParseReference(storage)
+	if err != nil {
+		return nil, err
+	}
+
+	if ref.Digest == "" {
+		if artifactFilePath == "" {
+			return nil, fmt.Errorf("a digest in the repository reference or an artifact file is required to fetch attestations from %v", ref)
+		}
+
+		digestSet, err := digest.CalculateFile(artifactFilePath, []crypto.Hash{crypto.SHA256})
+		if err != nil {
+			return nil, fmt.Errorf("failed to calculate artifact file's hash: %w", err)
+		}
+
+		ref = ref.WithDigest("sha256:" + digestSet[crypto.SHA256])
+	}
+
+	found, err := ocistore.NewClient().Fetch(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	envelopes := make([]witness.CollectionEnvelope, 0, len(found))
+	for _, f := range found {
+		envelopes = append(envelopes, witness.CollectionEnvelope{Envelope: f.Envelope, Reference: f.Reference})
+	}
+
+	return envelopes, nil
+}
@@ -0,0 +1,734 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	witness "github.com/testifysec/go-witness"
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/dsse"
+	"github.com/testifysec/go-witness/log"
+	gwpolicy "github.com/testifysec/go-witness/policy"
+	"github.com/testifysec/witness/options"
+	"github.com/testifysec/witness/pkg/allowedsigners"
+	"github.com/testifysec/witness/pkg/groups"
+	"github.com/testifysec/witness/pkg/network"
+	"github.com/testifysec/witness/pkg/payload"
+	"github.com/testifysec/witness/pkg/policy"
+	"github.com/testifysec/witness/pkg/policy/validate"
+	"github.com/testifysec/witness/pkg/rekorentry"
+	"github.com/testifysec/witness/pkg/simulate"
+)
+
+func PolicyCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:               "policy",
+		Short:             "Works with witness policies",
+		DisableAutoGenTag: true,
+	}
+
+	cmd.AddCommand(PolicyInitCmd())
+	cmd.AddCommand(PolicyValidateCmd())
+	cmd.AddCommand(PolicySignCmd())
+	cmd.AddCommand(PolicyCheckCmd())
+	cmd.AddCommand(PolicySimulateCmd())
+	cmd.AddCommand(PolicyAddSignersCmd())
+	return cmd
+}
+
+func PolicySimulateCmd() *cobra.Command {
+	o := options.PolicySimulateOptions{}
+	cmd := &cobra.Command{
+		Use:   "simulate",
+		Short: "Replays a proposed policy against stored attestations",
+		Long: "Groups recently stored attestations into the builds they were recorded for and verifies each build " +
+			"against the proposed policy, reporting which past builds would have failed. Exits with a non-zero code if " +
+			"any build would fail",
+		SilenceErrors:     true,
+		SilenceUsage:      true,
+		DisableAutoGenTag: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runPolicySimulate(o)
+		},
+	}
+
+	o.AddFlags(cmd)
+	return cmd
+}
+
+func PolicyAddSignersCmd() *cobra.Command {
+	o := options.PolicyAddSignersOptions{}
+	cmd := &cobra.Command{
+		Use:   "add-signers",
+		Short: "Adds the keys of an OpenSSH allowed_signers file to a policy",
+		Long: "Adds the keys an OpenSSH allowed_signers file allows to sign for the witness namespace to the policy's " +
+			"public keys, and trusts them as functionaries of the given steps, so teams signing git commits with SSH " +
+			"keys can reuse their allowed signers",
+		SilenceErrors:     true,
+		SilenceUsage:      true,
+		DisableAutoGenTag: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runPolicyAddSigners(o)
+		},
+	}
+
+	o.AddFlags(cmd)
+	return cmd
+}
+
+func PolicyValidateCmd() *cobra.Command {
+	o := options.PolicyValidateOptions{}
+	cmd := &cobra.Command{
+		Use:     "validate",
+		Aliases: []string{"lint"},
+		Short:   "Checks a policy for mistakes before it is signed",
+		Long: "Checks the policy's fields, expiration, keys, certificates, rego modules, and step constraints, and " +
+			"steps or keys that are defined more than once, and prints each problem with its line and column. Exits " +
+			"with a non-zero code if the policy has any errors",
+		SilenceErrors:     true,
+		SilenceUsage:      true,
+		DisableAutoGenTag: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runPolicyValidate(o)
+		},
+	}
+
+	o.AddFlags(cmd)
+	return cmd
+}
+
+func PolicyInitCmd() *cobra.Command {
+	o := options.PolicyInitOptions{}
+	cmd := &cobra.Command{
+		Use:   "init [attestation file]...",
+		Short: "Scaffolds a policy from existing attestations",
+		Long: "Writes an unsigned policy with a step for each step the attestations were recorded for. Each step " +
+			"requires the attestation types all of its attestations have, trusts the given public keys that signed " +
+			"them, and takes artifacts from the steps whose products it used as materials. Review the policy, then " +
+			"sign it with witness policy sign",
+		Args:              cobra.MinimumNArgs(1),
+		SilenceErrors:     true,
+		SilenceUsage:      true,
+		DisableAutoGenTag: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runPolicyInit(o, args)
+		},
+	}
+
+	o.AddFlags(cmd)
+	return cmd
+}
+
+func PolicySignCmd() *cobra.Command {
+	o := options.PolicySignOptions{}
+	cmd := &cobra.Command{
+		Use:   "sign",
+		Short: "Validates and signs a policy",
+		Long: "Validates the policy the same way witness policy validate does and signs it as a witness policy if it " +
+			"has no errors. Policies that are already signed are refused",
+		SilenceErrors:     true,
+		SilenceUsage:      true,
+		DisableAutoGenTag: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runPolicySign(o)
+		},
+	}
+
+	o.AddFlags(cmd)
+	return cmd
+}
+
+func PolicyCheckCmd() *cobra.Command {
+	o := options.PolicyCheckOptions{}
+	cmd := &cobra.Command{
+		Use:   "check [attestation file]",
+		Short: "Explains which of its step's constraints an attestation satisfies",
+		Long: "Checks the attestation against its step in the policy on its own, without the attestations of the " +
+			"other steps, and reports each constraint it satisfies or fails. The policy's signature is not verified. " +
+			"Exits with a non-zero code if the attestation fails any constraint",
+		Args:              cobra.ExactArgs(1),
+		SilenceErrors:     true,
+		SilenceUsage:      true,
+		DisableAutoGenTag: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runPolicyCheck(o, args[0])
+		},
+	}
+
+	o.AddFlags(cmd)
+	return cmd
+}
+
+func runPolicyValidate(po options.PolicyValidateOptions) error {
+	if po.PolicyFilePath == "" {
+		return fmt.Errorf("a policy is required")
+	}
+
+	data, err := os.ReadFile(po.PolicyFilePath)
+	if err != nil {
+		return fmt.Errorf("failed to read policy: %w", err)
+	}
+
+	report := validate.Validate(data)
+	if po.JSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(report); err != nil {
+			return fmt.Errorf("failed to write report: %w", err)
+		}
+	} else {
+		for _, d := range report.Diagnostics {
+			fmt.Printf("%v:%v\n", po.PolicyFilePath, d)
+		}
+	}
+
+	if report.HasErrors() {
+		return fmt.Errorf("%v is not a valid policy", po.PolicyFilePath)
+	}
+
+	return nil
+}
+
+func runPolicyInit(po options.PolicyInitOptions, attestationPaths []string) error {
+	expires, err := simulate.ParseSince(po.Expires)
+	if err != nil {
+		return err
+	}
+
+	envelopes := make([]dsse.Envelope, 0)
+	for _, path := range attestationPaths {
+		fileBytes, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read attestation: %w", err)
+		}
+
+		parsed := parseEnvelopes(fileBytes, path, nil)
+		if len(parsed) == 0 {
+			return fmt.Errorf("%v is not an attestation envelope", path)
+		}
+
+		for _, env := range parsed {
+			envelopes = append(envelopes, env.Envelope)
+		}
+	}
+
+	keys := make([]policy.PublicKey, 0, len(po.PublicKeyPaths))
+	for _, path := range po.PublicKeyPaths {
+		key, err := loadPolicyPublicKey(path)
+		if err != nil {
+			return err
+		}
+
+		keys = append(keys, key)
+	}
+
+	scaffold, err := policy.Scaffold(envelopes, keys, time.Now().Add(expires))
+	if err != nil {
+		return err
+	}
+
+	if report := validate.Validate(scaffold); len(report.Diagnostics) > 0 {
+		for _, d := range report.Diagnostics {
+			log.Warnf("policy:%v", d)
+		}
+	}
+
+	out, err := loadOutfile(po.OutFilePath)
+	if err != nil {
+		return fmt.Errorf("failed to open out file: %w", err)
+	}
+
+	defer out.Close()
+	_, err = out.Write(append(scaffold, '\n'))
+	return err
+}
+
+// loadPolicyPublicKey reads a PEM encoded public key as a policy public key.
+func loadPolicyPublicKey(path string) (policy.PublicKey, error) {
+	keyFile, err := os.Open(path)
+	if err != nil {
+		return policy.PublicKey{}, fmt.Errorf("failed to open public key: %w", err)
+	}
+
+	defer keyFile.Close()
+	verifier, err := cryptoutil.NewVerifierFromReader(keyFile)
+	if err != nil {
+		return policy.PublicKey{}, fmt.Errorf("failed to load public key %v: %w", path, err)
+	}
+
+	keyID, err := verifier.KeyID()
+	if err != nil {
+		return policy.PublicKey{}, fmt.Errorf("failed to get key id: %w", err)
+	}
+
+	keyBytes, err := verifier.Bytes()
+	if err != nil {
+		return policy.PublicKey{}, fmt.Errorf("failed to encode public key %v: %w", path, err)
+	}
+
+	return policy.PublicKey{KeyID: keyID, Key: keyBytes}, nil
+}
+
+func runPolicySign(po options.PolicySignOptions) error {
+	if po.PolicyFilePath == "" {
+		return fmt.Errorf("a policy is required")
+	}
+
+	data, err := os.ReadFile(po.PolicyFilePath)
+	if err != nil {
+		return fmt.Errorf("failed to read policy: %w", err)
+	}
+
+	report := validate.Validate(data)
+	if report.Signed {
+		return fmt.Errorf("%v is already signed", po.PolicyFilePath)
+	}
+
+	for _, d := range report.Diagnostics {
+		if d.Severity == validate.SeverityError {
+			log.Errorf("%v:%v", po.PolicyFilePath, d)
+		} else {
+			log.Warnf("%v:%v", po.PolicyFilePath, d)
+		}
+	}
+
+	if report.HasErrors() {
+		return fmt.Errorf("%v is not a valid policy and was not signed", po.PolicyFilePath)
+	}
+
+	if po.Strict && len(report.Diagnostics) > 0 {
+		return fmt.Errorf("%v has warnings and was not signed", po.PolicyFilePath)
+	}
+
+	return runSign(options.SignOptions{
+		KeyOptions:       po.KeyOptions,
+		PayloadType:      payload.PolicyType,
+		InFilePath:       po.PolicyFilePath,
+		OutFilePath:      po.OutFilePath,
+		TimestampServers: po.TimestampServers,
+	})
+}
+
+// policyCheckResult is the outcome of checking one collection against its step.
+type policyCheckResult struct {
+	Reference string         `json:"reference"`
+	Step      string         `json:"step"`
+	Checks    []policy.Check `json:"checks"`
+}
+
+func runPolicyCheck(po options.PolicyCheckOptions, attestationPath string) error {
+	if po.PolicyFilePath == "" {
+		return fmt.Errorf("a policy is required")
+	}
+
+	data, err := os.ReadFile(po.PolicyFilePath)
+	if err != nil {
+		return fmt.Errorf("failed to read policy: %w", err)
+	}
+
+	env := dsse.Envelope{}
+	if err := json.Unmarshal(data, &env); err == nil && env.PayloadType == gwpolicy.PolicyPredicate && len(env.Payload) > 0 {
+		data = env.Payload
+	}
+
+	p, err := policy.Parse(data)
+	if err != nil {
+		return err
+	}
+
+	fileBytes, err := os.ReadFile(attestationPath)
+	if err != nil {
+		return fmt.Errorf("failed to read attestation: %w", err)
+	}
+
+	envelopes := parseEnvelopes(fileBytes, attestationPath, nil)
+	if len(envelopes) == 0 {
+		return fmt.Errorf("%v is not an attestation envelope", attestationPath)
+	}
+
+	if po.Groups.SCIMURL != "" || po.Groups.LDAPURL != "" {
+		if err := network.Check("resolving groups from a directory"); err != nil {
+			return err
+		}
+	}
+
+	groupCache, err := loadGroups(po.Groups)
+	if err != nil {
+		return fmt.Errorf("failed to load groups: %w", err)
+	}
+
+	bundles, err := loadRekorBundles(nil, []string{attestationPath})
+	if err != nil {
+		return fmt.Errorf("failed to load rekor bundles: %w", err)
+	}
+
+	p.Groups = groupCache
+	p.LogBundles = bundles
+	results := make([]policyCheckResult, 0, len(envelopes))
+	for _, e := range envelopes {
+		step, checks, err := p.Explain(e.Envelope)
+		if err != nil {
+			return fmt.Errorf("failed to check %v: %w", e.Reference, err)
+		}
+
+		if p.Steps[step].Delegation != nil {
+			log.Infof("step %v is delegated, so its attestations are only checked against the delegated policy by witness verify", step)
+		} else {
+			stepCheck := policy.Check{Constraint: "functionaries and attestations", Passed: true}
+			if err := verifyStepAlone(data, p, step, e); err != nil {
+				stepCheck.Passed = false
+				stepCheck.Reason = strings.Join(strings.Fields(err.Error()), " ")
+			}
+
+			checks = append([]policy.Check{stepCheck}, checks...)
+		}
+
+		results = append(results, policyCheckResult{Reference: e.Reference, Step: step, Checks: checks})
+	}
+
+	if err := saveGroupSnapshot(po.Groups, groupCache); err != nil {
+		return fmt.Errorf("failed to save group snapshot: %w", err)
+	}
+
+	if po.JSON {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(results); err != nil {
+			return fmt.Errorf("failed to write checks: %w", err)
+		}
+	} else {
+		for _, result := range results {
+			fmt.Printf("%v: step %v\n", result.Reference, result.Step)
+			for _, c := range result.Checks {
+				if c.Passed {
+					fmt.Printf("  pass  %v\n", c.Constraint)
+				} else {
+					fmt.Printf("  fail  %v: %v\n", c.Constraint, c.Reason)
+				}
+			}
+		}
+	}
+
+	failed := 0
+	for _, result := range results {
+		for _, c := range result.Checks {
+			if !c.Passed {
+				failed++
+			}
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%v fails %d constraints of the policy", attestationPath, failed)
+	}
+
+	return nil
+}
+
+// verifyStepAlone verifies the collection with go-witness against the policy reduced to the collection's step, which
+// checks the step's functionaries, required attestations, and their rego policies. The other steps are removed along
+// with the step's artifactsFrom, since a single collection can't satisfy them.
+func verifyStepAlone(data []byte, p policy.Policy, step string, env witness.CollectionEnvelope) error {
+	others := make([]string, 0, len(p.Steps))
+	for name := range p.Steps {
+		if name != step {
+			others = append(others, name)
+		}
+	}
+
+	reduced, err := policy.WithoutSteps(data, others)
+	if err != nil {
+		return err
+	}
+
+	policyEnvelope, verifier, err := signEphemeralPolicy(reduced)
+	if err != nil {
+		return err
+	}
+
+	_, err = witness.Verify(policyEnvelope, []cryptoutil.Verifier{verifier}, witness.VerifyWithCollectionEnvelopes([]witness.CollectionEnvelope{env}))
+	return err
+}
+
+func runPolicyAddSigners(po options.PolicyAddSignersOptions) error {
+	if po.PolicyFilePath == "" {
+		return fmt.Errorf("a policy is required")
+	}
+
+	if po.AllowedSignersPath == "" {
+		return fmt.Errorf("an allowed signers file is required")
+	}
+
+	payload, err := os.ReadFile(po.PolicyFilePath)
+	if err != nil {
+		return fmt.Errorf("failed to read policy: %w", err)
+	}
+
+	env := dsse.Envelope{}
+	if err := json.Unmarshal(payload, &env); err == nil && len(env.Signatures) > 0 {
+		return fmt.Errorf("%v is signed; add signers to the unsigned policy and sign it again", po.PolicyFilePath)
+	}
+
+	signersFile, err := os.Open(po.AllowedSignersPath)
+	if err != nil {
+		return fmt.Errorf("failed to open allowed signers: %w", err)
+	}
+
+	defer signersFile.Close()
+	signers, err := allowedsigners.Parse(signersFile)
+	if err != nil {
+		return err
+	}
+
+	keys, err := allowedSignerKeys(signers, po.Principals)
+	if err != nil {
+		return err
+	}
+
+	if len(keys) == 0 {
+		return fmt.Errorf("no keys in %v are allowed to sign for the %v namespace", po.AllowedSignersPath, allowedsigners.Namespace)
+	}
+
+	updated, err := policy.AddPublicKeyFunctionaries(payload, keys, po.Steps)
+	if err != nil {
+		return err
+	}
+
+	out, err := loadOutfile(po.OutFilePath)
+	if err != nil {
+		return fmt.Errorf("failed to open out file: %w", err)
+	}
+
+	defer out.Close()
+	_, err = out.Write(append(updated, '\n'))
+	return err
+}
+
+// allowedSignerKeys returns the policy public keys of the allowed signers that may sign for witness now, limited to
+// the principals if any are given.
+func allowedSignerKeys(signers []allowedsigners.Signer, principals []string) ([]policy.PublicKey, error) {
+	wanted := make(map[string]bool)
+	for _, principal := range principals {
+		wanted[principal] = true
+	}
+
+	keys := make([]policy.PublicKey, 0)
+	for _, signer := range signers {
+		if !signer.Allows(time.Now()) {
+			continue
+		}
+
+		if len(wanted) > 0 {
+			matched := false
+			for _, principal := range signer.Principals {
+				matched = matched || wanted[principal]
+			}
+
+			if !matched {
+				continue
+			}
+		}
+
+		verifier, err := cryptoutil.NewVerifier(signer.PublicKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load key of %v: %w", strings.Join(signer.Principals, ","), err)
+		}
+
+		keyID, err := verifier.KeyID()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get key id: %w", err)
+		}
+
+		keyBytes, err := verifier.Bytes()
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode key of %v: %w", strings.Join(signer.Principals, ","), err)
+		}
+
+		keys = append(keys, policy.PublicKey{KeyID: keyID, Key: keyBytes})
+	}
+
+	return keys, nil
+}
+
+func runPolicySimulate(po options.PolicySimulateOptions) error {
+	if po.PolicyFilePath == "" {
+		return fmt.Errorf("a policy is required")
+	}
+
+	if po.From == "" {
+		return fmt.Errorf("a directory of stored attestations is required")
+	}
+
+	if info, err := os.Stat(po.From); err != nil || !info.IsDir() {
+		return fmt.Errorf("%v is not a directory of stored attestations", po.From)
+	}
+
+	since, err := simulate.ParseSince(po.Since)
+	if err != nil {
+		return err
+	}
+
+	policyEnvelope, verifier, err := signSimulatedPolicy(po.PolicyFilePath)
+	if err != nil {
+		return err
+	}
+
+	attestations, err := simulate.Load(po.From, time.Now().Add(-since))
+	if err != nil {
+		return err
+	}
+
+	if po.Groups.SCIMURL != "" || po.Groups.LDAPURL != "" {
+		if err := network.Check("resolving groups from a directory"); err != nil {
+			return err
+		}
+	}
+
+	groupCache, err := loadGroups(po.Groups)
+	if err != nil {
+		return fmt.Errorf("failed to load groups: %w", err)
+	}
+
+	results := make([]simulate.Result, 0)
+	for _, build := range simulate.GroupBuilds(attestations, po.GroupBy) {
+		results = append(results, simulate.Result{Build: build, Err: verifySimulatedBuild(policyEnvelope, verifier, build, groupCache)})
+	}
+
+	if err := saveGroupSnapshot(po.Groups, groupCache); err != nil {
+		return fmt.Errorf("failed to save group snapshot: %w", err)
+	}
+
+	if failed := simulate.Report(os.Stdout, results); failed > 0 {
+		return fmt.Errorf("%d of %d builds would fail the policy", failed, len(results))
+	}
+
+	return nil
+}
+
+// signSimulatedPolicy signs the proposed policy with a key that only exists for the simulation, so policies that have
+// not been signed yet can be replayed. Signed policies are re-signed with their payload.
+func signSimulatedPolicy(path string) (dsse.Envelope, cryptoutil.Verifier, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return dsse.Envelope{}, nil, fmt.Errorf("failed to read policy: %w", err)
+	}
+
+	env := dsse.Envelope{}
+	if err := json.Unmarshal(data, &env); err == nil && env.PayloadType == gwpolicy.PolicyPredicate && len(env.Payload) > 0 {
+		data = env.Payload
+	}
+
+	if _, err := policy.Parse(data); err != nil {
+		return dsse.Envelope{}, nil, err
+	}
+
+	return signEphemeralPolicy(data)
+}
+
+// signEphemeralPolicy signs the policy with a key that only exists for this process, for policies whose signature was
+// already checked or does not need to be.
+func signEphemeralPolicy(data []byte) (dsse.Envelope, cryptoutil.Verifier, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return dsse.Envelope{}, nil, fmt.Errorf("failed to generate ephemeral policy key: %w", err)
+	}
+
+	signer := cryptoutil.NewECDSASigner(key, crypto.SHA256)
+	verifier, err := signer.Verifier()
+	if err != nil {
+		return dsse.Envelope{}, nil, err
+	}
+
+	signed, err := dsse.Sign(gwpolicy.PolicyPredicate, bytes.NewReader(data), signer)
+	if err != nil {
+		return dsse.Envelope{}, nil, fmt.Errorf("failed to sign policy: %w", err)
+	}
+
+	return signed, verifier, nil
+}
+
+// verifySimulatedBuild verifies a build's attestations against the policy the same way witness verify does.
+func verifySimulatedBuild(policyEnvelope dsse.Envelope, verifier cryptoutil.Verifier, build simulate.Build, resolver groups.Resolver) error {
+	envelopes := make([]witness.CollectionEnvelope, 0, len(build.Attestations))
+	for _, attestation := range build.Attestations {
+		envelopes = append(envelopes, witness.CollectionEnvelope{Envelope: attestation.Envelope, Reference: attestation.Path})
+	}
+
+	evidence, err := witness.Verify(policyEnvelope, []cryptoutil.Verifier{verifier}, witness.VerifyWithCollectionEnvelopes(envelopes))
+	if err != nil {
+		return simulatedFailure(err)
+	}
+
+	if _, err := verifyPolicyConstraints(policyEnvelope, evidence, resolver, nil); err != nil {
+		return simulatedFailure(err)
+	}
+
+	return nil
+}
+
+// simulatedFailure keeps a build's failure to one line in the report.
+func simulatedFailure(err error) error {
+	return fmt.Errorf("%v", strings.Join(strings.Fields(err.Error()), " "))
+}
+
+// verifyPolicyConstraints checks the evidence go-witness verified against the policy fields that witness enforces itself.
+// Log inclusion constraints are checked against the Rekor bundles. The result's collections refer to the evidence by
+// its index.
+func verifyPolicyConstraints(policyEnvelope dsse.Envelope, evidence []witness.CollectionEnvelope, resolver groups.Resolver, bundles []rekorentry.Bundle) (policy.Result, error) {
+	p, err := policy.Parse(policyEnvelope.Payload)
+	if err != nil {
+		return policy.Result{}, err
+	}
+
+	p.Groups = resolver
+	p.LogBundles = bundles
+	envelopes := make([]dsse.Envelope, 0, len(evidence))
+	for _, e := range evidence {
+		envelopes = append(envelopes, e.Envelope)
+	}
+
+	return p.Verify(envelopes)
+}
+
+// checkBuildCounters rejects evidence whose build counters are lower than those recorded in the state file, and
+// returns the state updated with the evidence's counters.
+func checkBuildCounters(statePath string, evidence []witness.CollectionEnvelope) (policy.CounterState, error) {
+	state, err := policy.LoadCounterState(statePath)
+	if err != nil {
+		return nil, err
+	}
+
+	envelopes := make([]dsse.Envelope, 0, len(evidence))
+	for _, e := range evidence {
+		envelopes = append(envelopes, e.Envelope)
+	}
+
+	if err := state.Check(envelopes); err != nil {
+		return nil, err
+	}
+
+	return state, nil
+}
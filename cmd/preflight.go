@@ -0,0 +1,154 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/dsse"
+	"github.com/testifysec/witness/options"
+	"github.com/testifysec/witness/pkg/fips"
+	"github.com/testifysec/witness/pkg/preflight"
+)
+
+// certificateWarning is how close to expiring a signing certificate can be before preflight warns about it.
+const certificateWarning = 24 * time.Hour
+
+func PreflightCmd() *cobra.Command {
+	po := options.PreflightOptions{}
+	cmd := &cobra.Command{
+		Use:   "preflight",
+		Short: "Checks that witness is ready to sign and store attestations",
+		Long: "Loads the configured signer and signs a test payload, checks the signing certificate's validity, and " +
+			"checks that the Rekor and Fulcio servers are reachable and that the local clock agrees with them. Exits " +
+			"with a non-zero code if any check fails",
+		SilenceErrors:     true,
+		SilenceUsage:      true,
+		DisableAutoGenTag: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runPreflight(po)
+		},
+	}
+
+	po.AddFlags(cmd)
+	return cmd
+}
+
+func runPreflight(po options.PreflightOptions) error {
+	ctx := context.Background()
+	var signer cryptoutil.Signer
+	checks := []preflight.Check{{
+		Name: "signer",
+		Run: func(ctx context.Context) (preflight.Status, string) {
+			signers, errors := loadSigners(ctx, po.KeyOptions)
+			if len(errors) > 0 {
+				messages := make([]string, 0, len(errors))
+				for _, err := range errors {
+					messages = append(messages, err.Error())
+				}
+
+				return preflight.Fail, strings.Join(messages, "; ")
+			}
+
+			if len(signers) != 1 {
+				return preflight.Fail, fmt.Sprintf("expected one signer, found %d", len(signers))
+			}
+
+			signer = signers[0]
+			return checkSigner(signer)
+		},
+	}}
+
+	checks = append(checks, preflight.Check{
+		Name: "certificate",
+		Run: func(ctx context.Context) (preflight.Status, string) {
+			x509Signer, ok := signer.(*cryptoutil.X509Signer)
+			if !ok {
+				return preflight.Pass, "signer does not use a certificate"
+			}
+
+			return preflight.CheckCertificate(x509Signer.Certificate(), time.Now(), certificateWarning)
+		},
+	})
+
+	if fips.Enabled() {
+		checks = append(checks, preflight.Check{
+			Name: "fips",
+			Run: func(ctx context.Context) (preflight.Status, string) {
+				if signer == nil {
+					return preflight.Fail, "no signer to check"
+				}
+
+				if err := fips.CheckSigner(signer); err != nil {
+					return preflight.Fail, err.Error()
+				}
+
+				return preflight.Pass, "signer is usable in fips mode"
+			},
+		})
+	}
+
+	if po.RekorServer != "" {
+		checks = append(checks, preflight.Check{
+			Name: "rekor",
+			Run:  preflight.CheckClockSkew(http.DefaultClient, strings.TrimSuffix(po.RekorServer, "/")+"/api/v1/log", po.MaxClockSkew),
+		})
+	}
+
+	if po.KeyOptions.FulcioURL != "" {
+		checks = append(checks, preflight.Check{
+			Name: "fulcio",
+			Run:  preflight.CheckClockSkew(http.DefaultClient, po.KeyOptions.FulcioURL, po.MaxClockSkew),
+		})
+	}
+
+	if !preflight.Report(os.Stdout, preflight.Run(ctx, checks)) {
+		return fmt.Errorf("preflight checks failed")
+	}
+
+	return nil
+}
+
+// checkSigner signs a test payload and verifies the signature with the signer's verifier.
+func checkSigner(signer cryptoutil.Signer) (preflight.Status, string) {
+	env, err := dsse.Sign("https://witness.dev/preflight/v0.1", bytes.NewReader([]byte("witness preflight")), signer)
+	if err != nil {
+		return preflight.Fail, fmt.Sprintf("failed to sign: %v", err)
+	}
+
+	verifier, err := signer.Verifier()
+	if err != nil {
+		return preflight.Fail, fmt.Sprintf("failed to get verifier: %v", err)
+	}
+
+	if _, err := env.Verify(dsse.WithVerifiers([]cryptoutil.Verifier{verifier})); err != nil {
+		return preflight.Fail, fmt.Sprintf("signature did not verify: %v", err)
+	}
+
+	keyID, err := signer.KeyID()
+	if err != nil {
+		return preflight.Fail, fmt.Sprintf("failed to get key id: %v", err)
+	}
+
+	return preflight.Pass, fmt.Sprintf("signed and verified a test payload with key %v", keyID)
+}
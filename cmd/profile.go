@@ -0,0 +1,132 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/witness/options"
+	"github.com/testifysec/witness/pkg/fetch"
+	"github.com/testifysec/witness/pkg/network"
+	"github.com/testifysec/witness/pkg/profile"
+)
+
+// profileFlags are the flags that locate and verify the profile itself, which a profile can not set.
+var profileFlags = map[string]bool{"profile-uri": true, "profile-key": true, "publickey": true}
+
+// applyVerifyProfile loads the verification profile and sets the flags it configures. Flags already given on the
+// command line or in the config file must have the value the profile sets.
+func applyVerifyProfile(cmd *cobra.Command, vo *options.VerifyOptions) error {
+	if vo.ProfileKeyPath == "" {
+		return fmt.Errorf("--profile-key is required to verify a verification profile")
+	}
+
+	keyBytes, err := os.ReadFile(vo.ProfileKeyPath)
+	if err != nil {
+		return fmt.Errorf("failed to read profile key: %w", err)
+	}
+
+	verifier, err := cryptoutil.NewVerifierFromReader(bytes.NewReader(keyBytes))
+	if err != nil {
+		return fmt.Errorf("failed to create profile verifier: %w", err)
+	}
+
+	data, err := readFileOrURL(context.Background(), vo.ProfileURI, "downloading the verification profile")
+	if err != nil {
+		return fmt.Errorf("failed to load verification profile: %w", err)
+	}
+
+	p, err := profile.Load(data, time.Now(), verifier)
+	if err != nil {
+		return err
+	}
+
+	values := map[string]string{"policy": p.Policy}
+	if p.RekorServer != "" {
+		values["rekor-server"] = p.RekorServer
+	}
+
+	for name, value := range p.Flags {
+		if profileFlags[name] || values[name] != "" {
+			return fmt.Errorf("verification profile can not set --%v in its flags", name)
+		}
+
+		values[name] = value
+	}
+
+	for name, value := range values {
+		if err := setProfileFlag(cmd.Flags(), name, value); err != nil {
+			return err
+		}
+	}
+
+	if p.PolicyPublicKey != "" {
+		if vo.KeyPath != "" {
+			return fmt.Errorf("--publickey can not be used with a verification profile that sets the policy key")
+		}
+
+		vo.PolicyPublicKey = []byte(p.PolicyPublicKey)
+	}
+
+	return nil
+}
+
+// setProfileFlag sets the flag to the profile's value, failing if it was already set to something else.
+func setProfileFlag(flags *pflag.FlagSet, name, value string) error {
+	f := flags.Lookup(name)
+	if f == nil {
+		return fmt.Errorf("verification profile sets unknown flag --%v", name)
+	}
+
+	current := f.Value.String()
+	if strings.HasSuffix(f.Value.Type(), "Slice") {
+		current = strings.Trim(current, "[]")
+	}
+
+	if current == value {
+		return nil
+	}
+
+	if f.Changed || f.Value.String() != f.DefValue {
+		return fmt.Errorf("--%v is set to %v, but the verification profile requires %v", name, current, value)
+	}
+
+	if err := flags.Set(name, value); err != nil {
+		return fmt.Errorf("failed to set --%v from the verification profile: %w", name, err)
+	}
+
+	return nil
+}
+
+// readFileOrURL reads a local file, or downloads an http(s) URL that may be pinned with a #sha256=<hex> suffix.
+func readFileOrURL(ctx context.Context, location, operation string) ([]byte, error) {
+	if !fetch.IsURL(location) {
+		return os.ReadFile(location)
+	}
+
+	if err := network.Check(operation); err != nil {
+		return nil, err
+	}
+
+	return fetch.Fetch(ctx, location, fetch.DefaultOptions())
+}
@@ -0,0 +1,150 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/dsse"
+	"github.com/testifysec/go-witness/log"
+	"github.com/testifysec/witness/options"
+	"github.com/testifysec/witness/pkg/publish"
+)
+
+// publishDestinations returns the places witness run publishes the signed collection to: each Rekor server, the OCI
+// repository, and Archivista.
+func publishDestinations(ro options.RunOptions, signer cryptoutil.Signer) ([]publish.Destination, error) {
+	destinations := make([]publish.Destination, 0)
+	if len(ro.RekorServers) > 0 {
+		verifier, err := signer.Verifier()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get verifier from signer: %w", err)
+		}
+
+		pubKeyBytes, err := verifier.Bytes()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get bytes from verifier: %w", err)
+		}
+
+		bundlePaths := rekorBundlePaths(rekorBundlePath(ro.OutFilePath, ro.RekorBundlePath), ro.RekorServers)
+		for i, server := range ro.RekorServers {
+			server, bundlePath := server, bundlePaths[i]
+			destinations = append(destinations, publish.Destination{
+				Kind:   "rekor",
+				Target: server,
+				Publish: func(ctx context.Context, env dsse.Envelope, envBytes []byte) ([]string, error) {
+					location, err := uploadToRekor(ctx, server, ro.RekorEntryType, ro.RekorRetries, env, pubKeyBytes, bundlePath, ro.RekorBundlePath != "")
+					if err != nil {
+						return nil, err
+					}
+
+					return []string{location}, nil
+				},
+			})
+		}
+	}
+
+	if ro.AttestationStorage != "" {
+		destinations = append(destinations, publish.Destination{
+			Kind:   "oci",
+			Target: ro.AttestationStorage,
+			Publish: func(ctx context.Context, env dsse.Envelope, envBytes []byte) ([]string, error) {
+				references, err := storeInOCIRegistry(ctx, ro.AttestationStorage, env, envBytes)
+				if err != nil {
+					return nil, err
+				}
+
+				for _, reference := range references {
+					log.Infof("Attestation stored at %v", reference)
+				}
+
+				return references, nil
+			},
+		})
+	}
+
+	if ro.Archivist.Server != "" {
+		destinations = append(destinations, publish.Destination{
+			Kind:   "archivista",
+			Target: ro.Archivist.Server,
+			Publish: func(ctx context.Context, env dsse.Envelope, envBytes []byte) ([]string, error) {
+				gitoid, err := storeInArchivist(ctx, ro.Archivist, envBytes)
+				if err != nil {
+					return nil, err
+				}
+
+				return []string{gitoid}, nil
+			},
+		})
+	}
+
+	return destinations, nil
+}
+
+// rekorBundlePaths returns where the bundle from each Rekor server goes. A single server's bundle is written to
+// bundlePath. With several, each server's host is added before bundlePath's extension so every log's bundle is kept
+// next to the attestation, where witness verify and witness sync look for them.
+func rekorBundlePaths(bundlePath string, servers []string) []string {
+	paths := make([]string, len(servers))
+	if bundlePath == "" || len(servers) == 1 {
+		for i := range paths {
+			paths[i] = bundlePath
+		}
+
+		return paths
+	}
+
+	ext := filepath.Ext(bundlePath)
+	stem := strings.TrimSuffix(bundlePath, ext)
+	seen := make(map[string]int)
+	for i, server := range servers {
+		host := server
+		if u, err := url.Parse(server); err == nil && u.Host != "" {
+			host = u.Host
+		}
+
+		host = strings.NewReplacer(":", "_", "/", "_").Replace(host)
+		// servers on the same host, under different paths, are numbered so their bundles don't overwrite each other
+		seen[host]++
+		if seen[host] > 1 {
+			host = fmt.Sprintf("%v-%d", host, seen[host])
+		}
+
+		paths[i] = fmt.Sprintf("%v.%v%v", stem, host, ext)
+	}
+
+	return paths
+}
+
+// writePublishReport writes the report of where the signed collection was published to path as json.
+func writePublishReport(path string, report publish.Report) error {
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal publish report: %w", err)
+	}
+
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write publish report: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,183 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"crypto"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+
+	witness "github.com/testifysec/go-witness"
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/dsse"
+	"github.com/testifysec/witness/options"
+	"github.com/testifysec/witness/pkg/digest"
+	"github.com/testifysec/witness/pkg/policy"
+	"github.com/testifysec/witness/pkg/receipt"
+	"github.com/testifysec/witness/pkg/rekorentry"
+)
+
+// receiptOptions holds the verification settings and trust material a receipt is only valid for. A receipt issued under
+// one set of options must not short-circuit a verification under another.
+type receiptOptions struct {
+	// TrustedPolicy is the policy with the trust root's roots and keys applied, as it is verified
+	TrustedPolicy  dsse.Envelope        `json:"trustedpolicy"`
+	PolicyKeyID    string               `json:"policykeyid"`
+	Requirements   []string             `json:"requirements"`
+	ExpandArchive  bool                 `json:"expandarchive"`
+	RekorServer    string               `json:"rekorserver"`
+	RekorPublicKey []byte               `json:"rekorpublickey"`
+	RekorBundles   []rekorentry.Bundle  `json:"rekorbundles"`
+	GitHubRepo     string               `json:"githubrepo"`
+	Storage        string               `json:"attestationstorage"`
+	Groups         options.GroupOptions `json:"groups"`
+}
+
+func newReceiptOptions(vo options.VerifyOptions, trustedPolicy dsse.Envelope, policyVerifier cryptoutil.Verifier, logBundles []rekorentry.Bundle) (receiptOptions, error) {
+	ro := receiptOptions{
+		TrustedPolicy:  trustedPolicy,
+		Requirements:   append([]string{}, vo.Requirements...),
+		ExpandArchive:  vo.ExpandArchive,
+		RekorServer:    vo.RekorServer,
+		RekorPublicKey: vo.RekorPublicKey,
+		RekorBundles:   logBundles,
+		GitHubRepo:     vo.GitHubRepository,
+		Storage:        vo.AttestationStorage,
+		Groups:         vo.Groups,
+	}
+
+	sort.Strings(ro.Requirements)
+	if policyVerifier != nil {
+		keyID, err := policyVerifier.KeyID()
+		if err != nil {
+			return receiptOptions{}, fmt.Errorf("failed to get policy verifier's key id: %w", err)
+		}
+
+		ro.PolicyKeyID = keyID
+	}
+
+	if vo.RekorPublicKeyPath != "" {
+		rekorKey, err := os.ReadFile(vo.RekorPublicKeyPath)
+		if err != nil {
+			return receiptOptions{}, fmt.Errorf("failed to read rekor public key: %w", err)
+		}
+
+		ro.RekorPublicKey = rekorKey
+	}
+
+	return ro, nil
+}
+
+// receiptUnsupported returns why a receipt can not stand in for verifying against the policy, or nil if it can. Build
+// counters and the delegated and base image policies are state and content outside of what a receipt is keyed on.
+func receiptUnsupported(vo options.VerifyOptions, p policy.Policy) error {
+	if vo.BuildCounterState != "" {
+		return fmt.Errorf("build counters must be checked on every verification")
+	}
+
+	if len(p.Delegations()) > 0 {
+		return fmt.Errorf("the policy delegates steps to other policies")
+	}
+
+	if len(p.BaseImageSteps()) > 0 {
+		return fmt.Errorf("the policy verifies base images")
+	}
+
+	return nil
+}
+
+func newVerificationReceipt(artifactFilePath string, policyEnvelope dsse.Envelope, collectionEnvelopes []witness.CollectionEnvelope, ro receiptOptions) (receipt.Receipt, error) {
+	subjectDigest := ""
+	if artifactFilePath != "" {
+		digestSet, err := digest.CalculateFile(artifactFilePath, []crypto.Hash{crypto.SHA256})
+		if err != nil {
+			return receipt.Receipt{}, fmt.Errorf("failed to calculate artifact file's hash: %w", err)
+		}
+
+		subjectDigest = digestSet[crypto.SHA256]
+	}
+
+	envelopes := make([]dsse.Envelope, 0, len(collectionEnvelopes))
+	for _, env := range collectionEnvelopes {
+		envelopes = append(envelopes, env.Envelope)
+	}
+
+	return receipt.New(policyEnvelope, subjectDigest, envelopes, ro, nil)
+}
+
+func loadReceiptSigner(keyPath string) (cryptoutil.Signer, error) {
+	if keyPath == "" {
+		return nil, fmt.Errorf("a receipt key is required to sign and verify verification receipts")
+	}
+
+	return loadKeySigner(keyPath)
+}
+
+// loadKeySigner returns the signer of the key file at keyPath.
+func loadKeySigner(keyPath string) (cryptoutil.Signer, error) {
+	signers, errors := loadSigners(context.Background(), options.KeyOptions{KeyPath: keyPath})
+	if len(errors) > 0 {
+		return nil, errors[0]
+	}
+
+	if len(signers) != 1 {
+		return nil, fmt.Errorf("expected one signer, got %d", len(signers))
+	}
+
+	return signers[0], nil
+}
+
+// checkReceipt returns the receipt stored at path if it is signed by signer and was issued for the same inputs as expected
+func checkReceipt(path string, signer cryptoutil.Signer, expected receipt.Receipt, p policy.Policy, vo options.VerifyOptions) (receipt.Receipt, error) {
+	if err := receiptUnsupported(vo, p); err != nil {
+		return receipt.Receipt{}, err
+	}
+
+	verifier, err := signer.Verifier()
+	if err != nil {
+		return receipt.Receipt{}, fmt.Errorf("failed to get verifier from receipt signer: %w", err)
+	}
+
+	receiptFile, err := os.Open(path)
+	if err != nil {
+		return receipt.Receipt{}, fmt.Errorf("failed to open receipt: %w", err)
+	}
+
+	defer receiptFile.Close()
+	r, err := receipt.Load(receiptFile, verifier)
+	if err != nil {
+		return receipt.Receipt{}, err
+	}
+
+	return r, r.Check(expected, vo.ReceiptMaxAge)
+}
+
+func writeReceipt(path string, signer cryptoutil.Signer, r receipt.Receipt) error {
+	env, err := receipt.Sign(r, signer)
+	if err != nil {
+		return fmt.Errorf("failed to sign receipt: %w", err)
+	}
+
+	out, err := loadOutfile(path)
+	if err != nil {
+		return err
+	}
+
+	defer out.Close()
+	return json.NewEncoder(out).Encode(&env)
+}
@@ -0,0 +1,214 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	witness "github.com/testifysec/go-witness"
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/dsse"
+	"github.com/testifysec/go-witness/log"
+	"github.com/testifysec/witness/pkg/rekorentry"
+)
+
+// rekorBundleSuffix is appended to an attestation file's path to name the Rekor bundle written next to it.
+const rekorBundleSuffix = ".rekor.json"
+
+// rekorBundlePath returns where the Rekor bundle of the envelope written to outFilePath goes, or an empty string if
+// the envelope is written to stdout and no bundlePath was given.
+func rekorBundlePath(outFilePath, bundlePath string) string {
+	if bundlePath != "" {
+		return bundlePath
+	}
+
+	if outFilePath == "" {
+		return ""
+	}
+
+	return outFilePath + rekorBundleSuffix
+}
+
+// uploadToRekor creates a Rekor entry of the kind for the envelope, retrying as rekorentry.Upload does, and writes the
+// entry's bundle to bundlePath as the receipt of the upload. If the entry can't be created, the pending upload is
+// written to bundlePath instead so witness sync can resume it. A bundle that can't be fetched once the entry is
+// created is only an error if bundleRequired.
+func uploadToRekor(ctx context.Context, server, kind string, retries int, env dsse.Envelope, publicKey []byte, bundlePath string, bundleRequired bool) (string, error) {
+	proposed, err := rekorentry.ProposedEntry(kind, env, publicKey)
+	if err != nil {
+		return "", err
+	}
+
+	opts := rekorentry.DefaultUploadOptions()
+	opts.Retries = retries
+	location, err := rekorentry.New(server).Upload(ctx, proposed, opts)
+	if err != nil {
+		if bundlePath == "" {
+			return "", err
+		}
+
+		pending := rekorentry.Bundle{Pending: &rekorentry.Pending{
+			Server:      server,
+			Kind:        kind,
+			Entry:       proposed,
+			Error:       err.Error(),
+			LastAttempt: time.Now().UTC(),
+		}}
+
+		if writeErr := rekorentry.WriteBundle(bundlePath, pending); writeErr != nil {
+			return "", fmt.Errorf("%v, and failed to record the pending upload: %w", err, writeErr)
+		}
+
+		return "", fmt.Errorf("%w, the pending upload was written to %v to be resumed with witness sync", err, bundlePath)
+	}
+
+	log.Infof("Rekor entry added at %v\n", location)
+	if bundlePath == "" {
+		return location, nil
+	}
+
+	if err := writeRekorBundle(ctx, server, location, bundlePath); err != nil {
+		// the bundle is only required if its path was asked for
+		if bundleRequired {
+			return "", fmt.Errorf("failed to write rekor bundle: %w", err)
+		}
+
+		log.Warnf("failed to write rekor bundle: %v", err)
+	} else {
+		log.Infof("Rekor bundle written to %v", bundlePath)
+	}
+
+	return location, nil
+}
+
+// resumeRekorUpload creates the entry of a pending upload and replaces the pending upload with the entry's bundle.
+// The pending upload is left in place, with the latest error, if either fails.
+func resumeRekorUpload(ctx context.Context, bundlePath string, pending rekorentry.Pending, retries int) (string, error) {
+	opts := rekorentry.DefaultUploadOptions()
+	opts.Retries = retries
+	location, err := rekorentry.New(pending.Server).Upload(ctx, pending.Entry, opts)
+	if err == nil {
+		if err = writeRekorBundle(ctx, pending.Server, location, bundlePath); err != nil {
+			err = fmt.Errorf("rekor entry was added at %v, but its bundle could not be written: %w", location, err)
+		}
+	}
+
+	if err != nil {
+		pending.Error = err.Error()
+		pending.LastAttempt = time.Now().UTC()
+		if writeErr := rekorentry.WriteBundle(bundlePath, rekorentry.Bundle{Pending: &pending}); writeErr != nil {
+			return "", fmt.Errorf("%v, and failed to update the pending upload: %w", err, writeErr)
+		}
+
+		return "", err
+	}
+
+	return location, nil
+}
+
+// writeRekorBundle fetches the bundle of the Rekor entry at the location returned when it was created and writes it to
+// path.
+func writeRekorBundle(ctx context.Context, rekorServer, location, path string) error {
+	bundle, err := rekorentry.New(rekorServer).GetBundle(ctx, rekorentry.UUIDFromLocation(location))
+	if err != nil {
+		return err
+	}
+
+	return rekorentry.WriteBundle(path, bundle)
+}
+
+// rekorBundleFiles returns the Rekor bundles written next to the attestation file: the bundle from a single Rekor
+// server, and the bundle from each log when the attestation was published to several.
+func rekorBundleFiles(attestationPath string) ([]string, error) {
+	paths := make([]string, 0)
+	if _, err := os.Stat(attestationPath + rekorBundleSuffix); err == nil {
+		paths = append(paths, attestationPath+rekorBundleSuffix)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	ext := filepath.Ext(rekorBundleSuffix)
+	prefix := filepath.Base(attestationPath) + strings.TrimSuffix(rekorBundleSuffix, ext) + "."
+	entries, err := os.ReadDir(filepath.Dir(attestationPath))
+	if errors.Is(err, os.ErrNotExist) {
+		return paths, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasPrefix(entry.Name(), prefix) && strings.HasSuffix(entry.Name(), ext) && len(entry.Name()) > len(prefix+ext) {
+			paths = append(paths, filepath.Join(filepath.Dir(attestationPath), entry.Name()))
+		}
+	}
+
+	return paths, nil
+}
+
+// loadRekorBundles reads the bundles given with --rekor-bundle and the bundles found next to the attestation files.
+// Uploads that are still pending prove nothing, so they are left out with a warning.
+func loadRekorBundles(bundlePaths, attestationPaths []string) ([]rekorentry.Bundle, error) {
+	paths := append([]string{}, bundlePaths...)
+	for _, path := range attestationPaths {
+		found, err := rekorBundleFiles(path)
+		if err != nil {
+			return nil, err
+		}
+
+		paths = append(paths, found...)
+	}
+
+	bundles := make([]rekorentry.Bundle, 0, len(paths))
+	for _, path := range paths {
+		bundle, err := rekorentry.ReadBundle(path)
+		if err != nil {
+			return nil, err
+		}
+
+		if bundle.Pending != nil {
+			log.Warnf("the rekor upload recorded in %v is still pending, resume it with witness sync", path)
+			continue
+		}
+
+		bundles = append(bundles, bundle)
+	}
+
+	return bundles, nil
+}
+
+// verifyRekorBundles checks offline that each envelope was logged in the Rekor log with the pinned key, failing if
+// any envelope has no bundle proving it.
+func verifyRekorBundles(envs []witness.CollectionEnvelope, bundles []rekorentry.Bundle, rekorKey cryptoutil.Verifier) error {
+	for _, env := range envs {
+		err := fmt.Errorf("no rekor bundle was found")
+		for _, bundle := range bundles {
+			if err = rekorentry.VerifyBundle(bundle, env.Envelope, rekorKey); err == nil {
+				break
+			}
+		}
+
+		if err != nil {
+			return fmt.Errorf("failed to verify the rekor entry of %v: %w", env.Reference, err)
+		}
+	}
+
+	return nil
+}
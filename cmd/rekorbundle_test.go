@@ -0,0 +1,119 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	witness "github.com/testifysec/go-witness"
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/witness/options"
+	"github.com/testifysec/witness/pkg/rekorentry"
+	"github.com/testifysec/witness/pkg/tuf"
+)
+
+func TestRekorBundlePath(t *testing.T) {
+	require.Equal(t, "", rekorBundlePath("", ""))
+	require.Equal(t, "build.json.rekor.json", rekorBundlePath("build.json", ""))
+	require.Equal(t, "bundle.json", rekorBundlePath("build.json", "bundle.json"))
+}
+
+func TestRekorBundlePaths(t *testing.T) {
+	require.Equal(t, []string{"build.json.rekor.json"}, rekorBundlePaths("build.json.rekor.json", []string{"https://rekor.sigstore.dev"}))
+	require.Equal(t, []string{"", ""}, rekorBundlePaths("", []string{"https://rekor.sigstore.dev", "https://rekor.internal"}))
+	require.Equal(t, []string{
+		"build.json.rekor.rekor.sigstore.dev.json",
+		"build.json.rekor.rekor.internal_8443.json",
+		"build.json.rekor.rekor.internal_8443-2.json",
+	}, rekorBundlePaths("build.json.rekor.json", []string{"https://rekor.sigstore.dev", "https://rekor.internal:8443", "https://rekor.internal:8443/staging"}))
+}
+
+func TestLoadRekorBundles(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, bundle rekorentry.Bundle) string {
+		data, err := json.Marshal(bundle)
+		require.NoError(t, err)
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.WriteFile(path, data, 0644))
+		return path
+	}
+
+	build := filepath.Join(dir, "build.json")
+	write("build.json"+rekorBundleSuffix, rekorentry.Bundle{UUID: "build"})
+	write("build.json.rekor.rekor.internal.json", rekorentry.Bundle{UUID: "internal"})
+	write("build.json.rekor.rekor.sigstore.dev.json", rekorentry.Bundle{UUID: "public"})
+	write("other.json.rekor.rekor.sigstore.dev.json", rekorentry.Bundle{UUID: "other"})
+	explicit := write("explicit.json", rekorentry.Bundle{UUID: "explicit"})
+
+	bundles, err := loadRekorBundles([]string{explicit}, []string{build, filepath.Join(dir, "test.json")})
+	require.NoError(t, err)
+	require.Len(t, bundles, 4)
+	require.Equal(t, "explicit", bundles[0].UUID)
+	require.Equal(t, "build", bundles[1].UUID)
+	require.Equal(t, "internal", bundles[2].UUID)
+	require.Equal(t, "public", bundles[3].UUID)
+
+	_, err = loadRekorBundles([]string{filepath.Join(dir, "missing.json")}, nil)
+	require.Error(t, err)
+}
+
+func TestVerifyRekorBundlesRequiresBundle(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	verifier, err := cryptoutil.NewECDSASigner(key, crypto.SHA256).Verifier()
+	require.NoError(t, err)
+
+	envs := []witness.CollectionEnvelope{{Reference: "build.json"}}
+	err = verifyRekorBundles(envs, nil, verifier)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "build.json")
+	require.Error(t, verifyRekorBundles(envs, []rekorentry.Bundle{{UUID: "unsigned"}}, verifier))
+	require.NoError(t, verifyRekorBundles(nil, nil, verifier))
+}
+
+func TestCheckRekorBundlesTrustRootKey(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	keyPEM, err := cryptoutil.PublicPemBytes(key.Public())
+	require.NoError(t, err)
+	verifier, err := cryptoutil.NewECDSASigner(key, crypto.SHA256).Verifier()
+	require.NoError(t, err)
+	wantKeyID, err := verifier.KeyID()
+	require.NoError(t, err)
+
+	envs := []witness.CollectionEnvelope{{Reference: "build.json"}}
+	vo := options.VerifyOptions{}
+	require.NoError(t, checkRekorBundles(vo, envs, nil))
+
+	useTrustRootRekorKey(&vo, tuf.TrustRoot{Targets: []tuf.Target{{Name: "rekor.pub", Usage: tuf.Rekor, Data: keyPEM}}})
+	rekorKey, err := resolveRekorPublicKey(vo)
+	require.NoError(t, err)
+	require.NotNil(t, rekorKey)
+	keyID, err := rekorKey.KeyID()
+	require.NoError(t, err)
+	require.Equal(t, wantKeyID, keyID)
+
+	err = checkRekorBundles(vo, envs, nil)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "build.json")
+}
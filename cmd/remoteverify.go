@@ -0,0 +1,159 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/log"
+	"github.com/testifysec/witness/options"
+	"github.com/testifysec/witness/pkg/network"
+	"github.com/testifysec/witness/pkg/progress"
+	"github.com/testifysec/witness/pkg/remoteverify"
+)
+
+// runVerifyRemote asks a remote verification service to verify the artifact against the referenced policy, and
+// succeeds if the service's signed result says it passed. The result is written to --vsa-out whether it passed or not.
+func runVerifyRemote(vo options.VerifyOptions) error {
+	if err := checkRemoteVerifyOptions(vo); err != nil {
+		return err
+	}
+
+	if err := network.Check("verifying with a remote verification service"); err != nil {
+		return err
+	}
+
+	verifiers := make([]cryptoutil.Verifier, 0, len(vo.Remote.KeyPaths))
+	for _, path := range vo.Remote.KeyPaths {
+		keyVerifiers, err := loadVerifiers(path)
+		if err != nil {
+			return fmt.Errorf("failed to load remote verification service key: %w", err)
+		}
+
+		verifiers = append(verifiers, keyVerifiers...)
+	}
+
+	name, digestSet, err := remoteArtifactDigest(vo)
+	if err != nil {
+		return err
+	}
+
+	opts := remoteverify.DefaultOptions()
+	opts.MaxAge = vo.Remote.MaxAge
+	opts.Timeout = vo.Remote.Timeout
+	client := remoteverify.New(vo.Remote.URL, verifiers, opts)
+	doneVerifying := progress.Start("Verifying with " + vo.Remote.URL)
+	result, err := client.Verify(context.Background(), vo.PolicyFilePath, name, digestSet)
+	doneVerifying()
+	if result.Envelope.Payload != nil && vo.VSAOutPath != "" {
+		if writeErr := writeRemoteResult(vo.VSAOutPath, result); writeErr != nil {
+			if err == nil {
+				return fmt.Errorf("failed to write verification result: %w", writeErr)
+			}
+
+			log.Errorf("failed to write verification result: %v", writeErr)
+		}
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to verify policy: %w", err)
+	}
+
+	log.Infof("Verification succeeded by %v at %v", result.Summary.Verifier.ID, result.Summary.TimeVerified)
+	log.Info("Evidence:")
+	for i, input := range result.Summary.InputAttestations {
+		log.Info(fmt.Sprintf("%d: %s", i, input.URI))
+	}
+
+	return nil
+}
+
+// checkRemoteVerifyOptions fails if the options need local verification, which the remote service does instead.
+func checkRemoteVerifyOptions(vo options.VerifyOptions) error {
+	if len(vo.Remote.KeyPaths) == 0 {
+		return fmt.Errorf("--remote-key is required to trust the results of a remote verification service")
+	}
+
+	if vo.PolicyFilePath == "" {
+		return fmt.Errorf("--policy must name the policy for the remote verification service to verify against")
+	}
+
+	if (vo.ArtifactFilePath == "") == (vo.Remote.ArtifactDigest == "") {
+		return fmt.Errorf("exactly one of --artifactfile or --artifact-digest is required to verify with --remote")
+	}
+
+	if vo.Output == "json" {
+		return fmt.Errorf("json output is not supported with --remote")
+	}
+
+	local := []struct {
+		flag string
+		set  bool
+	}{
+		{"--attestations", len(vo.AttestationFilePaths) > 0},
+		{"--expand-archive", vo.ExpandArchive},
+		{"--rekor-server", vo.RekorServer != ""},
+		{"--rekor-bundle", len(vo.RekorBundlePaths) > 0},
+		{"--github-repo", vo.GitHubRepository != ""},
+		{"--attestation-storage", vo.AttestationStorage != ""},
+		{"--receipt", vo.ReceiptPath != ""},
+		{"--evidence-out", vo.EvidenceOutPath != ""},
+		{"--build-counter-state", vo.BuildCounterState != ""},
+		{"--require", len(vo.Requirements) > 0},
+		{"--envelope", vo.EnvelopePath != ""},
+		{"--vsa-key", vo.VSAKeyPath != ""},
+		{"--watch", vo.Watch.Enabled},
+	}
+
+	unsupported := make([]string, 0)
+	for _, l := range local {
+		if l.set {
+			unsupported = append(unsupported, l.flag)
+		}
+	}
+
+	if len(unsupported) > 0 {
+		return fmt.Errorf("--remote can not be used with %v, since the remote verification service finds and verifies the evidence", strings.Join(unsupported, ", "))
+	}
+
+	return nil
+}
+
+// remoteArtifactDigest returns the name and digests of the artifact sent to the remote verification service.
+func remoteArtifactDigest(vo options.VerifyOptions) (string, cryptoutil.DigestSet, error) {
+	if vo.Remote.ArtifactDigest != "" {
+		digestSet, err := remoteverify.ParseDigest(vo.Remote.ArtifactDigest)
+		return vo.Remote.ArtifactDigest, digestSet, err
+	}
+
+	doneHashing := progress.Start("Hashing artifact")
+	digestSet, err := loadArtifactDigestSet(context.Background(), vo.ArtifactFilePath)
+	doneHashing()
+	return vo.ArtifactFilePath, digestSet, err
+}
+
+func writeRemoteResult(path string, result remoteverify.Result) error {
+	out, err := loadOutfile(path)
+	if err != nil {
+		return err
+	}
+
+	defer out.Close()
+	return json.NewEncoder(out).Encode(&result.Envelope)
+}
@@ -0,0 +1,115 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/dsse"
+	"github.com/testifysec/witness/options"
+	"github.com/testifysec/witness/pkg/remoteverify"
+	"github.com/testifysec/witness/pkg/vsa"
+)
+
+func TestRunVerifyRemote(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	pubBytes, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	keyPath := filepath.Join(dir, "judge.pub")
+	require.NoError(t, os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubBytes}), 0644))
+
+	result := vsa.ResultPassed
+	signer := cryptoutil.NewECDSASigner(key, crypto.SHA256)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := remoteverify.Request{}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		summary := vsa.Summary{
+			Verifier:           vsa.Verifier{ID: "https://judge.example.com"},
+			TimeVerified:       time.Now(),
+			Policy:             vsa.ResourceDescriptor{URI: req.Policy},
+			InputAttestations:  []vsa.ResourceDescriptor{{URI: "build.json"}},
+			VerificationResult: result,
+		}
+
+		env, err := vsa.Sign(summary, map[string]cryptoutil.DigestSet{req.Subject.Name: {crypto.SHA256: req.Subject.Digest["sha256"]}}, signer)
+		require.NoError(t, err)
+		require.NoError(t, json.NewEncoder(w).Encode(&env))
+	}))
+
+	defer server.Close()
+	vo := options.VerifyOptions{
+		PolicyFilePath: "prod-images",
+		VSAOutPath:     filepath.Join(dir, "result.json"),
+		Remote: options.RemoteVerifyOptions{
+			URL:            server.URL,
+			KeyPaths:       []string{keyPath},
+			MaxAge:         time.Minute,
+			ArtifactDigest: "sha256:" + strings.Repeat("ab", 32),
+		},
+	}
+
+	require.NoError(t, runVerifyRemote(vo))
+	data, err := os.ReadFile(vo.VSAOutPath)
+	require.NoError(t, err)
+	env := dsse.Envelope{}
+	require.NoError(t, json.Unmarshal(data, &env))
+	require.Len(t, env.Signatures, 1)
+
+	result = vsa.ResultFailed
+	err = runVerifyRemote(vo)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "does not satisfy policy prod-images")
+}
+
+func TestCheckRemoteVerifyOptions(t *testing.T) {
+	vo := options.VerifyOptions{
+		PolicyFilePath:       "prod-images",
+		AttestationFilePaths: []string{"build.json"},
+		RekorServer:          "https://rekor.sigstore.dev",
+		Remote:               options.RemoteVerifyOptions{URL: "https://judge.example.com", KeyPaths: []string{"judge.pub"}, ArtifactDigest: "sha256:abc"},
+	}
+
+	err := checkRemoteVerifyOptions(vo)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "--attestations, --rekor-server")
+
+	vo.AttestationFilePaths, vo.RekorServer, vo.ArtifactFilePath = nil, "", "app"
+	err = checkRemoteVerifyOptions(vo)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "exactly one of --artifactfile or --artifact-digest")
+
+	vo.Remote.KeyPaths = nil
+	err = checkRemoteVerifyOptions(vo)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "--remote-key")
+}
@@ -0,0 +1,144 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	witness "github.com/testifysec/go-witness"
+	"github.com/testifysec/go-witness/dsse"
+	"github.com/testifysec/go-witness/log"
+	"github.com/testifysec/witness/options"
+	"github.com/testifysec/witness/pkg/fetch"
+	"github.com/testifysec/witness/pkg/fips"
+	"github.com/testifysec/witness/pkg/gitref"
+	"github.com/testifysec/witness/pkg/policy"
+)
+
+func parseRequirements(expressions []string) ([]policy.Requirement, error) {
+	requirements := make([]policy.Requirement, 0, len(expressions))
+	for _, expression := range expressions {
+		r, err := policy.ParseRequirement(expression)
+		if err != nil {
+			return nil, err
+		}
+
+		requirements = append(requirements, r)
+	}
+
+	return requirements, nil
+}
+
+func checkRequirements(requirements []policy.Requirement, evidence []witness.CollectionEnvelope) error {
+	if len(requirements) == 0 {
+		return nil
+	}
+
+	envelopes := make([]dsse.Envelope, 0, len(evidence))
+	for _, e := range evidence {
+		envelopes = append(envelopes, e.Envelope)
+	}
+
+	return policy.CheckRequirements(requirements, envelopes)
+}
+
+// runVerifyRequirements checks the requirements against attestations signed by --publickey without a policy, for users
+// not yet ready to author and sign one.
+func runVerifyRequirements(vo options.VerifyOptions, requirements []policy.Requirement) error {
+	if vo.KeyPath == "" {
+		return fmt.Errorf("the public key attestations are signed with is required to check requirements without a policy")
+	}
+
+	if vo.RekorServer != "" || vo.RekorPublicKeyPath != "" || vo.GitHubRepository != "" || vo.ReceiptPath != "" || vo.ExpandArchive || vo.EvidenceOutPath != "" || vo.BuildCounterState != "" {
+		return fmt.Errorf("rekor, github, receipts, archives, evidence bundles, and build counters require a policy")
+	}
+
+	if gitref.IsRef(vo.ArtifactFilePath) {
+		return fmt.Errorf("git artifacts require a policy")
+	}
+
+	verifiers, err := loadVerifiers(vo.KeyPath)
+	if err != nil {
+		return err
+	}
+
+	if fips.Enabled() {
+		for _, verifier := range verifiers {
+			if err := fips.CheckVerifier(verifier); err != nil {
+				return fmt.Errorf("verifier is not usable in fips mode: %w", err)
+			}
+		}
+	}
+
+	attestationPaths, attestationURLs := splitAttestationURLs(vo.AttestationFilePaths)
+	if err := checkVerifyOffline(vo, false, attestationURLs); err != nil {
+		return err
+	}
+
+	envs, err := loadEnvelopesFromDisk(attestationPaths, nil)
+	if err != nil {
+		return fmt.Errorf("failed to load attestation files: %w", err)
+	}
+
+	if len(attestationURLs) > 0 {
+		fetchOpts := fetch.DefaultOptions()
+		fetchOpts.MaxSize = vo.AttestationMaxSize
+		fetchOpts.Retries = vo.AttestationRetries
+		urlEnvs, err := loadEnvelopesFromURLs(context.Background(), attestationURLs, fetchOpts, nil)
+		if err != nil {
+			return fmt.Errorf("failed to download attestation files: %w", err)
+		}
+
+		envs = append(envs, urlEnvs...)
+	}
+
+	verifiedEvidence := make([]witness.CollectionEnvelope, 0, len(envs))
+	for _, env := range envs {
+		if _, err := env.Envelope.Verify(dsse.WithVerifiers(verifiers)); err != nil {
+			log.Debugf("(verify) skipping %v: %v", env.Reference, err)
+			continue
+		}
+
+		verifiedEvidence = append(verifiedEvidence, env)
+	}
+
+	if len(verifiedEvidence) == 0 {
+		return fmt.Errorf("no attestations are signed by the public key")
+	}
+
+	if vo.ArtifactFilePath != "" {
+		artifactDigestSet, err := loadArtifactDigestSet(context.Background(), vo.ArtifactFilePath)
+		if err != nil {
+			return err
+		}
+
+		if err := checkArtifactSubject(verifiedEvidence, artifactDigestSet); err != nil {
+			return fmt.Errorf("failed to verify requirements: %w", err)
+		}
+	}
+
+	if err := checkRequirements(requirements, verifiedEvidence); err != nil {
+		return fmt.Errorf("failed to verify requirements: %w", err)
+	}
+
+	log.Info("Verification succeeded")
+	log.Info("Evidence:")
+	for i, e := range verifiedEvidence {
+		log.Info(fmt.Sprintf("%d: %s", i, e.Reference))
+	}
+
+	return nil
+}
@@ -15,18 +15,26 @@
 package cmd
 
 import (
+	"crypto"
 	"fmt"
 	"os"
 
 	"github.com/spf13/cobra"
 	"github.com/testifysec/go-witness/log"
 	"github.com/testifysec/witness/options"
+	"github.com/testifysec/witness/pkg/digest"
+	"github.com/testifysec/witness/pkg/fips"
+	"github.com/testifysec/witness/pkg/network"
+	"github.com/testifysec/witness/pkg/progress"
 )
 
 var (
 	ro = &options.RootOptions{}
 )
 
+// digestBenchmarkSize is how much data each digest backend hashes when selecting the fastest.
+const digestBenchmarkSize = 8 * 1024 * 1024
+
 func New() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:               "witness",
@@ -38,6 +46,18 @@ func New() *cobra.Command {
 	cmd.AddCommand(SignCmd())
 	cmd.AddCommand(VerifyCmd())
 	cmd.AddCommand(RunCmd())
+	cmd.AddCommand(AttestCmd())
+	cmd.AddCommand(AttestorsCmd())
+	cmd.AddCommand(MergeCmd())
+	cmd.AddCommand(TranslateCmd())
+	cmd.AddCommand(InspectCmd())
+	cmd.AddCommand(SearchCmd())
+	cmd.AddCommand(PolicyCmd())
+	cmd.AddCommand(LogCmd())
+	cmd.AddCommand(PreflightCmd())
+	cmd.AddCommand(ServeCmd())
+	cmd.AddCommand(StoreCmd())
+	cmd.AddCommand(SyncCmd())
 	cmd.AddCommand(CompletionCmd())
 	cmd.AddCommand(versionCmd())
 	cobra.OnInitialize(func() { preRoot(cmd, ro) })
@@ -58,11 +78,50 @@ func preRoot(cmd *cobra.Command, ro *options.RootOptions) {
 		logger.l.Fatal(err)
 	}
 
+	if ro.FIPS {
+		fips.Enable()
+	}
+
+	if err := network.SetProxy(ro.Proxy, ro.NoProxy); err != nil {
+		logger.l.Fatal(err)
+	}
+
+	if ro.Offline {
+		network.EnableOffline()
+	}
+
+	if ro.Progress {
+		progress.Enable(os.Stderr)
+	}
+
+	if err := selectDigestBackend(ro.DigestBackend); err != nil {
+		logger.l.Fatal(err)
+	}
+
 	if err := initConfig(cmd, ro); err != nil {
 		logger.l.Fatal(err)
 	}
 }
 
+func selectDigestBackend(name string) error {
+	// only the standard library's implementations use the validated module in fips builds
+	if fips.Enabled() && name != digest.Go {
+		return fmt.Errorf("only the %v digest backend can be used in fips mode", digest.Go)
+	}
+
+	if name != "auto" {
+		return digest.Select(crypto.SHA256, name)
+	}
+
+	backend, err := digest.SelectFastest(crypto.SHA256, digestBenchmarkSize)
+	if err != nil {
+		return err
+	}
+
+	log.Debugf("Using %v sha256 backend", backend.Name)
+	return nil
+}
+
 func loadOutfile(outFilePath string) (*os.File, error) {
 	var err error
 	out := os.Stdout
@@ -15,16 +15,47 @@
 package cmd
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
+	"os"
 
 	"github.com/spf13/cobra"
-	witness "github.com/testifysec/go-witness"
 	"github.com/testifysec/go-witness/attestation"
+	"github.com/testifysec/go-witness/attestation/commandrun"
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/dsse"
+	"github.com/testifysec/go-witness/intoto"
 	"github.com/testifysec/go-witness/log"
-	"github.com/testifysec/go-witness/rekor"
 	"github.com/testifysec/witness/options"
+	"github.com/testifysec/witness/pkg/artifacts"
+	"github.com/testifysec/witness/pkg/attestation/container"
+	"github.com/testifysec/witness/pkg/attestation/deadline"
+	"github.com/testifysec/witness/pkg/attestation/fingerprint"
+	fipsattestor "github.com/testifysec/witness/pkg/attestation/fips"
+	"github.com/testifysec/witness/pkg/attestation/keyattestation"
+	"github.com/testifysec/witness/pkg/attestation/labels"
+	"github.com/testifysec/witness/pkg/attestation/material"
+	"github.com/testifysec/witness/pkg/attestation/matrix"
+	"github.com/testifysec/witness/pkg/attestation/obfuscate"
+	"github.com/testifysec/witness/pkg/attestation/product"
+	"github.com/testifysec/witness/pkg/attestation/secretscan"
+	"github.com/testifysec/witness/pkg/attestation/slim"
+	"github.com/testifysec/witness/pkg/attestation/svid"
+	"github.com/testifysec/witness/pkg/attestation/syscalltrace"
+	"github.com/testifysec/witness/pkg/attestation/versions"
+	"github.com/testifysec/witness/pkg/attestation/worktree"
+	"github.com/testifysec/witness/pkg/fips"
+	"github.com/testifysec/witness/pkg/network"
+	"github.com/testifysec/witness/pkg/ocistore"
+	"github.com/testifysec/witness/pkg/progress"
+	"github.com/testifysec/witness/pkg/publish"
+	"github.com/testifysec/witness/pkg/rekorentry"
+	"github.com/testifysec/witness/pkg/subjects"
+	"github.com/testifysec/witness/pkg/supervise"
+	"github.com/testifysec/witness/pkg/tektonchains"
+	"github.com/testifysec/witness/pkg/witness"
 )
 
 func RunCmd() *cobra.Command {
@@ -35,6 +66,10 @@ func RunCmd() *cobra.Command {
 		SilenceErrors: true,
 		SilenceUsage:  true,
 		RunE: func(cmd *cobra.Command, args []string) error {
+			if supervise.IsInit() {
+				return runAsInit()
+			}
+
 			return runRun(o, args)
 		},
 		Args: cobra.ArbitraryArgs,
@@ -44,10 +79,32 @@ func RunCmd() *cobra.Command {
 	return cmd
 }
 
+// runAsInit runs witness run again as a child when witness is the init process of a container, so the init process
+// only forwards signals and reaps orphaned processes. It exits with the child's exit code.
+func runAsInit() error {
+	code, err := supervise.Reexec()
+	if err != nil {
+		return err
+	}
+
+	if code != 0 {
+		os.Exit(code)
+	}
+
+	return nil
+}
+
 func runRun(ro options.RunOptions, args []string) error {
 	ctx := context.Background()
 
-	signers, errors := loadSigners(ctx, ro.KeyOptions)
+	var signers []cryptoutil.Signer
+	var errors []error
+	if ro.Ephemeral {
+		signers, errors = loadEphemeralSigner(ro.KeyOptions, ro.StepName, ro.EphemeralTTL)
+	} else {
+		signers, errors = loadSigners(ctx, ro.KeyOptions)
+	}
+
 	if len(errors) > 0 {
 		for _, err := range errors {
 			log.Error(err)
@@ -65,7 +122,106 @@ func runRun(ro options.RunOptions, args []string) error {
 		return fmt.Errorf("no signers found")
 	}
 
+	if err := obfuscate.ValidateProfiles(ro.Obfuscate); err != nil {
+		return err
+	}
+
+	collectionLabels, err := labels.Parse(ro.Labels)
+	if err != nil {
+		return err
+	}
+
+	matrixCoordinates, err := matrix.Parse(ro.Matrix)
+	if err != nil {
+		return err
+	}
+
+	if err := validateOutputFormat(ro.OutputFormat, ro.TektonChainsKey); err != nil {
+		return err
+	}
+
+	if err := worktree.ValidateMode(ro.DirtyWorktree); err != nil {
+		return err
+	}
+
+	if len(ro.TimestampServers) > 0 {
+		if err := network.Check("requesting timestamps"); err != nil {
+			return err
+		}
+	}
+
+	if len(ro.RekorServers) > 0 {
+		if err := network.Check("storing attestations in Rekor"); err != nil {
+			return err
+		}
+
+		if err := rekorentry.ValidateKind(ro.RekorEntryType); err != nil {
+			return err
+		}
+	}
+
+	if ro.AttestationStorage != "" {
+		if _, err := ocistore.ParseReference(ro.AttestationStorage); err != nil {
+			return err
+		}
+
+		if err := network.Check("storing attestations in an OCI registry"); err != nil {
+			return err
+		}
+	}
+
+	if ro.Archivist.Server != "" {
+		if err := network.Check("storing attestations in archivista"); err != nil {
+			return err
+		}
+	}
+
 	signer := signers[0]
+	attestors := ro.Attestations
+	// every collection records the builder's fingerprint and container so runs on the same builder or image can be
+	// cross-referenced, where the platform supports them
+	for _, name := range []string{fingerprint.Name, container.Name} {
+		if !contains(attestors, name) && witness.Available(name) {
+			attestors = append(append([]string{}, attestors...), name)
+		}
+	}
+
+	var signerAttestation *keyattestation.Attestor
+	if ro.KeyAttestationPath != "" {
+		signerAttestation, err = keyattestation.Load(ro.KeyAttestationPath, signer)
+		if err != nil {
+			return err
+		}
+	}
+
+	if fips.Enabled() {
+		if err := fips.CheckSigner(signer); err != nil {
+			return fmt.Errorf("signer is not usable in fips mode: %w", err)
+		}
+
+		if !contains(attestors, fipsattestor.Name) {
+			attestors = append(append([]string{}, attestors...), fipsattestor.Name)
+		}
+	}
+
+	if ro.FailOnSecrets && !contains(attestors, secretscan.Name) {
+		attestors = append(append([]string{}, attestors...), secretscan.Name)
+	}
+
+	// the worktree is checked before the command runs, since the command's own products would make it dirty
+	var dirty *worktree.Attestor
+	if ro.DirtyWorktree != worktree.ModeAllow {
+		dirty, err = worktree.Check(ro.WorkingDir)
+		if err != nil {
+			return fmt.Errorf("failed to check the git worktree: %w", err)
+		}
+
+		if dirty.Dirty() && ro.DirtyWorktree == worktree.ModeFail {
+			return fmt.Errorf("git worktree has uncommitted changes or untracked files: %v", dirty.Summary())
+		} else if dirty.Dirty() {
+			log.Warnf("git worktree has uncommitted changes or untracked files, recording them in the collection: %v", dirty.Summary())
+		}
+	}
 
 	out, err := loadOutfile(ro.OutFilePath)
 	if err != nil {
@@ -74,52 +230,229 @@ func runRun(ro options.RunOptions, args []string) error {
 
 	defer out.Close()
 
-	result, err := witness.Run(
-		ro.StepName,
-		signer,
-		witness.RunWithTracing(ro.Tracing),
-		witness.RunWithCommand(args),
-		witness.RunWithAttestors(ro.Attestations),
-		witness.RunWithAttestationOpts(attestation.WithWorkingDir(ro.WorkingDir)),
-	)
+	stopHeartbeats := func() {}
+	if ro.Heartbeat.Interval > 0 {
+		stopHeartbeats, err = startHeartbeats(ro, signer)
+		if err != nil {
+			return fmt.Errorf("failed to start heartbeats: %w", err)
+		}
+	}
+
+	// in a container, signals are forwarded to the command until the attestation is written, so a container being
+	// stopped ends the command without interrupting witness while it signs and stores the collection
+	stopForwarding := supervise.ForwardIfSupervised()
+	defer stopForwarding()
 
+	budget := deadline.NewBudget(ro.Budget.AttestorTimeout, ro.Budget.Deadline)
+	doneRunning := progress.Start(fmt.Sprintf("Running step %v and recording attestations", ro.StepName))
+	collection, err := runAttestors(ro, attestors, args, budget)
+	doneRunning()
+	stopHeartbeats()
 	if err != nil {
 		return err
 	}
 
-	signedBytes, err := json.Marshal(&result.SignedEnvelope)
+	if ro.FailOnSecrets {
+		// an attestor left out by the attestation budget didn't show the step is free of secrets
+		scan, ok := secretscan.FromCollection(collection)
+		if !ok {
+			return fmt.Errorf("secret scan did not finish, so the collection was not signed")
+		}
+
+		if len(scan.Findings) > 0 {
+			return fmt.Errorf("secret scan found possible secrets, so the collection was not signed: %v", scan.Summary())
+		}
+	}
+
+	obfuscated, _, err := obfuscate.Collection(collection, ro.Obfuscate)
 	if err != nil {
-		return fmt.Errorf("failed to marshal envelope: %w", err)
+		return fmt.Errorf("failed to obfuscate attestations: %w", err)
 	}
 
-	if _, err := out.Write(signedBytes); err != nil {
-		return fmt.Errorf("failed to write envelope to out file: %w", err)
+	slimmed, _, err := slim.Collection(obfuscated, slim.Options{
+		MaxAttestationSize: ro.Slim.MaxAttestationSize,
+		MaxProcesses:       ro.Slim.MaxProcesses,
+		DigestOnlyFiles:    ro.Slim.DigestOnlyFiles,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to slim attestations: %w", err)
+	}
+
+	labeled, _ := labels.Collection(slimmed, collectionLabels)
+	labeled, _ = matrix.Collection(labeled, matrixCoordinates)
+	labeled = worktree.Collection(labeled, dirty)
+	if signerAttestation != nil {
+		labeled = keyattestation.Collection(labeled, signerAttestation)
+	}
+
+	if signerSVID, ok := svid.FromSigner(signer); ok {
+		labeled = svid.Collection(labeled, signerSVID)
+	}
+
+	signedEnvelope, err := signCollection(labeled, signer)
+	if err != nil {
+		return fmt.Errorf("failed to sign collection: %w", err)
 	}
 
-	rekorServer := ro.RekorServer
-	if rekorServer != "" {
-		verifier, err := signer.Verifier()
+	signedBytes, err := json.Marshal(&signedEnvelope)
+	if err != nil {
+		return fmt.Errorf("failed to marshal envelope: %w", err)
+	}
+
+	// the stamped envelope is what's written and published, so the timestamps are kept wherever the envelope is stored
+	if len(ro.TimestampServers) > 0 {
+		doneStamping := progress.Start("Requesting timestamps")
+		signedBytes, err = stampEnvelope(signedEnvelope, ro.TimestampServers)
+		doneStamping()
 		if err != nil {
-			return fmt.Errorf("failed to get verifier from signer: %w", err)
+			return err
 		}
+	}
 
-		pubKeyBytes, err := verifier.Bytes()
+	outBytes := signedBytes
+	if ro.OutputFormat == outputFormatTektonChains {
+		outBytes, err = formatTektonChains(signedBytes, ro.TektonChainsKey)
 		if err != nil {
-			return fmt.Errorf("failed to get bytes from verifier: %w", err)
+			return err
 		}
+	}
 
-		rc, err := rekor.New(rekorServer)
-		if err != nil {
-			return fmt.Errorf("failed to get initialize Rekor client: %w", err)
+	if _, err := out.Write(outBytes); err != nil {
+		return fmt.Errorf("failed to write envelope to out file: %w", err)
+	}
+
+	destinations, err := publishDestinations(ro, signer)
+	if err != nil {
+		return err
+	}
+
+	if len(destinations) == 0 && ro.PublishReportPath == "" {
+		return nil
+	}
+
+	// the destinations are published to at the same time, and all of them are tried before a failure is returned
+	donePublishing := progress.Start("Publishing attestations")
+	report := publish.Publish(context.Background(), signedEnvelope, signedBytes, destinations)
+	donePublishing()
+	if ro.PublishReportPath != "" {
+		if err := writePublishReport(ro.PublishReportPath, report); err != nil {
+			return err
 		}
+	}
 
-		resp, err := rc.StoreArtifact(signedBytes, pubKeyBytes)
-		if err != nil {
-			return fmt.Errorf("failed to store artifact in rekor: %w", err)
+	return report.Err()
+}
+
+// runAttestors runs the attestors and the command the way witness.Run does, with every attestor other than the command
+// limited by the budget.
+func runAttestors(ro options.RunOptions, attestorNames []string, args []string, budget *deadline.Budget) (attestation.Collection, error) {
+	if ro.StepName == "" {
+		return attestation.Collection{}, fmt.Errorf("step name is required")
+	}
+
+	attestors, err := attestation.Attestors(attestorNames)
+	if err != nil {
+		return attestation.Collection{}, fmt.Errorf("failed to get attestors: %w", err)
+	}
+
+	for i := range attestors {
+		attestors[i] = budget.Wrap(attestors[i])
+	}
+
+	opts := []attestation.AttestationContextOption{attestation.WithWorkingDir(ro.WorkingDir)}
+	var traced *syscalltrace.Command
+	if len(args) > 0 {
+		var commandAttestor attestation.Attestor = commandrun.New(commandrun.WithCommand(args), commandrun.WithTracing(ro.Tracing))
+		if ro.TraceSyscalls {
+			traced = syscalltrace.NewCommand(args)
+			commandAttestor = traced
 		}
 
-		log.Infof("Rekor entry added at %v%v\n", rekorServer, resp.Location)
+		opts = append(opts,
+			attestation.WithCommandAttestor(commandAttestor),
+			attestation.WithMaterialAttestor(budget.Wrap(material.New(material.WithArtifactOptions(artifacts.Options{
+				Include: ro.Artifacts.MaterialInclude,
+				Exclude: ro.Artifacts.MaterialExclude,
+				Workers: ro.Artifacts.HashWorkers,
+			})))),
+			attestation.WithProductAttestor(budget.Wrap(product.New(product.WithArtifactOptions(artifacts.Options{
+				Include: ro.Artifacts.ProductInclude,
+				Exclude: ro.Artifacts.ProductExclude,
+				Workers: ro.Artifacts.HashWorkers,
+			})))),
+		)
 	}
 
-	return nil
+	runCtx, err := attestation.NewContext(attestors, opts...)
+	if err != nil {
+		return attestation.Collection{}, fmt.Errorf("failed to create attestation context: %w", err)
+	}
+
+	if err := runCtx.RunAttestors(); err != nil {
+		return attestation.Collection{}, fmt.Errorf("failed to run attestors: %w", err)
+	}
+
+	completed := runCtx.CompletedAttestors()
+	if traced != nil {
+		completed = append(completed, traced.Trace)
+	}
+
+	return deadline.Collection(ro.StepName, completed, budget), nil
+}
+
+const (
+	outputFormatDSSE         = "dsse"
+	outputFormatTektonChains = "tekton-chains"
+)
+
+func validateOutputFormat(format, tektonChainsKey string) error {
+	switch format {
+	case "", outputFormatDSSE:
+		return nil
+	case outputFormatTektonChains:
+		if tektonChainsKey == "" {
+			return fmt.Errorf("--tekton-chains-key is required with --output-format %v", outputFormatTektonChains)
+		}
+
+		return nil
+	default:
+		return fmt.Errorf("unknown output format %v", format)
+	}
+}
+
+// formatTektonChains returns a merge patch storing the envelope in the TaskRun annotations Tekton Chains uses.
+func formatTektonChains(envBytes []byte, key string) ([]byte, error) {
+	patch, err := tektonchains.NewPatch(envBytes, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to format envelope for tekton chains: %w", err)
+	}
+
+	data, err := json.Marshal(patch)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal tekton chains patch: %w", err)
+	}
+
+	return data, nil
+}
+
+// signCollection signs the collection, along with the attestors that contributed each of its subjects and the versions
+// of witness and the attestors that recorded it, as the predicate of a statement about its subjects.
+func signCollection(collection attestation.Collection, signer cryptoutil.Signer) (dsse.Envelope, error) {
+	collection = versions.Collection(collection, Version)
+	data, err := json.Marshal(subjects.NewCollection(collection))
+	if err != nil {
+		return dsse.Envelope{}, err
+	}
+
+	stmt, err := intoto.NewStatement(attestation.CollectionType, data, collection.Subjects())
+	if err != nil {
+		return dsse.Envelope{}, err
+	}
+
+	stmtJson, err := json.Marshal(&stmt)
+	if err != nil {
+		return dsse.Envelope{}, err
+	}
+
+	return dsse.Sign(intoto.PayloadType, bytes.NewReader(stmtJson), signer)
 }
@@ -43,7 +43,7 @@ func Test_runRunRSAKeyPair(t *testing.T) {
 		Attestations: []string{},
 		OutFilePath:  workingDir + "outfile.txt",
 		StepName:     "teststep",
-		RekorServer:  "",
+		RekorServers: []string{},
 		Tracing:      false,
 	}
 
@@ -71,7 +71,7 @@ func Test_runRunRSAKeyPair(t *testing.T) {
 		workingDir + "outfile.txt",
 	}
 
-	envelopes, err := loadEnvelopesFromDisk(envelopePaths)
+	envelopes, err := loadEnvelopesFromDisk(envelopePaths, nil)
 	if err != nil {
 		t.Errorf("Error loading envelopes from disk: err: %v", err)
 	}
@@ -105,7 +105,7 @@ func Test_runRunRSACA(t *testing.T) {
 		Attestations: []string{},
 		OutFilePath:  workingDir + "outfile.txt",
 		StepName:     "teststep",
-		RekorServer:  "",
+		RekorServers: []string{},
 		Tracing:      false,
 	}
 
@@ -133,7 +133,7 @@ func Test_runRunRSACA(t *testing.T) {
 		workingDir + "outfile.txt",
 	}
 
-	envelopes, err := loadEnvelopesFromDisk(envelopePaths)
+	envelopes, err := loadEnvelopesFromDisk(envelopePaths, nil)
 	if err != nil {
 		t.Errorf("Error loading envelopes from disk: err: %v", err)
 	}
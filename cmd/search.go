@@ -0,0 +1,138 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/spf13/cobra"
+	"github.com/testifysec/go-witness/log"
+	"github.com/testifysec/witness/options"
+	"github.com/testifysec/witness/pkg/network"
+	"github.com/testifysec/witness/pkg/rekorentry"
+	"github.com/testifysec/witness/pkg/search"
+)
+
+func SearchCmd() *cobra.Command {
+	so := options.SearchOptions{}
+	cmd := &cobra.Command{
+		Use:   "search",
+		Short: "Searches for attestations by subject across Rekor, Archivista, and local directories",
+		Long: "Searches the backends for attestations with the subject, optionally of one predicate type, and writes a " +
+			"JSON list of the envelopes found along with every backend and reference each was found in. Signatures are " +
+			"not checked; use witness verify to evaluate the results against a policy",
+		SilenceErrors:     true,
+		SilenceUsage:      true,
+		DisableAutoGenTag: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runSearch(so)
+		},
+	}
+
+	so.AddFlags(cmd)
+	return cmd
+}
+
+func runSearch(so options.SearchOptions) error {
+	if so.Subject == "" {
+		return fmt.Errorf("a subject is required")
+	}
+
+	backends, err := searchBackends(so)
+	if err != nil {
+		return err
+	}
+
+	results, failed := search.Search(context.Background(), backends, search.Query{Subject: so.Subject, PredicateType: so.PredicateType})
+	names := make([]string, 0, len(failed))
+	for name := range failed {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	for _, name := range names {
+		log.Warnf("failed to search %v: %v", name, failed[name])
+	}
+
+	if len(failed) == len(backends) {
+		return fmt.Errorf("every backend failed to search")
+	}
+
+	out, err := loadOutfile(so.OutFilePath)
+	if err != nil {
+		return fmt.Errorf("failed to open out file: %w", err)
+	}
+
+	defer out.Close()
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(results)
+}
+
+// searchBackends returns the backends named with --backend, or by default the local directory and every backend with a
+// server set.
+func searchBackends(so options.SearchOptions) ([]search.Backend, error) {
+	names := so.Backends
+	if len(names) == 0 {
+		names = []string{"local"}
+		if so.RekorServer != "" {
+			names = append(names, "rekor")
+		}
+
+		if so.ArchivistServer != "" {
+			names = append(names, "archivist")
+		}
+	}
+
+	backends := make([]search.Backend, 0, len(names))
+	for _, name := range names {
+		switch name {
+		case "local":
+			backends = append(backends, search.Local{Directory: so.Directory})
+		case "rekor":
+			if so.RekorServer == "" {
+				return nil, fmt.Errorf("the rekor backend requires --rekor-server")
+			}
+
+			if err := network.Check("searching rekor"); err != nil {
+				return nil, err
+			}
+
+			backends = append(backends, search.Rekor{Client: rekorentry.New(so.RekorServer)})
+		case "archivist":
+			if so.ArchivistServer == "" {
+				return nil, fmt.Errorf("the archivist backend requires --archivist-server")
+			}
+
+			if err := network.Check("searching archivist"); err != nil {
+				return nil, err
+			}
+
+			archivist, err := newSearchArchivist(so.ArchivistServer, so.ArchivistPublicKeyPath)
+			if err != nil {
+				return nil, err
+			}
+
+			backends = append(backends, archivist)
+		default:
+			return nil, fmt.Errorf("unknown search backend %v", name)
+		}
+	}
+
+	return backends, nil
+}
@@ -0,0 +1,290 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	witness "github.com/testifysec/go-witness"
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/dsse"
+	"github.com/testifysec/go-witness/log"
+	"github.com/testifysec/go-witness/rekor"
+	"github.com/testifysec/witness/options"
+	"github.com/testifysec/witness/pkg/auditlog"
+	"github.com/testifysec/witness/pkg/groups"
+	"github.com/testifysec/witness/pkg/network"
+	"github.com/testifysec/witness/pkg/ocistore"
+	"github.com/testifysec/witness/pkg/policywatch"
+	"github.com/testifysec/witness/pkg/profiling"
+	"github.com/testifysec/witness/pkg/registryhook"
+	"github.com/testifysec/witness/pkg/rekorentry"
+	"github.com/testifysec/witness/pkg/search"
+	"github.com/testifysec/witness/pkg/transport"
+)
+
+func ServeCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:               "serve",
+		Short:             "Runs witness as a long running service",
+		DisableAutoGenTag: true,
+	}
+
+	cmd.AddCommand(RegistryHookCmd())
+	return cmd
+}
+
+func RegistryHookCmd() *cobra.Command {
+	ro := options.RegistryHookOptions{}
+	cmd := &cobra.Command{
+		Use:   "registry-hook",
+		Short: "Verifies images pushed to a registry",
+		Long: "Listens for registry push webhooks on /harbor and /dockerhub, verifies the pushed digest against a policy " +
+			"using attestations from Rekor, and reports the results to the notify url, Docker Hub's callback, and Harbor " +
+			"labels. Docker Hub pushes only name the tag, so they are verified at the digest the tag refers to when " +
+			"the webhook arrives. Webhooks must present the webhook secret. The policy is reloaded from its file, OCI reference, or " +
+			"Archivista server when a newer signed policy is published, and a policy that has expired or is older than " +
+			"the active one is refused. Metrics about the active policy are served on the metrics address",
+		SilenceErrors:     true,
+		SilenceUsage:      true,
+		DisableAutoGenTag: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runRegistryHook(ro)
+		},
+	}
+
+	ro.AddFlags(cmd)
+	return cmd
+}
+
+func runRegistryHook(ro options.RegistryHookOptions) error {
+	if ro.KeyPath == "" || (ro.PolicyFilePath == "") == (ro.PolicyArchivist == "") || ro.RekorServer == "" {
+		return fmt.Errorf("a public key, rekor server, and one of a policy or policy archivist server are required")
+	}
+
+	if ro.WebhookSecretPath == "" {
+		return fmt.Errorf("a webhook secret is required")
+	}
+
+	if err := network.Check("the registry hook"); err != nil {
+		return err
+	}
+
+	secret, err := os.ReadFile(ro.WebhookSecretPath)
+	if err != nil {
+		return fmt.Errorf("failed to read webhook secret: %w", err)
+	}
+
+	if len(bytes.TrimSpace(secret)) == 0 {
+		return fmt.Errorf("webhook secret file %v is empty", ro.WebhookSecretPath)
+	}
+
+	serveProfiles(ro.Profiling)
+	verifiers, err := loadVerifiers(ro.KeyPath)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	source, err := policySource(ctx, ro)
+	if err != nil {
+		return err
+	}
+
+	policies, err := policywatch.New(ctx, source, verifiers)
+	if err != nil {
+		return fmt.Errorf("failed to load policy: %w", err)
+	}
+
+	log.Infof("Loaded policy %v from %v", policies.Policy().Digest, source)
+	if ro.ReloadInterval > 0 {
+		go policies.Run(ctx, ro.ReloadInterval)
+	}
+
+	if ro.MetricsAddress != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", policies)
+		go func() {
+			log.Infof("Serving policy metrics on %v", ro.MetricsAddress)
+			if err := http.ListenAndServe(ro.MetricsAddress, mux); err != nil {
+				log.Errorf("failed to serve metrics: %v", err)
+			}
+		}()
+	}
+
+	rc, err := rekor.New(ro.RekorServer)
+	if err != nil {
+		return fmt.Errorf("failed to get initialize Rekor client: %w", err)
+	}
+
+	rekorKey, err := loadRekorPublicKey(ro.RekorPublicKeyPath)
+	if err != nil {
+		return err
+	}
+
+	var pinned *rekorentry.Client
+	if rekorKey != nil {
+		pinned = rekorentry.New(ro.RekorServer)
+		pinned.PublicKey = rekorKey
+	}
+
+	var audit *auditlog.Log
+	if ro.Audit.LogPath != "" {
+		audit, err = openAuditLog(ro.Audit, ro.RekorServer, rc)
+		if err != nil {
+			return err
+		}
+
+		defer audit.Close()
+	}
+
+	groupCache, err := loadGroups(ro.Groups)
+	if err != nil {
+		return fmt.Errorf("failed to load groups: %w", err)
+	}
+
+	verify := func(ctx context.Context, event registryhook.Event) ([]string, error) {
+		active := policies.Policy()
+		references, err := verifyPushedImage(rc, pinned, verifiers, active.Envelope, event, groupCache)
+		if err := saveGroupSnapshot(ro.Groups, groupCache); err != nil {
+			log.Errorf("failed to save group snapshot: %v", err)
+		}
+
+		if audit != nil {
+			auditDecision(audit, event.Repository+"@"+event.Digest, active.Digest, references, err)
+		}
+
+		return references, err
+	}
+
+	handler := registryhook.NewHandler(verify, ro.NotifyURL, string(bytes.TrimSpace(secret)))
+	handler.Resolve = registryhook.TagResolver(ocistore.NewClient(), "docker.io")
+	if ro.NotifyURL != "" {
+		handler.NotifyClient, handler.NotifyURL, err = transport.HTTPClient(ctx, ro.NotifyURL)
+		if err != nil {
+			return fmt.Errorf("failed to create notify client: %w", err)
+		}
+	}
+
+	if ro.Harbor.URL != "" {
+		labels := &registryhook.HarborLabels{
+			Username:      os.Getenv("HARBOR_USERNAME"),
+			Password:      os.Getenv("HARBOR_PASSWORD"),
+			VerifiedLabel: ro.Harbor.VerifiedLabel,
+			FailedLabel:   ro.Harbor.FailedLabel,
+		}
+
+		labels.Client, labels.URL, err = transport.HTTPClient(ctx, ro.Harbor.URL)
+		if err != nil {
+			return fmt.Errorf("failed to create harbor client: %w", err)
+		}
+
+		handler.Harbor = labels
+	}
+
+	log.Infof("Listening for registry webhooks on %v", ro.ListenAddress)
+	return http.ListenAndServe(ro.ListenAddress, handler)
+}
+
+// policySource returns the source the registry hook loads its policy from: an Archivista server, an OCI reference to
+// a tagged image holding the policy envelope, or a file.
+func policySource(ctx context.Context, ro options.RegistryHookOptions) (policywatch.Source, error) {
+	if ro.PolicyArchivist != "" {
+		archivist := search.NewArchivist(ro.PolicyArchivist)
+		var err error
+		archivist.HTTPClient, archivist.URL, err = transport.HTTPClient(ctx, archivist.URL)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create policy archivist client: %w", err)
+		}
+
+		return policywatch.Archivist{Archivist: archivist}, nil
+	}
+
+	if strings.HasPrefix(ro.PolicyFilePath, ocistore.Scheme) {
+		ref, tag, err := ocistore.ParseTaggedReference(ro.PolicyFilePath)
+		if err != nil {
+			return nil, err
+		}
+
+		return policywatch.OCI{Client: ocistore.NewClient(), Reference: ref, Tag: tag}, nil
+	}
+
+	return policywatch.File(ro.PolicyFilePath), nil
+}
+
+// serveProfiles serves pprof profiles in the background if a profiling address is set, after applying the sampling
+// rates so the service's allocations are sampled from the start.
+func serveProfiles(o options.ProfilingOptions) {
+	if o.Address == "" {
+		return
+	}
+
+	profiling.Config{
+		MemProfileRate:       o.MemProfileRate,
+		BlockProfileRate:     o.BlockProfileRate,
+		MutexProfileFraction: o.MutexProfileFraction,
+	}.Apply()
+
+	go func() {
+		log.Infof("Serving pprof profiles on %v", o.Address)
+		if err := http.ListenAndServe(o.Address, profiling.Handler()); err != nil {
+			log.Errorf("failed to serve profiles: %v", err)
+		}
+	}()
+}
+
+// verifyPushedImage verifies the image digest in a registry push event against the policy using evidence from Rekor.
+// Evidence is read with the pinned client instead if the Rekor server's key is pinned.
+func verifyPushedImage(rc rekor.RekorClient, pinned *rekorentry.Client, verifiers []cryptoutil.Verifier, policyEnvelope dsse.Envelope, event registryhook.Event, resolver groups.Resolver) ([]string, error) {
+	algorithm, digest, err := registryhook.SplitDigest(event.Digest)
+	if err != nil {
+		return nil, err
+	}
+
+	if algorithm != "sha256" {
+		return nil, fmt.Errorf("unsupported digest algorithm: %v", algorithm)
+	}
+
+	digestSets := []cryptoutil.DigestSet{{crypto.SHA256: digest}}
+	var evidence []witness.CollectionEnvelope
+	if pinned != nil {
+		evidence, err = findEvidenceAllKinds(pinned, digestSets, policyEnvelope, verifiers, nil)
+	} else {
+		evidence, err = rc.FindEvidence(digestSets, policyEnvelope, verifiers, nil, MAX_DEPTH)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to find evidence: %w", err)
+	}
+
+	if _, err := verifyPolicyConstraints(policyEnvelope, evidence, resolver, nil); err != nil {
+		return nil, fmt.Errorf("failed to verify policy: %w", err)
+	}
+
+	references := make([]string, 0, len(evidence))
+	for _, e := range evidence {
+		references = append(references, e.Reference)
+	}
+
+	return references, nil
+}
@@ -15,22 +15,29 @@
 package cmd
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"os"
 
 	"github.com/spf13/cobra"
-	witness "github.com/testifysec/go-witness"
+	"github.com/testifysec/go-witness/dsse"
 	"github.com/testifysec/go-witness/log"
 	"github.com/testifysec/witness/options"
+	"github.com/testifysec/witness/pkg/fips"
+	"github.com/testifysec/witness/pkg/network"
+	"github.com/testifysec/witness/pkg/payload"
+	"github.com/testifysec/witness/pkg/rekorentry"
 )
 
 func SignCmd() *cobra.Command {
 	so := options.SignOptions{}
 	cmd := &cobra.Command{
-		Use:               "sign [file]",
-		Short:             "Signs a file",
-		Long:              "Signs a file with the provided key source and outputs the signed file to the specified destination",
+		Use:   "sign [file]",
+		Short: "Signs a file",
+		Long: "Signs a file with the provided key source and outputs the signed file to the specified destination. The " +
+			"file is checked against its payload type first, so in-toto statements, policies, and JSON documents must parse",
 		SilenceErrors:     true,
 		SilenceUsage:      true,
 		DisableAutoGenTag: true,
@@ -53,6 +60,16 @@ func runSign(so options.SignOptions) error {
 		return err
 	}
 
+	if so.RekorServer != "" {
+		if err := network.Check("storing signatures in Rekor"); err != nil {
+			return err
+		}
+
+		if err := rekorentry.ValidateKind(so.RekorEntryType); err != nil {
+			return err
+		}
+	}
+
 	signers, errors := loadSigners(ctx, so.KeyOptions)
 	if len(errors) > 0 {
 		for _, err := range errors {
@@ -72,17 +89,71 @@ func runSign(so options.SignOptions) error {
 	}
 
 	signer := signers[0]
+	if fips.Enabled() {
+		if err := fips.CheckSigner(signer); err != nil {
+			return fmt.Errorf("signer is not usable in fips mode: %w", err)
+		}
+	}
 
-	inFile, err := os.Open(so.InFilePath)
+	data, err := os.ReadFile(so.InFilePath)
 	if err != nil {
 		return fmt.Errorf("failed to open file to sign: %v", err)
 	}
 
+	if err := payload.Check(so.PayloadType, data); err != nil {
+		return fmt.Errorf("%v can't be signed as %v: %w", so.InFilePath, so.PayloadType, err)
+	}
+
 	outFile, err := loadOutfile(so.OutFilePath)
 	if err != nil {
 		return err
 	}
 
 	defer outFile.Close()
-	return witness.Sign(inFile, so.DataType, outFile, signer)
+	if len(so.TimestampServers) > 0 {
+		if err := network.Check("requesting timestamps"); err != nil {
+			return err
+		}
+	}
+
+	env, err := dsse.Sign(so.PayloadType, bytes.NewReader(data), signer)
+	if err != nil {
+		return fmt.Errorf("failed to sign: %w", err)
+	}
+
+	outBytes, err := json.Marshal(&env)
+	if err != nil {
+		return fmt.Errorf("failed to marshal envelope: %w", err)
+	}
+
+	if len(so.TimestampServers) > 0 {
+		if outBytes, err = stampEnvelope(env, so.TimestampServers); err != nil {
+			return err
+		}
+	}
+
+	if _, err := outFile.Write(append(outBytes, '\n')); err != nil {
+		return fmt.Errorf("failed to write signed file: %w", err)
+	}
+
+	if so.RekorServer == "" {
+		return nil
+	}
+
+	verifier, err := signer.Verifier()
+	if err != nil {
+		return fmt.Errorf("failed to get verifier from signer: %w", err)
+	}
+
+	pubKeyBytes, err := verifier.Bytes()
+	if err != nil {
+		return fmt.Errorf("failed to get bytes from verifier: %w", err)
+	}
+
+	if _, err := uploadToRekor(ctx, so.RekorServer, so.RekorEntryType, so.RekorRetries, env, pubKeyBytes,
+		rekorBundlePath(so.OutFilePath, so.RekorBundlePath), so.RekorBundlePath != ""); err != nil {
+		return fmt.Errorf("failed to store signed file in rekor: %w", err)
+	}
+
+	return nil
 }
@@ -39,7 +39,7 @@ func Test_runSignPolicyRSA(t *testing.T) {
 
 	signOptions := options.SignOptions{
 		KeyOptions:  keyOptions,
-		DataType:    "text",
+		PayloadType: "text",
 		OutFilePath: workingDir + "outfile.txt",
 		InFilePath:  workingDir + "test.txt",
 	}
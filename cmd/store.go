@@ -0,0 +1,72 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/testifysec/go-witness/log"
+	"github.com/testifysec/witness/options"
+	"github.com/testifysec/witness/pkg/store"
+)
+
+func StoreCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:               "store",
+		Short:             "Manages a local directory of attestations",
+		DisableAutoGenTag: true,
+	}
+
+	cmd.AddCommand(StoreGCCmd())
+	return cmd
+}
+
+func StoreGCCmd() *cobra.Command {
+	so := options.StoreGCOptions{}
+	cmd := &cobra.Command{
+		Use:   "gc",
+		Short: "Replaces old attestations with tombstones",
+		Long: "Removes attestations older than the retention period and writes a tombstone in their place. Tombstones " +
+			"record the removed attestation's digest, subjects, and signing key IDs as evidence that it existed",
+		SilenceErrors:     true,
+		SilenceUsage:      true,
+		DisableAutoGenTag: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runStoreGC(so)
+		},
+	}
+
+	so.AddFlags(cmd)
+	return cmd
+}
+
+func runStoreGC(so options.StoreGCOptions) error {
+	if so.OlderThan <= 0 {
+		return fmt.Errorf("a retention period must be provided with --older-than")
+	}
+
+	tombstones, err := store.GC(so.Directory, store.GCOptions{OlderThan: so.OlderThan, DryRun: so.DryRun})
+	if err != nil {
+		return fmt.Errorf("failed to garbage collect attestations: %w", err)
+	}
+
+	for _, tombstone := range tombstones {
+		log.Infof("Tombstoned %v (%v)", tombstone.Name, tombstone.Digest)
+	}
+
+	log.Infof("%d attestations tombstoned", len(tombstones))
+	return nil
+}
@@ -0,0 +1,43 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"fmt"
+
+	witness "github.com/testifysec/go-witness"
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/dsse"
+	"github.com/testifysec/witness/pkg/policy"
+)
+
+// evidenceIndex indexes the statements of the evidence by subject.
+func evidenceIndex(evidence []witness.CollectionEnvelope) *policy.Index {
+	envelopes := make([]dsse.Envelope, 0, len(evidence))
+	for _, e := range evidence {
+		envelopes = append(envelopes, e.Envelope)
+	}
+
+	return policy.NewIndex(envelopes)
+}
+
+// checkArtifactSubject returns an error unless the artifact matches a subject of the verified evidence.
+func checkArtifactSubject(evidence []witness.CollectionEnvelope, artifactDigest cryptoutil.DigestSet) error {
+	if !evidenceIndex(evidence).MatchesSubject(artifactDigest) {
+		return fmt.Errorf("artifact does not match any attestation subject")
+	}
+
+	return nil
+}
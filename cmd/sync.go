@@ -0,0 +1,241 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"github.com/spiffe/go-spiffe/v2/workloadapi"
+	"github.com/testifysec/go-witness/log"
+	"github.com/testifysec/witness/options"
+	"github.com/testifysec/witness/pkg/network"
+	"github.com/testifysec/witness/pkg/publish"
+	"github.com/testifysec/witness/pkg/rekorentry"
+	"github.com/testifysec/witness/pkg/sink"
+	"github.com/testifysec/witness/pkg/spiffeauth"
+)
+
+func SyncCmd() *cobra.Command {
+	so := options.SyncOptions{}
+	cmd := &cobra.Command{
+		Use:   "sync [attestation or bundle file...]",
+		Short: "Sends spooled attestations to Archivista and resumes pending Rekor uploads",
+		Long: "Sends the attestations witness run and witness attest spooled because the Archivista server couldn't be " +
+			"reached, and removes them from the spool once they are stored. Attestations that still can't be sent are " +
+			"left in the spool. Attestation files given as arguments, or their Rekor bundle files, have the Rekor uploads " +
+			"that failed when they were signed resumed, and the pending upload is replaced with the entry's bundle",
+		SilenceErrors:     true,
+		SilenceUsage:      true,
+		DisableAutoGenTag: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runSync(so, args)
+		},
+	}
+
+	so.AddFlags(cmd)
+	return cmd
+}
+
+func runSync(so options.SyncOptions, paths []string) error {
+	if so.Archivist.Server == "" && len(paths) == 0 {
+		return fmt.Errorf("an archivista server must be provided with --archivist-server, or attestation files with pending rekor uploads as arguments")
+	}
+
+	if len(paths) > 0 {
+		if err := network.Check("resuming rekor uploads"); err != nil {
+			return err
+		}
+
+		if err := syncRekor(context.Background(), paths, so.RekorRetries); err != nil {
+			return err
+		}
+	}
+
+	if so.Archivist.Server == "" {
+		return nil
+	}
+
+	if err := network.Check("sending attestations to archivista"); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	archivist, spoolDir, err := newArchivistSink(ctx, so.Archivist)
+	if err != nil {
+		return err
+	}
+
+	results, err := archivist.Sync(ctx, spoolDir)
+	if err != nil {
+		return fmt.Errorf("failed to sync spooled attestations: %w", err)
+	}
+
+	failed := 0
+	for _, result := range results {
+		if result.Err != nil {
+			failed++
+			log.Errorf("failed to send %v: %v", result.Path, result.Err)
+			continue
+		}
+
+		log.Infof("Sent %v, stored with gitoid %v", result.Path, result.Gitoid)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d spooled attestations could not be sent and were left in %v", failed, len(results), spoolDir)
+	}
+
+	log.Infof("Sent %d spooled attestations", len(results))
+	return nil
+}
+
+// syncRekor resumes the pending Rekor uploads recorded in the bundles of the attestation files, including the bundle
+// of each log an attestation was published to, or in the bundle files themselves. Bundles of entries that were
+// already created are left alone.
+func syncRekor(ctx context.Context, paths []string, retries int) error {
+	bundlePaths := make([]string, 0, len(paths))
+	for _, path := range paths {
+		found, err := rekorBundleFiles(path)
+		if err != nil {
+			return err
+		}
+
+		if len(found) == 0 {
+			found = []string{path}
+		}
+
+		bundlePaths = append(bundlePaths, found...)
+	}
+
+	failed := 0
+	for _, bundlePath := range bundlePaths {
+		bundle, err := rekorentry.ReadBundle(bundlePath)
+		if err != nil {
+			failed++
+			log.Errorf("failed to read the rekor bundle %v: %v", bundlePath, err)
+			continue
+		}
+
+		if bundle.Pending == nil {
+			log.Infof("%v is already logged in rekor as entry %v", bundlePath, bundle.UUID)
+			continue
+		}
+
+		location, err := resumeRekorUpload(ctx, bundlePath, *bundle.Pending, retries)
+		if err != nil {
+			failed++
+			log.Errorf("failed to resume the rekor upload recorded in %v: %v", bundlePath, err)
+			continue
+		}
+
+		log.Infof("Rekor entry added at %v, bundle written to %v", location, bundlePath)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d rekor uploads could not be resumed", failed, len(bundlePaths))
+	}
+
+	return nil
+}
+
+// newArchivistSink returns the sink for the archivist options and the directory it spools to. The sink's health checks
+// of a list of servers stop when ctx is cancelled.
+func newArchivistSink(ctx context.Context, ao options.ArchivistOptions) (*sink.Archivist, string, error) {
+	spoolDir := ao.SpoolDir
+	if spoolDir == "" {
+		var err error
+		if spoolDir, err = sink.DefaultSpoolDir(); err != nil {
+			return nil, "", err
+		}
+	}
+
+	opts := sink.DefaultOptions()
+	opts.Timeout = ao.Timeout
+	opts.DialTimeout = ao.DialTimeout
+	opts.Retries = ao.Retries
+	opts.SpoolDir = spoolDir
+	opts.GRPC = ao.GRPC
+	if ao.SPIFFESocket != "" || len(ao.SPIFFEIDs) > 0 {
+		tlsConfig, err := archivistSPIFFEConfig(ctx, ao)
+		if err != nil {
+			return nil, "", err
+		}
+
+		opts.TLSConfig = tlsConfig
+	}
+
+	archivist, err := sink.NewArchivist(ctx, ao.Server, opts)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return archivist, spoolDir, nil
+}
+
+// archivistSPIFFEConfig returns the mTLS config that authenticates to the Archivista server with the SVID from the
+// Workload API, and only accepts a server with one of the authorized SPIFFE IDs. The SVID is kept current until ctx is
+// cancelled.
+func archivistSPIFFEConfig(ctx context.Context, ao options.ArchivistOptions) (*tls.Config, error) {
+	if ao.SPIFFESocket == "" || len(ao.SPIFFEIDs) == 0 {
+		return nil, fmt.Errorf("--archivist-spiffe-socket and --archivist-spiffe-id must be used together")
+	}
+
+	source, err := workloadapi.NewX509Source(ctx, workloadapi.WithClientOptions(workloadapi.WithAddr(ao.SPIFFESocket)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch svid from the workload api: %w", err)
+	}
+
+	tlsConfig, err := spiffeauth.ClientConfig(source, source, ao.SPIFFEIDs)
+	if err != nil {
+		source.Close()
+		return nil, err
+	}
+
+	go func() {
+		<-ctx.Done()
+		source.Close()
+	}()
+
+	return tlsConfig, nil
+}
+
+// storeInArchivist stores the signed collection in Archivista and returns its gitoid. A collection spooled because the
+// server couldn't be reached is returned as a publish.ErrDeferred, which witness run only warns about, so an outage of
+// the collector doesn't fail the build. envBytes is the envelope as written to the out file, timestamps included.
+func storeInArchivist(ctx context.Context, ao options.ArchivistOptions, envBytes []byte) (string, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	archivist, _, err := newArchivistSink(ctx, ao)
+	if err != nil {
+		return "", err
+	}
+
+	gitoid, err := archivist.StoreData(ctx, envBytes)
+	spooled := sink.ErrSpooled{}
+	if errors.As(err, &spooled) {
+		log.Warnf("archivista could not be reached, spooled the attestation to %v to be sent with witness sync: %v", spooled.Path, spooled.Err)
+		return "", publish.ErrDeferred{Path: spooled.Path, Err: spooled.Err}
+	} else if err != nil {
+		return "", err
+	}
+
+	log.Infof("Attestation stored in Archivista, which acknowledged it with the envelope's gitoid %v", gitoid)
+	return gitoid, nil
+}
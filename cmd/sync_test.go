@@ -0,0 +1,117 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/testifysec/go-witness/dsse"
+	"github.com/testifysec/witness/options"
+	"github.com/testifysec/witness/pkg/publish"
+	"github.com/testifysec/witness/pkg/rekorentry"
+	"github.com/testifysec/witness/pkg/sink"
+)
+
+func TestStoreInArchivistSpools(t *testing.T) {
+	unreachable := httptest.NewServer(http.NotFoundHandler())
+	unreachable.Close()
+
+	spoolDir := t.TempDir()
+	ao := options.ArchivistOptions{Server: unreachable.URL, SpoolDir: spoolDir}
+	_, err := storeInArchivist(context.Background(), ao, []byte(`{"payloadType":"build"}`))
+	deferred := publish.ErrDeferred{}
+	require.ErrorAs(t, err, &deferred)
+
+	spooled, err := sink.Spooled(spoolDir)
+	require.NoError(t, err)
+	require.Len(t, spooled, 1)
+	require.Equal(t, spooled[0], deferred.Path)
+
+	stored := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		stored++
+		_ = json.NewEncoder(w).Encode(map[string]string{"gitoid": "abc"})
+	}))
+
+	defer server.Close()
+	require.NoError(t, runSync(options.SyncOptions{Archivist: options.ArchivistOptions{Server: server.URL, SpoolDir: spoolDir}}, nil))
+	require.Equal(t, 1, stored)
+
+	spooled, err = sink.Spooled(spoolDir)
+	require.NoError(t, err)
+	require.Empty(t, spooled)
+}
+
+func TestSyncRequiresServer(t *testing.T) {
+	err := runSync(options.SyncOptions{}, nil)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "--archivist-server")
+}
+
+func TestSyncResumesRekorUploads(t *testing.T) {
+	unreachable := httptest.NewServer(http.NotFoundHandler())
+	unreachable.Close()
+
+	dir := t.TempDir()
+	attestationPath := filepath.Join(dir, "build.json")
+	bundlePath := rekorBundlePath(attestationPath, "")
+	env := dsse.Envelope{PayloadType: "build", Payload: []byte("{}"), Signatures: []dsse.Signature{{KeyID: "key", Signature: []byte("signature")}}}
+	_, err := uploadToRekor(context.Background(), unreachable.URL, rekorentry.Intoto, 0, env, []byte("public key"), bundlePath, false)
+	require.Error(t, err)
+	require.Contains(t, err.Error(), "witness sync")
+
+	pending, err := rekorentry.ReadBundle(bundlePath)
+	require.NoError(t, err)
+	require.NotNil(t, pending.Pending)
+	require.Equal(t, unreachable.URL, pending.Pending.Server)
+
+	created := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			created++
+			w.Header().Set("Location", "/api/v1/log/entries/abc")
+			w.WriteHeader(http.StatusCreated)
+			return
+		}
+
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"abc": map[string]interface{}{
+			"body":         []byte("entry"),
+			"logIndex":     7,
+			"verification": map[string]interface{}{"signedEntryTimestamp": []byte("set"), "inclusionProof": map[string]interface{}{"checkpoint": "checkpoint"}},
+		}})
+	}))
+
+	defer server.Close()
+	pending.Pending.Server = server.URL
+	require.NoError(t, rekorentry.WriteBundle(bundlePath, pending))
+	require.NoError(t, runSync(options.SyncOptions{}, []string{attestationPath}))
+	require.Equal(t, 1, created)
+
+	bundle, err := rekorentry.ReadBundle(bundlePath)
+	require.NoError(t, err)
+	require.Nil(t, bundle.Pending)
+	require.Equal(t, "abc", bundle.UUID)
+	require.Equal(t, int64(7), bundle.LogIndex)
+
+	// bundles of entries that were already created are left alone
+	require.NoError(t, runSync(options.SyncOptions{}, []string{bundlePath}))
+	require.Equal(t, 1, created)
+}
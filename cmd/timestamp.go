@@ -0,0 +1,55 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/testifysec/go-witness/dsse"
+	"github.com/testifysec/witness/pkg/policy"
+	"github.com/testifysec/witness/pkg/timestamp"
+)
+
+// stampEnvelope timestamps the envelope's signatures with each of the timestamp authorities and returns the envelope's
+// json with the timestamps added.
+func stampEnvelope(env dsse.Envelope, servers []string) ([]byte, error) {
+	stamped, err := timestamp.Stamp(context.Background(), env, servers, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to timestamp signatures: %w", err)
+	}
+
+	stampedBytes, err := json.Marshal(&stamped)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal envelope: %w", err)
+	}
+
+	return stampedBytes, nil
+}
+
+// timestampVerifier returns a verifier for the timestamp authorities the policy trusts, or nil if it trusts none.
+func timestampVerifier(p policy.Policy) (*timestamp.Verifier, error) {
+	roots, intermediates, err := p.TimestampAuthorityCertificates()
+	if err != nil {
+		return nil, err
+	}
+
+	if len(roots) == 0 {
+		return nil, nil
+	}
+
+	return &timestamp.Verifier{Roots: roots, Intermediates: intermediates}, nil
+}
@@ -0,0 +1,379 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/dsse"
+	"github.com/testifysec/go-witness/log"
+	"github.com/testifysec/witness/options"
+	"github.com/testifysec/witness/pkg/fips"
+	"github.com/testifysec/witness/pkg/tlog"
+)
+
+func LogCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:               "log",
+		Short:             "Keeps a local append-only transparency log of attestations",
+		DisableAutoGenTag: true,
+	}
+
+	cmd.AddCommand(LogAddCmd())
+	cmd.AddCommand(LogProveCmd())
+	cmd.AddCommand(LogVerifyCmd())
+	return cmd
+}
+
+func LogAddCmd() *cobra.Command {
+	lo := options.LogAddOptions{}
+	cmd := &cobra.Command{
+		Use:   "add [attestation files]",
+		Short: "Adds attestations to the log",
+		Long: "Appends attestations to the log and signs a checkpoint committing to every entry in it. The checkpoint " +
+			"can be published so anyone holding it can detect entries being rewritten or removed later. Only one " +
+			"witness log add should write to a log at a time",
+		SilenceErrors:     true,
+		SilenceUsage:      true,
+		DisableAutoGenTag: true,
+		Args:              cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runLogAdd(lo, args)
+		},
+	}
+
+	lo.AddFlags(cmd)
+	return cmd
+}
+
+func runLogAdd(lo options.LogAddOptions, files []string) error {
+	ctx := context.Background()
+	if lo.KeyOptions.FulcioURL != "" {
+		return fmt.Errorf("fulcio url is not supported for signing log checkpoints")
+	}
+
+	signers, errors := loadSigners(ctx, lo.KeyOptions)
+	if len(errors) > 0 {
+		for _, err := range errors {
+			log.Error(err)
+		}
+
+		return fmt.Errorf("failed to load signers")
+	}
+
+	if len(signers) != 1 {
+		return fmt.Errorf("exactly one signer is required to sign log checkpoints")
+	}
+
+	signer := signers[0]
+	if fips.Enabled() {
+		if err := fips.CheckSigner(signer); err != nil {
+			return fmt.Errorf("signer is not usable in fips mode: %w", err)
+		}
+	}
+
+	l, err := tlog.Open(lo.Directory)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return fmt.Errorf("failed to read attestation: %w", err)
+		}
+
+		entry, err := l.Append(filepath.Base(file), data, now)
+		if err != nil {
+			return err
+		}
+
+		log.Infof("Added %v to the log as entry %d", file, entry.Index)
+	}
+
+	env, err := l.Sign(signer, now)
+	if err != nil {
+		return err
+	}
+
+	log.Infof("Signed checkpoint of %d entries", l.Size())
+	if lo.PublishTo == "" {
+		return nil
+	}
+
+	due, err := l.PublishDue(lo.PublishInterval, now)
+	if err != nil {
+		return err
+	}
+
+	if !due {
+		log.Infof("Skipping publish, a checkpoint was published within the last %v", lo.PublishInterval)
+		return nil
+	}
+
+	checkpoint, err := json.Marshal(env)
+	if err != nil {
+		return err
+	}
+
+	publisher := tlog.Publisher{Target: lo.PublishTo, Token: os.Getenv("WITNESS_LOG_PUBLISH_TOKEN")}
+	if strings.HasPrefix(lo.PublishTo, "gist:") {
+		publisher.Token = os.Getenv("GITHUB_TOKEN")
+		publisher.GitHubAPIURL = os.Getenv("GITHUB_API_URL")
+	}
+
+	if err := publisher.Publish(ctx, checkpoint); err != nil {
+		return err
+	}
+
+	log.Infof("Published checkpoint to %v", lo.PublishTo)
+	return l.MarkPublished(now)
+}
+
+func LogProveCmd() *cobra.Command {
+	lo := options.LogProveOptions{}
+	cmd := &cobra.Command{
+		Use:   "prove [attestation file]",
+		Short: "Proves an attestation is in the log",
+		Long: "Outputs a proof that the attestation is included in the log's latest checkpoint. If an older " +
+			"checkpoint is provided the proof also shows the latest checkpoint is an append-only extension of it",
+		SilenceErrors:     true,
+		SilenceUsage:      true,
+		DisableAutoGenTag: true,
+		Args:              cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runLogProve(lo, args[0])
+		},
+	}
+
+	lo.AddFlags(cmd)
+	return cmd
+}
+
+func runLogProve(lo options.LogProveOptions, file string) error {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return fmt.Errorf("failed to read attestation: %w", err)
+	}
+
+	l, err := tlog.Open(lo.Directory)
+	if err != nil {
+		return err
+	}
+
+	digest := sha256.Sum256(data)
+	entry, ok := l.Find(hex.EncodeToString(digest[:]))
+	if !ok {
+		return fmt.Errorf("%v is not in the log", file)
+	}
+
+	env, err := l.LatestCheckpoint()
+	if err != nil {
+		return err
+	}
+
+	checkpoint, err := tlog.ParseCheckpoint(env)
+	if err != nil {
+		return err
+	}
+
+	if entry.Index >= checkpoint.Size {
+		return fmt.Errorf("%v was added after the latest checkpoint", file)
+	}
+
+	// the old checkpoint's signature is checked by whoever verifies the proof, here we only need its size
+	oldSize := uint64(0)
+	if lo.CheckpointPath != "" {
+		oldEnv, err := readCheckpointEnvelope(lo.CheckpointPath)
+		if err != nil {
+			return err
+		}
+
+		old, err := tlog.ParseCheckpoint(oldEnv)
+		if err != nil {
+			return err
+		}
+
+		oldSize = old.Size
+	}
+
+	proof, err := l.Prove(entry, env, checkpoint, oldSize)
+	if err != nil {
+		return fmt.Errorf("failed to prove inclusion: %w", err)
+	}
+
+	out, err := loadOutfile(lo.OutFilePath)
+	if err != nil {
+		return err
+	}
+
+	defer out.Close()
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(proof)
+}
+
+func LogVerifyCmd() *cobra.Command {
+	lo := options.LogVerifyOptions{}
+	cmd := &cobra.Command{
+		Use:   "verify [attestation file]",
+		Short: "Verifies an attestation is in the log, or audits the log",
+		Long: "With a proof, verifies the attestation is included in the proof's signed checkpoint. Without one, " +
+			"verifies the log directory still matches its latest signed checkpoint. If an older checkpoint is provided " +
+			"the log must also be an append-only extension of it",
+		SilenceErrors:     true,
+		SilenceUsage:      true,
+		DisableAutoGenTag: true,
+		Args:              cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runLogVerify(lo, args)
+		},
+	}
+
+	lo.AddFlags(cmd)
+	return cmd
+}
+
+func runLogVerify(lo options.LogVerifyOptions, args []string) error {
+	if lo.KeyPath == "" {
+		return fmt.Errorf("the public key checkpoints are signed with is required")
+	}
+
+	keyFile, err := os.Open(lo.KeyPath)
+	if err != nil {
+		return fmt.Errorf("failed to open public key: %w", err)
+	}
+
+	defer keyFile.Close()
+	verifier, err := cryptoutil.NewVerifierFromReader(keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load public key: %w", err)
+	}
+
+	var old *tlog.Checkpoint
+	if lo.CheckpointPath != "" {
+		oldEnv, err := readCheckpointEnvelope(lo.CheckpointPath)
+		if err != nil {
+			return err
+		}
+
+		checkpoint, err := tlog.VerifyCheckpoint(oldEnv, verifier)
+		if err != nil {
+			return fmt.Errorf("failed to verify %v: %w", lo.CheckpointPath, err)
+		}
+
+		old = &checkpoint
+	}
+
+	if lo.ProofPath != "" {
+		if len(args) != 1 {
+			return fmt.Errorf("the attestation the proof is for is required")
+		}
+
+		return verifyLogProof(lo.ProofPath, args[0], verifier, old)
+	}
+
+	if len(args) > 0 {
+		return fmt.Errorf("a proof is required to verify an attestation is in the log")
+	}
+
+	return auditLog(lo.Directory, verifier, old)
+}
+
+func verifyLogProof(proofPath, file string, verifier cryptoutil.Verifier, old *tlog.Checkpoint) error {
+	proofBytes, err := os.ReadFile(proofPath)
+	if err != nil {
+		return fmt.Errorf("failed to read proof: %w", err)
+	}
+
+	proof := tlog.Proof{}
+	if err := json.Unmarshal(proofBytes, &proof); err != nil {
+		return fmt.Errorf("failed to parse proof: %w", err)
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return fmt.Errorf("failed to read attestation: %w", err)
+	}
+
+	checkpoint, err := proof.Verify(data, verifier)
+	if err != nil {
+		return fmt.Errorf("failed to verify inclusion: %w", err)
+	}
+
+	if old != nil {
+		if err := proof.VerifyConsistency(*old, checkpoint); err != nil {
+			return fmt.Errorf("failed to verify the log is consistent with the older checkpoint: %w", err)
+		}
+	}
+
+	log.Infof("Verified %v is entry %d of the log checkpoint of %d entries signed at %v", file, proof.Index, checkpoint.Size, checkpoint.Time)
+	return nil
+}
+
+func auditLog(dir string, verifier cryptoutil.Verifier, old *tlog.Checkpoint) error {
+	l, err := tlog.Open(dir)
+	if err != nil {
+		return err
+	}
+
+	env, err := l.LatestCheckpoint()
+	if err != nil {
+		return err
+	}
+
+	checkpoint, err := tlog.VerifyCheckpoint(env, verifier)
+	if err != nil {
+		return err
+	}
+
+	if err := l.Audit(checkpoint); err != nil {
+		return err
+	}
+
+	if old != nil {
+		if err := l.Audit(*old); err != nil {
+			return fmt.Errorf("log is not consistent with the older checkpoint: %w", err)
+		}
+	}
+
+	log.Infof("Verified the log matches its checkpoint of %d entries signed at %v", checkpoint.Size, checkpoint.Time)
+	return nil
+}
+
+func readCheckpointEnvelope(path string) (dsse.Envelope, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return dsse.Envelope{}, fmt.Errorf("failed to read checkpoint: %w", err)
+	}
+
+	env := dsse.Envelope{}
+	if err := json.Unmarshal(data, &env); err != nil {
+		return dsse.Envelope{}, fmt.Errorf("failed to parse checkpoint %v: %w", path, err)
+	}
+
+	return env, nil
+}
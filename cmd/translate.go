@@ -0,0 +1,99 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/dsse"
+	"github.com/testifysec/witness/options"
+	"github.com/testifysec/witness/pkg/fips"
+	"github.com/testifysec/witness/pkg/translate"
+)
+
+func TranslateCmd() *cobra.Command {
+	to := options.TranslateOptions{}
+	cmd := &cobra.Command{
+		Use:   "translate [attestation file]",
+		Short: "Translates a signed attestation collection to another format",
+		Long: "Converts a signed attestation collection into an unsigned in-toto statement of another supply chain " +
+			"metadata format, such as a SCAI attribute report, that can be signed with witness sign. Supported formats: " +
+			strings.Join(translate.Formats(), ", "),
+		SilenceErrors:     true,
+		SilenceUsage:      true,
+		DisableAutoGenTag: true,
+		Args:              cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runTranslate(to, args[0])
+		},
+	}
+
+	to.AddFlags(cmd)
+	return cmd
+}
+
+func runTranslate(to options.TranslateOptions, path string) error {
+	fileBytes, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read %v: %w", path, err)
+	}
+
+	env := dsse.Envelope{}
+	if err := json.Unmarshal(fileBytes, &env); err != nil {
+		return fmt.Errorf("failed to unmarshal envelope from %v: %w", path, err)
+	}
+
+	if to.KeyPath != "" {
+		keyFile, err := os.Open(to.KeyPath)
+		if err != nil {
+			return fmt.Errorf("failed to open key file: %w", err)
+		}
+
+		defer keyFile.Close()
+		verifier, err := cryptoutil.NewVerifierFromReader(keyFile)
+		if err != nil {
+			return fmt.Errorf("failed to create verifier: %w", err)
+		}
+
+		if fips.Enabled() {
+			if err := fips.CheckVerifier(verifier); err != nil {
+				return fmt.Errorf("verifier is not usable in fips mode: %w", err)
+			}
+		}
+
+		if _, err := env.Verify(dsse.WithVerifiers([]cryptoutil.Verifier{verifier})); err != nil {
+			return fmt.Errorf("failed to verify %v: %w", path, err)
+		}
+	}
+
+	statement, err := translate.Translate(to.Format, env)
+	if err != nil {
+		return fmt.Errorf("failed to translate %v: %w", path, err)
+	}
+
+	out, err := loadOutfile(to.OutFilePath)
+	if err != nil {
+		return fmt.Errorf("failed to open out file: %w", err)
+	}
+
+	defer out.Close()
+	encoder := json.NewEncoder(out)
+	return encoder.Encode(&statement)
+}
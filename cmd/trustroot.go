@@ -0,0 +1,194 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/dsse"
+	"github.com/testifysec/go-witness/log"
+	"github.com/testifysec/witness/options"
+	"github.com/testifysec/witness/pkg/fetch"
+	"github.com/testifysec/witness/pkg/network"
+	"github.com/testifysec/witness/pkg/policy"
+	"github.com/testifysec/witness/pkg/progress"
+	"github.com/testifysec/witness/pkg/tuf"
+)
+
+// loadTrustRoot returns the trust material in the TUF repository, updating the cached metadata if it is older than
+// the refresh interval. Offline, the cached metadata is used as long as it has not expired.
+func loadTrustRoot(o options.TrustRootOptions) (tuf.TrustRoot, error) {
+	repositoryURL, err := tuf.RepositoryURL(o.URL)
+	if err != nil {
+		return tuf.TrustRoot{}, err
+	}
+
+	cacheDir := o.CacheDir
+	if cacheDir == "" {
+		if cacheDir, err = tuf.DefaultCacheDir(repositoryURL); err != nil {
+			return tuf.TrustRoot{}, err
+		}
+	}
+
+	var initialRoot []byte
+	if o.InitialRootPath != "" {
+		if initialRoot, err = os.ReadFile(o.InitialRootPath); err != nil {
+			return tuf.TrustRoot{}, fmt.Errorf("failed to read initial root metadata: %w", err)
+		}
+	}
+
+	doneLoading := progress.Start("Updating the trust root")
+	trustRoot, err := tuf.Load(context.Background(), repositoryURL, tuf.Options{
+		CacheDir:    cacheDir,
+		InitialRoot: initialRoot,
+		Refresh:     o.Refresh,
+		Offline:     network.Offline(),
+		Fetch:       fetch.DefaultOptions(),
+	})
+	doneLoading()
+	if err != nil {
+		return tuf.TrustRoot{}, fmt.Errorf("failed to load trust root %v: %w", o.URL, err)
+	}
+
+	log.Debugf("(verify) using version %d of trust root %v, which expires at %v", trustRoot.Version, o.URL, trustRoot.Expires)
+	return trustRoot, nil
+}
+
+// trustRootPolicyVerifier returns the trust root's policy key that signed the policy.
+func trustRootPolicyVerifier(trustRoot tuf.TrustRoot, policyEnvelope dsse.Envelope) (cryptoutil.Verifier, error) {
+	verifiers := make([]cryptoutil.Verifier, 0)
+	for _, target := range trustRoot.ByUsage(tuf.PolicyKey) {
+		verifier, err := cryptoutil.NewVerifierFromReader(bytes.NewReader(target.Data))
+		if err != nil {
+			return nil, fmt.Errorf("failed to load policy key %v from the trust root: %w", target.Path, err)
+		}
+
+		verifiers = append(verifiers, verifier)
+	}
+
+	if len(verifiers) == 0 {
+		return nil, fmt.Errorf("the trust root has no policy keys")
+	}
+
+	passed, err := policyEnvelope.Verify(dsse.WithVerifiers(verifiers))
+	if err != nil {
+		return nil, fmt.Errorf("policy is not signed by a policy key in the trust root: %w", err)
+	}
+
+	return passed[0], nil
+}
+
+// applyTrustRoot checks the policy's signature and returns the policy with the trust root's CA roots, timestamp
+// authorities, and Rekor keys in place of the policy's own entries of the same names, signed with an ephemeral key.
+// The policy is returned as it is if the trust root holds none of them.
+func applyTrustRoot(policyEnvelope dsse.Envelope, verifier cryptoutil.Verifier, trustRoot tuf.TrustRoot) (dsse.Envelope, cryptoutil.Verifier, error) {
+	material, err := trustMaterial(trustRoot)
+	if err != nil {
+		return dsse.Envelope{}, nil, err
+	}
+
+	if len(material.Roots) == 0 && len(material.TimestampAuthorities) == 0 && len(material.TransparencyLogs) == 0 {
+		return policyEnvelope, verifier, nil
+	}
+
+	if verifier == nil {
+		return dsse.Envelope{}, nil, fmt.Errorf("policies must be verified with a public key to use the trust root's roots and keys")
+	}
+
+	if _, err := policyEnvelope.Verify(dsse.WithVerifiers([]cryptoutil.Verifier{verifier})); err != nil {
+		return dsse.Envelope{}, nil, fmt.Errorf("could not verify policy: %w", err)
+	}
+
+	payload, err := policy.WithTrustMaterial(policyEnvelope.Payload, material)
+	if err != nil {
+		return dsse.Envelope{}, nil, err
+	}
+
+	return signEphemeralPolicy(payload)
+}
+
+// trustMaterial returns the trust root's CA and Fulcio certificate chains as policy roots, its timestamp authorities,
+// and its Rekor keys as transparency logs, by name.
+func trustMaterial(trustRoot tuf.TrustRoot) (policy.TrustMaterial, error) {
+	material := policy.TrustMaterial{
+		Roots:                make(map[string]policy.Root),
+		TimestampAuthorities: make(map[string]policy.Root),
+		TransparencyLogs:     make(map[string]policy.TransparencyLog),
+	}
+
+	for _, target := range append(trustRoot.ByUsage(tuf.CA), trustRoot.ByUsage(tuf.Fulcio)...) {
+		root, err := certificateChain(target)
+		if err != nil {
+			return policy.TrustMaterial{}, err
+		}
+
+		material.Roots[target.Name] = root
+	}
+
+	for _, target := range trustRoot.ByUsage(tuf.TimestampAuthority) {
+		root, err := certificateChain(target)
+		if err != nil {
+			return policy.TrustMaterial{}, err
+		}
+
+		material.TimestampAuthorities[target.Name] = root
+	}
+
+	for _, target := range trustRoot.ByUsage(tuf.Rekor) {
+		if _, err := cryptoutil.NewVerifierFromReader(bytes.NewReader(target.Data)); err != nil {
+			return policy.TrustMaterial{}, fmt.Errorf("failed to load rekor key %v from the trust root: %w", target.Path, err)
+		}
+
+		material.TransparencyLogs[target.Name] = policy.TransparencyLog{Key: target.Data}
+	}
+
+	return material, nil
+}
+
+// certificateChain returns the target's PEM encoded certificates as a policy root: the first certificate, with the
+// rest as its intermediates.
+func certificateChain(target tuf.Target) (policy.Root, error) {
+	certs := make([][]byte, 0)
+	rest := target.Data
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+
+		if _, err := x509.ParseCertificate(block.Bytes); err != nil {
+			return policy.Root{}, fmt.Errorf("failed to parse certificate in %v from the trust root: %w", target.Path, err)
+		}
+
+		certs = append(certs, pem.EncodeToMemory(block))
+	}
+
+	if len(certs) == 0 {
+		return policy.Root{}, fmt.Errorf("%v from the trust root has no PEM encoded certificates", target.Path)
+	}
+
+	return policy.Root{Certificate: certs[0], Intermediates: certs[1:]}, nil
+}
@@ -15,12 +15,15 @@
 package cmd
 
 import (
+	"bytes"
+	"context"
 	"crypto"
 	"crypto/sha256"
 	"encoding/json"
 	"fmt"
 	"io"
 	"os"
+	"time"
 
 	"github.com/spf13/cobra"
 	witness "github.com/testifysec/go-witness"
@@ -29,6 +32,24 @@ import (
 	"github.com/testifysec/go-witness/log"
 	"github.com/testifysec/go-witness/rekor"
 	"github.com/testifysec/witness/options"
+	"github.com/testifysec/witness/pkg/allowedsigners"
+	"github.com/testifysec/witness/pkg/archive"
+	"github.com/testifysec/witness/pkg/fetch"
+	"github.com/testifysec/witness/pkg/fips"
+	"github.com/testifysec/witness/pkg/gitref"
+	"github.com/testifysec/witness/pkg/kms"
+	"github.com/testifysec/witness/pkg/merge"
+	"github.com/testifysec/witness/pkg/network"
+	"github.com/testifysec/witness/pkg/nullsigner"
+	"github.com/testifysec/witness/pkg/payload"
+	"github.com/testifysec/witness/pkg/policy"
+	"github.com/testifysec/witness/pkg/progress"
+	"github.com/testifysec/witness/pkg/receipt"
+	"github.com/testifysec/witness/pkg/rekorentry"
+	"github.com/testifysec/witness/pkg/sslib"
+	"github.com/testifysec/witness/pkg/tektonchains"
+	"github.com/testifysec/witness/pkg/timestamp"
+	"github.com/testifysec/witness/pkg/tuf"
 )
 
 func VerifyCmd() *cobra.Command {
@@ -41,6 +62,24 @@ func VerifyCmd() *cobra.Command {
 		SilenceUsage:      true,
 		DisableAutoGenTag: true,
 		RunE: func(cmd *cobra.Command, args []string) error {
+			if vo.ProfileURI != "" {
+				if err := applyVerifyProfile(cmd, &vo); err != nil {
+					return err
+				}
+			}
+
+			if vo.Watch.Profiling.Address != "" && !vo.Watch.Enabled {
+				return fmt.Errorf("profiles can only be served with --watch")
+			}
+
+			if vo.Remote.URL != "" {
+				return runVerifyRemote(vo)
+			}
+
+			if vo.Watch.Enabled {
+				return runVerifyWatch(vo, args)
+			}
+
 			return runVerify(vo, args)
 		},
 	}
@@ -52,67 +91,309 @@ const (
 	MAX_DEPTH = 4
 )
 
+// runVerify verifies the artifact and writes the outcome in the requested output format, and as a verification summary
+// attestation if --vsa-out is set. The summary is written whether verification passed or failed.
+func runVerify(vo options.VerifyOptions, args []string) error {
+	if err := checkVerifyOutputs(vo); err != nil {
+		return err
+	}
+
+	report := newVerifyReport(vo)
+	err := verifyArtifact(vo, args, report)
+	if vo.VSAOutPath != "" {
+		if vsaErr := writeVSA(vo, report, err == nil); vsaErr != nil {
+			if err == nil {
+				err = fmt.Errorf("failed to write verification summary attestation: %w", vsaErr)
+			} else {
+				log.Errorf("failed to write verification summary attestation: %v", vsaErr)
+			}
+		}
+	}
+
+	report.finish(err)
+	if vo.Output == "json" {
+		if writeErr := writeVerifyReport(os.Stdout, report); writeErr != nil && err == nil {
+			err = fmt.Errorf("failed to write verification report: %w", writeErr)
+		}
+	}
+
+	return err
+}
+
 //todo: this logic should be broken out and moved to pkg/
 //we need to abstract where keys are coming from, etc
-func runVerify(vo options.VerifyOptions, args []string) error {
-	if vo.KeyPath == "" && len(vo.CAPaths) == 0 {
-		return fmt.Errorf("must suply public key or ca paths")
+func verifyArtifact(vo options.VerifyOptions, args []string, report *verifyReport) error {
+	requirements, err := parseRequirements(vo.Requirements)
+	if err != nil {
+		return err
+	}
+
+	if vo.EnvelopePath != "" {
+		return runVerifyEnvelope(vo)
+	}
+
+	if vo.PolicyFilePath == "" && len(requirements) > 0 {
+		return runVerifyRequirements(vo, requirements)
+	}
+
+	if vo.KeyPath == "" && len(vo.CAPaths) == 0 && len(vo.PolicyPublicKey) == 0 && vo.TrustRoot.URL == "" {
+		return fmt.Errorf("must suply public key, ca paths, or a trust root")
+	}
+
+	var trustRoot tuf.TrustRoot
+	if vo.TrustRoot.URL != "" {
+		trustRoot, err = loadTrustRoot(vo.TrustRoot)
+		if err != nil {
+			return err
+		}
+
+		useTrustRootRekorKey(&vo, trustRoot)
+	}
+
+	policyBytes, err := readFileOrURL(context.Background(), vo.PolicyFilePath, "downloading the policy")
+	if err != nil {
+		return fmt.Errorf("failed to open file to sign: %v", err)
+	}
+
+	policyEnvelope := dsse.Envelope{}
+	if err := json.Unmarshal(policyBytes, &policyEnvelope); err != nil {
+		return fmt.Errorf("could not unmarshal policy envelope: %w", err)
 	}
 
+	if payload.KindOf(policyEnvelope.PayloadType) != payload.KindPolicy {
+		return fmt.Errorf("%v is not a signed witness policy: payload type is %v", vo.PolicyFilePath, policyEnvelope.PayloadType)
+	}
+
+	report.policyEnvelope = &policyEnvelope
+
 	var verifier cryptoutil.Verifier
 
 	if vo.KeyPath != "" {
-		keyFile, err := os.Open(vo.KeyPath)
+		verifier, err = loadPolicyVerifier(vo.KeyPath, policyEnvelope)
 		if err != nil {
-			return fmt.Errorf("failed to open key file: %w", err)
+			return err
 		}
-		defer keyFile.Close()
 
-		verifier, err = cryptoutil.NewVerifierFromReader(keyFile)
+	} else if len(vo.PolicyPublicKey) > 0 {
+		profileVerifier, err := cryptoutil.NewVerifierFromReader(bytes.NewReader(vo.PolicyPublicKey))
 		if err != nil {
-			return fmt.Errorf("failed to create verifier: %w", err)
+			return fmt.Errorf("failed to create verifier from verification profile: %w", err)
+		}
+
+		verifier = profileVerifier
+	} else if vo.TrustRoot.URL != "" {
+		verifier, err = trustRootPolicyVerifier(trustRoot, policyEnvelope)
+		if err != nil {
+			return err
+		}
+	}
+
+	if fips.Enabled() {
+		if verifier != nil {
+			if err := fips.CheckVerifier(verifier); err != nil {
+				return fmt.Errorf("policy verifier is not usable in fips mode: %w", err)
+			}
 		}
 
+		if err := checkPolicyFIPS(policyEnvelope); err != nil {
+			return err
+		}
+	}
+
+	// receipts and evidence bundles record the policy as it was signed, while verification uses the policy with the
+	// trust root's roots and keys and without its delegated steps
+	trustedEnvelope, trustedVerifier := policyEnvelope, verifier
+	if vo.TrustRoot.URL != "" {
+		trustedEnvelope, trustedVerifier, err = applyTrustRoot(policyEnvelope, verifier, trustRoot)
+		if err != nil {
+			return err
+		}
 	}
 
-	inFile, err := os.Open(vo.PolicyFilePath)
+	verifyPolicyEnvelope, policyVerifier, parentPolicy, err := stripDelegatedSteps(trustedEnvelope, trustedVerifier)
 	if err != nil {
-		return fmt.Errorf("failed to open file to sign: %v", err)
+		return err
 	}
 
-	defer inFile.Close()
-	policyEnvelope := dsse.Envelope{}
-	decoder := json.NewDecoder(inFile)
-	if err := decoder.Decode(&policyEnvelope); err != nil {
-		return fmt.Errorf("could not unmarshal policy envelope: %w", err)
+	isGitRef := gitref.IsRef(vo.ArtifactFilePath)
+	if isGitRef && (vo.ExpandArchive || vo.GitHubRepository != "" || vo.AttestationStorage != "" || vo.ArchivistServer != "" || vo.ReceiptPath != "") {
+		return fmt.Errorf("git artifacts can not be used with --expand-archive, --github-repo, --attestation-storage, --archivist-server, or receipts")
 	}
 
-	diskEnvs, err := loadEnvelopesFromDisk(vo.AttestationFilePaths)
+	attestationPaths, attestationURLs := splitAttestationURLs(vo.AttestationFilePaths)
+	if err := checkVerifyOffline(vo, isGitRef, attestationURLs); err != nil {
+		return err
+	}
+
+	tsaVerifier, err := timestampVerifier(parentPolicy)
+	if err != nil {
+		return fmt.Errorf("failed to load timestamp authorities: %w", err)
+	}
+
+	diskEnvs, err := loadEnvelopesFromDisk(attestationPaths, tsaVerifier)
 	if err != nil {
 		return fmt.Errorf("failed to load attestation files: %w", err)
 	}
 
-	verifiedEvidence := []witness.CollectionEnvelope{}
+	if len(attestationURLs) > 0 {
+		fetchOpts := fetch.DefaultOptions()
+		fetchOpts.MaxSize = vo.AttestationMaxSize
+		fetchOpts.Retries = vo.AttestationRetries
+		doneFetching := progress.Start("Downloading attestations")
+		urlEnvs, err := loadEnvelopesFromURLs(context.Background(), attestationURLs, fetchOpts, tsaVerifier)
+		doneFetching()
+		if err != nil {
+			return fmt.Errorf("failed to download attestation files: %w", err)
+		}
 
-	if vo.RekorServer != "" {
+		diskEnvs = append(diskEnvs, urlEnvs...)
+	}
+
+	if vo.RekorServer != "" && len(vo.RekorBundlePaths) > 0 {
+		return fmt.Errorf("--rekor-bundle can not be used with --rekor-server")
+	}
+
+	// the bundles are also checked against the transparency logs of the policy's log inclusion constraints
+	logBundles, err := loadRekorBundles(vo.RekorBundlePaths, attestationPaths)
+	if err != nil {
+		return fmt.Errorf("failed to load rekor bundles: %w", err)
+	}
+
+	if vo.RekorServer == "" {
+		if err := checkRekorBundles(vo, diskEnvs, logBundles); err != nil {
+			return err
+		}
+	}
+
+	for _, env := range diskEnvs {
+		if nullsigner.Signed(env.Envelope) {
+			log.Warnf("%v is signed by the null signer, so it can not satisfy the policy", env.Reference)
+		}
+	}
+
+	if vo.GitHubRepository != "" {
+		doneFetching := progress.Start("Fetching attestations from GitHub")
+		githubEnvs, err := loadEnvelopesFromGitHub(context.Background(), vo.GitHubRepository, vo.ArtifactFilePath)
+		doneFetching()
+		if err != nil {
+			return fmt.Errorf("failed to load attestations from github: %w", err)
+		}
+
+		diskEnvs = append(diskEnvs, githubEnvs...)
+	}
+
+	if vo.AttestationStorage != "" {
+		doneFetching := progress.Start("Fetching attestations from the OCI registry")
+		registryEnvs, err := loadEnvelopesFromOCIRegistry(context.Background(), vo.AttestationStorage, vo.ArtifactFilePath)
+		doneFetching()
+		if err != nil {
+			return fmt.Errorf("failed to load attestations from the oci registry: %w", err)
+		}
+
+		diskEnvs = append(diskEnvs, registryEnvs...)
+	}
+
+	if vo.ArchivistServer != "" {
+		doneFetching := progress.Start("Fetching attestations from Archivista")
+		archivistEnvs, archivistReport, err := loadEnvelopesFromArchivist(context.Background(), vo.ArchivistServer, vo.ArchivistPublicKeyPath, vo.ArtifactFilePath)
+		doneFetching()
+		if err != nil {
+			return fmt.Errorf("failed to load attestations from archivist: %w", err)
+		}
+
+		report.Archivist = archivistReport
+		if archivistReport.PinnedKey != "" {
+			log.Infof("Archivista server holding key %v listed %d attestations for %v at %v", archivistReport.PinnedKey, len(archivistEnvs), archivistReport.Digest, archivistReport.QueriedAt.Format(time.RFC3339))
+		} else {
+			log.Infof("Archivista listed %d attestations for %v; its key is not pinned, so it could have left some out", len(archivistEnvs), archivistReport.Digest)
+		}
+
+		diskEnvs = append(diskEnvs, archivistEnvs...)
+	}
+
+	diskEnvs = statementEnvelopes(diskEnvs)
+	report.considered = diskEnvs
+
+	if vo.UseReceipt && vo.ReceiptPath == "" {
+		return fmt.Errorf("a receipt path must be provided to use a verification receipt")
+	}
+
+	var receiptSigner cryptoutil.Signer
+	var expectedReceipt receipt.Receipt
+	if vo.ReceiptPath != "" {
+		receiptSigner, err = loadReceiptSigner(vo.ReceiptKeyPath)
+		if err != nil {
+			return fmt.Errorf("failed to load receipt signer: %w", err)
+		}
 
-		artifactDigestSet, err := cryptoutil.CalculateDigestSetFromFile(vo.ArtifactFilePath, []crypto.Hash{crypto.SHA256})
+		ro, err := newReceiptOptions(vo, verifyPolicyEnvelope, policyVerifier, logBundles)
 		if err != nil {
-			return fmt.Errorf("failed to calculate artifact file's hash: %w", err)
+			return fmt.Errorf("failed to create verification receipt: %w", err)
 		}
 
-		rc, err := rekor.New(vo.RekorServer)
+		expectedReceipt, err = newVerificationReceipt(vo.ArtifactFilePath, policyEnvelope, diskEnvs, ro)
 		if err != nil {
-			return fmt.Errorf("failed to get initialize Rekor client: %w", err)
+			return fmt.Errorf("failed to create verification receipt: %w", err)
+		}
+
+		if vo.UseReceipt && vo.EvidenceOutPath == "" {
+			r, err := checkReceipt(vo.ReceiptPath, receiptSigner, expectedReceipt, parentPolicy, vo)
+			if err == nil {
+				report.verified = diskEnvs
+				log.Infof("Verification succeeded using receipt issued at %v", r.VerifiedAt)
+				log.Info("Evidence:")
+				for i, e := range r.Evidence {
+					log.Info(fmt.Sprintf("%d: %s", i, e))
+				}
+
+				return nil
+			}
+
+			log.Infof("Not using verification receipt: %v", err)
+		}
+	}
+
+	var archiveDigests map[string]cryptoutil.DigestSet
+	if vo.ExpandArchive {
+		if vo.ArtifactFilePath == "" {
+			return fmt.Errorf("an artifact file is required to expand an archive")
+		}
+
+		doneHashing := progress.Start("Hashing archive files")
+		archiveDigests, err = archive.Digests(vo.ArtifactFilePath, []crypto.Hash{crypto.SHA256})
+		doneHashing()
+		if err != nil {
+			return fmt.Errorf("failed to calculate digests of archive files: %w", err)
+		}
+	}
+
+	verifiedEvidence := []witness.CollectionEnvelope{}
+	var artifactDigestSet cryptoutil.DigestSet
+	if vo.ArtifactFilePath != "" {
+		doneHashing := progress.Start("Hashing artifact")
+		artifactDigestSet, err = loadArtifactDigestSet(context.Background(), vo.ArtifactFilePath)
+		doneHashing()
+		if err != nil {
+			return err
+		}
+
+		report.ArtifactDigest = artifactDigestSet
+	}
+
+	if vo.RekorServer != "" {
+		if vo.ArtifactFilePath == "" {
+			return fmt.Errorf("an artifact file is required to find evidence in rekor")
 		}
 
 		digestSets := []cryptoutil.DigestSet{}
 		digestSets = append(digestSets, artifactDigestSet)
+		for _, ds := range archiveDigests {
+			digestSets = append(digestSets, ds)
+		}
 
-		verifiers := []cryptoutil.Verifier{}
-		verifiers = append(verifiers, verifier)
-
-		evidence, err := rc.FindEvidence(digestSets, policyEnvelope, verifiers, diskEnvs, MAX_DEPTH)
+		doneSearching := progress.Start("Searching Rekor for evidence")
+		evidence, err := searchRekor(vo, digestSets, verifyPolicyEnvelope, []cryptoutil.Verifier{policyVerifier}, diskEnvs)
+		doneSearching()
 		if err != nil {
 			return fmt.Errorf("failed to find evidence: %w", err)
 		}
@@ -121,23 +402,311 @@ func runVerify(vo options.VerifyOptions, args []string) error {
 	}
 
 	if vo.RekorServer == "" {
-		verifiedEvidence, err = witness.Verify(policyEnvelope, []cryptoutil.Verifier{verifier}, witness.VerifyWithCollectionEnvelopes(diskEnvs))
+		verifiedEvidence, err = witness.Verify(verifyPolicyEnvelope, []cryptoutil.Verifier{policyVerifier}, witness.VerifyWithCollectionEnvelopes(diskEnvs))
+		if err != nil {
+			return fmt.Errorf("failed to verify policy: %w", err)
+
+		}
+	}
+
+	groupCache, err := loadGroups(vo.Groups)
+	if err != nil {
+		return fmt.Errorf("failed to load groups: %w", err)
+	}
+
+	result, err := verifyPolicyConstraints(verifyPolicyEnvelope, verifiedEvidence, groupCache, logBundles)
+	report.setSteps(result, verifiedEvidence)
+	if err != nil {
+		return fmt.Errorf("failed to verify policy: %w", err)
+	}
+
+	delegatedEvidence, err := verifyDelegations(context.Background(), parentPolicy, mergeEvidence(diskEnvs, verifiedEvidence), groupCache, logBundles)
+	if err != nil {
+		return fmt.Errorf("failed to verify policy: %w", err)
+	}
+
+	verifiedEvidence = mergeEvidence(verifiedEvidence, delegatedEvidence)
+
+	baseImageEvidence, err := verifyBaseImages(context.Background(), vo, parentPolicy, verifiedEvidence, mergeEvidence(diskEnvs, verifiedEvidence), groupCache, logBundles, 0)
+	if err != nil {
+		return fmt.Errorf("failed to verify policy: %w", err)
+	}
+
+	verifiedEvidence = mergeEvidence(verifiedEvidence, baseImageEvidence)
+
+	if err := checkRequirements(requirements, verifiedEvidence); err != nil {
+		return fmt.Errorf("failed to verify policy: %w", err)
+	}
+
+	if err := saveGroupSnapshot(vo.Groups, groupCache); err != nil {
+		return fmt.Errorf("failed to save group snapshot: %w", err)
+	}
+
+	var counterState policy.CounterState
+	if vo.BuildCounterState != "" {
+		counterState, err = checkBuildCounters(vo.BuildCounterState, verifiedEvidence)
 		if err != nil {
 			return fmt.Errorf("failed to verify policy: %w", err)
+		}
+	}
+
+	if isGitRef {
+		if err := checkArtifactSubject(verifiedEvidence, artifactDigestSet); err != nil {
+			return fmt.Errorf("failed to verify policy: %w", err)
+		}
+	}
+
+	if vo.ExpandArchive {
+		if err := checkArchiveSubjects(verifiedEvidence, artifactDigestSet, archiveDigests); err != nil {
+			return err
+		}
+	}
+
+	if vo.EvidenceOutPath != "" {
+		if err := writeEvidenceBundle(vo, policyEnvelope, verifier, verifiedEvidence, artifactDigestSet); err != nil {
+			return fmt.Errorf("failed to write evidence bundle: %w", err)
+		}
+	}
 
+	if counterState != nil {
+		if err := counterState.Save(vo.BuildCounterState); err != nil {
+			return fmt.Errorf("failed to save build counter state: %w", err)
 		}
 	}
 
+	report.verified = verifiedEvidence
 	log.Info("Verification succeeded")
 	log.Info("Evidence:")
 	for i, e := range verifiedEvidence {
 		log.Info(fmt.Sprintf("%d: %s", i, e.Reference))
+		expectedReceipt.Evidence = append(expectedReceipt.Evidence, e.Reference)
+	}
+
+	if vo.ReceiptPath != "" {
+		if err := writeReceipt(vo.ReceiptPath, receiptSigner, expectedReceipt); err != nil {
+			return fmt.Errorf("failed to write verification receipt: %w", err)
+		}
+	}
+
+	return nil
+
+}
+
+// checkVerifyOffline fails if the verification needs the network while witness is in offline mode.
+func checkVerifyOffline(vo options.VerifyOptions, isGitRef bool, attestationURLs []string) error {
+	operations := map[string]bool{
+		"searching Rekor for attestations":      vo.RekorServer != "",
+		"downloading attestations":              len(attestationURLs) > 0,
+		"fetching GitHub artifact attestations": vo.GitHubRepository != "",
+		"fetching attestations from a registry": vo.AttestationStorage != "",
+		"fetching attestations from archivist":  vo.ArchivistServer != "",
+		"resolving git artifacts":               isGitRef,
+		"resolving groups from a directory":     vo.Groups.SCIMURL != "" || vo.Groups.LDAPURL != "",
 	}
+
+	for operation, needed := range operations {
+		if needed {
+			if err := network.Check(operation); err != nil {
+				return err
+			}
+		}
+	}
+
 	return nil
+}
+
+// loadPolicyVerifier loads the policy's public key from --publickey. If it is an OpenSSH allowed_signers file with
+// several keys, the key of the allowed signer that signed the policy is used.
+func loadPolicyVerifier(path string, policyEnvelope dsse.Envelope) (cryptoutil.Verifier, error) {
+	verifiers, err := loadVerifiers(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(verifiers) == 1 {
+		return verifiers[0], nil
+	}
+
+	passed, err := policyEnvelope.Verify(dsse.WithVerifiers(verifiers))
+	if err != nil {
+		return nil, fmt.Errorf("policy is not signed by an allowed signer: %w", err)
+	}
+
+	return passed[0], nil
+}
+
+// loadVerifiers loads the public key in a PEM file, the keys an OpenSSH allowed_signers file allows to sign for
+// witness, or the public key of a KMS key reference such as gcpkms://projects/....
+func loadVerifiers(path string) ([]cryptoutil.Verifier, error) {
+	if kms.IsReference(path) {
+		if err := network.Check("verifying with a KMS key"); err != nil {
+			return nil, err
+		}
+
+		verifier, err := kms.Get(context.Background(), path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load kms key: %w", err)
+		}
+
+		return []cryptoutil.Verifier{verifier}, nil
+	}
+
+	keyBytes, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open key file: %w", err)
+	}
+
+	if !allowedsigners.IsAllowedSigners(keyBytes) {
+		verifier, err := cryptoutil.NewVerifierFromReader(bytes.NewReader(keyBytes))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create verifier: %w", err)
+		}
+
+		return []cryptoutil.Verifier{verifier}, nil
+	}
+
+	signers, err := allowedsigners.Parse(bytes.NewReader(keyBytes))
+	if err != nil {
+		return nil, err
+	}
+
+	verifiers, err := allowedsigners.Verifiers(signers, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	if len(verifiers) == 0 {
+		return nil, fmt.Errorf("%v has no keys allowed to sign for the %v namespace", path, allowedsigners.Namespace)
+	}
+
+	return verifiers, nil
+}
+
+// searchRekor finds the evidence in Rekor indexed under the digests and verifies it, along with the candidates, against
+// the policy.
+func searchRekor(vo options.VerifyOptions, digestSets []cryptoutil.DigestSet, policyEnvelope dsse.Envelope, verifiers []cryptoutil.Verifier, candidates []witness.CollectionEnvelope) ([]witness.CollectionEnvelope, error) {
+	rekorKey, err := resolveRekorPublicKey(vo)
+	if err != nil {
+		return nil, err
+	}
+
+	if rekorKey != nil {
+		// go-witness does not check entries against the log's key, so pinned logs are only searched by rekorentry
+		pinned := rekorentry.New(vo.RekorServer)
+		pinned.PublicKey = rekorKey
+		return findEvidenceAllKinds(pinned, digestSets, policyEnvelope, verifiers, candidates)
+	}
+
+	rc, err := rekor.New(vo.RekorServer)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get initialize Rekor client: %w", err)
+	}
+
+	evidence, err := rc.FindEvidence(digestSets, policyEnvelope, verifiers, candidates, MAX_DEPTH)
+	if err != nil {
+		// go-witness only reads the entry kind it creates, so search again including entries of other kinds
+		log.Debugf("(verify) searching rekor for entries of every kind: %v", err)
+		return findEvidenceAllKinds(rekorentry.New(vo.RekorServer), digestSets, policyEnvelope, verifiers, candidates)
+	}
+
+	return evidence, nil
+}
+
+// useTrustRootRekorKey pins the trust root's Rekor key, if it holds exactly one and no key file is pinned.
+func useTrustRootRekorKey(vo *options.VerifyOptions, trustRoot tuf.TrustRoot) {
+	if rekorKeys := trustRoot.ByUsage(tuf.Rekor); vo.RekorPublicKeyPath == "" && len(rekorKeys) == 1 {
+		vo.RekorPublicKey = rekorKeys[0].Data
+	}
+}
+
+// resolveRekorPublicKey returns the pinned Rekor public key from --rekor-public-key or the trust root, or nil if no key
+// is pinned.
+func resolveRekorPublicKey(vo options.VerifyOptions) (cryptoutil.Verifier, error) {
+	rekorKey, err := loadRekorPublicKey(vo.RekorPublicKeyPath)
+	if err != nil || rekorKey != nil || len(vo.RekorPublicKey) == 0 {
+		return rekorKey, err
+	}
+
+	rekorKey, err = cryptoutil.NewVerifierFromReader(bytes.NewReader(vo.RekorPublicKey))
+	if err != nil {
+		return nil, fmt.Errorf("failed to load the trust root's rekor public key: %w", err)
+	}
+
+	return rekorKey, nil
+}
+
+// checkRekorBundles requires every attestation to have a Rekor bundle signed by the pinned Rekor key, if a key is
+// pinned, when Rekor isn't searched.
+func checkRekorBundles(vo options.VerifyOptions, envs []witness.CollectionEnvelope, bundles []rekorentry.Bundle) error {
+	rekorKey, err := resolveRekorPublicKey(vo)
+	if err != nil || rekorKey == nil {
+		return err
+	}
 
+	return verifyRekorBundles(envs, bundles, rekorKey)
 }
 
-func loadEnvelopesFromDisk(paths []string) ([]witness.CollectionEnvelope, error) {
+// loadRekorPublicKey returns the pinned Rekor public key, or nil if no key is pinned.
+func loadRekorPublicKey(path string) (cryptoutil.Verifier, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	keyFile, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open rekor public key: %w", err)
+	}
+
+	defer keyFile.Close()
+	verifier, err := cryptoutil.NewVerifierFromReader(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load rekor public key: %w", err)
+	}
+
+	return verifier, nil
+}
+
+// findEvidenceAllKinds searches Rekor for dsse and intoto entries indexed under the digests and verifies them, and the
+// attestation files, against the policy.
+func findEvidenceAllKinds(rc *rekorentry.Client, digestSets []cryptoutil.DigestSet, policyEnvelope dsse.Envelope, verifiers []cryptoutil.Verifier, diskEnvs []witness.CollectionEnvelope) ([]witness.CollectionEnvelope, error) {
+	known := make([]rekorentry.Evidence, 0, len(diskEnvs))
+	for _, env := range diskEnvs {
+		known = append(known, rekorentry.Evidence{Envelope: env.Envelope, Reference: env.Reference})
+	}
+
+	verify := func(candidates []rekorentry.Evidence) ([]rekorentry.Evidence, error) {
+		collectionEnvs := make([]witness.CollectionEnvelope, 0, len(candidates))
+		for _, c := range candidates {
+			collectionEnvs = append(collectionEnvs, witness.CollectionEnvelope{Envelope: c.Envelope, Reference: c.Reference})
+		}
+
+		verified, err := witness.Verify(policyEnvelope, verifiers, witness.VerifyWithCollectionEnvelopes(collectionEnvs))
+		if err != nil {
+			return nil, err
+		}
+
+		evidence := make([]rekorentry.Evidence, 0, len(verified))
+		for _, v := range verified {
+			evidence = append(evidence, rekorentry.Evidence{Envelope: v.Envelope, Reference: v.Reference})
+		}
+
+		return evidence, nil
+	}
+
+	found, err := rc.FindEvidence(context.Background(), digestSets, known, verify, MAX_DEPTH)
+	if err != nil {
+		return nil, err
+	}
+
+	evidence := make([]witness.CollectionEnvelope, 0, len(found))
+	for _, f := range found {
+		evidence = append(evidence, witness.CollectionEnvelope{Envelope: f.Envelope, Reference: f.Reference})
+	}
+
+	return evidence, nil
+}
+
+func loadEnvelopesFromDisk(paths []string, tsaVerifier *timestamp.Verifier) ([]witness.CollectionEnvelope, error) {
 	envelopes := make([]witness.CollectionEnvelope, 0)
 	for _, path := range paths {
 		file, err := os.Open(path)
@@ -151,20 +720,80 @@ func loadEnvelopesFromDisk(paths []string) ([]witness.CollectionEnvelope, error)
 			continue
 		}
 
-		env := dsse.Envelope{}
-		if err := json.Unmarshal(fileBytes, &env); err != nil {
-			continue
+		envelopes = append(envelopes, parseEnvelopes(fileBytes, path, tsaVerifier)...)
+	}
+
+	return envelopes, nil
+}
+
+// splitAttestationURLs separates attestation files given as http(s) URLs from local paths.
+func splitAttestationURLs(attestations []string) ([]string, []string) {
+	paths := make([]string, 0, len(attestations))
+	urls := make([]string, 0)
+	for _, attestation := range attestations {
+		if fetch.IsURL(attestation) {
+			urls = append(urls, attestation)
+		} else {
+			paths = append(paths, attestation)
 		}
+	}
 
-		h := sha256.Sum256(fileBytes)
+	return paths, urls
+}
 
-		collectionEnv := witness.CollectionEnvelope{
-			Envelope:  env,
-			Reference: fmt.Sprintf("sha256:%x  %s", h, path),
+// loadEnvelopesFromURLs downloads attestation files from http(s) URLs, checking any digest pins.
+func loadEnvelopesFromURLs(ctx context.Context, urls []string, opts fetch.Options, tsaVerifier *timestamp.Verifier) ([]witness.CollectionEnvelope, error) {
+	envelopes := make([]witness.CollectionEnvelope, 0)
+	for _, url := range urls {
+		body, err := fetch.Fetch(ctx, url, opts)
+		if err != nil {
+			return nil, err
 		}
 
-		envelopes = append(envelopes, collectionEnv)
+		envelopes = append(envelopes, parseEnvelopes(body, url, tsaVerifier)...)
 	}
 
 	return envelopes, nil
 }
+
+// parseEnvelopes returns the envelopes in an attestation file, which may be a single envelope, a merged bundle, or a
+// TaskRun or patch holding tekton chains annotations. Files that contain none of these are ignored. The certificates of
+// the signatures of a single envelope, or of an envelope in chains annotations, are verified at the time a trusted
+// timestamp authority timestamped them, if any did.
+func parseEnvelopes(fileBytes []byte, source string, tsaVerifier *timestamp.Verifier) []witness.CollectionEnvelope {
+	h := sha256.Sum256(fileBytes)
+	if bundle, ok := merge.Parse(fileBytes); ok {
+		envelopes := make([]witness.CollectionEnvelope, 0, len(bundle.Envelopes))
+		for i, env := range bundle.Envelopes {
+			envelopes = append(envelopes, witness.CollectionEnvelope{
+				Envelope:  env,
+				Reference: fmt.Sprintf("sha256:%x  %s#%d", h, source, i),
+			})
+		}
+
+		return envelopes
+	}
+
+	if chainsEnvs, chainsBytes, ok := tektonchains.Parse(fileBytes); ok {
+		envelopes := make([]witness.CollectionEnvelope, 0, len(chainsEnvs))
+		for i, env := range chainsEnvs {
+			envelopes = append(envelopes, witness.CollectionEnvelope{
+				Envelope:  tsaVerifier.Apply(chainsBytes[i], env),
+				Reference: fmt.Sprintf("sha256:%x  %s#%d", h, source, i),
+			})
+		}
+
+		return envelopes
+	}
+
+	// envelopes signed by the python in-toto tools hex encode their signatures
+	env, err := sslib.ParseEnvelope(fileBytes)
+	if err != nil {
+		return nil
+	}
+
+	return []witness.CollectionEnvelope{{
+		Envelope:  tsaVerifier.Apply(fileBytes, env),
+		Reference: fmt.Sprintf("sha256:%x  %s", h, source),
+	}}
+}
@@ -16,20 +16,30 @@ package cmd
 
 import (
 	"bytes"
+	"crypto"
+	"crypto/sha256"
 	"encoding/json"
+	"encoding/pem"
+	"fmt"
 	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/require"
 	witness "github.com/testifysec/go-witness"
+	"github.com/testifysec/go-witness/attestation"
 	"github.com/testifysec/go-witness/attestation/commandrun"
 	"github.com/testifysec/go-witness/cryptoutil"
 	"github.com/testifysec/go-witness/dsse"
+	"github.com/testifysec/go-witness/intoto"
 	"github.com/testifysec/go-witness/policy"
 	"github.com/testifysec/witness/options"
+	"github.com/testifysec/witness/pkg/vsa"
 )
 
 func Test_RunVerifyCA(t *testing.T) {
@@ -74,7 +84,7 @@ func Test_RunVerifyCA(t *testing.T) {
 		Attestations: []string{},
 		OutFilePath:  filepath.Join(attestationDir, "step01.json"),
 		StepName:     "step01",
-		RekorServer:  "",
+		RekorServers: []string{},
 		Tracing:      false,
 	}
 
@@ -95,7 +105,7 @@ func Test_RunVerifyCA(t *testing.T) {
 		Attestations: []string{},
 		OutFilePath:  filepath.Join(attestationDir, "step02.json"),
 		StepName:     "step02",
-		RekorServer:  "",
+		RekorServers: []string{},
 		Tracing:      false,
 	}
 
@@ -148,7 +158,7 @@ func Test_loadEnvelopesFromDisk(t *testing.T) {
 		t.Error(err)
 	}
 
-	envelopes, err := loadEnvelopesFromDisk([]string{filepath.Join(workingDir, "envelope.txt")})
+	envelopes, err := loadEnvelopesFromDisk([]string{filepath.Join(workingDir, "envelope.txt")}, nil)
 	if err != nil {
 		t.Error(err)
 	}
@@ -208,7 +218,7 @@ func Test_RunVerifyKeyPair(t *testing.T) {
 		Attestations: []string{},
 		OutFilePath:  filepath.Join(attestationDir, "step01.json"),
 		StepName:     "step01",
-		RekorServer:  "",
+		RekorServers: []string{},
 		Tracing:      false,
 	}
 
@@ -229,7 +239,7 @@ func Test_RunVerifyKeyPair(t *testing.T) {
 		Attestations: []string{},
 		OutFilePath:  filepath.Join(attestationDir, "step02.json"),
 		StepName:     "step02",
-		RekorServer:  "",
+		RekorServers: []string{},
 		Tracing:      false,
 	}
 
@@ -251,6 +261,110 @@ func Test_RunVerifyKeyPair(t *testing.T) {
 		t.Error(err)
 	}
 
+	vo.VSAOutPath = filepath.Join(workingDir, "vsa.json")
+	vo.VSAKeyPath = filepath.Join(workingDir, "func-priv.pem")
+	require.NoError(t, runVerify(vo, []string{}))
+	require.Equal(t, vsa.ResultPassed, readVSA(t, vo.VSAOutPath).VerificationResult)
+
+	vo.AttestationFilePaths = vo.AttestationFilePaths[:1]
+	require.Error(t, runVerify(vo, []string{}))
+	summary := readVSA(t, vo.VSAOutPath)
+	require.Equal(t, vsa.ResultFailed, summary.VerificationResult)
+	require.Len(t, summary.InputAttestations, 1)
+
+	// the same attestations, found in a pinned Archivista server instead of on disk
+	gitoids := map[string][]byte{}
+	for _, name := range []string{"step01.json", "step02.json"} {
+		data, err := os.ReadFile(filepath.Join(attestationDir, name))
+		require.NoError(t, err)
+		gitoids[fmt.Sprintf("gitoid:blob:sha256:%x", sha256.Sum256(append([]byte(fmt.Sprintf("blob %d\x00", len(data))), data...)))] = data
+	}
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/query" {
+			edges := []string{}
+			for gitoid := range gitoids {
+				edges = append(edges, `{"node": {"gitoidSha256": "`+gitoid+`"}}`)
+			}
+
+			fmt.Fprintf(w, `{"data": {"dsses": {"edges": [%s]}}}`, strings.Join(edges, ","))
+			return
+		}
+
+		_, _ = w.Write(gitoids[strings.TrimPrefix(r.URL.Path, "/download/")])
+	}))
+	defer server.Close()
+
+	serverKeyPath := filepath.Join(workingDir, "archivist.pem")
+	require.NoError(t, os.WriteFile(serverKeyPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0644))
+	vo.AttestationFilePaths = nil
+	vo.VSAOutPath = ""
+	vo.ArchivistServer = server.URL
+	vo.ArchivistPublicKeyPath = serverKeyPath
+	report := newVerifyReport(vo)
+	require.NoError(t, verifyArtifact(vo, []string{}, report))
+	require.NotNil(t, report.Archivist)
+	require.Len(t, report.Archivist.References, 2)
+	serverKey := sha256.Sum256(server.Certificate().RawSubjectPublicKeyInfo)
+	require.Equal(t, fmt.Sprintf("sha256:%x", serverKey), report.Archivist.PinnedKey)
+
+	// a server that doesn't hold the pinned key is refused
+	require.NoError(t, os.WriteFile(serverKeyPath, pub, 0644))
+	require.Error(t, verifyArtifact(vo, []string{}, newVerifyReport(vo)))
+}
+
+func readVSA(t *testing.T, path string) vsa.Summary {
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+
+	env := dsse.Envelope{}
+	require.NoError(t, json.Unmarshal(data, &env))
+	statement := intoto.Statement{}
+	require.NoError(t, json.Unmarshal(env.Payload, &statement))
+	require.Equal(t, vsa.PredicateType, statement.PredicateType)
+
+	summary := vsa.Summary{}
+	require.NoError(t, json.Unmarshal(statement.Predicate, &summary))
+	return summary
+}
+
+func Test_RunVerifyRequirements(t *testing.T) {
+	_, _, pub, priv, err := createTestRSAKey()
+	require.NoError(t, err)
+
+	workingDir := t.TempDir()
+	attestationDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(workingDir, "key.pem"), priv, 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(workingDir, "key.pub"), pub, 0644))
+
+	runOptions := options.RunOptions{
+		KeyOptions:   options.KeyOptions{KeyPath: filepath.Join(workingDir, "key.pem")},
+		WorkingDir:   workingDir,
+		Attestations: []string{},
+		OutFilePath:  filepath.Join(attestationDir, "step01.json"),
+		StepName:     "step01",
+	}
+
+	require.NoError(t, runRun(runOptions, []string{"bash", "-c", "echo 'test01' > test.txt"}))
+
+	vo := options.VerifyOptions{
+		KeyPath:              filepath.Join(workingDir, "key.pub"),
+		AttestationFilePaths: []string{filepath.Join(attestationDir, "step01.json")},
+		ArtifactFilePath:     filepath.Join(workingDir, "test.txt"),
+		Requirements:         []string{"command-run.exitcode=0", "material"},
+	}
+
+	require.NoError(t, runVerify(vo, []string{}))
+
+	vo.Requirements = []string{"command-run.exitcode=1"}
+	require.Error(t, runVerify(vo, []string{}))
+
+	vo.Requirements = []string{"command-run"}
+	vo.KeyPath = filepath.Join(workingDir, "other.pub")
+	_, _, otherPub, _, err := createTestRSAKey()
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(vo.KeyPath, otherPub, 0644))
+	require.Error(t, runVerify(vo, []string{}))
 }
 
 func signPolicyRSA(t *testing.T, p []byte) (signedPolicy []byte, pub []byte) {
@@ -372,3 +486,25 @@ func makepolicy(t *testing.T, functionary policy.Functionary, publicKey policy.P
 
 	return pb
 }
+
+func Test_checkArchiveSubjects(t *testing.T) {
+	statement, err := intoto.NewStatement(attestation.CollectionType, []byte("{}"), map[string]cryptoutil.DigestSet{
+		"file:bin/app": {crypto.SHA256: "aa"},
+		"file:README":  {crypto.SHA256: "bb"},
+	})
+	require.NoError(t, err)
+	payload, err := json.Marshal(statement)
+	require.NoError(t, err)
+	evidence := []witness.CollectionEnvelope{{Envelope: dsse.Envelope{Payload: payload}}}
+
+	files := map[string]cryptoutil.DigestSet{
+		"bin/app": {crypto.SHA256: "aa"},
+		"README":  {crypto.SHA256: "bb"},
+	}
+
+	require.NoError(t, checkArchiveSubjects(evidence, cryptoutil.DigestSet{crypto.SHA256: "archive"}, files))
+
+	files["LICENSE"] = cryptoutil.DigestSet{crypto.SHA256: "cc"}
+	require.Error(t, checkArchiveSubjects(evidence, cryptoutil.DigestSet{crypto.SHA256: "archive"}, files))
+	require.NoError(t, checkArchiveSubjects(evidence, cryptoutil.DigestSet{crypto.SHA256: "aa"}, files))
+}
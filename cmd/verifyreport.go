@@ -0,0 +1,198 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	witness "github.com/testifysec/go-witness"
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/dsse"
+	"github.com/testifysec/witness/options"
+	"github.com/testifysec/witness/pkg/policy"
+	"github.com/testifysec/witness/pkg/vsa"
+)
+
+// verifyReport records what witness verify considered and what it decided, for --output json and --vsa-out.
+type verifyReport struct {
+	Passed         bool                 `json:"passed"`
+	Error          string               `json:"error,omitempty"`
+	Policy         string               `json:"policy,omitempty"`
+	Artifact       string               `json:"artifact,omitempty"`
+	ArtifactDigest cryptoutil.DigestSet `json:"artifactDigest,omitempty"`
+	Evidence       []reportEvidence     `json:"evidence"`
+	Steps          []reportStep         `json:"steps"`
+	Archivist      *reportArchivist     `json:"archivist,omitempty"`
+
+	policyEnvelope *dsse.Envelope
+	considered     []witness.CollectionEnvelope
+	verified       []witness.CollectionEnvelope
+}
+
+// reportEvidence is an attestation that was loaded for verification, and whether its signature satisfied the policy.
+type reportEvidence struct {
+	Reference string `json:"reference"`
+	Verified  bool   `json:"verified"`
+}
+
+type reportStep struct {
+	Name        string             `json:"name"`
+	Passed      bool               `json:"passed"`
+	Reason      string             `json:"reason,omitempty"`
+	Collections []reportCollection `json:"collections"`
+}
+
+type reportCollection struct {
+	Reference string   `json:"reference"`
+	Signers   []string `json:"signers"`
+	Passed    bool     `json:"passed"`
+	Reason    string   `json:"reason,omitempty"`
+}
+
+func newVerifyReport(vo options.VerifyOptions) *verifyReport {
+	return &verifyReport{
+		Policy:   vo.PolicyFilePath,
+		Artifact: vo.ArtifactFilePath,
+		Evidence: []reportEvidence{},
+		Steps:    []reportStep{},
+	}
+}
+
+// setSteps records the policy's step results, resolving the collections to the references of the evidence they were
+// verified from.
+func (r *verifyReport) setSteps(result policy.Result, evidence []witness.CollectionEnvelope) {
+	r.Steps = make([]reportStep, 0, len(result.Steps))
+	for _, step := range result.Steps {
+		collections := make([]reportCollection, 0, len(step.Collections))
+		for _, collection := range step.Collections {
+			collections = append(collections, reportCollection{
+				Reference: evidence[collection.Envelope].Reference,
+				Signers:   collection.Signers,
+				Passed:    collection.Passed,
+				Reason:    collection.Reason,
+			})
+		}
+
+		r.Steps = append(r.Steps, reportStep{Name: step.Name, Passed: step.Passed, Reason: step.Reason, Collections: collections})
+	}
+}
+
+// finish records the outcome of verification and which of the considered attestations were verified.
+func (r *verifyReport) finish(err error) {
+	r.Passed = err == nil
+	if err != nil {
+		r.Error = err.Error()
+	}
+
+	verified := make(map[string]bool)
+	for _, e := range r.verified {
+		verified[e.Reference] = true
+	}
+
+	seen := make(map[string]bool)
+	for _, e := range append(append([]witness.CollectionEnvelope{}, r.considered...), r.verified...) {
+		if seen[e.Reference] {
+			continue
+		}
+
+		seen[e.Reference] = true
+		r.Evidence = append(r.Evidence, reportEvidence{Reference: e.Reference, Verified: verified[e.Reference]})
+	}
+}
+
+func writeVerifyReport(w io.Writer, r *verifyReport) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
+
+// checkVerifyOutputs rejects output options that can't be satisfied before anything is verified.
+func checkVerifyOutputs(vo options.VerifyOptions) error {
+	if vo.Output != "" && vo.Output != "text" && vo.Output != "json" {
+		return fmt.Errorf("unknown output format %v, expected text or json", vo.Output)
+	}
+
+	if vo.VSAOutPath == "" {
+		return nil
+	}
+
+	if vo.ArtifactFilePath == "" {
+		return fmt.Errorf("an artifact file is required to write a verification summary attestation")
+	}
+
+	if vo.VSAKeyPath == "" {
+		return fmt.Errorf("a key is required to sign the verification summary attestation")
+	}
+
+	return nil
+}
+
+// writeVSA signs a verification summary attestation of the decision about the artifact. The input attestations are
+// the verified evidence if verification passed, and every considered attestation if it failed.
+func writeVSA(vo options.VerifyOptions, r *verifyReport, passed bool) error {
+	if r.policyEnvelope == nil || len(r.ArtifactDigest) == 0 {
+		return fmt.Errorf("a verification summary attestation needs a policy and an artifact, and verification stopped before both were loaded")
+	}
+
+	signer, err := loadKeySigner(vo.VSAKeyPath)
+	if err != nil {
+		return fmt.Errorf("failed to load verification summary signer: %w", err)
+	}
+
+	policyDescriptor, err := vsa.Describe(vo.PolicyFilePath, *r.policyEnvelope)
+	if err != nil {
+		return err
+	}
+
+	summary := vsa.Summary{
+		Verifier:           vsa.Verifier{ID: vo.VSAVerifierID},
+		TimeVerified:       time.Now().UTC(),
+		ResourceURI:        vo.ArtifactFilePath,
+		Policy:             policyDescriptor,
+		InputAttestations:  []vsa.ResourceDescriptor{},
+		VerificationResult: vsa.ResultFailed,
+	}
+
+	inputs := r.considered
+	if passed {
+		summary.VerificationResult = vsa.ResultPassed
+		inputs = r.verified
+	}
+
+	for _, e := range inputs {
+		descriptor, err := vsa.Describe(e.Reference, e.Envelope)
+		if err != nil {
+			return err
+		}
+
+		summary.InputAttestations = append(summary.InputAttestations, descriptor)
+	}
+
+	env, err := vsa.Sign(summary, map[string]cryptoutil.DigestSet{vo.ArtifactFilePath: r.ArtifactDigest}, signer)
+	if err != nil {
+		return fmt.Errorf("failed to sign verification summary: %w", err)
+	}
+
+	out, err := loadOutfile(vo.VSAOutPath)
+	if err != nil {
+		return err
+	}
+
+	defer out.Close()
+	return json.NewEncoder(out).Encode(&env)
+}
@@ -0,0 +1,61 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	witness "github.com/testifysec/go-witness"
+	"github.com/testifysec/witness/options"
+	"github.com/testifysec/witness/pkg/policy"
+)
+
+func TestVerifyReport(t *testing.T) {
+	report := newVerifyReport(options.VerifyOptions{PolicyFilePath: "policy.json", ArtifactFilePath: "app"})
+	report.considered = []witness.CollectionEnvelope{{Reference: "build.json"}, {Reference: "test.json"}}
+	report.setSteps(policy.Result{Steps: []policy.StepResult{
+		{Name: "build", Passed: true, Collections: []policy.CollectionResult{{Envelope: 0, Signers: []string{"builder"}, Passed: true}}},
+		{Name: "test", Passed: false, Reason: "command mismatch", Collections: []policy.CollectionResult{{Envelope: 1, Passed: false, Reason: "command mismatch"}}},
+	}}, report.considered)
+
+	report.verified = report.considered[:1]
+	report.finish(fmt.Errorf("step test failed"))
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, writeVerifyReport(buf, report))
+	decoded := verifyReport{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &decoded))
+	require.False(t, decoded.Passed)
+	require.Equal(t, "step test failed", decoded.Error)
+	require.Equal(t, []reportEvidence{{Reference: "build.json", Verified: true}, {Reference: "test.json", Verified: false}}, decoded.Evidence)
+	require.Len(t, decoded.Steps, 2)
+	require.Equal(t, "build.json", decoded.Steps[0].Collections[0].Reference)
+	require.Equal(t, []string{"builder"}, decoded.Steps[0].Collections[0].Signers)
+	require.Equal(t, "test.json", decoded.Steps[1].Collections[0].Reference)
+	require.Equal(t, "command mismatch", decoded.Steps[1].Reason)
+}
+
+func TestCheckVerifyOutputs(t *testing.T) {
+	require.NoError(t, checkVerifyOutputs(options.VerifyOptions{}))
+	require.NoError(t, checkVerifyOutputs(options.VerifyOptions{Output: "json"}))
+	require.Error(t, checkVerifyOutputs(options.VerifyOptions{Output: "yaml"}))
+	require.Error(t, checkVerifyOutputs(options.VerifyOptions{VSAOutPath: "vsa.json", VSAKeyPath: "key.pem"}))
+	require.Error(t, checkVerifyOutputs(options.VerifyOptions{VSAOutPath: "vsa.json", ArtifactFilePath: "app"}))
+	require.NoError(t, checkVerifyOutputs(options.VerifyOptions{VSAOutPath: "vsa.json", VSAKeyPath: "key.pem", ArtifactFilePath: "app"}))
+}
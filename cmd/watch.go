@@ -0,0 +1,78 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"github.com/testifysec/go-witness/log"
+	"github.com/testifysec/witness/options"
+	"github.com/testifysec/witness/pkg/monitor"
+	"github.com/testifysec/witness/pkg/transport"
+)
+
+// runVerifyWatch re-verifies each artifact every interval until the process exits. The policy and attestations are
+// loaded again for every check so revoked keys, new policies, and new attestations in Rekor or GitHub are picked up.
+func runVerifyWatch(vo options.VerifyOptions, args []string) error {
+	if vo.ReceiptPath != "" || vo.EvidenceOutPath != "" || vo.VSAOutPath != "" {
+		return fmt.Errorf("receipts, evidence bundles, and verification summaries can not be written with --watch")
+	}
+
+	if vo.Watch.Interval <= 0 {
+		return fmt.Errorf("the watch interval must be greater than 0")
+	}
+
+	artifacts := append([]string{}, vo.Watch.ArtifactPaths...)
+	if vo.ArtifactFilePath != "" {
+		artifacts = append([]string{vo.ArtifactFilePath}, artifacts...)
+	}
+
+	if len(artifacts) == 0 {
+		return fmt.Errorf("at least one artifact is required with --watch")
+	}
+
+	serveProfiles(vo.Watch.Profiling)
+	m := monitor.New(artifacts, func(ctx context.Context, artifact string) error {
+		artifactOpts := vo
+		artifactOpts.ArtifactFilePath = artifact
+		return runVerify(artifactOpts, args)
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if vo.Watch.AlertURL != "" {
+		var err error
+		m.AlertClient, m.AlertURL, err = transport.HTTPClient(ctx, vo.Watch.AlertURL)
+		if err != nil {
+			return fmt.Errorf("failed to create alert client: %w", err)
+		}
+	}
+
+	if vo.Watch.MetricsAddress != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/metrics", m)
+		go func() {
+			log.Infof("Serving verification metrics on %v", vo.Watch.MetricsAddress)
+			if err := http.ListenAndServe(vo.Watch.MetricsAddress, mux); err != nil {
+				log.Errorf("failed to serve metrics: %v", err)
+			}
+		}()
+	}
+
+	m.Run(ctx, vo.Watch.Interval)
+	return nil
+}
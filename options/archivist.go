@@ -0,0 +1,54 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package options
+
+import (
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+type ArchivistOptions struct {
+	Server      string
+	SpoolDir    string
+	Timeout     time.Duration
+	DialTimeout time.Duration
+	Retries     int
+	GRPC        bool
+	// SPIFFESocket is the Workload API socket the SVID used to authenticate to the server with mTLS is fetched from.
+	SPIFFESocket string
+	SPIFFEIDs    []string
+}
+
+func (ao *ArchivistOptions) AddFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&ao.Server, "archivist-server", "", "Archivista server to store attestations in. unix:///path/to/socket and unix:@name connect to a unix or abstract socket. A comma separated list of servers fails over between them")
+	cmd.Flags().StringVar(&ao.SpoolDir, "archivist-spool-dir", "", "Directory attestations are spooled to when the Archivista server can't be reached, to be sent later with witness sync. Defaults to witness/spool in the user cache directory")
+	cmd.Flags().DurationVar(&ao.Timeout, "archivist-timeout", 30*time.Second, "Limit on each attempt to store an attestation in Archivista, including connecting. 0 disables the limit")
+	cmd.Flags().DurationVar(&ao.DialTimeout, "archivist-dial-timeout", 10*time.Second, "Limit on connecting to the Archivista server. 0 uses the default limit of 30s")
+	cmd.Flags().StringVar(&ao.SPIFFESocket, "archivist-spiffe-socket", "", "SPIFFE Workload API socket to fetch an X.509 SVID from to authenticate to the Archivista server with mTLS, such as unix:///run/spire/sockets/agent.sock")
+	cmd.Flags().StringSliceVar(&ao.SPIFFEIDs, "archivist-spiffe-id", []string{}, "SPIFFE ID the Archivista server must present with mTLS, or a trust domain followed by /* to accept any ID in it, such as spiffe://example.org/*. May be repeated")
+	cmd.Flags().BoolVar(&ao.GRPC, "archivist-grpc", false, "Stream attestations to the Archivista server's Collector gRPC service in chunks instead of posting them to its upload API")
+	cmd.Flags().IntVar(&ao.Retries, "archivist-retries", 3, "How many times to retry storing an attestation in Archivista, waiting twice as long after each failure")
+}
+
+type SyncOptions struct {
+	Archivist    ArchivistOptions
+	RekorRetries int
+}
+
+func (so *SyncOptions) AddFlags(cmd *cobra.Command) {
+	so.Archivist.AddFlags(cmd)
+	cmd.Flags().IntVar(&so.RekorRetries, "rekor-retries", 3, "How many times to retry creating each pending Rekor entry when Rekor can't be reached or is overloaded, waiting twice as long after each failure")
+}
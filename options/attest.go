@@ -0,0 +1,69 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package options
+
+import (
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+type AttestOptions struct {
+	KeyOptions         KeyOptions
+	WorkingDir         string
+	Attestations       []string
+	OutFilePath        string
+	StepName           string
+	RekorServers       []string
+	RekorEntryType     string
+	RekorBundlePath    string
+	RekorRetries       int
+	PublishReportPath  string
+	Ephemeral          bool
+	EphemeralTTL       time.Duration
+	Obfuscate          []string
+	Labels             []string
+	Matrix             []string
+	DirtyWorktree      string
+	FailOnSecrets      bool
+	OutputFormat       string
+	TektonChainsKey    string
+	KeyAttestationPath string
+	Archivist          ArchivistOptions
+}
+
+func (ao *AttestOptions) AddFlags(cmd *cobra.Command) {
+	ao.KeyOptions.AddFlags(cmd)
+	cmd.Flags().StringVarP(&ao.WorkingDir, "workingdir", "d", "", "Directory the attestors record")
+	cmd.Flags().StringSliceVarP(&ao.Attestations, "attestations", "a", []string{"environment", "git"}, "Attestations to record")
+	cmd.Flags().StringVarP(&ao.OutFilePath, "outfile", "o", "", "File to which to write signed data.  Defaults to stdout")
+	cmd.Flags().StringVarP(&ao.StepName, "step", "s", "", "Name of the step being attested")
+	cmd.Flags().StringSliceVarP(&ao.RekorServers, "rekor-server", "r", []string{}, "Rekor server to store attestations. May be repeated to store them in several logs, such as a public and a private Rekor")
+	cmd.Flags().StringVar(&ao.RekorEntryType, "rekor-entry-type", "dsse", "Kind of Rekor entry to store attestations as. One of dsse or intoto")
+	cmd.Flags().StringVar(&ao.RekorBundlePath, "rekor-bundle", "", "Path to write the Rekor entry's bundle to, so witness verify can check the entry offline. If the upload fails, the pending upload is written there instead, to be resumed with witness sync. Defaults to <outfile>.rekor.json when --outfile is set. With several Rekor servers, each server's host is added before the extension, such as <outfile>.rekor.rekor.sigstore.dev.json")
+	cmd.Flags().IntVar(&ao.RekorRetries, "rekor-retries", 3, "How many times to retry creating the Rekor entry when Rekor can't be reached or is overloaded, waiting twice as long after each failure")
+	cmd.Flags().StringVar(&ao.PublishReportPath, "publish-report", "", "Path to write a json report of where the signed collection was published to, with the status of each Rekor server, OCI registry, and Archivista server")
+	cmd.Flags().BoolVar(&ao.Ephemeral, "ephemeral-key", false, "Sign with a key generated for this run and certified by --key. --certificate must be a CA certificate for --key")
+	cmd.Flags().DurationVar(&ao.EphemeralTTL, "ephemeral-key-ttl", time.Hour, "How long the ephemeral key's certificate is valid for, capped at the expiry of --certificate. Store attestations in Rekor so they verify after it expires")
+	cmd.Flags().StringSliceVar(&ao.Obfuscate, "obfuscate", []string{}, "Obfuscation profiles to apply to recorded host, user, and path data. One of none, hash-hostnames, hash-usernames, or strip-paths")
+	cmd.Flags().StringSliceVar(&ao.Labels, "label", []string{}, "Label to sign with the collection and index it by, in key=value form. May be repeated")
+	cmd.Flags().StringSliceVar(&ao.Matrix, "matrix", []string{}, "Coordinate of the CI matrix job running the step, in dimension=value form such as os=linux. May be repeated")
+	cmd.Flags().StringVar(&ao.DirtyWorktree, "dirty-worktree", "allow", "What to do when the git worktree has uncommitted changes or untracked files. One of allow, record (adds a worktree attestation listing the changed files), or fail")
+	cmd.Flags().BoolVar(&ao.FailOnSecrets, "fail-on-secrets", false, "Scan the working directory, products, and command output for secrets with the secret-scan attestor, and fail without signing the collection if any are found")
+	cmd.Flags().StringVar(&ao.OutputFormat, "output-format", "dsse", "Format to write the signed collection in. One of dsse or tekton-chains")
+	cmd.Flags().StringVar(&ao.TektonChainsKey, "tekton-chains-key", "", "Key of the tekton chains annotations to store the collection in, such as taskrun-<uid>. Required with --output-format tekton-chains")
+	cmd.Flags().StringVar(&ao.KeyAttestationPath, "key-attestation", "", "Path to the key attestation certificate the HSM or key management service holding the signing key issued for it, followed by its chain. Embedded in the signed collection")
+	ao.Archivist.AddFlags(cmd)
+}
@@ -0,0 +1,33 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package options
+
+import (
+	"github.com/spf13/cobra"
+)
+
+type AttestorsTestOptions struct {
+	Attestations []string
+	WorkingDir   string
+	RecordPath   string
+	Update       bool
+}
+
+func (ao *AttestorsTestOptions) AddFlags(cmd *cobra.Command) {
+	cmd.Flags().StringSliceVarP(&ao.Attestations, "attestations", "a", []string{}, "Attestors to record a fixture for with --record")
+	cmd.Flags().StringVarP(&ao.WorkingDir, "workingdir", "d", "", "Directory the attestors record")
+	cmd.Flags().StringVar(&ao.RecordPath, "record", "", "Run the attestors and record their inputs and attestations to a fixture at this path")
+	cmd.Flags().BoolVar(&ao.Update, "update", false, "Replace the attestations in fixtures that no longer match with the replayed attestations")
+}
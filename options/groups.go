@@ -0,0 +1,43 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package options
+
+import (
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+type GroupOptions struct {
+	SCIMURL       string
+	LDAPURL       string
+	LDAPBase      string
+	LDAPGroupBase string
+	LDAPBindDN    string
+	SnapshotPath  string
+	CacheTTL      time.Duration
+	MaxStale      time.Duration
+}
+
+func (g *GroupOptions) AddFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&g.SCIMURL, "groups-scim-url", "", "Base URL of a SCIM 2.0 service to resolve approval groups with. Uses SCIM_TOKEN if set")
+	cmd.Flags().StringVar(&g.LDAPURL, "groups-ldap-url", "", "LDAP server to resolve approval groups with using ldapsearch. Uses LDAP_BIND_PASSWORD if set")
+	cmd.Flags().StringVar(&g.LDAPBase, "groups-ldap-base", "", "Base DN to search for group members under")
+	cmd.Flags().StringVar(&g.LDAPGroupBase, "groups-ldap-group-base", "", "DN containing the groups. Defaults to --groups-ldap-base")
+	cmd.Flags().StringVar(&g.LDAPBindDN, "groups-ldap-bind-dn", "", "DN to bind to the LDAP server as. Binds anonymously if not set")
+	cmd.Flags().StringVar(&g.SnapshotPath, "groups-snapshot", "", "Path to a snapshot of group memberships. Written after resolving groups from a directory, and read when no directory is configured")
+	cmd.Flags().DurationVar(&g.CacheTTL, "groups-cache-ttl", 10*time.Minute, "How long to cache resolved group memberships")
+	cmd.Flags().DurationVar(&g.MaxStale, "groups-max-stale", time.Hour, "How long past --groups-cache-ttl a cached membership is still used while the directory can't be reached. Verification fails once it is older. 0 never uses an expired membership")
+}
@@ -22,8 +22,11 @@ type KeyOptions struct {
 	IntermediatePaths []string
 	SpiffePath        string
 	FulcioURL         string
+	FulcioToken       string
 	OIDCIssuer        string
 	OIDCClientID      string
+	KMSRef            string
+	NullSigner        bool
 }
 
 func (ko *KeyOptions) AddFlags(cmd *cobra.Command) {
@@ -32,6 +35,9 @@ func (ko *KeyOptions) AddFlags(cmd *cobra.Command) {
 	cmd.Flags().StringSliceVarP(&ko.IntermediatePaths, "intermediates", "i", []string{}, "Intermediates that link trust back to a root of trust in the policy")
 	cmd.Flags().StringVar(&ko.SpiffePath, "spiffe-socket", "", "Path to the SPIFFE Workload API socket")
 	cmd.Flags().StringVar(&ko.FulcioURL, "fulcio", "", "Fulcio address to sign with")
-	cmd.Flags().StringVar(&ko.OIDCIssuer, "fulcio-oidc-issuer", "", "OIDC issuer to use for authentication")
-	cmd.Flags().StringVar(&ko.OIDCClientID, "fulcio-oidc-client-id", "", "OIDC client ID to use for authentication")
+	cmd.Flags().StringVar(&ko.FulcioToken, "fulcio-token", "", "OIDC identity token to request the Fulcio certificate with. Defaults to SIGSTORE_ID_TOKEN or the GitHub Actions identity token when available")
+	cmd.Flags().StringVar(&ko.OIDCIssuer, "fulcio-oidc-issuer", "", "OIDC issuer to log in with interactively when no identity token is available")
+	cmd.Flags().StringVar(&ko.OIDCClientID, "fulcio-oidc-client-id", "", "OIDC client ID to log in with interactively when no identity token is available")
+	cmd.Flags().StringVar(&ko.KMSRef, "signer-kms-ref", "", "KMS key to sign with, such as awskms:///alias/witness, gcpkms://projects/<project>/locations/<location>/keyRings/<ring>/cryptoKeys/<key>/cryptoKeyVersions/<version>, azurekms://<vault>.vault.azure.net/<key>, or hashivault://<key>")
+	cmd.Flags().BoolVar(&ko.NullSigner, "null-signer", false, "Sign with a throwaway key generated for this run, for trying witness before real keys are provisioned. Nothing it signs can satisfy a policy")
 }
@@ -0,0 +1,61 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package options
+
+import (
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+type LogAddOptions struct {
+	KeyOptions      KeyOptions
+	Directory       string
+	PublishTo       string
+	PublishInterval time.Duration
+}
+
+func (lo *LogAddOptions) AddFlags(cmd *cobra.Command) {
+	lo.KeyOptions.AddFlags(cmd)
+	cmd.Flags().StringVarP(&lo.Directory, "dir", "d", ".witness-log", "Directory the log is kept in")
+	cmd.Flags().StringVar(&lo.PublishTo, "publish-to", "", "Publish the signed checkpoint to gist:<id> using GITHUB_TOKEN, or PUT it to an http(s) url such as a presigned S3 url using WITNESS_LOG_PUBLISH_TOKEN if set")
+	cmd.Flags().DurationVar(&lo.PublishInterval, "publish-interval", 0, "Only publish a checkpoint if none has been published within this long. Publishes on every add if 0")
+}
+
+type LogProveOptions struct {
+	Directory      string
+	CheckpointPath string
+	OutFilePath    string
+}
+
+func (lo *LogProveOptions) AddFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&lo.Directory, "dir", "d", ".witness-log", "Directory the log is kept in")
+	cmd.Flags().StringVar(&lo.CheckpointPath, "checkpoint", "", "Older checkpoint to include a consistency proof from")
+	cmd.Flags().StringVarP(&lo.OutFilePath, "outfile", "o", "", "File to write the proof to. Defaults to stdout")
+}
+
+type LogVerifyOptions struct {
+	Directory      string
+	KeyPath        string
+	ProofPath      string
+	CheckpointPath string
+}
+
+func (lo *LogVerifyOptions) AddFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&lo.Directory, "dir", "d", ".witness-log", "Directory the log is kept in. Audited against its latest checkpoint if no proof is provided")
+	cmd.Flags().StringVarP(&lo.KeyPath, "publickey", "k", "", "Path to the public key checkpoints are signed with")
+	cmd.Flags().StringVar(&lo.ProofPath, "proof", "", "Proof from witness log prove that the attestation is in the log")
+	cmd.Flags().StringVar(&lo.CheckpointPath, "checkpoint", "", "Older checkpoint, such as a published one, that the log must be consistent with")
+}
@@ -0,0 +1,101 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package options
+
+import (
+	"github.com/spf13/cobra"
+)
+
+type PolicySimulateOptions struct {
+	PolicyFilePath string
+	From           string
+	Since          string
+	GroupBy        string
+	Groups         GroupOptions
+}
+
+func (po *PolicySimulateOptions) AddFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&po.PolicyFilePath, "policy", "p", "", "Path to the proposed policy. May be signed or unsigned")
+	cmd.Flags().StringVar(&po.From, "from", "", "Directory of stored attestations to replay the policy against, such as one kept by witness store")
+	cmd.Flags().StringVar(&po.Since, "since", "30d", "Only replay attestations stored within this long ago, such as 30d, 2w, or 12h")
+	cmd.Flags().StringVar(&po.GroupBy, "group-by", "commithash", "Subject prefix shared by the attestations of one build")
+	po.Groups.AddFlags(cmd)
+}
+
+type PolicyAddSignersOptions struct {
+	PolicyFilePath     string
+	AllowedSignersPath string
+	Principals         []string
+	Steps              []string
+	OutFilePath        string
+}
+
+func (po *PolicyAddSignersOptions) AddFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&po.PolicyFilePath, "policy", "p", "", "Path to the unsigned policy to add the keys to")
+	cmd.Flags().StringVar(&po.AllowedSignersPath, "allowed-signers", "", "Path to an OpenSSH allowed_signers file, such as the one configured as git's gpg.ssh.allowedSignersFile")
+	cmd.Flags().StringSliceVar(&po.Principals, "principal", []string{}, "Only add the keys of these principals. Defaults to every key allowed to sign for witness")
+	cmd.Flags().StringSliceVar(&po.Steps, "step", []string{}, "Steps to trust the keys as functionaries of")
+	cmd.Flags().StringVarP(&po.OutFilePath, "outfile", "o", "", "File to write the updated policy to. Defaults to stdout")
+}
+
+type PolicyValidateOptions struct {
+	PolicyFilePath string
+	JSON           bool
+}
+
+func (po *PolicyValidateOptions) AddFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&po.PolicyFilePath, "policy", "p", "", "Path to the policy to validate. May be signed or unsigned")
+	cmd.Flags().BoolVar(&po.JSON, "json", false, "Print the diagnostics as a json report")
+}
+
+type PolicyInitOptions struct {
+	PublicKeyPaths []string
+	Expires        string
+	OutFilePath    string
+}
+
+func (po *PolicyInitOptions) AddFlags(cmd *cobra.Command) {
+	cmd.Flags().StringSliceVarP(&po.PublicKeyPaths, "publickey", "k", []string{}, "Path to a public key to trust as a functionary of the steps whose attestations it signed. May be repeated")
+	cmd.Flags().StringVar(&po.Expires, "expires", "365d", "How long the policy is valid for, such as 365d, 12w, or 720h")
+	cmd.Flags().StringVarP(&po.OutFilePath, "outfile", "o", "", "File to write the policy to. Defaults to stdout")
+}
+
+type PolicySignOptions struct {
+	KeyOptions       KeyOptions
+	PolicyFilePath   string
+	OutFilePath      string
+	TimestampServers []string
+	Strict           bool
+}
+
+func (po *PolicySignOptions) AddFlags(cmd *cobra.Command) {
+	po.KeyOptions.AddFlags(cmd)
+	cmd.Flags().StringVarP(&po.PolicyFilePath, "policy", "p", "", "Path to the unsigned policy to sign")
+	cmd.Flags().StringVarP(&po.OutFilePath, "outfile", "o", "", "File to write the signed policy to. Defaults to stdout")
+	cmd.Flags().StringSliceVar(&po.TimestampServers, "timestamp-server", []string{}, "URL of an RFC 3161 timestamp authority to timestamp the signature with, so it verifies after the signing certificate expires. May be repeated")
+	cmd.Flags().BoolVar(&po.Strict, "strict", false, "Refuse to sign a policy with warnings as well as errors")
+}
+
+type PolicyCheckOptions struct {
+	PolicyFilePath string
+	JSON           bool
+	Groups         GroupOptions
+}
+
+func (po *PolicyCheckOptions) AddFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&po.PolicyFilePath, "policy", "p", "", "Path to the policy to check the attestation against. May be signed or unsigned")
+	cmd.Flags().BoolVar(&po.JSON, "json", false, "Print the checks as json")
+	po.Groups.AddFlags(cmd)
+}
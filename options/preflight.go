@@ -0,0 +1,33 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package options
+
+import (
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+type PreflightOptions struct {
+	KeyOptions   KeyOptions
+	RekorServer  string
+	MaxClockSkew time.Duration
+}
+
+func (po *PreflightOptions) AddFlags(cmd *cobra.Command) {
+	po.KeyOptions.AddFlags(cmd)
+	cmd.Flags().StringVarP(&po.RekorServer, "rekor-server", "r", "", "Rekor server to check")
+	cmd.Flags().DurationVar(&po.MaxClockSkew, "max-clock-skew", time.Minute, "Largest difference allowed between the local clock and the Rekor and Fulcio servers")
+}
@@ -17,11 +17,23 @@ package options
 import "github.com/spf13/cobra"
 
 type RootOptions struct {
-	Config   string
-	LogLevel string
+	Config        string
+	LogLevel      string
+	FIPS          bool
+	Progress      bool
+	DigestBackend string
+	Offline       bool
+	Proxy         string
+	NoProxy       string
 }
 
 func (ro *RootOptions) AddFlags(cmd *cobra.Command) {
 	cmd.PersistentFlags().StringVarP(&ro.Config, "config", "c", ".witness.yaml", "Path to the witness config file")
 	cmd.PersistentFlags().StringVarP(&ro.LogLevel, "log-level", "l", "info", "Level of logging to output (debug, info, warn, error)")
+	cmd.PersistentFlags().BoolVar(&ro.FIPS, "fips", false, "Restrict signing and verification to FIPS 140-2 approved algorithms")
+	cmd.PersistentFlags().BoolVar(&ro.Progress, "progress", false, "Report the progress of long running phases, such as hashing and Rekor searches, on stderr")
+	cmd.PersistentFlags().StringVar(&ro.DigestBackend, "digest-backend", "go", "Implementation of sha256 to hash artifacts with. auto benchmarks the available implementations and uses the fastest")
+	cmd.PersistentFlags().BoolVar(&ro.Offline, "offline", false, "Disable all network access. Commands that need the network, such as Rekor and Fulcio, fail immediately")
+	cmd.PersistentFlags().StringVar(&ro.Proxy, "proxy", "", "Proxy to send all http and https requests through. Defaults to the HTTPS_PROXY and HTTP_PROXY environment variables")
+	cmd.PersistentFlags().StringVar(&ro.NoProxy, "no-proxy", "", "Comma separated hosts and domains to connect to without the proxy")
 }
@@ -14,16 +14,66 @@
 
 package options
 
-import "github.com/spf13/cobra"
+import (
+	"time"
+
+	"github.com/spf13/cobra"
+)
 
 type RunOptions struct {
-	KeyOptions   KeyOptions
-	WorkingDir   string
-	Attestations []string
-	OutFilePath  string
-	StepName     string
-	RekorServer  string
-	Tracing      bool
+	KeyOptions         KeyOptions
+	WorkingDir         string
+	Attestations       []string
+	OutFilePath        string
+	StepName           string
+	RekorServers       []string
+	RekorEntryType     string
+	RekorBundlePath    string
+	RekorRetries       int
+	PublishReportPath  string
+	Tracing            bool
+	TraceSyscalls      bool
+	Ephemeral          bool
+	EphemeralTTL       time.Duration
+	Obfuscate          []string
+	Labels             []string
+	Matrix             []string
+	DirtyWorktree      string
+	FailOnSecrets      bool
+	OutputFormat       string
+	TektonChainsKey    string
+	KeyAttestationPath string
+	TimestampServers   []string
+	AttestationStorage string
+	Archivist          ArchivistOptions
+	Slim               SlimOptions
+	Heartbeat          HeartbeatOptions
+	Budget             BudgetOptions
+	Artifacts          ArtifactOptions
+}
+
+type ArtifactOptions struct {
+	MaterialInclude []string
+	MaterialExclude []string
+	ProductInclude  []string
+	ProductExclude  []string
+	HashWorkers     int
+}
+
+type BudgetOptions struct {
+	AttestorTimeout time.Duration
+	Deadline        time.Duration
+}
+
+type HeartbeatOptions struct {
+	Interval  time.Duration
+	Directory string
+}
+
+type SlimOptions struct {
+	MaxAttestationSize int
+	MaxProcesses       int
+	DigestOnlyFiles    bool
 }
 
 func (ro *RunOptions) AddFlags(cmd *cobra.Command) {
@@ -32,6 +82,36 @@ func (ro *RunOptions) AddFlags(cmd *cobra.Command) {
 	cmd.Flags().StringSliceVarP(&ro.Attestations, "attestations", "a", []string{"environment", "git"}, "Attestations to record")
 	cmd.Flags().StringVarP(&ro.OutFilePath, "outfile", "o", "", "File to which to write signed data.  Defaults to stdout")
 	cmd.Flags().StringVarP(&ro.StepName, "step", "s", "", "Name of the step being run")
-	cmd.Flags().StringVarP(&ro.RekorServer, "rekor-server", "r", "", "Rekor server to store attestations")
+	cmd.Flags().StringSliceVarP(&ro.RekorServers, "rekor-server", "r", []string{}, "Rekor server to store attestations. May be repeated to store them in several logs, such as a public and a private Rekor")
+	cmd.Flags().StringVar(&ro.RekorEntryType, "rekor-entry-type", "dsse", "Kind of Rekor entry to store attestations as. One of dsse or intoto")
+	cmd.Flags().StringVar(&ro.RekorBundlePath, "rekor-bundle", "", "Path to write the Rekor entry's bundle to, so witness verify can check the entry offline. If the upload fails, the pending upload is written there instead, to be resumed with witness sync. Defaults to <outfile>.rekor.json when --outfile is set. With several Rekor servers, each server's host is added before the extension, such as <outfile>.rekor.rekor.sigstore.dev.json")
+	cmd.Flags().IntVar(&ro.RekorRetries, "rekor-retries", 3, "How many times to retry creating the Rekor entry when Rekor can't be reached or is overloaded, waiting twice as long after each failure")
+	cmd.Flags().StringVar(&ro.PublishReportPath, "publish-report", "", "Path to write a json report of where the signed collection was published to, with the status of each Rekor server, OCI registry, and Archivista server")
 	cmd.Flags().BoolVar(&ro.Tracing, "trace", false, "Enable tracing for the command")
+	cmd.Flags().BoolVar(&ro.TraceSyscalls, "trace-syscalls", false, "Trace the processes the command starts, the files they read and write, and the network connections they make, recording them in a syscall-trace attestation. Linux only")
+	cmd.Flags().BoolVar(&ro.Ephemeral, "ephemeral-key", false, "Sign with a key generated for this run and certified by --key. --certificate must be a CA certificate for --key")
+	cmd.Flags().DurationVar(&ro.EphemeralTTL, "ephemeral-key-ttl", time.Hour, "How long the ephemeral key's certificate is valid for, capped at the expiry of --certificate. Use --timestamp-server or Rekor so attestations verify after it expires")
+	cmd.Flags().StringSliceVar(&ro.Obfuscate, "obfuscate", []string{}, "Obfuscation profiles to apply to recorded host, user, and path data. One of none, hash-hostnames, hash-usernames, or strip-paths")
+	cmd.Flags().StringSliceVar(&ro.Labels, "label", []string{}, "Label to sign with the collection and index it by, in key=value form. May be repeated")
+	cmd.Flags().StringSliceVar(&ro.Matrix, "matrix", []string{}, "Coordinate of the CI matrix job running the step, in dimension=value form such as os=linux. May be repeated")
+	cmd.Flags().StringVar(&ro.DirtyWorktree, "dirty-worktree", "allow", "What to do when the git worktree has uncommitted changes or untracked files. One of allow, record (adds a worktree attestation listing the changed files), or fail")
+	cmd.Flags().BoolVar(&ro.FailOnSecrets, "fail-on-secrets", false, "Scan the working directory, products, and command output for secrets with the secret-scan attestor, and fail without signing the collection if any are found")
+	cmd.Flags().StringVar(&ro.OutputFormat, "output-format", "dsse", "Format to write the signed collection in. One of dsse or tekton-chains")
+	cmd.Flags().StringVar(&ro.TektonChainsKey, "tekton-chains-key", "", "Key of the tekton chains annotations to store the collection in, such as taskrun-<uid>. Required with --output-format tekton-chains")
+	cmd.Flags().StringVar(&ro.KeyAttestationPath, "key-attestation", "", "Path to the key attestation certificate the HSM or key management service holding the signing key issued for it, followed by its chain. Embedded in the signed collection")
+	cmd.Flags().StringSliceVar(&ro.TimestampServers, "timestamp-server", []string{}, "URL of an RFC 3161 timestamp authority to timestamp the collection's signature with, so it verifies after the signing certificate expires. May be repeated")
+	cmd.Flags().StringVar(&ro.AttestationStorage, "attestation-storage", "", "OCI repository to attach the signed collection to its image in, such as oci://ghcr.io/org/repo@sha256:<digest>. Without a digest, the collection is attached to each of its subjects that is an image in the repository. Uses the registry's credentials from the docker config and its credential helpers, the cloud provider, or .netrc")
+	ro.Archivist.AddFlags(cmd)
+	cmd.Flags().DurationVar(&ro.Heartbeat.Interval, "heartbeat-interval", 0, "Write a signed heartbeat attestation of the step's materials and processes each time this long passes while the command runs. 0 disables heartbeats")
+	cmd.Flags().StringVar(&ro.Heartbeat.Directory, "heartbeat-dir", ".", "Directory to write heartbeat attestations to")
+	cmd.Flags().DurationVar(&ro.Budget.AttestorTimeout, "attestor-timeout", 0, "Leave an attestor out of the collection if it runs for longer than this. The command is never limited. 0 disables the limit")
+	cmd.Flags().DurationVar(&ro.Budget.Deadline, "attestation-deadline", 0, "Sign the collection with the attestors that finished once the attestors have run for this long in total, not counting the command. 0 disables the deadline")
+	cmd.Flags().StringSliceVar(&ro.Artifacts.MaterialInclude, "attestor-material-include", []string{}, "Only record materials matching this pattern, relative to the working directory. ** matches any number of directories, and a directory matches every file in it. May be repeated")
+	cmd.Flags().StringSliceVar(&ro.Artifacts.MaterialExclude, "attestor-material-exclude", []string{}, "Don't record materials matching this pattern, such as node_modules or **/*.log. Excluded directories are not walked. May be repeated")
+	cmd.Flags().StringSliceVar(&ro.Artifacts.ProductInclude, "attestor-product-include", []string{}, "Only record products matching this pattern, relative to the working directory, such as dist/**. May be repeated")
+	cmd.Flags().StringSliceVar(&ro.Artifacts.ProductExclude, "attestor-product-exclude", []string{}, "Don't record products matching this pattern. Excluded directories are not walked. May be repeated")
+	cmd.Flags().IntVar(&ro.Artifacts.HashWorkers, "hash-workers", 0, "Number of materials and products to hash at a time. 0 uses the number of CPUs")
+	cmd.Flags().IntVar(&ro.Slim.MaxAttestationSize, "max-attestation-size", 0, "Drop attestations larger than this many bytes after summarization. 0 disables the limit")
+	cmd.Flags().IntVar(&ro.Slim.MaxProcesses, "max-processes", 0, "Only record the traced processes that opened the most files. 0 disables the limit")
+	cmd.Flags().BoolVar(&ro.Slim.DigestOnlyFiles, "digest-only-files", false, "Only record the sha256 digest of materials, products, and opened files")
 }
@@ -0,0 +1,42 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package options
+
+import (
+	"github.com/spf13/cobra"
+)
+
+type SearchOptions struct {
+	Subject         string
+	PredicateType   string
+	Backends        []string
+	RekorServer     string
+	ArchivistServer string
+	// ArchivistPublicKeyPath pins the key of the Archivista server's TLS certificate
+	ArchivistPublicKeyPath string
+	Directory              string
+	OutFilePath            string
+}
+
+func (so *SearchOptions) AddFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&so.Subject, "subject", "", "Subject name, such as file:main.go, or digest, such as sha256:<hex>, to search for")
+	cmd.Flags().StringVar(&so.PredicateType, "type", "", "Only return statements of this predicate type")
+	cmd.Flags().StringSliceVar(&so.Backends, "backend", []string{}, "Backends to search: rekor, archivist, and local. Defaults to local and the backends with a server set")
+	cmd.Flags().StringVarP(&so.RekorServer, "rekor-server", "r", "", "Rekor server to search")
+	cmd.Flags().StringVar(&so.ArchivistServer, "archivist-server", "", "Archivista server to search")
+	cmd.Flags().StringVar(&so.ArchivistPublicKeyPath, "archivist-public-key", "", "Path to the PEM encoded public key or certificate of the Archivista server's TLS certificate. If set, only a server holding the key is searched, and its results that can't be downloaded fail the search")
+	cmd.Flags().StringVarP(&so.Directory, "dir", "d", ".", "Directory of attestations for the local backend to search, such as one kept by witness store")
+	cmd.Flags().StringVarP(&so.OutFilePath, "outfile", "o", "", "File to write the results to. Defaults to stdout")
+}
@@ -0,0 +1,87 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package options
+
+import (
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+type RegistryHookOptions struct {
+	ListenAddress      string
+	KeyPath            string
+	PolicyFilePath     string
+	PolicyArchivist    string
+	MetricsAddress     string
+	RekorServer        string
+	RekorPublicKeyPath string
+	NotifyURL          string
+	WebhookSecretPath  string
+	ReloadInterval     time.Duration
+	Harbor             HarborOptions
+	Groups             GroupOptions
+	Audit              AuditOptions
+	Profiling          ProfilingOptions
+}
+
+// HarborOptions labels artifacts pushed to Harbor with the result of their verification.
+type HarborOptions struct {
+	URL           string
+	VerifiedLabel int64
+	FailedLabel   int64
+}
+
+type AuditOptions struct {
+	LogPath        string
+	AnchorInterval time.Duration
+	AnchorKeyPath  string
+}
+
+func (ro *RegistryHookOptions) AddFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&ro.ListenAddress, "listen", ":8080", "Address to listen for registry webhooks on")
+	cmd.Flags().StringVar(&ro.MetricsAddress, "metrics-address", "", "Address to serve metrics about the active policy on at /metrics, such as localhost:9090. Metrics are not served on the webhook listener")
+	cmd.Flags().StringVarP(&ro.KeyPath, "publickey", "k", "", "Path to the policy signer's public key, an OpenSSH allowed_signers file holding it, or a KMS key reference such as gcpkms://projects/...")
+	cmd.Flags().StringVarP(&ro.PolicyFilePath, "policy", "p", "", "Path to the policy to verify pushed images against, or an oci://<registry>/<repository>:<tag> reference to an image whose envelope layer holds it")
+	cmd.Flags().StringVar(&ro.PolicyArchivist, "policy-archivist-server", "", "Archivista server to load the newest policy signed by the policy signer from, instead of --policy")
+	cmd.Flags().StringVarP(&ro.RekorServer, "rekor-server", "r", "", "Rekor server from which to fetch attestations")
+	cmd.Flags().StringVar(&ro.RekorPublicKeyPath, "rekor-public-key", "", "Path to the Rekor server's public key. If set, only Rekor entries signed by it and included in a tree head signed by it are used as evidence")
+	cmd.Flags().StringVar(&ro.NotifyURL, "notify-url", "", "URL to POST verification results to. unix:///path/to/socket and unix:@name post to a unix or abstract socket. A comma separated list of addresses fails over between them")
+	cmd.Flags().StringVar(&ro.WebhookSecretPath, "webhook-secret-file", "", "Path to a file holding the secret webhooks must present, as the Authorization header set in Harbor or as the token query parameter of the Docker Hub webhook url. Required")
+	cmd.Flags().StringVar(&ro.Harbor.URL, "harbor-url", "", "Base URL of the Harbor instance to label pushed artifacts in. Uses HARBOR_USERNAME and HARBOR_PASSWORD")
+	cmd.Flags().Int64Var(&ro.Harbor.VerifiedLabel, "harbor-verified-label", 0, "ID of the Harbor label to add to artifacts that pass verification")
+	cmd.Flags().Int64Var(&ro.Harbor.FailedLabel, "harbor-failed-label", 0, "ID of the Harbor label to add to artifacts that fail verification")
+	cmd.Flags().DurationVar(&ro.ReloadInterval, "policy-reload-interval", 30*time.Second, "How often to check the policy source for a newer signed policy. 0 disables reloading")
+	cmd.Flags().StringVar(&ro.Audit.LogPath, "audit-log", "", "Path to an append-only, hash-chained log to record every verification decision in")
+	cmd.Flags().DurationVar(&ro.Audit.AnchorInterval, "audit-anchor-interval", 0, "How often to publish the audit log's head to the Rekor server. 0 disables anchoring")
+	cmd.Flags().StringVar(&ro.Audit.AnchorKeyPath, "audit-anchor-key", "", "Path to the key used to sign audit log anchors published to Rekor")
+	ro.Groups.AddFlags(cmd)
+	ro.Profiling.AddFlags(cmd)
+}
+
+// ProfilingOptions serves the Go runtime's pprof profiles from long running commands.
+type ProfilingOptions struct {
+	Address              string
+	MemProfileRate       int
+	BlockProfileRate     int
+	MutexProfileFraction int
+}
+
+func (po *ProfilingOptions) AddFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&po.Address, "pprof-address", "", "Address to serve Go pprof profiles on at /debug/pprof/, such as localhost:6060. Profiles reveal the process's memory, so only listen on a private address")
+	cmd.Flags().IntVar(&po.MemProfileRate, "pprof-mem-rate", 512*1024, "Average number of bytes allocated between samples of the heap and allocs profiles. Lower rates record allocations more precisely but slow the service")
+	cmd.Flags().IntVar(&po.BlockProfileRate, "pprof-block-rate", 0, "Nanoseconds spent blocked between samples of the block profile. 0 disables the block profile")
+	cmd.Flags().IntVar(&po.MutexProfileFraction, "pprof-mutex-fraction", 0, "Sample 1 in this many contended mutexes in the mutex profile. 0 disables the mutex profile")
+}
@@ -17,15 +17,27 @@ package options
 import "github.com/spf13/cobra"
 
 type SignOptions struct {
-	KeyOptions  KeyOptions
-	DataType    string
-	OutFilePath string
-	InFilePath  string
+	KeyOptions       KeyOptions
+	PayloadType      string
+	OutFilePath      string
+	InFilePath       string
+	TimestampServers []string
+	RekorServer      string
+	RekorEntryType   string
+	RekorBundlePath  string
+	RekorRetries     int
 }
 
 func (so *SignOptions) AddFlags(cmd *cobra.Command) {
 	so.KeyOptions.AddFlags(cmd)
-	cmd.Flags().StringVarP(&so.DataType, "datatype", "t", "https://witness.testifysec.com/policy/v0.1", "The URI reference to the type of data being signed. Defaults to the Witness policy type")
+	cmd.Flags().StringVarP(&so.PayloadType, "payload-type", "t", "https://witness.testifysec.com/policy/v0.1", "DSSE payload type of the data being signed, such as application/vnd.in-toto+json or application/spdx+json. Defaults to the Witness policy type")
+	cmd.Flags().StringVar(&so.PayloadType, "datatype", "https://witness.testifysec.com/policy/v0.1", "The URI reference to the type of data being signed")
+	_ = cmd.Flags().MarkDeprecated("datatype", "use --payload-type instead")
 	cmd.Flags().StringVarP(&so.OutFilePath, "outfile", "o", "", "File to write signed data. Defaults to stdout")
-	cmd.Flags().StringVarP(&so.InFilePath, "infile", "f", "", "Witness policy file to sign")
+	cmd.Flags().StringVarP(&so.InFilePath, "infile", "f", "", "File to sign, such as a witness policy, SBOM, or in-toto statement")
+	cmd.Flags().StringSliceVar(&so.TimestampServers, "timestamp-server", []string{}, "URL of an RFC 3161 timestamp authority to timestamp the signature with, so it verifies after the signing certificate expires. May be repeated")
+	cmd.Flags().StringVarP(&so.RekorServer, "rekor-server", "r", "", "Rekor server to store the signed file in")
+	cmd.Flags().StringVar(&so.RekorEntryType, "rekor-entry-type", "dsse", "Kind of Rekor entry to store the signed file as. One of dsse or intoto")
+	cmd.Flags().StringVar(&so.RekorBundlePath, "rekor-bundle", "", "Path to write the Rekor entry's bundle to, so witness verify can check the entry offline. If the upload fails, the pending upload is written there instead, to be resumed with witness sync. Defaults to <outfile>.rekor.json when --outfile is set")
+	cmd.Flags().IntVar(&so.RekorRetries, "rekor-retries", 3, "How many times to retry creating the Rekor entry when Rekor can't be reached or is overloaded, waiting twice as long after each failure")
 }
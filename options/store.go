@@ -0,0 +1,33 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package options
+
+import (
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+type StoreGCOptions struct {
+	Directory string
+	OlderThan time.Duration
+	DryRun    bool
+}
+
+func (so *StoreGCOptions) AddFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&so.Directory, "dir", "d", ".", "Directory of attestations to garbage collect")
+	cmd.Flags().DurationVar(&so.OlderThan, "older-than", 0, "Replace attestations last modified longer ago than this with tombstones")
+	cmd.Flags().BoolVar(&so.DryRun, "dry-run", false, "Print the tombstones that would be written without removing any attestations")
+}
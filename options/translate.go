@@ -0,0 +1,29 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package options
+
+import "github.com/spf13/cobra"
+
+type TranslateOptions struct {
+	Format      string
+	KeyPath     string
+	OutFilePath string
+}
+
+func (to *TranslateOptions) AddFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&to.Format, "format", "scai", "Format to translate the attestation collection to")
+	cmd.Flags().StringVarP(&to.KeyPath, "publickey", "k", "", "Path to the public key the attestation is signed with. If set, the attestation's signature is checked before translating it")
+	cmd.Flags().StringVarP(&to.OutFilePath, "outfile", "o", "", "File to write the translated statement to. Defaults to stdout")
+}
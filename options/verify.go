@@ -14,23 +14,128 @@
 
 package options
 
-import "github.com/spf13/cobra"
+import (
+	"time"
+
+	"github.com/spf13/cobra"
+)
 
 type VerifyOptions struct {
 	KeyPath              string
 	AttestationFilePaths []string
+	AttestationMaxSize   int64
+	AttestationRetries   int
 	PolicyFilePath       string
 	ArtifactFilePath     string
+	ExpandArchive        bool
 	RekorServer          string
+	RekorPublicKeyPath   string
+	RekorBundlePaths     []string
 	CAPaths              []string
 	EmailContstraints    []string
+	ReceiptPath          string
+	ReceiptKeyPath       string
+	UseReceipt           bool
+	ReceiptMaxAge        time.Duration
+	GitHubRepository     string
+	AttestationStorage   string
+	ArchivistServer      string
+	// ArchivistPublicKeyPath pins the key of the Archivista server's TLS certificate
+	ArchivistPublicKeyPath string
+	EvidenceOutPath        string
+	BuildCounterState      string
+	Requirements           []string
+	ProfileURI             string
+	ProfileKeyPath         string
+	EnvelopePath           string
+	PayloadType            string
+	PayloadOutPath         string
+	Output                 string
+	VSAOutPath             string
+	VSAKeyPath             string
+	VSAVerifierID          string
+	// PolicyPublicKey is the PEM encoded policy signer's key, set by a verification profile
+	PolicyPublicKey []byte
+	// RekorPublicKey is the PEM encoded Rekor server's key, set by a trust root holding a single Rekor key
+	RekorPublicKey []byte
+	Groups         GroupOptions
+	Watch          WatchOptions
+	Remote         RemoteVerifyOptions
+	TrustRoot      TrustRootOptions
+}
+
+type WatchOptions struct {
+	Enabled        bool
+	Interval       time.Duration
+	ArtifactPaths  []string
+	AlertURL       string
+	MetricsAddress string
+	Profiling      ProfilingOptions
+}
+
+// RemoteVerifyOptions delegates verification to a remote verification service.
+type RemoteVerifyOptions struct {
+	URL            string
+	KeyPaths       []string
+	MaxAge         time.Duration
+	Timeout        time.Duration
+	ArtifactDigest string
+}
+
+// TrustRootOptions loads policy keys, CA roots, timestamp authorities, and Rekor keys from a TUF repository.
+type TrustRootOptions struct {
+	URL             string
+	InitialRootPath string
+	CacheDir        string
+	Refresh         time.Duration
 }
 
 func (vo *VerifyOptions) AddFlags(cmd *cobra.Command) {
-	cmd.Flags().StringVarP(&vo.KeyPath, "publickey", "k", "", "Path to the policy signer's public key")
-	cmd.Flags().StringSliceVarP(&vo.AttestationFilePaths, "attestations", "a", []string{}, "Attestation files to test against the policy")
-	cmd.Flags().StringVarP(&vo.PolicyFilePath, "policy", "p", "", "Path to the policy to verify")
-	cmd.Flags().StringVarP(&vo.ArtifactFilePath, "artifactfile", "f", "", "Path to the artifact to verify")
+	cmd.Flags().StringVarP(&vo.KeyPath, "publickey", "k", "", "Path to the policy signer's public key, an OpenSSH allowed_signers file holding it, or a KMS key reference such as gcpkms://projects/...")
+	cmd.Flags().StringSliceVarP(&vo.AttestationFilePaths, "attestations", "a", []string{}, "Attestation files to test against the policy. http(s) URLs are downloaded, and may be pinned with a #sha256=<hex> suffix")
+	cmd.Flags().Int64Var(&vo.AttestationMaxSize, "attestation-max-size", 32<<20, "Largest attestation file, in bytes, to download from a URL")
+	cmd.Flags().IntVar(&vo.AttestationRetries, "attestation-retries", 3, "How many times to retry a failed attestation download")
+	cmd.Flags().StringVarP(&vo.PolicyFilePath, "policy", "p", "", "Path or http(s) URL of the policy to verify. URLs may be pinned with a #sha256=<hex> suffix")
+	cmd.Flags().StringVarP(&vo.ArtifactFilePath, "artifactfile", "f", "", "Path to the artifact to verify, or a git tag, branch, or commit in the form git+<repository url>@<ref>")
+	cmd.Flags().BoolVar(&vo.ExpandArchive, "expand-archive", false, "Also verify the files contained in the zip or tar artifact against attestation subjects")
 	cmd.Flags().StringVarP(&vo.RekorServer, "rekor-server", "r", "", "Rekor server from which to fetch attestations")
+	cmd.Flags().StringVar(&vo.RekorPublicKeyPath, "rekor-public-key", "", "Path to the Rekor server's public key. If set, only Rekor entries signed by it and included in a tree head signed by it are used as evidence. Without --rekor-server, every attestation file must have a Rekor bundle signed by it. Defaults to the trust root's Rekor key if it has exactly one")
+	cmd.Flags().StringSliceVar(&vo.RekorBundlePaths, "rekor-bundle", []string{}, "Path to a Rekor bundle written by witness run, checked offline against --rekor-public-key and the transparency logs of the policy's log inclusion constraints. Bundles next to attestation files, named <attestation file>.rekor.json or <attestation file>.rekor.<host>.json, are found without this flag")
 	cmd.Flags().StringSliceVarP(&vo.CAPaths, "policy-ca", "", []string{}, "Paths to CA certificates to use for verifying the policy")
+	cmd.Flags().StringVar(&vo.ReceiptPath, "receipt", "", "Path to a signed verification receipt. Written after verification succeeds")
+	cmd.Flags().StringVar(&vo.ReceiptKeyPath, "receipt-key", "", "Path to the key used to sign and verify verification receipts")
+	cmd.Flags().BoolVar(&vo.UseReceipt, "use-receipt", false, "Skip verification if the receipt matches the policy, artifact, and attestations being verified")
+	cmd.Flags().DurationVar(&vo.ReceiptMaxAge, "receipt-max-age", 24*time.Hour, "Maximum age of a receipt used with --use-receipt. 0 disables the check")
+	cmd.Flags().StringVar(&vo.GitHubRepository, "github-repo", "", "GitHub repository (owner/repo) to fetch artifact attestations for the artifact from. Uses GITHUB_TOKEN if set")
+	cmd.Flags().StringVar(&vo.AttestationStorage, "attestation-storage", "", "OCI repository to fetch the attestations attached to an image from, such as oci://ghcr.io/org/repo@sha256:<digest>. Without a digest, the attestations attached to the artifact file's digest are fetched. Uses the registry's credentials from the docker config and its credential helpers, the cloud provider, or .netrc")
+	cmd.Flags().StringVar(&vo.ArchivistServer, "archivist-server", "", "Archivista server to fetch the attestations of the artifact file's digest from")
+	cmd.Flags().StringVar(&vo.ArchivistPublicKeyPath, "archivist-public-key", "", "Path to the PEM encoded public key or certificate of the Archivista server's TLS certificate. If set, only a server holding the key is used, attestations it lists that can't be downloaded fail verification, and the result records the pinned key")
+	cmd.Flags().StringVar(&vo.EvidenceOutPath, "evidence-out", "", "Path to write a gzipped tar of the policy, verified attestations, trust material, and result to after verification succeeds")
+	cmd.Flags().StringVar(&vo.BuildCounterState, "build-counter-state", "", "Path to a file recording the highest build counter accepted for each step. Verification fails if an older build is presented")
+	cmd.Flags().StringSliceVar(&vo.Requirements, "require", []string{}, "Require an attestation from an attestor, or one with a field set to a value, in the form <attestor> or <attestor>.<path>=<value>. Without a policy, --publickey is the attestations' signing key")
+	cmd.Flags().StringVar(&vo.ProfileURI, "profile-uri", "", "Path or http(s) URL of a signed verification profile setting the policy, policy key, Rekor server, and other flags. URLs may be pinned with a #sha256=<hex> suffix")
+	cmd.Flags().StringVar(&vo.ProfileKeyPath, "profile-key", "", "Path to the public key verification profiles must be signed by")
+	cmd.Flags().StringVar(&vo.EnvelopePath, "envelope", "", "Path to a signed envelope of any payload type, such as an SBOM signed with witness sign, to verify with --publickey instead of a policy")
+	cmd.Flags().StringVar(&vo.PayloadType, "payload-type", "", "Payload type the --envelope must declare")
+	cmd.Flags().StringVar(&vo.PayloadOutPath, "payload-out", "", "Path to write the verified payload of --envelope to")
+	cmd.Flags().StringVar(&vo.Output, "output", "text", "Output format of the verification result, text or json. json reports each policy step's collections, signers, and failure reasons on stdout")
+	cmd.Flags().StringVar(&vo.VSAOutPath, "vsa-out", "", "Path to write a signed SLSA verification summary attestation of the verification result about --artifactfile to, whether verification passed or failed")
+	cmd.Flags().StringVar(&vo.VSAKeyPath, "vsa-key", "", "Path to the key used to sign the verification summary attestation")
+	cmd.Flags().StringVar(&vo.VSAVerifierID, "vsa-verifier-id", "https://witness.dev/verify", "URI identifying the verifier in the verification summary attestation")
+	cmd.Flags().BoolVar(&vo.Watch.Enabled, "watch", false, "Keep running and re-verify the artifacts every --interval, alerting when a passing artifact starts failing")
+	cmd.Flags().DurationVar(&vo.Watch.Interval, "interval", time.Hour, "How often to re-verify artifacts with --watch")
+	cmd.Flags().StringSliceVar(&vo.Watch.ArtifactPaths, "watch-artifacts", []string{}, "Additional artifacts to re-verify with --watch")
+	cmd.Flags().StringVar(&vo.Watch.AlertURL, "alert-url", "", "URL, or unix socket address, to POST an alert to when an artifact starts failing verification with --watch. A comma separated list of addresses fails over between them")
+	cmd.Flags().StringVar(&vo.Watch.MetricsAddress, "metrics-address", "", "Address to serve verification metrics on at /metrics with --watch")
+	cmd.Flags().StringVar(&vo.Remote.URL, "remote", "", "URL of a remote verification service to verify the artifact with instead of verifying locally. The artifact's digest and the --policy reference are sent to the service")
+	cmd.Flags().StringSliceVar(&vo.Remote.KeyPaths, "remote-key", []string{}, "Path to a public key the remote verification service signs its results with. Results signed by other keys are rejected")
+	cmd.Flags().DurationVar(&vo.Remote.MaxAge, "remote-max-age", 5*time.Minute, "Oldest verification time accepted in a result from the remote verification service. 0 disables the check")
+	cmd.Flags().DurationVar(&vo.Remote.Timeout, "remote-timeout", 30*time.Second, "How long to wait for the remote verification service")
+	cmd.Flags().StringVar(&vo.Remote.ArtifactDigest, "artifact-digest", "", "Digest of the artifact to verify with --remote, in the form sha256:<hex>, instead of hashing --artifactfile")
+	cmd.Flags().StringVar(&vo.TrustRoot.URL, "trust-root", "", "TUF repository, as tuf://<host>/<path>, to load policy signing keys, CA roots, timestamp authorities, and Rekor keys from. The repository's CA roots, timestamp authorities, and Rekor keys replace the policy's entries of the same names")
+	cmd.Flags().StringVar(&vo.TrustRoot.InitialRootPath, "trust-root-initial", "", "Path to the TUF repository's root.json to trust the first time the repository is used. Later root versions are verified against the cached root")
+	cmd.Flags().StringVar(&vo.TrustRoot.CacheDir, "trust-root-cache", "", "Directory to cache the TUF repository's metadata and targets in. Defaults to witness/tuf in the user cache directory")
+	cmd.Flags().DurationVar(&vo.TrustRoot.Refresh, "trust-root-refresh", time.Hour, "How long cached TUF metadata is used before the repository is checked for new metadata. 0 checks every time")
+	vo.Groups.AddFlags(cmd)
+	vo.Watch.Profiling.AddFlags(cmd)
 }
@@ -0,0 +1,214 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package allowedsigners reads the OpenSSH allowed_signers files used to verify git SSH signatures, so the keys they
+// trust can verify witness policies and attestations.
+package allowedsigners
+
+import (
+	"bufio"
+	"bytes"
+	"crypto"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/testifysec/go-witness/cryptoutil"
+	"golang.org/x/crypto/ssh"
+)
+
+// Namespace is the signature namespace that entries restricted with the namespaces option must list to be trusted by
+// witness, in the same way git requires the git namespace.
+const Namespace = "witness"
+
+// Signer is an entry of an allowed_signers file.
+type Signer struct {
+	Principals    []string
+	Namespaces    []string
+	ValidAfter    time.Time
+	ValidBefore   time.Time
+	CertAuthority bool
+	KeyType       string
+	// PublicKey is nil for key types witness can't verify signatures with, such as FIDO security key types.
+	PublicKey crypto.PublicKey
+}
+
+// Parse reads the entries of an allowed_signers file, as described in ssh-keygen(1). Each line holds a comma separated
+// list of principals, optional options, a key type, and a base64 encoded key.
+func Parse(r io.Reader) ([]Signer, error) {
+	signers := make([]Signer, 0)
+	scanner := bufio.NewScanner(r)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		signer, err := parseLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse allowed signers line %v: %w", lineNumber, err)
+		}
+
+		signers = append(signers, signer)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read allowed signers: %w", err)
+	}
+
+	return signers, nil
+}
+
+// IsAllowedSigners reports whether data holds at least one allowed_signers entry rather than a PEM encoded key.
+func IsAllowedSigners(data []byte) bool {
+	if bytes.Contains(data, []byte("-----BEGIN")) {
+		return false
+	}
+
+	signers, err := Parse(bytes.NewReader(data))
+	return err == nil && len(signers) > 0
+}
+
+func parseLine(line string) (Signer, error) {
+	principals, rest, err := splitPrincipals(line)
+	if err != nil {
+		return Signer{}, err
+	}
+
+	key, _, options, _, err := ssh.ParseAuthorizedKey([]byte(rest))
+	if err != nil {
+		return Signer{}, fmt.Errorf("failed to parse key: %w", err)
+	}
+
+	signer := Signer{
+		Principals: strings.Split(principals, ","),
+		KeyType:    key.Type(),
+	}
+
+	if cryptoKey, ok := key.(ssh.CryptoPublicKey); ok {
+		signer.PublicKey = cryptoKey.CryptoPublicKey()
+	}
+
+	for _, option := range options {
+		parts := strings.SplitN(option, "=", 2)
+		value := ""
+		if len(parts) == 2 {
+			value = strings.Trim(parts[1], `"`)
+		}
+
+		switch strings.ToLower(parts[0]) {
+		case "cert-authority":
+			signer.CertAuthority = true
+		case "namespaces":
+			signer.Namespaces = strings.Split(value, ",")
+		case "valid-after":
+			if signer.ValidAfter, err = parseTime(value); err != nil {
+				return Signer{}, fmt.Errorf("invalid valid-after: %w", err)
+			}
+		case "valid-before":
+			if signer.ValidBefore, err = parseTime(value); err != nil {
+				return Signer{}, fmt.Errorf("invalid valid-before: %w", err)
+			}
+		}
+	}
+
+	return signer, nil
+}
+
+// splitPrincipals splits the principals field, which may be quoted, from the rest of the line.
+func splitPrincipals(line string) (string, string, error) {
+	if strings.HasPrefix(line, `"`) {
+		end := strings.Index(line[1:], `"`)
+		if end < 0 {
+			return "", "", fmt.Errorf("unterminated quoted principals")
+		}
+
+		return line[1 : end+1], strings.TrimSpace(line[end+2:]), nil
+	}
+
+	end := strings.IndexAny(line, " \t")
+	if end < 0 {
+		return "", "", fmt.Errorf("missing key")
+	}
+
+	return line[:end], strings.TrimSpace(line[end:]), nil
+}
+
+// parseTime parses the YYYYMMDD[HHMM[SS]] times of the valid-after and valid-before options. Times are local unless
+// suffixed with Z.
+func parseTime(value string) (time.Time, error) {
+	location := time.Local
+	if strings.HasSuffix(value, "Z") {
+		location = time.UTC
+		value = strings.TrimSuffix(value, "Z")
+	}
+
+	layouts := map[int]string{8: "20060102", 12: "200601021504", 14: "20060102150405"}
+	layout, ok := layouts[len(value)]
+	if !ok {
+		return time.Time{}, fmt.Errorf("time %v is not in the form YYYYMMDD[HHMM[SS]]", value)
+	}
+
+	return time.ParseInLocation(layout, value, location)
+}
+
+// Allows reports whether the entry trusts its key to sign for witness at the time. Entries for certificate
+// authorities are never allowed since witness can't verify SSH certificates.
+func (s Signer) Allows(at time.Time) bool {
+	if s.PublicKey == nil || s.CertAuthority {
+		return false
+	}
+
+	if !s.ValidAfter.IsZero() && at.Before(s.ValidAfter) {
+		return false
+	}
+
+	if !s.ValidBefore.IsZero() && !at.Before(s.ValidBefore) {
+		return false
+	}
+
+	if len(s.Namespaces) == 0 {
+		return true
+	}
+
+	for _, namespace := range s.Namespaces {
+		if namespace == Namespace {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Verifiers returns verifiers for the keys of the entries that are allowed to sign for witness at the time.
+func Verifiers(signers []Signer, at time.Time) ([]cryptoutil.Verifier, error) {
+	verifiers := make([]cryptoutil.Verifier, 0, len(signers))
+	for _, signer := range signers {
+		if !signer.Allows(at) {
+			continue
+		}
+
+		verifier, err := cryptoutil.NewVerifier(signer.PublicKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create verifier for %v: %w", strings.Join(signer.Principals, ","), err)
+		}
+
+		verifiers = append(verifiers, verifier)
+	}
+
+	return verifiers, nil
+}
@@ -0,0 +1,130 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package allowedsigners
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+func authorizedKey(t *testing.T, pub interface{}) string {
+	key, err := ssh.NewPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return strings.TrimSpace(string(ssh.MarshalAuthorizedKey(key)))
+}
+
+func TestParse(t *testing.T) {
+	edPub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file := strings.Join([]string{
+		"# release engineers",
+		"alice@example.com,alice@corp.example.com " + authorizedKey(t, edPub) + " alice laptop",
+		"",
+		`"bob@example.com" namespaces="git,witness",valid-after="20200101Z",valid-before="20300101Z" ` + authorizedKey(t, &ecKey.PublicKey),
+		`*@example.com cert-authority ` + authorizedKey(t, edPub),
+	}, "\n")
+
+	signers, err := Parse(strings.NewReader(file))
+	if err != nil {
+		t.Fatalf("failed to parse allowed signers: %v", err)
+	}
+
+	if len(signers) != 3 {
+		t.Fatalf("expected 3 signers, got %v", len(signers))
+	}
+
+	if strings.Join(signers[0].Principals, ",") != "alice@example.com,alice@corp.example.com" {
+		t.Errorf("unexpected principals %v", signers[0].Principals)
+	}
+
+	if _, ok := signers[0].PublicKey.(ed25519.PublicKey); !ok {
+		t.Errorf("expected an ed25519 key, got %T", signers[0].PublicKey)
+	}
+
+	bob := signers[1]
+	if _, ok := bob.PublicKey.(*ecdsa.PublicKey); !ok {
+		t.Errorf("expected an ecdsa key, got %T", bob.PublicKey)
+	}
+
+	if len(bob.Namespaces) != 2 || !bob.ValidAfter.Equal(time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected options %v %v", bob.Namespaces, bob.ValidAfter)
+	}
+
+	if !signers[2].CertAuthority {
+		t.Error("expected the cert-authority option to be read")
+	}
+
+	verifiers, err := Verifiers(signers, time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("failed to create verifiers: %v", err)
+	}
+
+	if len(verifiers) != 2 {
+		t.Errorf("expected the certificate authority to be skipped, got %v verifiers", len(verifiers))
+	}
+
+	if !IsAllowedSigners([]byte(file)) {
+		t.Error("expected the file to be recognized as allowed signers")
+	}
+
+	if _, err := Parse(strings.NewReader("alice@example.com ssh-ed25519 not-base64")); err == nil {
+		t.Error("expected a malformed key to fail")
+	}
+}
+
+func TestAllows(t *testing.T) {
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	tests := []struct {
+		name    string
+		signer  Signer
+		allowed bool
+	}{
+		{"unrestricted", Signer{PublicKey: pub}, true},
+		{"witness namespace", Signer{PublicKey: pub, Namespaces: []string{"git", Namespace}}, true},
+		{"git namespace only", Signer{PublicKey: pub, Namespaces: []string{"git"}}, false},
+		{"not yet valid", Signer{PublicKey: pub, ValidAfter: now.Add(time.Hour)}, false},
+		{"expired", Signer{PublicKey: pub, ValidBefore: now.Add(-time.Hour)}, false},
+		{"unsupported key type", Signer{KeyType: "sk-ssh-ed25519@openssh.com"}, false},
+	}
+
+	for _, test := range tests {
+		if allowed := test.signer.Allows(now); allowed != test.allowed {
+			t.Errorf("%v: expected allowed to be %v", test.name, test.allowed)
+		}
+	}
+}
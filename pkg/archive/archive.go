@@ -0,0 +1,258 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package archive calculates digests of zip and tar archives and the files they contain.
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"crypto"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/witness/pkg/digest"
+)
+
+var (
+	gzipMagic = []byte{0x1f, 0x8b}
+	zipMagic  = []byte("PK\x03\x04")
+)
+
+// Entry is a file, directory, or link within an archive.
+type Entry struct {
+	Name     string
+	Mode     fs.FileMode
+	Linkname string
+}
+
+// IsArchive returns true if the file at path is a zip, tar, or gzipped tar archive.
+func IsArchive(archivePath string) (bool, error) {
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return false, err
+	}
+
+	defer f.Close()
+	header := make([]byte, 512)
+	n, err := io.ReadFull(f, header)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return false, err
+	}
+
+	header = header[:n]
+	if bytes.HasPrefix(header, zipMagic) || bytes.HasPrefix(header, gzipMagic) {
+		return true, nil
+	}
+
+	// tar archives have the ustar magic at offset 257
+	return len(header) >= 262 && string(header[257:262]) == "ustar", nil
+}
+
+// Digests returns the digests of every regular file in the zip, tar, or gzipped tar archive at path, keyed by the
+// file's cleaned name within the archive. The archive format is detected from its contents.
+func Digests(archivePath string, hashes []crypto.Hash) (map[string]cryptoutil.DigestSet, error) {
+	digests := make(map[string]cryptoutil.DigestSet)
+	err := Walk(archivePath, func(entry Entry, r io.Reader) error {
+		if !entry.Mode.IsRegular() {
+			return nil
+		}
+
+		ds, err := digest.Calculate(r, hashes)
+		if err != nil {
+			return fmt.Errorf("failed to calculate digest of %v: %w", entry.Name, err)
+		}
+
+		digests[entry.Name] = ds
+		return nil
+	})
+
+	return digests, err
+}
+
+// NormalizedDigest returns a digest of the archive's contents that does not depend on the archive format, compression,
+// entry order, timestamps, or ownership. It is calculated over a manifest with one line per entry, sorted by name,
+// holding the entry's permissions, type, name, link target, and the digest of its contents.
+func NormalizedDigest(archivePath string, hashes []crypto.Hash) (cryptoutil.DigestSet, error) {
+	type manifestEntry struct {
+		Entry
+		digest cryptoutil.DigestSet
+	}
+
+	entries := make([]manifestEntry, 0)
+	err := Walk(archivePath, func(entry Entry, r io.Reader) error {
+		me := manifestEntry{Entry: entry}
+		if entry.Mode.IsRegular() {
+			ds, err := digest.Calculate(r, hashes)
+			if err != nil {
+				return fmt.Errorf("failed to calculate digest of %v: %w", entry.Name, err)
+			}
+
+			me.digest = ds
+		}
+
+		entries = append(entries, me)
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name < entries[j].Name
+	})
+
+	normalized := make(cryptoutil.DigestSet)
+	for _, hash := range hashes {
+		manifest := strings.Builder{}
+		for _, entry := range entries {
+			fmt.Fprintf(&manifest, "%v %v %q %q %v\n", entry.Mode.Perm(), entry.Mode.Type(), entry.Name, entry.Linkname, entry.digest[hash])
+		}
+
+		ds, err := digest.Calculate(strings.NewReader(manifest.String()), []crypto.Hash{hash})
+		if err != nil {
+			return nil, err
+		}
+
+		normalized[hash] = ds[hash]
+	}
+
+	return normalized, nil
+}
+
+// Walk calls fn for each entry in the zip, tar, or gzipped tar archive at path. The reader passed to fn is only valid
+// until fn returns and only has content for regular files.
+func Walk(archivePath string, fn func(Entry, io.Reader) error) error {
+	f, err := os.Open(archivePath)
+	if err != nil {
+		return err
+	}
+
+	defer f.Close()
+	br := bufio.NewReader(f)
+	magic, err := br.Peek(len(zipMagic))
+	if err != nil && err != io.EOF {
+		return fmt.Errorf("failed to read archive: %w", err)
+	}
+
+	switch {
+	case bytes.HasPrefix(magic, zipMagic):
+		info, err := f.Stat()
+		if err != nil {
+			return err
+		}
+
+		return walkZip(f, info.Size(), fn)
+
+	default:
+		return WalkStream(br, fn)
+	}
+}
+
+// WalkStream calls fn for each entry in the tar or gzipped tar archive read from r, such as an image layer read from
+// within another archive. The reader passed to fn is only valid until fn returns and only has content for regular
+// files.
+func WalkStream(r io.Reader, fn func(Entry, io.Reader) error) error {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(len(gzipMagic))
+	if err != nil && err != io.EOF {
+		return fmt.Errorf("failed to read archive: %w", err)
+	}
+
+	if !bytes.HasPrefix(magic, gzipMagic) {
+		return walkTar(br, fn)
+	}
+
+	gz, err := gzip.NewReader(br)
+	if err != nil {
+		return fmt.Errorf("failed to read gzip stream: %w", err)
+	}
+
+	defer gz.Close()
+	return walkTar(gz, fn)
+}
+
+func walkTar(r io.Reader, fn func(Entry, io.Reader) error) error {
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("failed to read tar archive: %w", err)
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeXGlobalHeader, tar.TypeXHeader:
+			continue
+		}
+
+		entry := Entry{
+			Name:     path.Clean(hdr.Name),
+			Mode:     hdr.FileInfo().Mode(),
+			Linkname: hdr.Linkname,
+		}
+
+		if err := fn(entry, tr); err != nil {
+			return err
+		}
+	}
+}
+
+func walkZip(r io.ReaderAt, size int64, fn func(Entry, io.Reader) error) error {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return fmt.Errorf("failed to read zip archive: %w", err)
+	}
+
+	for _, zf := range zr.File {
+		entry := Entry{
+			Name: path.Clean(zf.Name),
+			Mode: zf.Mode(),
+		}
+
+		rc, err := zf.Open()
+		if err != nil {
+			return fmt.Errorf("failed to open %v: %w", zf.Name, err)
+		}
+
+		if entry.Mode&fs.ModeSymlink != 0 {
+			target, err := io.ReadAll(rc)
+			if err != nil {
+				rc.Close()
+				return fmt.Errorf("failed to read link target of %v: %w", zf.Name, err)
+			}
+
+			entry.Linkname = string(target)
+		}
+
+		err = fn(entry, rc)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,212 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package archive
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"crypto"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+var testFiles = map[string]string{
+	"bin/app":   "binary",
+	"README.md": "readme",
+}
+
+func writeTar(t *testing.T, w io.Writer) {
+	tw := tar.NewWriter(w)
+	if err := tw.WriteHeader(&tar.Header{Name: "bin/", Typeflag: tar.TypeDir, Mode: 0755}); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, content := range testFiles {
+		if err := tw.WriteHeader(&tar.Header{Name: "./" + name, Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(content))}); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func checkDigests(t *testing.T, archivePath string) {
+	digests, err := Digests(archivePath, []crypto.Hash{crypto.SHA256})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(digests) != len(testFiles) {
+		t.Fatalf("expected %d files, got %d", len(testFiles), len(digests))
+	}
+
+	for name, content := range testFiles {
+		expected := fmt.Sprintf("%x", sha256.Sum256([]byte(content)))
+		if digests[name][crypto.SHA256] != expected {
+			t.Errorf("unexpected digest for %v: %v", name, digests[name])
+		}
+	}
+}
+
+func TestTarGz(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "release.tar.gz")
+	f, err := os.Create(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gz := gzip.NewWriter(f)
+	writeTar(t, gz)
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	f.Close()
+	checkDigests(t, p)
+}
+
+func TestTar(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "release.tar")
+	f, err := os.Create(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	writeTar(t, f)
+	f.Close()
+	checkDigests(t, p)
+}
+
+func TestZip(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "release.zip")
+	f, err := os.Create(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	zw := zip.NewWriter(f)
+	for name, content := range testFiles {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := w.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	f.Close()
+	checkDigests(t, p)
+}
+
+func writeNormalizationTar(t *testing.T, p string, names []string, modTime time.Time, uid int, compress bool) {
+	f, err := os.Create(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer f.Close()
+	var w io.Writer = f
+	if compress {
+		gz := gzip.NewWriter(f)
+		defer gz.Close()
+		w = gz
+	}
+
+	tw := tar.NewWriter(w)
+	for _, name := range names {
+		content := testFiles[name]
+		hdr := &tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(content)), ModTime: modTime, Uid: uid, Gid: uid}
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNormalizedDigest(t *testing.T) {
+	dir := t.TempDir()
+	first := filepath.Join(dir, "first.tar")
+	second := filepath.Join(dir, "second.tar.gz")
+	writeNormalizationTar(t, first, []string{"bin/app", "README.md"}, time.Unix(0, 0), 0, false)
+	writeNormalizationTar(t, second, []string{"README.md", "bin/app"}, time.Now(), 1000, true)
+
+	firstDigest, err := NormalizedDigest(first, []crypto.Hash{crypto.SHA256})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	secondDigest, err := NormalizedDigest(second, []crypto.Hash{crypto.SHA256})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !firstDigest.Equal(secondDigest) {
+		t.Errorf("expected normalized digests to match: %v != %v", firstDigest, secondDigest)
+	}
+
+	third := filepath.Join(dir, "third.tar")
+	writeNormalizationTar(t, third, []string{"bin/app"}, time.Unix(0, 0), 0, false)
+	thirdDigest, err := NormalizedDigest(third, []crypto.Hash{crypto.SHA256})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if firstDigest.Equal(thirdDigest) {
+		t.Error("expected archives with different contents to have different normalized digests")
+	}
+}
+
+func TestIsArchive(t *testing.T) {
+	dir := t.TempDir()
+	tarPath := filepath.Join(dir, "release.tar")
+	writeNormalizationTar(t, tarPath, []string{"bin/app"}, time.Unix(0, 0), 0, false)
+	textPath := filepath.Join(dir, "notes.txt")
+	if err := os.WriteFile(textPath, []byte("not an archive"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, err := IsArchive(tarPath); err != nil || !ok {
+		t.Errorf("expected tar to be detected as an archive: %v", err)
+	}
+
+	if ok, err := IsArchive(textPath); err != nil || ok {
+		t.Errorf("expected text file not to be detected as an archive: %v", err)
+	}
+}
@@ -0,0 +1,195 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package artifacts records the digests of the files in a directory, as the material and product attestors do,
+// limited to the files matching include and exclude patterns and hashing several files at a time.
+package artifacts
+
+import (
+	"crypto"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/witness/pkg/digest"
+)
+
+// Options limits which files are recorded. Include and Exclude are patterns matched against paths relative to the
+// recorded directory with path.Match, where a ** segment matches any number of directories and a pattern matching a
+// directory matches every file in it. Without include patterns every file is included. Exclude patterns take
+// precedence, and excluded directories are not walked at all.
+type Options struct {
+	Include []string
+	Exclude []string
+	// Workers is the number of files hashed at a time. Defaults to the number of CPUs.
+	Workers int
+}
+
+// Validate checks that the patterns are well formed.
+func (o Options) Validate() error {
+	for _, pattern := range append(append([]string{}, o.Include...), o.Exclude...) {
+		if _, err := path.Match(strings.ReplaceAll(pattern, "**", "*"), ""); err != nil {
+			return fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+	}
+
+	return nil
+}
+
+// Record walks basePath and returns the digest of each regular file the options select, keyed by its path relative to
+// basePath. Symbolic links to directories are followed once. Files in baseArtifacts with the same digest are left
+// out, so recording products after the command leaves out the materials it didn't change.
+func Record(basePath string, baseArtifacts map[string]cryptoutil.DigestSet, hashes []crypto.Hash, opts Options) (map[string]cryptoutil.DigestSet, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+
+	files := map[string]string{}
+	if err := walk(basePath, "", opts, files, map[string]struct{}{}); err != nil {
+		return nil, err
+	}
+
+	paths := make([]string, 0, len(files))
+	for _, file := range files {
+		paths = append(paths, file)
+	}
+
+	digests, err := digest.CalculateFiles(paths, hashes, opts.Workers)
+	if err != nil {
+		return nil, err
+	}
+
+	artifacts := make(map[string]cryptoutil.DigestSet, len(files))
+	for relPath, file := range files {
+		artifact := digests[file]
+		if previous, ok := baseArtifacts[relPath]; ok && artifact.Equal(previous) {
+			continue
+		}
+
+		artifacts[relPath] = artifact
+	}
+
+	return artifacts, nil
+}
+
+// walk adds the files under dir that the options select to files, keyed by their path relative to the recorded
+// directory, which is prefix joined with their path relative to dir.
+func walk(dir, prefix string, opts Options, files map[string]string, visited map[string]struct{}) error {
+	return filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+
+		if rel == "." {
+			return nil
+		}
+
+		relPath := filepath.Join(prefix, rel)
+		slashPath := filepath.ToSlash(relPath)
+		if matchAny(opts.Exclude, slashPath) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		switch {
+		case d.IsDir():
+			return nil
+		case d.Type()&fs.ModeSymlink != 0:
+			target, err := filepath.EvalSymlinks(p)
+			if err != nil {
+				return err
+			}
+
+			info, err := os.Stat(target)
+			if err != nil {
+				return err
+			}
+
+			if !info.IsDir() {
+				if info.Mode().IsRegular() && included(opts, slashPath) {
+					files[relPath] = target
+				}
+
+				return nil
+			}
+
+			if _, ok := visited[target]; ok {
+				return nil
+			}
+
+			visited[target] = struct{}{}
+			return walk(target, relPath, opts, files, visited)
+		case d.Type().IsRegular() && included(opts, slashPath):
+			files[relPath] = p
+		}
+
+		return nil
+	})
+}
+
+func included(opts Options, slashPath string) bool {
+	return len(opts.Include) == 0 || matchAny(opts.Include, slashPath)
+}
+
+func matchAny(patterns []string, slashPath string) bool {
+	for _, pattern := range patterns {
+		if Match(pattern, slashPath) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Match reports whether the slash separated path, or a directory containing it, matches the pattern. A ** segment of
+// the pattern matches any number of path segments, and other segments are matched with path.Match.
+func Match(pattern, slashPath string) bool {
+	return matchSegments(strings.Split(strings.Trim(pattern, "/"), "/"), strings.Split(slashPath, "/"))
+}
+
+func matchSegments(pattern, segments []string) bool {
+	if len(pattern) == 0 {
+		// every segment of the pattern matched, so the path is the matched file or is in the matched directory
+		return true
+	}
+
+	if pattern[0] == "**" {
+		for i := 0; i <= len(segments); i++ {
+			if matchSegments(pattern[1:], segments[i:]) {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	if len(segments) == 0 {
+		return false
+	}
+
+	matched, err := path.Match(pattern[0], segments[0])
+	return err == nil && matched && matchSegments(pattern[1:], segments[1:])
+}
@@ -0,0 +1,118 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package artifacts
+
+import (
+	"crypto"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/testifysec/go-witness/cryptoutil"
+)
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		matched bool
+	}{
+		{"*.go", "main.go", true},
+		{"*.go", "cmd/main.go", false},
+		{"**/*.go", "cmd/main.go", true},
+		{"**/*.go", "main.go", true},
+		{"node_modules", "node_modules/left-pad/index.js", true},
+		{"**/node_modules", "web/node_modules/left-pad/index.js", true},
+		{"dist/**", "dist/app.js", true},
+		{"dist/**", "src/dist/app.js", false},
+		{"build/*.o", "build/main.o", true},
+		{"build/*.o", "build/obj/main.o", false},
+		{"src/**/test/*.go", "src/a/b/test/x.go", true},
+	}
+
+	for _, test := range tests {
+		if matched := Match(test.pattern, test.path); matched != test.matched {
+			t.Errorf("expected %v matching %v to be %v", test.pattern, test.path, test.matched)
+		}
+	}
+}
+
+func TestRecord(t *testing.T) {
+	dir := t.TempDir()
+	for _, file := range []string{"main.go", "go.sum", "cmd/run.go", "node_modules/pad/index.js", "dist/app", "dist/app.map"} {
+		p := filepath.Join(dir, filepath.FromSlash(file))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := os.WriteFile(p, []byte(file), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := os.Symlink(filepath.Join(dir, "cmd"), filepath.Join(dir, "linked")); err != nil {
+		t.Fatal(err)
+	}
+
+	hashes := []crypto.Hash{crypto.SHA256}
+	all, err := Record(dir, nil, hashes, Options{Exclude: []string{"node_modules"}, Workers: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{"cmd/run.go", "dist/app", "dist/app.map", "go.sum", "linked/run.go", "main.go"}
+	if names := keys(all); !reflect.DeepEqual(names, expected) {
+		t.Errorf("expected %v, got %v", expected, names)
+	}
+
+	filtered, err := Record(dir, nil, hashes, Options{Include: []string{"**/*.go", "dist"}, Exclude: []string{"**/*.map", "linked"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected = []string{"cmd/run.go", "dist/app", "main.go"}
+	if names := keys(filtered); !reflect.DeepEqual(names, expected) {
+		t.Errorf("expected %v, got %v", expected, names)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte("changed"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	changed, err := Record(dir, all, hashes, Options{Exclude: []string{"node_modules"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if names := keys(changed); !reflect.DeepEqual(names, []string{"main.go"}) {
+		t.Errorf("expected only the changed file, got %v", names)
+	}
+
+	if _, err := Record(dir, nil, hashes, Options{Include: []string{"[.go"}}); err == nil {
+		t.Error("expected an error for a malformed pattern")
+	}
+}
+
+func keys(artifacts map[string]cryptoutil.DigestSet) []string {
+	names := make([]string, 0, len(artifacts))
+	for name := range artifacts {
+		names = append(names, filepath.ToSlash(name))
+	}
+
+	sort.Strings(names)
+	return names
+}
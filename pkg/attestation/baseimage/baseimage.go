@@ -0,0 +1,216 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package baseimage
+
+import (
+	"crypto"
+	"path/filepath"
+	"strings"
+
+	"github.com/testifysec/go-witness/attestation"
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/log"
+	"github.com/testifysec/witness/pkg/attestation/command"
+	"github.com/testifysec/witness/pkg/replay"
+)
+
+const (
+	Name    = "base-image"
+	Type    = "https://witness.dev/attestations/base-image/v0.1"
+	RunType = attestation.PostRunType
+)
+
+// runCommand is replaced in tests
+var runCommand = replay.Output
+
+func init() {
+	attestation.RegisterAttestation(Name, Type, RunType, func() attestation.Attestor {
+		return New()
+	})
+}
+
+// Attestor records the images the Dockerfile of a docker, podman, or buildah build is built from, so the provenance
+// of the base image can be verified along with the image that was built.
+type Attestor struct {
+	Tool             string               `json:"tool,omitempty"`
+	Dockerfile       string               `json:"dockerfile,omitempty"`
+	DockerfileDigest cryptoutil.DigestSet `json:"dockerfiledigest,omitempty"`
+	Target           string               `json:"target,omitempty"`
+	// Images are the images the Dockerfile's stages are built from. Stages built from other stages are left out.
+	Images []Image `json:"images,omitempty"`
+	// Base is the image the target stage is built from, following stages built from other stages. It is empty for
+	// images built from scratch.
+	Base *Image `json:"base,omitempty"`
+}
+
+type Image struct {
+	Reference string `json:"reference"`
+	// Stage is the name of the stage built from the image, or its index when it isn't named.
+	Stage string `json:"stage"`
+	// Digest is the digest of the image's manifest, from the reference if it is pinned, the build's provenance, or the
+	// image in the local store.
+	Digest cryptoutil.DigestSet `json:"digest,omitempty"`
+}
+
+func New() *Attestor {
+	return &Attestor{}
+}
+
+func (a *Attestor) Name() string {
+	return Name
+}
+
+func (a *Attestor) Type() string {
+	return Type
+}
+
+func (a *Attestor) RunType() attestation.RunType {
+	return RunType
+}
+
+func (a *Attestor) Attest(ctx *attestation.AttestationContext) error {
+	var cmd []string
+	if cr, ok := command.Run(ctx.CompletedAttestors()); ok {
+		cmd = cr.Cmd
+	}
+
+	if len(cmd) == 0 {
+		log.Debugf("(attestation/base-image) no command was run")
+		return nil
+	}
+
+	return a.attest(ctx, cmd)
+}
+
+func (a *Attestor) attest(ctx *attestation.AttestationContext, cmd []string) error {
+	tool, args, ok := buildCommand(cmd)
+	if !ok {
+		log.Debugf("(attestation/base-image) %v did not build an image", strings.Join(cmd, " "))
+		return nil
+	}
+
+	build := parseBuildArgs(args)
+	wd := ctx.WorkingDir()
+	dockerfile := ""
+	switch {
+	case build.dockerfile == "-":
+		log.Debugf("(attestation/base-image) the dockerfile was read from stdin")
+		return nil
+	case build.dockerfile != "":
+		dockerfile = resolve(wd, build.dockerfile)
+	case build.context == "" || build.context == "-" || isRemote(build.context):
+		log.Debugf("(attestation/base-image) build context %v is not a local directory", build.context)
+		return nil
+	default:
+		dockerfile = defaultDockerfile(resolve(wd, build.context))
+	}
+
+	data, err := replay.ReadFile(dockerfile)
+	if err != nil {
+		log.Debugf("(attestation/base-image) failed to read %v: %v", dockerfile, err)
+		return nil
+	}
+
+	a.Tool = tool
+	a.Dockerfile = relativePath(wd, dockerfile)
+	a.Target = build.target
+	if digest, err := cryptoutil.CalculateDigestSetFromBytes(data, ctx.Hashes()); err == nil {
+		a.DockerfileDigest = digest
+	}
+
+	images, base := resolveStages(parseDockerfile(data, build.buildArgs), build.target)
+	materials := []material{}
+	if build.metadataFile != "" {
+		materials = readProvenanceMaterials(resolve(wd, build.metadataFile))
+	}
+
+	for i := range images {
+		if digest := a.imageDigest(ctx, images[i].Reference, materials); digest != "" {
+			images[i].Digest = cryptoutil.DigestSet{crypto.SHA256: digest}
+		}
+	}
+
+	if len(images) > 0 {
+		a.Images = images
+	}
+
+	if base >= 0 {
+		image := images[base]
+		a.Base = &image
+	}
+
+	return nil
+}
+
+// imageDigest returns the sha256 digest of the image from its reference if it is pinned, the materials of the build's
+// provenance, or the repo digests of the image in docker's or podman's local store, in that order.
+func (a *Attestor) imageDigest(ctx *attestation.AttestationContext, reference string, materials []material) string {
+	ref := parseReference(reference)
+	if ref.digest != "" {
+		return ref.digest
+	}
+
+	for _, m := range materials {
+		if m.name == ref.name && m.version == ref.tag && m.digest != "" {
+			return m.digest
+		}
+	}
+
+	if a.Tool == "buildah" {
+		return ""
+	}
+
+	out, err := runCommand(ctx.Context(), a.Tool, "image", "inspect", "--format", "{{json .RepoDigests}}", reference)
+	if err != nil {
+		log.Debugf("(attestation/base-image) failed to inspect %v: %v", reference, err)
+		return ""
+	}
+
+	return repoDigest(out, ref.name)
+}
+
+// defaultDockerfile returns the Dockerfile in the build context, or its Containerfile if it only has one.
+func defaultDockerfile(context string) string {
+	dockerfile := filepath.Join(context, "Dockerfile")
+	if containerfile := filepath.Join(context, "Containerfile"); !replay.Exists(dockerfile) && replay.Exists(containerfile) {
+		return containerfile
+	}
+
+	return dockerfile
+}
+
+// relativePath returns path relative to the working directory if it is inside of it.
+func relativePath(workingDir, path string) string {
+	rel, err := filepath.Rel(workingDir, path)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return path
+	}
+
+	return rel
+}
+
+// resolve joins a path from the command line to the directory the command ran in.
+func resolve(dir, path string) string {
+	if filepath.IsAbs(path) {
+		return filepath.Clean(path)
+	}
+
+	return filepath.Join(dir, path)
+}
+
+// isRemote returns true for build contexts that are git repositories or urls rather than local directories.
+func isRemote(context string) bool {
+	return strings.Contains(context, "://") || strings.HasPrefix(context, "git@") || strings.HasPrefix(context, "github.com/")
+}
@@ -0,0 +1,247 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package baseimage
+
+import (
+	"context"
+	"crypto"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/testifysec/go-witness/attestation"
+	"github.com/testifysec/go-witness/cryptoutil"
+)
+
+const (
+	alpineDigest = "aaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaaa"
+	golangDigest = "bbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbbb"
+	debianDigest = "cccccccccccccccccccccccccccccccccccccccccccccccccccccccccccccccc"
+)
+
+const dockerfile = `# syntax=docker/dockerfile:1
+ARG GO_VERSION=1.19
+ARG RUNTIME
+
+FROM --platform=$BUILDPLATFORM golang:${GO_VERSION} AS build
+RUN go build \
+    -o /app .
+
+FROM build AS test
+RUN go test ./...
+
+FROM ${RUNTIME:-alpine:3.18} AS runtime
+COPY --from=build /app /app
+
+FROM scratch AS minimal
+COPY --from=build /app /app
+
+FROM runtime
+`
+
+func newContext(t *testing.T, dir string) *attestation.AttestationContext {
+	ctx, err := attestation.NewContext([]attestation.Attestor{}, attestation.WithWorkingDir(dir), attestation.WithHashes([]crypto.Hash{crypto.SHA256}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return ctx
+}
+
+func TestBuildCommand(t *testing.T) {
+	tests := []struct {
+		cmd  []string
+		tool string
+		args []string
+		ok   bool
+	}{
+		{cmd: []string{"docker", "build", "-t", "app", "."}, tool: "docker", args: []string{"-t", "app", "."}, ok: true},
+		{cmd: []string{"/usr/bin/docker", "--context", "ci", "buildx", "build", "."}, tool: "docker", args: []string{"."}, ok: true},
+		{cmd: []string{"podman", "image", "build", "."}, tool: "podman", args: []string{"."}, ok: true},
+		{cmd: []string{"buildah", "bud", "."}, tool: "buildah", args: []string{"."}, ok: true},
+		{cmd: []string{"docker", "run", "build"}},
+		{cmd: []string{"docker", "buildx", "ls"}},
+		{cmd: []string{"make", "build"}},
+	}
+
+	for i, test := range tests {
+		t.Run(fmt.Sprint(i), func(t *testing.T) {
+			tool, args, ok := buildCommand(test.cmd)
+			if ok != test.ok {
+				t.Fatalf("expected ok to be %v", test.ok)
+			}
+
+			if ok && (tool != test.tool || !reflect.DeepEqual(args, test.args)) {
+				t.Fatalf("expected %v %v, got %v %v", test.tool, test.args, tool, args)
+			}
+		})
+	}
+}
+
+func TestParseBuildArgs(t *testing.T) {
+	args := parseBuildArgs([]string{"--pull", "-t", "app:1", "-f", "build/Dockerfile", "--build-arg", "GO_VERSION=1.20", "--build-arg", "TOKEN", "--target=runtime", "--metadata-file", "meta.json", "--platform", "linux/amd64,linux/arm64", "src"})
+	expected := buildArgs{
+		context:      "src",
+		dockerfile:   "build/Dockerfile",
+		target:       "runtime",
+		metadataFile: "meta.json",
+		buildArgs:    map[string]string{"GO_VERSION": "1.20"},
+	}
+
+	if !reflect.DeepEqual(args, expected) {
+		t.Fatalf("expected %+v, got %+v", expected, args)
+	}
+}
+
+func TestParseDockerfile(t *testing.T) {
+	stages := parseDockerfile([]byte(dockerfile), map[string]string{"GO_VERSION": "1.20"})
+	expected := []stage{
+		{name: "build", image: "golang:1.20"},
+		{name: "test", image: "build"},
+		{name: "runtime", image: "alpine:3.18"},
+		{name: "minimal", image: "scratch"},
+		{image: "runtime"},
+	}
+
+	if !reflect.DeepEqual(stages, expected) {
+		t.Fatalf("expected %+v, got %+v", expected, stages)
+	}
+
+	escaped := parseDockerfile([]byte("# escape=`\nFROM `\n  mcr.microsoft.com/windows/servercore:ltsc2022\n"), nil)
+	if len(escaped) != 1 || escaped[0].image != "mcr.microsoft.com/windows/servercore:ltsc2022" {
+		t.Fatalf("expected the escape directive to be used, got %+v", escaped)
+	}
+}
+
+func TestResolveStages(t *testing.T) {
+	stages := parseDockerfile([]byte(dockerfile), nil)
+	tests := []struct {
+		target string
+		base   string
+	}{
+		{target: "", base: "alpine:3.18"},
+		{target: "test", base: "golang:1.19"},
+		{target: "minimal", base: ""},
+		{target: "missing", base: ""},
+	}
+
+	for _, test := range tests {
+		images, base := resolveStages(stages, test.target)
+		if len(images) != 2 || images[0].Stage != "build" || images[1].Stage != "runtime" {
+			t.Fatalf("unexpected images: %+v", images)
+		}
+
+		got := ""
+		if base >= 0 {
+			got = images[base].Reference
+		}
+
+		if got != test.base {
+			t.Errorf("expected base %q for target %q, got %q", test.base, test.target, got)
+		}
+	}
+}
+
+func TestParseReference(t *testing.T) {
+	tests := map[string]reference{
+		"alpine":                                    {name: "alpine", tag: "latest"},
+		"docker.io/library/alpine:3.18":             {name: "alpine", tag: "3.18"},
+		"docker.io/bitnami/redis:7":                 {name: "bitnami/redis", tag: "7"},
+		"localhost:5000/app":                        {name: "localhost:5000/app", tag: "latest"},
+		"ghcr.io/org/app:v1@sha256:" + alpineDigest: {name: "ghcr.io/org/app", tag: "v1", digest: alpineDigest},
+	}
+
+	for s, expected := range tests {
+		if ref := parseReference(s); ref != expected {
+			t.Errorf("expected %+v for %v, got %+v", expected, s, ref)
+		}
+	}
+}
+
+func TestAttest(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "Dockerfile"), []byte(dockerfile), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	metadata := fmt.Sprintf(`{
+  "containerimage.digest": "sha256:%v",
+  "buildx.build.provenance": {
+    "buildType": "https://mobyproject.org/buildkit@v1",
+    "materials": [
+      {"uri": "pkg:docker/golang@1.19?platform=linux%%2Famd64", "digest": {"sha256": %q}},
+      {"uri": "pkg:docker/alpine@3.18?platform=linux%%2Famd64", "digest": {"sha256": %q}}
+    ]
+  }
+}`, debianDigest, golangDigest, alpineDigest)
+	if err := os.WriteFile(filepath.Join(dir, "metadata.json"), []byte(metadata), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	oldRun := runCommand
+	defer func() { runCommand = oldRun }()
+	runCommand = func(ctx context.Context, name string, args ...string) ([]byte, error) {
+		return nil, fmt.Errorf("unexpected command %v %v", name, strings.Join(args, " "))
+	}
+
+	a := New()
+	if err := a.attest(newContext(t, dir), []string{"docker", "buildx", "build", "--metadata-file", "metadata.json", "."}); err != nil {
+		t.Fatal(err)
+	}
+
+	if a.Tool != "docker" || a.Dockerfile != "Dockerfile" || a.DockerfileDigest == nil {
+		t.Fatalf("unexpected build: %+v", a)
+	}
+
+	expected := &Image{Reference: "alpine:3.18", Stage: "runtime", Digest: cryptoutil.DigestSet{crypto.SHA256: alpineDigest}}
+	if !reflect.DeepEqual(a.Base, expected) {
+		t.Fatalf("expected base %+v, got %+v", expected, a.Base)
+	}
+
+	if len(a.Images) != 2 || a.Images[0].Digest[crypto.SHA256] != golangDigest {
+		t.Fatalf("unexpected images: %+v", a.Images)
+	}
+}
+
+func TestAttestInspectsLocalImage(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "Containerfile"), []byte("FROM debian:12\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	oldRun := runCommand
+	defer func() { runCommand = oldRun }()
+	query := ""
+	runCommand = func(ctx context.Context, name string, args ...string) ([]byte, error) {
+		query = strings.Join(append([]string{name}, args...), " ")
+		return []byte(fmt.Sprintf(`["docker.io/library/alpine@sha256:%v","docker.io/library/debian@sha256:%v"]`, alpineDigest, debianDigest)), nil
+	}
+
+	a := New()
+	if err := a.attest(newContext(t, dir), []string{"podman", "build", "-t", "app", "."}); err != nil {
+		t.Fatal(err)
+	}
+
+	if query != "podman image inspect --format {{json .RepoDigests}} debian:12" {
+		t.Fatalf("unexpected query %q", query)
+	}
+
+	if a.Dockerfile != "Containerfile" || a.Base == nil || a.Base.Digest[crypto.SHA256] != debianDigest {
+		t.Fatalf("unexpected base: %+v", a.Base)
+	}
+}
@@ -0,0 +1,178 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package baseimage
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+type buildArgs struct {
+	context      string
+	dockerfile   string
+	target       string
+	metadataFile string
+	buildArgs    map[string]string
+}
+
+// buildSubcommands are the subcommands of each tool that build an image.
+var buildSubcommands = map[string][][]string{
+	"docker":  {{"build"}, {"buildx", "build"}, {"buildx", "b"}, {"image", "build"}, {"builder", "build"}},
+	"podman":  {{"build"}, {"image", "build"}, {"buildx", "build"}},
+	"buildah": {{"build"}, {"bud"}, {"build-using-dockerfile"}},
+}
+
+// globalOptionsWithValue are the options before the subcommand that take a value as the next argument when it isn't
+// attached with =.
+var globalOptionsWithValue = map[string]struct{}{
+	"-c":               {},
+	"--context":        {},
+	"--config":         {},
+	"-H":               {},
+	"--host":           {},
+	"-l":               {},
+	"--log-level":      {},
+	"--tlscacert":      {},
+	"--tlscert":        {},
+	"--tlskey":         {},
+	"--builder":        {},
+	"--connection":     {},
+	"--url":            {},
+	"--root":           {},
+	"--runroot":        {},
+	"--storage-driver": {},
+	"--storage-opt":    {},
+}
+
+// buildBooleanOptions are the build options that don't take a value. Any other option without an attached value is
+// assumed to take the next argument.
+var buildBooleanOptions = map[string]struct{}{
+	"-q":                      {},
+	"--quiet":                 {},
+	"--no-cache":              {},
+	"--pull":                  {},
+	"--push":                  {},
+	"--load":                  {},
+	"--rm":                    {},
+	"--force-rm":              {},
+	"--squash":                {},
+	"--squash-all":            {},
+	"--compress":              {},
+	"--disable-content-trust": {},
+	"--debug":                 {},
+	"--check":                 {},
+	"--layers":                {},
+	"--no-hosts":              {},
+	"--all-platforms":         {},
+	"--stdin":                 {},
+	"--tls-verify":            {},
+	"--omit-history":          {},
+	"--identity-label":        {},
+}
+
+// buildCommand returns the tool and the arguments following the build subcommand of a command line that builds an
+// image with docker, podman, or buildah.
+func buildCommand(cmd []string) (string, []string, bool) {
+	tool := filepath.Base(cmd[0])
+	subcommands, ok := buildSubcommands[tool]
+	if !ok {
+		return "", nil, false
+	}
+
+	words := []string{}
+	for i := 1; i < len(cmd); i++ {
+		arg := cmd[i]
+		if strings.HasPrefix(arg, "-") {
+			if _, ok := globalOptionsWithValue[arg]; ok {
+				i++
+			}
+
+			continue
+		}
+
+		words = append(words, arg)
+		for _, subcommand := range subcommands {
+			if equal(words, subcommand) {
+				return tool, cmd[i+1:], true
+			}
+		}
+
+		if len(words) >= 2 {
+			return "", nil, false
+		}
+	}
+
+	return "", nil, false
+}
+
+// parseBuildArgs parses the build context, Dockerfile, target, build arguments, and metadata file of a build command.
+func parseBuildArgs(args []string) buildArgs {
+	parsed := buildArgs{buildArgs: make(map[string]string)}
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			if i+1 < len(args) {
+				parsed.context = args[i+1]
+			}
+
+			break
+		}
+
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			parsed.context = arg
+			continue
+		}
+
+		option, value := arg, ""
+		if idx := strings.Index(arg, "="); idx >= 0 {
+			option, value = arg[:idx], arg[idx+1:]
+		} else if _, ok := buildBooleanOptions[arg]; ok {
+			continue
+		} else if i+1 < len(args) {
+			i++
+			value = args[i]
+		}
+
+		switch option {
+		case "-f", "--file":
+			parsed.dockerfile = value
+		case "--target":
+			parsed.target = value
+		case "--metadata-file":
+			parsed.metadataFile = value
+		case "--build-arg":
+			// build arguments without a value are taken from the environment, which isn't recorded
+			if idx := strings.Index(value, "="); idx >= 0 {
+				parsed.buildArgs[value[:idx]] = value[idx+1:]
+			}
+		}
+	}
+
+	return parsed
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,203 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package baseimage
+
+import (
+	"bufio"
+	"bytes"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type stage struct {
+	name  string
+	image string
+}
+
+var (
+	escapeDirectivePattern = regexp.MustCompile(`^#\s*escape\s*=\s*(\S)`)
+	variablePattern        = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)(?::([-+])([^}]*))?\}|\$([A-Za-z_][A-Za-z0-9_]*)`)
+)
+
+// parseDockerfile returns the stages of a Dockerfile in order, with the ARG instructions before the first FROM
+// substituted into their images. Build arguments override the ARG defaults.
+func parseDockerfile(data []byte, buildArgs map[string]string) []stage {
+	escape := `\`
+	args := make(map[string]string)
+	stages := []stage{}
+	for _, line := range logicalLines(data, &escape) {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		switch strings.ToUpper(fields[0]) {
+		case "ARG":
+			if len(stages) > 0 {
+				continue
+			}
+
+			for _, arg := range fields[1:] {
+				name, value := arg, ""
+				if idx := strings.Index(arg, "="); idx >= 0 {
+					name, value = arg[:idx], strings.Trim(substitute(arg[idx+1:], args), `"'`)
+				}
+
+				if override, ok := buildArgs[name]; ok {
+					value = override
+				}
+
+				args[name] = value
+			}
+
+		case "FROM":
+			operands := []string{}
+			for _, field := range fields[1:] {
+				if !strings.HasPrefix(field, "--") {
+					operands = append(operands, field)
+				}
+			}
+
+			if len(operands) == 0 {
+				continue
+			}
+
+			s := stage{image: substitute(operands[0], args)}
+			if len(operands) >= 3 && strings.EqualFold(operands[1], "AS") {
+				s.name = strings.ToLower(operands[2])
+			}
+
+			stages = append(stages, s)
+		}
+	}
+
+	return stages
+}
+
+// logicalLines returns the instructions of a Dockerfile with escaped newlines joined, and blank lines and comments
+// removed. The escape character is updated from the escape parser directive.
+func logicalLines(data []byte, escape *string) []string {
+	lines := []string{}
+	current := strings.Builder{}
+	directives := true
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if directives {
+			if matches := escapeDirectivePattern.FindStringSubmatch(line); matches != nil {
+				*escape = matches[1]
+				continue
+			}
+
+			directives = strings.HasPrefix(line, "#") && strings.Contains(line, "=")
+		}
+
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if strings.HasSuffix(line, *escape) {
+			current.WriteString(strings.TrimSuffix(line, *escape))
+			current.WriteString(" ")
+			continue
+		}
+
+		current.WriteString(line)
+		lines = append(lines, current.String())
+		current.Reset()
+	}
+
+	if current.Len() > 0 {
+		lines = append(lines, current.String())
+	}
+
+	return lines
+}
+
+// substitute replaces $NAME, ${NAME}, ${NAME:-default}, and ${NAME:+alternate} with the values of the arguments.
+func substitute(s string, args map[string]string) string {
+	return variablePattern.ReplaceAllStringFunc(s, func(match string) string {
+		groups := variablePattern.FindStringSubmatch(match)
+		if groups[4] != "" {
+			return args[groups[4]]
+		}
+
+		value := args[groups[1]]
+		switch groups[2] {
+		case "-":
+			if value == "" {
+				return groups[3]
+			}
+		case "+":
+			if value != "" {
+				return groups[3]
+			}
+
+			return ""
+		}
+
+		return value
+	})
+}
+
+// resolveStages returns the images the stages are built from, leaving out stages built from scratch or from an earlier
+// stage, and the index of the image the target stage is ultimately built from. The last stage is the target when none
+// is given. The index is -1 if the target is built from scratch or can't be found.
+func resolveStages(stages []stage, target string) ([]Image, int) {
+	images := []Image{}
+	imageIndex := make([]int, len(stages))
+	parent := make([]int, len(stages))
+	names := make(map[string]int)
+	for i, s := range stages {
+		imageIndex[i], parent[i] = -1, -1
+		if earlier, ok := names[strings.ToLower(s.image)]; ok {
+			parent[i] = earlier
+		} else if !strings.EqualFold(s.image, "scratch") {
+			name := s.name
+			if name == "" {
+				name = strconv.Itoa(i)
+			}
+
+			imageIndex[i] = len(images)
+			images = append(images, Image{Reference: s.image, Stage: name})
+		}
+
+		if s.name != "" {
+			names[s.name] = i
+		}
+	}
+
+	if len(stages) == 0 {
+		return images, -1
+	}
+
+	current := len(stages) - 1
+	if target != "" {
+		idx, ok := names[strings.ToLower(target)]
+		if !ok {
+			return images, -1
+		}
+
+		current = idx
+	}
+
+	for parent[current] >= 0 {
+		current = parent[current]
+	}
+
+	return images, imageIndex[current]
+}
@@ -0,0 +1,189 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package baseimage
+
+import (
+	"encoding/json"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/testifysec/go-witness/log"
+	"github.com/testifysec/witness/pkg/replay"
+)
+
+const (
+	// provenanceKey prefixes the keys of the SLSA provenance buildx writes to its metadata file, which are suffixed
+	// with the platform for multi-platform builds.
+	provenanceKey = "buildx.build.provenance"
+	dockerPURL    = "pkg:docker/"
+)
+
+var sha256Pattern = regexp.MustCompile(`^sha256:([0-9a-f]{64})$`)
+
+type reference struct {
+	name   string
+	tag    string
+	digest string
+}
+
+// material is an image resolved by buildkit, from the materials of its SLSA provenance.
+type material struct {
+	name    string
+	version string
+	digest  string
+}
+
+type provenanceDependency struct {
+	URI    string            `json:"uri"`
+	Digest map[string]string `json:"digest"`
+}
+
+type provenance struct {
+	Materials       []provenanceDependency `json:"materials"`
+	BuildDefinition struct {
+		ResolvedDependencies []provenanceDependency `json:"resolvedDependencies"`
+	} `json:"buildDefinition"`
+}
+
+// parseReference splits an image reference into its name, tag, and sha256 digest. Names on docker hub are shortened
+// the way docker shows them, so docker.io/library/alpine and alpine have the same name. The tag defaults to latest.
+func parseReference(s string) reference {
+	ref := reference{}
+	if idx := strings.Index(s, "@"); idx >= 0 {
+		if matches := sha256Pattern.FindStringSubmatch(s[idx+1:]); matches != nil {
+			ref.digest = matches[1]
+		}
+
+		s = s[:idx]
+	}
+
+	if idx := strings.LastIndex(s, ":"); idx > strings.LastIndex(s, "/") {
+		s, ref.tag = s[:idx], s[idx+1:]
+	}
+
+	if ref.tag == "" && ref.digest == "" {
+		ref.tag = "latest"
+	}
+
+	ref.name = familiarName(s)
+	return ref
+}
+
+func familiarName(name string) string {
+	for _, registry := range []string{"docker.io/", "index.docker.io/", "registry-1.docker.io/"} {
+		if strings.HasPrefix(name, registry) {
+			name = strings.TrimPrefix(name, registry)
+			break
+		}
+	}
+
+	if rest := strings.TrimPrefix(name, "library/"); rest != name && !strings.Contains(rest, "/") {
+		return rest
+	}
+
+	return name
+}
+
+// readProvenanceMaterials returns the images in the SLSA provenance of the metadata file buildx writes with
+// --metadata-file.
+func readProvenanceMaterials(path string) []material {
+	data, err := replay.ReadFile(path)
+	if err != nil {
+		log.Debugf("(attestation/base-image) failed to read build metadata %v: %v", path, err)
+		return nil
+	}
+
+	metadata := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(data, &metadata); err != nil {
+		log.Debugf("(attestation/base-image) failed to unmarshal build metadata %v: %v", path, err)
+		return nil
+	}
+
+	keys := make([]string, 0, len(metadata))
+	for key := range metadata {
+		if strings.HasPrefix(key, provenanceKey) {
+			keys = append(keys, key)
+		}
+	}
+
+	sort.Strings(keys)
+	materials := []material{}
+	for _, key := range keys {
+		p := provenance{}
+		if err := json.Unmarshal(metadata[key], &p); err != nil {
+			log.Debugf("(attestation/base-image) failed to unmarshal %v: %v", key, err)
+			continue
+		}
+
+		for _, dependency := range append(p.Materials, p.BuildDefinition.ResolvedDependencies...) {
+			if m, ok := parseDockerPURL(dependency.URI); ok {
+				m.digest = dependency.Digest["sha256"]
+				materials = append(materials, m)
+			}
+		}
+	}
+
+	return materials
+}
+
+// parseDockerPURL parses the name and version of a package url for a docker image, such as
+// pkg:docker/alpine@3.18?platform=linux%2Famd64.
+func parseDockerPURL(purl string) (material, bool) {
+	if !strings.HasPrefix(purl, dockerPURL) {
+		return material{}, false
+	}
+
+	purl = strings.TrimPrefix(purl, dockerPURL)
+	if idx := strings.IndexAny(purl, "?#"); idx >= 0 {
+		purl = purl[:idx]
+	}
+
+	m := material{}
+	if idx := strings.LastIndex(purl, "@"); idx >= 0 {
+		purl, m.version = purl[:idx], purl[idx+1:]
+	}
+
+	name, err := url.PathUnescape(purl)
+	if err != nil {
+		return material{}, false
+	}
+
+	if version, err := url.PathUnescape(m.version); err == nil {
+		m.version = version
+	}
+
+	m.name = familiarName(name)
+	return m, true
+}
+
+// repoDigest returns the digest of the image with the name from the json list of repo digests docker or podman
+// reports for an image.
+func repoDigest(out []byte, name string) string {
+	repoDigests := []string{}
+	if err := json.Unmarshal(out, &repoDigests); err != nil {
+		log.Debugf("(attestation/base-image) failed to unmarshal repo digests: %v", err)
+		return ""
+	}
+
+	for _, repoDigest := range repoDigests {
+		if ref := parseReference(repoDigest); ref.name == name && ref.digest != "" {
+			return ref.digest
+		}
+	}
+
+	return ""
+}
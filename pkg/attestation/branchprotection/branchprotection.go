@@ -0,0 +1,189 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package branchprotection
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/testifysec/go-witness/attestation"
+	"github.com/testifysec/go-witness/log"
+	"github.com/testifysec/witness/pkg/github"
+)
+
+const (
+	Name    = "branch-protection"
+	Type    = "https://witness.dev/attestations/branch-protection/v0.1"
+	RunType = attestation.PreRunType
+
+	PlatformGitHub = "github"
+	PlatformGitLab = "gitlab"
+)
+
+var errGitLabNotFound = errors.New("not found")
+
+type ErrUnsupportedPlatform struct{}
+
+func (e ErrUnsupportedPlatform) Error() string {
+	return "branch protection can only be attested in github actions or gitlab ci"
+}
+
+func init() {
+	attestation.RegisterAttestation(Name, Type, RunType, func() attestation.Attestor {
+		return New()
+	})
+}
+
+// Attestor records the branch protection rules in effect for the branch being built. Responses from the platform's
+// API are recorded as they were returned so policies can inspect any setting.
+type Attestor struct {
+	Platform   string `json:"platform"`
+	Repository string `json:"repository"`
+	Branch     string `json:"branch"`
+	// Protected is false if the platform reported that the branch has no protection.
+	Protected bool `json:"protected"`
+	// Protection holds github's classic branch protection, or gitlab's protected branch settings.
+	Protection json.RawMessage `json:"protection,omitempty"`
+	// Rules holds the github ruleset rules that apply to the branch.
+	Rules json.RawMessage `json:"rules,omitempty"`
+	// ApprovalRules holds the gitlab merge request approval rules of the project.
+	ApprovalRules json.RawMessage `json:"approvalrules,omitempty"`
+}
+
+func New() *Attestor {
+	return &Attestor{}
+}
+
+func (a *Attestor) Name() string {
+	return Name
+}
+
+func (a *Attestor) Type() string {
+	return Type
+}
+
+func (a *Attestor) RunType() attestation.RunType {
+	return RunType
+}
+
+func (a *Attestor) Attest(ctx *attestation.AttestationContext) error {
+	switch {
+	case os.Getenv("GITHUB_ACTIONS") == "true":
+		return a.attestGitHub(ctx.Context())
+	case os.Getenv("GITLAB_CI") == "true":
+		return a.attestGitLab(ctx.Context())
+	default:
+		return ErrUnsupportedPlatform{}
+	}
+}
+
+func (a *Attestor) attestGitHub(ctx context.Context) error {
+	a.Platform = PlatformGitHub
+	a.Repository = os.Getenv("GITHUB_REPOSITORY")
+	// pull requests are merged into the base branch, so its protection is what applies to the change
+	a.Branch = os.Getenv("GITHUB_BASE_REF")
+	if a.Branch == "" {
+		a.Branch = os.Getenv("GITHUB_REF_NAME")
+	}
+
+	client := github.Client{APIURL: os.Getenv("GITHUB_API_URL"), Token: os.Getenv("GITHUB_TOKEN")}
+	protection, err := client.BranchProtection(ctx, a.Repository, a.Branch)
+	if err == nil {
+		a.Protection = protection
+		a.Protected = true
+	} else if !errors.Is(err, github.ErrNotFound) {
+		return err
+	}
+
+	rules, err := client.BranchRules(ctx, a.Repository, a.Branch)
+	if err != nil {
+		log.Debugf("(attestation/branch-protection) failed to get branch rules: %v", err)
+		return nil
+	}
+
+	if string(rules) != "[]" {
+		a.Rules = rules
+		a.Protected = true
+	}
+
+	return nil
+}
+
+func (a *Attestor) attestGitLab(ctx context.Context) error {
+	a.Platform = PlatformGitLab
+	a.Repository = os.Getenv("CI_PROJECT_PATH")
+	a.Branch = os.Getenv("CI_MERGE_REQUEST_TARGET_BRANCH_NAME")
+	if a.Branch == "" {
+		a.Branch = os.Getenv("CI_COMMIT_REF_NAME")
+	}
+
+	projectURL := fmt.Sprintf("%s/projects/%s", strings.TrimSuffix(os.Getenv("CI_API_V4_URL"), "/"), url.PathEscape(os.Getenv("CI_PROJECT_ID")))
+	protection, err := gitlabGet(ctx, fmt.Sprintf("%s/protected_branches/%s", projectURL, url.PathEscape(a.Branch)))
+	if err == nil {
+		a.Protection = protection
+		a.Protected = true
+	} else if !errors.Is(err, errGitLabNotFound) {
+		return err
+	}
+
+	approvalRules, err := gitlabGet(ctx, projectURL+"/approval_rules")
+	if err != nil {
+		log.Debugf("(attestation/branch-protection) failed to get approval rules: %v", err)
+		return nil
+	}
+
+	a.ApprovalRules = approvalRules
+	return nil
+}
+
+func gitlabGet(ctx context.Context, reqURL string) (json.RawMessage, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, reqURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if token := os.Getenv("GITLAB_TOKEN"); token != "" {
+		req.Header.Set("PRIVATE-TOKEN", token)
+	} else if token := os.Getenv("CI_JOB_TOKEN"); token != "" {
+		req.Header.Set("JOB-TOKEN", token)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, errGitLabNotFound
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to get %v: unexpected status %v", reqURL, resp.Status)
+	}
+
+	body := json.RawMessage{}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return body, nil
+}
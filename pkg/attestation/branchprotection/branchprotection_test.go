@@ -0,0 +1,115 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package branchprotection
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAttestGitHub(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/testifysec/witness/branches/main/protection":
+			_, _ = w.Write([]byte(`{"required_pull_request_reviews": {"required_approving_review_count": 2}}`))
+		case "/repos/testifysec/witness/rules/branches/main":
+			_, _ = w.Write([]byte(`[]`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	t.Setenv("GITHUB_REPOSITORY", "testifysec/witness")
+	t.Setenv("GITHUB_REF_NAME", "feature")
+	t.Setenv("GITHUB_BASE_REF", "main")
+	t.Setenv("GITHUB_API_URL", server.URL)
+
+	a := New()
+	if err := a.attestGitHub(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if a.Branch != "main" || !a.Protected {
+		t.Errorf("expected protected base branch, got %v protected=%v", a.Branch, a.Protected)
+	}
+
+	if len(a.Protection) == 0 || a.Rules != nil {
+		t.Errorf("unexpected protection %s and rules %s", a.Protection, a.Rules)
+	}
+}
+
+func TestAttestGitHubUnprotected(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/repos/testifysec/witness/rules/branches/dev" {
+			_, _ = w.Write([]byte(`[]`))
+			return
+		}
+
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	t.Setenv("GITHUB_REPOSITORY", "testifysec/witness")
+	t.Setenv("GITHUB_REF_NAME", "dev")
+	t.Setenv("GITHUB_BASE_REF", "")
+	t.Setenv("GITHUB_API_URL", server.URL)
+
+	a := New()
+	if err := a.attestGitHub(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if a.Protected {
+		t.Error("expected branch to be unprotected")
+	}
+}
+
+func TestAttestGitLab(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("PRIVATE-TOKEN") != "token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		switch r.URL.EscapedPath() {
+		case "/api/v4/projects/42/protected_branches/main":
+			_, _ = w.Write([]byte(`{"name": "main", "allow_force_push": false}`))
+		case "/api/v4/projects/42/approval_rules":
+			_, _ = w.Write([]byte(`[{"approvals_required": 1}]`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	t.Setenv("CI_API_V4_URL", server.URL+"/api/v4")
+	t.Setenv("CI_PROJECT_ID", "42")
+	t.Setenv("CI_PROJECT_PATH", "testifysec/witness")
+	t.Setenv("CI_COMMIT_REF_NAME", "main")
+	t.Setenv("CI_MERGE_REQUEST_TARGET_BRANCH_NAME", "")
+	t.Setenv("GITLAB_TOKEN", "token")
+
+	a := New()
+	if err := a.attestGitLab(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !a.Protected || len(a.ApprovalRules) == 0 {
+		t.Errorf("unexpected attestation: %+v", a)
+	}
+}
@@ -0,0 +1,209 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package buildcache
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/url"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/testifysec/go-witness/attestation"
+	"github.com/testifysec/go-witness/log"
+	"github.com/testifysec/witness/pkg/replay"
+)
+
+const (
+	Name    = "buildcache"
+	Type    = "https://witness.dev/attestations/buildcache/v0.1"
+	RunType = attestation.PostRunType
+)
+
+// sccacheStorageEnv are the environment variables that select a remote sccache backend.
+var sccacheStorageEnv = []string{
+	"SCCACHE_BUCKET",
+	"SCCACHE_ENDPOINT",
+	"SCCACHE_GCS_BUCKET",
+	"SCCACHE_AZURE_CONNECTION_STRING",
+	"SCCACHE_REDIS",
+	"SCCACHE_MEMCACHED",
+	"SCCACHE_GHA_CACHE",
+}
+
+// retrievedPattern matches ccache log lines recording a result retrieved from local or remote storage.
+var retrievedPattern = regexp.MustCompile(`Retrieved ([0-9a-z]+) from (.+)$`)
+
+// runCommand is replaced in tests
+var runCommand = replay.Output
+
+func init() {
+	attestation.RegisterAttestation(Name, Type, RunType, func() attestation.Attestor {
+		return New()
+	})
+}
+
+type Attestor struct {
+	Ccache  *Ccache  `json:"ccache,omitempty"`
+	Sccache *Sccache `json:"sccache,omitempty"`
+}
+
+type Ccache struct {
+	CacheDir      string            `json:"cachedir"`
+	RemoteStorage []string          `json:"remotestorage,omitempty"`
+	Stats         map[string]string `json:"stats"`
+	// Entries are the cache results consumed during the build, read from CCACHE_LOGFILE if it is set.
+	Entries []CacheEntry `json:"entries,omitempty"`
+}
+
+type CacheEntry struct {
+	Key    string `json:"key"`
+	Source string `json:"source"`
+}
+
+type Sccache struct {
+	RemoteStorage map[string]string `json:"remotestorage,omitempty"`
+	Stats         json.RawMessage   `json:"stats"`
+}
+
+func New() *Attestor {
+	return &Attestor{}
+}
+
+func (a *Attestor) Name() string {
+	return Name
+}
+
+func (a *Attestor) Type() string {
+	return Type
+}
+
+func (a *Attestor) RunType() attestation.RunType {
+	return RunType
+}
+
+func (a *Attestor) Attest(ctx *attestation.AttestationContext) error {
+	a.Ccache = attestCcache(ctx.Context())
+	a.Sccache = attestSccache(ctx.Context())
+	return nil
+}
+
+func attestCcache(ctx context.Context) *Ccache {
+	statsOut, err := runCommand(ctx, "ccache", "--print-stats")
+	if err != nil {
+		log.Debugf("(attestation/buildcache) ccache stats unavailable: %v", err)
+		return nil
+	}
+
+	c := &Ccache{Stats: parseCcacheStats(statsOut)}
+	if out, err := runCommand(ctx, "ccache", "--get-config", "cache_dir"); err == nil {
+		c.CacheDir = strings.TrimSpace(string(out))
+	}
+
+	// remote_storage replaced secondary_storage in ccache 4.4
+	for _, key := range []string{"remote_storage", "secondary_storage"} {
+		out, err := runCommand(ctx, "ccache", "--get-config", key)
+		if err != nil {
+			continue
+		}
+
+		for _, storage := range strings.Fields(string(out)) {
+			c.RemoteStorage = append(c.RemoteStorage, redactURL(storage))
+		}
+	}
+
+	if logFile := os.Getenv("CCACHE_LOGFILE"); logFile != "" {
+		data, err := replay.ReadFile(logFile)
+		if err != nil {
+			log.Debugf("(attestation/buildcache) failed to read ccache log: %v", err)
+		} else {
+			c.Entries = parseCcacheLog(bytes.NewReader(data))
+		}
+	}
+
+	return c
+}
+
+func attestSccache(ctx context.Context) *Sccache {
+	out, err := runCommand(ctx, "sccache", "--show-stats", "--stats-format=json")
+	if err != nil {
+		log.Debugf("(attestation/buildcache) sccache stats unavailable: %v", err)
+		return nil
+	}
+
+	s := &Sccache{Stats: json.RawMessage(bytes.TrimSpace(out))}
+	for _, env := range sccacheStorageEnv {
+		if value, ok := os.LookupEnv(env); ok {
+			if s.RemoteStorage == nil {
+				s.RemoteStorage = make(map[string]string)
+			}
+
+			// connection strings carry credentials, only record that the backend was configured
+			if env == "SCCACHE_AZURE_CONNECTION_STRING" {
+				value = "set"
+			}
+
+			s.RemoteStorage[env] = redactURL(value)
+		}
+	}
+
+	return s
+}
+
+// parseCcacheStats parses the tab separated output of ccache --print-stats.
+func parseCcacheStats(out []byte) map[string]string {
+	stats := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		parts := strings.SplitN(scanner.Text(), "\t", 2)
+		if len(parts) != 2 {
+			continue
+		}
+
+		stats[parts[0]] = strings.TrimSpace(parts[1])
+	}
+
+	return stats
+}
+
+func parseCcacheLog(r io.Reader) []CacheEntry {
+	entries := make([]CacheEntry, 0)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		matches := retrievedPattern.FindStringSubmatch(scanner.Text())
+		if matches == nil {
+			continue
+		}
+
+		entries = append(entries, CacheEntry{Key: matches[1], Source: redactURL(matches[2])})
+	}
+
+	return entries
+}
+
+// redactURL removes any credentials from storage URLs so they aren't recorded in the attestation.
+func redactURL(s string) string {
+	u, err := url.Parse(s)
+	if err != nil || u.User == nil {
+		return s
+	}
+
+	u.User = nil
+	return u.String()
+}
@@ -0,0 +1,182 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package buildcounter
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/testifysec/go-witness/attestation"
+	"github.com/testifysec/witness/pkg/replay"
+)
+
+const (
+	Name    = "build-counter"
+	Type    = "https://witness.dev/attestations/build-counter/v0.1"
+	RunType = attestation.PreRunType
+
+	// SourceEnv selects the counter source: tpm:<nv index> for a TPM NV counter, or the http(s) URL of a sequence
+	// service such as Archivista.
+	SourceEnv = "WITNESS_BUILD_COUNTER"
+	// NameEnv names the counter to increment at a sequence service.
+	NameEnv = "WITNESS_BUILD_COUNTER_NAME"
+	// TokenEnv is sent to a sequence service as a bearer token.
+	TokenEnv = "WITNESS_BUILD_COUNTER_TOKEN"
+
+	tpmPrefix = "tpm:"
+)
+
+// runCommand is replaced in tests
+var runCommand = replay.Output
+
+func init() {
+	attestation.RegisterAttestation(Name, Type, RunType, func() attestation.Attestor {
+		return New()
+	})
+}
+
+// Attestor increments a monotonic counter before the step runs and records its new value. Because the counter can
+// never go backwards, a verifier that remembers the highest counter it has accepted can reject an older collection
+// that is replayed as the latest release.
+type Attestor struct {
+	Source      string `json:"source"`
+	CounterName string `json:"name,omitempty"`
+	Counter     uint64 `json:"counter"`
+	// Signature and KeyID are returned by sequence services that sign the counters they issue, such as a cloud KMS
+	// backed sequence.
+	Signature string `json:"signature,omitempty"`
+	KeyID     string `json:"keyid,omitempty"`
+}
+
+func New() *Attestor {
+	return &Attestor{}
+}
+
+func (a *Attestor) Name() string {
+	return Name
+}
+
+func (a *Attestor) Type() string {
+	return Type
+}
+
+func (a *Attestor) RunType() attestation.RunType {
+	return RunType
+}
+
+func (a *Attestor) Attest(ctx *attestation.AttestationContext) error {
+	source := os.Getenv(SourceEnv)
+	if source == "" {
+		return fmt.Errorf("%v must be set to use the build counter attestor", SourceEnv)
+	}
+
+	a.CounterName = os.Getenv(NameEnv)
+	if strings.HasPrefix(source, tpmPrefix) {
+		a.Source = source
+		counter, err := incrementTPM(ctx.Context(), strings.TrimPrefix(source, tpmPrefix))
+		if err != nil {
+			return fmt.Errorf("failed to increment tpm counter: %w", err)
+		}
+
+		a.Counter = counter
+		return nil
+	}
+
+	u, err := url.Parse(source)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return fmt.Errorf("build counter source must be tpm:<nv index> or an http(s) url: %v", source)
+	}
+
+	// credentials in the url are not recorded
+	u.User = nil
+	a.Source = u.String()
+	seq, err := incrementSequence(ctx.Context(), source, a.CounterName, os.Getenv(TokenEnv))
+	if err != nil {
+		return fmt.Errorf("failed to increment sequence: %w", err)
+	}
+
+	a.Counter = seq.Counter
+	a.Signature = seq.Signature
+	a.KeyID = seq.KeyID
+	return nil
+}
+
+// incrementTPM increments the TPM NV counter at the index with tpm2-tools and returns its new value.
+func incrementTPM(ctx context.Context, index string) (uint64, error) {
+	if _, err := runCommand(ctx, "tpm2_nvincrement", "-C", "o", index); err != nil {
+		return 0, err
+	}
+
+	out, err := runCommand(ctx, "tpm2_nvread", "-C", "o", "-s", "8", index)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(out) != 8 {
+		return 0, fmt.Errorf("expected 8 byte counter, got %v bytes", len(out))
+	}
+
+	return binary.BigEndian.Uint64(out), nil
+}
+
+type sequence struct {
+	Counter   uint64 `json:"counter"`
+	Signature string `json:"signature,omitempty"`
+	KeyID     string `json:"keyid,omitempty"`
+}
+
+// incrementSequence POSTs the counter's name to the sequence service, which responds with the counter's new value.
+func incrementSequence(ctx context.Context, source, name, token string) (sequence, error) {
+	body, err := json.Marshal(struct {
+		Name string `json:"name"`
+	}{name})
+	if err != nil {
+		return sequence{}, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, source, bytes.NewReader(body))
+	if err != nil {
+		return sequence{}, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return sequence{}, err
+	}
+
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return sequence{}, fmt.Errorf("unexpected status %v", resp.Status)
+	}
+
+	seq := sequence{}
+	if err := json.NewDecoder(resp.Body).Decode(&seq); err != nil {
+		return sequence{}, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return seq, nil
+}
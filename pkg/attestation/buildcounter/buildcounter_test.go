@@ -0,0 +1,85 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package buildcounter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestIncrementTPM(t *testing.T) {
+	oldRun := runCommand
+	defer func() { runCommand = oldRun }()
+	incremented := false
+	runCommand = func(ctx context.Context, name string, args ...string) ([]byte, error) {
+		switch strings.Join(append([]string{name}, args...), " ") {
+		case "tpm2_nvincrement -C o 0x1500016":
+			incremented = true
+			return nil, nil
+		case "tpm2_nvread -C o -s 8 0x1500016":
+			return []byte{0, 0, 0, 0, 0, 0, 1, 2}, nil
+		default:
+			return nil, fmt.Errorf("unexpected command")
+		}
+	}
+
+	counter, err := incrementTPM(context.Background(), "0x1500016")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !incremented || counter != 258 {
+		t.Errorf("expected counter to be incremented to 258, got %v", counter)
+	}
+}
+
+func TestIncrementSequence(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		body := struct {
+			Name string `json:"name"`
+		}{}
+
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Name != "release" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		fmt.Fprint(w, `{"counter": 42, "signature": "c2ln", "keyid": "kms-key"}`)
+	}))
+	defer server.Close()
+
+	seq, err := incrementSequence(context.Background(), server.URL, "release", "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if seq.Counter != 42 || seq.Signature != "c2ln" || seq.KeyID != "kms-key" {
+		t.Errorf("unexpected sequence: %+v", seq)
+	}
+
+	if _, err := incrementSequence(context.Background(), server.URL, "release", ""); err == nil {
+		t.Error("expected unauthorized request to fail")
+	}
+}
@@ -0,0 +1,391 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builderidentity
+
+import (
+	"encoding/json"
+	"fmt"
+	"runtime"
+	"strings"
+
+	"github.com/testifysec/go-witness/attestation"
+	"github.com/testifysec/go-witness/attestation/environment"
+	"github.com/testifysec/go-witness/attestation/gitlab"
+	"github.com/testifysec/go-witness/log"
+	"github.com/testifysec/witness/pkg/attestation/container"
+	"github.com/testifysec/witness/pkg/attestation/deadline"
+	"github.com/testifysec/witness/pkg/attestation/fingerprint"
+	"github.com/testifysec/witness/pkg/attestation/gitlabci"
+	"github.com/testifysec/witness/pkg/attestation/jenkins"
+)
+
+const (
+	Name    = "builder-identity"
+	Type    = "https://witness.dev/attestations/builder-identity/v0.1"
+	RunType = attestation.PostRunType
+
+	CloudAWS = "aws"
+	CloudGCP = "gcp"
+
+	CIGitLab        = "gitlab"
+	CIGitHubActions = "github-actions"
+	CIJenkins       = "jenkins"
+
+	// awsType and gcpType are the types of go-witness's aws and gcp-iit attestations. Their packages aren't imported so
+	// the cloud SDKs they depend on aren't needed to build this one.
+	awsType = "https://witness.dev/attestations/aws/v0.1"
+	gcpType = "https://witness.dev/attestations/gcp-iit/v0.1"
+)
+
+func init() {
+	attestation.RegisterAttestation(Name, Type, RunType, func() attestation.Attestor {
+		return New()
+	})
+}
+
+// Attestor assembles the identity of the builder from the host, cloud, container, and CI attestations recorded before
+// it, so a policy can constrain the builder as a single unit with a stable schema rather than across each attestor's
+// own. Only what those attestations recorded is included; nothing is read from the builder itself other than its
+// architecture.
+type Attestor struct {
+	// Sources are the types of the attestations the identity was assembled from.
+	Sources   []string   `json:"sources"`
+	Host      Host       `json:"host"`
+	Cloud     *Cloud     `json:"cloud,omitempty"`
+	Container *Container `json:"container,omitempty"`
+	CI        *CI        `json:"ci,omitempty"`
+}
+
+type Host struct {
+	OS       string `json:"os"`
+	Arch     string `json:"arch"`
+	Hostname string `json:"hostname,omitempty"`
+	Username string `json:"username,omitempty"`
+	// Fingerprint is the builder-fingerprint attestation's digest of the host's hardware and kernel.
+	Fingerprint string `json:"fingerprint,omitempty"`
+}
+
+type Cloud struct {
+	Provider string `json:"provider"`
+	// Account is the AWS account ID or the GCP project ID.
+	Account      string `json:"account"`
+	InstanceID   string `json:"instanceid"`
+	InstanceType string `json:"instancetype,omitempty"`
+	Region       string `json:"region,omitempty"`
+	Zone         string `json:"zone,omitempty"`
+	Image        string `json:"image,omitempty"`
+	Cluster      string `json:"cluster,omitempty"`
+}
+
+type Container struct {
+	Runtime     string `json:"runtime,omitempty"`
+	ID          string `json:"id,omitempty"`
+	Image       string `json:"image,omitempty"`
+	ImageDigest string `json:"imagedigest,omitempty"`
+}
+
+type CI struct {
+	Provider    string `json:"provider"`
+	Server      string `json:"server,omitempty"`
+	Project     string `json:"project,omitempty"`
+	PipelineID  string `json:"pipelineid,omitempty"`
+	PipelineURL string `json:"pipelineurl,omitempty"`
+	JobID       string `json:"jobid,omitempty"`
+	JobName     string `json:"jobname,omitempty"`
+	JobURL      string `json:"joburl,omitempty"`
+	RunnerID    string `json:"runnerid,omitempty"`
+	// Image is the image the CI provider ran the job in, as configured in the pipeline.
+	Image string `json:"image,omitempty"`
+}
+
+func New() *Attestor {
+	return &Attestor{Sources: []string{}}
+}
+
+func (a *Attestor) Name() string {
+	return Name
+}
+
+func (a *Attestor) Type() string {
+	return Type
+}
+
+func (a *Attestor) RunType() attestation.RunType {
+	return RunType
+}
+
+func (a *Attestor) Attest(ctx *attestation.AttestationContext) error {
+	return a.assemble(deadline.Completed(ctx))
+}
+
+// assemble reads the predicates of the attestors it knows among the completed ones. Each is read from the attestation
+// it marshals to, which is what the collection records.
+func (a *Attestor) assemble(completed []attestation.Attestor) error {
+	a.Host = Host{OS: runtime.GOOS, Arch: runtime.GOARCH}
+	var env map[string]string
+	for _, c := range completed {
+		attestor, finished := deadline.Unwrap(c)
+		if !finished {
+			continue
+		}
+
+		var err error
+		switch attestor.Type() {
+		case environment.Type:
+			env, err = a.readEnvironment(attestor)
+		case fingerprint.Type:
+			err = a.readFingerprint(attestor)
+		case awsType:
+			err = a.readAWS(attestor)
+		case gcpType:
+			err = a.readGCP(attestor)
+		case container.Type:
+			err = a.readContainer(attestor)
+		case gitlab.Type, gitlabci.Type:
+			err = a.readGitLab(attestor)
+		case jenkins.Type:
+			err = a.readJenkins(attestor)
+		default:
+			continue
+		}
+
+		if err != nil {
+			return fmt.Errorf("failed to read %v attestation: %w", attestor.Name(), err)
+		}
+
+		a.Sources = append(a.Sources, attestor.Type())
+	}
+
+	// GitHub Actions has no attestor of its own, so its run is read from the variables the environment attestor recorded
+	if a.CI == nil && env["GITHUB_ACTIONS"] == "true" {
+		a.CI = gitHubActions(env)
+	}
+
+	if len(a.Sources) == 0 {
+		log.Warnf("(attestation/builder-identity) no host, cloud, container, or CI attestations were recorded before it, only the os and architecture are known")
+	}
+
+	return nil
+}
+
+func decode(attestor attestation.Attestor, v interface{}) error {
+	data, err := json.Marshal(attestor)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, v)
+}
+
+func (a *Attestor) readEnvironment(attestor attestation.Attestor) (map[string]string, error) {
+	var env struct {
+		OS        string            `json:"os"`
+		Hostname  string            `json:"hostname"`
+		Username  string            `json:"username"`
+		Variables map[string]string `json:"variables"`
+	}
+
+	if err := decode(attestor, &env); err != nil {
+		return nil, err
+	}
+
+	if env.OS != "" {
+		a.Host.OS = env.OS
+	}
+
+	a.Host.Hostname = env.Hostname
+	a.Host.Username = env.Username
+	return env.Variables, nil
+}
+
+func (a *Attestor) readFingerprint(attestor attestation.Attestor) error {
+	var fp struct {
+		Fingerprint string `json:"fingerprint"`
+	}
+
+	if err := decode(attestor, &fp); err != nil {
+		return err
+	}
+
+	a.Host.Fingerprint = fp.Fingerprint
+	return nil
+}
+
+func (a *Attestor) readAWS(attestor attestation.Attestor) error {
+	var iid struct {
+		AccountID        string `json:"accountId"`
+		InstanceID       string `json:"instanceId"`
+		InstanceType     string `json:"instanceType"`
+		Region           string `json:"region"`
+		AvailabilityZone string `json:"availabilityZone"`
+		ImageID          string `json:"imageId"`
+	}
+
+	if err := decode(attestor, &iid); err != nil {
+		return err
+	}
+
+	a.Cloud = &Cloud{
+		Provider:     CloudAWS,
+		Account:      iid.AccountID,
+		InstanceID:   iid.InstanceID,
+		InstanceType: iid.InstanceType,
+		Region:       iid.Region,
+		Zone:         iid.AvailabilityZone,
+		Image:        iid.ImageID,
+	}
+
+	return nil
+}
+
+func (a *Attestor) readGCP(attestor attestation.Attestor) error {
+	var iit struct {
+		ProjectID   string `json:"project_id"`
+		InstanceID  string `json:"instance_id"`
+		Zone        string `json:"zone"`
+		ClusterName string `json:"cluster_name"`
+	}
+
+	if err := decode(attestor, &iit); err != nil {
+		return err
+	}
+
+	a.Cloud = &Cloud{
+		Provider:   CloudGCP,
+		Account:    iit.ProjectID,
+		InstanceID: iit.InstanceID,
+		Region:     gcpRegion(iit.Zone),
+		Zone:       iit.Zone,
+		Cluster:    iit.ClusterName,
+	}
+
+	return nil
+}
+
+// gcpRegion returns the region of a GCP zone, which is the zone without its last dash-separated part.
+func gcpRegion(zone string) string {
+	if i := strings.LastIndex(zone, "-"); i > 0 {
+		return zone[:i]
+	}
+
+	return zone
+}
+
+func (a *Attestor) readContainer(attestor attestation.Attestor) error {
+	var c struct {
+		InContainer bool   `json:"incontainer"`
+		Runtime     string `json:"runtime"`
+		ContainerID string `json:"containerid"`
+		Image       string `json:"image"`
+		ImageDigest string `json:"imagedigest"`
+	}
+
+	if err := decode(attestor, &c); err != nil {
+		return err
+	}
+
+	if c.InContainer {
+		a.Container = &Container{Runtime: c.Runtime, ID: c.ContainerID, Image: c.Image, ImageDigest: c.ImageDigest}
+	}
+
+	return nil
+}
+
+// readGitLab reads the gitlab attestation of go-witness or witness's own gitlab-ci attestation, which name the server's
+// URL differently.
+func (a *Attestor) readGitLab(attestor attestation.Attestor) error {
+	var gl struct {
+		CIServerUrl string `json:"ciserverurl"`
+		ServerURL   string `json:"serverurl"`
+		ProjectUrl  string `json:"projecturl"`
+		PipelineID  string `json:"pipelineid"`
+		PipelineUrl string `json:"pipelineurl"`
+		JobID       string `json:"jobid"`
+		JobName     string `json:"jobname"`
+		JobUrl      string `json:"joburl"`
+		RunnerID    string `json:"runnerid"`
+		JobImage    string `json:"jobimage"`
+	}
+
+	if err := decode(attestor, &gl); err != nil {
+		return err
+	}
+
+	server := gl.CIServerUrl
+	if server == "" {
+		server = gl.ServerURL
+	}
+
+	a.CI = &CI{
+		Provider:    CIGitLab,
+		Server:      server,
+		Project:     gl.ProjectUrl,
+		PipelineID:  gl.PipelineID,
+		PipelineURL: gl.PipelineUrl,
+		JobID:       gl.JobID,
+		JobName:     gl.JobName,
+		JobURL:      gl.JobUrl,
+		RunnerID:    gl.RunnerID,
+		Image:       gl.JobImage,
+	}
+
+	return nil
+}
+
+func (a *Attestor) readJenkins(attestor attestation.Attestor) error {
+	var j struct {
+		URL         string `json:"url"`
+		JobName     string `json:"jobname"`
+		JobURL      string `json:"joburl"`
+		BuildNumber string `json:"buildnumber"`
+		BuildURL    string `json:"buildurl"`
+		NodeName    string `json:"nodename"`
+	}
+
+	if err := decode(attestor, &j); err != nil {
+		return err
+	}
+
+	a.CI = &CI{
+		Provider:    CIJenkins,
+		Server:      j.URL,
+		Project:     j.JobURL,
+		PipelineID:  j.BuildNumber,
+		PipelineURL: j.BuildURL,
+		JobName:     j.JobName,
+		RunnerID:    j.NodeName,
+	}
+
+	return nil
+}
+
+func gitHubActions(env map[string]string) *CI {
+	ci := &CI{
+		Provider:   CIGitHubActions,
+		Server:     env["GITHUB_SERVER_URL"],
+		PipelineID: env["GITHUB_RUN_ID"],
+		JobName:    env["GITHUB_JOB"],
+		RunnerID:   env["RUNNER_NAME"],
+	}
+
+	if repository := env["GITHUB_REPOSITORY"]; repository != "" && ci.Server != "" {
+		ci.Project = strings.TrimSuffix(ci.Server, "/") + "/" + repository
+		if ci.PipelineID != "" {
+			ci.PipelineURL = ci.Project + "/actions/runs/" + ci.PipelineID
+		}
+	}
+
+	return ci
+}
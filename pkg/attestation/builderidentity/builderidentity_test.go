@@ -0,0 +1,228 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package builderidentity
+
+import (
+	"reflect"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/testifysec/go-witness/attestation"
+	"github.com/testifysec/go-witness/attestation/environment"
+	"github.com/testifysec/go-witness/attestation/gitlab"
+	"github.com/testifysec/witness/pkg/attestation/container"
+	"github.com/testifysec/witness/pkg/attestation/deadline"
+	"github.com/testifysec/witness/pkg/attestation/fingerprint"
+	"github.com/testifysec/witness/pkg/attestation/gitlabci"
+	"github.com/testifysec/witness/pkg/attestation/jenkins"
+)
+
+// awsAttestor stands in for go-witness's aws attestor, recording the fields of an instance identity document.
+type awsAttestor struct {
+	AccountID        string `json:"accountId"`
+	InstanceID       string `json:"instanceId"`
+	InstanceType     string `json:"instanceType"`
+	Region           string `json:"region"`
+	AvailabilityZone string `json:"availabilityZone"`
+	ImageID          string `json:"imageId"`
+	RawIID           string `json:"rawiid"`
+}
+
+func (a *awsAttestor) Name() string                                     { return "aws" }
+func (a *awsAttestor) Type() string                                     { return awsType }
+func (a *awsAttestor) RunType() attestation.RunType                     { return attestation.PreRunType }
+func (a *awsAttestor) Attest(ctx *attestation.AttestationContext) error { return nil }
+
+func TestAssemble(t *testing.T) {
+	env := environment.New()
+	env.OS = "linux"
+	env.Hostname = "runner-1"
+	env.Username = "builder"
+	fp := fingerprint.New()
+	fp.Fingerprint = "abc123"
+	aws := &awsAttestor{
+		AccountID:        "123456789012",
+		InstanceID:       "i-0abc",
+		InstanceType:     "m5.large",
+		Region:           "us-east-1",
+		AvailabilityZone: "us-east-1a",
+		ImageID:          "ami-0def",
+		RawIID:           "{}",
+	}
+
+	c := container.New()
+	c.InContainer = true
+	c.Runtime = "docker"
+	c.ContainerID = "0123"
+	c.Image = "golang:1.19"
+	c.ImageDigest = "sha256:aaaa"
+	gl := gitlab.New()
+	gl.CIServerUrl = "https://gitlab.com"
+	gl.ProjectUrl = "https://gitlab.com/group/project"
+	gl.PipelineID = "42"
+	gl.PipelineUrl = "https://gitlab.com/group/project/-/pipelines/42"
+	gl.JobID = "7"
+	gl.JobName = "build"
+	gl.JobUrl = "https://gitlab.com/group/project/-/jobs/7"
+	gl.RunnerID = "99"
+	gl.JobImage = "golang:1.19"
+
+	a := New()
+	if err := a.assemble([]attestation.Attestor{env, fp, aws, c, gl}); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := &Attestor{
+		Sources: []string{environment.Type, fingerprint.Type, awsType, container.Type, gitlab.Type},
+		Host:    Host{OS: "linux", Arch: runtime.GOARCH, Hostname: "runner-1", Username: "builder", Fingerprint: "abc123"},
+		Cloud: &Cloud{
+			Provider:     CloudAWS,
+			Account:      "123456789012",
+			InstanceID:   "i-0abc",
+			InstanceType: "m5.large",
+			Region:       "us-east-1",
+			Zone:         "us-east-1a",
+			Image:        "ami-0def",
+		},
+		Container: &Container{Runtime: "docker", ID: "0123", Image: "golang:1.19", ImageDigest: "sha256:aaaa"},
+		CI: &CI{
+			Provider:    CIGitLab,
+			Server:      "https://gitlab.com",
+			Project:     "https://gitlab.com/group/project",
+			PipelineID:  "42",
+			PipelineURL: "https://gitlab.com/group/project/-/pipelines/42",
+			JobID:       "7",
+			JobName:     "build",
+			JobURL:      "https://gitlab.com/group/project/-/jobs/7",
+			RunnerID:    "99",
+			Image:       "golang:1.19",
+		},
+	}
+
+	if !reflect.DeepEqual(a, expected) {
+		t.Errorf("expected %+v, got %+v", expected, a)
+	}
+}
+
+func TestAssembleGitHubActions(t *testing.T) {
+	env := environment.New()
+	env.OS = "linux"
+	env.Variables = map[string]string{
+		"GITHUB_ACTIONS":    "true",
+		"GITHUB_SERVER_URL": "https://github.com",
+		"GITHUB_REPOSITORY": "testifysec/witness",
+		"GITHUB_RUN_ID":     "1234",
+		"GITHUB_JOB":        "release",
+		"RUNNER_NAME":       "GitHub Actions 2",
+	}
+
+	c := container.New()
+	a := New()
+	if err := a.assemble([]attestation.Attestor{env, c}); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := &CI{
+		Provider:    CIGitHubActions,
+		Server:      "https://github.com",
+		Project:     "https://github.com/testifysec/witness",
+		PipelineID:  "1234",
+		PipelineURL: "https://github.com/testifysec/witness/actions/runs/1234",
+		JobName:     "release",
+		RunnerID:    "GitHub Actions 2",
+	}
+
+	if !reflect.DeepEqual(a.CI, expected) {
+		t.Errorf("expected %+v, got %+v", expected, a.CI)
+	}
+
+	if a.Container != nil {
+		t.Errorf("expected no container outside of one, got %+v", a.Container)
+	}
+}
+
+func TestAssembleUnwrapsBudget(t *testing.T) {
+	budget := deadline.NewBudget(time.Minute, 0)
+	finished := budget.Wrap(&awsAttestor{AccountID: "123456789012", InstanceID: "i-0abc"})
+	ctx, err := attestation.NewContext(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := finished.Attest(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	// an attestor that never ran within its budget isn't finished, so it isn't part of the identity
+	fp := fingerprint.New()
+	fp.Fingerprint = "abc123"
+	unfinished := budget.Wrap(fp)
+	a := New()
+	if err := a.assemble([]attestation.Attestor{finished, unfinished}); err != nil {
+		t.Fatal(err)
+	}
+
+	if a.Cloud == nil || a.Cloud.InstanceID != "i-0abc" || a.Host.Fingerprint != "" || !reflect.DeepEqual(a.Sources, []string{awsType}) {
+		t.Errorf("expected only the finished aws attestation, got %+v", a)
+	}
+}
+
+func TestGCPRegion(t *testing.T) {
+	if region := gcpRegion("us-central1-a"); region != "us-central1" {
+		t.Errorf("expected us-central1, got %v", region)
+	}
+}
+
+func TestAssembleCIAttestors(t *testing.T) {
+	gl := gitlabci.New()
+	gl.ServerURL = "https://gitlab.example.com"
+	gl.ProjectURL = "https://gitlab.example.com/group/app"
+	gl.PipelineID = "42"
+	a := New()
+	if err := a.assemble([]attestation.Attestor{gl}); err != nil {
+		t.Fatal(err)
+	}
+
+	if a.CI == nil || a.CI.Provider != CIGitLab || a.CI.Server != "https://gitlab.example.com" || a.CI.PipelineID != "42" {
+		t.Errorf("expected the gitlab-ci attestation's job, got %+v", a.CI)
+	}
+
+	j := jenkins.New()
+	j.URL = "https://jenkins.example.com/"
+	j.JobName = "app/main"
+	j.JobURL = "https://jenkins.example.com/job/app/job/main/"
+	j.BuildNumber = "31"
+	j.BuildURL = "https://jenkins.example.com/job/app/job/main/31/"
+	j.NodeName = "linux-agent-2"
+	a = New()
+	if err := a.assemble([]attestation.Attestor{j}); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := &CI{
+		Provider:    CIJenkins,
+		Server:      "https://jenkins.example.com/",
+		Project:     "https://jenkins.example.com/job/app/job/main/",
+		PipelineID:  "31",
+		PipelineURL: "https://jenkins.example.com/job/app/job/main/31/",
+		JobName:     "app/main",
+		RunnerID:    "linux-agent-2",
+	}
+
+	if !reflect.DeepEqual(a.CI, expected) {
+		t.Errorf("expected %+v, got %+v", expected, a.CI)
+	}
+}
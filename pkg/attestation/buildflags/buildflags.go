@@ -0,0 +1,217 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package buildflags
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/testifysec/go-witness/attestation"
+	"github.com/testifysec/go-witness/log"
+	"github.com/testifysec/witness/pkg/attestation/command"
+	"github.com/testifysec/witness/pkg/replay"
+)
+
+const (
+	Name    = "build-flags"
+	Type    = "https://witness.dev/attestations/build-flags/v0.1"
+	RunType = attestation.PostRunType
+
+	ToolchainGo    = "go"
+	ToolchainCargo = "cargo"
+	ToolchainC     = "c"
+)
+
+// runCommand is replaced in tests
+var runCommand = replay.Output
+
+// toolchainEnvironment are the environment variables that configure each toolchain's target and flags. Only these
+// are recorded, so credentials in the environment are never captured.
+var toolchainEnvironment = map[string][]string{
+	ToolchainGo: {
+		"GOOS", "GOARCH", "GOARM", "GOAMD64", "GO386", "GOMIPS", "GOPPC64", "GOWASM", "GOFLAGS", "GOEXPERIMENT",
+		"GOTOOLCHAIN", "CGO_ENABLED", "CGO_CFLAGS", "CGO_CPPFLAGS", "CGO_CXXFLAGS", "CGO_LDFLAGS", "CC", "CXX",
+		"SOURCE_DATE_EPOCH",
+	},
+	ToolchainCargo: {
+		"CARGO_BUILD_TARGET", "RUSTFLAGS", "CARGO_ENCODED_RUSTFLAGS", "CARGO_INCREMENTAL", "CC", "CXX",
+		"SOURCE_DATE_EPOCH",
+	},
+	ToolchainC: {
+		"CC", "CXX", "CFLAGS", "CXXFLAGS", "CPPFLAGS", "LDFLAGS", "CROSS_COMPILE", "SOURCE_DATE_EPOCH",
+	},
+}
+
+func init() {
+	attestation.RegisterAttestation(Name, Type, RunType, func() attestation.Attestor {
+		return New()
+	})
+}
+
+// Attestor records the target platform and flags of the Go, Cargo, or C compiler command witness ran, so policies
+// can require cross-compilation targets and reproducibility-friendly flags.
+type Attestor struct {
+	Toolchain string `json:"toolchain,omitempty"`
+	// Host is the os/arch witness ran on.
+	Host string `json:"host"`
+	// Target is the os/arch a Go build targeted, or the target triple given to cargo or a C compiler.
+	Target        string `json:"target,omitempty"`
+	CrossCompiled bool   `json:"crosscompiled"`
+	// Flags are the build flags from the command line, and from GOFLAGS for Go, in -name=value form.
+	Flags       []string          `json:"flags,omitempty"`
+	Environment map[string]string `json:"environment,omitempty"`
+	Go          *GoBuild          `json:"go,omitempty"`
+}
+
+func New() *Attestor {
+	return &Attestor{}
+}
+
+func (a *Attestor) Name() string {
+	return Name
+}
+
+func (a *Attestor) Type() string {
+	return Type
+}
+
+func (a *Attestor) RunType() attestation.RunType {
+	return RunType
+}
+
+func (a *Attestor) Attest(ctx *attestation.AttestationContext) error {
+	a.Host = runtime.GOOS + "/" + runtime.GOARCH
+	var cmd []string
+	if cr, ok := command.Run(ctx.CompletedAttestors()); ok {
+		cmd = cr.Cmd
+	}
+
+	if len(cmd) == 0 {
+		log.Debugf("(attestation/build-flags) no command was run")
+		return nil
+	}
+
+	tool := filepath.Base(cmd[0])
+	switch {
+	case tool == "go" && len(cmd) > 1 && isGoBuild(cmd[1]):
+		a.attestGo(ctx, cmd[2:])
+	case tool == "cargo" && len(cmd) > 1 && isCargoBuild(cmd[1]):
+		a.attestCargo(cmd[2:])
+	case isCCompiler(tool):
+		a.attestC(tool, cmd[1:])
+	default:
+		log.Debugf("(attestation/build-flags) %v is not a supported build command", strings.Join(cmd, " "))
+	}
+
+	return nil
+}
+
+func (a *Attestor) recordEnvironment(toolchain string) {
+	for _, name := range toolchainEnvironment[toolchain] {
+		value := os.Getenv(name)
+		if value == "" {
+			continue
+		}
+
+		if a.Environment == nil {
+			a.Environment = make(map[string]string)
+		}
+
+		a.Environment[name] = value
+	}
+}
+
+// normalizeFlags returns the options of a command line in -name=value form. Options in optionsWithValue take the next
+// argument as their value when it isn't attached. Arguments that aren't options are left out, as is everything after
+// a -- argument.
+func normalizeFlags(args []string, optionsWithValue map[string]bool) []string {
+	flags := make([]string, 0)
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			break
+		}
+
+		if !strings.HasPrefix(arg, "-") || arg == "-" {
+			continue
+		}
+
+		if strings.Contains(arg, "=") {
+			flags = append(flags, arg)
+			continue
+		}
+
+		if optionsWithValue[strings.TrimLeft(arg, "-")] && i+1 < len(args) {
+			flags = append(flags, arg+"="+args[i+1])
+			i++
+			continue
+		}
+
+		flags = append(flags, arg)
+	}
+
+	return flags
+}
+
+// flagValue returns the value of the last occurrence of a normalized flag, which is the one build tools use.
+func flagValue(flags []string, names ...string) (string, bool) {
+	value, found := "", false
+	for _, flag := range flags {
+		for _, name := range names {
+			if flag == name {
+				value, found = "", true
+			} else if strings.HasPrefix(flag, name+"=") {
+				value, found = strings.TrimPrefix(flag, name+"="), true
+			}
+		}
+	}
+
+	return value, found
+}
+
+// tripleNames are the names target triples use for go's architectures and operating systems.
+var tripleNames = map[string][]string{
+	"amd64":   {"x86_64", "amd64"},
+	"arm64":   {"aarch64", "arm64"},
+	"386":     {"i386", "i486", "i586", "i686"},
+	"arm":     {"arm"},
+	"riscv64": {"riscv64"},
+	"ppc64le": {"powerpc64le", "ppc64le"},
+	"s390x":   {"s390x"},
+	"linux":   {"linux"},
+	"darwin":  {"apple", "darwin"},
+	"windows": {"windows"},
+	"freebsd": {"freebsd"},
+}
+
+// matchesHost returns true if a target triple names the host's architecture and operating system.
+func matchesHost(triple string) bool {
+	parts := strings.Split(triple, "-")
+	has := func(goName string) bool {
+		for _, name := range tripleNames[goName] {
+			for _, part := range parts {
+				if strings.HasPrefix(part, name) {
+					return true
+				}
+			}
+		}
+
+		return false
+	}
+
+	return has(runtime.GOARCH) && has(runtime.GOOS)
+}
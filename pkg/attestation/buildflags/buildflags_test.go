@@ -0,0 +1,167 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package buildflags
+
+import (
+	"context"
+	"crypto"
+	"fmt"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/testifysec/go-witness/attestation"
+)
+
+// goVersionOutput is the output of go version -m for a binary built with -trimpath and a stripped build ID.
+const goVersionOutput = `/src/bin/app: go1.21.3
+	path	example.com/app
+	mod	example.com/app	(devel)	
+	dep	golang.org/x/sys	v0.13.0	h1:Af8nKPmuFypiUBjVoU9V20FiaFXOcuZI21p0ycVYYGE=
+	build	-buildmode=exe
+	build	-compiler=gc
+	build	-trimpath=true
+	build	-ldflags="-s -w -buildid="
+	build	CGO_ENABLED=0
+	build	GOARCH=arm64
+	build	GOOS=linux
+`
+
+func newContext(t *testing.T) *attestation.AttestationContext {
+	ctx, err := attestation.NewContext([]attestation.Attestor{}, attestation.WithWorkingDir(t.TempDir()), attestation.WithHashes([]crypto.Hash{crypto.SHA256}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return ctx
+}
+
+func TestNormalizeFlags(t *testing.T) {
+	flags := normalizeFlags([]string{"-trimpath", "-ldflags", "-s -w", "-o=bin/app", "-tags", "netgo", "./cmd/app", "--", "-v"}, goOptionsWithValue)
+	expected := []string{"-trimpath", "-ldflags=-s -w", "-o=bin/app", "-tags=netgo"}
+	if !reflect.DeepEqual(flags, expected) {
+		t.Errorf("expected %q, got %q", expected, flags)
+	}
+
+	if value, ok := flagValue(append(flags, "-ldflags=-buildid="), "-ldflags"); !ok || value != "-buildid=" {
+		t.Errorf("expected the last ldflags to win, got %q", value)
+	}
+}
+
+func TestAttestGo(t *testing.T) {
+	oldRun := runCommand
+	defer func() { runCommand = oldRun }()
+	runCommand = func(ctx context.Context, name string, args ...string) ([]byte, error) {
+		if strings.Join(args, " ") != "env GOOS GOARCH CGO_ENABLED GOFLAGS" {
+			return nil, fmt.Errorf("unexpected command %v %v", name, args)
+		}
+
+		return []byte("windows\narm64\n0\n-mod=readonly -trimpath\n"), nil
+	}
+
+	for _, name := range toolchainEnvironment[ToolchainGo] {
+		t.Setenv(name, "")
+	}
+
+	t.Setenv("GOOS", "windows")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "hunter2")
+	a := New()
+	a.Host = runtime.GOOS + "/" + runtime.GOARCH
+	a.attestGo(newContext(t), []string{"-ldflags", "-s -w -buildid=", "-tags=netgo,osusergo", "-o", "bin/app.exe", "./cmd/app"})
+	if a.Toolchain != ToolchainGo || a.Target != "windows/arm64" || a.CrossCompiled != (a.Host != "windows/arm64") {
+		t.Errorf("unexpected target: %+v", a)
+	}
+
+	expectedFlags := []string{"-mod=readonly", "-trimpath", "-ldflags=-s -w -buildid=", "-tags=netgo,osusergo", "-o=bin/app.exe"}
+	if !reflect.DeepEqual(a.Flags, expectedFlags) {
+		t.Errorf("expected flags %q, got %q", expectedFlags, a.Flags)
+	}
+
+	expectedBuild := &GoBuild{Trimpath: true, BuildIDStripped: true, LDFlags: "-s -w -buildid=", Tags: []string{"netgo", "osusergo"}}
+	if !reflect.DeepEqual(a.Go, expectedBuild) {
+		t.Errorf("expected %+v, got %+v", expectedBuild, a.Go)
+	}
+
+	if !reflect.DeepEqual(a.Environment, map[string]string{"GOOS": "windows"}) {
+		t.Errorf("expected only toolchain variables to be recorded, got %v", a.Environment)
+	}
+}
+
+func TestParseGoVersion(t *testing.T) {
+	binary, ok := parseGoVersion([]byte(goVersionOutput))
+	if !ok {
+		t.Fatal("expected the output to be parsed")
+	}
+
+	expected := map[string]string{
+		"-buildmode":  "exe",
+		"-compiler":   "gc",
+		"-trimpath":   "true",
+		"-ldflags":    "-s -w -buildid=",
+		"CGO_ENABLED": "0",
+		"GOARCH":      "arm64",
+		"GOOS":        "linux",
+	}
+
+	if binary.GoVersion != "go1.21.3" || !reflect.DeepEqual(binary.Settings, expected) {
+		t.Errorf("unexpected binary %+v", binary)
+	}
+
+	if _, ok := parseGoVersion([]byte("")); ok {
+		t.Error("expected empty output to be rejected")
+	}
+}
+
+func TestAttestCargo(t *testing.T) {
+	t.Setenv("RUSTFLAGS", "-C target-feature=+crt-static")
+	a := New()
+	a.attestCargo([]string{"--release", "--target", "riscv64gc-unknown-linux-gnu", "--locked"})
+	if a.Target != "riscv64gc-unknown-linux-gnu" || !a.CrossCompiled {
+		t.Errorf("expected a cross compiled target, got %+v", a)
+	}
+
+	if !reflect.DeepEqual(a.Flags, []string{"--release", "--target=riscv64gc-unknown-linux-gnu", "--locked"}) {
+		t.Errorf("unexpected flags %q", a.Flags)
+	}
+
+	if a.Environment["RUSTFLAGS"] != "-C target-feature=+crt-static" {
+		t.Errorf("expected RUSTFLAGS to be recorded, got %v", a.Environment)
+	}
+}
+
+func TestAttestC(t *testing.T) {
+	a := New()
+	a.attestC("aarch64-linux-gnu-gcc", []string{"-O2", "-ffile-prefix-map=/src=.", "-o", "app", "main.c"})
+	if a.Toolchain != ToolchainC || a.Target != "aarch64-linux-gnu" || a.CrossCompiled != !(runtime.GOARCH == "arm64" && runtime.GOOS == "linux") {
+		t.Errorf("unexpected target %+v", a)
+	}
+
+	if !reflect.DeepEqual(a.Flags, []string{"-O2", "-ffile-prefix-map=/src=.", "-o=app"}) {
+		t.Errorf("unexpected flags %q", a.Flags)
+	}
+
+	a = New()
+	a.attestC("clang", []string{"-target", "x86_64-apple-darwin", "-c", "main.c"})
+	if a.Target != "x86_64-apple-darwin" {
+		t.Errorf("expected the -target flag to set the target, got %v", a.Target)
+	}
+
+	for tool, ok := range map[string]bool{"cc": true, "x86_64-w64-mingw32-g++": true, "clang++": true, "ld": false, "gccgo": false} {
+		if isCCompiler(tool) != ok {
+			t.Errorf("expected isCCompiler(%v) to be %v", tool, ok)
+		}
+	}
+}
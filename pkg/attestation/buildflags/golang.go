@@ -0,0 +1,199 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package buildflags
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/testifysec/go-witness/attestation"
+	"github.com/testifysec/go-witness/log"
+)
+
+// goOptionsWithValue are the go build flags that take a value, which may be the next argument.
+var goOptionsWithValue = map[string]bool{
+	"C": true, "o": true, "p": true, "asmflags": true, "buildmode": true, "compiler": true, "gccgoflags": true,
+	"gcflags": true, "installsuffix": true, "ldflags": true, "mod": true, "modfile": true, "overlay": true,
+	"pgo": true, "pkgdir": true, "tags": true, "toolexec": true, "exec": true, "covermode": true, "coverpkg": true,
+}
+
+// GoBuild is the configuration of a go build, install, run, or test command.
+type GoBuild struct {
+	CGOEnabled bool `json:"cgoenabled"`
+	Trimpath   bool `json:"trimpath"`
+	// BuildIDStripped is true if the linker was told to write an empty build ID with -ldflags=-buildid=.
+	BuildIDStripped bool     `json:"buildidstripped"`
+	BuildMode       string   `json:"buildmode,omitempty"`
+	Tags            []string `json:"tags,omitempty"`
+	GCFlags         string   `json:"gcflags,omitempty"`
+	LDFlags         string   `json:"ldflags,omitempty"`
+	ASMFlags        string   `json:"asmflags,omitempty"`
+	// Binaries are the build settings embedded in the Go binaries among the products, keyed by path. They are read
+	// with go version -m, and are the settings the toolchain actually built each binary with.
+	Binaries map[string]GoBinary `json:"binaries,omitempty"`
+}
+
+type GoBinary struct {
+	GoVersion string            `json:"goversion"`
+	Settings  map[string]string `json:"settings"`
+}
+
+func isGoBuild(subcommand string) bool {
+	switch subcommand {
+	case "build", "install", "run", "test":
+		return true
+	default:
+		return false
+	}
+}
+
+func (a *Attestor) attestGo(ctx *attestation.AttestationContext, args []string) {
+	a.Toolchain = ToolchainGo
+	a.recordEnvironment(ToolchainGo)
+	env := goEnv(ctx)
+	goos, goarch := env["GOOS"], env["GOARCH"]
+	if goos == "" {
+		goos = runtime.GOOS
+	}
+
+	if goarch == "" {
+		goarch = runtime.GOARCH
+	}
+
+	a.Target = goos + "/" + goarch
+	a.CrossCompiled = a.Target != a.Host
+
+	// flags on the command line take precedence over GOFLAGS, and the last occurrence of a flag wins
+	a.Flags = append(normalizeFlags(strings.Fields(env["GOFLAGS"]), goOptionsWithValue), normalizeFlags(args, goOptionsWithValue)...)
+	build := &GoBuild{CGOEnabled: env["CGO_ENABLED"] == "1"}
+	if value, ok := flagValue(a.Flags, "-trimpath", "--trimpath"); ok {
+		build.Trimpath = value == "" || value == "true"
+	}
+
+	build.BuildMode, _ = flagValue(a.Flags, "-buildmode", "--buildmode")
+	build.GCFlags, _ = flagValue(a.Flags, "-gcflags", "--gcflags")
+	build.LDFlags, _ = flagValue(a.Flags, "-ldflags", "--ldflags")
+	build.ASMFlags, _ = flagValue(a.Flags, "-asmflags", "--asmflags")
+	if tags, ok := flagValue(a.Flags, "-tags", "--tags"); ok && tags != "" {
+		build.Tags = strings.FieldsFunc(tags, func(r rune) bool { return r == ',' || r == ' ' })
+	}
+
+	for _, field := range strings.Fields(build.LDFlags) {
+		if field == "-buildid=" || field == "--buildid=" {
+			build.BuildIDStripped = true
+		}
+	}
+
+	build.Binaries = goBinaries(ctx)
+	a.Go = build
+}
+
+// goEnv returns the target and cgo settings the go command builds with, which account for go env -w settings as well
+// as the environment. If the go command can't be run, the environment is used.
+func goEnv(ctx *attestation.AttestationContext) map[string]string {
+	names := []string{"GOOS", "GOARCH", "CGO_ENABLED", "GOFLAGS"}
+	env := make(map[string]string)
+	out, err := runCommand(ctx.Context(), "go", append([]string{"env"}, names...)...)
+	lines := strings.Split(strings.TrimRight(string(out), "\n"), "\n")
+	if err != nil || len(lines) != len(names) {
+		log.Debugf("(attestation/build-flags) failed to run go env, reading the environment instead: %v", err)
+		for _, name := range names {
+			env[name] = os.Getenv(name)
+		}
+
+		return env
+	}
+
+	for i, name := range names {
+		env[name] = lines[i]
+	}
+
+	return env
+}
+
+// goBinaries reads the build settings of the products that are Go binaries.
+func goBinaries(ctx *attestation.AttestationContext) map[string]GoBinary {
+	paths := make([]string, 0)
+	for path, product := range ctx.Products() {
+		if product.MimeType == "application/octet-stream" {
+			paths = append(paths, path)
+		}
+	}
+
+	sort.Strings(paths)
+	binaries := make(map[string]GoBinary)
+	for _, path := range paths {
+		fullPath := path
+		if !filepath.IsAbs(fullPath) {
+			fullPath = filepath.Join(ctx.WorkingDir(), path)
+		}
+
+		out, err := runCommand(ctx.Context(), "go", "version", "-m", fullPath)
+		if err != nil {
+			continue
+		}
+
+		if binary, ok := parseGoVersion(out); ok {
+			binaries[path] = binary
+		}
+	}
+
+	if len(binaries) == 0 {
+		return nil
+	}
+
+	return binaries
+}
+
+// parseGoVersion parses the go version and build settings from the output of go version -m for a single binary.
+func parseGoVersion(out []byte) (GoBinary, bool) {
+	binary := GoBinary{Settings: make(map[string]string)}
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "\t") {
+			if i := strings.LastIndex(line, ": "); i >= 0 {
+				binary.GoVersion = strings.TrimSpace(line[i+2:])
+			}
+
+			continue
+		}
+
+		fields := strings.SplitN(strings.TrimPrefix(line, "\t"), "\t", 2)
+		if len(fields) != 2 || fields[0] != "build" {
+			continue
+		}
+
+		setting := strings.SplitN(fields[1], "=", 2)
+		if len(setting) != 2 {
+			continue
+		}
+
+		value := setting[1]
+		if unquoted, err := strconv.Unquote(value); err == nil {
+			value = unquoted
+		}
+
+		binary.Settings[setting[0]] = value
+	}
+
+	return binary, binary.GoVersion != ""
+}
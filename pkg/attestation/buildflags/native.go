@@ -0,0 +1,91 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package buildflags
+
+import (
+	"os"
+	"strings"
+)
+
+// cargoOptionsWithValue are the cargo build options that take a value, which may be the next argument.
+var cargoOptionsWithValue = map[string]bool{
+	"target": true, "profile": true, "features": true, "F": true, "package": true, "p": true, "bin": true,
+	"example": true, "test": true, "bench": true, "manifest-path": true, "target-dir": true, "jobs": true, "j": true,
+	"config": true, "Z": true, "exclude": true, "message-format": true, "color": true,
+}
+
+// cOptionsWithValue are the C compiler options that take a value as the next argument.
+var cOptionsWithValue = map[string]bool{
+	"o": true, "target": true, "x": true, "include": true, "isystem": true, "MF": true, "MT": true, "MQ": true,
+	"Xlinker": true, "Xassembler": true, "Xpreprocessor": true,
+}
+
+// cCompilers are the names of C and C++ compilers, which may be prefixed with a target triple for cross compilers,
+// such as aarch64-linux-gnu-gcc.
+var cCompilers = []string{"cc", "c++", "gcc", "g++", "clang", "clang++"}
+
+func isCargoBuild(subcommand string) bool {
+	switch subcommand {
+	case "build", "rustc", "install", "test", "run":
+		return true
+	default:
+		return false
+	}
+}
+
+func isCCompiler(tool string) bool {
+	_, ok := cCompilerTriple(tool)
+	return ok
+}
+
+// cCompilerTriple returns the target triple a cross compiler's name is prefixed with, if any, and whether the name
+// is a C compiler's.
+func cCompilerTriple(tool string) (string, bool) {
+	for _, compiler := range cCompilers {
+		if tool == compiler {
+			return "", true
+		}
+
+		if strings.HasSuffix(tool, "-"+compiler) {
+			return strings.TrimSuffix(tool, "-"+compiler), true
+		}
+	}
+
+	return "", false
+}
+
+func (a *Attestor) attestCargo(args []string) {
+	a.Toolchain = ToolchainCargo
+	a.recordEnvironment(ToolchainCargo)
+	a.Flags = normalizeFlags(args, cargoOptionsWithValue)
+	a.Target, _ = flagValue(a.Flags, "--target")
+	if a.Target == "" {
+		a.Target = os.Getenv("CARGO_BUILD_TARGET")
+	}
+
+	a.CrossCompiled = a.Target != "" && !matchesHost(a.Target)
+}
+
+func (a *Attestor) attestC(tool string, args []string) {
+	a.Toolchain = ToolchainC
+	a.recordEnvironment(ToolchainC)
+	a.Flags = normalizeFlags(args, cOptionsWithValue)
+	a.Target, _ = cCompilerTriple(tool)
+	if target, ok := flagValue(a.Flags, "--target", "-target"); ok {
+		a.Target = target
+	}
+
+	a.CrossCompiled = a.Target != "" && !matchesHost(a.Target)
+}
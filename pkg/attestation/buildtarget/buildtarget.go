@@ -0,0 +1,144 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package buildtarget
+
+import (
+	"crypto"
+	"path/filepath"
+
+	"github.com/testifysec/go-witness/attestation"
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/log"
+	"github.com/testifysec/witness/pkg/attestation/command"
+	"github.com/testifysec/witness/pkg/replay"
+)
+
+const (
+	Name    = "build-target"
+	Type    = "https://witness.dev/attestations/build-target/v0.1"
+	RunType = attestation.PostRunType
+
+	ToolMake  = "make"
+	ToolCMake = "cmake"
+)
+
+// runCommand is replaced in tests
+var runCommand = replay.Output
+
+func init() {
+	attestation.RegisterAttestation(Name, Type, RunType, func() attestation.Attestor {
+		return New()
+	})
+}
+
+// Attestor records the targets, build files, and variables of the make or cmake command witness ran.
+type Attestor struct {
+	Tool string `json:"tool,omitempty"`
+	// Directory is the directory make ran in, or cmake's build directory.
+	Directory       string   `json:"directory,omitempty"`
+	SourceDirectory string   `json:"sourcedirectory,omitempty"`
+	Generator       string   `json:"generator,omitempty"`
+	Configuration   string   `json:"configuration,omitempty"`
+	Targets         []string `json:"targets,omitempty"`
+	// Files are the makefiles make read, or the CMakeLists.txt and .cmake files of the project cmake configured.
+	Files map[string]cryptoutil.DigestSet `json:"files,omitempty"`
+	// Variables are the variables set by makefiles and the command line, or the cmake cache entries.
+	Variables map[string]string `json:"variables,omitempty"`
+}
+
+func New() *Attestor {
+	return &Attestor{}
+}
+
+func (a *Attestor) Name() string {
+	return Name
+}
+
+func (a *Attestor) Type() string {
+	return Type
+}
+
+func (a *Attestor) RunType() attestation.RunType {
+	return RunType
+}
+
+func (a *Attestor) Attest(ctx *attestation.AttestationContext) error {
+	var cmd []string
+	if cr, ok := command.Run(ctx.CompletedAttestors()); ok {
+		cmd = cr.Cmd
+	}
+
+	if len(cmd) == 0 {
+		log.Debugf("(attestation/build-target) no command was run")
+		return nil
+	}
+
+	switch filepath.Base(cmd[0]) {
+	case "make", "gmake":
+		a.attestMake(ctx, cmd)
+	case "cmake":
+		a.attestCMake(ctx, cmd)
+	default:
+		log.Debugf("(attestation/build-target) %v is not make or cmake", cmd[0])
+	}
+
+	return nil
+}
+
+// addFile records the digest of a build file, keyed by its path relative to the working directory when it is inside
+// of it.
+func (a *Attestor) addFile(workingDir, path string, hashes []crypto.Hash) {
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(workingDir, path)
+	}
+
+	path = filepath.Clean(path)
+	data, err := replay.ReadFile(path)
+	if err != nil {
+		log.Debugf("(attestation/build-target) failed to read %v: %v", path, err)
+		return
+	}
+
+	digest, err := cryptoutil.CalculateDigestSetFromBytes(data, hashes)
+	if err != nil {
+		log.Debugf("(attestation/build-target) failed to digest %v: %v", path, err)
+		return
+	}
+
+	if a.Files == nil {
+		a.Files = make(map[string]cryptoutil.DigestSet)
+	}
+
+	a.Files[relativePath(workingDir, path)] = digest
+}
+
+// relativePath returns path relative to the working directory if it is inside of it.
+func relativePath(workingDir, path string) string {
+	if !within(workingDir, path) {
+		return path
+	}
+
+	rel, _ := filepath.Rel(workingDir, path)
+	return rel
+}
+
+// resolve joins a path from the command line to the directory the command ran in.
+func resolve(dir, path string) string {
+	if filepath.IsAbs(path) {
+		return filepath.Clean(path)
+	}
+
+	return filepath.Join(dir, path)
+}
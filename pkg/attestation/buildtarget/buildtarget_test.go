@@ -0,0 +1,248 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package buildtarget
+
+import (
+	"context"
+	"crypto"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/testifysec/go-witness/attestation"
+)
+
+// makeDatabase is an excerpt of make --print-data-base for a Makefile that includes common.mk.
+const makeDatabase = `# GNU Make 4.3
+# Variables
+
+# makefile
+MAKEFLAGS = pq --no-print-directory -- $(MAKEOVERRIDES)
+# makefile
+CURDIR := /src
+# makefile (from 'common.mk', line 1)
+VERSION = 1.2
+# makefile (from 'common.mk', line 1)
+MAKEFILE_LIST := Makefile common.mk
+# makefile (from 'Makefile', line 2)
+OPT := -O2
+# makefile
+.DEFAULT_GOAL := all
+# environment
+SECRET = hunter2
+# command line
+CFLAGS = -g
+# 'override' directive (from 'Makefile', line 5)
+DEBUG = 1
+# default
+CXX = g++
+
+# Files
+
+# makefile (from 'Makefile', line 9)
+all: main.o
+`
+
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func newContext(t *testing.T, dir string) *attestation.AttestationContext {
+	ctx, err := attestation.NewContext([]attestation.Attestor{}, attestation.WithWorkingDir(dir), attestation.WithHashes([]crypto.Hash{crypto.SHA256}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return ctx
+}
+
+func TestParseMakeArgs(t *testing.T) {
+	args := parseMakeArgs([]string{"-j", "8", "-C", "src", "-kf", "build.mk", "--include-dir=inc", "-r", "CFLAGS=-O2", "install", "-l4", "test"})
+	expected := makeArgs{
+		directories: []string{"src"},
+		makefiles:   []string{"build.mk"},
+		includeDirs: []string{"inc"},
+		flags:       []string{"-r"},
+		targets:     []string{"install", "test"},
+		variables:   []string{"CFLAGS=-O2"},
+	}
+
+	if !reflect.DeepEqual(args, expected) {
+		t.Fatalf("expected %+v, got %+v", expected, args)
+	}
+}
+
+func TestAttestMake(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"src/Makefile":  "include common.mk\nall: main.o\n",
+		"src/common.mk": "VERSION = 1.2\n",
+	})
+
+	oldRun := runCommand
+	defer func() { runCommand = oldRun }()
+	query := ""
+	runCommand = func(ctx context.Context, name string, args ...string) ([]byte, error) {
+		query = strings.Join(append([]string{name}, args...), " ")
+		return []byte(makeDatabase), fmt.Errorf("exit status 1")
+	}
+
+	a := New()
+	a.attestMake(newContext(t, dir), []string{"make", "-C", "src", "CFLAGS=-g"})
+	expectedQuery := fmt.Sprintf("make -C %v CFLAGS=-g --print-data-base --question --no-print-directory", filepath.Join(dir, "src"))
+	if query != expectedQuery {
+		t.Fatalf("expected query %q, got %q", expectedQuery, query)
+	}
+
+	if a.Tool != ToolMake || a.Directory != "src" {
+		t.Errorf("unexpected tool %v and directory %v", a.Tool, a.Directory)
+	}
+
+	if !reflect.DeepEqual(a.Targets, []string{"all"}) {
+		t.Errorf("expected the default goal to be the target, got %v", a.Targets)
+	}
+
+	expectedVariables := map[string]string{"VERSION": "1.2", "OPT": "-O2", "CFLAGS": "-g", "DEBUG": "1"}
+	if !reflect.DeepEqual(a.Variables, expectedVariables) {
+		t.Errorf("expected variables %v, got %v", expectedVariables, a.Variables)
+	}
+
+	for _, name := range []string{"src/Makefile", "src/common.mk"} {
+		if _, ok := a.Files[filepath.FromSlash(name)]; !ok {
+			t.Errorf("expected %v to be recorded, got %v", name, a.Files)
+		}
+	}
+}
+
+func TestAttestMakeWithoutDatabase(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"Makefile": "all:\n"})
+	oldRun := runCommand
+	defer func() { runCommand = oldRun }()
+	runCommand = func(ctx context.Context, name string, args ...string) ([]byte, error) {
+		return nil, fmt.Errorf("not found")
+	}
+
+	a := New()
+	a.attestMake(newContext(t, dir), []string{"gmake", "release"})
+	if !reflect.DeepEqual(a.Targets, []string{"release"}) {
+		t.Errorf("unexpected targets: %v", a.Targets)
+	}
+
+	if len(a.Files) != 1 || a.Files["Makefile"] == nil {
+		t.Errorf("expected the default makefile to be recorded, got %v", a.Files)
+	}
+}
+
+func TestParseCMakeArgs(t *testing.T) {
+	tests := []struct {
+		args     []string
+		expected cmakeArgs
+		ok       bool
+	}{
+		{
+			args:     []string{"-S", ".", "-Bbuild", "-G", "Ninja", "-DCMAKE_BUILD_TYPE=Release", "--preset", "ci"},
+			expected: cmakeArgs{sourceDir: ".", buildDir: "build", generator: "Ninja"},
+			ok:       true,
+		},
+		{
+			args:     []string{"--build", "build", "--target", "app", "tests", "--config", "Release", "-j", "4"},
+			expected: cmakeArgs{build: true, buildDir: "build", targets: []string{"app", "tests"}, configuration: "Release"},
+			ok:       true,
+		},
+		{
+			args:     []string{"..", "-DFOO=1"},
+			expected: cmakeArgs{path: ".."},
+			ok:       true,
+		},
+		{args: []string{"-E", "copy", "a", "b"}},
+		{args: []string{"--install", "build"}},
+	}
+
+	for i, test := range tests {
+		t.Run(fmt.Sprint(i), func(t *testing.T) {
+			args, ok := parseCMakeArgs(test.args)
+			if ok != test.ok {
+				t.Fatalf("expected ok to be %v", test.ok)
+			}
+
+			if ok && !reflect.DeepEqual(args, test.expected) {
+				t.Fatalf("expected %+v, got %+v", test.expected, args)
+			}
+		})
+	}
+}
+
+func TestAttestCMake(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"CMakeLists.txt":        "project(app)\ninclude(cmake/deps.cmake)\n",
+		"cmake/deps.cmake":      "set(DEPS_VERSION 1.0)\n",
+		"build/generated.cmake": "",
+		"build/CMakeCache.txt": "# This is the CMakeCache file.\n" +
+			"//Choose the type of build\nCMAKE_BUILD_TYPE:STRING=Release\n" +
+			"\"WITH SPACE\":BOOL=ON\n" +
+			"CMAKE_GENERATOR:INTERNAL=Unix Makefiles\n" +
+			"CMAKE_HOME_DIRECTORY:INTERNAL=" + dir + "\n",
+		"build/CMakeFiles/Makefile.cmake": "set(CMAKE_MAKEFILE_DEPENDS\n" +
+			"  \"CMakeCache.txt\"\n" +
+			"  \"" + filepath.Join(dir, "CMakeLists.txt") + "\"\n" +
+			"  \"" + filepath.Join(dir, "cmake", "deps.cmake") + "\"\n" +
+			"  \"generated.cmake\"\n" +
+			"  \"/usr/share/cmake/Modules/CMakeCInformation.cmake\"\n" +
+			"  )\n",
+	})
+
+	a := New()
+	a.attestCMake(newContext(t, dir), []string{"cmake", "--build", "build", "--target", "app"})
+	if a.Tool != ToolCMake || a.Directory != "build" || a.SourceDirectory != "." || a.Generator != "Unix Makefiles" {
+		t.Errorf("unexpected invocation: %+v", a)
+	}
+
+	if !reflect.DeepEqual(a.Targets, []string{"app"}) {
+		t.Errorf("unexpected targets: %v", a.Targets)
+	}
+
+	expectedVariables := map[string]string{"CMAKE_BUILD_TYPE": "Release", "WITH SPACE": "ON"}
+	if !reflect.DeepEqual(a.Variables, expectedVariables) {
+		t.Errorf("expected variables %v, got %v", expectedVariables, a.Variables)
+	}
+
+	if len(a.Files) != 2 || a.Files["CMakeLists.txt"] == nil || a.Files[filepath.Join("cmake", "deps.cmake")] == nil {
+		t.Errorf("expected only the project's list files to be recorded, got %v", a.Files)
+	}
+}
+
+func TestParseNinjaRerunDepends(t *testing.T) {
+	ninja := "rule RERUN_CMAKE\n  command = cmake --regenerate-during-build\n\n" +
+		"build build.ninja: RERUN_CMAKE | ../CMakeLists.txt ../my$ dir/x.cmake $\n    CMakeCache.txt || cmake_object_order_depends\n  pool = console\n"
+	files := parseNinjaRerunDepends([]byte(ninja))
+	expected := []string{"../CMakeLists.txt", "../my dir/x.cmake", "CMakeCache.txt"}
+	if !reflect.DeepEqual(files, expected) {
+		t.Fatalf("expected %v, got %v", expected, files)
+	}
+}
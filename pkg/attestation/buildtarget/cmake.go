@@ -0,0 +1,360 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package buildtarget
+
+import (
+	"bufio"
+	"bytes"
+	"path/filepath"
+	"strings"
+
+	"github.com/testifysec/go-witness/attestation"
+	"github.com/testifysec/go-witness/log"
+	"github.com/testifysec/witness/pkg/replay"
+)
+
+const cmakeCacheFile = "CMakeCache.txt"
+
+type cmakeArgs struct {
+	build         bool
+	sourceDir     string
+	buildDir      string
+	path          string
+	generator     string
+	configuration string
+	targets       []string
+}
+
+// cmakeIgnoredModes are the modes of cmake that neither configure nor build a project.
+var cmakeIgnoredModes = map[string]struct{}{
+	"-E":             {},
+	"-P":             {},
+	"-N":             {},
+	"--install":      {},
+	"--open":         {},
+	"--find-package": {},
+	"--workflow":     {},
+	"--help":         {},
+	"--version":      {},
+}
+
+// cmakeOptionsWithValue are the configure options that take a value as the next argument when it isn't attached.
+var cmakeOptionsWithValue = map[string]struct{}{
+	"-S": {},
+	"-B": {},
+	"-C": {},
+	"-D": {},
+	"-U": {},
+	"-G": {},
+	"-T": {},
+	"-A": {},
+}
+
+// cmakeLongOptionsWithValue are the long configure options that take a value as the next argument when it isn't
+// attached with =.
+var cmakeLongOptionsWithValue = map[string]struct{}{
+	"--preset":         {},
+	"--toolchain":      {},
+	"--install-prefix": {},
+}
+
+// parseCMakeArgs parses the directories, generator, targets, and configuration of a cmake command line that
+// configures or builds a project. It returns false for any other use of cmake.
+func parseCMakeArgs(args []string) (cmakeArgs, bool) {
+	parsed := cmakeArgs{}
+	if len(args) == 0 {
+		return parsed, false
+	}
+
+	if _, ok := cmakeIgnoredModes[args[0]]; ok {
+		return parsed, false
+	}
+
+	if args[0] == "--build" {
+		parsed.build = true
+		for i := 1; i < len(args); i++ {
+			arg := args[i]
+			switch {
+			case arg == "--":
+				return parsed, parsed.buildDir != ""
+			case arg == "--target" || arg == "-t":
+				for i+1 < len(args) && !strings.HasPrefix(args[i+1], "-") {
+					i++
+					parsed.targets = append(parsed.targets, args[i])
+				}
+			case strings.HasPrefix(arg, "--target="):
+				parsed.targets = append(parsed.targets, strings.TrimPrefix(arg, "--target="))
+			case arg == "--config" && i+1 < len(args):
+				i++
+				parsed.configuration = args[i]
+			case strings.HasPrefix(arg, "--config="):
+				parsed.configuration = strings.TrimPrefix(arg, "--config=")
+			case (arg == "--parallel" || arg == "-j") && i+1 < len(args) && isNumber(args[i+1]):
+				i++
+			case !strings.HasPrefix(arg, "-") && parsed.buildDir == "":
+				parsed.buildDir = arg
+			}
+		}
+
+		return parsed, parsed.buildDir != ""
+	}
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if _, ok := cmakeIgnoredModes[arg]; ok {
+			return parsed, false
+		}
+
+		if len(arg) < 2 || arg[0] != '-' {
+			parsed.path = arg
+			continue
+		}
+
+		if _, ok := cmakeLongOptionsWithValue[arg]; ok {
+			i++
+			continue
+		}
+
+		option, value := arg[:2], arg[2:]
+		if _, ok := cmakeOptionsWithValue[option]; !ok {
+			continue
+		}
+
+		if value == "" && i+1 < len(args) {
+			i++
+			value = args[i]
+		}
+
+		switch option {
+		case "-S":
+			parsed.sourceDir = value
+		case "-B":
+			parsed.buildDir = value
+		case "-G":
+			parsed.generator = value
+		}
+	}
+
+	return parsed, true
+}
+
+func (a *Attestor) attestCMake(ctx *attestation.AttestationContext, cmd []string) {
+	args, ok := parseCMakeArgs(cmd[1:])
+	if !ok {
+		log.Debugf("(attestation/build-target) cmake did not configure or build a project")
+		return
+	}
+
+	wd := ctx.WorkingDir()
+	buildDir := ""
+	sourceDir := ""
+	switch {
+	case args.build:
+		buildDir = resolve(wd, args.buildDir)
+	case args.buildDir != "":
+		buildDir = resolve(wd, args.buildDir)
+		if args.sourceDir != "" {
+			sourceDir = resolve(wd, args.sourceDir)
+		} else if args.path != "" {
+			sourceDir = resolve(wd, args.path)
+		}
+	case args.sourceDir != "":
+		buildDir, sourceDir = wd, resolve(wd, args.sourceDir)
+	case args.path != "" && replay.Exists(filepath.Join(resolve(wd, args.path), cmakeCacheFile)):
+		// an existing build directory is being configured again
+		buildDir = resolve(wd, args.path)
+	case args.path != "":
+		buildDir, sourceDir = wd, resolve(wd, args.path)
+	default:
+		log.Debugf("(attestation/build-target) could not determine cmake's build directory")
+		return
+	}
+
+	a.Tool = ToolCMake
+	a.Directory = relativePath(wd, buildDir)
+	a.Generator = args.generator
+	a.Configuration = args.configuration
+	a.Targets = args.targets
+
+	cache, err := replay.ReadFile(filepath.Join(buildDir, cmakeCacheFile))
+	if err != nil {
+		log.Debugf("(attestation/build-target) failed to read cmake cache: %v", err)
+		return
+	}
+
+	variables, internal := parseCMakeCache(cache)
+	if len(variables) > 0 {
+		a.Variables = variables
+	}
+
+	if sourceDir == "" {
+		sourceDir = internal["CMAKE_HOME_DIRECTORY"]
+	}
+
+	if a.Generator == "" {
+		a.Generator = internal["CMAKE_GENERATOR"]
+	}
+
+	if sourceDir == "" {
+		return
+	}
+
+	a.SourceDirectory = relativePath(wd, sourceDir)
+	for _, listFile := range cmakeListFiles(buildDir) {
+		listFile = resolve(buildDir, listFile)
+		if !within(sourceDir, listFile) || (buildDir != sourceDir && within(buildDir, listFile)) {
+			continue
+		}
+
+		a.addFile(wd, listFile, ctx.Hashes())
+	}
+}
+
+// parseCMakeCache returns the entries of a CMakeCache.txt. Entries of the INTERNAL and STATIC types are returned
+// separately, since they describe cmake's own state rather than the project's configuration.
+func parseCMakeCache(data []byte) (map[string]string, map[string]string) {
+	variables := make(map[string]string)
+	internal := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "//") {
+			continue
+		}
+
+		idx := strings.Index(line, "=")
+		if idx < 0 {
+			continue
+		}
+
+		key, value := line[:idx], line[idx+1:]
+		typeIdx := strings.LastIndex(key, ":")
+		if typeIdx < 0 {
+			continue
+		}
+
+		name, entryType := strings.Trim(key[:typeIdx], `"`), key[typeIdx+1:]
+		if entryType == "INTERNAL" || entryType == "STATIC" {
+			internal[name] = value
+			continue
+		}
+
+		variables[name] = value
+	}
+
+	return variables, internal
+}
+
+// cmakeListFiles returns the files cmake read while configuring the build directory, as listed by the Makefile or
+// Ninja generator so it can rerun cmake when they change.
+func cmakeListFiles(buildDir string) []string {
+	if data, err := replay.ReadFile(filepath.Join(buildDir, "CMakeFiles", "Makefile.cmake")); err == nil {
+		return parseMakefileDepends(data)
+	}
+
+	if data, err := replay.ReadFile(filepath.Join(buildDir, "build.ninja")); err == nil {
+		return parseNinjaRerunDepends(data)
+	}
+
+	log.Debugf("(attestation/build-target) cmake generator does not list the files it read")
+	return nil
+}
+
+// parseMakefileDepends returns the files of the CMAKE_MAKEFILE_DEPENDS list written by the Makefile generator.
+func parseMakefileDepends(data []byte) []string {
+	files := []string{}
+	inDepends := false
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "set(CMAKE_MAKEFILE_DEPENDS" {
+			inDepends = true
+			continue
+		}
+
+		if !inDepends {
+			continue
+		}
+
+		if line == ")" {
+			break
+		}
+
+		files = append(files, strings.Trim(line, `"`))
+	}
+
+	return files
+}
+
+// parseNinjaRerunDepends returns the implicit inputs of the build statement that reruns cmake in a build.ninja.
+func parseNinjaRerunDepends(data []byte) []string {
+	// $ followed by a newline continues the line
+	data = bytes.ReplaceAll(data, []byte("$\r\n"), nil)
+	data = bytes.ReplaceAll(data, []byte("$\n"), nil)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, "build ") || !strings.Contains(line, ": RERUN_CMAKE") {
+			continue
+		}
+
+		idx := strings.Index(line, "|")
+		if idx < 0 {
+			return nil
+		}
+
+		inputs := line[idx+1:]
+		if orderOnly := strings.Index(inputs, "||"); orderOnly >= 0 {
+			inputs = inputs[:orderOnly]
+		}
+
+		return splitNinjaPaths(inputs)
+	}
+
+	return nil
+}
+
+// splitNinjaPaths splits a list of ninja paths on unescaped spaces, unescaping $ , $:, and $$.
+func splitNinjaPaths(s string) []string {
+	paths := []string{}
+	current := strings.Builder{}
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '$' && i+1 < len(s):
+			i++
+			current.WriteByte(s[i])
+		case s[i] == ' ' || s[i] == '\t':
+			if current.Len() > 0 {
+				paths = append(paths, current.String())
+				current.Reset()
+			}
+		default:
+			current.WriteByte(s[i])
+		}
+	}
+
+	if current.Len() > 0 {
+		paths = append(paths, current.String())
+	}
+
+	return paths
+}
+
+func within(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
@@ -0,0 +1,281 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package buildtarget
+
+import (
+	"bufio"
+	"bytes"
+	"regexp"
+	"strings"
+
+	"github.com/testifysec/go-witness/attestation"
+	"github.com/testifysec/go-witness/log"
+	"github.com/testifysec/witness/pkg/replay"
+)
+
+// defaultMakefiles are the makefiles GNU make reads, in order, when none are given with -f.
+var defaultMakefiles = []string{"GNUmakefile", "makefile", "Makefile"}
+
+// makeVariablePattern matches variable definitions in the output of make --print-data-base.
+var makeVariablePattern = regexp.MustCompile(`^([^\s:#=]+)\s*(?:=|:=|::=)\s?(.*)$`)
+
+// makeRecordedOrigins are the origins of the variables that are recorded. Environment variables are left out since
+// they may hold credentials, and variables make defines itself describe the query rather than the build.
+var makeRecordedOrigins = []string{"# makefile (from ", "# command line", "# 'override' directive"}
+
+type makeArgs struct {
+	directories []string
+	makefiles   []string
+	includeDirs []string
+	evals       []string
+	flags       []string
+	targets     []string
+	variables   []string
+}
+
+// makeOptionsWithValue are the short options that require a value, which may be attached or the next argument.
+const makeOptionsWithValue = "CfIoWE"
+
+var makeLongOptionsWithValue = map[string]byte{
+	"directory":   'C',
+	"file":        'f',
+	"makefile":    'f',
+	"include-dir": 'I',
+	"old-file":    'o',
+	"assume-old":  'o',
+	"what-if":     'W',
+	"new-file":    'W',
+	"assume-new":  'W',
+	"eval":        'E',
+}
+
+// makeDatabaseFlags change the variables make defines, so they're passed along when reading its database.
+var makeDatabaseFlags = map[string]string{
+	"r":                     "-r",
+	"R":                     "-R",
+	"e":                     "-e",
+	"no-builtin-rules":      "-r",
+	"no-builtin-variables":  "-R",
+	"environment-overrides": "-e",
+}
+
+// parseMakeArgs parses the options, variable assignments, and targets of a GNU make command line.
+func parseMakeArgs(args []string) makeArgs {
+	parsed := makeArgs{}
+	add := func(option byte, value string) {
+		switch option {
+		case 'C':
+			parsed.directories = append(parsed.directories, value)
+		case 'f':
+			parsed.makefiles = append(parsed.makefiles, value)
+		case 'I':
+			parsed.includeDirs = append(parsed.includeDirs, value)
+		case 'E':
+			parsed.evals = append(parsed.evals, value)
+		}
+	}
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case arg == "--":
+			for _, rest := range args[i+1:] {
+				parsed.addOperand(rest)
+			}
+
+			return parsed
+
+		case strings.HasPrefix(arg, "--"):
+			name := strings.TrimPrefix(arg, "--")
+			value := ""
+			hasValue := false
+			if idx := strings.Index(name, "="); idx >= 0 {
+				name, value, hasValue = name[:idx], name[idx+1:], true
+			}
+
+			if option, ok := makeLongOptionsWithValue[name]; ok {
+				if !hasValue && i+1 < len(args) {
+					i++
+					value = args[i]
+				}
+
+				add(option, value)
+			} else if flag, ok := makeDatabaseFlags[name]; ok {
+				parsed.flags = append(parsed.flags, flag)
+			}
+
+		case strings.HasPrefix(arg, "-") && len(arg) > 1:
+			for j := 1; j < len(arg); j++ {
+				option := arg[j]
+				if strings.IndexByte(makeOptionsWithValue, option) >= 0 {
+					value := arg[j+1:]
+					if value == "" && i+1 < len(args) {
+						i++
+						value = args[i]
+					}
+
+					add(option, value)
+					break
+				}
+
+				// -j and -l take an optional number
+				if option == 'j' || option == 'l' {
+					if j+1 == len(arg) && i+1 < len(args) && isNumber(args[i+1]) {
+						i++
+					}
+
+					break
+				}
+
+				if flag, ok := makeDatabaseFlags[string(option)]; ok {
+					parsed.flags = append(parsed.flags, flag)
+				}
+			}
+
+		default:
+			parsed.addOperand(arg)
+		}
+	}
+
+	return parsed
+}
+
+func (m *makeArgs) addOperand(arg string) {
+	if strings.Contains(arg, "=") {
+		m.variables = append(m.variables, arg)
+		return
+	}
+
+	m.targets = append(m.targets, arg)
+}
+
+func isNumber(s string) bool {
+	if s == "" {
+		return false
+	}
+
+	for _, r := range s {
+		if (r < '0' || r > '9') && r != '.' {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (a *Attestor) attestMake(ctx *attestation.AttestationContext, cmd []string) {
+	args := parseMakeArgs(cmd[1:])
+	dir := ctx.WorkingDir()
+	for _, d := range args.directories {
+		dir = resolve(dir, d)
+	}
+
+	a.Tool = ToolMake
+	a.Directory = relativePath(ctx.WorkingDir(), dir)
+	a.Targets = args.targets
+
+	// make --print-data-base --question reads the makefiles and prints every rule and variable without running any
+	// recipes. It exits with a non-zero code if the targets are out of date, so its output is used regardless.
+	query := []string{"-C", dir}
+	for _, makefile := range args.makefiles {
+		query = append(query, "-f", makefile)
+	}
+
+	for _, includeDir := range args.includeDirs {
+		query = append(query, "-I", includeDir)
+	}
+
+	for _, eval := range args.evals {
+		query = append(query, "--eval", eval)
+	}
+
+	query = append(query, args.flags...)
+	query = append(query, args.variables...)
+	query = append(query, "--print-data-base", "--question", "--no-print-directory")
+	out, err := runCommand(ctx.Context(), cmd[0], query...)
+	if err != nil {
+		log.Debugf("(attestation/build-target) make exited with %v while reading its database", err)
+	}
+
+	variables, makefiles, defaultGoal := parseMakeDatabase(out)
+	if len(variables) > 0 {
+		a.Variables = variables
+	}
+
+	if len(a.Targets) == 0 && defaultGoal != "" {
+		a.Targets = []string{defaultGoal}
+	}
+
+	if len(makefiles) == 0 {
+		makefiles = args.makefiles
+	}
+
+	if len(makefiles) == 0 {
+		for _, name := range defaultMakefiles {
+			if replay.Exists(resolve(dir, name)) {
+				makefiles = []string{name}
+				break
+			}
+		}
+	}
+
+	for _, makefile := range makefiles {
+		a.addFile(ctx.WorkingDir(), resolve(dir, makefile), ctx.Hashes())
+	}
+}
+
+// parseMakeDatabase returns the variables defined by makefiles and the command line, the makefiles make read from
+// MAKEFILE_LIST, and the default goal from the output of make --print-data-base.
+func parseMakeDatabase(out []byte) (map[string]string, []string, string) {
+	variables := make(map[string]string)
+	makefiles := []string{}
+	defaultGoal := ""
+	origin := ""
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "#") {
+			origin = line
+			continue
+		}
+
+		currentOrigin := origin
+		origin = ""
+		matches := makeVariablePattern.FindStringSubmatch(line)
+		if matches == nil {
+			continue
+		}
+
+		name, value := matches[1], matches[2]
+		switch name {
+		case ".DEFAULT_GOAL":
+			defaultGoal = strings.TrimSpace(value)
+			continue
+		case "MAKEFILE_LIST":
+			makefiles = strings.Fields(value)
+			continue
+		}
+
+		for _, recorded := range makeRecordedOrigins {
+			if strings.HasPrefix(currentOrigin, recorded) {
+				variables[name] = value
+				break
+			}
+		}
+	}
+
+	return variables, makefiles, defaultGoal
+}
@@ -0,0 +1,44 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package command finds the command-run attestation of the command witness run recorded, whichever attestor ran the
+// command.
+package command
+
+import (
+	"github.com/testifysec/go-witness/attestation"
+	"github.com/testifysec/go-witness/attestation/commandrun"
+)
+
+// Runner is implemented by command attestors that run the command in place of the command-run attestor, such as the
+// syscall-trace attestor's traced command. CommandRun returns the command-run attestation they recorded.
+type Runner interface {
+	CommandRun() *commandrun.CommandRun
+}
+
+// Run returns the command-run attestation among the completed attestors, or false if no command was run.
+func Run(completed []attestation.Attestor) (*commandrun.CommandRun, bool) {
+	for _, attestor := range completed {
+		switch a := attestor.(type) {
+		case *commandrun.CommandRun:
+			return a, true
+		case Runner:
+			if cr := a.CommandRun(); cr != nil {
+				return cr, true
+			}
+		}
+	}
+
+	return nil, false
+}
@@ -0,0 +1,50 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package command
+
+import (
+	"testing"
+
+	"github.com/testifysec/go-witness/attestation"
+	"github.com/testifysec/go-witness/attestation/commandrun"
+	"github.com/testifysec/go-witness/attestation/environment"
+)
+
+type runner struct {
+	*environment.Attestor
+	cr *commandrun.CommandRun
+}
+
+func (r runner) CommandRun() *commandrun.CommandRun {
+	return r.cr
+}
+
+func TestRun(t *testing.T) {
+	cr := commandrun.New(commandrun.WithCommand([]string{"go", "build"}))
+	found, ok := Run([]attestation.Attestor{environment.New(), cr})
+	if !ok || found != cr {
+		t.Fatalf("expected the command-run attestor, got %v %v", found, ok)
+	}
+
+	traced := commandrun.New(commandrun.WithCommand([]string{"make"}))
+	found, ok = Run([]attestation.Attestor{runner{Attestor: environment.New(), cr: traced}})
+	if !ok || found != traced {
+		t.Fatalf("expected the runner's command-run attestation, got %v %v", found, ok)
+	}
+
+	if _, ok := Run([]attestation.Attestor{environment.New(), runner{Attestor: environment.New()}}); ok {
+		t.Fatal("expected no command-run attestation")
+	}
+}
@@ -0,0 +1,387 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package container
+
+import (
+	"bufio"
+	"context"
+	"crypto"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/testifysec/go-witness/attestation"
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/log"
+	"github.com/testifysec/witness/pkg/replay"
+)
+
+const (
+	Name    = "container"
+	Type    = "https://witness.dev/attestations/container/v0.1"
+	RunType = attestation.PreRunType
+
+	// ImageEnv can be set to the container's image, for example from the Kubernetes downward API or by the runner that
+	// started the container.
+	ImageEnv = "WITNESS_CONTAINER_IMAGE"
+)
+
+// root and dockerSocket are replaced in tests
+var (
+	root         = "/"
+	dockerSocket = "/var/run/docker.sock"
+)
+
+var (
+	containerIDPattern = regexp.MustCompile(`[0-9a-f]{64}`)
+	digestPattern      = regexp.MustCompile(`sha256:([0-9a-f]{64})`)
+)
+
+// Attestor records the container witness is running in: its image, the cgroup limits applied to it, and the overlay
+// filesystem layers its root filesystem is built from. It answers which image a step ran on without relying on
+// attestors for a particular CI provider.
+type Attestor struct {
+	InContainer bool   `json:"incontainer"`
+	Runtime     string `json:"runtime,omitempty"`
+	ContainerID string `json:"containerid,omitempty"`
+	Image       string `json:"image,omitempty"`
+	// ImageDigest is the sha256 digest of the image's manifest or config, as reported by the runtime.
+	ImageDigest string         `json:"imagedigest,omitempty"`
+	Limits      *CgroupLimits  `json:"limits,omitempty"`
+	Overlay     *OverlayLayers `json:"overlay,omitempty"`
+}
+
+// CgroupLimits are the resource limits of the container's cgroup. Unset limits are omitted.
+type CgroupLimits struct {
+	Version     int    `json:"version"`
+	MemoryBytes int64  `json:"memorybytes,omitempty"`
+	CPUQuota    string `json:"cpuquota,omitempty"`
+	Pids        int64  `json:"pids,omitempty"`
+}
+
+// OverlayLayers are the directories of the overlay filesystem mounted as the container's root.
+type OverlayLayers struct {
+	LowerDirs []string `json:"lowerdirs"`
+	UpperDir  string   `json:"upperdir,omitempty"`
+}
+
+func New() *Attestor {
+	return &Attestor{}
+}
+
+func (a *Attestor) Name() string {
+	return Name
+}
+
+func (a *Attestor) Type() string {
+	return Type
+}
+
+func (a *Attestor) RunType() attestation.RunType {
+	return RunType
+}
+
+// Available returns an error if the process's cgroups can't be read, as in sandboxes that don't mount /proc.
+func (a *Attestor) Available() error {
+	if _, err := os.Stat(hostPath("/proc/self/cgroup")); err != nil {
+		return fmt.Errorf("cgroups of the process are not readable: %w", err)
+	}
+
+	return nil
+}
+
+func (a *Attestor) Attest(ctx *attestation.AttestationContext) error {
+	a.Runtime = detectRuntime()
+	a.InContainer = a.Runtime != ""
+	if !a.InContainer {
+		return nil
+	}
+
+	a.ContainerID = containerID()
+	a.Image = os.Getenv(ImageEnv)
+	if a.Runtime == "podman" {
+		a.readContainerEnv()
+	}
+
+	if a.ContainerID != "" && a.ImageDigest == "" {
+		if err := a.inspectDocker(ctx.Context()); err != nil {
+			log.Debugf("(attestation/container) failed to inspect container with docker: %v", err)
+		}
+	}
+
+	if a.ImageDigest == "" {
+		if match := digestPattern.FindStringSubmatch(a.Image); match != nil {
+			a.ImageDigest = match[1]
+		}
+	}
+
+	a.Limits = cgroupLimits()
+	a.Overlay = overlayLayers()
+	return nil
+}
+
+func (a *Attestor) Subjects() map[string]cryptoutil.DigestSet {
+	subjects := make(map[string]cryptoutil.DigestSet)
+	if a.ImageDigest != "" {
+		subjects[fmt.Sprintf("containerimage:%v", a.ImageDigest)] = cryptoutil.DigestSet{crypto.SHA256: a.ImageDigest}
+	}
+
+	return subjects
+}
+
+func hostPath(path string) string {
+	return filepath.Join(root, path)
+}
+
+func readHostFile(path string) string {
+	data, err := replay.ReadFile(hostPath(path))
+	if err != nil {
+		return ""
+	}
+
+	return string(data)
+}
+
+// detectRuntime returns the container runtime witness is running under, or an empty string if it isn't in a container.
+func detectRuntime() string {
+	if replay.Exists(hostPath("/run/.containerenv")) {
+		return "podman"
+	}
+
+	if replay.Exists(hostPath("/.dockerenv")) {
+		return "docker"
+	}
+
+	cgroup := readHostFile("/proc/1/cgroup")
+	switch {
+	case strings.Contains(cgroup, "kubepods"):
+		return "kubernetes"
+	case strings.Contains(cgroup, "docker"):
+		return "docker"
+	case strings.Contains(cgroup, "containerd"):
+		return "containerd"
+	case strings.Contains(cgroup, "libpod"):
+		return "podman"
+	}
+
+	if os.Getenv("KUBERNETES_SERVICE_HOST") != "" {
+		return "kubernetes"
+	}
+
+	return ""
+}
+
+// containerID finds the container's id in its cgroup path or, with cgroup v2 namespaces hiding the path, in the
+// mounts the runtime makes into the container.
+func containerID() string {
+	if id := containerIDPattern.FindString(readHostFile("/proc/self/cgroup")); id != "" {
+		return id
+	}
+
+	scanner := bufio.NewScanner(strings.NewReader(readHostFile("/proc/self/mountinfo")))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 5 || (fields[4] != "/etc/hostname" && fields[4] != "/etc/hosts") {
+			continue
+		}
+
+		if id := containerIDPattern.FindString(fields[3]); id != "" {
+			return id
+		}
+	}
+
+	return ""
+}
+
+// readContainerEnv reads the image podman records in /run/.containerenv.
+func (a *Attestor) readContainerEnv() {
+	scanner := bufio.NewScanner(strings.NewReader(readHostFile("/run/.containerenv")))
+	for scanner.Scan() {
+		parts := strings.SplitN(scanner.Text(), "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+
+		value := strings.Trim(parts[1], `"`)
+		switch parts[0] {
+		case "id":
+			a.ContainerID = value
+		case "image":
+			if a.Image == "" {
+				a.Image = value
+			}
+		case "imageid":
+			a.ImageDigest = value
+		}
+	}
+}
+
+// inspectDocker asks the docker daemon, if its socket is mounted into the container, for the container's image.
+func (a *Attestor) inspectDocker(ctx context.Context) error {
+	if !replay.Exists(dockerSocket) {
+		return fmt.Errorf("docker socket %v not found", dockerSocket)
+	}
+
+	client := &http.Client{
+		Timeout: 5 * time.Second,
+		Transport: replay.Transport(&http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, "unix", dockerSocket)
+			},
+		}),
+	}
+
+	container := struct {
+		Image  string `json:"Image"`
+		Config struct {
+			Image string `json:"Image"`
+		} `json:"Config"`
+	}{}
+
+	if err := dockerGet(ctx, client, "/containers/"+a.ContainerID+"/json", &container); err != nil {
+		return err
+	}
+
+	if a.Image == "" {
+		a.Image = container.Config.Image
+	}
+
+	image := struct {
+		RepoDigests []string `json:"RepoDigests"`
+	}{}
+
+	if err := dockerGet(ctx, client, "/images/"+container.Image+"/json", &image); err == nil && len(image.RepoDigests) > 0 {
+		if match := digestPattern.FindStringSubmatch(image.RepoDigests[0]); match != nil {
+			a.Image = image.RepoDigests[0]
+			a.ImageDigest = match[1]
+			return nil
+		}
+	}
+
+	// images built locally have no repo digest, so fall back to the image's config digest
+	if match := digestPattern.FindStringSubmatch(container.Image); match != nil {
+		a.ImageDigest = match[1]
+	}
+
+	return nil
+}
+
+func dockerGet(ctx context.Context, client *http.Client, path string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://docker"+path, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to get %v: unexpected status %v", path, resp.Status)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// cgroupLimits reads the limits of the cgroup witness is running in, which is mounted at /sys/fs/cgroup in a
+// container.
+func cgroupLimits() *CgroupLimits {
+	if replay.Exists(hostPath("/sys/fs/cgroup/cgroup.controllers")) {
+		limits := &CgroupLimits{Version: 2}
+		limits.MemoryBytes = readLimit("/sys/fs/cgroup/memory.max")
+		limits.Pids = readLimit("/sys/fs/cgroup/pids.max")
+		if fields := strings.Fields(readHostFile("/sys/fs/cgroup/cpu.max")); len(fields) == 2 && fields[0] != "max" {
+			limits.CPUQuota = fields[0] + "/" + fields[1]
+		}
+
+		return limits
+	}
+
+	if !replay.Exists(hostPath("/sys/fs/cgroup/memory")) {
+		return nil
+	}
+
+	limits := &CgroupLimits{Version: 1}
+	limits.MemoryBytes = readLimit("/sys/fs/cgroup/memory/memory.limit_in_bytes")
+	limits.Pids = readLimit("/sys/fs/cgroup/pids/pids.max")
+	quota := readLimit("/sys/fs/cgroup/cpu/cpu.cfs_quota_us")
+	period := readLimit("/sys/fs/cgroup/cpu/cpu.cfs_period_us")
+	if quota > 0 && period > 0 {
+		limits.CPUQuota = fmt.Sprintf("%v/%v", quota, period)
+	}
+
+	// cgroup v1 reports an unlimited memory limit as a very large number rather than max
+	if limits.MemoryBytes >= 1<<62 {
+		limits.MemoryBytes = 0
+	}
+
+	return limits
+}
+
+// readLimit returns the limit in the file, or 0 if it is unlimited or can't be read.
+func readLimit(path string) int64 {
+	limit, err := strconv.ParseInt(strings.TrimSpace(readHostFile(path)), 10, 64)
+	if err != nil || limit < 0 {
+		return 0
+	}
+
+	return limit
+}
+
+// overlayLayers returns the layers of the overlay filesystem mounted at /, if there is one.
+func overlayLayers() *OverlayLayers {
+	scanner := bufio.NewScanner(strings.NewReader(readHostFile("/proc/self/mountinfo")))
+	for scanner.Scan() {
+		// fields after the - separator are the filesystem type, source, and super block options
+		parts := strings.SplitN(scanner.Text(), " - ", 2)
+		if len(parts) != 2 {
+			continue
+		}
+
+		fields := strings.Fields(parts[0])
+		fsFields := strings.Fields(parts[1])
+		if len(fields) < 5 || fields[4] != "/" || len(fsFields) < 3 || fsFields[0] != "overlay" {
+			continue
+		}
+
+		layers := &OverlayLayers{}
+		for _, option := range strings.Split(fsFields[2], ",") {
+			kv := strings.SplitN(option, "=", 2)
+			if len(kv) != 2 {
+				continue
+			}
+
+			switch kv[0] {
+			case "lowerdir":
+				layers.LowerDirs = strings.Split(kv[1], ":")
+			case "upperdir":
+				layers.UpperDir = kv[1]
+			}
+		}
+
+		return layers
+	}
+
+	return nil
+}
@@ -0,0 +1,144 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package container
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const testID = "3b6f5a1c2d4e8f90a1b2c3d4e5f60718293a4b5c6d7e8f90a1b2c3d4e5f60718"
+
+func writeHostFiles(t *testing.T, files map[string]string) {
+	oldRoot := root
+	t.Cleanup(func() { root = oldRoot })
+	root = t.TempDir()
+	for path, content := range files {
+		full := filepath.Join(root, path)
+		if err := os.MkdirAll(filepath.Dir(full), 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := os.WriteFile(full, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestNotInContainer(t *testing.T) {
+	writeHostFiles(t, map[string]string{"/proc/1/cgroup": "0::/init.scope\n"})
+	t.Setenv("KUBERNETES_SERVICE_HOST", "")
+	if runtime := detectRuntime(); runtime != "" {
+		t.Errorf("expected no container runtime, got %v", runtime)
+	}
+}
+
+func TestDockerContainer(t *testing.T) {
+	writeHostFiles(t, map[string]string{
+		"/.dockerenv":       "",
+		"/proc/1/cgroup":    "0::/\n",
+		"/proc/self/cgroup": "0::/\n",
+		"/proc/self/mountinfo": "" +
+			"500 450 0:52 / / rw,relatime master:1 - overlay overlay rw,lowerdir=/var/lib/docker/overlay2/l/AAA:/var/lib/docker/overlay2/l/BBB,upperdir=/var/lib/docker/overlay2/abc/diff,workdir=/var/lib/docker/overlay2/abc/work\n" +
+			"510 500 259:1 /var/lib/docker/containers/" + testID + "/hostname /etc/hostname rw,relatime - ext4 /dev/root rw\n",
+		"/sys/fs/cgroup/cgroup.controllers": "cpu memory pids\n",
+		"/sys/fs/cgroup/memory.max":         "536870912\n",
+		"/sys/fs/cgroup/cpu.max":            "200000 100000\n",
+		"/sys/fs/cgroup/pids.max":           "max\n",
+	})
+
+	oldSocket := dockerSocket
+	defer func() { dockerSocket = oldSocket }()
+	dockerSocket = filepath.Join(t.TempDir(), "docker.sock")
+	listener, err := net.Listen("unix", dockerSocket)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/containers/" + testID + "/json":
+			fmt.Fprint(w, `{"Image": "sha256:1111111111111111111111111111111111111111111111111111111111111111", "Config": {"Image": "golang:1.17"}}`)
+		case "/images/sha256:1111111111111111111111111111111111111111111111111111111111111111/json":
+			fmt.Fprint(w, `{"RepoDigests": ["golang@sha256:2222222222222222222222222222222222222222222222222222222222222222"]}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	})}
+	go server.Serve(listener)
+	defer server.Close()
+
+	a := New()
+	if a.Runtime = detectRuntime(); a.Runtime != "docker" {
+		t.Fatalf("expected docker runtime, got %v", a.Runtime)
+	}
+
+	if a.ContainerID = containerID(); a.ContainerID != testID {
+		t.Fatalf("expected container id from mountinfo, got %v", a.ContainerID)
+	}
+
+	if err := a.inspectDocker(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if a.ImageDigest != "2222222222222222222222222222222222222222222222222222222222222222" {
+		t.Errorf("expected repo digest of the image, got %v", a.ImageDigest)
+	}
+
+	if _, ok := a.Subjects()["containerimage:"+a.ImageDigest]; !ok {
+		t.Errorf("expected image digest subject: %v", a.Subjects())
+	}
+
+	limits := cgroupLimits()
+	if limits.Version != 2 || limits.MemoryBytes != 536870912 || limits.CPUQuota != "200000/100000" || limits.Pids != 0 {
+		t.Errorf("unexpected limits: %+v", limits)
+	}
+
+	overlay := overlayLayers()
+	if overlay == nil || len(overlay.LowerDirs) != 2 || overlay.UpperDir != "/var/lib/docker/overlay2/abc/diff" {
+		t.Errorf("unexpected overlay layers: %+v", overlay)
+	}
+}
+
+func TestPodmanContainer(t *testing.T) {
+	writeHostFiles(t, map[string]string{
+		"/run/.containerenv": "engine=\"podman-4.2.0\"\nname=\"builder\"\nid=\"" + testID + "\"\n" +
+			"image=\"quay.io/example/builder:latest\"\nimageid=\"3333333333333333333333333333333333333333333333333333333333333333\"\n",
+		"/sys/fs/cgroup/memory/memory.limit_in_bytes": "9223372036854771712\n",
+		"/sys/fs/cgroup/cpu/cpu.cfs_quota_us":         "-1\n",
+		"/sys/fs/cgroup/cpu/cpu.cfs_period_us":        "100000\n",
+		"/sys/fs/cgroup/pids/pids.max":                "1024\n",
+	})
+
+	a := New()
+	if a.Runtime = detectRuntime(); a.Runtime != "podman" {
+		t.Fatalf("expected podman runtime, got %v", a.Runtime)
+	}
+
+	a.readContainerEnv()
+	if a.ContainerID != testID || a.Image != "quay.io/example/builder:latest" || a.ImageDigest != "3333333333333333333333333333333333333333333333333333333333333333" {
+		t.Errorf("unexpected container: %+v", a)
+	}
+
+	limits := cgroupLimits()
+	if limits.Version != 1 || limits.MemoryBytes != 0 || limits.CPUQuota != "" || limits.Pids != 1024 {
+		t.Errorf("unexpected limits: %+v", limits)
+	}
+}
@@ -0,0 +1,371 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deadline
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/testifysec/go-witness/attestation"
+	"github.com/testifysec/go-witness/attestation/commandrun"
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/log"
+	"github.com/testifysec/witness/pkg/attestation/command"
+)
+
+const (
+	Name    = "deadline"
+	Type    = "https://witness.dev/attestations/deadline/v0.1"
+	RunType = attestation.PostRunType
+)
+
+func init() {
+	attestation.RegisterAttestation(Name, Type, RunType, func() attestation.Attestor {
+		return New()
+	})
+}
+
+// Attestor records the time budget witness run was given and the attestors that did not finish within it. It is not
+// run; Collection adds it to collections recorded with a budget.
+type Attestor struct {
+	AttestorTimeout string   `json:"attestorTimeout,omitempty"`
+	Deadline        string   `json:"deadline,omitempty"`
+	TimedOut        []string `json:"timedOut"`
+	Skipped         []string `json:"skipped"`
+}
+
+func New() *Attestor {
+	return &Attestor{TimedOut: []string{}, Skipped: []string{}}
+}
+
+func (a *Attestor) Name() string {
+	return Name
+}
+
+func (a *Attestor) Type() string {
+	return Type
+}
+
+func (a *Attestor) RunType() attestation.RunType {
+	return RunType
+}
+
+func (a *Attestor) Attest(ctx *attestation.AttestationContext) error {
+	return nil
+}
+
+// Budget limits how long attestors may run. Each attestor may run for at most AttestorTimeout, and all of them
+// together for at most Deadline. The command run by witness run is never limited, and the time it takes does not count
+// against the deadline. Either limit is disabled when it is 0.
+type Budget struct {
+	AttestorTimeout time.Duration
+	Deadline        time.Duration
+
+	mu       sync.Mutex
+	spent    time.Duration
+	timedOut []string
+	skipped  []string
+}
+
+func NewBudget(attestorTimeout, deadline time.Duration) *Budget {
+	return &Budget{AttestorTimeout: attestorTimeout, Deadline: deadline}
+}
+
+func (b *Budget) enabled() bool {
+	return b != nil && (b.AttestorTimeout > 0 || b.Deadline > 0)
+}
+
+// timeout returns how long the next attestor may run, or false if the deadline has passed.
+func (b *Budget) timeout() (time.Duration, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	timeout := b.AttestorTimeout
+	if b.Deadline > 0 {
+		remaining := b.Deadline - b.spent
+		if remaining <= 0 {
+			return 0, false
+		}
+
+		if timeout <= 0 || remaining < timeout {
+			timeout = remaining
+		}
+	}
+
+	return timeout, true
+}
+
+func (b *Budget) spend(d time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.spent += d
+}
+
+func (b *Budget) record(list *[]string, name string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	*list = append(*list, name)
+}
+
+// Wrap returns the attestor limited by the budget. An attestor that runs out of time is abandoned: witness moves on
+// while it keeps running in the background, and it is left out of the collection. Limited attestors run with a snapshot
+// of the attestation context, whose context is cancelled once they run out of time, so an abandoned attestor never
+// reads the context witness goes on to change. Attestors are returned unchanged if the budget has no limits.
+func (b *Budget) Wrap(attestor attestation.Attestor) attestation.Attestor {
+	if !b.enabled() {
+		return attestor
+	}
+
+	l := &limited{Attestor: attestor, budget: b}
+	_, isMaterialer := attestor.(attestation.Materialer)
+	_, isProducer := attestor.(attestation.Producer)
+	switch {
+	case isMaterialer && isProducer:
+		return &limitedMaterialerProducer{l}
+	case isMaterialer:
+		return &limitedMaterialer{l}
+	case isProducer:
+		return &limitedProducer{l}
+	default:
+		return l
+	}
+}
+
+// limited runs an attestor within its budget. go-witness adds an attestor's materials and products to the attestation
+// context once it finishes, so the wrappers below keep the wrapped attestor's Materialer and Producer implementations.
+type limited struct {
+	attestation.Attestor
+	budget    *Budget
+	mu        sync.Mutex
+	completed bool
+}
+
+func (l *limited) Attest(ctx *attestation.AttestationContext) error {
+	timeout, ok := l.budget.timeout()
+	if !ok {
+		log.Warnf("Skipping %v attestor, the attestation deadline has passed", l.Name())
+		l.budget.record(&l.budget.skipped, l.Name())
+		return nil
+	}
+
+	if timeout <= 0 {
+		return l.finish(l.Attestor.Attest(ctx))
+	}
+
+	attestCtx, cancel := context.WithCancel(ctx.Context())
+	defer cancel()
+	snapshotCtx, err := newSnapshot(attestCtx, ctx, l.Name())
+	if err != nil {
+		return err
+	}
+
+	done := make(chan error, 1)
+	started := time.Now()
+	go func() { done <- l.Attestor.Attest(snapshotCtx) }()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case err := <-done:
+		l.budget.spend(time.Since(started))
+		return l.finish(err)
+	case <-timer.C:
+		l.budget.spend(timeout)
+		log.Warnf("%v attestor did not finish within %v, leaving it out of the collection", l.Name(), timeout)
+		l.budget.record(&l.budget.timedOut, l.Name())
+		return nil
+	}
+}
+
+func (l *limited) finish(err error) error {
+	if err == nil {
+		l.mu.Lock()
+		l.completed = true
+		l.mu.Unlock()
+	}
+
+	return err
+}
+
+func (l *limited) done() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.completed
+}
+
+func (l *limited) materials() map[string]cryptoutil.DigestSet {
+	if !l.done() {
+		return map[string]cryptoutil.DigestSet{}
+	}
+
+	return l.Attestor.(attestation.Materialer).Materials()
+}
+
+func (l *limited) products() map[string]attestation.Product {
+	if !l.done() {
+		return map[string]attestation.Product{}
+	}
+
+	return l.Attestor.(attestation.Producer).Products()
+}
+
+type limitedMaterialer struct{ *limited }
+
+func (l *limitedMaterialer) Materials() map[string]cryptoutil.DigestSet { return l.materials() }
+
+type limitedProducer struct{ *limited }
+
+func (l *limitedProducer) Products() map[string]attestation.Product { return l.products() }
+
+type limitedMaterialerProducer struct{ *limited }
+
+func (l *limitedMaterialerProducer) Materials() map[string]cryptoutil.DigestSet { return l.materials() }
+
+func (l *limitedMaterialerProducer) Products() map[string]attestation.Product { return l.products() }
+
+// snapshot stands in for the attestors completed before a limited attestor in the attestation context it runs with. It
+// is the context's material attestor, so the materials and products it copied become the context's.
+type snapshot struct {
+	name      string
+	completed []attestation.Attestor
+	materials map[string]cryptoutil.DigestSet
+	products  map[string]attestation.Product
+}
+
+// newSnapshot returns an attestation context with a copy of ctx's materials, products, and completed attestors, whose
+// context is runCtx.
+func newSnapshot(runCtx context.Context, ctx *attestation.AttestationContext, name string) (*attestation.AttestationContext, error) {
+	s := &snapshot{
+		name:      name,
+		completed: ctx.CompletedAttestors(),
+		materials: ctx.Materials(),
+		products:  make(map[string]attestation.Product),
+	}
+
+	// Products returns the context's own map, which witness adds to as later attestors finish
+	for path, product := range ctx.Products() {
+		s.products[path] = product
+	}
+
+	snapshotCtx, err := attestation.NewContext(nil,
+		attestation.WithContext(runCtx),
+		attestation.WithWorkingDir(ctx.WorkingDir()),
+		attestation.WithHashes(ctx.Hashes()),
+		attestation.WithMaterialAttestor(s),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create attestation context for %v attestor: %w", name, err)
+	}
+
+	if err := snapshotCtx.RunAttestors(); err != nil {
+		return nil, fmt.Errorf("failed to create attestation context for %v attestor: %w", name, err)
+	}
+
+	return snapshotCtx, nil
+}
+
+func (s *snapshot) Name() string {
+	return fmt.Sprintf("snapshot for the %v", s.name)
+}
+
+func (s *snapshot) Type() string {
+	return Type
+}
+
+func (s *snapshot) RunType() attestation.RunType {
+	return attestation.Internal
+}
+
+func (s *snapshot) Attest(ctx *attestation.AttestationContext) error {
+	return nil
+}
+
+func (s *snapshot) Materials() map[string]cryptoutil.DigestSet {
+	return s.materials
+}
+
+func (s *snapshot) Products() map[string]attestation.Product {
+	return s.products
+}
+
+// CommandRun returns the command-run attestation among the completed attestors, so command.Run finds it in a snapshot.
+func (s *snapshot) CommandRun() *commandrun.CommandRun {
+	cr, _ := command.Run(s.completed)
+	return cr
+}
+
+// Completed returns the attestors completed before the one running with ctx. Limited attestors run with a snapshot of
+// the attestation context, which Completed returns the completed attestors of in its place.
+func Completed(ctx *attestation.AttestationContext) []attestation.Attestor {
+	completed := make([]attestation.Attestor, 0)
+	for _, a := range ctx.CompletedAttestors() {
+		if s, ok := a.(*snapshot); ok {
+			completed = append(completed, s.completed...)
+			continue
+		}
+
+		completed = append(completed, a)
+	}
+
+	return completed
+}
+
+// Unwrap returns the attestor a wrapper limits and whether it finished. Attestors that were not wrapped are finished.
+// Attestors that read the attestors completed before them use it to find the ones they know.
+func Unwrap(attestor attestation.Attestor) (attestation.Attestor, bool) {
+	switch a := attestor.(type) {
+	case *limited:
+		return a.Attestor, a.done()
+	case *limitedMaterialer:
+		return a.Attestor, a.done()
+	case *limitedProducer:
+		return a.Attestor, a.done()
+	case *limitedMaterialerProducer:
+		return a.Attestor, a.done()
+	default:
+		return attestor, true
+	}
+}
+
+// Collection returns the collection recorded with the budget. Attestors that did not finish are left out, and a
+// deadline attestation recording the budget and the attestors that timed out or were skipped is appended, unless the
+// budget has no limits.
+func Collection(name string, attestors []attestation.Attestor, budget *Budget) attestation.Collection {
+	if !budget.enabled() {
+		return attestation.NewCollection(name, attestors)
+	}
+
+	finished := make([]attestation.Attestor, 0, len(attestors)+1)
+	for _, a := range attestors {
+		if inner, ok := Unwrap(a); ok {
+			finished = append(finished, inner)
+		}
+	}
+
+	record := New()
+	if budget.AttestorTimeout > 0 {
+		record.AttestorTimeout = budget.AttestorTimeout.String()
+	}
+
+	if budget.Deadline > 0 {
+		record.Deadline = budget.Deadline.String()
+	}
+
+	budget.mu.Lock()
+	record.TimedOut = append(record.TimedOut, budget.timedOut...)
+	record.Skipped = append(record.Skipped, budget.skipped...)
+	budget.mu.Unlock()
+	return attestation.NewCollection(name, append(finished, record))
+}
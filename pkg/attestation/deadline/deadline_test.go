@@ -0,0 +1,220 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deadline
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/testifysec/go-witness/attestation"
+	"github.com/testifysec/go-witness/cryptoutil"
+)
+
+type sleepAttestor struct {
+	name  string
+	sleep time.Duration
+	err   error
+}
+
+func (a *sleepAttestor) Name() string                 { return a.name }
+func (a *sleepAttestor) Type() string                 { return "https://witness.dev/attestations/" + a.name + "/v0.1" }
+func (a *sleepAttestor) RunType() attestation.RunType { return attestation.PreRunType }
+
+func (a *sleepAttestor) Attest(ctx *attestation.AttestationContext) error {
+	time.Sleep(a.sleep)
+	return a.err
+}
+
+type sleepMaterialer struct{ sleepAttestor }
+
+func (a *sleepMaterialer) Materials() map[string]cryptoutil.DigestSet {
+	return map[string]cryptoutil.DigestSet{a.name: {}}
+}
+
+// readAttestor keeps reading the attestation context it runs with until the context is cancelled or stop is closed.
+type readAttestor struct {
+	sleepAttestor
+	stop      chan struct{}
+	finished  chan struct{}
+	cancelled bool
+	materials map[string]cryptoutil.DigestSet
+	completed []attestation.Attestor
+}
+
+func (a *readAttestor) Attest(ctx *attestation.AttestationContext) error {
+	defer close(a.finished)
+	a.materials = ctx.Materials()
+	a.completed = Completed(ctx)
+	for {
+		select {
+		case <-ctx.Context().Done():
+			a.cancelled = true
+			return nil
+		case <-a.stop:
+			return nil
+		case <-time.After(time.Millisecond):
+			_, _, _ = ctx.Materials(), ctx.Products(), ctx.CompletedAttestors()
+		}
+	}
+}
+
+func run(t *testing.T, budget *Budget, attestors ...attestation.Attestor) (attestation.Collection, error) {
+	wrapped := make([]attestation.Attestor, 0, len(attestors))
+	for _, a := range attestors {
+		wrapped = append(wrapped, budget.Wrap(a))
+	}
+
+	ctx, err := attestation.NewContext(wrapped)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ctx.RunAttestors(); err != nil {
+		return attestation.Collection{}, err
+	}
+
+	return Collection("build", ctx.CompletedAttestors(), budget), nil
+}
+
+func record(t *testing.T, collection attestation.Collection) *Attestor {
+	last := collection.Attestations[len(collection.Attestations)-1].Attestation
+	a, ok := last.(*Attestor)
+	if !ok {
+		t.Fatalf("expected a deadline attestation last, got %v", last.Type())
+	}
+
+	return a
+}
+
+func TestAttestorTimeout(t *testing.T) {
+	budget := NewBudget(50*time.Millisecond, 0)
+	collection, err := run(t, budget,
+		&sleepAttestor{name: "fast"},
+		&sleepAttestor{name: "slow", sleep: time.Second},
+		&sleepAttestor{name: "after"},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(collection.Attestations) != 3 || collection.Attestations[0].Type != (&sleepAttestor{name: "fast"}).Type() || collection.Attestations[1].Type != (&sleepAttestor{name: "after"}).Type() {
+		t.Fatalf("unexpected attestations: %+v", collection.Attestations)
+	}
+
+	a := record(t, collection)
+	if len(a.TimedOut) != 1 || a.TimedOut[0] != "slow" || len(a.Skipped) != 0 || a.AttestorTimeout != "50ms" || a.Deadline != "" {
+		t.Fatalf("unexpected deadline attestation: %+v", a)
+	}
+}
+
+func TestDeadline(t *testing.T) {
+	budget := NewBudget(0, 100*time.Millisecond)
+	slow := &sleepMaterialer{sleepAttestor{name: "slow", sleep: time.Second}}
+	collection, err := run(t, budget,
+		&sleepAttestor{name: "fast"},
+		slow,
+		&sleepAttestor{name: "skipped"},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(collection.Attestations) != 2 {
+		t.Fatalf("unexpected attestations: %+v", collection.Attestations)
+	}
+
+	a := record(t, collection)
+	if len(a.TimedOut) != 1 || a.TimedOut[0] != "slow" || len(a.Skipped) != 1 || a.Skipped[0] != "skipped" {
+		t.Fatalf("unexpected deadline attestation: %+v", a)
+	}
+}
+
+func TestMaterials(t *testing.T) {
+	budget := NewBudget(50*time.Millisecond, 0)
+	fast := budget.Wrap(&sleepMaterialer{sleepAttestor{name: "fast"}})
+	slow := budget.Wrap(&sleepMaterialer{sleepAttestor{name: "slow", sleep: time.Second}})
+	ctx, err := attestation.NewContext([]attestation.Attestor{fast, slow})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ctx.RunAttestors(); err != nil {
+		t.Fatal(err)
+	}
+
+	materials := ctx.Materials()
+	if _, ok := materials["fast"]; !ok || len(materials) != 1 {
+		t.Fatalf("expected only the finished materialer's materials, got %v", materials)
+	}
+}
+
+func TestAbandonedAttestorSnapshot(t *testing.T) {
+	budget := NewBudget(50*time.Millisecond, 0)
+	reader := &readAttestor{sleepAttestor: sleepAttestor{name: "reader"}, stop: make(chan struct{}), finished: make(chan struct{})}
+	attestors := []attestation.Attestor{&sleepMaterialer{sleepAttestor{name: "before"}}, reader}
+	for i := 0; i < 10; i++ {
+		attestors = append(attestors, &sleepMaterialer{sleepAttestor{name: fmt.Sprintf("after-%d", i), sleep: 10 * time.Millisecond}})
+	}
+
+	// the attestors that finish after the reader is abandoned change the context while it may still be reading it,
+	// which the race detector catches unless the reader was given a snapshot
+	collection, err := run(t, budget, attestors...)
+	close(reader.stop)
+	<-reader.finished
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reader.cancelled {
+		t.Error("expected the abandoned attestor's context to be cancelled")
+	}
+
+	if _, ok := reader.materials["before"]; !ok || len(reader.materials) != 1 {
+		t.Errorf("expected the snapshot to hold the materials recorded before the attestor, got %v", reader.materials)
+	}
+
+	if len(reader.completed) != 1 || reader.completed[0].Name() != "before" {
+		t.Errorf("expected the snapshot to hold the attestors completed before the attestor, got %v", reader.completed)
+	}
+
+	if a := record(t, collection); len(a.TimedOut) != 1 || a.TimedOut[0] != "reader" || len(collection.Attestations) != 12 {
+		t.Fatalf("expected only the reader to time out, got %+v", a)
+	}
+}
+
+func TestBudgetErrors(t *testing.T) {
+	expected := errors.New("failed")
+	if _, err := run(t, NewBudget(time.Second, 0), &sleepAttestor{name: "failing", err: expected}); !errors.Is(err, expected) {
+		t.Fatalf("expected attestor error to be returned, got %v", err)
+	}
+}
+
+func TestNoBudget(t *testing.T) {
+	a := &sleepAttestor{name: "fast"}
+	if NewBudget(0, 0).Wrap(a) != attestation.Attestor(a) {
+		t.Fatal("expected attestor to be returned unchanged without limits")
+	}
+
+	collection, err := run(t, NewBudget(0, 0), a)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(collection.Attestations) != 1 {
+		t.Fatalf("expected no deadline attestation without limits, got %+v", collection.Attestations)
+	}
+}
@@ -0,0 +1,177 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fingerprint
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/testifysec/go-witness/attestation"
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/witness/pkg/replay"
+)
+
+const (
+	Name    = "builder-fingerprint"
+	Type    = "https://witness.dev/attestations/builder-fingerprint/v0.1"
+	RunType = attestation.PreRunType
+
+	// ImageEnv can be set to the digest of the image a self-hosted runner was started from.
+	ImageEnv = "WITNESS_RUNNER_IMAGE"
+)
+
+// toolchains are the version commands recorded for each toolchain found in the PATH.
+var toolchains = map[string][]string{
+	"go":      {"go", "version"},
+	"gcc":     {"gcc", "--version"},
+	"clang":   {"clang", "--version"},
+	"rustc":   {"rustc", "--version"},
+	"java":    {"java", "-version"},
+	"node":    {"node", "--version"},
+	"python3": {"python3", "--version"},
+}
+
+// imageEnv are the environment variables CI providers use to identify the runner image.
+var imageEnv = []string{
+	ImageEnv,
+	// GitHub hosted runners
+	"ImageOS",
+	"ImageVersion",
+	// GitLab
+	"CI_JOB_IMAGE",
+}
+
+// runCommand and readFile are replaced in tests
+var (
+	runCommand = replay.CombinedOutput
+	readFile   = replay.ReadFile
+)
+
+func init() {
+	attestation.RegisterAttestation(Name, Type, RunType, func() attestation.Attestor {
+		return New()
+	})
+}
+
+// Attestor records a fingerprint of the builder: its operating system, toolchain versions, and runner image. Builds on
+// the same runner image share a fingerprint, so every artifact built on an image can be found from its fingerprint.
+type Attestor struct {
+	Fingerprint string            `json:"fingerprint"`
+	Components  map[string]string `json:"components"`
+}
+
+func New() *Attestor {
+	return &Attestor{}
+}
+
+func (a *Attestor) Name() string {
+	return Name
+}
+
+func (a *Attestor) Type() string {
+	return Type
+}
+
+func (a *Attestor) RunType() attestation.RunType {
+	return RunType
+}
+
+func (a *Attestor) Attest(ctx *attestation.AttestationContext) error {
+	a.Components = components(ctx.Context())
+	a.Fingerprint = Calculate(a.Components)
+	return nil
+}
+
+func (a *Attestor) Subjects() map[string]cryptoutil.DigestSet {
+	return map[string]cryptoutil.DigestSet{
+		fmt.Sprintf("builderfingerprint:%v", a.Fingerprint): {crypto.SHA256: a.Fingerprint},
+	}
+}
+
+// Calculate returns the hex encoded sha256 digest of the components, one key=value line each sorted by key.
+func Calculate(components map[string]string) string {
+	keys := make([]string, 0, len(components))
+	for k := range components {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+	b := strings.Builder{}
+	for _, k := range keys {
+		fmt.Fprintf(&b, "%v=%v\n", k, components[k])
+	}
+
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(b.String())))
+}
+
+func components(ctx context.Context) map[string]string {
+	c := map[string]string{
+		"os":   runtime.GOOS,
+		"arch": runtime.GOARCH,
+	}
+
+	if release, err := readFile("/etc/os-release"); err == nil {
+		fields := parseOSRelease(release)
+		for _, key := range []string{"ID", "VERSION_ID"} {
+			if value, ok := fields[key]; ok {
+				c["os-release."+strings.ToLower(key)] = value
+			}
+		}
+	}
+
+	for name, cmd := range toolchains {
+		out, err := runCommand(ctx, cmd[0], cmd[1:]...)
+		if err != nil {
+			continue
+		}
+
+		// the first line holds the version, later lines hold copyright notices and paths
+		line := strings.TrimSpace(strings.SplitN(string(out), "\n", 2)[0])
+		if line != "" {
+			c["toolchain."+name] = line
+		}
+	}
+
+	for _, env := range imageEnv {
+		if value := os.Getenv(env); value != "" {
+			c["image."+env] = value
+		}
+	}
+
+	return c
+}
+
+func parseOSRelease(data []byte) map[string]string {
+	fields := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		parts := strings.SplitN(scanner.Text(), "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+
+		fields[strings.TrimSpace(parts[0])] = strings.Trim(strings.TrimSpace(parts[1]), `"'`)
+	}
+
+	return fields
+}
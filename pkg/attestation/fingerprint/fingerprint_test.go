@@ -0,0 +1,76 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fingerprint
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestComponents(t *testing.T) {
+	oldRun, oldRead := runCommand, readFile
+	defer func() { runCommand, readFile = oldRun, oldRead }()
+	runCommand = func(ctx context.Context, name string, args ...string) ([]byte, error) {
+		switch strings.Join(append([]string{name}, args...), " ") {
+		case "go version":
+			return []byte("go version go1.17.13 linux/amd64\n"), nil
+		case "gcc --version":
+			return []byte("gcc (Ubuntu 11.3.0-1ubuntu1~22.04) 11.3.0\nCopyright (C) 2021 Free Software Foundation, Inc.\n"), nil
+		default:
+			return nil, fmt.Errorf("not found")
+		}
+	}
+
+	readFile = func(name string) ([]byte, error) {
+		return []byte("NAME=\"Ubuntu\"\nVERSION_ID=\"22.04\"\nID=ubuntu\n"), nil
+	}
+
+	t.Setenv(ImageEnv, "sha256:abcd")
+	c := components(context.Background())
+	expected := map[string]string{
+		"os":                    runtime.GOOS,
+		"arch":                  runtime.GOARCH,
+		"os-release.id":         "ubuntu",
+		"os-release.version_id": "22.04",
+		"toolchain.go":          "go version go1.17.13 linux/amd64",
+		"toolchain.gcc":         "gcc (Ubuntu 11.3.0-1ubuntu1~22.04) 11.3.0",
+		"image." + ImageEnv:     "sha256:abcd",
+	}
+
+	for k, v := range expected {
+		if c[k] != v {
+			t.Errorf("expected %v to be %q, got %q", k, v, c[k])
+		}
+	}
+
+	if _, ok := c["toolchain.rustc"]; ok {
+		t.Error("expected missing toolchains to be skipped")
+	}
+}
+
+func TestCalculate(t *testing.T) {
+	a := Calculate(map[string]string{"os": "linux", "arch": "amd64"})
+	b := Calculate(map[string]string{"arch": "amd64", "os": "linux"})
+	if a != b {
+		t.Error("expected fingerprint not to depend on map order")
+	}
+
+	if a == Calculate(map[string]string{"os": "linux", "arch": "arm64"}) {
+		t.Error("expected different components to change the fingerprint")
+	}
+}
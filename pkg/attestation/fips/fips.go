@@ -0,0 +1,65 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fips
+
+import (
+	"runtime"
+
+	"github.com/testifysec/go-witness/attestation"
+	fipsmode "github.com/testifysec/witness/pkg/fips"
+)
+
+const (
+	Name    = "fips"
+	Type    = "https://witness.dev/attestations/fips/v0.1"
+	RunType = attestation.PreRunType
+)
+
+func init() {
+	attestation.RegisterAttestation(Name, Type, RunType, func() attestation.Attestor {
+		return New()
+	})
+}
+
+type Attestor struct {
+	Enabled      bool   `json:"enabled"`
+	BuildTag     bool   `json:"buildtag"`
+	BoringCrypto bool   `json:"boringcrypto"`
+	GoVersion    string `json:"goversion"`
+}
+
+func New() *Attestor {
+	return &Attestor{}
+}
+
+func (a *Attestor) Name() string {
+	return Name
+}
+
+func (a *Attestor) Type() string {
+	return Type
+}
+
+func (a *Attestor) RunType() attestation.RunType {
+	return RunType
+}
+
+func (a *Attestor) Attest(ctx *attestation.AttestationContext) error {
+	a.Enabled = fipsmode.Enabled()
+	a.BuildTag = fipsmode.BuildTag()
+	a.BoringCrypto = fipsmode.BoringCrypto()
+	a.GoVersion = runtime.Version()
+	return nil
+}
@@ -0,0 +1,166 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gitlabci
+
+import (
+	"crypto"
+	"crypto/sha256"
+	"fmt"
+	"os"
+
+	"github.com/testifysec/go-witness/attestation"
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/log"
+	"github.com/testifysec/witness/pkg/oidctoken"
+)
+
+const (
+	Name    = "gitlab-ci"
+	Type    = "https://witness.dev/attestations/gitlab-ci/v0.1"
+	RunType = attestation.PreRunType
+)
+
+// verifyToken is replaced in tests
+var verifyToken = oidctoken.Verify
+
+// tokenEnv are the variables an ID token is read from, in order. CI_JOB_JWT_V2 and CI_JOB_JWT are the tokens older
+// GitLab versions give every job; newer ones only issue tokens requested with id_tokens.
+var tokenEnv = []string{oidctoken.TokenEnv, "CI_JOB_JWT_V2", "CI_JOB_JWT"}
+
+// backedClaims are the claims of GitLab's ID tokens returned as subjects.
+var backedClaims = []string{
+	"namespace_path", "project_path", "ref", "ref_type", "ref_protected", "environment", "environment_protected",
+	"pipeline_source", "runner_environment",
+}
+
+func init() {
+	attestation.RegisterAttestation(Name, Type, RunType, func() attestation.Attestor {
+		return New()
+	})
+}
+
+// Attestor records the GitLab CI job witness is running in. Its fields are read from the job's predefined variables,
+// which the job could set for itself; when an ID token is available its claims are verified against the GitLab
+// instance's keys and recorded in Token, and are the attributes policies should rely on.
+type Attestor struct {
+	ServerURL      string           `json:"serverurl"`
+	ProjectID      string           `json:"projectid"`
+	ProjectPath    string           `json:"projectpath"`
+	ProjectURL     string           `json:"projecturl"`
+	Ref            string           `json:"ref"`
+	RefType        string           `json:"reftype"`
+	RefProtected   bool             `json:"refprotected"`
+	CommitSHA      string           `json:"commitsha"`
+	PipelineID     string           `json:"pipelineid"`
+	PipelineURL    string           `json:"pipelineurl"`
+	PipelineSource string           `json:"pipelinesource"`
+	JobID          string           `json:"jobid"`
+	JobName        string           `json:"jobname"`
+	JobStage       string           `json:"jobstage"`
+	JobURL         string           `json:"joburl"`
+	JobImage       string           `json:"jobimage,omitempty"`
+	RunnerID       string           `json:"runnerid"`
+	Environment    string           `json:"environment,omitempty"`
+	ConfigPath     string           `json:"configpath"`
+	Token          *oidctoken.Token `json:"token,omitempty"`
+}
+
+func New() *Attestor {
+	return &Attestor{}
+}
+
+func (a *Attestor) Name() string {
+	return Name
+}
+
+func (a *Attestor) Type() string {
+	return Type
+}
+
+func (a *Attestor) RunType() attestation.RunType {
+	return RunType
+}
+
+func (a *Attestor) Attest(ctx *attestation.AttestationContext) error {
+	if os.Getenv("GITLAB_CI") != "true" {
+		return fmt.Errorf("not running in a gitlab ci job")
+	}
+
+	a.ServerURL = os.Getenv("CI_SERVER_URL")
+	a.ProjectID = os.Getenv("CI_PROJECT_ID")
+	a.ProjectPath = os.Getenv("CI_PROJECT_PATH")
+	a.ProjectURL = os.Getenv("CI_PROJECT_URL")
+	a.CommitSHA = os.Getenv("CI_COMMIT_SHA")
+	a.Ref = os.Getenv("CI_COMMIT_REF_NAME")
+	a.RefType = "branch"
+	if os.Getenv("CI_COMMIT_TAG") != "" {
+		a.RefType = "tag"
+	}
+
+	a.RefProtected = os.Getenv("CI_COMMIT_REF_PROTECTED") == "true"
+	a.PipelineID = os.Getenv("CI_PIPELINE_ID")
+	a.PipelineURL = os.Getenv("CI_PIPELINE_URL")
+	a.PipelineSource = os.Getenv("CI_PIPELINE_SOURCE")
+	a.JobID = os.Getenv("CI_JOB_ID")
+	a.JobName = os.Getenv("CI_JOB_NAME")
+	a.JobStage = os.Getenv("CI_JOB_STAGE")
+	a.JobURL = os.Getenv("CI_JOB_URL")
+	a.JobImage = os.Getenv("CI_JOB_IMAGE")
+	a.RunnerID = os.Getenv("CI_RUNNER_ID")
+	a.Environment = os.Getenv("CI_ENVIRONMENT_NAME")
+	a.ConfigPath = os.Getenv("CI_CONFIG_PATH")
+
+	token := ""
+	for _, name := range tokenEnv {
+		if token = os.Getenv(name); token != "" {
+			break
+		}
+	}
+
+	if token == "" {
+		log.Debugf("(attestation/gitlab-ci) no id token is available, recording the job's variables only")
+		return nil
+	}
+
+	verified, err := verifyToken(ctx.Context(), token, oidctoken.Options{
+		Issuers:  []string{a.ServerURL},
+		Audience: os.Getenv(oidctoken.AudienceEnv),
+	})
+	if err != nil {
+		return err
+	}
+
+	a.Token = verified
+	return nil
+}
+
+// Subjects returns the pipeline, job, and project URLs, and a gitlabclaim:<claim>=<value> subject for each of the
+// project, ref, environment, and pipeline claims of a verified ID token.
+func (a *Attestor) Subjects() map[string]cryptoutil.DigestSet {
+	subjects := make(map[string]cryptoutil.DigestSet)
+	for prefix, url := range map[string]string{"pipelineurl": a.PipelineURL, "joburl": a.JobURL, "projecturl": a.ProjectURL} {
+		if url != "" {
+			subjects[fmt.Sprintf("%v:%v", prefix, url)] = cryptoutil.DigestSet{crypto.SHA256: fmt.Sprintf("%x", sha256.Sum256([]byte(url)))}
+		}
+	}
+
+	if a.Token != nil {
+		for subject, digest := range a.Token.Subjects("gitlabclaim", backedClaims) {
+			subjects[subject] = digest
+		}
+	}
+
+	return subjects
+}
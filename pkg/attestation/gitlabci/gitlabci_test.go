@@ -0,0 +1,141 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gitlabci
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/testifysec/go-witness/attestation"
+	"github.com/testifysec/witness/pkg/oidctoken"
+)
+
+func newContext(t *testing.T) *attestation.AttestationContext {
+	ctx, err := attestation.NewContext([]attestation.Attestor{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return ctx
+}
+
+func setJobEnv(t *testing.T) {
+	for name, value := range map[string]string{
+		"GITLAB_CI":               "true",
+		"CI_SERVER_URL":           "https://gitlab.example.com",
+		"CI_PROJECT_ID":           "12",
+		"CI_PROJECT_PATH":         "group/app",
+		"CI_PROJECT_URL":          "https://gitlab.example.com/group/app",
+		"CI_COMMIT_SHA":           "0123456789abcdef",
+		"CI_COMMIT_REF_NAME":      "v1.2.0",
+		"CI_COMMIT_TAG":           "v1.2.0",
+		"CI_COMMIT_REF_PROTECTED": "true",
+		"CI_PIPELINE_ID":          "42",
+		"CI_PIPELINE_URL":         "https://gitlab.example.com/group/app/-/pipelines/42",
+		"CI_PIPELINE_SOURCE":      "push",
+		"CI_JOB_ID":               "7",
+		"CI_JOB_NAME":             "release",
+		"CI_JOB_URL":              "https://gitlab.example.com/group/app/-/jobs/7",
+		"CI_RUNNER_ID":            "99",
+		"CI_ENVIRONMENT_NAME":     "production",
+		oidctoken.TokenEnv:        "",
+		"CI_JOB_JWT_V2":           "",
+		"CI_JOB_JWT":              "",
+	} {
+		t.Setenv(name, value)
+	}
+}
+
+func TestAttest(t *testing.T) {
+	setJobEnv(t)
+	a := New()
+	if err := a.Attest(newContext(t)); err != nil {
+		t.Fatal(err)
+	}
+
+	if a.ProjectPath != "group/app" || a.Ref != "v1.2.0" || a.RefType != "tag" || !a.RefProtected || a.Environment != "production" {
+		t.Errorf("unexpected attestation %+v", a)
+	}
+
+	if a.Token != nil {
+		t.Errorf("expected no token, got %+v", a.Token)
+	}
+
+	subjects := a.Subjects()
+	if len(subjects) != 3 {
+		t.Errorf("expected only the url subjects without a token, got %v", subjects)
+	}
+}
+
+func TestAttestVerifiesToken(t *testing.T) {
+	setJobEnv(t)
+	t.Setenv("CI_JOB_JWT_V2", "v2token")
+	t.Setenv(oidctoken.AudienceEnv, "witness")
+	oldVerify := verifyToken
+	defer func() { verifyToken = oldVerify }()
+	var opts oidctoken.Options
+	verifyToken = func(ctx context.Context, token string, o oidctoken.Options) (*oidctoken.Token, error) {
+		if token != "v2token" {
+			return nil, fmt.Errorf("unexpected token %v", token)
+		}
+
+		opts = o
+		return &oidctoken.Token{Issuer: "https://gitlab.example.com", Claims: map[string]interface{}{
+			"project_path":  "group/app",
+			"ref":           "v1.2.0",
+			"ref_protected": "true",
+			"environment":   "production",
+		}}, nil
+	}
+
+	a := New()
+	if err := a.Attest(newContext(t)); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(opts, oidctoken.Options{Issuers: []string{"https://gitlab.example.com"}, Audience: "witness"}) {
+		t.Errorf("unexpected verification options %+v", opts)
+	}
+
+	subjects := a.Subjects()
+	for _, subject := range []string{
+		"gitlabclaim:project_path=group/app",
+		"gitlabclaim:ref=v1.2.0",
+		"gitlabclaim:ref_protected=true",
+		"gitlabclaim:environment=production",
+		"projecturl:https://gitlab.example.com/group/app",
+	} {
+		if _, ok := subjects[subject]; !ok {
+			t.Errorf("expected subject %v in %v", subject, subjects)
+		}
+	}
+
+	verifyToken = func(ctx context.Context, token string, o oidctoken.Options) (*oidctoken.Token, error) {
+		return nil, fmt.Errorf("id token is not valid")
+	}
+
+	if err := New().Attest(newContext(t)); err == nil {
+		t.Error("expected a token that fails verification to fail the attestor")
+	}
+}
+
+func TestAttestNotGitLab(t *testing.T) {
+	t.Setenv("GITLAB_CI", "")
+	if err := New().Attest(newContext(t)); err == nil {
+		t.Error("expected an error outside of gitlab ci")
+	}
+}
@@ -0,0 +1,96 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package heartbeat
+
+import (
+	"crypto"
+	"fmt"
+	"time"
+
+	"github.com/testifysec/go-witness/attestation"
+	"github.com/testifysec/go-witness/attestation/file"
+	"github.com/testifysec/go-witness/cryptoutil"
+)
+
+const (
+	Name    = "heartbeat"
+	Type    = "https://witness.dev/attestations/heartbeat/v0.1"
+	RunType = attestation.Internal
+)
+
+func init() {
+	attestation.RegisterAttestation(Name, Type, RunType, func() attestation.Attestor {
+		return New()
+	})
+}
+
+// Attestor records the state of a step that is still running. It is not run with the other attestors; witness run
+// records one every heartbeat interval so a runner that crashes during a long step still leaves signed evidence of its
+// progress.
+type Attestor struct {
+	Sequence  int                             `json:"sequence"`
+	StartedAt time.Time                       `json:"startedat"`
+	Time      time.Time                       `json:"time"`
+	Materials map[string]cryptoutil.DigestSet `json:"materials"`
+	Processes []Process                       `json:"processes"`
+}
+
+type Process struct {
+	ProcessID int    `json:"processid"`
+	ParentPID int    `json:"parentpid"`
+	Comm      string `json:"comm,omitempty"`
+	Cmdline   string `json:"cmdline,omitempty"`
+}
+
+func New() *Attestor {
+	return &Attestor{}
+}
+
+func (a *Attestor) Name() string {
+	return Name
+}
+
+func (a *Attestor) Type() string {
+	return Type
+}
+
+func (a *Attestor) RunType() attestation.RunType {
+	return RunType
+}
+
+func (a *Attestor) Attest(ctx *attestation.AttestationContext) error {
+	return nil
+}
+
+// Record captures the digests of the files in workingDir and the processes started by this process.
+func Record(sequence int, startedAt time.Time, workingDir string, hashes []crypto.Hash) (*Attestor, error) {
+	materials, err := file.RecordArtifacts(workingDir, map[string]cryptoutil.DigestSet{}, hashes, map[string]struct{}{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to record materials: %w", err)
+	}
+
+	processes, err := descendants()
+	if err != nil {
+		return nil, fmt.Errorf("failed to record processes: %w", err)
+	}
+
+	return &Attestor{
+		Sequence:  sequence,
+		StartedAt: startedAt,
+		Time:      time.Now(),
+		Materials: materials,
+		Processes: processes,
+	}, nil
+}
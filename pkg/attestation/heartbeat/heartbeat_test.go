@@ -0,0 +1,66 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package heartbeat
+
+import (
+	"crypto"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestRecord(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cmd := exec.Command("sleep", "5")
+	if err := cmd.Start(); err != nil {
+		t.Skipf("unable to start child process: %v", err)
+	}
+
+	defer func() {
+		_ = cmd.Process.Kill()
+		_ = cmd.Wait()
+	}()
+
+	hb, err := Record(3, time.Now().Add(-time.Hour), dir, []crypto.Hash{crypto.SHA256})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if hb.Sequence != 3 || len(hb.Materials) != 1 || hb.Materials["main.go"][crypto.SHA256] == "" {
+		t.Errorf("unexpected heartbeat: %+v", hb)
+	}
+
+	if runtime.GOOS != "linux" {
+		return
+	}
+
+	found := false
+	for _, p := range hb.Processes {
+		if p.ProcessID == cmd.Process.Pid && p.ParentPID == os.Getpid() {
+			found = true
+		}
+	}
+
+	if !found {
+		t.Errorf("expected child process %d in heartbeat: %+v", cmd.Process.Pid, hb.Processes)
+	}
+}
@@ -0,0 +1,447 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package imagelayers
+
+import (
+	"crypto"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/testifysec/go-witness/attestation"
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/log"
+	"github.com/testifysec/witness/pkg/archive"
+	"github.com/testifysec/witness/pkg/digest"
+)
+
+const (
+	Name    = "image-layers"
+	Type    = "https://witness.dev/attestations/image-layers/v0.1"
+	RunType = attestation.PostRunType
+
+	whiteoutPrefix = ".wh."
+	opaqueWhiteout = ".wh..wh..opq"
+	// maxMetadataSize is the largest blob read into memory while looking for OCI indexes and manifests.
+	maxMetadataSize = 1 << 20
+)
+
+func init() {
+	attestation.RegisterAttestation(Name, Type, RunType, func() attestation.Attestor {
+		return New()
+	})
+}
+
+// Attestor maps the layers of each container image among the products to the files they contain, and the files to
+// the materials with the same digest.
+type Attestor struct {
+	Images map[string][]Image `json:"images"`
+}
+
+type Image struct {
+	Name   string  `json:"name,omitempty"`
+	Layers []Layer `json:"layers"`
+	// Unmatched holds the files in the image's final filesystem that do not match any material.
+	Unmatched []string `json:"unmatched"`
+}
+
+type Layer struct {
+	Path   string               `json:"path"`
+	Digest cryptoutil.DigestSet `json:"digest"`
+	Files  map[string]File      `json:"files"`
+	// Deleted holds the paths removed from lower layers by whiteouts. Paths ending in / had their contents removed.
+	Deleted []string `json:"deleted,omitempty"`
+}
+
+type File struct {
+	Digest    cryptoutil.DigestSet `json:"digest"`
+	Materials []string             `json:"materials,omitempty"`
+}
+
+type dockerManifest struct {
+	RepoTags []string `json:"RepoTags"`
+	Layers   []string `json:"Layers"`
+}
+
+type ociDescriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Annotations map[string]string `json:"annotations"`
+}
+
+type ociManifest struct {
+	Manifests []ociDescriptor `json:"manifests"`
+	Layers    []ociDescriptor `json:"layers"`
+}
+
+type imageLayout struct {
+	name   string
+	layers []string
+}
+
+func New() *Attestor {
+	return &Attestor{}
+}
+
+func (a *Attestor) Name() string {
+	return Name
+}
+
+func (a *Attestor) Type() string {
+	return Type
+}
+
+func (a *Attestor) RunType() attestation.RunType {
+	return RunType
+}
+
+func (a *Attestor) Attest(ctx *attestation.AttestationContext) error {
+	a.Images = make(map[string][]Image)
+	materials := indexMaterials(ctx.Materials())
+	for name := range ctx.Products() {
+		productPath := filepath.Join(ctx.WorkingDir(), name)
+		isArchive, err := archive.IsArchive(productPath)
+		if err != nil {
+			log.Debugf("(attestation/image-layers) failed to read product %v: %v", name, err)
+			continue
+		}
+
+		if !isArchive {
+			continue
+		}
+
+		layouts, err := readLayouts(productPath)
+		if err != nil {
+			log.Debugf("(attestation/image-layers) failed to read image metadata from %v: %v", name, err)
+			continue
+		}
+
+		if len(layouts) == 0 {
+			continue
+		}
+
+		images, err := readImages(productPath, layouts, ctx.Hashes(), materials)
+		if err != nil {
+			return fmt.Errorf("failed to read layers of image %v: %w", name, err)
+		}
+
+		a.Images[name] = images
+	}
+
+	return nil
+}
+
+func (a *Attestor) Subjects() map[string]cryptoutil.DigestSet {
+	subjects := make(map[string]cryptoutil.DigestSet)
+	for _, images := range a.Images {
+		for _, image := range images {
+			for _, layer := range image.Layers {
+				subjects[fmt.Sprintf("imagelayer:%v", layer.Digest[crypto.SHA256])] = layer.Digest
+			}
+		}
+	}
+
+	return subjects
+}
+
+// readLayouts returns the images in a docker save or OCI layout archive and the paths of their layers, bottom layer
+// first. Archives with a docker manifest.json are read from it, and others from their OCI index.json.
+func readLayouts(archivePath string) ([]imageLayout, error) {
+	metadata := make(map[string][]byte)
+	err := archive.Walk(archivePath, func(entry archive.Entry, r io.Reader) error {
+		if !entry.Mode.IsRegular() {
+			return nil
+		}
+
+		if entry.Name != "manifest.json" && entry.Name != "index.json" && !strings.HasPrefix(entry.Name, "blobs/") {
+			return nil
+		}
+
+		data, err := io.ReadAll(io.LimitReader(r, maxMetadataSize+1))
+		if err != nil {
+			return err
+		}
+
+		if len(data) <= maxMetadataSize {
+			metadata[entry.Name] = data
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if data, ok := metadata["manifest.json"]; ok {
+		manifests := []dockerManifest{}
+		if err := json.Unmarshal(data, &manifests); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal manifest.json: %w", err)
+		}
+
+		layouts := make([]imageLayout, 0, len(manifests))
+		for _, manifest := range manifests {
+			layout := imageLayout{layers: make([]string, 0, len(manifest.Layers))}
+			if len(manifest.RepoTags) > 0 {
+				layout.name = manifest.RepoTags[0]
+			}
+
+			for _, layer := range manifest.Layers {
+				layout.layers = append(layout.layers, path.Clean(layer))
+			}
+
+			layouts = append(layouts, layout)
+		}
+
+		return layouts, nil
+	}
+
+	if data, ok := metadata["index.json"]; ok {
+		layouts := make([]imageLayout, 0)
+		if err := resolveIndex(data, "", metadata, &layouts, 0); err != nil {
+			return nil, err
+		}
+
+		return layouts, nil
+	}
+
+	return nil, nil
+}
+
+// resolveIndex follows an OCI index, and any nested indexes, to the image manifests it references.
+func resolveIndex(data []byte, name string, metadata map[string][]byte, layouts *[]imageLayout, depth int) error {
+	if depth > 4 {
+		return fmt.Errorf("oci index nested too deeply")
+	}
+
+	index := ociManifest{}
+	if err := json.Unmarshal(data, &index); err != nil {
+		return fmt.Errorf("failed to unmarshal oci index: %w", err)
+	}
+
+	for _, desc := range index.Manifests {
+		blob, ok := metadata[blobPath(desc.Digest)]
+		if !ok {
+			return fmt.Errorf("oci layout is missing blob %v", desc.Digest)
+		}
+
+		manifestName := name
+		if ref, ok := desc.Annotations["org.opencontainers.image.ref.name"]; ok && manifestName == "" {
+			manifestName = ref
+		}
+
+		manifest := ociManifest{}
+		if err := json.Unmarshal(blob, &manifest); err != nil {
+			return fmt.Errorf("failed to unmarshal oci manifest %v: %w", desc.Digest, err)
+		}
+
+		if len(manifest.Manifests) > 0 {
+			if err := resolveIndex(blob, manifestName, metadata, layouts, depth+1); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		layout := imageLayout{name: manifestName, layers: make([]string, 0, len(manifest.Layers))}
+		for _, layer := range manifest.Layers {
+			layout.layers = append(layout.layers, blobPath(layer.Digest))
+		}
+
+		*layouts = append(*layouts, layout)
+	}
+
+	return nil
+}
+
+func blobPath(digest string) string {
+	return path.Join("blobs", strings.Replace(digest, ":", "/", 1))
+}
+
+// readImages reads each layer referenced by the layouts once and assembles the images from them.
+func readImages(archivePath string, layouts []imageLayout, hashes []crypto.Hash, materials map[string][]string) ([]Image, error) {
+	wanted := make(map[string]bool)
+	for _, layout := range layouts {
+		for _, layer := range layout.layers {
+			wanted[layer] = true
+		}
+	}
+
+	layers := make(map[string]Layer)
+	err := archive.Walk(archivePath, func(entry archive.Entry, r io.Reader) error {
+		if !entry.Mode.IsRegular() || !wanted[entry.Name] {
+			return nil
+		}
+
+		layer, err := readLayer(r, hashes, materials)
+		if err != nil {
+			return fmt.Errorf("failed to read layer %v: %w", entry.Name, err)
+		}
+
+		layer.Path = entry.Name
+		layers[entry.Name] = layer
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	images := make([]Image, 0, len(layouts))
+	for _, layout := range layouts {
+		image := Image{Name: layout.name, Layers: make([]Layer, 0, len(layout.layers))}
+		for _, layerPath := range layout.layers {
+			layer, ok := layers[layerPath]
+			if !ok {
+				return nil, fmt.Errorf("image is missing layer %v", layerPath)
+			}
+
+			image.Layers = append(image.Layers, layer)
+		}
+
+		image.Unmatched = unmatchedFiles(image.Layers)
+		images = append(images, image)
+	}
+
+	return images, nil
+}
+
+// readLayer records the files in a tar or gzipped tar layer while hashing the layer as it is stored.
+func readLayer(r io.Reader, hashes []crypto.Hash, materials map[string][]string) (Layer, error) {
+	type result struct {
+		digest cryptoutil.DigestSet
+		err    error
+	}
+
+	pr, pw := io.Pipe()
+	done := make(chan result, 1)
+	go func() {
+		ds, err := digest.Calculate(pr, hashes)
+		pr.CloseWithError(err)
+		done <- result{ds, err}
+	}()
+
+	layer := Layer{Files: make(map[string]File)}
+	tee := io.TeeReader(r, pw)
+	err := archive.WalkStream(tee, func(entry archive.Entry, r io.Reader) error {
+		dir, base := path.Split(entry.Name)
+		if base == opaqueWhiteout {
+			layer.Deleted = append(layer.Deleted, dir)
+			return nil
+		}
+
+		if strings.HasPrefix(base, whiteoutPrefix) {
+			layer.Deleted = append(layer.Deleted, dir+strings.TrimPrefix(base, whiteoutPrefix))
+			return nil
+		}
+
+		if !entry.Mode.IsRegular() {
+			return nil
+		}
+
+		ds, err := digest.Calculate(r, hashes)
+		if err != nil {
+			return fmt.Errorf("failed to calculate digest of %v: %w", entry.Name, err)
+		}
+
+		layer.Files[entry.Name] = File{Digest: ds, Materials: matchMaterials(ds, materials)}
+		return nil
+	})
+
+	// the tar stream can end before the layer does, so hash the rest of the layer too
+	if err == nil {
+		_, err = io.Copy(io.Discard, tee)
+	}
+
+	pw.CloseWithError(err)
+	res := <-done
+	if err != nil {
+		return layer, err
+	}
+
+	if res.err != nil {
+		return layer, fmt.Errorf("failed to calculate layer digest: %w", res.err)
+	}
+
+	layer.Digest = res.digest
+	return layer, nil
+}
+
+// unmatchedFiles applies the layers in order, including their whiteouts, and returns the files in the resulting
+// filesystem that do not match a material.
+func unmatchedFiles(layers []Layer) []string {
+	files := make(map[string]File)
+	for _, layer := range layers {
+		for _, deleted := range layer.Deleted {
+			prefix := strings.TrimSuffix(deleted, "/") + "/"
+			for name := range files {
+				if name == deleted || strings.HasPrefix(name, prefix) {
+					delete(files, name)
+				}
+			}
+		}
+
+		for name, file := range layer.Files {
+			files[name] = file
+		}
+	}
+
+	unmatched := make([]string, 0)
+	for name, file := range files {
+		if len(file.Materials) == 0 {
+			unmatched = append(unmatched, name)
+		}
+	}
+
+	sort.Strings(unmatched)
+	return unmatched
+}
+
+// indexMaterials maps each digest of each material, in the form algorithm:digest, to the materials with that digest.
+func indexMaterials(materials map[string]cryptoutil.DigestSet) map[string][]string {
+	index := make(map[string][]string)
+	for name, ds := range materials {
+		for hash, value := range ds {
+			key := fmt.Sprintf("%v:%v", hash, value)
+			index[key] = append(index[key], name)
+		}
+	}
+
+	return index
+}
+
+func matchMaterials(ds cryptoutil.DigestSet, materials map[string][]string) []string {
+	seen := make(map[string]bool)
+	matches := make([]string, 0)
+	for hash, value := range ds {
+		for _, name := range materials[fmt.Sprintf("%v:%v", hash, value)] {
+			if !seen[name] {
+				seen[name] = true
+				matches = append(matches, name)
+			}
+		}
+	}
+
+	if len(matches) == 0 {
+		return nil
+	}
+
+	sort.Strings(matches)
+	return matches
+}
@@ -0,0 +1,175 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package imagelayers
+
+import (
+	"archive/tar"
+	"bytes"
+	"crypto"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/testifysec/go-witness/cryptoutil"
+)
+
+func tarBytes(t *testing.T, files map[string]string) []byte {
+	buf := &bytes.Buffer{}
+	tw := tar.NewWriter(buf)
+	for name, content := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(content))}); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := tw.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+func sha256Hex(data []byte) string {
+	return fmt.Sprintf("%x", sha256.Sum256(data))
+}
+
+func writeImage(t *testing.T, files map[string][]byte) string {
+	stringFiles := make(map[string]string)
+	for name, content := range files {
+		stringFiles[name] = string(content)
+	}
+
+	imagePath := filepath.Join(t.TempDir(), "image.tar")
+	if err := os.WriteFile(imagePath, tarBytes(t, stringFiles), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	return imagePath
+}
+
+func testLayers(t *testing.T) ([]byte, []byte) {
+	base := tarBytes(t, map[string]string{
+		"bin/app":       "app v1",
+		"etc/config":    "config",
+		"usr/lib/a.so":  "a",
+		"usr/lib/b.so":  "b",
+		"var/cache/tmp": "tmp",
+	})
+
+	top := tarBytes(t, map[string]string{
+		"bin/app":               "app v2",
+		"etc/.wh.config":        "",
+		"usr/lib/.wh..wh..opq":  "",
+		"usr/lib/c.so":          "c",
+		"var/cache/.wh.nothing": "",
+	})
+
+	return base, top
+}
+
+func checkImage(t *testing.T, image Image, base, top []byte) {
+	if len(image.Layers) != 2 {
+		t.Fatalf("expected 2 layers, got %d", len(image.Layers))
+	}
+
+	if image.Layers[0].Digest[crypto.SHA256] != sha256Hex(base) || image.Layers[1].Digest[crypto.SHA256] != sha256Hex(top) {
+		t.Errorf("unexpected layer digests: %v %v", image.Layers[0].Digest, image.Layers[1].Digest)
+	}
+
+	app := image.Layers[1].Files["bin/app"]
+	if app.Digest[crypto.SHA256] != sha256Hex([]byte("app v2")) || !reflect.DeepEqual(app.Materials, []string{"src/app"}) {
+		t.Errorf("unexpected bin/app record: %+v", app)
+	}
+
+	expectedUnmatched := []string{"usr/lib/c.so", "var/cache/tmp"}
+	if !reflect.DeepEqual(image.Unmatched, expectedUnmatched) {
+		t.Errorf("expected unmatched files %v, got %v", expectedUnmatched, image.Unmatched)
+	}
+}
+
+func testMaterials() map[string][]string {
+	return indexMaterials(map[string]cryptoutil.DigestSet{
+		"src/app": {crypto.SHA256: sha256Hex([]byte("app v2"))},
+	})
+}
+
+func TestDockerSave(t *testing.T) {
+	base, top := testLayers(t)
+	imagePath := writeImage(t, map[string][]byte{
+		"manifest.json":  []byte(`[{"Config":"config.json","RepoTags":["example/app:1.0"],"Layers":["base/layer.tar","top/layer.tar"]}]`),
+		"base/layer.tar": base,
+		"top/layer.tar":  top,
+	})
+
+	layouts, err := readLayouts(imagePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	images, err := readImages(imagePath, layouts, []crypto.Hash{crypto.SHA256}, testMaterials())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(images) != 1 || images[0].Name != "example/app:1.0" {
+		t.Fatalf("unexpected images: %+v", images)
+	}
+
+	checkImage(t, images[0], base, top)
+}
+
+func TestOCILayout(t *testing.T) {
+	base, top := testLayers(t)
+	manifest := []byte(fmt.Sprintf(`{"layers":[{"digest":"sha256:%v"},{"digest":"sha256:%v"}]}`, sha256Hex(base), sha256Hex(top)))
+	imageIndex := []byte(fmt.Sprintf(`{"manifests":[{"digest":"sha256:%v"}]}`, sha256Hex(manifest)))
+	imagePath := writeImage(t, map[string][]byte{
+		"index.json":                            []byte(fmt.Sprintf(`{"manifests":[{"digest":"sha256:%v","annotations":{"org.opencontainers.image.ref.name":"1.0"}}]}`, sha256Hex(imageIndex))),
+		"blobs/sha256/" + sha256Hex(imageIndex): imageIndex,
+		"blobs/sha256/" + sha256Hex(manifest):   manifest,
+		"blobs/sha256/" + sha256Hex(base):       base,
+		"blobs/sha256/" + sha256Hex(top):        top,
+	})
+
+	layouts, err := readLayouts(imagePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	images, err := readImages(imagePath, layouts, []crypto.Hash{crypto.SHA256}, testMaterials())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(images) != 1 || images[0].Name != "1.0" {
+		t.Fatalf("unexpected images: %+v", images)
+	}
+
+	checkImage(t, images[0], base, top)
+}
+
+func TestNotAnImage(t *testing.T) {
+	imagePath := writeImage(t, map[string][]byte{"README.md": []byte("readme")})
+	layouts, err := readLayouts(imagePath)
+	if err != nil || len(layouts) != 0 {
+		t.Fatalf("expected no images, got %v %v", layouts, err)
+	}
+}
@@ -0,0 +1,151 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jenkins
+
+import (
+	"crypto"
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/testifysec/go-witness/attestation"
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/log"
+	"github.com/testifysec/witness/pkg/oidctoken"
+)
+
+const (
+	Name    = "jenkins"
+	Type    = "https://witness.dev/attestations/jenkins/v0.1"
+	RunType = attestation.PreRunType
+
+	// IssuerEnv overrides the issuer ID tokens must be from. The OpenID Connect Provider plugin issues tokens as the
+	// controller's /oidc URL by default.
+	IssuerEnv = "WITNESS_JENKINS_OIDC_ISSUER"
+)
+
+// verifyToken is replaced in tests
+var verifyToken = oidctoken.Verify
+
+// backedClaims are the claims of the OpenID Connect Provider plugin's ID tokens returned as subjects. sub is the
+// build's job URL unless the plugin is configured otherwise.
+var backedClaims = []string{"sub", "build_number"}
+
+func init() {
+	attestation.RegisterAttestation(Name, Type, RunType, func() attestation.Attestor {
+		return New()
+	})
+}
+
+// Attestor records the Jenkins build witness is running in, from the variables Jenkins sets for the build and those
+// the Git plugin and multibranch pipelines add. Jenkins doesn't issue ID tokens by itself; one bound from an OpenID
+// Connect Provider plugin credential to WITNESS_ID_TOKEN is verified against the controller's keys and recorded in
+// Token.
+type Attestor struct {
+	URL          string           `json:"url"`
+	JobName      string           `json:"jobname"`
+	JobURL       string           `json:"joburl"`
+	BuildID      string           `json:"buildid"`
+	BuildNumber  string           `json:"buildnumber"`
+	BuildURL     string           `json:"buildurl"`
+	BuildTag     string           `json:"buildtag"`
+	NodeName     string           `json:"nodename"`
+	Executor     string           `json:"executor,omitempty"`
+	GitURL       string           `json:"giturl,omitempty"`
+	GitBranch    string           `json:"gitbranch,omitempty"`
+	GitCommit    string           `json:"gitcommit,omitempty"`
+	ChangeID     string           `json:"changeid,omitempty"`
+	ChangeTarget string           `json:"changetarget,omitempty"`
+	Token        *oidctoken.Token `json:"token,omitempty"`
+}
+
+func New() *Attestor {
+	return &Attestor{}
+}
+
+func (a *Attestor) Name() string {
+	return Name
+}
+
+func (a *Attestor) Type() string {
+	return Type
+}
+
+func (a *Attestor) RunType() attestation.RunType {
+	return RunType
+}
+
+func (a *Attestor) Attest(ctx *attestation.AttestationContext) error {
+	a.URL = os.Getenv("JENKINS_URL")
+	if a.URL == "" || os.Getenv("BUILD_ID") == "" {
+		return fmt.Errorf("not running in a jenkins build")
+	}
+
+	a.JobName = os.Getenv("JOB_NAME")
+	a.JobURL = os.Getenv("JOB_URL")
+	a.BuildID = os.Getenv("BUILD_ID")
+	a.BuildNumber = os.Getenv("BUILD_NUMBER")
+	a.BuildURL = os.Getenv("BUILD_URL")
+	a.BuildTag = os.Getenv("BUILD_TAG")
+	a.NodeName = os.Getenv("NODE_NAME")
+	a.Executor = os.Getenv("EXECUTOR_NUMBER")
+	a.GitURL = os.Getenv("GIT_URL")
+	a.GitBranch = os.Getenv("GIT_BRANCH")
+	a.GitCommit = os.Getenv("GIT_COMMIT")
+	a.ChangeID = os.Getenv("CHANGE_ID")
+	a.ChangeTarget = os.Getenv("CHANGE_TARGET")
+
+	token := os.Getenv(oidctoken.TokenEnv)
+	if token == "" {
+		log.Debugf("(attestation/jenkins) no id token is available, recording the build's variables only")
+		return nil
+	}
+
+	issuer := os.Getenv(IssuerEnv)
+	if issuer == "" {
+		issuer = strings.TrimSuffix(a.URL, "/") + "/oidc"
+	}
+
+	verified, err := verifyToken(ctx.Context(), token, oidctoken.Options{
+		Issuers:  []string{issuer},
+		Audience: os.Getenv(oidctoken.AudienceEnv),
+	})
+	if err != nil {
+		return err
+	}
+
+	a.Token = verified
+	return nil
+}
+
+// Subjects returns the build and job URLs, and a jenkinsclaim:<claim>=<value> subject for the subject and build
+// number of a verified ID token.
+func (a *Attestor) Subjects() map[string]cryptoutil.DigestSet {
+	subjects := make(map[string]cryptoutil.DigestSet)
+	for prefix, url := range map[string]string{"buildurl": a.BuildURL, "joburl": a.JobURL} {
+		if url != "" {
+			subjects[fmt.Sprintf("%v:%v", prefix, url)] = cryptoutil.DigestSet{crypto.SHA256: fmt.Sprintf("%x", sha256.Sum256([]byte(url)))}
+		}
+	}
+
+	if a.Token != nil {
+		for subject, digest := range a.Token.Subjects("jenkinsclaim", backedClaims) {
+			subjects[subject] = digest
+		}
+	}
+
+	return subjects
+}
@@ -0,0 +1,118 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package jenkins
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/testifysec/go-witness/attestation"
+	"github.com/testifysec/witness/pkg/oidctoken"
+)
+
+func newContext(t *testing.T) *attestation.AttestationContext {
+	ctx, err := attestation.NewContext([]attestation.Attestor{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return ctx
+}
+
+func setBuildEnv(t *testing.T) {
+	for name, value := range map[string]string{
+		"JENKINS_URL":         "https://jenkins.example.com/",
+		"JOB_NAME":            "app/main",
+		"JOB_URL":             "https://jenkins.example.com/job/app/job/main/",
+		"BUILD_ID":            "31",
+		"BUILD_NUMBER":        "31",
+		"BUILD_URL":           "https://jenkins.example.com/job/app/job/main/31/",
+		"BUILD_TAG":           "jenkins-app-main-31",
+		"NODE_NAME":           "linux-agent-2",
+		"GIT_URL":             "https://git.example.com/app.git",
+		"GIT_BRANCH":          "origin/main",
+		"GIT_COMMIT":          "0123456789abcdef",
+		oidctoken.TokenEnv:    "",
+		oidctoken.AudienceEnv: "",
+		IssuerEnv:             "",
+	} {
+		t.Setenv(name, value)
+	}
+}
+
+func TestAttest(t *testing.T) {
+	setBuildEnv(t)
+	a := New()
+	if err := a.Attest(newContext(t)); err != nil {
+		t.Fatal(err)
+	}
+
+	if a.JobName != "app/main" || a.BuildNumber != "31" || a.NodeName != "linux-agent-2" || a.GitCommit != "0123456789abcdef" {
+		t.Errorf("unexpected attestation %+v", a)
+	}
+
+	subjects := a.Subjects()
+	if _, ok := subjects["buildurl:https://jenkins.example.com/job/app/job/main/31/"]; !ok || len(subjects) != 2 {
+		t.Errorf("expected the build and job url subjects, got %v", subjects)
+	}
+}
+
+func TestAttestVerifiesToken(t *testing.T) {
+	setBuildEnv(t)
+	t.Setenv(oidctoken.TokenEnv, "token")
+	oldVerify := verifyToken
+	defer func() { verifyToken = oldVerify }()
+	var opts oidctoken.Options
+	verifyToken = func(ctx context.Context, token string, o oidctoken.Options) (*oidctoken.Token, error) {
+		opts = o
+		return &oidctoken.Token{Issuer: "https://jenkins.example.com/oidc", Claims: map[string]interface{}{
+			"sub":          "https://jenkins.example.com/job/app/job/main/",
+			"build_number": float64(31),
+		}}, nil
+	}
+
+	a := New()
+	if err := a.Attest(newContext(t)); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(opts.Issuers, []string{"https://jenkins.example.com/oidc"}) {
+		t.Errorf("expected the controller's oidc issuer, got %v", opts.Issuers)
+	}
+
+	subjects := a.Subjects()
+	for _, subject := range []string{"jenkinsclaim:sub=https://jenkins.example.com/job/app/job/main/", "jenkinsclaim:build_number=31"} {
+		if _, ok := subjects[subject]; !ok {
+			t.Errorf("expected subject %v in %v", subject, subjects)
+		}
+	}
+
+	t.Setenv(IssuerEnv, "https://oidc.example.com")
+	if err := New().Attest(newContext(t)); err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(opts.Issuers, []string{"https://oidc.example.com"}) {
+		t.Errorf("expected the configured issuer, got %v", opts.Issuers)
+	}
+}
+
+func TestAttestNotJenkins(t *testing.T) {
+	t.Setenv("JENKINS_URL", "")
+	if err := New().Attest(newContext(t)); err == nil {
+		t.Error("expected an error outside of jenkins")
+	}
+}
@@ -0,0 +1,139 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package keyattestation
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+
+	"github.com/testifysec/go-witness/attestation"
+	"github.com/testifysec/go-witness/cryptoutil"
+)
+
+const (
+	Name    = "key-attestation"
+	Type    = "https://witness.dev/attestations/key-attestation/v0.1"
+	RunType = attestation.PostRunType
+)
+
+func init() {
+	attestation.RegisterAttestation(Name, Type, RunType, func() attestation.Attestor {
+		return New()
+	})
+}
+
+// Attestor embeds the key attestation certificate issued for the signing key by the HSM or key management service
+// that holds it, followed by the certificates linking it to the vendor's attestation root. It is not run; Collection
+// adds it to the collections signed with the attested key.
+type Attestor struct {
+	Certificates []string `json:"certificates"`
+}
+
+func New() *Attestor {
+	return &Attestor{}
+}
+
+func (a *Attestor) Name() string {
+	return Name
+}
+
+func (a *Attestor) Type() string {
+	return Type
+}
+
+func (a *Attestor) RunType() attestation.RunType {
+	return RunType
+}
+
+func (a *Attestor) Attest(ctx *attestation.AttestationContext) error {
+	return nil
+}
+
+// Load reads a PEM file holding the key attestation certificate followed by its chain, and checks that the
+// attestation certificate is for the signer's key by verifying a signature made with it.
+func Load(path string, signer cryptoutil.Signer) (*Attestor, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key attestation: %w", err)
+	}
+
+	a := New()
+	for block, rest := pem.Decode(data); block != nil; block, rest = pem.Decode(rest) {
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+
+		a.Certificates = append(a.Certificates, string(pem.EncodeToMemory(block)))
+	}
+
+	certs, err := a.Parse()
+	if err != nil {
+		return nil, err
+	}
+
+	verifier, err := cryptoutil.NewVerifier(certs[0].PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create verifier for attested key: %w", err)
+	}
+
+	challenge := []byte("witness key attestation")
+	sig, err := signer.Sign(bytes.NewReader(challenge))
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign key attestation challenge: %w", err)
+	}
+
+	if err := verifier.Verify(bytes.NewReader(challenge), sig); err != nil {
+		return nil, fmt.Errorf("key attestation is not for the signing key: %w", err)
+	}
+
+	return a, nil
+}
+
+// Parse returns the attestation certificate followed by its chain.
+func (a *Attestor) Parse() ([]*x509.Certificate, error) {
+	certs := make([]*x509.Certificate, 0, len(a.Certificates))
+	for _, certPEM := range a.Certificates {
+		block, _ := pem.Decode([]byte(certPEM))
+		if block == nil {
+			return nil, fmt.Errorf("key attestation certificate is not PEM encoded")
+		}
+
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse key attestation certificate: %w", err)
+		}
+
+		certs = append(certs, cert)
+	}
+
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("key attestation does not contain any certificates")
+	}
+
+	return certs, nil
+}
+
+// Collection returns a copy of the collection with the key attestation appended.
+func Collection(collection attestation.Collection, a *Attestor) attestation.Collection {
+	attestors := make([]attestation.Attestor, 0, len(collection.Attestations)+1)
+	for _, ca := range collection.Attestations {
+		attestors = append(attestors, ca.Attestation)
+	}
+
+	return attestation.NewCollection(collection.Name, append(attestors, a))
+}
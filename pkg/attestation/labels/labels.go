@@ -0,0 +1,121 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package labels
+
+import (
+	"crypto"
+	"crypto/sha256"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/testifysec/go-witness/attestation"
+	"github.com/testifysec/go-witness/cryptoutil"
+)
+
+const (
+	Name    = "labels"
+	Type    = "https://witness.dev/attestations/labels/v0.1"
+	RunType = attestation.PostRunType
+)
+
+// keyPattern restricts label keys to characters that are safe in subject names and rego references.
+var keyPattern = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9._/-]*[A-Za-z0-9])?$`)
+
+func init() {
+	attestation.RegisterAttestation(Name, Type, RunType, func() attestation.Attestor {
+		return New()
+	})
+}
+
+// Attestor records the labels passed to witness run. It is not run; Collection adds it to the collections it labels.
+type Attestor struct {
+	Labels map[string]string `json:"labels"`
+}
+
+func New() *Attestor {
+	return &Attestor{Labels: map[string]string{}}
+}
+
+func (a *Attestor) Name() string {
+	return Name
+}
+
+func (a *Attestor) Type() string {
+	return Type
+}
+
+func (a *Attestor) RunType() attestation.RunType {
+	return RunType
+}
+
+func (a *Attestor) Attest(ctx *attestation.AttestationContext) error {
+	return nil
+}
+
+// Subjects returns a label:<key>=<value> subject for each label so collections can be looked up by their labels.
+func (a *Attestor) Subjects() map[string]cryptoutil.DigestSet {
+	subjects := make(map[string]cryptoutil.DigestSet, len(a.Labels))
+	for key, value := range a.Labels {
+		label := fmt.Sprintf("%v=%v", key, value)
+		subjects["label:"+label] = cryptoutil.DigestSet{crypto.SHA256: fmt.Sprintf("%x", sha256.Sum256([]byte(label)))}
+	}
+
+	return subjects
+}
+
+// Parse parses labels in key=value form. Keys may only be given once.
+func Parse(labels []string) (map[string]string, error) {
+	parsed := make(map[string]string, len(labels))
+	for _, label := range labels {
+		i := strings.Index(label, "=")
+		if i < 0 {
+			return nil, fmt.Errorf("label %v is not in key=value form", label)
+		}
+
+		key, value := label[:i], label[i+1:]
+		if !keyPattern.MatchString(key) {
+			return nil, fmt.Errorf("invalid label key %v: keys may only contain letters, digits, '.', '_', '/', and '-'", key)
+		}
+
+		if _, ok := parsed[key]; ok {
+			return nil, fmt.Errorf("label %v is set more than once", key)
+		}
+
+		parsed[key] = value
+	}
+
+	return parsed, nil
+}
+
+// Collection returns a copy of the collection with a labels attestation recording the labels appended. changed is
+// false if there are no labels.
+func Collection(collection attestation.Collection, labels map[string]string) (labeled attestation.Collection, changed bool) {
+	if len(labels) == 0 {
+		return collection, false
+	}
+
+	record := New()
+	for key, value := range labels {
+		record.Labels[key] = value
+	}
+
+	attestors := make([]attestation.Attestor, 0, len(collection.Attestations)+1)
+	for _, ca := range collection.Attestations {
+		attestors = append(attestors, ca.Attestation)
+	}
+
+	return attestation.NewCollection(collection.Name, append(attestors, record)), true
+}
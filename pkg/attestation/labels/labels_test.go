@@ -0,0 +1,60 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package labels
+
+import (
+	"testing"
+
+	"github.com/testifysec/go-witness/attestation"
+	"github.com/testifysec/go-witness/attestation/environment"
+)
+
+func TestParse(t *testing.T) {
+	labels, err := Parse([]string{"team=payments", "env=prod", "note=a=b", "empty="})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if labels["team"] != "payments" || labels["env"] != "prod" || labels["note"] != "a=b" || labels["empty"] != "" {
+		t.Errorf("unexpected labels: %v", labels)
+	}
+
+	for _, invalid := range [][]string{{"team"}, {"=payments"}, {"team name=payments"}, {"team=a", "team=b"}} {
+		if _, err := Parse(invalid); err == nil {
+			t.Errorf("expected %v to be rejected", invalid)
+		}
+	}
+}
+
+func TestCollection(t *testing.T) {
+	collection := attestation.NewCollection("build", []attestation.Attestor{environment.New()})
+	if _, changed := Collection(collection, nil); changed {
+		t.Error("expected collection without labels to be unchanged")
+	}
+
+	labeled, changed := Collection(collection, map[string]string{"team": "payments"})
+	if !changed || len(labeled.Attestations) != 2 {
+		t.Fatalf("expected labels attestation to be appended: %+v", labeled)
+	}
+
+	record, ok := labeled.Attestations[1].Attestation.(*Attestor)
+	if !ok || record.Labels["team"] != "payments" {
+		t.Errorf("unexpected labels attestation: %+v", labeled.Attestations[1])
+	}
+
+	if _, ok := labeled.Subjects()[Type+"/label:team=payments"]; !ok {
+		t.Errorf("expected label subject, got %v", labeled.Subjects())
+	}
+}
@@ -0,0 +1,86 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package material records the files in the working directory before the command runs, limited to the files selected
+// by include and exclude patterns and hashing several files at a time. It records the same attestation as go-witness's
+// material attestor, which stays registered to read it.
+package material
+
+import (
+	"encoding/json"
+
+	"github.com/testifysec/go-witness/attestation"
+	gwmaterial "github.com/testifysec/go-witness/attestation/material"
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/witness/pkg/artifacts"
+)
+
+const (
+	Name    = gwmaterial.Name
+	Type    = gwmaterial.Type
+	RunType = gwmaterial.RunType
+)
+
+type Option func(*Attestor)
+
+// WithArtifactOptions limits the recorded files and sets how many are hashed at a time.
+func WithArtifactOptions(opts artifacts.Options) Option {
+	return func(a *Attestor) {
+		a.opts = opts
+	}
+}
+
+type Attestor struct {
+	opts      artifacts.Options
+	materials map[string]cryptoutil.DigestSet
+}
+
+func New(opts ...Option) *Attestor {
+	a := &Attestor{}
+	for _, opt := range opts {
+		opt(a)
+	}
+
+	return a
+}
+
+func (a *Attestor) Name() string {
+	return Name
+}
+
+func (a *Attestor) Type() string {
+	return Type
+}
+
+func (a *Attestor) RunType() attestation.RunType {
+	return RunType
+}
+
+func (a *Attestor) Attest(ctx *attestation.AttestationContext) error {
+	materials, err := artifacts.Record(ctx.WorkingDir(), nil, ctx.Hashes(), a.opts)
+	if err != nil {
+		return err
+	}
+
+	a.materials = materials
+	return nil
+}
+
+func (a *Attestor) MarshalJSON() ([]byte, error) {
+	return json.Marshal(a.materials)
+}
+
+func (a *Attestor) Materials() map[string]cryptoutil.DigestSet {
+	return a.materials
+}
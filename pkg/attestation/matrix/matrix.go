@@ -0,0 +1,143 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package matrix
+
+import (
+	"crypto"
+	"crypto/sha256"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/testifysec/go-witness/attestation"
+	"github.com/testifysec/go-witness/cryptoutil"
+)
+
+const (
+	Name    = "matrix"
+	Type    = "https://witness.dev/attestations/matrix/v0.1"
+	RunType = attestation.PostRunType
+)
+
+var dimensionPattern = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9._-]*[A-Za-z0-9])?$`)
+
+func init() {
+	attestation.RegisterAttestation(Name, Type, RunType, func() attestation.Attestor {
+		return New()
+	})
+}
+
+// Attestor records the coordinates of the matrix job that ran the step, such as os=linux and arch=amd64, so a policy
+// can require every variant of a step that runs across a CI matrix.
+type Attestor struct {
+	Coordinates map[string]string `json:"coordinates"`
+}
+
+func New() *Attestor {
+	return &Attestor{Coordinates: map[string]string{}}
+}
+
+func (a *Attestor) Name() string {
+	return Name
+}
+
+func (a *Attestor) Type() string {
+	return Type
+}
+
+func (a *Attestor) RunType() attestation.RunType {
+	return RunType
+}
+
+func (a *Attestor) Attest(ctx *attestation.AttestationContext) error {
+	return nil
+}
+
+func (a *Attestor) Subjects() map[string]cryptoutil.DigestSet {
+	if len(a.Coordinates) == 0 {
+		return map[string]cryptoutil.DigestSet{}
+	}
+
+	variant := Variant(a.Coordinates)
+	return map[string]cryptoutil.DigestSet{
+		"matrix:" + variant: {crypto.SHA256: fmt.Sprintf("%x", sha256.Sum256([]byte(variant)))},
+	}
+}
+
+// Variant returns the coordinates as key=value pairs sorted by key and joined with commas, such as
+// arch=amd64,os=linux.
+func Variant(coordinates map[string]string) string {
+	keys := make([]string, 0, len(coordinates))
+	for key := range coordinates {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+	pairs := make([]string, 0, len(keys))
+	for _, key := range keys {
+		pairs = append(pairs, fmt.Sprintf("%v=%v", key, coordinates[key]))
+	}
+
+	return strings.Join(pairs, ",")
+}
+
+// Parse reads matrix coordinates in dimension=value form.
+func Parse(coordinates []string) (map[string]string, error) {
+	parsed := make(map[string]string, len(coordinates))
+	for _, coordinate := range coordinates {
+		i := strings.Index(coordinate, "=")
+		if i < 0 {
+			return nil, fmt.Errorf("matrix coordinate %v is not in dimension=value form", coordinate)
+		}
+
+		dimension, value := coordinate[:i], coordinate[i+1:]
+		if !dimensionPattern.MatchString(dimension) {
+			return nil, fmt.Errorf("invalid matrix dimension %v: dimensions may only contain letters, digits, '.', '_', and '-'", dimension)
+		}
+
+		if value == "" {
+			return nil, fmt.Errorf("matrix dimension %v has no value", dimension)
+		}
+
+		if _, ok := parsed[dimension]; ok {
+			return nil, fmt.Errorf("matrix dimension %v is set more than once", dimension)
+		}
+
+		parsed[dimension] = value
+	}
+
+	return parsed, nil
+}
+
+// Collection returns the collection with a matrix attestation of the coordinates appended. The collection is returned
+// unchanged if there are no coordinates.
+func Collection(collection attestation.Collection, coordinates map[string]string) (attestation.Collection, bool) {
+	if len(coordinates) == 0 {
+		return collection, false
+	}
+
+	record := New()
+	for dimension, value := range coordinates {
+		record.Coordinates[dimension] = value
+	}
+
+	attestors := make([]attestation.Attestor, 0, len(collection.Attestations)+1)
+	for _, ca := range collection.Attestations {
+		attestors = append(attestors, ca.Attestation)
+	}
+
+	return attestation.NewCollection(collection.Name, append(attestors, record)), true
+}
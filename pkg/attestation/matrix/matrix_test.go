@@ -0,0 +1,60 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package matrix
+
+import (
+	"testing"
+
+	"github.com/testifysec/go-witness/attestation"
+	"github.com/testifysec/go-witness/attestation/environment"
+)
+
+func TestParse(t *testing.T) {
+	coordinates, err := Parse([]string{"os=linux", "arch=amd64", "go=1.17"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if Variant(coordinates) != "arch=amd64,go=1.17,os=linux" {
+		t.Errorf("unexpected variant: %v", Variant(coordinates))
+	}
+
+	for _, invalid := range [][]string{{"os"}, {"=linux"}, {"os="}, {"target os=linux"}, {"os=linux", "os=darwin"}} {
+		if _, err := Parse(invalid); err == nil {
+			t.Errorf("expected %v to be rejected", invalid)
+		}
+	}
+}
+
+func TestCollection(t *testing.T) {
+	collection := attestation.NewCollection("test", []attestation.Attestor{environment.New()})
+	if _, changed := Collection(collection, nil); changed {
+		t.Error("expected collection without coordinates to be unchanged")
+	}
+
+	recorded, changed := Collection(collection, map[string]string{"os": "linux", "arch": "arm64"})
+	if !changed || len(recorded.Attestations) != 2 {
+		t.Fatalf("expected matrix attestation to be appended: %+v", recorded)
+	}
+
+	record, ok := recorded.Attestations[1].Attestation.(*Attestor)
+	if !ok || record.Coordinates["os"] != "linux" || record.Coordinates["arch"] != "arm64" {
+		t.Errorf("unexpected matrix attestation: %+v", recorded.Attestations[1])
+	}
+
+	if _, ok := recorded.Subjects()[Type+"/matrix:arch=arm64,os=linux"]; !ok {
+		t.Errorf("expected matrix subject, got %v", recorded.Subjects())
+	}
+}
@@ -0,0 +1,205 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package monorepo
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/testifysec/go-witness/attestation"
+)
+
+const (
+	Name    = "monorepo"
+	Type    = "https://witness.dev/attestations/monorepo/v0.1"
+	RunType = attestation.PostRunType
+
+	// BaseEnv selects the git revision changes are calculated against. Defaults to HEAD~1.
+	BaseEnv     = "WITNESS_MONOREPO_BASE"
+	defaultBase = "HEAD~1"
+)
+
+// manifests mark the root directory of a package.
+var manifests = []string{
+	"go.mod",
+	"package.json",
+	"Cargo.toml",
+	"pom.xml",
+	"build.gradle",
+	"build.gradle.kts",
+	"pyproject.toml",
+	"setup.py",
+	"BUILD",
+	"BUILD.bazel",
+}
+
+func init() {
+	attestation.RegisterAttestation(Name, Type, RunType, func() attestation.Attestor {
+		return New()
+	})
+}
+
+type Attestor struct {
+	Base       string              `json:"base"`
+	BaseCommit string              `json:"basecommit"`
+	HeadCommit string              `json:"headcommit"`
+	Packages   map[string]*Package `json:"packages"`
+}
+
+// Package is a directory of the repository containing a package manifest.
+type Package struct {
+	// Changed is true if any file in the package changed between the base and head commits.
+	Changed      bool     `json:"changed"`
+	ChangedFiles []string `json:"changedfiles,omitempty"`
+	Products     []string `json:"products,omitempty"`
+}
+
+func New() *Attestor {
+	return &Attestor{}
+}
+
+func (a *Attestor) Name() string {
+	return Name
+}
+
+func (a *Attestor) Type() string {
+	return Type
+}
+
+func (a *Attestor) RunType() attestation.RunType {
+	return RunType
+}
+
+func (a *Attestor) Attest(ctx *attestation.AttestationContext) error {
+	a.Base = os.Getenv(BaseEnv)
+	if a.Base == "" {
+		a.Base = defaultBase
+	}
+
+	wd := ctx.WorkingDir()
+	repoRoot, err := git(wd, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return err
+	}
+
+	if a.BaseCommit, err = git(wd, "rev-parse", a.Base); err != nil {
+		return err
+	}
+
+	if a.HeadCommit, err = git(wd, "rev-parse", "HEAD"); err != nil {
+		return err
+	}
+
+	diff, err := git(wd, "diff", "--name-only", a.BaseCommit, a.HeadCommit)
+	if err != nil {
+		return err
+	}
+
+	changed := strings.Fields(diff)
+	wdRel, err := repoRelative(repoRoot, wd)
+	if err != nil {
+		return err
+	}
+
+	products := make([]string, 0)
+	for product := range ctx.Products() {
+		products = append(products, path.Join(wdRel, filepath.ToSlash(product)))
+	}
+
+	a.Packages = mapPackages(repoRoot, changed, products)
+	return nil
+}
+
+// mapPackages groups changed files and products, both relative to repoRoot, by the package that contains them.
+func mapPackages(repoRoot string, changed, products []string) map[string]*Package {
+	packages := make(map[string]*Package)
+	get := func(file string) *Package {
+		pkg := packageFor(repoRoot, file)
+		if _, ok := packages[pkg]; !ok {
+			packages[pkg] = &Package{}
+		}
+
+		return packages[pkg]
+	}
+
+	for _, file := range changed {
+		pkg := get(file)
+		pkg.Changed = true
+		pkg.ChangedFiles = append(pkg.ChangedFiles, file)
+	}
+
+	for _, product := range products {
+		pkg := get(product)
+		pkg.Products = append(pkg.Products, product)
+	}
+
+	for _, pkg := range packages {
+		sort.Strings(pkg.ChangedFiles)
+		sort.Strings(pkg.Products)
+	}
+
+	return packages
+}
+
+// packageFor returns the nearest directory above file that contains a package manifest, or . if there is none.
+func packageFor(repoRoot, file string) string {
+	dir := path.Dir(file)
+	for dir != "." && dir != "/" {
+		for _, manifest := range manifests {
+			if _, err := os.Stat(filepath.Join(repoRoot, filepath.FromSlash(dir), manifest)); err == nil {
+				return dir
+			}
+		}
+
+		dir = path.Dir(dir)
+	}
+
+	return "."
+}
+
+func repoRelative(repoRoot, dir string) (string, error) {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", err
+	}
+
+	// resolve symlinks so paths compare with git's view of the repository root
+	if resolved, err := filepath.EvalSymlinks(absDir); err == nil {
+		absDir = resolved
+	}
+
+	rel, err := filepath.Rel(repoRoot, absDir)
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.ToSlash(rel), nil
+}
+
+func git(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to run git %v: %w", strings.Join(args, " "), err)
+	}
+
+	return strings.TrimSpace(string(out)), nil
+}
@@ -0,0 +1,54 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package monorepo
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestMapPackages(t *testing.T) {
+	root := t.TempDir()
+	for _, manifest := range []string{"services/api/go.mod", "web/package.json"} {
+		p := filepath.Join(root, filepath.FromSlash(manifest))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := os.WriteFile(p, []byte{}, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	changed := []string{"services/api/internal/server.go", "README.md"}
+	products := []string{"services/api/bin/api", "web/dist/index.js"}
+	packages := mapPackages(root, changed, products)
+
+	expected := map[string]*Package{
+		"services/api": {Changed: true, ChangedFiles: []string{"services/api/internal/server.go"}, Products: []string{"services/api/bin/api"}},
+		"web":          {Products: []string{"web/dist/index.js"}},
+		".":            {Changed: true, ChangedFiles: []string{"README.md"}},
+	}
+
+	if !reflect.DeepEqual(packages, expected) {
+		for name, pkg := range packages {
+			t.Logf("%v: %+v", name, pkg)
+		}
+
+		t.Errorf("unexpected packages")
+	}
+}
@@ -0,0 +1,100 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package normalizedarchive
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/testifysec/go-witness/attestation"
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/log"
+	"github.com/testifysec/witness/pkg/archive"
+)
+
+const (
+	Name    = "normalized-archive"
+	Type    = "https://witness.dev/attestations/normalized-archive/v0.1"
+	RunType = attestation.PostRunType
+)
+
+func init() {
+	attestation.RegisterAttestation(Name, Type, RunType, func() attestation.Attestor {
+		return New()
+	})
+}
+
+// Attestor records a normalized digest for each archive among the products, alongside the product's raw digest.
+type Attestor struct {
+	Archives map[string]Archive `json:"archives"`
+}
+
+type Archive struct {
+	Digest           cryptoutil.DigestSet `json:"digest"`
+	NormalizedDigest cryptoutil.DigestSet `json:"normalizeddigest"`
+}
+
+func New() *Attestor {
+	return &Attestor{}
+}
+
+func (a *Attestor) Name() string {
+	return Name
+}
+
+func (a *Attestor) Type() string {
+	return Type
+}
+
+func (a *Attestor) RunType() attestation.RunType {
+	return RunType
+}
+
+func (a *Attestor) Attest(ctx *attestation.AttestationContext) error {
+	a.Archives = make(map[string]Archive)
+	for name, product := range ctx.Products() {
+		productPath := filepath.Join(ctx.WorkingDir(), name)
+		isArchive, err := archive.IsArchive(productPath)
+		if err != nil {
+			log.Debugf("(attestation/normalized-archive) failed to read product %v: %v", name, err)
+			continue
+		}
+
+		if !isArchive {
+			continue
+		}
+
+		normalized, err := archive.NormalizedDigest(productPath, ctx.Hashes())
+		if err != nil {
+			return fmt.Errorf("failed to normalize archive %v: %w", name, err)
+		}
+
+		a.Archives[name] = Archive{
+			Digest:           product.Digest,
+			NormalizedDigest: normalized,
+		}
+	}
+
+	return nil
+}
+
+func (a *Attestor) Subjects() map[string]cryptoutil.DigestSet {
+	subjects := make(map[string]cryptoutil.DigestSet)
+	for name, archive := range a.Archives {
+		subjects[fmt.Sprintf("normalizedarchive:%v", name)] = archive.NormalizedDigest
+	}
+
+	return subjects
+}
@@ -0,0 +1,328 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package obfuscate
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/testifysec/go-witness/attestation"
+	"github.com/testifysec/go-witness/attestation/commandrun"
+	"github.com/testifysec/go-witness/attestation/environment"
+)
+
+const (
+	Name    = "obfuscate"
+	Type    = "https://witness.dev/attestations/obfuscate/v0.1"
+	RunType = attestation.PostRunType
+)
+
+// Profiles that can be applied to a collection.
+const (
+	ProfileNone          = "none"
+	ProfileHashHostnames = "hash-hostnames"
+	ProfileHashUsernames = "hash-usernames"
+	ProfileStripPaths    = "strip-paths"
+)
+
+var (
+	hostnameVariables = []string{"HOSTNAME", "HOST", "COMPUTERNAME"}
+	usernameVariables = []string{"USER", "USERNAME", "LOGNAME"}
+)
+
+func init() {
+	attestation.RegisterAttestation(Name, Type, RunType, func() attestation.Attestor {
+		return New()
+	})
+}
+
+// Attestor records the obfuscation profiles applied to a collection and the attestations they changed. It is not run;
+// Collection adds it to the collections it changes.
+type Attestor struct {
+	Profiles     []string `json:"profiles"`
+	Attestations []string `json:"attestations"`
+}
+
+func New() *Attestor {
+	return &Attestor{}
+}
+
+func (a *Attestor) Name() string {
+	return Name
+}
+
+func (a *Attestor) Type() string {
+	return Type
+}
+
+func (a *Attestor) RunType() attestation.RunType {
+	return RunType
+}
+
+func (a *Attestor) Attest(ctx *attestation.AttestationContext) error {
+	return nil
+}
+
+// ValidateProfiles returns an error if any of the profiles are unknown.
+func ValidateProfiles(profiles []string) error {
+	for _, profile := range profiles {
+		switch profile {
+		case ProfileNone, ProfileHashHostnames, ProfileHashUsernames, ProfileStripPaths:
+		default:
+			return fmt.Errorf("unknown obfuscation profile %v", profile)
+		}
+	}
+
+	return nil
+}
+
+// Collection returns a copy of the collection with the profiles applied to the environment and command-run
+// attestations. If any attestation changed, an obfuscate attestation recording the profiles is appended and changed is
+// true.
+func Collection(collection attestation.Collection, profiles []string) (obfuscated attestation.Collection, changed bool, err error) {
+	if err := ValidateProfiles(profiles); err != nil {
+		return collection, false, err
+	}
+
+	enabled := map[string]bool{}
+	for _, profile := range profiles {
+		enabled[profile] = profile != ProfileNone
+	}
+
+	record := New()
+	attestors := make([]attestation.Attestor, 0, len(collection.Attestations)+1)
+	for _, ca := range collection.Attestations {
+		original, err := json.Marshal(ca.Attestation)
+		if err != nil {
+			return collection, false, fmt.Errorf("failed to marshal %v attestation: %w", ca.Type, err)
+		}
+
+		data, modified, err := apply(ca.Type, original, enabled)
+		if err != nil {
+			return collection, false, fmt.Errorf("failed to obfuscate %v attestation: %w", ca.Type, err)
+		}
+
+		if !modified {
+			attestors = append(attestors, ca.Attestation)
+			continue
+		}
+
+		// round trip through the attestor's type so the result is still valid for that attestation type
+		factory, ok := attestation.FactoryByType(ca.Type)
+		if !ok {
+			return collection, false, attestation.ErrAttestationNotFound(ca.Type)
+		}
+
+		attestor := factory()
+		if err := json.Unmarshal(data, &attestor); err != nil {
+			return collection, false, fmt.Errorf("failed to unmarshal obfuscated %v attestation: %w", ca.Type, err)
+		}
+
+		record.Attestations = append(record.Attestations, ca.Type)
+		attestors = append(attestors, attestor)
+	}
+
+	if len(record.Attestations) == 0 {
+		return collection, false, nil
+	}
+
+	for profile, on := range enabled {
+		if on {
+			record.Profiles = append(record.Profiles, profile)
+		}
+	}
+
+	sort.Strings(record.Profiles)
+	return attestation.NewCollection(collection.Name, append(attestors, record)), true, nil
+}
+
+func apply(attestationType string, data []byte, enabled map[string]bool) ([]byte, bool, error) {
+	switch attestationType {
+	case environment.Type:
+		env := map[string]interface{}{}
+		if err := json.Unmarshal(data, &env); err != nil {
+			return nil, false, err
+		}
+
+		before, err := json.Marshal(env)
+		if err != nil {
+			return nil, false, err
+		}
+
+		variables, _ := env["variables"].(map[string]interface{})
+		if enabled[ProfileHashHostnames] {
+			hashField(env, "hostname")
+			hashVariables(variables, hostnameVariables)
+		}
+
+		if enabled[ProfileHashUsernames] {
+			hashField(env, "username")
+			hashVariables(variables, usernameVariables)
+		}
+
+		if enabled[ProfileStripPaths] {
+			for name, value := range variables {
+				if s, ok := value.(string); ok {
+					variables[name] = stripPaths(s)
+				}
+			}
+		}
+
+		out, err := json.Marshal(env)
+		return out, !bytes.Equal(before, out), err
+
+	case commandrun.Type:
+		cr := map[string]interface{}{}
+		if err := json.Unmarshal(data, &cr); err != nil {
+			return nil, false, err
+		}
+
+		before, err := json.Marshal(cr)
+		if err != nil {
+			return nil, false, err
+		}
+
+		processes, _ := cr["processes"].([]interface{})
+		for _, p := range processes {
+			process, ok := p.(map[string]interface{})
+			if !ok {
+				continue
+			}
+
+			if environ, ok := process["environ"].(string); ok {
+				process["environ"] = obfuscateEnviron(environ, enabled)
+			}
+
+			if !enabled[ProfileStripPaths] {
+				continue
+			}
+
+			for _, field := range []string{"program", "cmdline"} {
+				if s, ok := process[field].(string); ok {
+					process[field] = stripPaths(s)
+				}
+			}
+
+			if files, ok := process["openedfiles"].(map[string]interface{}); ok {
+				process["openedfiles"] = stripFilePaths(files)
+			}
+		}
+
+		out, err := json.Marshal(cr)
+		return out, !bytes.Equal(before, out), err
+	}
+
+	return data, false, nil
+}
+
+func hashValue(value string) string {
+	if value == "" || strings.HasPrefix(value, "sha256:") {
+		return value
+	}
+
+	return fmt.Sprintf("sha256:%x", sha256.Sum256([]byte(value)))
+}
+
+func hashField(m map[string]interface{}, field string) {
+	if s, ok := m[field].(string); ok {
+		m[field] = hashValue(s)
+	}
+}
+
+func hashVariables(variables map[string]interface{}, names []string) {
+	for _, name := range names {
+		hashField(variables, name)
+	}
+}
+
+// obfuscateEnviron applies the profiles to a process's environment, which the command-run attestor records as space
+// separated KEY=value pairs.
+func obfuscateEnviron(environ string, enabled map[string]bool) string {
+	hashed := map[string]bool{}
+	if enabled[ProfileHashHostnames] {
+		for _, name := range hostnameVariables {
+			hashed[name] = true
+		}
+	}
+
+	if enabled[ProfileHashUsernames] {
+		for _, name := range usernameVariables {
+			hashed[name] = true
+		}
+	}
+
+	fields := strings.Split(environ, " ")
+	for i, field := range fields {
+		kv := strings.SplitN(field, "=", 2)
+		if len(kv) != 2 {
+			continue
+		}
+
+		key, value := kv[0], kv[1]
+		if hashed[key] {
+			fields[i] = key + "=" + hashValue(value)
+		} else if enabled[ProfileStripPaths] {
+			fields[i] = key + "=" + stripPaths(value)
+		}
+	}
+
+	return strings.Join(fields, " ")
+}
+
+// stripPaths replaces every absolute path in s, including those in colon separated lists, with its last element.
+func stripPaths(s string) string {
+	fields := strings.Split(s, " ")
+	for i, field := range fields {
+		elems := strings.Split(field, ":")
+		for j, elem := range elems {
+			if strings.HasPrefix(elem, "/") {
+				elems[j] = path.Base(elem)
+			}
+		}
+
+		fields[i] = strings.Join(elems, ":")
+	}
+
+	return strings.Join(fields, " ")
+}
+
+// stripFilePaths reduces the absolute paths of opened files to their last element. When several files reduce to the
+// same name, the digest of the file whose original path sorts first is kept.
+func stripFilePaths(files map[string]interface{}) map[string]interface{} {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	stripped := make(map[string]interface{}, len(files))
+	for _, name := range names {
+		newName := name
+		if strings.HasPrefix(name, "/") {
+			newName = path.Base(name)
+		}
+
+		if _, ok := stripped[newName]; !ok {
+			stripped[newName] = files[name]
+		}
+	}
+
+	return stripped
+}
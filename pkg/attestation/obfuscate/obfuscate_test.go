@@ -0,0 +1,114 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package obfuscate
+
+import (
+	"crypto"
+	"crypto/sha256"
+	"fmt"
+	"testing"
+
+	"github.com/testifysec/go-witness/attestation"
+	"github.com/testifysec/go-witness/attestation/commandrun"
+	"github.com/testifysec/go-witness/attestation/environment"
+	"github.com/testifysec/go-witness/cryptoutil"
+)
+
+func testCollection() attestation.Collection {
+	env := environment.New()
+	env.Hostname = "runner-42.corp.example.com"
+	env.Username = "alice"
+	env.Variables = map[string]string{"USER": "alice", "HOME": "/home/alice", "PATH": "/usr/local/bin:/usr/bin"}
+
+	cr := commandrun.New()
+	cr.Cmd = []string{"make"}
+	cr.Processes = []commandrun.ProcessInfo{{
+		ProcessID: 1,
+		Program:   "/usr/bin/make",
+		Cmdline:   "make -C /home/alice/src",
+		Environ:   "USER=alice HOME=/home/alice",
+		OpenedFiles: map[string]cryptoutil.DigestSet{
+			"/home/alice/src/Makefile": {crypto.SHA256: "aa"},
+			"main.go":                  {crypto.SHA256: "bb"},
+		},
+	}}
+
+	return attestation.NewCollection("build", []attestation.Attestor{env, cr})
+}
+
+func hash(s string) string {
+	return fmt.Sprintf("sha256:%x", sha256.Sum256([]byte(s)))
+}
+
+func TestCollection(t *testing.T) {
+	obfuscated, changed, err := Collection(testCollection(), []string{ProfileHashHostnames, ProfileHashUsernames, ProfileStripPaths})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !changed || len(obfuscated.Attestations) != 3 {
+		t.Fatalf("expected obfuscate attestation to be added, got %d attestations", len(obfuscated.Attestations))
+	}
+
+	env, ok := obfuscated.Attestations[0].Attestation.(*environment.Attestor)
+	if !ok {
+		t.Fatalf("expected environment attestation, got %T", obfuscated.Attestations[0].Attestation)
+	}
+
+	if env.Hostname != hash("runner-42.corp.example.com") || env.Username != hash("alice") || env.Variables["USER"] != hash("alice") {
+		t.Errorf("expected host and user names to be hashed: %+v", env)
+	}
+
+	if env.Variables["HOME"] != "alice" || env.Variables["PATH"] != "bin:bin" {
+		t.Errorf("expected paths to be stripped: %+v", env.Variables)
+	}
+
+	cr, ok := obfuscated.Attestations[1].Attestation.(*commandrun.CommandRun)
+	if !ok {
+		t.Fatalf("expected command run attestation, got %T", obfuscated.Attestations[1].Attestation)
+	}
+
+	process := cr.Processes[0]
+	if process.Program != "make" || process.Cmdline != "make -C src" || process.Environ != "USER="+hash("alice")+" HOME=alice" {
+		t.Errorf("unexpected process: %+v", process)
+	}
+
+	if _, ok := process.OpenedFiles["Makefile"]; !ok || len(process.OpenedFiles) != 2 {
+		t.Errorf("expected opened file paths to be stripped: %v", process.OpenedFiles)
+	}
+
+	record, ok := obfuscated.Attestations[2].Attestation.(*Attestor)
+	if !ok || len(record.Profiles) != 3 || len(record.Attestations) != 2 {
+		t.Errorf("unexpected obfuscate attestation: %+v", obfuscated.Attestations[2].Attestation)
+	}
+}
+
+func TestCollectionNone(t *testing.T) {
+	collection := testCollection()
+	obfuscated, changed, err := Collection(collection, []string{ProfileNone})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if changed || len(obfuscated.Attestations) != len(collection.Attestations) {
+		t.Errorf("expected collection to be unchanged")
+	}
+}
+
+func TestValidateProfiles(t *testing.T) {
+	if err := ValidateProfiles([]string{ProfileStripPaths, "hash-everything"}); err == nil {
+		t.Error("expected error for unknown profile")
+	}
+}
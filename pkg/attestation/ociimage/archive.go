@@ -0,0 +1,195 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ociimage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/testifysec/witness/pkg/archive"
+)
+
+const (
+	// maxMetadataSize is the largest file read into memory while looking for manifests, indexes, and configs.
+	maxMetadataSize = 1 << 20
+
+	refNameAnnotation   = "org.opencontainers.image.ref.name"
+	imageNameAnnotation = "io.containerd.image.name"
+)
+
+type dockerManifest struct {
+	Config   string   `json:"Config"`
+	RepoTags []string `json:"RepoTags"`
+}
+
+type descriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Annotations map[string]string `json:"annotations"`
+}
+
+type ociManifest struct {
+	MediaType string       `json:"mediaType"`
+	Config    descriptor   `json:"config"`
+	Manifests []descriptor `json:"manifests"`
+}
+
+// readArchive returns the images in a docker save or OCI layout archive. Images in an OCI layout are read from its
+// index.json, and the tags docker save records in manifest.json are added to the images with the same config. Older
+// docker save archives only have a manifest.json, so their images have no manifest digest.
+func readArchive(archivePath string) ([]Image, error) {
+	metadata := make(map[string][]byte)
+	err := archive.Walk(archivePath, func(entry archive.Entry, r io.Reader) error {
+		if !entry.Mode.IsRegular() || (!strings.HasSuffix(entry.Name, ".json") && !strings.HasPrefix(entry.Name, "blobs/")) {
+			return nil
+		}
+
+		data, err := io.ReadAll(io.LimitReader(r, maxMetadataSize+1))
+		if err != nil {
+			return err
+		}
+
+		if len(data) <= maxMetadataSize {
+			metadata[path.Clean(entry.Name)] = data
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	images := make([]Image, 0)
+	if data, ok := metadata["index.json"]; ok {
+		index := ociManifest{}
+		if err := json.Unmarshal(data, &index); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal index.json: %w", err)
+		}
+
+		for _, desc := range index.Manifests {
+			image, err := readManifest(desc, metadata)
+			if err != nil {
+				return nil, err
+			}
+
+			images = append(images, image)
+		}
+	}
+
+	data, ok := metadata["manifest.json"]
+	if !ok {
+		return images, nil
+	}
+
+	manifests := []dockerManifest{}
+	if err := json.Unmarshal(data, &manifests); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal manifest.json: %w", err)
+	}
+
+	for _, manifest := range manifests {
+		config, ok := metadata[path.Clean(manifest.Config)]
+		if !ok {
+			return nil, fmt.Errorf("image archive is missing config %v", manifest.Config)
+		}
+
+		configDigest := digestOf(config)
+		merged := false
+		for i := range images {
+			if images[i].ConfigDigest == configDigest {
+				images[i].Tags = appendTags(images[i].Tags, manifest.RepoTags...)
+				merged = true
+			}
+		}
+
+		if !merged {
+			images = append(images, Image{Source: SourceProduct, ConfigDigest: configDigest, Tags: appendTags(nil, manifest.RepoTags...)})
+		}
+	}
+
+	return images, nil
+}
+
+// readManifest returns the image of a manifest or index listed in an OCI layout's index.json, checking that its blob
+// has the digest it is listed with.
+func readManifest(desc descriptor, metadata map[string][]byte) (Image, error) {
+	blob, ok := metadata[blobPath(desc.Digest)]
+	if !ok {
+		return Image{}, fmt.Errorf("oci layout is missing blob %v", desc.Digest)
+	}
+
+	if digestOf(blob) != desc.Digest {
+		return Image{}, fmt.Errorf("blob %v does not match its digest", desc.Digest)
+	}
+
+	manifest := ociManifest{}
+	if err := json.Unmarshal(blob, &manifest); err != nil {
+		return Image{}, fmt.Errorf("failed to unmarshal oci manifest %v: %w", desc.Digest, err)
+	}
+
+	image := Image{Source: SourceProduct, MediaType: desc.MediaType, ManifestDigest: desc.Digest}
+	if image.MediaType == "" {
+		image.MediaType = manifest.MediaType
+	}
+
+	if len(manifest.Manifests) == 0 {
+		image.ConfigDigest = manifest.Config.Digest
+	}
+
+	// containerd and docker record the full image name, while the ref name of other tools may only be the tag
+	image.Tags = appendTags(image.Tags, desc.Annotations[imageNameAnnotation])
+	if ref := desc.Annotations[refNameAnnotation]; desc.Annotations[imageNameAnnotation] == "" || strings.Contains(ref, "/") {
+		image.Tags = appendTags(image.Tags, ref)
+	}
+
+	return image, nil
+}
+
+func blobPath(digest string) string {
+	return path.Join("blobs", strings.Replace(digest, ":", "/", 1))
+}
+
+func digestOf(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// appendTags appends the tags that aren't empty or already in tags.
+func appendTags(tags []string, add ...string) []string {
+	for _, tag := range add {
+		if tag == "" || contains(tags, tag) {
+			continue
+		}
+
+		tags = append(tags, tag)
+	}
+
+	return tags
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,243 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ociimage
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+
+	"github.com/testifysec/go-witness/attestation"
+	"github.com/testifysec/go-witness/log"
+	"github.com/testifysec/witness/pkg/ocistore"
+)
+
+// globalOptionsWithValue are the options before the subcommand that take a value as the next argument when it isn't
+// attached with =.
+var globalOptionsWithValue = map[string]bool{
+	"-c": true, "--context": true, "--config": true, "-H": true, "--host": true, "-l": true, "--log-level": true,
+	"--connection": true, "--url": true, "--root": true, "--runroot": true, "--storage-driver": true,
+	"--storage-opt": true,
+}
+
+// pushBooleanOptions are the push options that don't take a value. Any other option without an attached value is
+// assumed to take the next argument.
+var pushBooleanOptions = map[string]bool{
+	"-a": true, "--all-tags": true, "-q": true, "--quiet": true, "--disable-content-trust": true, "--tls-verify": true,
+	"--remove-signatures": true, "--compress": true, "--all": true, "--force-compression": true,
+}
+
+// commandImages returns the tool to inspect images with and the images a docker, podman, or buildah command built
+// with -t or pushed. buildah shares podman's image store, so its images are inspected with podman. Other commands are
+// assumed to have used docker.
+func commandImages(cmd []string) (string, []string) {
+	if len(cmd) == 0 {
+		return "docker", nil
+	}
+
+	tool := filepath.Base(cmd[0])
+	inspector := tool
+	switch tool {
+	case "docker", "podman":
+	case "buildah":
+		inspector = "podman"
+	default:
+		return "docker", nil
+	}
+
+	words := []string{}
+	for i := 1; i < len(cmd); i++ {
+		arg := cmd[i]
+		if strings.HasPrefix(arg, "-") {
+			if globalOptionsWithValue[arg] {
+				i++
+			}
+
+			continue
+		}
+
+		words = append(words, arg)
+		switch arg {
+		case "build", "b", "bud", "build-using-dockerfile":
+			return inspector, buildTags(cmd[i+1:])
+		case "push":
+			return inspector, pushedImage(cmd[i+1:])
+		}
+
+		if len(words) >= 2 {
+			break
+		}
+	}
+
+	return inspector, nil
+}
+
+// buildTags returns the values of the -t and --tag options of a build command.
+func buildTags(args []string) []string {
+	tags := []string{}
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			break
+		}
+
+		switch {
+		case (arg == "-t" || arg == "--tag") && i+1 < len(args):
+			i++
+			tags = append(tags, args[i])
+		case strings.HasPrefix(arg, "-t="), strings.HasPrefix(arg, "--tag="):
+			tags = append(tags, arg[strings.Index(arg, "=")+1:])
+		}
+	}
+
+	return tags
+}
+
+// pushedImage returns the image a push command pushed, which is its first argument.
+func pushedImage(args []string) []string {
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "-") {
+			return []string{arg}
+		}
+
+		if !strings.Contains(arg, "=") && !pushBooleanOptions[arg] {
+			i++
+		}
+	}
+
+	return nil
+}
+
+// parseImageReference parses an image reference as docker does, returning the repository it is in and the tag or
+// digest it names. Names without a registry are on docker hub, and the tag defaults to latest.
+func parseImageReference(s string) (ocistore.Reference, string, error) {
+	name, digest, tag := s, "", ""
+	if i := strings.Index(name, "@"); i >= 0 {
+		name, digest = name[:i], name[i+1:]
+	}
+
+	if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		name, tag = name[:i], name[i+1:]
+	}
+
+	registry := "docker.io"
+	if parts := strings.SplitN(name, "/", 2); len(parts) == 2 && (strings.ContainsAny(parts[0], ".:") || parts[0] == "localhost") {
+		registry, name = parts[0], parts[1]
+	}
+
+	reference := ocistore.Scheme + registry + "/" + name
+	if digest != "" {
+		reference += "@" + digest
+	}
+
+	ref, err := ocistore.ParseReference(reference)
+	if err != nil {
+		return ocistore.Reference{}, "", err
+	}
+
+	switch {
+	case digest != "":
+		return ref, digest, nil
+	case tag != "":
+		return ref, tag, nil
+	default:
+		return ref, "latest", nil
+	}
+}
+
+// inspect looks the image up in the local image store, and in its registry for the manifest digest of images that
+// docker has no repo digest for. The registry's manifest is only used if it has the same config as the local image, so
+// a tag that was not pushed is never bound to an older image in the registry.
+func inspect(ctx *attestation.AttestationContext, tool, reference string) (Image, bool) {
+	ref, tagOrDigest, err := parseImageReference(reference)
+	if err != nil {
+		log.Debugf("(attestation/oci-image) invalid image reference %v: %v", reference, err)
+		return Image{}, false
+	}
+
+	image := Image{Reference: reference}
+	if !strings.HasPrefix(tagOrDigest, "sha256:") {
+		image.Tags = []string{reference}
+	}
+
+	local := struct {
+		ID          string   `json:"Id"`
+		RepoTags    []string `json:"RepoTags"`
+		RepoDigests []string `json:"RepoDigests"`
+	}{}
+
+	out, err := runCommand(ctx.Context(), tool, "image", "inspect", "--format", "{{json .}}", reference)
+	if err == nil {
+		err = json.Unmarshal(out, &local)
+	}
+
+	if err != nil {
+		log.Debugf("(attestation/oci-image) failed to inspect %v with %v: %v", reference, tool, err)
+	} else {
+		image.Source = SourceDaemon
+		image.ConfigDigest = local.ID
+		// podman reports image IDs without the algorithm
+		if !strings.Contains(image.ConfigDigest, ":") {
+			image.ConfigDigest = "sha256:" + image.ConfigDigest
+		}
+
+		image.Tags = appendTags(image.Tags, local.RepoTags...)
+		image.ManifestDigest = repoDigest(local.RepoDigests, ref)
+		if image.ManifestDigest != "" {
+			return image, true
+		}
+	}
+
+	desc, manifest, err := newClient().Inspect(ctx.Context(), ref, tagOrDigest)
+	if err != nil {
+		log.Debugf("(attestation/oci-image) failed to get the manifest of %v from its registry: %v", reference, err)
+		return image, image.Source != ""
+	}
+
+	if image.Source == SourceDaemon {
+		if manifest != nil && manifest.Config.Digest == image.ConfigDigest {
+			image.ManifestDigest = desc.Digest
+			image.MediaType = desc.MediaType
+		}
+
+		return image, true
+	}
+
+	image.Source = SourceRegistry
+	image.ManifestDigest = desc.Digest
+	image.MediaType = desc.MediaType
+	if manifest != nil {
+		image.ConfigDigest = manifest.Config.Digest
+	}
+
+	return image, true
+}
+
+// repoDigest returns the digest from the repo digests of a local image that is in the reference's repository.
+func repoDigest(repoDigests []string, ref ocistore.Reference) string {
+	for _, repoDigest := range repoDigests {
+		parsed, digest, err := parseImageReference(repoDigest)
+		if err != nil || !strings.HasPrefix(digest, "sha256:") {
+			continue
+		}
+
+		if parsed.Registry == ref.Registry && parsed.Repository == ref.Repository {
+			return digest
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,196 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ociimage
+
+import (
+	"crypto"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/testifysec/go-witness/attestation"
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/log"
+	"github.com/testifysec/witness/pkg/archive"
+	"github.com/testifysec/witness/pkg/attestation/command"
+	"github.com/testifysec/witness/pkg/ocistore"
+	"github.com/testifysec/witness/pkg/replay"
+)
+
+const (
+	Name    = "oci-image"
+	Type    = "https://witness.dev/attestations/oci-image/v0.1"
+	RunType = attestation.PostRunType
+
+	// ImagesEnv is a comma separated list of image references to inspect after the command, in addition to the images
+	// the command built or pushed.
+	ImagesEnv = "WITNESS_OCI_IMAGES"
+
+	SourceProduct  = "product"
+	SourceDaemon   = "daemon"
+	SourceRegistry = "registry"
+)
+
+// runCommand and newClient are replaced in tests
+var (
+	runCommand = replay.Output
+	newClient  = ocistore.NewClient
+)
+
+func init() {
+	attestation.RegisterAttestation(Name, Type, RunType, func() attestation.Attestor {
+		return New()
+	})
+}
+
+// Attestor records the manifest digest, config digest, and tags of the container images a step built, and
+// contributes them as subjects so policies and transparency log lookups can key off the image. Images are read from
+// image archives among the products, and from the local image store or the registry for the images the command built,
+// pushed, or that were named in WITNESS_OCI_IMAGES.
+type Attestor struct {
+	Images []Image `json:"images,omitempty"`
+}
+
+type Image struct {
+	// Source is where the image was read from: product, daemon, or registry.
+	Source string `json:"source"`
+	// Path is the product the image was read from.
+	Path string `json:"path,omitempty"`
+	// Reference is the reference the image was looked up by in the image store or registry.
+	Reference string `json:"reference,omitempty"`
+	MediaType string `json:"mediatype,omitempty"`
+	// ManifestDigest is the digest of the image's manifest, or of its index for multi-platform images. Images only in
+	// docker's local image store, and docker save archives without an OCI layout, have no manifest digest.
+	ManifestDigest string `json:"manifestdigest,omitempty"`
+	// ConfigDigest is the digest of the image's config, which docker shows as the image ID. Indexes have none.
+	ConfigDigest string   `json:"configdigest,omitempty"`
+	Tags         []string `json:"tags,omitempty"`
+}
+
+func New() *Attestor {
+	return &Attestor{}
+}
+
+func (a *Attestor) Name() string {
+	return Name
+}
+
+func (a *Attestor) Type() string {
+	return Type
+}
+
+func (a *Attestor) RunType() attestation.RunType {
+	return RunType
+}
+
+func (a *Attestor) Attest(ctx *attestation.AttestationContext) error {
+	paths := make([]string, 0)
+	for path := range ctx.Products() {
+		paths = append(paths, path)
+	}
+
+	sort.Strings(paths)
+	for _, path := range paths {
+		productPath := filepath.Join(ctx.WorkingDir(), path)
+		isArchive, err := archive.IsArchive(productPath)
+		if err != nil {
+			log.Debugf("(attestation/oci-image) failed to read product %v: %v", path, err)
+			continue
+		}
+
+		if !isArchive {
+			continue
+		}
+
+		images, err := readArchive(productPath)
+		if err != nil {
+			return fmt.Errorf("failed to read image archive %v: %w", path, err)
+		}
+
+		for i := range images {
+			images[i].Path = path
+		}
+
+		a.Images = append(a.Images, images...)
+	}
+
+	var cmd []string
+	if cr, ok := command.Run(ctx.CompletedAttestors()); ok {
+		cmd = cr.Cmd
+	}
+
+	tool, references := commandImages(cmd)
+	for _, reference := range strings.Split(os.Getenv(ImagesEnv), ",") {
+		if reference = strings.TrimSpace(reference); reference != "" {
+			references = append(references, reference)
+		}
+	}
+
+	seen := make(map[string]bool)
+	for _, reference := range references {
+		if seen[reference] {
+			continue
+		}
+
+		seen[reference] = true
+		if image, ok := inspect(ctx, tool, reference); ok {
+			a.Images = append(a.Images, image)
+		}
+	}
+
+	return nil
+}
+
+func (a *Attestor) Subjects() map[string]cryptoutil.DigestSet {
+	subjects := make(map[string]cryptoutil.DigestSet)
+	for _, image := range a.Images {
+		manifest := digestSet(image.ManifestDigest)
+		config := digestSet(image.ConfigDigest)
+		if manifest != nil {
+			subjects[fmt.Sprintf("imagemanifest:%v", manifest[crypto.SHA256])] = manifest
+		}
+
+		if config != nil {
+			subjects[fmt.Sprintf("imageconfig:%v", config[crypto.SHA256])] = config
+		}
+
+		// tags are bound to the manifest they were pushed as, or the image ID for images that were never pushed
+		tagged := manifest
+		if tagged == nil {
+			tagged = config
+		}
+
+		if tagged == nil {
+			continue
+		}
+
+		for _, tag := range image.Tags {
+			subjects[fmt.Sprintf("imagetag:%v", tag)] = tagged
+		}
+	}
+
+	return subjects
+}
+
+// digestSet returns the digest set of a sha256:<hex> digest, or nil for other digests.
+func digestSet(digest string) cryptoutil.DigestSet {
+	if !strings.HasPrefix(digest, "sha256:") {
+		return nil
+	}
+
+	return cryptoutil.DigestSet{crypto.SHA256: strings.TrimPrefix(digest, "sha256:")}
+}
@@ -0,0 +1,313 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ociimage
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"crypto"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/testifysec/go-witness/attestation"
+	"github.com/testifysec/go-witness/attestation/commandrun"
+	"github.com/testifysec/go-witness/attestation/material"
+	"github.com/testifysec/go-witness/attestation/product"
+	"github.com/testifysec/witness/pkg/ocistore"
+)
+
+var (
+	testConfig   = []byte(`{"architecture": "amd64", "os": "linux"}`)
+	testManifest = []byte(fmt.Sprintf(`{"schemaVersion": 2, "mediaType": "application/vnd.oci.image.manifest.v1+json", "config": {"digest": "%v"}}`, digestOf(testConfig)))
+)
+
+func tarBytes(t *testing.T, files map[string][]byte) []byte {
+	buf := &bytes.Buffer{}
+	tw := tar.NewWriter(buf)
+	for name, content := range files {
+		if err := tw.WriteHeader(&tar.Header{Name: name, Typeflag: tar.TypeReg, Mode: 0644, Size: int64(len(content))}); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := tw.Write(content); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+func writeArchive(t *testing.T, dir string, files map[string][]byte) string {
+	archivePath := filepath.Join(dir, "image.tar")
+	if err := os.WriteFile(archivePath, tarBytes(t, files), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	return archivePath
+}
+
+// dockerSave returns the files of a docker save archive with an OCI layout, as docker 25 and later write them.
+func dockerSave() map[string][]byte {
+	return map[string][]byte{
+		"index.json": []byte(fmt.Sprintf(`{"manifests": [{"mediaType": "application/vnd.oci.image.manifest.v1+json", "digest": "%v",
+			"annotations": {"io.containerd.image.name": "ghcr.io/org/app:v1", "org.opencontainers.image.ref.name": "v1"}}]}`, digestOf(testManifest))),
+		"manifest.json":                  []byte(fmt.Sprintf(`[{"Config": "%v", "RepoTags": ["ghcr.io/org/app:v1", "app:latest"]}]`, blobPath(digestOf(testConfig)))),
+		blobPath(digestOf(testManifest)): testManifest,
+		blobPath(digestOf(testConfig)):   testConfig,
+	}
+}
+
+func TestReadDockerSave(t *testing.T) {
+	images, err := readArchive(writeArchive(t, t.TempDir(), dockerSave()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []Image{{
+		Source:         SourceProduct,
+		MediaType:      "application/vnd.oci.image.manifest.v1+json",
+		ManifestDigest: digestOf(testManifest),
+		ConfigDigest:   digestOf(testConfig),
+		Tags:           []string{"ghcr.io/org/app:v1", "app:latest"},
+	}}
+
+	if !reflect.DeepEqual(images, expected) {
+		t.Fatalf("expected %+v, got %+v", expected, images)
+	}
+}
+
+func TestReadLegacyDockerSave(t *testing.T) {
+	images, err := readArchive(writeArchive(t, t.TempDir(), map[string][]byte{
+		"manifest.json": []byte(`[{"Config": "abc.json", "RepoTags": ["app:latest"]}]`),
+		"abc.json":      testConfig,
+	}))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(images) != 1 || images[0].ManifestDigest != "" || images[0].ConfigDigest != digestOf(testConfig) || !reflect.DeepEqual(images[0].Tags, []string{"app:latest"}) {
+		t.Fatalf("unexpected images %+v", images)
+	}
+}
+
+func TestReadOCILayoutIndex(t *testing.T) {
+	index := []byte(fmt.Sprintf(`{"mediaType": "application/vnd.oci.image.index.v1+json", "manifests": [{"digest": "%v"}]}`, digestOf(testManifest)))
+	files := map[string][]byte{
+		"oci-layout": []byte(`{"imageLayoutVersion": "1.0.0"}`),
+		"index.json": []byte(fmt.Sprintf(`{"manifests": [{"mediaType": "application/vnd.oci.image.index.v1+json", "digest": "%v",
+			"annotations": {"org.opencontainers.image.ref.name": "v2"}}]}`, digestOf(index))),
+		blobPath(digestOf(index)): index,
+	}
+
+	images, err := readArchive(writeArchive(t, t.TempDir(), files))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(images) != 1 || images[0].ManifestDigest != digestOf(index) || images[0].ConfigDigest != "" || !reflect.DeepEqual(images[0].Tags, []string{"v2"}) {
+		t.Fatalf("unexpected images %+v", images)
+	}
+
+	files[blobPath(digestOf(index))] = testManifest
+	if _, err := readArchive(writeArchive(t, t.TempDir(), files)); err == nil || !strings.Contains(err.Error(), "does not match") {
+		t.Fatalf("expected a blob that doesn't match its digest to fail, got %v", err)
+	}
+}
+
+func TestAttest(t *testing.T) {
+	dir := t.TempDir()
+	staged := writeArchive(t, t.TempDir(), dockerSave())
+	t.Setenv(ImagesEnv, "")
+	oldRun := runCommand
+	defer func() { runCommand = oldRun }()
+	runCommand = func(ctx context.Context, name string, args ...string) ([]byte, error) {
+		return nil, fmt.Errorf("%v is not installed", name)
+	}
+
+	a := New()
+	cmd := []string{"cp", staged, "image.tar"}
+	ctx, err := attestation.NewContext([]attestation.Attestor{a}, attestation.WithWorkingDir(dir), attestation.WithHashes([]crypto.Hash{crypto.SHA256}),
+		attestation.WithCommandAttestor(commandrun.New(commandrun.WithCommand(cmd), commandrun.WithSilent(true))),
+		attestation.WithMaterialAttestor(material.New()), attestation.WithProductAttestor(product.New()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ctx.RunAttestors(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(a.Images) != 1 || a.Images[0].Path != "image.tar" {
+		t.Fatalf("unexpected images %+v", a.Images)
+	}
+
+	subjects := a.Subjects()
+	manifestHex := strings.TrimPrefix(digestOf(testManifest), "sha256:")
+	for _, name := range []string{"imagemanifest:" + manifestHex, "imagetag:ghcr.io/org/app:v1", "imagetag:app:latest"} {
+		if subjects[name][crypto.SHA256] != manifestHex {
+			t.Errorf("expected subject %v with the manifest digest, got %v", name, subjects)
+		}
+	}
+
+	if _, ok := subjects["imageconfig:"+strings.TrimPrefix(digestOf(testConfig), "sha256:")]; !ok {
+		t.Errorf("expected a config subject, got %v", subjects)
+	}
+}
+
+func TestCommandImages(t *testing.T) {
+	tests := []struct {
+		cmd        []string
+		tool       string
+		references []string
+	}{
+		{[]string{"docker", "build", "-t", "app:v1", "--tag=ghcr.io/org/app:v1", "."}, "docker", []string{"app:v1", "ghcr.io/org/app:v1"}},
+		{[]string{"docker", "--context", "remote", "buildx", "build", "--push", "-t", "app", "."}, "docker", []string{"app"}},
+		{[]string{"/usr/bin/buildah", "bud", "-t", "app", "."}, "podman", []string{"app"}},
+		{[]string{"podman", "push", "--creds", "user:pass", "quay.io/org/app:v1"}, "podman", []string{"quay.io/org/app:v1"}},
+		{[]string{"docker", "image", "push", "-q", "app:v1"}, "docker", []string{"app:v1"}},
+		{[]string{"docker", "run", "app"}, "docker", nil},
+		{[]string{"make", "image"}, "docker", nil},
+	}
+
+	for _, test := range tests {
+		tool, references := commandImages(test.cmd)
+		if tool != test.tool || (len(references) > 0 || len(test.references) > 0) && !reflect.DeepEqual(references, test.references) {
+			t.Errorf("%v: expected %v %v, got %v %v", test.cmd, test.tool, test.references, tool, references)
+		}
+	}
+}
+
+func TestParseImageReference(t *testing.T) {
+	digest := digestOf(testManifest)
+	tests := []struct {
+		reference   string
+		expected    ocistore.Reference
+		tagOrDigest string
+	}{
+		{"alpine", ocistore.Reference{Registry: "docker.io", Repository: "library/alpine"}, "latest"},
+		{"org/app:v1", ocistore.Reference{Registry: "docker.io", Repository: "org/app"}, "v1"},
+		{"localhost:5000/app:v1", ocistore.Reference{Registry: "localhost:5000", Repository: "app"}, "v1"},
+		{"ghcr.io/org/app:v1@" + digest, ocistore.Reference{Registry: "ghcr.io", Repository: "org/app", Digest: digest}, digest},
+	}
+
+	for _, test := range tests {
+		ref, tagOrDigest, err := parseImageReference(test.reference)
+		if err != nil || ref != test.expected || tagOrDigest != test.tagOrDigest {
+			t.Errorf("%v: expected %+v %v, got %+v %v %v", test.reference, test.expected, test.tagOrDigest, ref, tagOrDigest, err)
+		}
+	}
+
+	if _, _, err := parseImageReference("ghcr.io/Org/App"); err == nil {
+		t.Error("expected an invalid repository to fail")
+	}
+}
+
+// testRegistry serves testManifest as org/app:v1.
+func testRegistry(t *testing.T) string {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v2/org/app/manifests/v1" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", ocistore.ManifestMediaType)
+		w.Write(testManifest)
+	}))
+
+	t.Cleanup(server.Close)
+	return strings.TrimPrefix(server.URL, "http://")
+}
+
+func newContext(t *testing.T) *attestation.AttestationContext {
+	ctx, err := attestation.NewContext([]attestation.Attestor{}, attestation.WithWorkingDir(t.TempDir()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return ctx
+}
+
+func TestInspect(t *testing.T) {
+	registry := testRegistry(t)
+	reference := registry + "/org/app:v1"
+	oldRun, oldClient := runCommand, newClient
+	defer func() { runCommand, newClient = oldRun, oldClient }()
+	newClient = func() *ocistore.Client { return &ocistore.Client{} }
+
+	localID := strings.TrimPrefix(digestOf(testConfig), "sha256:")
+	runCommand = func(ctx context.Context, name string, args ...string) ([]byte, error) {
+		if name != "podman" || strings.Join(args, " ") != "image inspect --format {{json .}} "+reference {
+			t.Fatalf("unexpected command %v %v", name, args)
+		}
+
+		return []byte(fmt.Sprintf(`{"Id": "%v", "RepoTags": ["%v"], "RepoDigests": []}`, localID, reference)), nil
+	}
+
+	image, ok := inspect(newContext(t), "podman", reference)
+	if !ok || image.Source != SourceDaemon || image.ConfigDigest != digestOf(testConfig) || image.ManifestDigest != digestOf(testManifest) {
+		t.Fatalf("expected the local image with the registry's manifest digest, got %+v", image)
+	}
+
+	// a local image that wasn't pushed keeps the tag from being bound to the manifest in the registry
+	localID = strings.Repeat("a", 64)
+	image, ok = inspect(newContext(t), "podman", reference)
+	if !ok || image.ManifestDigest != "" || image.ConfigDigest != "sha256:"+localID {
+		t.Fatalf("expected the local image without a manifest digest, got %+v", image)
+	}
+
+	runCommand = func(ctx context.Context, name string, args ...string) ([]byte, error) {
+		return nil, fmt.Errorf("%v is not installed", name)
+	}
+
+	image, ok = inspect(newContext(t), "docker", reference)
+	if !ok || image.Source != SourceRegistry || image.ManifestDigest != digestOf(testManifest) || image.ConfigDigest != digestOf(testConfig) {
+		t.Fatalf("expected the image from the registry, got %+v", image)
+	}
+
+	if _, ok := inspect(newContext(t), "docker", registry+"/org/app:missing"); ok {
+		t.Fatal("expected an image that can't be found to be left out")
+	}
+}
+
+func TestInspectRepoDigest(t *testing.T) {
+	oldRun, oldClient := runCommand, newClient
+	defer func() { runCommand, newClient = oldRun, oldClient }()
+	newClient = func() *ocistore.Client {
+		t.Fatal("expected the repo digest to be used without querying the registry")
+		return nil
+	}
+
+	runCommand = func(ctx context.Context, name string, args ...string) ([]byte, error) {
+		return []byte(fmt.Sprintf(`{"Id": "%v", "RepoTags": ["org/app:v1"], "RepoDigests": ["ghcr.io/org/app@sha256:%v", "org/app@%v"]}`,
+			digestOf(testConfig), strings.Repeat("b", 64), digestOf(testManifest))), nil
+	}
+
+	image, ok := inspect(newContext(t), "docker", "org/app:v1")
+	if !ok || image.ManifestDigest != digestOf(testManifest) || !reflect.DeepEqual(image.Tags, []string{"org/app:v1"}) {
+		t.Fatalf("unexpected image %+v", image)
+	}
+}
@@ -0,0 +1,121 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package product records the files the command created or changed in the working directory, limited to the files
+// selected by include and exclude patterns and hashing several files at a time. It records the same attestation as
+// go-witness's product attestor, which stays registered to read it.
+package product
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/testifysec/go-witness/attestation"
+	gwproduct "github.com/testifysec/go-witness/attestation/product"
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/witness/pkg/artifacts"
+)
+
+const (
+	Name    = gwproduct.Name
+	Type    = gwproduct.Type
+	RunType = gwproduct.RunType
+)
+
+type Option func(*Attestor)
+
+// WithArtifactOptions limits the recorded files and sets how many are hashed at a time.
+func WithArtifactOptions(opts artifacts.Options) Option {
+	return func(a *Attestor) {
+		a.opts = opts
+	}
+}
+
+type Attestor struct {
+	opts     artifacts.Options
+	products map[string]attestation.Product
+}
+
+func New(opts ...Option) *Attestor {
+	a := &Attestor{}
+	for _, opt := range opts {
+		opt(a)
+	}
+
+	return a
+}
+
+func (a *Attestor) Name() string {
+	return Name
+}
+
+func (a *Attestor) Type() string {
+	return Type
+}
+
+func (a *Attestor) RunType() attestation.RunType {
+	return RunType
+}
+
+// Attest records the files that are not materials, or whose digests changed since the materials were recorded.
+func (a *Attestor) Attest(ctx *attestation.AttestationContext) error {
+	digests, err := artifacts.Record(ctx.WorkingDir(), ctx.Materials(), ctx.Hashes(), a.opts)
+	if err != nil {
+		return err
+	}
+
+	a.products = make(map[string]attestation.Product, len(digests))
+	for name, ds := range digests {
+		a.products[name] = attestation.Product{MimeType: mimeType(filepath.Join(ctx.WorkingDir(), name)), Digest: ds}
+	}
+
+	return nil
+}
+
+func (a *Attestor) MarshalJSON() ([]byte, error) {
+	return json.Marshal(a.products)
+}
+
+func (a *Attestor) Products() map[string]attestation.Product {
+	return a.products
+}
+
+func (a *Attestor) Subjects() map[string]cryptoutil.DigestSet {
+	subjects := make(map[string]cryptoutil.DigestSet)
+	for name, product := range a.products {
+		subjects[fmt.Sprintf("file:%v", name)] = product.Digest
+	}
+
+	return subjects
+}
+
+// mimeType sniffs the content type of the file from its first 512 bytes, as go-witness's product attestor does.
+func mimeType(path string) string {
+	f, err := os.Open(path)
+	if err != nil {
+		return "unknown"
+	}
+
+	defer f.Close()
+	buffer := make([]byte, 512)
+	n, err := f.Read(buffer)
+	if err != nil {
+		return "unknown"
+	}
+
+	return http.DetectContentType(buffer[:n])
+}
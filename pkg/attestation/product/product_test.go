@@ -0,0 +1,66 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package product
+
+import (
+	"crypto"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/testifysec/go-witness/attestation"
+	"github.com/testifysec/witness/pkg/artifacts"
+	"github.com/testifysec/witness/pkg/attestation/material"
+)
+
+func TestAttest(t *testing.T) {
+	dir := t.TempDir()
+	for _, file := range []string{"main.go", "out/app", "out/app.log"} {
+		p := filepath.Join(dir, filepath.FromSlash(file))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := os.WriteFile(p, []byte("package main"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	m := material.New(material.WithArtifactOptions(artifacts.Options{Exclude: []string{"out"}}))
+	p := New(WithArtifactOptions(artifacts.Options{Exclude: []string{"**/*.log"}}))
+	ctx, err := attestation.NewContext([]attestation.Attestor{}, attestation.WithWorkingDir(dir), attestation.WithHashes([]crypto.Hash{crypto.SHA256}),
+		attestation.WithMaterialAttestor(m), attestation.WithProductAttestor(p))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := ctx.RunAttestors(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(m.Materials()) != 1 || m.Materials()["main.go"] == nil {
+		t.Errorf("expected only main.go as a material, got %v", m.Materials())
+	}
+
+	products := p.Products()
+	app := filepath.Join("out", "app")
+	if len(products) != 1 || products[app].MimeType != "text/plain; charset=utf-8" {
+		t.Errorf("expected only %v as a product, got %v", app, products)
+	}
+
+	if _, ok := p.Subjects()["file:"+app]; !ok {
+		t.Errorf("expected a subject for %v, got %v", app, p.Subjects())
+	}
+}
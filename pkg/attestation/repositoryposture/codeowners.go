@@ -0,0 +1,166 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package repositoryposture
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// sectionHeader matches the section headers of gitlab CODEOWNERS files, such as [Docs], ^[Docs], or [Docs][2] @docs.
+var sectionHeader = regexp.MustCompile(`^\^?\[([^\]]+)\](?:\[\d+\])?(.*)$`)
+
+// rule is a line of a CODEOWNERS file. Rules without owners take the default owners of their section.
+type rule struct {
+	section string
+	pattern string
+	match   *regexp.Regexp
+	owners  []string
+}
+
+// parseCodeOwners parses a CODEOWNERS file. Rules before the first section header are in the unnamed section, which is
+// the only section of github CODEOWNERS files.
+func parseCodeOwners(data []byte) ([]rule, error) {
+	rules := make([]rule, 0)
+	section, defaults := "", []string{}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if match := sectionHeader.FindStringSubmatch(line); match != nil {
+			section, defaults = strings.TrimSpace(match[1]), ownerFields(strings.Fields(match[2]))
+			continue
+		}
+
+		fields := splitEscaped(line)
+		match, err := compilePattern(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", n, err)
+		}
+
+		owners := ownerFields(fields[1:])
+		if len(owners) == 0 {
+			owners = defaults
+		}
+
+		rules = append(rules, rule{section: section, pattern: fields[0], match: match, owners: owners})
+	}
+
+	return rules, scanner.Err()
+}
+
+// ownersOf returns the owners of path, which is relative to the root of the repository. Within each section the last
+// rule matching the path applies, and the path is owned by the owners of every section. A matching rule without
+// owners leaves the path unowned in its section.
+func ownersOf(rules []rule, path string) []string {
+	applied := map[string][]string{}
+	for _, r := range rules {
+		if r.match.MatchString(path) {
+			applied[r.section] = r.owners
+		}
+	}
+
+	seen := map[string]struct{}{}
+	owners := make([]string, 0)
+	for _, sectionOwners := range applied {
+		for _, owner := range sectionOwners {
+			if _, ok := seen[owner]; !ok {
+				seen[owner] = struct{}{}
+				owners = append(owners, owner)
+			}
+		}
+	}
+
+	sort.Strings(owners)
+	return owners
+}
+
+// ownerFields returns the owners among the fields following a pattern or section header, stopping at a comment.
+func ownerFields(fields []string) []string {
+	owners := make([]string, 0, len(fields))
+	for _, field := range fields {
+		if strings.HasPrefix(field, "#") {
+			break
+		}
+
+		owners = append(owners, field)
+	}
+
+	return owners
+}
+
+// splitEscaped splits a line on whitespace, keeping spaces escaped with a backslash in the pattern.
+func splitEscaped(line string) []string {
+	fields := strings.Fields(strings.ReplaceAll(line, `\ `, "\x00"))
+	for i := range fields {
+		fields[i] = strings.ReplaceAll(fields[i], "\x00", " ")
+	}
+
+	return fields
+}
+
+// compilePattern compiles a CODEOWNERS pattern, which follows gitignore's rules: patterns containing a slash other than
+// a trailing one are relative to the root of the repository, others match at any depth, and a pattern matching a
+// directory matches everything in it. Unlike gitignore, a pattern ending in /* only matches the files directly in the
+// directory.
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	pattern = strings.TrimPrefix(pattern, `\`)
+	trimmed := strings.Trim(pattern, "/")
+	if trimmed == "" {
+		return nil, fmt.Errorf("invalid pattern %q", pattern)
+	}
+
+	expr := strings.Builder{}
+	if strings.HasPrefix(pattern, "/") || strings.Contains(trimmed, "/") {
+		expr.WriteString("^")
+	} else {
+		expr.WriteString("^(?:.*/)?")
+	}
+
+	for i := 0; i < len(trimmed); i++ {
+		switch {
+		case strings.HasPrefix(trimmed[i:], "**/"):
+			expr.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(trimmed[i:], "**"):
+			expr.WriteString(".*")
+			i++
+		case trimmed[i] == '*':
+			expr.WriteString("[^/]*")
+		case trimmed[i] == '?':
+			expr.WriteString("[^/]")
+		default:
+			expr.WriteString(regexp.QuoteMeta(trimmed[i : i+1]))
+		}
+	}
+
+	switch {
+	case strings.HasSuffix(pattern, "/*") && !strings.HasSuffix(pattern, "/**"):
+		expr.WriteString("$")
+	case strings.HasSuffix(pattern, "/"):
+		expr.WriteString("/.*$")
+	default:
+		expr.WriteString("(?:/.*)?$")
+	}
+
+	return regexp.Compile(expr.String())
+}
@@ -0,0 +1,91 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package repositoryposture
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestCompilePattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		matches []string
+		misses  []string
+	}{
+		{"*", []string{"README.md", "a/b/c.go"}, nil},
+		{"*.js", []string{"app.js", "web/src/app.js"}, []string{"app.ts"}},
+		{"/build/logs/", []string{"build/logs/a.log", "build/logs/x/b.log"}, []string{"src/build/logs/a.log", "build/logs"}},
+		{"docs/*", []string{"docs/getting-started.md"}, []string{"docs/build-app/troubleshooting.md"}},
+		{"apps/", []string{"apps/a.go", "src/apps/b.go"}, []string{"apps.go"}},
+		{"/docs", []string{"docs/a.md", "docs/x/b.md"}, []string{"src/docs/a.md"}},
+		{"**/logs", []string{"logs/a", "build/logs/a", "deep/build/logs"}, []string{"logsa"}},
+		{"src/**/*.go", []string{"src/a.go", "src/x/y/a.go"}, []string{"a.go", "src/a.ts"}},
+		{"file?.txt", []string{"file1.txt"}, []string{"file10.txt"}},
+	}
+
+	for _, test := range tests {
+		match, err := compilePattern(test.pattern)
+		if err != nil {
+			t.Fatalf("%v: %v", test.pattern, err)
+		}
+
+		for _, path := range test.matches {
+			if !match.MatchString(path) {
+				t.Errorf("expected %v to match %v", test.pattern, path)
+			}
+		}
+
+		for _, path := range test.misses {
+			if match.MatchString(path) {
+				t.Errorf("expected %v not to match %v", test.pattern, path)
+			}
+		}
+	}
+}
+
+func TestOwnersOf(t *testing.T) {
+	rules, err := parseCodeOwners([]byte(`# global owners
+*       @testifysec/maintainers
+*.go    @gophers  # go code
+/docs/  @writers docs@example.com
+/docs/generated/
+/cmd/my\ tool/ @tools
+
+[Security][2] @security
+/pkg/crypto/
+^[Frontend]
+/web/ @frontend
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string][]string{
+		"README.md":            {"@testifysec/maintainers"},
+		"pkg/policy/policy.go": {"@gophers"},
+		"docs/index.md":        {"@writers", "docs@example.com"},
+		"docs/generated/a.md":  {},
+		"cmd/my tool/main.go":  {"@tools"},
+		"pkg/crypto/sign.go":   {"@gophers", "@security"},
+		"web/index.html":       {"@frontend", "@testifysec/maintainers"},
+	}
+
+	for path, expected := range tests {
+		if owners := ownersOf(rules, path); !reflect.DeepEqual(owners, expected) {
+			t.Errorf("expected %v to be owned by %v, got %v", path, expected, owners)
+		}
+	}
+}
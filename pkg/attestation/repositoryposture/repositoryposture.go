@@ -0,0 +1,218 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package repositoryposture
+
+import (
+	"context"
+	"crypto"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/testifysec/go-witness/attestation"
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/log"
+	"github.com/testifysec/witness/pkg/github"
+	"github.com/testifysec/witness/pkg/replay"
+)
+
+const (
+	Name    = "repository-posture"
+	Type    = "https://witness.dev/attestations/repository-posture/v0.1"
+	RunType = attestation.PreRunType
+
+	PlatformGitHub = "github"
+	PlatformGitLab = "gitlab"
+
+	// BaseEnv selects the git revision changed paths are calculated against. Defaults to HEAD~1.
+	BaseEnv     = "WITNESS_CODEOWNERS_BASE"
+	defaultBase = "HEAD~1"
+)
+
+// codeOwnersLocations are where each platform looks for the CODEOWNERS file, in the order it looks. Outside of CI
+// every location is tried.
+var codeOwnersLocations = map[string][]string{
+	PlatformGitHub: {".github/CODEOWNERS", "CODEOWNERS", "docs/CODEOWNERS"},
+	PlatformGitLab: {"CODEOWNERS", "docs/CODEOWNERS", ".gitlab/CODEOWNERS"},
+	"":             {".github/CODEOWNERS", "CODEOWNERS", "docs/CODEOWNERS", ".gitlab/CODEOWNERS"},
+}
+
+// runCommand is replaced in tests
+var runCommand = replay.Output
+
+func init() {
+	attestation.RegisterAttestation(Name, Type, RunType, func() attestation.Attestor {
+		return New()
+	})
+}
+
+// Attestor records who owns the paths the build's commits changed, and the visibility and security features of the
+// repository at build time.
+type Attestor struct {
+	Platform   string `json:"platform,omitempty"`
+	Repository string `json:"repository,omitempty"`
+	Visibility string `json:"visibility,omitempty"`
+	// SecurityFeatures are whether the repository's security features are enabled, by github's names for them such as
+	// secret_scanning and dependabot_alerts. Features the token could not read are left out.
+	SecurityFeatures map[string]bool `json:"securityfeatures"`
+	// CodeOwners is the path of the CODEOWNERS file, relative to the root of the repository.
+	CodeOwners       string               `json:"codeowners,omitempty"`
+	CodeOwnersDigest cryptoutil.DigestSet `json:"codeownersdigest,omitempty"`
+	Base             string               `json:"base"`
+	BaseCommit       string               `json:"basecommit"`
+	HeadCommit       string               `json:"headcommit"`
+	// Owners are the code owners of each path changed between the base and head commits. Paths without owners have
+	// none.
+	Owners  map[string][]string `json:"owners"`
+	Unowned []string            `json:"unowned"`
+}
+
+func New() *Attestor {
+	return &Attestor{
+		SecurityFeatures: map[string]bool{},
+		Owners:           map[string][]string{},
+		Unowned:          []string{},
+	}
+}
+
+func (a *Attestor) Name() string {
+	return Name
+}
+
+func (a *Attestor) Type() string {
+	return Type
+}
+
+func (a *Attestor) RunType() attestation.RunType {
+	return RunType
+}
+
+func (a *Attestor) Attest(ctx *attestation.AttestationContext) error {
+	switch {
+	case os.Getenv("GITHUB_ACTIONS") == "true":
+		if err := a.attestGitHub(ctx.Context()); err != nil {
+			return err
+		}
+	case os.Getenv("GITLAB_CI") == "true":
+		a.attestGitLab()
+	default:
+		log.Debugf("(attestation/repository-posture) not running in github actions or gitlab ci, only recording code owners")
+	}
+
+	return a.attestCodeOwners(ctx.Context(), ctx.WorkingDir(), ctx.Hashes())
+}
+
+func (a *Attestor) attestGitHub(ctx context.Context) error {
+	a.Platform = PlatformGitHub
+	a.Repository = os.Getenv("GITHUB_REPOSITORY")
+	client := github.Client{APIURL: os.Getenv("GITHUB_API_URL"), Token: os.Getenv("GITHUB_TOKEN")}
+	repo, err := client.Repository(ctx, a.Repository)
+	if err != nil {
+		return err
+	}
+
+	a.Visibility = repo.Visibility
+	for feature, setting := range repo.SecurityAndAnalysis {
+		a.SecurityFeatures[feature] = setting.Status == "enabled"
+	}
+
+	alerts, err := client.VulnerabilityAlerts(ctx, a.Repository)
+	if err != nil {
+		log.Debugf("(attestation/repository-posture) failed to get dependabot alerts: %v", err)
+		return nil
+	}
+
+	a.SecurityFeatures["dependabot_alerts"] = alerts
+	return nil
+}
+
+// attestGitLab records what gitlab ci tells the job about the project. Gitlab enables its security scanners through
+// the project's pipeline rather than its settings, so no security features are recorded.
+func (a *Attestor) attestGitLab() {
+	a.Platform = PlatformGitLab
+	a.Repository = os.Getenv("CI_PROJECT_PATH")
+	a.Visibility = os.Getenv("CI_PROJECT_VISIBILITY")
+}
+
+func (a *Attestor) attestCodeOwners(ctx context.Context, wd string, hashes []crypto.Hash) error {
+	a.Base = os.Getenv(BaseEnv)
+	if a.Base == "" {
+		a.Base = defaultBase
+	}
+
+	repoRoot, err := git(ctx, wd, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return err
+	}
+
+	if a.BaseCommit, err = git(ctx, wd, "rev-parse", a.Base); err != nil {
+		return err
+	}
+
+	if a.HeadCommit, err = git(ctx, wd, "rev-parse", "HEAD"); err != nil {
+		return err
+	}
+
+	diff, err := git(ctx, wd, "diff", "--name-only", "-z", a.BaseCommit, a.HeadCommit)
+	if err != nil {
+		return err
+	}
+
+	rules := []rule{}
+	for _, location := range codeOwnersLocations[a.Platform] {
+		data, err := os.ReadFile(filepath.Join(repoRoot, filepath.FromSlash(location)))
+		if os.IsNotExist(err) {
+			continue
+		} else if err != nil {
+			return fmt.Errorf("failed to read %v: %w", location, err)
+		}
+
+		if rules, err = parseCodeOwners(data); err != nil {
+			return fmt.Errorf("failed to parse %v: %w", location, err)
+		}
+
+		if a.CodeOwnersDigest, err = cryptoutil.CalculateDigestSetFromBytes(data, hashes); err != nil {
+			return err
+		}
+
+		a.CodeOwners = location
+		break
+	}
+
+	for _, path := range strings.Split(diff, "\x00") {
+		if path == "" {
+			continue
+		}
+
+		a.Owners[path] = ownersOf(rules, path)
+		if len(a.Owners[path]) == 0 {
+			a.Unowned = append(a.Unowned, path)
+		}
+	}
+
+	sort.Strings(a.Unowned)
+	return nil
+}
+
+func git(ctx context.Context, dir string, args ...string) (string, error) {
+	out, err := runCommand(ctx, "git", append([]string{"-C", dir}, args...)...)
+	if err != nil {
+		return "", fmt.Errorf("failed to run git %v: %w", strings.Join(args, " "), err)
+	}
+
+	return strings.TrimSpace(string(out)), nil
+}
@@ -0,0 +1,100 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package repositoryposture
+
+import (
+	"context"
+	"crypto"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestAttestGitHub(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/repos/testifysec/witness":
+			_, _ = w.Write([]byte(`{"visibility": "public", "security_and_analysis": {"secret_scanning": {"status": "enabled"}, "dependabot_security_updates": {"status": "disabled"}}}`))
+		case "/repos/testifysec/witness/vulnerability-alerts":
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	t.Setenv("GITHUB_REPOSITORY", "testifysec/witness")
+	t.Setenv("GITHUB_API_URL", server.URL)
+
+	a := New()
+	if err := a.attestGitHub(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := map[string]bool{"secret_scanning": true, "dependabot_security_updates": false, "dependabot_alerts": true}
+	if a.Visibility != "public" || !reflect.DeepEqual(a.SecurityFeatures, expected) {
+		t.Errorf("unexpected posture: %v %v", a.Visibility, a.SecurityFeatures)
+	}
+}
+
+func TestAttestCodeOwners(t *testing.T) {
+	dir := t.TempDir()
+	gitRun := func(args ...string) {
+		cmd := exec.Command("git", append([]string{"-C", dir, "-c", "user.name=witness", "-c", "user.email=witness@example.com"}, args...)...)
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v: %s", args, err, out)
+		}
+	}
+
+	write := func(path, content string) {
+		p := filepath.Join(dir, filepath.FromSlash(path))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	gitRun("init", "-q")
+	write(".github/CODEOWNERS", "/pkg/ @gophers\n")
+	write("README.md", "witness")
+	gitRun("add", "-A")
+	gitRun("commit", "-q", "-m", "initial")
+	write("pkg/a.go", "package a")
+	write("README.md", "witness!")
+	gitRun("add", "-A")
+	gitRun("commit", "-q", "-m", "change")
+
+	t.Setenv(BaseEnv, "")
+	a := New()
+	if err := a.attestCodeOwners(context.Background(), dir, []crypto.Hash{crypto.SHA256}); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string][]string{"pkg/a.go": {"@gophers"}, "README.md": {}}
+	if !reflect.DeepEqual(a.Owners, expected) || !reflect.DeepEqual(a.Unowned, []string{"README.md"}) {
+		t.Errorf("unexpected owners %v, unowned %v", a.Owners, a.Unowned)
+	}
+
+	if a.CodeOwners != ".github/CODEOWNERS" || len(a.CodeOwnersDigest) == 0 || a.BaseCommit == a.HeadCommit {
+		t.Errorf("unexpected attestation: %+v", a)
+	}
+}
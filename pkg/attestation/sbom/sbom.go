@@ -0,0 +1,217 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sbom
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/testifysec/go-witness/attestation"
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/log"
+	"github.com/testifysec/witness/pkg/attestation/sbomcompleteness"
+	"github.com/testifysec/witness/pkg/replay"
+)
+
+const (
+	Name    = "sbom"
+	Type    = "https://witness.dev/attestations/sbom/v0.1"
+	RunType = attestation.PostRunType
+
+	FormatSPDX      = sbomcompleteness.FormatSPDX
+	FormatCycloneDX = sbomcompleteness.FormatCycloneDX
+
+	// SourceProduct is the source of an SBOM the step produced, and SourceGenerated of one the attestor generated.
+	SourceProduct   = "product"
+	SourceGenerated = "generated"
+
+	// FormatEnv is the format of the SBOM to generate, spdx or cyclonedx. When the step produced SBOMs in both
+	// formats, the one in this format is recorded. Defaults to cyclonedx.
+	FormatEnv = "WITNESS_SBOM_FORMAT"
+	// GeneratorEnv is the syft executable used to generate SBOMs. Defaults to syft on the PATH.
+	GeneratorEnv = "WITNESS_SBOM_GENERATOR"
+)
+
+// runCommand is replaced in tests
+var runCommand = replay.Output
+
+// syftOutputs are the syft output formats that produce each SBOM format.
+var syftOutputs = map[string]string{
+	FormatSPDX:      "spdx-json",
+	FormatCycloneDX: "cyclonedx-json",
+}
+
+func init() {
+	attestation.RegisterAttestation(Name, Type, RunType, func() attestation.Attestor {
+		return New()
+	})
+}
+
+// Attestor records an SPDX or CycloneDX SBOM for the step's products, with the products as its subjects. An SBOM the
+// step produced is recorded as is. Otherwise one is generated with syft from the working directory.
+type Attestor struct {
+	Format string `json:"format"`
+	Source string `json:"source"`
+	// Path is the product the SBOM was read from, if the step produced it.
+	Path      string               `json:"path,omitempty"`
+	Generator string               `json:"generator,omitempty"`
+	Digest    cryptoutil.DigestSet `json:"digest"`
+	// Artifacts are the products the SBOM describes, which are the step's products other than SBOMs.
+	Artifacts map[string]cryptoutil.DigestSet `json:"artifacts"`
+	SBOM      json.RawMessage                 `json:"sbom"`
+}
+
+func New() *Attestor {
+	return &Attestor{}
+}
+
+func (a *Attestor) Name() string {
+	return Name
+}
+
+func (a *Attestor) Type() string {
+	return Type
+}
+
+func (a *Attestor) RunType() attestation.RunType {
+	return RunType
+}
+
+func (a *Attestor) Attest(ctx *attestation.AttestationContext) error {
+	format := strings.ToLower(os.Getenv(FormatEnv))
+	if format == "" {
+		format = FormatCycloneDX
+	}
+
+	if _, ok := syftOutputs[format]; !ok {
+		return fmt.Errorf("unsupported sbom format %v, expected %v or %v", format, FormatSPDX, FormatCycloneDX)
+	}
+
+	sboms := make(map[string]productSBOM)
+	a.Artifacts = make(map[string]cryptoutil.DigestSet)
+	for path, product := range ctx.Products() {
+		if sbom, ok := readSBOM(ctx, path, product); ok {
+			sboms[path] = sbom
+			continue
+		}
+
+		a.Artifacts[path] = product.Digest
+	}
+
+	if len(sboms) > 0 {
+		path := chooseSBOM(sboms, format)
+		sbom := sboms[path]
+		log.Debugf("(attestation/sbom) recording %v sbom %v", sbom.format, path)
+		a.Format, a.Source, a.Path = sbom.format, SourceProduct, path
+		a.Digest, a.SBOM = ctx.Products()[path].Digest, sbom.data
+		return nil
+	}
+
+	if len(a.Artifacts) == 0 {
+		log.Debugf("(attestation/sbom) step has no products to generate an sbom for")
+		return nil
+	}
+
+	return a.generate(ctx, format)
+}
+
+type productSBOM struct {
+	format string
+	data   json.RawMessage
+}
+
+func readSBOM(ctx *attestation.AttestationContext, path string, product attestation.Product) (productSBOM, bool) {
+	if !strings.HasSuffix(path, ".json") {
+		return productSBOM{}, false
+	}
+
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(ctx.WorkingDir(), path)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		log.Debugf("(attestation/sbom) failed to read product %v: %v", path, err)
+		return productSBOM{}, false
+	}
+
+	format, ok := sbomcompleteness.DetectFormat(data)
+	if !ok {
+		return productSBOM{}, false
+	}
+
+	return productSBOM{format: format, data: data}, true
+}
+
+// chooseSBOM returns the first SBOM, by path, in the preferred format, or the first SBOM if none are in that format.
+func chooseSBOM(sboms map[string]productSBOM, format string) string {
+	paths := make([]string, 0, len(sboms))
+	for path := range sboms {
+		paths = append(paths, path)
+	}
+
+	sort.Strings(paths)
+	for _, path := range paths {
+		if sboms[path].format == format {
+			return path
+		}
+	}
+
+	return paths[0]
+}
+
+func (a *Attestor) generate(ctx *attestation.AttestationContext, format string) error {
+	generator := os.Getenv(GeneratorEnv)
+	if generator == "" {
+		generator = "syft"
+	}
+
+	log.Debugf("(attestation/sbom) generating %v sbom with %v", format, generator)
+	out, err := runCommand(ctx.Context(), generator, "dir:"+ctx.WorkingDir(), "-o", syftOutputs[format], "-q")
+	if err != nil {
+		return fmt.Errorf("step produced no sbom and %v failed to generate one: %w", generator, err)
+	}
+
+	if detected, ok := sbomcompleteness.DetectFormat(out); !ok || detected != format {
+		return fmt.Errorf("%v did not generate a %v sbom", generator, format)
+	}
+
+	digest, err := cryptoutil.CalculateDigestSetFromBytes(out, ctx.Hashes())
+	if err != nil {
+		return fmt.Errorf("failed to calculate sbom digest: %w", err)
+	}
+
+	a.Format, a.Source, a.Generator = format, SourceGenerated, filepath.Base(generator)
+	a.Digest, a.SBOM = digest, out
+	return nil
+}
+
+// Subjects are the artifacts the SBOM describes, and the SBOM itself.
+func (a *Attestor) Subjects() map[string]cryptoutil.DigestSet {
+	subjects := make(map[string]cryptoutil.DigestSet)
+	for path, digest := range a.Artifacts {
+		subjects[fmt.Sprintf("file:%v", path)] = digest
+	}
+
+	if len(a.SBOM) > 0 {
+		subjects[fmt.Sprintf("sbom:%v", a.Format)] = a.Digest
+	}
+
+	return subjects
+}
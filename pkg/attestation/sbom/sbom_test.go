@@ -0,0 +1,147 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sbom
+
+import (
+	"context"
+	"crypto"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/testifysec/go-witness/attestation"
+	"github.com/testifysec/go-witness/attestation/product"
+)
+
+const (
+	cycloneDXSBOM = `{"bomFormat": "CycloneDX", "specVersion": "1.4", "components": [{"name": "app", "version": "1.0.0"}]}`
+	spdxSBOM      = `{"spdxVersion": "SPDX-2.3", "packages": [{"SPDXID": "SPDXRef-app", "name": "app"}]}`
+)
+
+// attest writes files to a working directory and runs the attestor after the product attestor records them.
+func attest(t *testing.T, files map[string]string) (*Attestor, error) {
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	a := New()
+	ctx, err := attestation.NewContext([]attestation.Attestor{a}, attestation.WithWorkingDir(dir), attestation.WithHashes([]crypto.Hash{crypto.SHA256}), attestation.WithProductAttestor(product.New()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return a, ctx.RunAttestors()
+}
+
+func TestProductSBOM(t *testing.T) {
+	t.Setenv(FormatEnv, "")
+	a, err := attest(t, map[string]string{"app": "\x7fELF binary", "sbom.cdx.json": cycloneDXSBOM, "config.json": `{"name": "app"}`})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if a.Format != FormatCycloneDX || a.Source != SourceProduct || a.Path != "sbom.cdx.json" || string(a.SBOM) != cycloneDXSBOM {
+		t.Errorf("unexpected sbom: %+v", a)
+	}
+
+	if len(a.Artifacts) != 2 || a.Artifacts["app"] == nil || a.Artifacts["config.json"] == nil {
+		t.Errorf("expected app and config.json to be the artifacts, got %v", a.Artifacts)
+	}
+
+	subjects := a.Subjects()
+	if len(subjects) != 3 || !subjects["file:app"].Equal(a.Artifacts["app"]) || !subjects["sbom:cyclonedx"].Equal(a.Digest) {
+		t.Errorf("unexpected subjects: %v", subjects)
+	}
+}
+
+func TestPreferredFormat(t *testing.T) {
+	t.Setenv(FormatEnv, "SPDX")
+	a, err := attest(t, map[string]string{"app": "binary", "a.cdx.json": cycloneDXSBOM, "b.spdx.json": spdxSBOM})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if a.Format != FormatSPDX || a.Path != "b.spdx.json" || len(a.Artifacts) != 1 {
+		t.Errorf("expected the spdx sbom to be recorded, got %+v", a)
+	}
+}
+
+func TestGenerate(t *testing.T) {
+	oldRun := runCommand
+	defer func() { runCommand = oldRun }()
+	t.Setenv(FormatEnv, FormatSPDX)
+	t.Setenv(GeneratorEnv, "/opt/bin/syft")
+	runCommand = func(ctx context.Context, name string, args ...string) ([]byte, error) {
+		if name != "/opt/bin/syft" || !strings.HasPrefix(args[0], "dir:") || strings.Join(args[1:], " ") != "-o spdx-json -q" {
+			return nil, errors.New("unexpected command")
+		}
+
+		return []byte(spdxSBOM), nil
+	}
+
+	a, err := attest(t, map[string]string{"app": "binary"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if a.Format != FormatSPDX || a.Source != SourceGenerated || a.Generator != "syft" || a.Path != "" || len(a.Digest) != 1 {
+		t.Errorf("unexpected sbom: %+v", a)
+	}
+
+	runCommand = func(ctx context.Context, name string, args ...string) ([]byte, error) {
+		return []byte(cycloneDXSBOM), nil
+	}
+
+	if _, err := attest(t, map[string]string{"app": "binary"}); err == nil || !strings.Contains(err.Error(), "did not generate a spdx sbom") {
+		t.Errorf("expected an error for an sbom in the wrong format, got %v", err)
+	}
+
+	runCommand = func(ctx context.Context, name string, args ...string) ([]byte, error) {
+		return nil, errors.New("executable file not found")
+	}
+
+	if _, err := attest(t, map[string]string{"app": "binary"}); err == nil || !strings.Contains(err.Error(), "failed to generate one") {
+		t.Errorf("expected an error when syft fails, got %v", err)
+	}
+}
+
+func TestNoProducts(t *testing.T) {
+	oldRun := runCommand
+	defer func() { runCommand = oldRun }()
+	runCommand = func(ctx context.Context, name string, args ...string) ([]byte, error) {
+		return nil, errors.New("unexpected command")
+	}
+
+	a, err := attest(t, map[string]string{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(a.SBOM) != 0 || len(a.Subjects()) != 0 {
+		t.Errorf("expected no sbom, got %+v", a)
+	}
+}
+
+func TestUnsupportedFormat(t *testing.T) {
+	t.Setenv(FormatEnv, "swid")
+	if _, err := attest(t, map[string]string{"app": "binary"}); err == nil || !strings.Contains(err.Error(), "unsupported sbom format") {
+		t.Errorf("expected an unsupported format error, got %v", err)
+	}
+}
@@ -0,0 +1,181 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sbomcompleteness
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// DetectFormat returns the format of an SPDX or CycloneDX json document, and false if the document is neither.
+func DetectFormat(data []byte) (string, bool) {
+	header := struct {
+		SPDXVersion string `json:"spdxVersion"`
+		BOMFormat   string `json:"bomFormat"`
+	}{}
+
+	if err := json.Unmarshal(data, &header); err != nil {
+		return "", false
+	}
+
+	if strings.HasPrefix(header.SPDXVersion, "SPDX-") {
+		return FormatSPDX, true
+	}
+
+	if header.BOMFormat == "CycloneDX" {
+		return FormatCycloneDX, true
+	}
+
+	return "", false
+}
+
+type spdxDocument struct {
+	SPDXVersion  string `json:"spdxVersion"`
+	CreationInfo struct {
+		Created  string   `json:"created"`
+		Creators []string `json:"creators"`
+	} `json:"creationInfo"`
+	Packages []struct {
+		SPDXID       string `json:"SPDXID"`
+		Name         string `json:"name"`
+		VersionInfo  string `json:"versionInfo"`
+		Supplier     string `json:"supplier"`
+		ExternalRefs []struct {
+			ReferenceType string `json:"referenceType"`
+		} `json:"externalRefs"`
+	} `json:"packages"`
+	Relationships []struct {
+		SPDXElementID      string `json:"spdxElementId"`
+		RelatedSPDXElement string `json:"relatedSpdxElement"`
+		RelationshipType   string `json:"relationshipType"`
+	} `json:"relationships"`
+}
+
+func scoreSPDX(data []byte) (elementCounts, bool) {
+	doc := spdxDocument{}
+	if err := json.Unmarshal(data, &doc); err != nil || !strings.HasPrefix(doc.SPDXVersion, "SPDX-") {
+		return elementCounts{}, false
+	}
+
+	related := make(map[string]bool)
+	for _, r := range doc.Relationships {
+		if r.RelationshipType == "DESCRIBES" {
+			continue
+		}
+
+		related[r.SPDXElementID] = true
+		related[r.RelatedSPDXElement] = true
+	}
+
+	counts := elementCounts{components: len(doc.Packages), present: make(map[string]int)}
+	for _, p := range doc.Packages {
+		hasIdentifier := false
+		for _, ref := range p.ExternalRefs {
+			switch ref.ReferenceType {
+			case "purl", "cpe22Type", "cpe23Type", "swid":
+				hasIdentifier = true
+			}
+		}
+
+		counts.add("supplier", assertion(p.Supplier))
+		counts.add("name", p.Name != "")
+		counts.add("version", assertion(p.VersionInfo))
+		counts.add("identifiers", hasIdentifier)
+		counts.add("dependencies", related[p.SPDXID])
+	}
+
+	for _, creator := range doc.CreationInfo.Creators {
+		counts.add("author", assertion(creator))
+	}
+
+	counts.add("timestamp", doc.CreationInfo.Created != "")
+	return counts, true
+}
+
+// assertion returns true if the SPDX value is set to something other than NOASSERTION or NONE.
+func assertion(value string) bool {
+	value = strings.TrimSpace(value)
+	return value != "" && value != "NOASSERTION" && value != "NONE"
+}
+
+type cycloneDXComponent struct {
+	BOMRef    string `json:"bom-ref"`
+	Name      string `json:"name"`
+	Version   string `json:"version"`
+	Publisher string `json:"publisher"`
+	Supplier  *struct {
+		Name string `json:"name"`
+	} `json:"supplier"`
+	PURL       string               `json:"purl"`
+	CPE        string               `json:"cpe"`
+	SWID       json.RawMessage      `json:"swid"`
+	Components []cycloneDXComponent `json:"components"`
+}
+
+type cycloneDXDocument struct {
+	BOMFormat string `json:"bomFormat"`
+	Metadata  struct {
+		Timestamp string `json:"timestamp"`
+		Authors   []struct {
+			Name string `json:"name"`
+		} `json:"authors"`
+		Manufacture *struct {
+			Name string `json:"name"`
+		} `json:"manufacture"`
+	} `json:"metadata"`
+	Components   []cycloneDXComponent `json:"components"`
+	Dependencies []struct {
+		Ref       string   `json:"ref"`
+		DependsOn []string `json:"dependsOn"`
+	} `json:"dependencies"`
+}
+
+func scoreCycloneDX(data []byte) (elementCounts, bool) {
+	doc := cycloneDXDocument{}
+	if err := json.Unmarshal(data, &doc); err != nil || doc.BOMFormat != "CycloneDX" {
+		return elementCounts{}, false
+	}
+
+	related := make(map[string]bool)
+	for _, d := range doc.Dependencies {
+		related[d.Ref] = true
+		for _, ref := range d.DependsOn {
+			related[ref] = true
+		}
+	}
+
+	counts := elementCounts{present: make(map[string]int)}
+	var count func(components []cycloneDXComponent)
+	count = func(components []cycloneDXComponent) {
+		for _, c := range components {
+			counts.components++
+			counts.add("supplier", (c.Supplier != nil && c.Supplier.Name != "") || c.Publisher != "")
+			counts.add("name", c.Name != "")
+			counts.add("version", c.Version != "")
+			counts.add("identifiers", c.PURL != "" || c.CPE != "" || len(c.SWID) > 0)
+			counts.add("dependencies", c.BOMRef != "" && related[c.BOMRef])
+			count(c.Components)
+		}
+	}
+
+	count(doc.Components)
+	for _, author := range doc.Metadata.Authors {
+		counts.add("author", author.Name != "")
+	}
+
+	counts.add("author", doc.Metadata.Manufacture != nil && doc.Metadata.Manufacture.Name != "")
+	counts.add("timestamp", doc.Metadata.Timestamp != "")
+	return counts, true
+}
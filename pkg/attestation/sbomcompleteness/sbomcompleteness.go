@@ -0,0 +1,153 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sbomcompleteness
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/testifysec/go-witness/attestation"
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/log"
+)
+
+const (
+	Name    = "sbom-completeness"
+	Type    = "https://witness.dev/attestations/sbom-completeness/v0.1"
+	RunType = attestation.PostRunType
+
+	FormatSPDX      = "spdx"
+	FormatCycloneDX = "cyclonedx"
+)
+
+// Elements are the NTIA minimum elements an SBOM is scored against. The first four are recorded for each component.
+var Elements = []string{"supplier", "name", "version", "identifiers", "dependencies", "author", "timestamp"}
+
+func init() {
+	attestation.RegisterAttestation(Name, Type, RunType, func() attestation.Attestor {
+		return New()
+	})
+}
+
+// Attestor scores each SPDX or CycloneDX json SBOM among the products against the NTIA minimum elements.
+type Attestor struct {
+	SBOMs map[string]SBOM `json:"sboms"`
+}
+
+// SBOM is the completeness of one SBOM. Elements holds the fraction, from 0 to 1, of components that have each
+// element, or 0 or 1 for the elements describing the whole SBOM. Score is the average of the elements as a percentage.
+type SBOM struct {
+	Format     string               `json:"format"`
+	Digest     cryptoutil.DigestSet `json:"digest"`
+	Components int                  `json:"components"`
+	Elements   map[string]float64   `json:"elements"`
+	Score      float64              `json:"score"`
+}
+
+func New() *Attestor {
+	return &Attestor{}
+}
+
+func (a *Attestor) Name() string {
+	return Name
+}
+
+func (a *Attestor) Type() string {
+	return Type
+}
+
+func (a *Attestor) RunType() attestation.RunType {
+	return RunType
+}
+
+func (a *Attestor) Attest(ctx *attestation.AttestationContext) error {
+	a.SBOMs = make(map[string]SBOM)
+	for name, product := range ctx.Products() {
+		if !strings.HasSuffix(name, ".json") {
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(ctx.WorkingDir(), name))
+		if err != nil {
+			log.Debugf("(attestation/sbom-completeness) failed to read product %v: %v", name, err)
+			continue
+		}
+
+		sbom, ok := Score(data)
+		if !ok {
+			continue
+		}
+
+		sbom.Digest = product.Digest
+		a.SBOMs[name] = sbom
+	}
+
+	return nil
+}
+
+// Score scores an SPDX or CycloneDX json document. It returns false if the document is neither, since not every json
+// product is an SBOM.
+func Score(data []byte) (SBOM, bool) {
+	counts, format := elementCounts{}, ""
+	if c, ok := scoreSPDX(data); ok {
+		counts, format = c, FormatSPDX
+	} else if c, ok := scoreCycloneDX(data); ok {
+		counts, format = c, FormatCycloneDX
+	} else {
+		return SBOM{}, false
+	}
+
+	sbom := SBOM{Format: format, Components: counts.components, Elements: make(map[string]float64, len(Elements))}
+	total := 0.0
+	for _, element := range Elements {
+		value := 0.0
+		if documentElement(element) {
+			if counts.present[element] > 0 {
+				value = 1
+			}
+		} else if counts.components > 0 {
+			value = float64(counts.present[element]) / float64(counts.components)
+		}
+
+		sbom.Elements[element] = round(value, 3)
+		total += value
+	}
+
+	sbom.Score = round(total/float64(len(Elements))*100, 1)
+	return sbom, true
+}
+
+func documentElement(element string) bool {
+	return element == "author" || element == "timestamp"
+}
+
+func round(value float64, places int) float64 {
+	scale := math.Pow(10, float64(places))
+	return math.Round(value*scale) / scale
+}
+
+// elementCounts counts the components with each component element, and whether the SBOM has each document element.
+type elementCounts struct {
+	components int
+	present    map[string]int
+}
+
+func (c *elementCounts) add(element string, present bool) {
+	if present {
+		c.present[element]++
+	}
+}
@@ -0,0 +1,112 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sbomcompleteness
+
+import (
+	"testing"
+)
+
+const spdxSBOM = `{
+  "spdxVersion": "SPDX-2.3",
+  "creationInfo": {"created": "2022-06-01T00:00:00Z", "creators": ["Organization: Example", "Tool: syft"]},
+  "packages": [
+    {"SPDXID": "SPDXRef-app", "name": "app", "versionInfo": "1.0.0", "supplier": "Organization: Example",
+     "externalRefs": [{"referenceType": "purl", "referenceLocator": "pkg:golang/example.com/app@1.0.0"}]},
+    {"SPDXID": "SPDXRef-lib", "name": "lib", "versionInfo": "NOASSERTION", "supplier": "NOASSERTION"}
+  ],
+  "relationships": [
+    {"spdxElementId": "SPDXRef-DOCUMENT", "relatedSpdxElement": "SPDXRef-app", "relationshipType": "DESCRIBES"},
+    {"spdxElementId": "SPDXRef-app", "relatedSpdxElement": "SPDXRef-lib", "relationshipType": "DEPENDS_ON"}
+  ]
+}`
+
+const cycloneDXSBOM = `{
+  "bomFormat": "CycloneDX",
+  "specVersion": "1.4",
+  "metadata": {"timestamp": "2022-06-01T00:00:00Z"},
+  "components": [
+    {"bom-ref": "app", "name": "app", "version": "1.0.0", "supplier": {"name": "Example"}, "purl": "pkg:npm/app@1.0.0",
+     "components": [{"bom-ref": "lib", "name": "lib", "version": "2.0.0", "cpe": "cpe:2.3:a:example:lib:2.0.0"}]}
+  ],
+  "dependencies": [{"ref": "app", "dependsOn": ["lib"]}]
+}`
+
+func TestScore(t *testing.T) {
+	tests := []struct {
+		name       string
+		data       string
+		format     string
+		components int
+		elements   map[string]float64
+		score      float64
+	}{
+		{
+			name:       "spdx",
+			data:       spdxSBOM,
+			format:     FormatSPDX,
+			components: 2,
+			elements:   map[string]float64{"supplier": 0.5, "name": 1, "version": 0.5, "identifiers": 0.5, "dependencies": 1, "author": 1, "timestamp": 1},
+			score:      78.6,
+		},
+		{
+			name:       "cyclonedx",
+			data:       cycloneDXSBOM,
+			format:     FormatCycloneDX,
+			components: 2,
+			elements:   map[string]float64{"supplier": 0.5, "name": 1, "version": 1, "identifiers": 1, "dependencies": 1, "author": 0, "timestamp": 1},
+			score:      78.6,
+		},
+	}
+
+	for _, test := range tests {
+		sbom, ok := Score([]byte(test.data))
+		if !ok {
+			t.Fatalf("%v: expected document to be scored", test.name)
+		}
+
+		if sbom.Format != test.format || sbom.Components != test.components || sbom.Score != test.score {
+			t.Errorf("%v: unexpected sbom: %+v", test.name, sbom)
+		}
+
+		for element, expected := range test.elements {
+			if sbom.Elements[element] != expected {
+				t.Errorf("%v: expected %v to be %v, got %v", test.name, element, expected, sbom.Elements[element])
+			}
+		}
+	}
+}
+
+func TestScoreNotSBOM(t *testing.T) {
+	for _, data := range []string{`{"name": "package.json"}`, `[1, 2]`, `not json`} {
+		if _, ok := Score([]byte(data)); ok {
+			t.Errorf("expected %v to not be scored", data)
+		}
+	}
+}
+
+func TestDetectFormat(t *testing.T) {
+	tests := map[string]string{spdxSBOM: FormatSPDX, cycloneDXSBOM: FormatCycloneDX}
+	for data, expected := range tests {
+		if format, ok := DetectFormat([]byte(data)); !ok || format != expected {
+			t.Errorf("expected format %v, got %v", expected, format)
+		}
+	}
+
+	for _, data := range []string{`{"name": "package.json"}`, `{"spdxVersion": "2.3"}`, `not json`} {
+		if _, ok := DetectFormat([]byte(data)); ok {
+			t.Errorf("expected %v to not be detected as an sbom", data)
+		}
+	}
+}
@@ -0,0 +1,270 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package secretscan
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/testifysec/go-witness/attestation"
+	"github.com/testifysec/go-witness/log"
+	"github.com/testifysec/witness/pkg/attestation/command"
+)
+
+const (
+	Name    = "secret-scan"
+	Type    = "https://witness.dev/attestations/secret-scan/v0.1"
+	RunType = attestation.PostRunType
+
+	// Sources of findings.
+	SourceFile    = "file"
+	SourceProduct = "product"
+	SourceStdout  = "stdout"
+	SourceStderr  = "stderr"
+
+	// maxFileSize limits the files that are scanned. Larger files are counted as skipped.
+	maxFileSize = 10 << 20
+	// maxFindings limits the findings recorded. Findings beyond it are only counted.
+	maxFindings = 100
+	// maxReportedFindings limits how many findings are named in a summary.
+	maxReportedFindings = 5
+)
+
+func init() {
+	attestation.RegisterAttestation(Name, Type, RunType, func() attestation.Attestor {
+		return New()
+	})
+}
+
+// Attestor scans the working directory, the step's products, and the command's stdout and stderr for secrets, and
+// records what it found with the secrets redacted. A collection whose attestation has no findings is evidence that the
+// step didn't leak credentials matching the rules it lists.
+type Attestor struct {
+	Rules        []string  `json:"rules"`
+	FilesScanned int       `json:"filesscanned"`
+	FilesSkipped int       `json:"filesskipped,omitempty"`
+	Findings     []Finding `json:"findings"`
+	// Truncated counts the findings beyond the ones recorded.
+	Truncated int `json:"truncated,omitempty"`
+}
+
+// Finding is a possible secret. Path is relative to the working directory, and Line is only set for text.
+type Finding struct {
+	Rule     string `json:"rule"`
+	Source   string `json:"source"`
+	Path     string `json:"path,omitempty"`
+	Line     int    `json:"line,omitempty"`
+	Redacted string `json:"redacted"`
+	// Fingerprint is the hex sha256 digest of the secret, so a known secret can be looked for without recording it.
+	Fingerprint string `json:"fingerprint"`
+}
+
+func New() *Attestor {
+	return &Attestor{}
+}
+
+func (a *Attestor) Name() string {
+	return Name
+}
+
+func (a *Attestor) Type() string {
+	return Type
+}
+
+func (a *Attestor) RunType() attestation.RunType {
+	return RunType
+}
+
+func (a *Attestor) Attest(ctx *attestation.AttestationContext) error {
+	a.Findings = make([]Finding, 0)
+	for _, rule := range Rules {
+		a.Rules = append(a.Rules, rule.ID)
+	}
+
+	if cr, ok := command.Run(ctx.CompletedAttestors()); ok {
+		a.scan(SourceStdout, "", []byte(cr.Stdout))
+		a.scan(SourceStderr, "", []byte(cr.Stderr))
+	}
+
+	products := ctx.Products()
+	err := filepath.WalkDir(ctx.WorkingDir(), func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			log.Debugf("(attestation/secret-scan) failed to read %v: %v", path, err)
+			return nil
+		}
+
+		if d.IsDir() {
+			if d.Name() == ".git" {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(ctx.WorkingDir(), path)
+		if err != nil {
+			return err
+		}
+
+		a.scanFile(path, filepath.ToSlash(rel), products)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to scan working directory: %w", err)
+	}
+
+	if len(a.Findings) > 0 {
+		log.Warnf("secret scan found %d possible secrets: %v", len(a.Findings)+a.Truncated, a.Summary())
+	}
+
+	return nil
+}
+
+func (a *Attestor) scanFile(path, rel string, products map[string]attestation.Product) {
+	info, err := os.Stat(path)
+	if err != nil || info.Size() > maxFileSize {
+		a.FilesSkipped++
+		return
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		log.Debugf("(attestation/secret-scan) failed to read %v: %v", path, err)
+		a.FilesSkipped++
+		return
+	}
+
+	source := SourceFile
+	if _, ok := products[rel]; ok {
+		source = SourceProduct
+	}
+
+	a.FilesScanned++
+	a.scan(source, rel, data)
+}
+
+// scan records the secrets in data. A secret matched by more than one rule is recorded once, for the first rule.
+func (a *Attestor) scan(source, path string, data []byte) {
+	if len(data) == 0 {
+		return
+	}
+
+	text := !bytes.Contains(data[:min(len(data), 8000)], []byte{0})
+	seen := make(map[string]bool)
+	for _, rule := range Rules {
+		for _, match := range rule.Pattern.FindAllSubmatchIndex(data, -1) {
+			start, end := match[2*rule.Group], match[2*rule.Group+1]
+			if start < 0 {
+				continue
+			}
+
+			secret := string(data[start:end])
+			if rule.Entropy && entropy(secret) < minEntropy {
+				continue
+			}
+
+			digest := sha256.Sum256([]byte(secret))
+			finding := Finding{
+				Rule:        rule.ID,
+				Source:      source,
+				Path:        path,
+				Redacted:    redact(secret),
+				Fingerprint: hex.EncodeToString(digest[:]),
+			}
+
+			if text {
+				finding.Line = bytes.Count(data[:start], []byte{'\n'}) + 1
+			}
+
+			key := fmt.Sprintf("%v:%v", finding.Fingerprint, finding.Line)
+			if seen[key] {
+				continue
+			}
+
+			seen[key] = true
+			if len(a.Findings) >= maxFindings {
+				a.Truncated++
+				continue
+			}
+
+			a.Findings = append(a.Findings, finding)
+		}
+	}
+}
+
+// redact keeps the first four characters of a secret, which are often a token's well known prefix, and masks the rest.
+func redact(secret string) string {
+	if len(secret) <= 8 {
+		return strings.Repeat("*", len(secret))
+	}
+
+	return secret[:4] + strings.Repeat("*", min(len(secret)-4, 16))
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+
+	return b
+}
+
+// Summary names the first few findings.
+func (a *Attestor) Summary() string {
+	names := make([]string, 0, maxReportedFindings)
+	for i, f := range a.Findings {
+		if i == maxReportedFindings {
+			break
+		}
+
+		location := f.Source
+		if f.Path != "" {
+			location = f.Path
+		}
+
+		if f.Line > 0 {
+			location = fmt.Sprintf("%v:%d", location, f.Line)
+		}
+
+		names = append(names, fmt.Sprintf("%v in %v", f.Rule, location))
+	}
+
+	if more := len(a.Findings) + a.Truncated - len(names); more > 0 {
+		return fmt.Sprintf("%v, and %d more", strings.Join(names, ", "), more)
+	}
+
+	return strings.Join(names, ", ")
+}
+
+// FromCollection returns the collection's secret-scan attestation, if it has one.
+func FromCollection(collection attestation.Collection) (*Attestor, bool) {
+	for _, a := range collection.Attestations {
+		if scan, ok := a.Attestation.(*Attestor); ok {
+			return scan, true
+		}
+	}
+
+	return nil, false
+}
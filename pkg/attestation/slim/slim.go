@@ -0,0 +1,259 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package slim
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/testifysec/go-witness/attestation"
+	"github.com/testifysec/go-witness/attestation/commandrun"
+	"github.com/testifysec/go-witness/attestation/material"
+	"github.com/testifysec/go-witness/attestation/product"
+)
+
+const (
+	Name    = "slim"
+	Type    = "https://witness.dev/attestations/slim/v0.1"
+	RunType = attestation.PostRunType
+)
+
+func init() {
+	attestation.RegisterAttestation(Name, Type, RunType, func() attestation.Attestor {
+		return New()
+	})
+}
+
+// Options control how attestations in a collection are reduced in size.
+type Options struct {
+	// MaxAttestationSize is the largest an attestation may be, in bytes, after it has been summarized. Larger
+	// attestations are dropped from the collection. 0 disables the limit.
+	MaxAttestationSize int
+	// MaxProcesses limits the processes recorded by the command-run attestor to those that opened the most files.
+	// 0 disables the limit.
+	MaxProcesses int
+	// DigestOnlyFiles removes everything but the sha256 digest from recorded files.
+	DigestOnlyFiles bool
+}
+
+// Attestor records the attestations that were summarized or dropped from a collection to stay within its size budget.
+// It is not run; Collection adds it to the collections it changes.
+type Attestor struct {
+	Attestations []Entry `json:"attestations"`
+}
+
+type Entry struct {
+	Type string `json:"type"`
+	// OriginalDigest is the sha256 of the attestation's json before it was changed.
+	OriginalDigest string   `json:"originaldigest"`
+	OriginalSize   int      `json:"originalsize"`
+	Size           int      `json:"size"`
+	Actions        []string `json:"actions"`
+	Dropped        bool     `json:"dropped"`
+}
+
+func New() *Attestor {
+	return &Attestor{}
+}
+
+func (a *Attestor) Name() string {
+	return Name
+}
+
+func (a *Attestor) Type() string {
+	return Type
+}
+
+func (a *Attestor) RunType() attestation.RunType {
+	return RunType
+}
+
+func (a *Attestor) Attest(ctx *attestation.AttestationContext) error {
+	return nil
+}
+
+// Collection returns a copy of the collection with its attestations reduced according to opts. If any attestation
+// changed, a slim attestation recording the changes is appended and changed is true.
+func Collection(collection attestation.Collection, opts Options) (slimmed attestation.Collection, changed bool, err error) {
+	record := New()
+	attestors := make([]attestation.Attestor, 0, len(collection.Attestations)+1)
+	for _, ca := range collection.Attestations {
+		original, err := json.Marshal(ca.Attestation)
+		if err != nil {
+			return collection, false, fmt.Errorf("failed to marshal %v attestation: %w", ca.Type, err)
+		}
+
+		data, actions, err := summarize(ca.Type, original, opts)
+		if err != nil {
+			return collection, false, fmt.Errorf("failed to summarize %v attestation: %w", ca.Type, err)
+		}
+
+		entry := Entry{
+			Type:           ca.Type,
+			OriginalDigest: fmt.Sprintf("sha256:%x", sha256.Sum256(original)),
+			OriginalSize:   len(original),
+			Size:           len(data),
+			Actions:        actions,
+		}
+
+		if opts.MaxAttestationSize > 0 && len(data) > opts.MaxAttestationSize {
+			entry.Dropped = true
+			entry.Size = 0
+			entry.Actions = append(entry.Actions, fmt.Sprintf("dropped: %d bytes exceeds limit of %d", len(data), opts.MaxAttestationSize))
+			record.Attestations = append(record.Attestations, entry)
+			continue
+		}
+
+		if len(actions) == 0 {
+			attestors = append(attestors, ca.Attestation)
+			continue
+		}
+
+		// round trip through the attestor's type so the result is still valid for that attestation type
+		factory, ok := attestation.FactoryByType(ca.Type)
+		if !ok {
+			return collection, false, attestation.ErrAttestationNotFound(ca.Type)
+		}
+
+		attestor := factory()
+		if err := json.Unmarshal(data, &attestor); err != nil {
+			return collection, false, fmt.Errorf("failed to unmarshal summarized %v attestation: %w", ca.Type, err)
+		}
+
+		record.Attestations = append(record.Attestations, entry)
+		attestors = append(attestors, attestor)
+	}
+
+	if len(record.Attestations) == 0 {
+		return collection, false, nil
+	}
+
+	return attestation.NewCollection(collection.Name, append(attestors, record)), true, nil
+}
+
+func summarize(attestationType string, data []byte, opts Options) ([]byte, []string, error) {
+	actions := make([]string, 0)
+	switch attestationType {
+	case commandrun.Type:
+		cr := map[string]interface{}{}
+		if err := json.Unmarshal(data, &cr); err != nil {
+			return nil, nil, err
+		}
+
+		processes, _ := cr["processes"].([]interface{})
+		if opts.MaxProcesses > 0 && len(processes) > opts.MaxProcesses {
+			cr["processes"] = topProcesses(processes, opts.MaxProcesses)
+			actions = append(actions, fmt.Sprintf("kept %d of %d processes", opts.MaxProcesses, len(processes)))
+		}
+
+		if opts.DigestOnlyFiles {
+			for _, p := range processes {
+				if process, ok := p.(map[string]interface{}); ok {
+					if files, ok := process["openedfiles"].(map[string]interface{}); ok {
+						sha256Only(files)
+					}
+				}
+			}
+
+			actions = append(actions, "digest only opened files")
+		}
+
+		if len(actions) == 0 {
+			return data, nil, nil
+		}
+
+		out, err := json.Marshal(cr)
+		return out, actions, err
+
+	case material.Type, product.Type:
+		if !opts.DigestOnlyFiles {
+			return data, nil, nil
+		}
+
+		files := map[string]interface{}{}
+		if err := json.Unmarshal(data, &files); err != nil {
+			return nil, nil, err
+		}
+
+		if attestationType == product.Type {
+			for _, f := range files {
+				if p, ok := f.(map[string]interface{}); ok {
+					delete(p, "mime_type")
+					if digestSet, ok := p["digest"].(map[string]interface{}); ok {
+						keepSHA256(digestSet)
+					}
+				}
+			}
+		} else {
+			sha256Only(files)
+		}
+
+		out, err := json.Marshal(files)
+		return out, []string{"digest only files"}, err
+	}
+
+	return data, nil, nil
+}
+
+// topProcesses keeps the n processes that opened the most files, in their original order.
+func topProcesses(processes []interface{}, n int) []interface{} {
+	openedFiles := func(p interface{}) int {
+		process, _ := p.(map[string]interface{})
+		files, _ := process["openedfiles"].(map[string]interface{})
+		return len(files)
+	}
+
+	indexes := make([]int, len(processes))
+	for i := range indexes {
+		indexes[i] = i
+	}
+
+	sort.SliceStable(indexes, func(i, j int) bool {
+		return openedFiles(processes[indexes[i]]) > openedFiles(processes[indexes[j]])
+	})
+
+	indexes = indexes[:n]
+	sort.Ints(indexes)
+	kept := make([]interface{}, 0, n)
+	for _, i := range indexes {
+		kept = append(kept, processes[i])
+	}
+
+	return kept
+}
+
+// sha256Only removes every digest other than sha256 from a map of file names to digest sets.
+func sha256Only(files map[string]interface{}) {
+	for _, f := range files {
+		if digestSet, ok := f.(map[string]interface{}); ok {
+			keepSHA256(digestSet)
+		}
+	}
+}
+
+// keepSHA256 removes every digest other than sha256 from the digest set, if it has a sha256 digest.
+func keepSHA256(digestSet map[string]interface{}) {
+	if _, ok := digestSet["sha256"]; !ok {
+		return
+	}
+
+	for name := range digestSet {
+		if name != "sha256" {
+			delete(digestSet, name)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package slim
+
+import (
+	"crypto"
+	"encoding/json"
+	"testing"
+
+	"github.com/testifysec/go-witness/attestation"
+	"github.com/testifysec/go-witness/attestation/commandrun"
+	"github.com/testifysec/go-witness/attestation/material"
+	"github.com/testifysec/go-witness/cryptoutil"
+)
+
+func testCollection(t *testing.T) attestation.Collection {
+	processes := []commandrun.ProcessInfo{
+		{ProcessID: 1, OpenedFiles: map[string]cryptoutil.DigestSet{"a": {crypto.SHA256: "aa"}}},
+		{ProcessID: 2, OpenedFiles: map[string]cryptoutil.DigestSet{"a": {crypto.SHA256: "aa"}, "b": {crypto.SHA256: "bb"}, "c": {crypto.SHA256: "cc"}}},
+		{ProcessID: 3},
+		{ProcessID: 4, OpenedFiles: map[string]cryptoutil.DigestSet{"a": {crypto.SHA256: "aa"}, "b": {crypto.SHA256: "bb"}}},
+	}
+
+	cr := commandrun.New()
+	cr.Cmd = []string{"make"}
+	cr.Processes = processes
+
+	m := material.New()
+	materials, err := json.Marshal(map[string]map[string]string{"main.go": {"sha256": "aa", "sha1": "bb"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := json.Unmarshal(materials, m); err != nil {
+		t.Fatal(err)
+	}
+
+	return attestation.NewCollection("build", []attestation.Attestor{cr, m})
+}
+
+func TestCollectionUnchanged(t *testing.T) {
+	collection := testCollection(t)
+	slimmed, changed, err := Collection(collection, Options{MaxProcesses: 10})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if changed || len(slimmed.Attestations) != len(collection.Attestations) {
+		t.Errorf("expected collection to be unchanged")
+	}
+}
+
+func TestCollectionMaxProcesses(t *testing.T) {
+	slimmed, changed, err := Collection(testCollection(t), Options{MaxProcesses: 2})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !changed || len(slimmed.Attestations) != 3 {
+		t.Fatalf("expected slim attestation to be added, got %d attestations", len(slimmed.Attestations))
+	}
+
+	cr, ok := slimmed.Attestations[0].Attestation.(*commandrun.CommandRun)
+	if !ok {
+		t.Fatalf("expected command run attestation, got %T", slimmed.Attestations[0].Attestation)
+	}
+
+	if len(cr.Processes) != 2 || cr.Processes[0].ProcessID != 2 || cr.Processes[1].ProcessID != 4 {
+		t.Errorf("expected processes 2 and 4 to be kept, got %+v", cr.Processes)
+	}
+
+	record, ok := slimmed.Attestations[2].Attestation.(*Attestor)
+	if !ok || len(record.Attestations) != 1 || record.Attestations[0].Type != commandrun.Type {
+		t.Errorf("unexpected slim record: %+v", slimmed.Attestations[2].Attestation)
+	}
+}
+
+func TestCollectionDigestOnlyAndDrop(t *testing.T) {
+	slimmed, changed, err := Collection(testCollection(t), Options{DigestOnlyFiles: true, MaxAttestationSize: 100})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !changed || len(slimmed.Attestations) != 2 {
+		t.Fatalf("expected command run to be dropped, got %d attestations", len(slimmed.Attestations))
+	}
+
+	m, ok := slimmed.Attestations[0].Attestation.(*material.Attestor)
+	if !ok {
+		t.Fatalf("expected material attestation, got %T", slimmed.Attestations[0].Attestation)
+	}
+
+	ds := m.Materials()["main.go"]
+	if len(ds) != 1 || ds[crypto.SHA256] != "aa" {
+		t.Errorf("expected only sha256 digest to be kept, got %v", ds)
+	}
+
+	record := slimmed.Attestations[1].Attestation.(*Attestor)
+	if len(record.Attestations) != 2 || !record.Attestations[0].Dropped || record.Attestations[1].Dropped {
+		t.Errorf("unexpected slim record: %+v", record.Attestations)
+	}
+}
@@ -0,0 +1,123 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package svid
+
+import (
+	"crypto"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"time"
+
+	"github.com/testifysec/go-witness/attestation"
+	"github.com/testifysec/go-witness/cryptoutil"
+)
+
+const (
+	Name    = "spiffe-svid"
+	Type    = "https://witness.dev/attestations/spiffe-svid/v0.1"
+	RunType = attestation.PostRunType
+)
+
+func init() {
+	attestation.RegisterAttestation(Name, Type, RunType, func() attestation.Attestor {
+		return New()
+	})
+}
+
+// Attestor records the X.509 SVID of the workload that signed the collection, such as a build farm worker that gets
+// its signing certificate from SPIRE. It is not run; Collection adds it to the collections signed with an SVID.
+type Attestor struct {
+	ID            string    `json:"id"`
+	TrustDomain   string    `json:"trustDomain"`
+	Path          string    `json:"path"`
+	Certificate   string    `json:"certificate"`
+	Intermediates []string  `json:"intermediates,omitempty"`
+	NotAfter      time.Time `json:"notAfter"`
+}
+
+func New() *Attestor {
+	return &Attestor{}
+}
+
+func (a *Attestor) Name() string {
+	return Name
+}
+
+func (a *Attestor) Type() string {
+	return Type
+}
+
+func (a *Attestor) RunType() attestation.RunType {
+	return RunType
+}
+
+func (a *Attestor) Attest(ctx *attestation.AttestationContext) error {
+	return nil
+}
+
+// Subjects returns a spiffe:<id> subject with the sha256 digest of the SPIFFE ID, so the collections a workload signed
+// can be looked up by its ID.
+func (a *Attestor) Subjects() map[string]cryptoutil.DigestSet {
+	return map[string]cryptoutil.DigestSet{
+		"spiffe:" + a.ID: {crypto.SHA256: fmt.Sprintf("%x", sha256.Sum256([]byte(a.ID)))},
+	}
+}
+
+// FromSigner returns the SVID of the signer, if it signs with an X.509 certificate holding a SPIFFE ID.
+func FromSigner(signer cryptoutil.Signer) (*Attestor, bool) {
+	x509Signer, ok := signer.(*cryptoutil.X509Signer)
+	if !ok || x509Signer.Certificate() == nil {
+		return nil, false
+	}
+
+	cert := x509Signer.Certificate()
+	for _, uri := range cert.URIs {
+		if uri.Scheme != "spiffe" {
+			continue
+		}
+
+		a := &Attestor{
+			ID:          uri.String(),
+			TrustDomain: uri.Host,
+			Path:        uri.Path,
+			Certificate: encodeCertificate(cert),
+			NotAfter:    cert.NotAfter,
+		}
+
+		for _, intermediate := range x509Signer.Intermediates() {
+			a.Intermediates = append(a.Intermediates, encodeCertificate(intermediate))
+		}
+
+		return a, true
+	}
+
+	return nil, false
+}
+
+func encodeCertificate(cert *x509.Certificate) string {
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw}))
+}
+
+// Collection returns a copy of the collection with the SVID appended.
+func Collection(collection attestation.Collection, a *Attestor) attestation.Collection {
+	attestors := make([]attestation.Attestor, 0, len(collection.Attestations)+1)
+	for _, ca := range collection.Attestations {
+		attestors = append(attestors, ca.Attestation)
+	}
+
+	return attestation.NewCollection(collection.Name, append(attestors, a))
+}
@@ -0,0 +1,103 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package svid
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"math/big"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/testifysec/go-witness/attestation"
+	"github.com/testifysec/go-witness/cryptoutil"
+)
+
+func newSigner(t *testing.T, uris ...string) cryptoutil.Signer {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+
+	for _, uri := range uris {
+		parsed, err := url.Parse(uri)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		template.URIs = append(template.URIs, parsed)
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signer, err := cryptoutil.NewX509Signer(cryptoutil.NewECDSASigner(key, crypto.SHA256), cert, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return signer
+}
+
+func TestFromSigner(t *testing.T) {
+	a, ok := FromSigner(newSigner(t, "https://example.com/not-spiffe", "spiffe://corp/ci/prod/builder-1"))
+	if !ok {
+		t.Fatal("expected svid from signer with a spiffe id")
+	}
+
+	if a.ID != "spiffe://corp/ci/prod/builder-1" || a.TrustDomain != "corp" || a.Path != "/ci/prod/builder-1" || a.Certificate == "" {
+		t.Fatalf("unexpected svid: %+v", a)
+	}
+
+	subjects := a.Subjects()
+	if _, ok := subjects["spiffe:spiffe://corp/ci/prod/builder-1"]; !ok {
+		t.Fatalf("unexpected subjects: %v", subjects)
+	}
+
+	collection := Collection(attestation.NewCollection("build", nil), a)
+	if len(collection.Attestations) != 1 || collection.Attestations[0].Type != Type {
+		t.Fatalf("unexpected collection: %+v", collection)
+	}
+
+	if _, ok := FromSigner(newSigner(t)); ok {
+		t.Fatal("expected no svid from signer without a spiffe id")
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := FromSigner(cryptoutil.NewECDSASigner(key, crypto.SHA256)); ok {
+		t.Fatal("expected no svid from signer without a certificate")
+	}
+}
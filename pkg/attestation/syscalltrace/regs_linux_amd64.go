@@ -0,0 +1,43 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux && amd64
+
+package syscalltrace
+
+import "golang.org/x/sys/unix"
+
+// syscalls are the names of the system calls the tracer handles.
+var syscalls = map[uint64]string{
+	unix.SYS_EXECVE:   "execve",
+	unix.SYS_EXECVEAT: "execveat",
+	unix.SYS_OPEN:     "open",
+	unix.SYS_CREAT:    "creat",
+	unix.SYS_OPENAT:   "openat",
+	unix.SYS_OPENAT2:  "openat2",
+	unix.SYS_CONNECT:  "connect",
+	unix.SYS_SENDTO:   "sendto",
+	unix.SYS_SENDMSG:  "sendmsg",
+}
+
+// registers returns the number, arguments, and return value of the system call the thread is stopped in. The return
+// value is only meaningful when the thread is stopped leaving the system call.
+func registers(tid int) (uint64, [6]uint64, int64, error) {
+	regs := unix.PtraceRegs{}
+	if err := unix.PtraceGetRegs(tid, &regs); err != nil {
+		return 0, [6]uint64{}, 0, err
+	}
+
+	return regs.Orig_rax, [6]uint64{regs.Rdi, regs.Rsi, regs.Rdx, regs.R10, regs.R8, regs.R9}, int64(regs.Rax), nil
+}
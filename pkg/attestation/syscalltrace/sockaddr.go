@@ -0,0 +1,87 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package syscalltrace
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+)
+
+// The linux values of the socket address families and open flags, which are read from the memory and registers of
+// the traced processes as is.
+const (
+	afUnix  = 1
+	afInet  = 2
+	afInet6 = 10
+
+	oWronly = 0x1
+	oRdwr   = 0x2
+	oCreat  = 0x40
+	oTrunc  = 0x200
+	oAppend = 0x400
+)
+
+// parseSockaddr parses the socket address a traced process passed to connect, sendto, or sendmsg. Only unix, inet,
+// and inet6 addresses are parsed. Tracing is only supported on little endian architectures, so the family is read as
+// little endian.
+func parseSockaddr(b []byte) (family, address string, port int, ok bool) {
+	if len(b) < 2 {
+		return "", "", 0, false
+	}
+
+	switch binary.LittleEndian.Uint16(b) {
+	case afInet:
+		if len(b) < 8 {
+			return "", "", 0, false
+		}
+
+		return "inet", net.IP(append([]byte{}, b[4:8]...)).String(), int(binary.BigEndian.Uint16(b[2:4])), true
+	case afInet6:
+		if len(b) < 24 {
+			return "", "", 0, false
+		}
+
+		return "inet6", net.IP(append([]byte{}, b[8:24]...)).String(), int(binary.BigEndian.Uint16(b[2:4])), true
+	case afUnix:
+		path := b[2:]
+		if len(path) > 0 && path[0] == 0 {
+			return "unix", "@" + string(bytes.TrimRight(path[1:], "\x00")), 0, true
+		}
+
+		if i := bytes.IndexByte(path, 0); i >= 0 {
+			path = path[:i]
+		}
+
+		return "unix", string(path), 0, true
+	}
+
+	return "", "", 0, false
+}
+
+// isEgress reports whether the connection leaves the host.
+func isEgress(c Connection) bool {
+	if c.Family != "inet" && c.Family != "inet6" {
+		return false
+	}
+
+	ip := net.ParseIP(c.Address)
+	return ip != nil && !ip.IsLoopback() && !ip.IsUnspecified()
+}
+
+// openedForWrite reports whether a file opened with flags may be written to.
+func openedForWrite(flags uint64) bool {
+	return flags&(oWronly|oRdwr|oCreat|oTrunc|oAppend) != 0
+}
@@ -0,0 +1,77 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package syscalltrace
+
+import (
+	"testing"
+)
+
+func TestParseSockaddr(t *testing.T) {
+	inet6 := make([]byte, 28)
+	inet6[0], inet6[2], inet6[3], inet6[23] = afInet6, 0x01, 0xbb, 1
+
+	tests := []struct {
+		name    string
+		data    []byte
+		family  string
+		address string
+		port    int
+		ok      bool
+	}{
+		{"inet", []byte{afInet, 0, 0x00, 0x35, 8, 8, 4, 4, 0, 0, 0, 0, 0, 0, 0, 0}, "inet", "8.8.4.4", 53, true},
+		{"inet6", inet6, "inet6", "::1", 443, true},
+		{"unix", append([]byte{afUnix, 0}, "/var/run/docker.sock\x00"...), "unix", "/var/run/docker.sock", 0, true},
+		{"abstract unix", append([]byte{afUnix, 0, 0}, "dbus"...), "unix", "@dbus", 0, true},
+		{"netlink", []byte{16, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}, "", "", 0, false},
+		{"short inet", []byte{afInet, 0, 0, 53}, "", "", 0, false},
+		{"empty", nil, "", "", 0, false},
+	}
+
+	for _, test := range tests {
+		family, address, port, ok := parseSockaddr(test.data)
+		if family != test.family || address != test.address || port != test.port || ok != test.ok {
+			t.Errorf("%v: got %v %v %v %v, expected %v %v %v %v", test.name, family, address, port, ok, test.family, test.address, test.port, test.ok)
+		}
+	}
+}
+
+func TestIsEgress(t *testing.T) {
+	tests := map[Connection]bool{
+		{Family: "inet", Address: "140.82.112.3", Port: 443}:  true,
+		{Family: "inet6", Address: "2606:4700::1", Port: 443}: true,
+		{Family: "inet", Address: "127.0.0.53", Port: 53}:     false,
+		{Family: "inet6", Address: "::1", Port: 8080}:         false,
+		{Family: "inet", Address: "0.0.0.0", Port: 8080}:      false,
+		{Family: "unix", Address: "/var/run/docker.sock"}:     false,
+	}
+
+	for connection, expected := range tests {
+		if isEgress(connection) != expected {
+			t.Errorf("expected egress of %v to be %v", connection, expected)
+		}
+	}
+}
+
+func TestOpenedForWrite(t *testing.T) {
+	if openedForWrite(0) {
+		t.Error("expected O_RDONLY to be a read")
+	}
+
+	for _, flags := range []uint64{oWronly, oRdwr, oCreat | oWronly, oAppend | oWronly, oTrunc} {
+		if !openedForWrite(flags) {
+			t.Errorf("expected %#x to be a write", flags)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package syscalltrace traces the system calls of the command witness run records, so policies can assert the step
+// was hermetic: which processes it started, which files they read and wrote, and which addresses they connected to.
+package syscalltrace
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+
+	"github.com/testifysec/go-witness/attestation"
+	"github.com/testifysec/go-witness/attestation/commandrun"
+	"github.com/testifysec/go-witness/attestation/environment"
+	"github.com/testifysec/go-witness/cryptoutil"
+)
+
+const (
+	Name    = "syscall-trace"
+	Type    = "https://witness.dev/attestations/syscall-trace/v0.1"
+	RunType = attestation.Internal
+)
+
+func init() {
+	attestation.RegisterAttestation(Name, Type, RunType, func() attestation.Attestor {
+		return New()
+	})
+}
+
+// Attestor records the system calls made by the processes of a traced command. It is not run; the traced command
+// records it as Command.Trace.
+type Attestor struct {
+	Processes []Process `json:"processes"`
+	// Reads are the regular files opened for reading, with their digests when they were first opened.
+	Reads map[string]cryptoutil.DigestSet `json:"reads"`
+	// Writes are the regular files opened for writing, with their digests after the command exited. Files removed
+	// before the command exited have no digests.
+	Writes      map[string]cryptoutil.DigestSet `json:"writes"`
+	Connections []Connection                    `json:"connections"`
+	// Egress is true if a process connected or sent to an address outside the host.
+	Egress bool `json:"egress"`
+}
+
+// Process is a process started by the traced command. The command's own process has the pid of witness as its parent.
+type Process struct {
+	ProcessID int    `json:"processid"`
+	ParentPID int    `json:"parentpid"`
+	Program   string `json:"program,omitempty"`
+	Cmdline   string `json:"cmdline,omitempty"`
+}
+
+// Connection is an address a process connected or sent a datagram to. Address is the path of unix sockets, prefixed
+// with @ for abstract sockets.
+type Connection struct {
+	ProcessID int    `json:"processid"`
+	Syscall   string `json:"syscall"`
+	Family    string `json:"family"`
+	Address   string `json:"address"`
+	Port      int    `json:"port,omitempty"`
+}
+
+func New() *Attestor {
+	return &Attestor{
+		Processes:   []Process{},
+		Reads:       map[string]cryptoutil.DigestSet{},
+		Writes:      map[string]cryptoutil.DigestSet{},
+		Connections: []Connection{},
+	}
+}
+
+func (a *Attestor) Name() string {
+	return Name
+}
+
+func (a *Attestor) Type() string {
+	return Type
+}
+
+func (a *Attestor) RunType() attestation.RunType {
+	return RunType
+}
+
+func (a *Attestor) Attest(ctx *attestation.AttestationContext) error {
+	return nil
+}
+
+// Command runs a command in place of the command-run attestor, tracing the system calls of every process it starts.
+// It records the command-run attestation the command-run attestor would have, with the traced processes and the files
+// they read, and records the rest of the trace as Trace. Tracing is only supported on linux/amd64 and linux/arm64.
+type Command struct {
+	Trace *Attestor
+
+	run       *commandrun.CommandRun
+	blockList map[string]struct{}
+}
+
+func NewCommand(cmd []string) *Command {
+	return &Command{
+		run:       commandrun.New(commandrun.WithCommand(cmd)),
+		blockList: environment.DefaultBlockList(),
+	}
+}
+
+func (c *Command) Name() string {
+	return commandrun.Name
+}
+
+func (c *Command) Type() string {
+	return commandrun.Type
+}
+
+func (c *Command) RunType() attestation.RunType {
+	return commandrun.RunType
+}
+
+// CommandRun returns the command-run attestation of the traced command.
+func (c *Command) CommandRun() *commandrun.CommandRun {
+	return c.run
+}
+
+func (c *Command) MarshalJSON() ([]byte, error) {
+	return json.Marshal(c.run)
+}
+
+func (c *Command) Attest(ctx *attestation.AttestationContext) error {
+	if len(c.run.Cmd) == 0 {
+		return attestation.ErrInvalidOption{
+			Option: "Cmd",
+			Reason: "CommandRun attestation requires a command to run",
+		}
+	}
+
+	cmd := exec.Command(c.run.Cmd[0], c.run.Cmd[1:]...)
+	cmd.Dir = ctx.WorkingDir()
+	stdout, stderr := bytes.Buffer{}, bytes.Buffer{}
+	cmd.Stdout = io.MultiWriter(&stdout, os.Stdout)
+	cmd.Stderr = io.MultiWriter(&stderr, os.Stderr)
+	result, err := trace(cmd, ctx.Hashes(), c.blockList)
+	c.run.Stdout = stdout.String()
+	c.run.Stderr = stderr.String()
+	if err != nil {
+		return fmt.Errorf("failed to trace command: %w", err)
+	}
+
+	c.run.ExitCode = result.exitCode
+	c.run.Processes = result.processes
+	c.Trace = result.trace
+	if result.exitCode != 0 {
+		return fmt.Errorf("exit status %v", result.exitCode)
+	}
+
+	return nil
+}
+
+// result is what tracing a command recorded.
+type result struct {
+	exitCode  int
+	processes []commandrun.ProcessInfo
+	trace     *Attestor
+}
@@ -0,0 +1,464 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux && (amd64 || arm64)
+
+package syscalltrace
+
+import (
+	"crypto"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/testifysec/go-witness/attestation/commandrun"
+	"github.com/testifysec/go-witness/attestation/environment"
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/log"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	maxPathLen     = 4096
+	maxSockaddrLen = 128
+)
+
+// tracer follows the threads of the traced command with ptrace, recording the processes they belong to.
+type tracer struct {
+	hashes    []crypto.Hash
+	blockList map[string]struct{}
+	main      int
+
+	threads     map[int]*thread
+	processes   map[int]*commandrun.ProcessInfo
+	reads       map[string]cryptoutil.DigestSet
+	writes      map[string]struct{}
+	connections map[Connection]struct{}
+	trace       *Attestor
+}
+
+// thread is a traced thread. Syscall stops don't say whether the thread is entering or leaving the system call, so
+// the tracer keeps track, and remembers the arguments of the call until it returns.
+type thread struct {
+	pid       int
+	inSyscall bool
+	syscall   string
+	args      [6]uint64
+	flags     uint64
+	program   string
+}
+
+func trace(cmd *exec.Cmd, hashes []crypto.Hash, blockList map[string]struct{}) (result, error) {
+	t := &tracer{
+		hashes:      hashes,
+		blockList:   blockList,
+		threads:     map[int]*thread{},
+		processes:   map[int]*commandrun.ProcessInfo{},
+		reads:       map[string]cryptoutil.DigestSet{},
+		writes:      map[string]struct{}{},
+		connections: map[Connection]struct{}{},
+		trace:       New(),
+	}
+
+	type done struct {
+		exitCode int
+		err      error
+	}
+
+	// ptrace requests must come from the thread that started the command, so the tracer runs on its own locked
+	// thread. The thread is never unlocked, so the go runtime ends it when the tracer returns, which detaches any
+	// processes the command left running.
+	finished := make(chan done)
+	go func() {
+		runtime.LockOSThread()
+		exitCode, err := t.run(cmd)
+		finished <- done{exitCode, err}
+	}()
+
+	d := <-finished
+	if d.err != nil {
+		return result{}, d.err
+	}
+
+	// the command was reaped by the tracer, so this only waits for its output to be copied
+	if err := cmd.Wait(); err != nil {
+		log.Debugf("(attestation/syscall-trace) waiting for the command's output: %v", err)
+	}
+
+	return t.result(d.exitCode), nil
+}
+
+func (t *tracer) run(cmd *exec.Cmd) (int, error) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Ptrace: true}
+	if err := cmd.Start(); err != nil {
+		return 0, err
+	}
+
+	t.main = cmd.Process.Pid
+	status := unix.WaitStatus(0)
+	if _, err := unix.Wait4(t.main, &status, unix.WALL, nil); err != nil {
+		return 0, err
+	}
+
+	options := unix.PTRACE_O_TRACESYSGOOD | unix.PTRACE_O_TRACEEXEC | unix.PTRACE_O_TRACEFORK | unix.PTRACE_O_TRACEVFORK | unix.PTRACE_O_TRACECLONE
+	if err := unix.PtraceSetOptions(t.main, options); err != nil {
+		return 0, err
+	}
+
+	t.threads[t.main] = &thread{pid: t.main, program: cmd.Path}
+	t.processes[t.main] = &commandrun.ProcessInfo{ProcessID: t.main, ParentPID: os.Getpid()}
+	t.exec(t.main)
+	if err := unix.PtraceSyscall(t.main, 0); err != nil {
+		return 0, err
+	}
+
+	for {
+		tid, err := unix.Wait4(-1, &status, unix.WALL|unix.WNOTHREAD, nil)
+		if err == unix.EINTR {
+			continue
+		} else if err != nil {
+			return 0, err
+		}
+
+		if status.Exited() || status.Signaled() {
+			delete(t.threads, tid)
+			if tid == t.main {
+				return status.ExitStatus(), nil
+			}
+
+			continue
+		}
+
+		if !status.Stopped() {
+			continue
+		}
+
+		th, known := t.threads[tid]
+		if !known {
+			th = t.attach(tid)
+		}
+
+		signal := 0
+		switch sig := status.StopSignal(); {
+		case sig == unix.SIGTRAP|0x80:
+			t.syscall(tid, th)
+		case sig == unix.SIGTRAP && status.TrapCause() == unix.PTRACE_EVENT_EXEC:
+			t.exec(th.pid)
+		case sig == unix.SIGTRAP && status.TrapCause() > 0:
+		case sig == unix.SIGSTOP && !known:
+			// the stop that starts a newly attached thread
+		default:
+			signal = int(sig)
+		}
+
+		if err := unix.PtraceSyscall(tid, signal); err != nil {
+			log.Debugf("(attestation/syscall-trace) failed to resume %v: %v", tid, err)
+		}
+	}
+}
+
+// attach starts following a thread the traced threads created, and the process it belongs to if the thread started
+// a new process. A new process runs its parent's program until it calls exec.
+func (t *tracer) attach(tid int) *thread {
+	pid, ppid := tid, 0
+	if status, err := os.ReadFile(fmt.Sprintf("/proc/%d/status", tid)); err == nil {
+		pid = statusField(status, "Tgid", tid)
+		ppid = statusField(status, "PPid", 0)
+	}
+
+	th := &thread{pid: pid}
+	t.threads[tid] = th
+	if _, ok := t.processes[pid]; ok {
+		return th
+	}
+
+	process := &commandrun.ProcessInfo{ProcessID: pid, ParentPID: ppid}
+	if parent, ok := t.processes[ppid]; ok {
+		process.Program = parent.Program
+		process.Cmdline = parent.Cmdline
+		process.Comm = parent.Comm
+	}
+
+	t.processes[pid] = process
+	return th
+}
+
+// syscall records the system call the thread is entering or leaving.
+func (t *tracer) syscall(tid int, th *thread) {
+	th.inSyscall = !th.inSyscall
+	nr, args, ret, err := registers(tid)
+	if err != nil {
+		log.Debugf("(attestation/syscall-trace) failed to read the registers of %v: %v", tid, err)
+		return
+	}
+
+	if th.inSyscall {
+		th.syscall, th.args = syscalls[nr], args
+		t.enter(tid, th)
+		return
+	}
+
+	if th.syscall == "" || ret < 0 {
+		return
+	}
+
+	switch th.syscall {
+	case "open":
+		t.open(th.pid, tid, int(ret), openedForWrite(th.args[1]))
+	case "openat":
+		t.open(th.pid, tid, int(ret), openedForWrite(th.args[2]))
+	case "openat2":
+		t.open(th.pid, tid, int(ret), openedForWrite(th.flags))
+	case "creat":
+		t.open(th.pid, tid, int(ret), true)
+	}
+}
+
+// enter records the arguments of a system call that are only readable while the thread is in it.
+func (t *tracer) enter(tid int, th *thread) {
+	switch th.syscall {
+	case "execve":
+		th.program = readString(tid, th.args[0])
+	case "execveat":
+		th.program = readString(tid, th.args[1])
+	case "openat2":
+		// the flags are the first field of the open_how struct
+		th.flags = 0
+		if how := readMemory(tid, th.args[2], 8); len(how) == 8 {
+			th.flags = binary.LittleEndian.Uint64(how)
+		}
+	case "connect":
+		t.connect(th.pid, tid, th.syscall, th.args[1], th.args[2])
+	case "sendto":
+		t.connect(th.pid, tid, th.syscall, th.args[4], th.args[5])
+	case "sendmsg":
+		// the address and its length are the first fields of the msghdr struct
+		if msg := readMemory(tid, th.args[1], 12); len(msg) == 12 {
+			t.connect(th.pid, tid, th.syscall, binary.LittleEndian.Uint64(msg), uint64(binary.LittleEndian.Uint32(msg[8:])))
+		}
+	}
+}
+
+// exec records the program a process started running, reading what /proc knows about it.
+func (t *tracer) exec(pid int) {
+	process, ok := t.processes[pid]
+	if !ok {
+		return
+	}
+
+	program := ""
+	if th, ok := t.threads[pid]; ok {
+		program = th.program
+	}
+
+	if program == "" {
+		program, _ = os.Readlink(fmt.Sprintf("/proc/%d/exe", pid))
+	}
+
+	process.Program = program
+	if !filepath.IsAbs(program) {
+		program = filepath.Join(fmt.Sprintf("/proc/%d/cwd", pid), program)
+	}
+
+	if digest, err := cryptoutil.CalculateDigestSetFromFile(program, t.hashes); err == nil {
+		process.ProgramDigest = digest
+	}
+
+	if digest, err := cryptoutil.CalculateDigestSetFromFile(fmt.Sprintf("/proc/%d/exe", pid), t.hashes); err == nil {
+		process.ExeDigest = digest
+	}
+
+	if comm, err := os.ReadFile(fmt.Sprintf("/proc/%d/comm", pid)); err == nil {
+		process.Comm = cleanString(comm)
+	}
+
+	if cmdline, err := os.ReadFile(fmt.Sprintf("/proc/%d/cmdline", pid)); err == nil {
+		process.Cmdline = cleanString(cmdline)
+	}
+
+	if environ, err := os.ReadFile(fmt.Sprintf("/proc/%d/environ", pid)); err == nil {
+		filtered := make([]string, 0)
+		environment.FilterEnvironmentArray(strings.Split(string(environ), "\x00"), t.blockList, func(_, _, variable string) {
+			filtered = append(filtered, variable)
+		})
+
+		process.Environ = strings.Join(filtered, " ")
+	}
+
+	if status, err := os.ReadFile(fmt.Sprintf("/proc/%d/status", pid)); err == nil {
+		process.SpecBypassIsVuln = strings.Contains(statusLine(status, "Speculation_Store_Bypass"), "vulnerable")
+	}
+}
+
+// open records the file a process opened as fd. Only regular files outside of /proc and /sys are recorded. Files
+// opened for reading are digested when they are first opened, files opened for writing once the command exits.
+func (t *tracer) open(pid, tid, fd int, write bool) {
+	path, err := os.Readlink(fmt.Sprintf("/proc/%d/fd/%d", tid, fd))
+	if err != nil || !filepath.IsAbs(path) || strings.HasPrefix(path, "/proc/") || strings.HasPrefix(path, "/sys/") {
+		return
+	}
+
+	if info, err := os.Stat(path); err != nil || !info.Mode().IsRegular() {
+		return
+	}
+
+	if write {
+		t.writes[path] = struct{}{}
+		return
+	}
+
+	digest, ok := t.reads[path]
+	if !ok {
+		digest, err = cryptoutil.CalculateDigestSetFromFile(path, t.hashes)
+		if err != nil {
+			log.Debugf("(attestation/syscall-trace) failed to digest %v: %v", path, err)
+		}
+
+		t.reads[path] = digest
+	}
+
+	if process, ok := t.processes[pid]; ok {
+		if process.OpenedFiles == nil {
+			process.OpenedFiles = map[string]cryptoutil.DigestSet{}
+		}
+
+		process.OpenedFiles[path] = digest
+	}
+}
+
+// connect records the address a process connected or sent to.
+func (t *tracer) connect(pid, tid int, syscall string, addr, length uint64) {
+	if addr == 0 || length == 0 {
+		return
+	}
+
+	if length > maxSockaddrLen {
+		length = maxSockaddrLen
+	}
+
+	family, address, port, ok := parseSockaddr(readMemory(tid, addr, int(length)))
+	if !ok {
+		return
+	}
+
+	connection := Connection{ProcessID: pid, Syscall: syscall, Family: family, Address: address, Port: port}
+	if _, ok := t.connections[connection]; ok {
+		return
+	}
+
+	t.connections[connection] = struct{}{}
+	t.trace.Connections = append(t.trace.Connections, connection)
+	t.trace.Egress = t.trace.Egress || isEgress(connection)
+}
+
+func (t *tracer) result(exitCode int) result {
+	pids := make([]int, 0, len(t.processes))
+	for pid := range t.processes {
+		pids = append(pids, pid)
+	}
+
+	sort.Ints(pids)
+	processes := make([]commandrun.ProcessInfo, 0, len(pids))
+	for _, pid := range pids {
+		process := t.processes[pid]
+		processes = append(processes, *process)
+		t.trace.Processes = append(t.trace.Processes, Process{
+			ProcessID: process.ProcessID,
+			ParentPID: process.ParentPID,
+			Program:   process.Program,
+			Cmdline:   process.Cmdline,
+		})
+	}
+
+	t.trace.Reads = t.reads
+	for path := range t.writes {
+		digest, err := cryptoutil.CalculateDigestSetFromFile(path, t.hashes)
+		if err != nil {
+			digest = cryptoutil.DigestSet{}
+		}
+
+		t.trace.Writes[path] = digest
+	}
+
+	return result{exitCode: exitCode, processes: processes, trace: t.trace}
+}
+
+// readMemory reads n bytes at addr from the memory of a traced thread, returning fewer if not all of them could be read.
+func readMemory(tid int, addr uint64, n int) []byte {
+	if addr == 0 {
+		return nil
+	}
+
+	data := make([]byte, n)
+	read, err := unix.PtracePeekData(tid, uintptr(addr), data)
+	if err != nil && read == 0 {
+		return nil
+	}
+
+	return data[:read]
+}
+
+// readString reads the null terminated string at addr from the memory of a traced thread.
+func readString(tid int, addr uint64) string {
+	if addr == 0 {
+		return ""
+	}
+
+	data := make([]byte, maxPathLen)
+	local := []unix.Iovec{{Base: &data[0]}}
+	local[0].SetLen(len(data))
+	remote := []unix.RemoteIovec{{Base: uintptr(addr), Len: len(data)}}
+	n, err := unix.ProcessVMReadv(tid, local, remote, 0)
+	if err != nil {
+		// process_vm_readv fails if the string ends near the end of a mapping, so fall back to reading it word by word
+		n, _ = unix.PtracePeekData(tid, uintptr(addr), data[:256])
+	}
+
+	s := string(data[:n])
+	if i := strings.IndexByte(s, 0); i >= 0 {
+		s = s[:i]
+	}
+
+	return s
+}
+
+func statusLine(status []byte, field string) string {
+	for _, line := range strings.Split(string(status), "\n") {
+		if strings.HasPrefix(line, field+":") {
+			return strings.TrimSpace(strings.TrimPrefix(line, field+":"))
+		}
+	}
+
+	return ""
+}
+
+func statusField(status []byte, field string, fallback int) int {
+	value, err := strconv.Atoi(statusLine(status, field))
+	if err != nil {
+		return fallback
+	}
+
+	return value
+}
+
+func cleanString(b []byte) string {
+	return strings.TrimSpace(strings.ReplaceAll(string(b), "\x00", " "))
+}
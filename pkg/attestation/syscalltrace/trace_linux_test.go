@@ -0,0 +1,139 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux && (amd64 || arm64)
+
+package syscalltrace
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/testifysec/go-witness/attestation"
+	"github.com/testifysec/go-witness/attestation/commandrun"
+)
+
+const (
+	helperEnv = "WITNESS_SYSCALLTRACE_HELPER"
+	dialEnv   = "WITNESS_SYSCALLTRACE_DIAL"
+)
+
+// TestHelperProcess is the command traced by TestCommand. It reads input, writes output, and connects to the test's
+// listener.
+func TestHelperProcess(t *testing.T) {
+	dir := os.Getenv(helperEnv)
+	if dir == "" {
+		return
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "input"))
+	if err != nil {
+		os.Exit(2)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "output"), append(data, "!"...), 0644); err != nil {
+		os.Exit(3)
+	}
+
+	conn, err := net.Dial("tcp", os.Getenv(dialEnv))
+	if err != nil {
+		os.Exit(4)
+	}
+
+	conn.Close()
+	os.Exit(0)
+}
+
+func TestCommand(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "input"), []byte("hello"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer listener.Close()
+	t.Setenv(helperEnv, dir)
+	t.Setenv(dialEnv, listener.Addr().String())
+
+	ctx, err := attestation.NewContext([]attestation.Attestor{}, attestation.WithWorkingDir(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := NewCommand([]string{os.Args[0], "-test.run=TestHelperProcess"})
+	if err := c.Attest(ctx); err != nil {
+		if strings.Contains(err.Error(), "operation not permitted") {
+			t.Skipf("ptrace is not permitted: %v", err)
+		}
+
+		t.Fatal(err)
+	}
+
+	trace := c.Trace
+	input, output := filepath.Join(dir, "input"), filepath.Join(dir, "output")
+	if _, ok := trace.Reads[input]; !ok {
+		t.Errorf("expected %v to be read, got %v", input, trace.Reads)
+	}
+
+	if len(trace.Writes[output]) == 0 {
+		t.Errorf("expected a digest of %v, got %v", output, trace.Writes)
+	}
+
+	addr := listener.Addr().(*net.TCPAddr)
+	found := false
+	for _, connection := range trace.Connections {
+		found = found || (connection.Syscall == "connect" && connection.Family == "inet" && connection.Address == "127.0.0.1" && connection.Port == addr.Port)
+	}
+
+	if !found {
+		t.Errorf("expected a connection to %v, got %v", addr, trace.Connections)
+	}
+
+	if trace.Egress {
+		t.Error("expected no egress from a loopback connection")
+	}
+
+	if len(trace.Processes) == 0 || trace.Processes[0].ParentPID != os.Getpid() {
+		t.Errorf("expected the command's process to be a child of the test, got %v", trace.Processes)
+	}
+
+	cr, ok := interface{}(c.CommandRun()).(*commandrun.CommandRun)
+	if !ok || cr.ExitCode != 0 || len(cr.Processes) != len(trace.Processes) {
+		t.Errorf("expected the command-run attestation to record the traced processes, got %+v", cr)
+	}
+}
+
+func TestCommandExitCode(t *testing.T) {
+	ctx, err := attestation.NewContext([]attestation.Attestor{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := NewCommand([]string{"sh", "-c", "exit 3"})
+	err = c.Attest(ctx)
+	if err != nil && strings.Contains(err.Error(), "operation not permitted") {
+		t.Skipf("ptrace is not permitted: %v", err)
+	}
+
+	if err == nil || c.CommandRun().ExitCode != 3 {
+		t.Fatalf("expected exit status 3, got %v %v", err, c.CommandRun().ExitCode)
+	}
+}
@@ -0,0 +1,29 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !linux || !(amd64 || arm64)
+
+package syscalltrace
+
+import (
+	"crypto"
+	"fmt"
+	"os/exec"
+	"runtime"
+)
+
+// trace is only implemented on linux/amd64 and linux/arm64.
+func trace(cmd *exec.Cmd, hashes []crypto.Hash, blockList map[string]struct{}) (result, error) {
+	return result{}, fmt.Errorf("syscall tracing is not supported on %v/%v", runtime.GOOS, runtime.GOARCH)
+}
@@ -0,0 +1,241 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package upload
+
+import (
+	"context"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"hash/crc32"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	md5Pattern          = regexp.MustCompile(`^[0-9a-f]{32}$`)
+	contentRangePattern = regexp.MustCompile(`^bytes \d+-\d+/(\d+)$`)
+
+	// amzChecksumHeaders are the checksums S3 returns for objects uploaded with additional checksums.
+	amzChecksumHeaders = map[string]string{
+		"X-Amz-Checksum-Sha256": "sha256",
+		"X-Amz-Checksum-Sha1":   "sha1",
+		"X-Amz-Checksum-Crc32c": "crc32c",
+		"X-Amz-Checksum-Crc32":  "crc32",
+	}
+)
+
+// object is what a store reports about an object.
+type object struct {
+	provider  string
+	size      int64
+	etag      string
+	checksums map[string]string
+}
+
+// comparable returns true if the store reported a checksum of the object's content that can be checked locally.
+func (o object) comparable() bool {
+	return len(o.checksums) > 0
+}
+
+// fetchObject reads the object's headers with a HEAD request. Pre-signed urls are only valid for the method they were
+// signed for, so if the store refuses the HEAD request the first byte of the object is requested instead.
+func fetchObject(ctx context.Context, rawURL string) (object, error) {
+	resp, err := request(ctx, http.MethodHead, rawURL, "")
+	if err != nil {
+		return object{}, err
+	}
+
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusMethodNotAllowed || resp.StatusCode == http.StatusNotImplemented {
+		resp, err = request(ctx, http.MethodGet, rawURL, "bytes=0-0")
+		if err != nil {
+			return object{}, err
+		}
+
+		_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 1024))
+		resp.Body.Close()
+	}
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
+		return object{}, fmt.Errorf("object store returned %v", resp.Status)
+	}
+
+	return parseObject(resp), nil
+}
+
+func request(ctx context.Context, method, rawURL, byteRange string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, rawURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if byteRange != "" {
+		req.Header.Set("Range", byteRange)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reach object store: %v", redactError(err, rawURL))
+	}
+
+	return resp, nil
+}
+
+// parseObject reads the size, ETag, and checksums of an object from the store's response headers.
+func parseObject(resp *http.Response) object {
+	obj := object{
+		provider:  provider(resp),
+		size:      resp.ContentLength,
+		etag:      strings.Trim(strings.TrimPrefix(resp.Header.Get("ETag"), "W/"), `"`),
+		checksums: make(map[string]string),
+	}
+
+	if resp.StatusCode == http.StatusPartialContent {
+		obj.size = -1
+		if m := contentRangePattern.FindStringSubmatch(resp.Header.Get("Content-Range")); m != nil {
+			if size, err := strconv.ParseInt(m[1], 10, 64); err == nil {
+				obj.size = size
+			}
+		}
+	}
+
+	// gcs sends crc32c=<base64>,md5=<base64>, in one header or several
+	for _, value := range resp.Header.Values("X-Goog-Hash") {
+		for _, part := range strings.Split(value, ",") {
+			kv := strings.SplitN(strings.TrimSpace(part), "=", 2)
+			if len(kv) == 2 {
+				setBase64(obj.checksums, kv[0], kv[1])
+			}
+		}
+	}
+
+	for header, algorithm := range amzChecksumHeaders {
+		if value := resp.Header.Get(header); value != "" {
+			setBase64(obj.checksums, algorithm, value)
+		}
+	}
+
+	if value := resp.Header.Get("Content-MD5"); value != "" {
+		setBase64(obj.checksums, "md5", value)
+	}
+
+	// the ETag of an S3 object is the md5 of its content unless it was uploaded in parts or encrypted with KMS
+	if _, ok := obj.checksums["md5"]; !ok && obj.provider == "s3" && md5Pattern.MatchString(obj.etag) &&
+		!strings.HasPrefix(resp.Header.Get("X-Amz-Server-Side-Encryption"), "aws:kms") {
+		obj.checksums["md5"] = obj.etag
+	}
+
+	return obj
+}
+
+func provider(resp *http.Response) string {
+	host := resp.Request.URL.Hostname()
+	switch {
+	case resp.Header.Get("X-Amz-Request-Id") != "" || strings.HasSuffix(host, ".amazonaws.com"):
+		return "s3"
+	case resp.Header.Get("X-Goog-Generation") != "" || resp.Header.Get("X-Goog-Hash") != "" || host == "storage.googleapis.com":
+		return "gcs"
+	default:
+		return "http"
+	}
+}
+
+func setBase64(checksums map[string]string, algorithm, value string) {
+	decoded, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return
+	}
+
+	checksums[algorithm] = hex.EncodeToString(decoded)
+}
+
+// downloadSHA256 downloads the object and returns the hex encoded sha256 digest of its content.
+func downloadSHA256(ctx context.Context, rawURL string) (string, error) {
+	resp, err := request(ctx, http.MethodGet, rawURL, "")
+	if err != nil {
+		return "", err
+	}
+
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("object store returned %v", resp.Status)
+	}
+
+	h := sha256.New()
+	if _, err := io.Copy(h, resp.Body); err != nil {
+		return "", fmt.Errorf("failed to download object: %v", redactError(err, rawURL))
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// redactError removes the pre-signed query from the urls that http errors include.
+func redactError(err error, rawURL string) string {
+	msg := err.Error()
+	if u, parseErr := url.Parse(rawURL); parseErr == nil && u.RawQuery != "" {
+		msg = strings.ReplaceAll(msg, "?"+u.RawQuery, "")
+	}
+
+	return msg
+}
+
+type checksums struct {
+	size int64
+	sums map[string]string
+}
+
+// localChecksums hashes the file with each algorithm object stores report.
+func localChecksums(path string) (checksums, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return checksums{}, err
+	}
+
+	defer f.Close()
+	hashes := map[string]hash.Hash{
+		"md5":    md5.New(),
+		"sha1":   sha1.New(),
+		"sha256": sha256.New(),
+		"crc32c": crc32.New(crc32.MakeTable(crc32.Castagnoli)),
+		"crc32":  crc32.NewIEEE(),
+	}
+
+	writers := make([]io.Writer, 0, len(hashes))
+	for _, h := range hashes {
+		writers = append(writers, h)
+	}
+
+	size, err := io.Copy(io.MultiWriter(writers...), f)
+	if err != nil {
+		return checksums{}, fmt.Errorf("failed to hash file: %w", err)
+	}
+
+	local := checksums{size: size, sums: make(map[string]string)}
+	for algorithm, h := range hashes {
+		local.sums[algorithm] = hex.EncodeToString(h.Sum(nil))
+	}
+
+	return local, nil
+}
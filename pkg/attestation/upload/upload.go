@@ -0,0 +1,234 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package upload
+
+import (
+	"crypto"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/testifysec/go-witness/attestation"
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/log"
+)
+
+const (
+	Name    = "upload"
+	Type    = "https://witness.dev/attestations/upload/v0.1"
+	RunType = attestation.PostRunType
+
+	// UploadsEnv lists the files the step uploaded, as whitespace separated <path>=<pre-signed url> pairs. Paths are
+	// relative to the working directory.
+	UploadsEnv = "WITNESS_UPLOADS"
+)
+
+func init() {
+	attestation.RegisterAttestation(Name, Type, RunType, func() attestation.Attestor {
+		return New()
+	})
+}
+
+// Attestor records the checksums an object store reports for files the step uploaded through pre-signed URLs, and
+// checks them against the local files. A collection with this attestation shows that the objects in the store are the
+// files that were attested locally.
+type Attestor struct {
+	Uploads []Upload `json:"uploads"`
+}
+
+// Upload is a file uploaded to an object store.
+type Upload struct {
+	Path   string               `json:"path"`
+	Digest cryptoutil.DigestSet `json:"digest"`
+	// URL is the object's address without the pre-signed query, which holds credentials.
+	URL      string `json:"url"`
+	Provider string `json:"provider"`
+	Size     int64  `json:"size"`
+	ETag     string `json:"etag,omitempty"`
+	// Checksums are the hex encoded checksums the store reported for the object, by algorithm.
+	Checksums map[string]string `json:"checksums"`
+	// Matched are the algorithms whose checksums matched the local file.
+	Matched []string `json:"matched"`
+	// Downloaded is set when the store reported no checksum that could be compared, so the object was downloaded and
+	// hashed instead.
+	Downloaded bool `json:"downloaded,omitempty"`
+}
+
+func New() *Attestor {
+	return &Attestor{}
+}
+
+func (a *Attestor) Name() string {
+	return Name
+}
+
+func (a *Attestor) Type() string {
+	return Type
+}
+
+func (a *Attestor) RunType() attestation.RunType {
+	return RunType
+}
+
+func (a *Attestor) Attest(ctx *attestation.AttestationContext) error {
+	uploads, err := parseUploads(os.Getenv(UploadsEnv))
+	if err != nil {
+		return err
+	}
+
+	if len(uploads) == 0 {
+		return fmt.Errorf("%v must list the uploaded files to use the upload attestor", UploadsEnv)
+	}
+
+	for _, u := range uploads {
+		path := u.path
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(ctx.WorkingDir(), path)
+		}
+
+		log.Debugf("(attestation/upload) checking upload of %v", u.path)
+		upload, err := check(ctx, path, u.url)
+		if err != nil {
+			return fmt.Errorf("failed to check upload of %v: %w", u.path, err)
+		}
+
+		upload.Path = u.path
+		a.Uploads = append(a.Uploads, upload)
+	}
+
+	return nil
+}
+
+func (a *Attestor) Subjects() map[string]cryptoutil.DigestSet {
+	subjects := make(map[string]cryptoutil.DigestSet)
+	for _, upload := range a.Uploads {
+		subjects[fmt.Sprintf("upload:%v", upload.URL)] = upload.Digest
+	}
+
+	return subjects
+}
+
+type uploadSpec struct {
+	path string
+	url  string
+}
+
+// parseUploads parses the <path>=<url> pairs of UploadsEnv.
+func parseUploads(value string) ([]uploadSpec, error) {
+	uploads := make([]uploadSpec, 0)
+	for _, pair := range strings.Fields(value) {
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 || parts[0] == "" {
+			return nil, fmt.Errorf("%v entries must be <path>=<url>", UploadsEnv)
+		}
+
+		u, err := url.Parse(parts[1])
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return nil, fmt.Errorf("upload url of %v must be an http(s) url", parts[0])
+		}
+
+		uploads = append(uploads, uploadSpec{path: parts[0], url: parts[1]})
+	}
+
+	return uploads, nil
+}
+
+// check compares the object at the pre-signed url with the local file.
+func check(ctx *attestation.AttestationContext, path, rawURL string) (Upload, error) {
+	digest, err := cryptoutil.CalculateDigestSetFromFile(path, hashes(ctx))
+	if err != nil {
+		return Upload{}, fmt.Errorf("failed to hash file: %w", err)
+	}
+
+	local, err := localChecksums(path)
+	if err != nil {
+		return Upload{}, err
+	}
+
+	obj, err := fetchObject(ctx.Context(), rawURL)
+	if err != nil {
+		return Upload{}, err
+	}
+
+	upload := Upload{
+		Digest:    digest,
+		URL:       redact(rawURL),
+		Provider:  obj.provider,
+		Size:      obj.size,
+		ETag:      obj.etag,
+		Checksums: obj.checksums,
+		Matched:   make([]string, 0),
+	}
+
+	if obj.size >= 0 && obj.size != local.size {
+		return Upload{}, fmt.Errorf("object is %v bytes, but the file is %v bytes", obj.size, local.size)
+	}
+
+	if !obj.comparable() {
+		log.Debugf("(attestation/upload) %v reported no comparable checksum, downloading the object", upload.URL)
+		sum, err := downloadSHA256(ctx.Context(), rawURL)
+		if err != nil {
+			return Upload{}, err
+		}
+
+		upload.Checksums["sha256"] = sum
+		upload.Downloaded = true
+	}
+
+	algorithms := make([]string, 0, len(upload.Checksums))
+	for algorithm := range upload.Checksums {
+		algorithms = append(algorithms, algorithm)
+	}
+
+	sort.Strings(algorithms)
+	for _, algorithm := range algorithms {
+		expected, ok := local.sums[algorithm]
+		if !ok {
+			continue
+		}
+
+		if upload.Checksums[algorithm] != expected {
+			return Upload{}, fmt.Errorf("object's %v checksum is %v, but the file's is %v", algorithm, upload.Checksums[algorithm], expected)
+		}
+
+		upload.Matched = append(upload.Matched, algorithm)
+	}
+
+	return upload, nil
+}
+
+func hashes(ctx *attestation.AttestationContext) []crypto.Hash {
+	if hashes := ctx.Hashes(); len(hashes) > 0 {
+		return hashes
+	}
+
+	return []crypto.Hash{crypto.SHA256}
+}
+
+// redact removes the query and credentials from a url, since a pre-signed query grants access to the object.
+func redact(rawURL string) string {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return ""
+	}
+
+	u.User = nil
+	u.RawQuery = ""
+	u.Fragment = ""
+	return u.String()
+}
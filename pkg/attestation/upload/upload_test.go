@@ -0,0 +1,182 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package upload
+
+import (
+	"crypto"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash/crc32"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/testifysec/go-witness/attestation"
+)
+
+var content = []byte("release binary")
+
+// store serves content like an object store, with headers set by the test.
+type store struct {
+	content  []byte
+	headers  map[string]string
+	denyHead bool
+}
+
+func (s store) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Query().Get("X-Amz-Signature") != "secret" {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	if r.Method == http.MethodHead && s.denyHead {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	for k, v := range s.headers {
+		w.Header().Set(k, v)
+	}
+
+	if r.Header.Get("Range") == "bytes=0-0" {
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes 0-0/%v", len(s.content)))
+		w.WriteHeader(http.StatusPartialContent)
+		_, _ = w.Write(s.content[:1])
+		return
+	}
+
+	w.Header().Set("Content-Length", fmt.Sprint(len(s.content)))
+	if r.Method == http.MethodGet {
+		_, _ = w.Write(s.content)
+	}
+}
+
+func attest(t *testing.T, s store) (*Attestor, error) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "app"), content, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(s)
+	t.Cleanup(server.Close)
+	t.Setenv(UploadsEnv, fmt.Sprintf("app=%v/bucket/app?X-Amz-Signature=secret", server.URL))
+
+	a := New()
+	ctx, err := attestation.NewContext([]attestation.Attestor{a}, attestation.WithWorkingDir(dir))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return a, a.Attest(ctx)
+}
+
+func TestS3Upload(t *testing.T) {
+	sum := md5.Sum(content)
+	a, err := attest(t, store{content: content, denyHead: true, headers: map[string]string{
+		"ETag":             fmt.Sprintf(`"%x"`, sum),
+		"X-Amz-Request-Id": "req",
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(a.Uploads) != 1 {
+		t.Fatalf("expected 1 upload, got %v", a.Uploads)
+	}
+
+	upload := a.Uploads[0]
+	if upload.Provider != "s3" || upload.Size != int64(len(content)) || upload.Downloaded {
+		t.Errorf("unexpected upload: %+v", upload)
+	}
+
+	if len(upload.Matched) != 1 || upload.Matched[0] != "md5" {
+		t.Errorf("expected the etag to match as md5, got %v", upload.Matched)
+	}
+
+	if strings.Contains(upload.URL, "secret") || !strings.HasSuffix(upload.URL, "/bucket/app") {
+		t.Errorf("expected the pre-signed query to be removed, got %v", upload.URL)
+	}
+
+	digest := sha256.Sum256(content)
+	if subject := a.Subjects()["upload:"+upload.URL]; subject[crypto.SHA256] != hex.EncodeToString(digest[:]) {
+		t.Errorf("expected a subject with the file's digest, got %v", a.Subjects())
+	}
+}
+
+func TestGCSUpload(t *testing.T) {
+	sum := md5.Sum(content)
+	crc := crc32.New(crc32.MakeTable(crc32.Castagnoli))
+	crc.Write(content)
+	a, err := attest(t, store{content: content, headers: map[string]string{
+		"X-Goog-Hash": fmt.Sprintf("crc32c=%v,md5=%v", base64.StdEncoding.EncodeToString(crc.Sum(nil)), base64.StdEncoding.EncodeToString(sum[:])),
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if upload := a.Uploads[0]; upload.Provider != "gcs" || strings.Join(upload.Matched, ",") != "crc32c,md5" {
+		t.Errorf("unexpected upload: %+v", upload)
+	}
+}
+
+func TestUploadWithoutChecksums(t *testing.T) {
+	a, err := attest(t, store{content: content, headers: map[string]string{"ETag": `"abc-2"`}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if upload := a.Uploads[0]; !upload.Downloaded || strings.Join(upload.Matched, ",") != "sha256" {
+		t.Errorf("expected the object to be downloaded and hashed, got %+v", upload)
+	}
+}
+
+func TestUploadMismatch(t *testing.T) {
+	sum := md5.Sum([]byte("other binary!!"))
+	_, err := attest(t, store{content: content, headers: map[string]string{
+		"ETag":             fmt.Sprintf(`"%x"`, sum),
+		"X-Amz-Request-Id": "req",
+	}})
+	if err == nil || !strings.Contains(err.Error(), "md5") {
+		t.Errorf("expected a checksum mismatch, got %v", err)
+	}
+
+	_, err = attest(t, store{content: []byte("short")})
+	if err == nil || !strings.Contains(err.Error(), "bytes") {
+		t.Errorf("expected a size mismatch, got %v", err)
+	}
+}
+
+func TestParseUploads(t *testing.T) {
+	uploads, err := parseUploads("dist/app=https://bucket.s3.amazonaws.com/app?X-Amz-Signature=a=b\n  sbom.json=https://storage.googleapis.com/b/sbom.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(uploads) != 2 || uploads[0].path != "dist/app" || !strings.HasSuffix(uploads[0].url, "Signature=a=b") {
+		t.Errorf("unexpected uploads: %+v", uploads)
+	}
+
+	for _, bad := range []string{"app", "=https://example.com", "app=ftp://example.com/app"} {
+		if _, err := parseUploads(bad); err == nil {
+			t.Errorf("expected %q to fail", bad)
+		}
+	}
+}
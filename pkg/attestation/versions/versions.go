@@ -0,0 +1,89 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package versions
+
+import (
+	"sort"
+
+	"github.com/testifysec/go-witness/attestation"
+	"github.com/testifysec/witness/pkg/version"
+)
+
+const (
+	Name    = "versions"
+	Type    = "https://witness.dev/attestations/versions/v0.1"
+	RunType = attestation.PostRunType
+)
+
+func init() {
+	attestation.RegisterAttestation(Name, Type, RunType, func() attestation.Attestor {
+		return New()
+	})
+}
+
+// Attestor records the version of witness that signed a collection and the schema version of each of its
+// attestations, so a policy can require a minimum version and reject collections recorded by old clients. It is not
+// run; Collection adds it to every collection witness signs.
+type Attestor struct {
+	Witness   string            `json:"witness"`
+	Attestors []AttestorVersion `json:"attestors"`
+}
+
+// AttestorVersion is the schema version of an attestation in the collection, taken from the end of its type.
+type AttestorVersion struct {
+	Name    string `json:"name"`
+	Type    string `json:"type"`
+	Version string `json:"version,omitempty"`
+}
+
+func New() *Attestor {
+	return &Attestor{Attestors: []AttestorVersion{}}
+}
+
+func (a *Attestor) Name() string {
+	return Name
+}
+
+func (a *Attestor) Type() string {
+	return Type
+}
+
+func (a *Attestor) RunType() attestation.RunType {
+	return RunType
+}
+
+func (a *Attestor) Attest(ctx *attestation.AttestationContext) error {
+	return nil
+}
+
+// Collection returns a copy of the collection with a versions attestation recording witnessVersion and the schema
+// versions of the collection's attestations appended, in place of any versions attestation it already has.
+func Collection(collection attestation.Collection, witnessVersion string) attestation.Collection {
+	record := New()
+	record.Witness = witnessVersion
+	attestors := make([]attestation.Attestor, 0, len(collection.Attestations)+1)
+	for _, ca := range collection.Attestations {
+		if ca.Type == Type {
+			continue
+		}
+
+		attestors = append(attestors, ca.Attestation)
+		_, schema, _ := version.SplitType(ca.Type)
+		record.Attestors = append(record.Attestors, AttestorVersion{Name: ca.Attestation.Name(), Type: ca.Type, Version: schema})
+	}
+
+	sort.Slice(record.Attestors, func(i, j int) bool { return record.Attestors[i].Type < record.Attestors[j].Type })
+	return attestation.NewCollection(collection.Name, append(attestors, record))
+}
@@ -0,0 +1,60 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package versions
+
+import (
+	"testing"
+
+	"github.com/testifysec/go-witness/attestation"
+	"github.com/testifysec/go-witness/attestation/environment"
+	"github.com/testifysec/witness/pkg/attestation/labels"
+)
+
+func TestCollection(t *testing.T) {
+	collection := attestation.NewCollection("build", []attestation.Attestor{environment.New(), labels.New()})
+	recorded := Collection(collection, "v0.1.12-4f3c2a1")
+	if len(recorded.Attestations) != 3 {
+		t.Fatalf("expected versions attestation to be appended: %+v", recorded)
+	}
+
+	record, ok := recorded.Attestations[2].Attestation.(*Attestor)
+	if !ok || record.Witness != "v0.1.12-4f3c2a1" {
+		t.Fatalf("unexpected versions attestation: %+v", recorded.Attestations[2])
+	}
+
+	expected := []AttestorVersion{
+		{Name: "environment", Type: "https://witness.dev/attestations/environment/v0.1", Version: "v0.1"},
+		{Name: labels.Name, Type: labels.Type, Version: "v0.1"},
+	}
+
+	if len(record.Attestors) != len(expected) {
+		t.Fatalf("expected %v, got %v", expected, record.Attestors)
+	}
+
+	for i := range expected {
+		if record.Attestors[i] != expected[i] {
+			t.Errorf("expected %v, got %v", expected[i], record.Attestors[i])
+		}
+	}
+
+	rerecorded := Collection(recorded, "v0.1.13")
+	if len(rerecorded.Attestations) != 3 {
+		t.Fatalf("expected the versions attestation to be replaced: %+v", rerecorded)
+	}
+
+	if record := rerecorded.Attestations[2].Attestation.(*Attestor); record.Witness != "v0.1.13" || len(record.Attestors) != 2 {
+		t.Errorf("unexpected versions attestation: %+v", record)
+	}
+}
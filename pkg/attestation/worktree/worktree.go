@@ -0,0 +1,157 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package worktree
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"sort"
+	"strings"
+
+	"github.com/testifysec/go-witness/attestation"
+)
+
+const (
+	Name    = "worktree"
+	Type    = "https://witness.dev/attestations/worktree/v0.1"
+	RunType = attestation.PreRunType
+
+	// ModeAllow ignores a dirty worktree.
+	ModeAllow = "allow"
+	// ModeRecord adds an attestation listing the changed files to the collection.
+	ModeRecord = "record"
+	// ModeFail fails the step before its command runs.
+	ModeFail = "fail"
+
+	// maxReportedFiles limits how many changed files are named in errors.
+	maxReportedFiles = 5
+)
+
+func init() {
+	attestation.RegisterAttestation(Name, Type, RunType, func() attestation.Attestor {
+		return New()
+	})
+}
+
+// Attestor records that the git worktree had uncommitted changes or untracked files before the step ran, so the
+// commit named by the git attestation is known not to be the tree that was built. It is not run; Collection adds it
+// to the collections of steps run with a dirty worktree.
+type Attestor struct {
+	CommitHash string   `json:"commithash"`
+	Files      []string `json:"files"`
+}
+
+func New() *Attestor {
+	return &Attestor{Files: []string{}}
+}
+
+func (a *Attestor) Name() string {
+	return Name
+}
+
+func (a *Attestor) Type() string {
+	return Type
+}
+
+func (a *Attestor) RunType() attestation.RunType {
+	return RunType
+}
+
+func (a *Attestor) Attest(ctx *attestation.AttestationContext) error {
+	return nil
+}
+
+// Dirty returns true if any file was changed, staged, or untracked.
+func (a *Attestor) Dirty() bool {
+	return len(a.Files) > 0
+}
+
+// Summary names the changed files, eliding all but the first few.
+func (a *Attestor) Summary() string {
+	if len(a.Files) <= maxReportedFiles {
+		return strings.Join(a.Files, ", ")
+	}
+
+	return fmt.Sprintf("%v, and %d more", strings.Join(a.Files[:maxReportedFiles], ", "), len(a.Files)-maxReportedFiles)
+}
+
+// ValidateMode returns an error if mode is not allow, record, or fail.
+func ValidateMode(mode string) error {
+	switch mode {
+	case ModeAllow, ModeRecord, ModeFail:
+		return nil
+	default:
+		return fmt.Errorf("unknown dirty worktree mode %v, expected %v, %v, or %v", mode, ModeAllow, ModeRecord, ModeFail)
+	}
+}
+
+// gitOutput runs git in dir and returns its standard output.
+func gitOutput(dir string, args ...string) ([]byte, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to run git %v: %w", args[0], err)
+	}
+
+	return out, nil
+}
+
+// Check returns the state of the git worktree containing dir. Ignored files are not reported.
+func Check(dir string) (*Attestor, error) {
+	if dir == "" {
+		dir = "."
+	}
+
+	head, err := gitOutput(dir, "rev-parse", "HEAD")
+	if err != nil {
+		return nil, err
+	}
+
+	status, err := gitOutput(dir, "status", "--porcelain", "-z", "--untracked-files=all")
+	if err != nil {
+		return nil, err
+	}
+
+	a := New()
+	a.CommitHash = strings.TrimSpace(string(head))
+	entries := bytes.Split(status, []byte{0})
+	for i := 0; i < len(entries); i++ {
+		entry := string(entries[i])
+		if len(entry) < 4 {
+			continue
+		}
+
+		a.Files = append(a.Files, entry[3:])
+		// renames and copies are followed by the path they were renamed or copied from
+		if entry[0] == 'R' || entry[0] == 'C' {
+			i++
+		}
+	}
+
+	sort.Strings(a.Files)
+	return a, nil
+}
+
+// Collection appends the attestor to the collection if the worktree was dirty.
+func Collection(collection attestation.Collection, a *Attestor) attestation.Collection {
+	if a == nil || !a.Dirty() {
+		return collection
+	}
+
+	collection.Attestations = append(collection.Attestations, attestation.NewCollectionAttestation(a))
+	return collection
+}
@@ -0,0 +1,124 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package worktree
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/testifysec/go-witness/attestation"
+)
+
+func testRepo(t *testing.T) string {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, ".gitignore"), []byte("*.log\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"add", "."},
+		{"-c", "user.name=witness", "-c", "user.email=witness@example.com", "commit", "-q", "-m", "initial commit"},
+	} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = dir
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v: %s", args[0], err, out)
+		}
+	}
+
+	return dir
+}
+
+func TestCheck(t *testing.T) {
+	dir := testRepo(t)
+	clean, err := Check(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if clean.Dirty() || len(clean.CommitHash) != 40 {
+		t.Fatalf("expected a clean worktree at a commit, got %+v", clean)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n\nfunc main() {}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.MkdirAll(filepath.Join(dir, "docs"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, file := range []string{"docs/notes.txt", "build.log"} {
+		if err := os.WriteFile(filepath.Join(dir, file), []byte("untracked"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dirty, err := Check(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(dirty.Files, []string{"docs/notes.txt", "main.go"}) || dirty.CommitHash != clean.CommitHash {
+		t.Fatalf("expected the modified and untracked files, got %+v", dirty)
+	}
+
+	if _, err := Check(t.TempDir()); err == nil {
+		t.Error("expected a directory outside a repository to fail")
+	}
+}
+
+func TestSummary(t *testing.T) {
+	a := &Attestor{Files: []string{"a", "b", "c", "d", "e", "f", "g"}}
+	if summary := a.Summary(); summary != "a, b, c, d, e, and 2 more" {
+		t.Errorf("unexpected summary %q", summary)
+	}
+}
+
+func TestCollection(t *testing.T) {
+	collection := attestation.NewCollection("build", nil)
+	if c := Collection(collection, New()); len(c.Attestations) != 0 {
+		t.Error("expected a clean worktree not to be recorded")
+	}
+
+	c := Collection(collection, &Attestor{Files: []string{"main.go"}})
+	if len(c.Attestations) != 1 || c.Attestations[0].Type != Type {
+		t.Errorf("expected the dirty worktree to be recorded, got %+v", c.Attestations)
+	}
+}
+
+func TestValidateMode(t *testing.T) {
+	for _, mode := range []string{ModeAllow, ModeRecord, ModeFail} {
+		if err := ValidateMode(mode); err != nil {
+			t.Error(err)
+		}
+	}
+
+	if err := ValidateMode("warn"); err == nil {
+		t.Error("expected an unknown mode to be rejected")
+	}
+}
@@ -0,0 +1,252 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package auditlog writes an append-only, hash-chained log of verification decisions.
+package auditlog
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/testifysec/go-witness/log"
+)
+
+// Decision is the outcome of verifying a subject against a policy.
+type Decision struct {
+	Subject string `json:"subject"`
+	// Inputs identify the evidence the decision was based on.
+	Inputs       []string `json:"inputs"`
+	PolicyDigest string   `json:"policyDigest"`
+	Allowed      bool     `json:"allowed"`
+	Error        string   `json:"error,omitempty"`
+}
+
+// Anchor records that the log's head was published to a transparency log.
+type Anchor struct {
+	Sequence uint64 `json:"sequence"`
+	Head     string `json:"head"`
+	Location string `json:"location"`
+}
+
+// Entry is a single line of the log. Each entry's hash covers the hash of the entry before it, so removing or changing
+// an entry breaks the chain for every entry after it.
+type Entry struct {
+	Sequence uint64    `json:"sequence"`
+	Time     time.Time `json:"time"`
+	Previous string    `json:"previous"`
+	Decision *Decision `json:"decision,omitempty"`
+	Anchor   *Anchor   `json:"anchor,omitempty"`
+	Hash     string    `json:"hash"`
+}
+
+// ErrBrokenChain is returned when an entry does not match the hash chain.
+type ErrBrokenChain struct {
+	Sequence uint64
+	Reason   string
+}
+
+func (e ErrBrokenChain) Error() string {
+	return fmt.Sprintf("audit log entry %d is invalid: %v", e.Sequence, e.Reason)
+}
+
+// AnchorFunc publishes the log's head and returns where it was published.
+type AnchorFunc func(ctx context.Context, sequence uint64, head string) (string, error)
+
+// Log appends entries to a file.
+type Log struct {
+	mu       sync.Mutex
+	file     *os.File
+	sequence uint64
+	head     string
+	anchored string
+}
+
+// Open verifies the chain of an existing log at path, or creates it, and opens it for appending.
+func Open(path string) (*Log, error) {
+	l := &Log{}
+	if existing, err := os.Open(path); err == nil {
+		entries, err := Verify(existing)
+		existing.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to verify existing audit log: %w", err)
+		}
+
+		if len(entries) > 0 {
+			last := entries[len(entries)-1]
+			l.sequence = last.Sequence + 1
+			l.head = last.Hash
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+
+	l.file = f
+	return l, nil
+}
+
+func (l *Log) Close() error {
+	return l.file.Close()
+}
+
+// Append adds a decision to the log.
+func (l *Log) Append(d Decision) (Entry, error) {
+	return l.append(Entry{Decision: &d})
+}
+
+func (l *Log) append(e Entry) (Entry, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	e.Sequence = l.sequence
+	e.Time = time.Now().UTC()
+	e.Previous = l.head
+	hash, err := hashEntry(e)
+	if err != nil {
+		return Entry{}, err
+	}
+
+	e.Hash = hash
+	line, err := json.Marshal(e)
+	if err != nil {
+		return Entry{}, fmt.Errorf("failed to marshal audit log entry: %w", err)
+	}
+
+	if _, err := l.file.Write(append(line, '\n')); err != nil {
+		return Entry{}, fmt.Errorf("failed to write audit log entry: %w", err)
+	}
+
+	if err := l.file.Sync(); err != nil {
+		return Entry{}, fmt.Errorf("failed to sync audit log: %w", err)
+	}
+
+	l.sequence++
+	l.head = e.Hash
+	return e, nil
+}
+
+// Head returns the sequence number and hash of the last entry.
+func (l *Log) Head() (uint64, string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.sequence == 0 {
+		return 0, ""
+	}
+
+	return l.sequence - 1, l.head
+}
+
+// Anchor publishes the log's head with anchor and records where it was published, unless the head has already been
+// anchored or the log is empty.
+func (l *Log) Anchor(ctx context.Context, anchor AnchorFunc) error {
+	sequence, head := l.Head()
+	if head == "" || head == l.anchored {
+		return nil
+	}
+
+	location, err := anchor(ctx, sequence, head)
+	if err != nil {
+		return fmt.Errorf("failed to anchor audit log: %w", err)
+	}
+
+	e, err := l.append(Entry{Anchor: &Anchor{Sequence: sequence, Head: head, Location: location}})
+	if err != nil {
+		return err
+	}
+
+	// the anchor entry itself doesn't need anchoring until another decision is logged
+	l.mu.Lock()
+	l.anchored = e.Hash
+	l.mu.Unlock()
+	return nil
+}
+
+// RunAnchor anchors the log's head every interval until ctx is done.
+func (l *Log) RunAnchor(ctx context.Context, interval time.Duration, anchor AnchorFunc) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := l.Anchor(ctx, anchor); err != nil {
+				log.Error(err)
+			}
+		}
+	}
+}
+
+// Verify reads a log and checks that every entry's hash is correct and chains to the entry before it.
+func Verify(r io.Reader) ([]Entry, error) {
+	entries := make([]Entry, 0)
+	previous := ""
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 16*1024*1024)
+	for scanner.Scan() {
+		sequence := uint64(len(entries))
+		e := Entry{}
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, ErrBrokenChain{Sequence: sequence, Reason: err.Error()}
+		}
+
+		if e.Sequence != sequence {
+			return nil, ErrBrokenChain{Sequence: sequence, Reason: fmt.Sprintf("unexpected sequence number %d", e.Sequence)}
+		}
+
+		if e.Previous != previous {
+			return nil, ErrBrokenChain{Sequence: sequence, Reason: "previous hash does not match"}
+		}
+
+		hash, err := hashEntry(e)
+		if err != nil {
+			return nil, err
+		}
+
+		if hash != e.Hash {
+			return nil, ErrBrokenChain{Sequence: sequence, Reason: "hash does not match contents"}
+		}
+
+		entries = append(entries, e)
+		previous = e.Hash
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read audit log: %w", err)
+	}
+
+	return entries, nil
+}
+
+// hashEntry returns the sha256 of the entry's json with its hash removed.
+func hashEntry(e Entry) (string, error) {
+	e.Hash = ""
+	b, err := json.Marshal(e)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal audit log entry: %w", err)
+	}
+
+	return fmt.Sprintf("sha256:%x", sha256.Sum256(b)), nil
+}
@@ -0,0 +1,113 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package auditlog
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	l, err := Open(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if _, err := l.Append(Decision{Subject: "app@sha256:aa", PolicyDigest: "sha256:policy", Allowed: true, Inputs: []string{"sha256:att"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	anchored := 0
+	anchor := func(ctx context.Context, sequence uint64, head string) (string, error) {
+		anchored++
+		return "https://rekor.example.com/api/v1/log/entries/1", nil
+	}
+
+	if err := l.Anchor(context.Background(), anchor); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := l.Anchor(context.Background(), anchor); err != nil || anchored != 1 {
+		t.Errorf("expected unchanged head not to be anchored again: %v", err)
+	}
+
+	l.Close()
+
+	// reopening continues the chain
+	l, err = Open(path)
+	if err != nil {
+		t.Fatalf("unexpected error reopening log: %v", err)
+	}
+
+	if _, err := l.Append(Decision{Subject: "app@sha256:bb", PolicyDigest: "sha256:policy", Error: "no evidence"}); err != nil {
+		t.Fatal(err)
+	}
+
+	l.Close()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer f.Close()
+	entries, err := Verify(f)
+	if err != nil {
+		t.Fatalf("expected log to verify: %v", err)
+	}
+
+	if len(entries) != 3 || entries[1].Anchor == nil || entries[1].Anchor.Head != entries[0].Hash || entries[2].Decision.Allowed {
+		t.Errorf("unexpected entries: %+v", entries)
+	}
+}
+
+func TestVerifyTampered(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	l, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, allowed := range []bool{false, false} {
+		if _, err := l.Append(Decision{Subject: "app@sha256:aa", Allowed: allowed}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	l.Close()
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tampered := bytes.Replace(contents, []byte(`"allowed":false`), []byte(`"allowed":true`), 1)
+	_, err = Verify(bytes.NewReader(tampered))
+	brokenChain := ErrBrokenChain{}
+	if !errors.As(err, &brokenChain) || brokenChain.Sequence != 0 {
+		t.Errorf("expected broken chain at entry 0, got %v", err)
+	}
+
+	if err := os.WriteFile(path, tampered, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Open(path); err == nil {
+		t.Error("expected tampered log not to be opened")
+	}
+}
@@ -0,0 +1,211 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package awskms signs with asymmetric keys held in AWS KMS, so signing keys never leave KMS. Credentials, region, and
+// assumed roles come from the standard AWS SDK configuration chain, such as AWS_PROFILE and AWS_REGION.
+package awskms
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/kms"
+	"github.com/aws/aws-sdk-go/service/kms/kmsiface"
+	"github.com/testifysec/go-witness/cryptoutil"
+	witnesskms "github.com/testifysec/witness/pkg/kms"
+)
+
+const (
+	// Scheme is the kms reference scheme AWS KMS keys are registered under.
+	Scheme = "awskms"
+	// ReferenceScheme prefixes references to AWS KMS keys.
+	ReferenceScheme = Scheme + "://"
+)
+
+func init() {
+	witnesskms.Register(Scheme, func(ctx context.Context, ref string) (witnesskms.SignerVerifier, error) {
+		return New(ctx, ref)
+	})
+}
+
+// Reference identifies a KMS key, written as awskms://[endpoint]/<key id, alias, or arn>, such as
+// awskms:///alias/witness or awskms://localhost:4566/alias/witness.
+type Reference struct {
+	// Endpoint overrides the KMS endpoint, such as for a local KMS emulator.
+	Endpoint string
+	KeyID    string
+	// Region is read from the key's ARN, if it is one.
+	Region string
+}
+
+// ParseReference parses an awskms:// key reference.
+func ParseReference(ref string) (Reference, error) {
+	if !strings.HasPrefix(ref, ReferenceScheme) {
+		return Reference{}, fmt.Errorf("kms reference %v does not start with %v", ref, ReferenceScheme)
+	}
+
+	parts := strings.SplitN(strings.TrimPrefix(ref, ReferenceScheme), "/", 2)
+	if len(parts) != 2 || parts[1] == "" {
+		return Reference{}, fmt.Errorf("kms reference %v does not name a key", ref)
+	}
+
+	r := Reference{Endpoint: parts[0], KeyID: parts[1]}
+	if arn := strings.Split(r.KeyID, ":"); len(arn) >= 6 && arn[0] == "arn" && arn[2] == "kms" {
+		r.Region = arn[3]
+	}
+
+	return r, nil
+}
+
+// Signer signs with a KMS key. Only ECC_NIST_P256 and RSA keys are supported, signing SHA-256 digests with ECDSA and
+// RSASSA-PSS respectively, since witness verifies signatures over SHA-256 digests.
+type Signer struct {
+	ctx       context.Context
+	client    kmsiface.KMSAPI
+	keyID     string
+	algorithm string
+	verifier  cryptoutil.Verifier
+}
+
+// New returns a signer for the referenced key using the AWS SDK's default configuration.
+func New(ctx context.Context, ref string) (*Signer, error) {
+	r, err := ParseReference(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	config := aws.NewConfig()
+	if r.Region != "" {
+		config = config.WithRegion(r.Region)
+	}
+
+	if r.Endpoint != "" {
+		config = config.WithEndpoint(r.Endpoint)
+	}
+
+	sess, err := session.NewSessionWithOptions(session.Options{
+		Config:            *config,
+		SharedConfigState: session.SharedConfigEnable,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create aws session: %w", err)
+	}
+
+	return NewWithClient(ctx, kms.New(sess), r.KeyID)
+}
+
+// NewWithClient returns a signer for the key using the KMS client. The key's public key is fetched to choose the
+// signing algorithm and to compute the key id.
+func NewWithClient(ctx context.Context, client kmsiface.KMSAPI, keyID string) (*Signer, error) {
+	out, err := client.GetPublicKeyWithContext(ctx, &kms.GetPublicKeyInput{KeyId: aws.String(keyID)})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get public key of kms key %v: %w", keyID, err)
+	}
+
+	if usage := aws.StringValue(out.KeyUsage); usage != "" && usage != kms.KeyUsageTypeSignVerify {
+		return nil, fmt.Errorf("kms key %v has usage %v, not %v", keyID, usage, kms.KeyUsageTypeSignVerify)
+	}
+
+	pub, err := x509.ParsePKIXPublicKey(out.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse public key of kms key %v: %w", keyID, err)
+	}
+
+	algorithm, err := signingAlgorithm(pub)
+	if err != nil {
+		return nil, fmt.Errorf("kms key %v can not be used: %w", keyID, err)
+	}
+
+	if len(out.SigningAlgorithms) > 0 && !contains(aws.StringValueSlice(out.SigningAlgorithms), algorithm) {
+		return nil, fmt.Errorf("kms key %v does not support %v", keyID, algorithm)
+	}
+
+	verifier, err := cryptoutil.NewVerifier(pub)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Signer{ctx: ctx, client: client, keyID: keyID, algorithm: algorithm, verifier: verifier}, nil
+}
+
+func signingAlgorithm(pub crypto.PublicKey) (string, error) {
+	switch key := pub.(type) {
+	case *ecdsa.PublicKey:
+		if key.Curve != elliptic.P256() {
+			return "", fmt.Errorf("unsupported ecdsa curve %v", key.Curve.Params().Name)
+		}
+
+		return kms.SigningAlgorithmSpecEcdsaSha256, nil
+	case *rsa.PublicKey:
+		return kms.SigningAlgorithmSpecRsassaPssSha256, nil
+	default:
+		return "", fmt.Errorf("unsupported key type %T", pub)
+	}
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (s *Signer) KeyID() (string, error) {
+	return s.verifier.KeyID()
+}
+
+// Sign sends the SHA-256 digest of the data to KMS to be signed.
+func (s *Signer) Sign(r io.Reader) ([]byte, error) {
+	digest, err := cryptoutil.Digest(r, crypto.SHA256)
+	if err != nil {
+		return nil, err
+	}
+
+	out, err := s.client.SignWithContext(s.ctx, &kms.SignInput{
+		KeyId:            aws.String(s.keyID),
+		Message:          digest,
+		MessageType:      aws.String(kms.MessageTypeDigest),
+		SigningAlgorithm: aws.String(s.algorithm),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign with kms key %v: %w", s.keyID, err)
+	}
+
+	return out.Signature, nil
+}
+
+func (s *Signer) Verifier() (cryptoutil.Verifier, error) {
+	return s.verifier, nil
+}
+
+// Verify checks the signature with the key's public key, without calling KMS.
+func (s *Signer) Verify(body io.Reader, sig []byte) error {
+	return s.verifier.Verify(body, sig)
+}
+
+func (s *Signer) Bytes() ([]byte, error) {
+	return s.verifier.Bytes()
+}
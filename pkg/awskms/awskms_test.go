@@ -0,0 +1,147 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package awskms
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"fmt"
+	"testing"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/request"
+	"github.com/aws/aws-sdk-go/service/kms"
+	"github.com/aws/aws-sdk-go/service/kms/kmsiface"
+	"github.com/testifysec/go-witness/cryptoutil"
+)
+
+// mockKMS signs with a local key the way KMS signs digests with an asymmetric key.
+type mockKMS struct {
+	kmsiface.KMSAPI
+	key  *ecdsa.PrivateKey
+	sign *kms.SignInput
+}
+
+func (m *mockKMS) GetPublicKeyWithContext(ctx aws.Context, input *kms.GetPublicKeyInput, opts ...request.Option) (*kms.GetPublicKeyOutput, error) {
+	der, err := x509.MarshalPKIXPublicKey(&m.key.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &kms.GetPublicKeyOutput{
+		KeyId:             input.KeyId,
+		KeyUsage:          aws.String(kms.KeyUsageTypeSignVerify),
+		PublicKey:         der,
+		SigningAlgorithms: aws.StringSlice([]string{kms.SigningAlgorithmSpecEcdsaSha256}),
+	}, nil
+}
+
+func (m *mockKMS) SignWithContext(ctx aws.Context, input *kms.SignInput, opts ...request.Option) (*kms.SignOutput, error) {
+	m.sign = input
+	if aws.StringValue(input.MessageType) != kms.MessageTypeDigest {
+		return nil, fmt.Errorf("expected a digest")
+	}
+
+	signature, err := ecdsa.SignASN1(rand.Reader, m.key, input.Message)
+	if err != nil {
+		return nil, err
+	}
+
+	return &kms.SignOutput{KeyId: input.KeyId, Signature: signature, SigningAlgorithm: input.SigningAlgorithm}, nil
+}
+
+func TestParseReference(t *testing.T) {
+	tests := []struct {
+		ref      string
+		expected Reference
+	}{
+		{"awskms:///alias/witness", Reference{KeyID: "alias/witness"}},
+		{"awskms://localhost:4566/alias/witness", Reference{Endpoint: "localhost:4566", KeyID: "alias/witness"}},
+		{"awskms:///arn:aws:kms:eu-west-1:123456789012:key/1234abcd", Reference{KeyID: "arn:aws:kms:eu-west-1:123456789012:key/1234abcd", Region: "eu-west-1"}},
+	}
+
+	for _, test := range tests {
+		r, err := ParseReference(test.ref)
+		if err != nil {
+			t.Fatalf("failed to parse %v: %v", test.ref, err)
+		}
+
+		if r != test.expected {
+			t.Errorf("expected %v to parse as %+v, got %+v", test.ref, test.expected, r)
+		}
+	}
+
+	for _, ref := range []string{"gcpkms://projects/p/keys/k", "awskms://", "awskms:///"} {
+		if _, err := ParseReference(ref); err == nil {
+			t.Errorf("expected %v to be rejected", ref)
+		}
+	}
+}
+
+func TestSigner(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := &mockKMS{key: key}
+	signer, err := NewWithClient(context.Background(), client, "alias/witness")
+	if err != nil {
+		t.Fatalf("failed to create signer: %v", err)
+	}
+
+	data := []byte("attestation")
+	signature, err := signer.Sign(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("failed to sign: %v", err)
+	}
+
+	if aws.StringValue(client.sign.SigningAlgorithm) != kms.SigningAlgorithmSpecEcdsaSha256 {
+		t.Errorf("unexpected signing algorithm %v", aws.StringValue(client.sign.SigningAlgorithm))
+	}
+
+	verifier := cryptoutil.NewECDSAVerifier(&key.PublicKey, crypto.SHA256)
+	if err := verifier.Verify(bytes.NewReader(data), signature); err != nil {
+		t.Errorf("expected the signature to verify with the kms public key: %v", err)
+	}
+
+	signerKeyID, err := signer.KeyID()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyID, err := verifier.KeyID()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if signerKeyID != keyID {
+		t.Errorf("expected the key id of the kms public key, got %v", signerKeyID)
+	}
+
+	p384, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewWithClient(context.Background(), &mockKMS{key: p384}, "alias/p384"); err == nil {
+		t.Error("expected a P-384 key to be rejected")
+	}
+}
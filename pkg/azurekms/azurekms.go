@@ -0,0 +1,264 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package azurekms signs with keys held in Azure Key Vault. Credentials come from the Azure SDK's default credential
+// chain, such as AZURE_CLIENT_ID and AZURE_CLIENT_SECRET, a managed identity, or the Azure CLI.
+package azurekms
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/Azure/azure-sdk-for-go/sdk/azidentity"
+	"github.com/testifysec/witness/pkg/kms"
+)
+
+const (
+	// Scheme is the kms reference scheme Key Vault keys are registered under.
+	Scheme = "azurekms"
+	// ReferenceScheme prefixes references to Key Vault keys.
+	ReferenceScheme = Scheme + "://"
+
+	apiVersion    = "7.3"
+	keyVaultScope = "https://vault.azure.net/.default"
+)
+
+func init() {
+	kms.Register(Scheme, New)
+}
+
+// Reference identifies a Key Vault key, written as azurekms://<vault host>/<key name>[/<version>], such as
+// azurekms://witness.vault.azure.net/signing.
+type Reference struct {
+	VaultURL string
+	Name     string
+	// Version is the key version to sign with. The key's current version is used if it is empty.
+	Version string
+}
+
+// ParseReference parses an azurekms:// key reference.
+func ParseReference(ref string) (Reference, error) {
+	if !strings.HasPrefix(ref, ReferenceScheme) {
+		return Reference{}, fmt.Errorf("kms reference %v does not start with %v", ref, ReferenceScheme)
+	}
+
+	parts := strings.Split(strings.TrimPrefix(ref, ReferenceScheme), "/")
+	if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || parts[1] == "" {
+		return Reference{}, fmt.Errorf("kms reference %v does not name a vault and key", ref)
+	}
+
+	r := Reference{VaultURL: "https://" + parts[0], Name: parts[1]}
+	if len(parts) == 3 {
+		r.Version = parts[2]
+	}
+
+	return r, nil
+}
+
+// TokenFunc returns an access token for Key Vault.
+type TokenFunc func(ctx context.Context) (string, error)
+
+// Client calls the Key Vault REST API.
+type Client struct {
+	Token      TokenFunc
+	HTTPClient *http.Client
+}
+
+// New returns a signer for the referenced key using the Azure SDK's default credential chain.
+func New(ctx context.Context, ref string) (kms.SignerVerifier, error) {
+	r, err := ParseReference(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	cred, err := azidentity.NewDefaultAzureCredential(nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find azure credentials: %w", err)
+	}
+
+	c := Client{
+		Token: func(ctx context.Context) (string, error) {
+			token, err := cred.GetToken(ctx, policy.TokenRequestOptions{Scopes: []string{keyVaultScope}})
+			return token.Token, err
+		},
+	}
+
+	return c.SignerVerifier(ctx, r)
+}
+
+type jsonWebKey struct {
+	Kid string `json:"kid"`
+	Kty string `json:"kty"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+// SignerVerifier returns a signer for the key. P-256 keys sign with ES256 and RSA keys with PS256. If the reference
+// doesn't name a version, the key's current version is resolved once so every signature is made by the same version.
+func (c Client) SignerVerifier(ctx context.Context, r Reference) (kms.SignerVerifier, error) {
+	bundle := struct {
+		Key jsonWebKey `json:"key"`
+	}{}
+
+	keyPath := "keys/" + r.Name
+	if r.Version != "" {
+		keyPath += "/" + r.Version
+	}
+
+	if err := c.do(ctx, r.VaultURL, http.MethodGet, keyPath, nil, &bundle); err != nil {
+		return nil, fmt.Errorf("failed to get key %v: %w", r.Name, err)
+	}
+
+	pub, alg, err := publicKey(bundle.Key)
+	if err != nil {
+		return nil, fmt.Errorf("key %v can not be used: %w", r.Name, err)
+	}
+
+	version := r.Version
+	if version == "" {
+		version = bundle.Key.Kid[strings.LastIndex(bundle.Key.Kid, "/")+1:]
+	}
+
+	return kms.NewSignerVerifier(pub, func(digest []byte) ([]byte, error) {
+		request := map[string]string{"alg": alg, "value": base64.RawURLEncoding.EncodeToString(digest)}
+		signed := struct {
+			Value string `json:"value"`
+		}{}
+
+		if err := c.do(ctx, r.VaultURL, http.MethodPost, fmt.Sprintf("keys/%v/%v/sign", r.Name, version), request, &signed); err != nil {
+			return nil, fmt.Errorf("failed to sign with key %v: %w", r.Name, err)
+		}
+
+		sig, err := base64.RawURLEncoding.DecodeString(signed.Value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode signature from key %v: %w", r.Name, err)
+		}
+
+		if alg == "ES256" {
+			return asn1Signature(sig)
+		}
+
+		return sig, nil
+	})
+}
+
+func publicKey(key jsonWebKey) (interface{}, string, error) {
+	switch key.Kty {
+	case "EC", "EC-HSM":
+		if key.Crv != "P-256" {
+			return nil, "", fmt.Errorf("unsupported curve %v", key.Crv)
+		}
+
+		x, err := base64.RawURLEncoding.DecodeString(key.X)
+		if err != nil {
+			return nil, "", err
+		}
+
+		y, err := base64.RawURLEncoding.DecodeString(key.Y)
+		if err != nil {
+			return nil, "", err
+		}
+
+		return &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, "ES256", nil
+	case "RSA", "RSA-HSM":
+		n, err := base64.RawURLEncoding.DecodeString(key.N)
+		if err != nil {
+			return nil, "", err
+		}
+
+		e, err := base64.RawURLEncoding.DecodeString(key.E)
+		if err != nil {
+			return nil, "", err
+		}
+
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, "PS256", nil
+	default:
+		return nil, "", fmt.Errorf("unsupported key type %v", key.Kty)
+	}
+}
+
+// asn1Signature converts the r||s ECDSA signature Key Vault returns to the ASN.1 encoding witness verifies.
+func asn1Signature(sig []byte) ([]byte, error) {
+	if len(sig) != 64 {
+		return nil, fmt.Errorf("unexpected ES256 signature length %v", len(sig))
+	}
+
+	return asn1.Marshal(struct {
+		R, S *big.Int
+	}{new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])})
+}
+
+func (c Client) do(ctx context.Context, vaultURL, method, path string, body interface{}, out interface{}) error {
+	token, err := c.Token(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get key vault access token: %w", err)
+	}
+
+	var reqBody io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+
+		reqBody = bytes.NewReader(encoded)
+	}
+
+	url := fmt.Sprintf("%v/%v?api-version=%v", strings.TrimSuffix(vaultURL, "/"), path, apiVersion)
+	req, err := http.NewRequestWithContext(ctx, method, url, reqBody)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		response := struct {
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}{}
+
+		_ = json.NewDecoder(resp.Body).Decode(&response)
+		return fmt.Errorf("key vault returned %v: %v", resp.Status, response.Error.Message)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,131 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package azurekms
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func fakeKeyVault(t *testing.T, priv *ecdsa.PrivateKey) *httptest.Server {
+	var server *httptest.Server
+	mux := http.NewServeMux()
+	mux.HandleFunc("/keys/signing", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"key": map[string]string{
+			"kid": server.URL + "/keys/signing/abc123",
+			"kty": "EC-HSM",
+			"crv": "P-256",
+			"x":   base64.RawURLEncoding.EncodeToString(priv.X.FillBytes(make([]byte, 32))),
+			"y":   base64.RawURLEncoding.EncodeToString(priv.Y.FillBytes(make([]byte, 32))),
+		}})
+	})
+
+	mux.HandleFunc("/keys/signing/abc123/sign", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"error": map[string]string{"message": "unauthorized"}})
+			return
+		}
+
+		request := struct {
+			Alg   string `json:"alg"`
+			Value string `json:"value"`
+		}{}
+
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil || request.Alg != "ES256" {
+			t.Fatalf("unexpected sign request %+v: %v", request, err)
+		}
+
+		digest, err := base64.RawURLEncoding.DecodeString(request.Value)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		sigR, sigS, err := ecdsa.Sign(rand.Reader, priv, digest)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		sig := append(sigR.FillBytes(make([]byte, 32)), sigS.FillBytes(make([]byte, 32))...)
+		_ = json.NewEncoder(w).Encode(map[string]string{"value": base64.RawURLEncoding.EncodeToString(sig)})
+	})
+
+	server = httptest.NewServer(mux)
+	return server
+}
+
+func TestParseReference(t *testing.T) {
+	r, err := ParseReference("azurekms://witness.vault.azure.net/signing/abc123")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if r.VaultURL != "https://witness.vault.azure.net" || r.Name != "signing" || r.Version != "abc123" {
+		t.Errorf("unexpected reference: %+v", r)
+	}
+
+	for _, invalid := range []string{"azurekms://witness.vault.azure.net", "azurekms:///signing", "azurekms://vault/a/b/c"} {
+		if _, err := ParseReference(invalid); err == nil {
+			t.Errorf("expected %v to be rejected", invalid)
+		}
+	}
+}
+
+func TestSignerVerifier(t *testing.T) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := fakeKeyVault(t, priv)
+	defer server.Close()
+
+	c := Client{
+		Token:      func(ctx context.Context) (string, error) { return "token", nil },
+		HTTPClient: server.Client(),
+	}
+
+	signer, err := c.SignerVerifier(context.Background(), Reference{VaultURL: server.URL, Name: "signing"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sig, err := signer.Sign(bytes.NewReader([]byte("attestation")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := signer.Verify(bytes.NewReader([]byte("attestation")), sig); err != nil {
+		t.Errorf("expected signature to verify: %v", err)
+	}
+
+	c.Token = func(ctx context.Context) (string, error) { return "expired", nil }
+	signer, err = c.SignerVerifier(context.Background(), Reference{VaultURL: server.URL, Name: "signing"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := signer.Sign(bytes.NewReader([]byte("attestation"))); err == nil {
+		t.Error("expected key vault error to be returned")
+	}
+}
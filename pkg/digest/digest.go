@@ -0,0 +1,300 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package digest computes file digests using pluggable hash implementations. Implementations are registered per
+// algorithm, so an accelerated implementation can replace the standard library's without changing callers.
+package digest
+
+import (
+	"crypto"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+
+	// imported so the standard library implementations are registered with crypto
+	_ "crypto/sha1"
+	_ "crypto/sha256"
+
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/log"
+)
+
+const chunkSize = 1024 * 1024
+
+// mmapThreshold is the size at which CalculateFile memory maps files instead of reading them.
+var mmapThreshold int64 = 64 * 1024 * 1024
+
+// Go is the name of the standard library backends. The standard library uses the SHA extensions on amd64 and arm64
+// CPUs that support them.
+const Go = "go"
+
+// Backend is an implementation of a hash algorithm.
+type Backend struct {
+	Name string
+	Hash crypto.Hash
+	New  func() hash.Hash
+}
+
+type ErrUnknownBackend struct {
+	Hash crypto.Hash
+	Name string
+}
+
+func (e ErrUnknownBackend) Error() string {
+	return fmt.Sprintf("no %v backend named %v", e.Hash, e.Name)
+}
+
+var (
+	mu       sync.RWMutex
+	backends = map[crypto.Hash]map[string]Backend{}
+	selected = map[crypto.Hash]string{}
+)
+
+func init() {
+	for _, h := range []crypto.Hash{crypto.SHA256, crypto.SHA1} {
+		Register(Backend{Name: Go, Hash: h, New: h.New})
+	}
+}
+
+// Register makes a backend available for its algorithm. The first backend registered for an algorithm is used until
+// another is selected.
+func Register(b Backend) {
+	mu.Lock()
+	defer mu.Unlock()
+	if backends[b.Hash] == nil {
+		backends[b.Hash] = map[string]Backend{}
+	}
+
+	backends[b.Hash][b.Name] = b
+	if _, ok := selected[b.Hash]; !ok {
+		selected[b.Hash] = b.Name
+	}
+}
+
+// Select uses the named backend for the algorithm.
+func Select(h crypto.Hash, name string) error {
+	mu.Lock()
+	defer mu.Unlock()
+	if _, ok := backends[h][name]; !ok {
+		return ErrUnknownBackend{Hash: h, Name: name}
+	}
+
+	selected[h] = name
+	return nil
+}
+
+// Available returns the names of the backends registered for the algorithm, sorted by name.
+func Available(h crypto.Hash) []string {
+	mu.RLock()
+	defer mu.RUnlock()
+	names := make([]string, 0, len(backends[h]))
+	for name := range backends[h] {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// Selected returns the backend used for the algorithm. Algorithms without a registered backend use crypto's.
+func Selected(h crypto.Hash) Backend {
+	mu.RLock()
+	defer mu.RUnlock()
+	if b, ok := backends[h][selected[h]]; ok {
+		return b
+	}
+
+	return Backend{Name: Go, Hash: h, New: h.New}
+}
+
+// SelectFastest benchmarks each backend for the algorithm by hashing size bytes and selects the fastest.
+func SelectFastest(h crypto.Hash, size int) (Backend, error) {
+	fastest := Backend{}
+	var best time.Duration
+	for _, name := range Available(h) {
+		mu.RLock()
+		b := backends[h][name]
+		mu.RUnlock()
+		elapsed := Benchmark(b, size)
+		if fastest.New == nil || elapsed < best {
+			fastest, best = b, elapsed
+		}
+	}
+
+	if fastest.New == nil {
+		return fastest, fmt.Errorf("no backends registered for %v", h)
+	}
+
+	return fastest, Select(h, fastest.Name)
+}
+
+// Benchmark returns how long the backend takes to hash size bytes.
+func Benchmark(b Backend, size int) time.Duration {
+	buf := make([]byte, 64*1024)
+	hasher := b.New()
+	start := time.Now()
+	for written := 0; written < size; written += len(buf) {
+		hasher.Write(buf)
+	}
+
+	hasher.Sum(nil)
+	return time.Since(start)
+}
+
+// Calculate reads r once, in chunks, and returns its digest for each algorithm using the selected backends.
+func Calculate(r io.Reader, hashes []crypto.Hash) (cryptoutil.DigestSet, error) {
+	hashers := newHashers(hashes)
+	writers := make([]io.Writer, 0, len(hashes))
+	for _, hasher := range hashers {
+		writers = append(writers, hasher)
+	}
+
+	if _, err := io.CopyBuffer(io.MultiWriter(writers...), r, make([]byte, chunkSize)); err != nil {
+		return nil, err
+	}
+
+	return sum(hashers), nil
+}
+
+// CalculateFile returns the digest of the file at path for each algorithm using the selected backends. Files of at
+// least 64MiB are memory mapped where supported, so the file is never copied into a buffer and each
+// algorithm hashes it concurrently. Other files, or files that can't be mapped, are read in chunks.
+func CalculateFile(path string, hashes []crypto.Hash) (cryptoutil.DigestSet, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	if info.Mode().IsRegular() && info.Size() >= mmapThreshold {
+		data, unmap, err := mmap(f, info.Size())
+		if err == nil {
+			defer unmap()
+			return calculateMapped(data, hashes), nil
+		}
+
+		log.Debugf("failed to memory map %v, reading it instead: %v", path, err)
+	}
+
+	return Calculate(f, hashes)
+}
+
+// CalculateFiles returns the digest of each file in paths, hashing up to workers files at a time. workers defaults to
+// the number of CPUs when it is not positive. The first error stops the remaining files from being hashed.
+func CalculateFiles(paths []string, hashes []crypto.Hash, workers int) (map[string]cryptoutil.DigestSet, error) {
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	if workers > len(paths) {
+		workers = len(paths)
+	}
+
+	type calculated struct {
+		path string
+		ds   cryptoutil.DigestSet
+		err  error
+	}
+
+	queue := make(chan string)
+	results := make(chan calculated)
+	done := make(chan struct{})
+	defer close(done)
+	for i := 0; i < workers; i++ {
+		go func() {
+			for path := range queue {
+				ds, err := CalculateFile(path, hashes)
+				select {
+				case results <- calculated{path, ds, err}:
+				case <-done:
+					return
+				}
+			}
+		}()
+	}
+
+	go func() {
+		defer close(queue)
+		for _, path := range paths {
+			select {
+			case queue <- path:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	digests := make(map[string]cryptoutil.DigestSet, len(paths))
+	for range paths {
+		result := <-results
+		if result.err != nil {
+			return nil, fmt.Errorf("failed to digest %v: %w", result.path, result.err)
+		}
+
+		digests[result.path] = result.ds
+	}
+
+	return digests, nil
+}
+
+// calculateMapped hashes data with each algorithm concurrently, a chunk at a time.
+func calculateMapped(data []byte, hashes []crypto.Hash) cryptoutil.DigestSet {
+	hashers := newHashers(hashes)
+	var wg sync.WaitGroup
+	for _, hasher := range hashers {
+		wg.Add(1)
+		go func(hasher hash.Hash) {
+			defer wg.Done()
+			for offset := 0; offset < len(data); offset += chunkSize {
+				end := offset + chunkSize
+				if end > len(data) {
+					end = len(data)
+				}
+
+				hasher.Write(data[offset:end])
+			}
+		}(hasher)
+	}
+
+	wg.Wait()
+	return sum(hashers)
+}
+
+func newHashers(hashes []crypto.Hash) map[crypto.Hash]hash.Hash {
+	hashers := make(map[crypto.Hash]hash.Hash, len(hashes))
+	for _, h := range hashes {
+		hashers[h] = Selected(h).New()
+	}
+
+	return hashers
+}
+
+func sum(hashers map[crypto.Hash]hash.Hash) cryptoutil.DigestSet {
+	ds := make(cryptoutil.DigestSet, len(hashers))
+	for h, hasher := range hashers {
+		ds[h] = fmt.Sprintf("%x", hasher.Sum(nil))
+	}
+
+	return ds
+}
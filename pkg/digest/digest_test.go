@@ -0,0 +1,179 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package digest
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"hash"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type countingHash struct {
+	hash.Hash
+	written *int
+}
+
+func (c countingHash) Write(p []byte) (int, error) {
+	*c.written += len(p)
+	return c.Hash.Write(p)
+}
+
+func TestSelect(t *testing.T) {
+	written := 0
+	Register(Backend{Name: "counting", Hash: crypto.SHA256, New: func() hash.Hash {
+		return countingHash{Hash: sha256.New(), written: &written}
+	}})
+
+	defer func() {
+		if err := Select(crypto.SHA256, Go); err != nil {
+			t.Fatal(err)
+		}
+	}()
+
+	if Selected(crypto.SHA256).Name != Go {
+		t.Errorf("expected registering a backend not to change the selected backend")
+	}
+
+	if err := Select(crypto.SHA256, "counting"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	data := []byte("witness")
+	ds, err := Calculate(bytes.NewReader(data), []crypto.Hash{crypto.SHA256, crypto.SHA1})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if ds[crypto.SHA256] != fmt.Sprintf("%x", sha256.Sum256(data)) || ds[crypto.SHA1] == "" {
+		t.Errorf("unexpected digests: %v", ds)
+	}
+
+	if written != len(data) {
+		t.Errorf("expected selected backend to be used, wrote %d bytes", written)
+	}
+
+	if err := Select(crypto.SHA256, "missing"); !errors.As(err, &ErrUnknownBackend{}) {
+		t.Errorf("expected unknown backend error, got %v", err)
+	}
+
+	if _, err := SelectFastest(crypto.SHA256, 1024*1024); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestCalculateFile(t *testing.T) {
+	data := bytes.Repeat([]byte("witness"), 3*chunkSize/7+5)
+	path := filepath.Join(t.TempDir(), "large")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	expected, err := Calculate(bytes.NewReader(data), []crypto.Hash{crypto.SHA256, crypto.SHA1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, threshold := range []int64{1, int64(len(data)) + 1} {
+		mmapThreshold = threshold
+		ds, err := CalculateFile(path, []crypto.Hash{crypto.SHA256, crypto.SHA1})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		if !ds.Equal(expected) || len(ds) != 2 {
+			t.Errorf("unexpected digests with threshold %d: %v", threshold, ds)
+		}
+	}
+
+	mmapThreshold = 64 * 1024 * 1024
+}
+
+func BenchmarkSHA256(b *testing.B) {
+	data := bytes.Repeat([]byte{1}, 16*1024*1024)
+	b.SetBytes(int64(len(data)))
+	for i := 0; i < b.N; i++ {
+		if _, err := Calculate(bytes.NewReader(data), []crypto.Hash{crypto.SHA256}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestCalculateFiles(t *testing.T) {
+	dir := t.TempDir()
+	paths := make([]string, 0)
+	for i := 0; i < 20; i++ {
+		path := filepath.Join(dir, fmt.Sprintf("file%d", i))
+		if err := os.WriteFile(path, []byte(path), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		paths = append(paths, path)
+	}
+
+	for _, workers := range []int{0, 1, 3, 100} {
+		digests, err := CalculateFiles(paths, []crypto.Hash{crypto.SHA256}, workers)
+		if err != nil {
+			t.Fatalf("unexpected error with %d workers: %v", workers, err)
+		}
+
+		for _, path := range paths {
+			if digests[path][crypto.SHA256] != fmt.Sprintf("%x", sha256.Sum256([]byte(path))) {
+				t.Errorf("unexpected digest of %v with %d workers: %v", path, workers, digests[path])
+			}
+		}
+	}
+
+	if _, err := CalculateFiles(append(paths, filepath.Join(dir, "missing")), []crypto.Hash{crypto.SHA256}, 2); err == nil {
+		t.Error("expected an error for a missing file")
+	}
+}
+
+// benchmarkCalculateFiles hashes 256 files of 256KiB, about the size of a mid-sized repository's sources and build
+// outputs, with the given number of workers.
+func benchmarkCalculateFiles(b *testing.B, workers int) {
+	dir := b.TempDir()
+	data := bytes.Repeat([]byte{1}, 256*1024)
+	paths := make([]string, 0)
+	for i := 0; i < 256; i++ {
+		path := filepath.Join(dir, fmt.Sprintf("file%d", i))
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			b.Fatal(err)
+		}
+
+		paths = append(paths, path)
+	}
+
+	b.SetBytes(int64(len(data) * len(paths)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := CalculateFiles(paths, []crypto.Hash{crypto.SHA256}, workers); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkCalculateFilesSequential(b *testing.B) {
+	benchmarkCalculateFiles(b, 1)
+}
+
+func BenchmarkCalculateFilesParallel(b *testing.B) {
+	benchmarkCalculateFiles(b, 0)
+}
@@ -0,0 +1,94 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ephemeral creates single use signing keys certified by a long-term key.
+package ephemeral
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/testifysec/go-witness/cryptoutil"
+)
+
+const (
+	// clockSkew is subtracted from the certificate's start time to tolerate clocks that are slightly behind.
+	clockSkew = 5 * time.Minute
+	// DefaultTTL is how long ephemeral certificates are valid for by default, long enough for most CI steps.
+	DefaultTTL = time.Hour
+)
+
+// NewSigner generates an ECDSA P-256 key and issues it a certificate signed by caKey, which must be the key of caCert.
+// The returned signer carries caCert and intermediates as its chain, so envelopes it signs verify against the root of
+// caCert. The certificate is valid for ttl from now, or until caCert expires if that is sooner, so a leaked ephemeral
+// key can only sign for the length of a run.
+func NewSigner(caKey crypto.Signer, caCert *x509.Certificate, intermediates []*x509.Certificate, commonName string, ttl time.Duration) (cryptoutil.Signer, error) {
+	if !caCert.IsCA || caCert.KeyUsage&x509.KeyUsageCertSign == 0 {
+		return nil, fmt.Errorf("certificate %v can not sign certificates", caCert.Subject.CommonName)
+	}
+
+	if ttl <= 0 {
+		return nil, fmt.Errorf("ephemeral certificate lifetime must be positive, got %v", ttl)
+	}
+
+	now := time.Now()
+	if !now.Before(caCert.NotAfter) {
+		return nil, fmt.Errorf("certificate %v expired at %v", caCert.Subject.CommonName, caCert.NotAfter)
+	}
+
+	notAfter := now.Add(ttl)
+	if caCert.NotAfter.Before(notAfter) {
+		notAfter = caCert.NotAfter
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate ephemeral key: %w", err)
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate serial number: %w", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: commonName},
+		NotBefore:             now.Add(-clockSkew),
+		NotAfter:              notAfter,
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+		BasicConstraintsValid: true,
+	}
+
+	certBytes, err := x509.CreateCertificate(rand.Reader, template, caCert, key.Public(), caKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to certify ephemeral key: %w", err)
+	}
+
+	cert, err := x509.ParseCertificate(certBytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse ephemeral certificate: %w", err)
+	}
+
+	chain := append([]*x509.Certificate{caCert}, intermediates...)
+	return cryptoutil.NewSigner(key, cryptoutil.SignWithCertificate(cert), cryptoutil.SignWithIntermediates(chain))
+}
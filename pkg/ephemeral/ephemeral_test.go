@@ -0,0 +1,123 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ephemeral
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/dsse"
+)
+
+func newCA(t *testing.T, isCA bool) (*ecdsa.PrivateKey, *x509.Certificate) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "runner"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  isCA,
+	}
+
+	certBytes, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := x509.ParseCertificate(certBytes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return key, cert
+}
+
+func TestNewSigner(t *testing.T) {
+	caKey, caCert := newCA(t, true)
+	signer, err := NewSigner(caKey, caCert, nil, "build", DefaultTTL)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	x509Signer, ok := signer.(*cryptoutil.X509Signer)
+	if !ok {
+		t.Fatalf("expected x509 signer, got %T", signer)
+	}
+
+	if x509Signer.Certificate().Subject.CommonName != "build" {
+		t.Errorf("unexpected ephemeral certificate: %+v", x509Signer.Certificate().Subject)
+	}
+
+	env, err := dsse.Sign("test", bytes.NewReader([]byte("payload")), signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := env.Verify(dsse.WithRoots([]*x509.Certificate{caCert})); err != nil {
+		t.Errorf("expected envelope to verify against the long-term certificate: %v", err)
+	}
+
+	_, otherCert := newCA(t, true)
+	if _, err := env.Verify(dsse.WithRoots([]*x509.Certificate{otherCert})); err == nil {
+		t.Error("expected envelope not to verify against another root")
+	}
+}
+
+func TestNewSignerTTL(t *testing.T) {
+	caKey, caCert := newCA(t, true)
+	for _, ttl := range []time.Duration{10 * time.Minute, 2 * time.Hour} {
+		before := time.Now()
+		signer, err := NewSigner(caKey, caCert, nil, "build", ttl)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		notAfter := signer.(*cryptoutil.X509Signer).Certificate().NotAfter
+		expected := before.Add(ttl).Truncate(time.Second)
+		if caCert.NotAfter.Before(expected) {
+			expected = caCert.NotAfter
+		}
+
+		// certificate times are truncated to seconds
+		if notAfter.Before(expected) || notAfter.After(time.Now().Add(ttl)) || notAfter.After(caCert.NotAfter) {
+			t.Errorf("expected a %v certificate to expire at %v, capped at the ca's %v, got %v", ttl, expected, caCert.NotAfter, notAfter)
+		}
+	}
+
+	if _, err := NewSigner(caKey, caCert, nil, "build", 0); err == nil {
+		t.Error("expected error for a certificate without a lifetime")
+	}
+}
+
+func TestNewSignerNotCA(t *testing.T) {
+	key, cert := newCA(t, false)
+	if _, err := NewSigner(key, cert, nil, "build", DefaultTTL); err == nil {
+		t.Error("expected error for certificate that is not a ca")
+	}
+}
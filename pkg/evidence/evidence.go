@@ -0,0 +1,132 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package evidence packages everything used in a verification into an archive that can be verified again offline.
+package evidence
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/testifysec/go-witness/dsse"
+)
+
+const (
+	PolicyFile      = "policy.json"
+	PolicyKeyFile   = "policy-key.pem"
+	ResultFile      = "result.json"
+	policyCADir     = "policy-ca"
+	attestationsDir = "attestations"
+)
+
+// Bundle holds the inputs and result of a verification.
+type Bundle struct {
+	Policy dsse.Envelope
+	// PolicyKey is the public key that verified the policy's signature, if one was used.
+	PolicyKey []byte
+	// PolicyCAs are the PEM encoded CA certificates that verified the policy's signature, if any were used.
+	PolicyCAs [][]byte
+	Evidence  []Envelope
+	Result    Result
+}
+
+type Envelope struct {
+	Envelope  dsse.Envelope
+	Reference string
+}
+
+// Result is the outcome of the verification, written to result.json in the bundle.
+type Result struct {
+	Verified       bool              `json:"verified"`
+	VerifiedAt     time.Time         `json:"verifiedat"`
+	ArtifactDigest map[string]string `json:"artifactdigest,omitempty"`
+	// Evidence maps the path of each attestation in the bundle to the reference it was verified under.
+	Evidence map[string]string `json:"evidence"`
+}
+
+// Write writes the bundle to w as a gzipped tar archive.
+func Write(w io.Writer, b Bundle) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	modTime := b.Result.VerifiedAt
+	writeFile := func(name string, data []byte) error {
+		hdr := &tar.Header{
+			Name:     name,
+			Typeflag: tar.TypeReg,
+			Mode:     0644,
+			Size:     int64(len(data)),
+			ModTime:  modTime,
+		}
+
+		if err := tw.WriteHeader(hdr); err != nil {
+			return fmt.Errorf("failed to write %v to evidence bundle: %w", name, err)
+		}
+
+		if _, err := tw.Write(data); err != nil {
+			return fmt.Errorf("failed to write %v to evidence bundle: %w", name, err)
+		}
+
+		return nil
+	}
+
+	writeJSON := func(name string, v interface{}) error {
+		data, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return fmt.Errorf("failed to marshal %v: %w", name, err)
+		}
+
+		return writeFile(name, data)
+	}
+
+	if err := writeJSON(PolicyFile, b.Policy); err != nil {
+		return err
+	}
+
+	if len(b.PolicyKey) > 0 {
+		if err := writeFile(PolicyKeyFile, b.PolicyKey); err != nil {
+			return err
+		}
+	}
+
+	for i, ca := range b.PolicyCAs {
+		if err := writeFile(fmt.Sprintf("%s/%d.pem", policyCADir, i), ca); err != nil {
+			return err
+		}
+	}
+
+	result := b.Result
+	result.Evidence = make(map[string]string)
+	for i, env := range b.Evidence {
+		name := fmt.Sprintf("%s/%d.json", attestationsDir, i)
+		if err := writeJSON(name, env.Envelope); err != nil {
+			return err
+		}
+
+		result.Evidence[name] = env.Reference
+	}
+
+	if err := writeJSON(ResultFile, result); err != nil {
+		return err
+	}
+
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("failed to close evidence bundle: %w", err)
+	}
+
+	return gz.Close()
+}
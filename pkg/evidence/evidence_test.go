@@ -0,0 +1,91 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package evidence
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/testifysec/go-witness/dsse"
+)
+
+func TestWrite(t *testing.T) {
+	b := Bundle{
+		Policy:    dsse.Envelope{Payload: []byte("policy"), PayloadType: "policy"},
+		PolicyKey: []byte("key"),
+		PolicyCAs: [][]byte{[]byte("ca")},
+		Evidence: []Envelope{
+			{Envelope: dsse.Envelope{Payload: []byte("build")}, Reference: "sha256:abc  build.json"},
+		},
+		Result: Result{Verified: true, VerifiedAt: time.Unix(1000, 0)},
+	}
+
+	buf := bytes.Buffer{}
+	if err := Write(&buf, b); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	gz, err := gzip.NewReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := make(map[string][]byte)
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		files[hdr.Name] = data
+	}
+
+	for _, name := range []string{PolicyFile, PolicyKeyFile, "policy-ca/0.pem", "attestations/0.json", ResultFile} {
+		if _, ok := files[name]; !ok {
+			t.Errorf("expected %v in bundle", name)
+		}
+	}
+
+	result := Result{}
+	if err := json.Unmarshal(files[ResultFile], &result); err != nil {
+		t.Fatal(err)
+	}
+
+	if !result.Verified || result.Evidence["attestations/0.json"] != "sha256:abc  build.json" {
+		t.Errorf("unexpected result: %+v", result)
+	}
+
+	policy := dsse.Envelope{}
+	if err := json.Unmarshal(files[PolicyFile], &policy); err != nil {
+		t.Fatal(err)
+	}
+
+	if string(policy.Payload) != "policy" {
+		t.Errorf("unexpected policy payload: %s", policy.Payload)
+	}
+}
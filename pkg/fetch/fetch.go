@@ -0,0 +1,215 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package fetch downloads files referenced by URL, such as attestations published alongside a release.
+package fetch
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/testifysec/witness/pkg/netrc"
+)
+
+const pinPrefix = "sha256="
+
+// Options controls how files are downloaded.
+type Options struct {
+	Client *http.Client
+	// MaxSize is the largest response body accepted, in bytes. 0 means no limit.
+	MaxSize int64
+	// Retries is how many times a failed request is retried. Requests that fail with a 4xx status other than 429 are
+	// not retried.
+	Retries int
+	// Backoff is the wait before the first retry. It doubles after each retry.
+	Backoff time.Duration
+}
+
+func DefaultOptions() Options {
+	return Options{
+		Client:  http.DefaultClient,
+		MaxSize: 32 << 20,
+		Retries: 3,
+		Backoff: time.Second,
+	}
+}
+
+type ErrTooLarge struct {
+	URL     string
+	MaxSize int64
+}
+
+func (e ErrTooLarge) Error() string {
+	return fmt.Sprintf("%v is larger than the limit of %d bytes", e.URL, e.MaxSize)
+}
+
+type ErrDigestMismatch struct {
+	URL      string
+	Expected string
+	Actual   string
+}
+
+func (e ErrDigestMismatch) Error() string {
+	return fmt.Sprintf("sha256 digest of %v is %v, expected %v", e.URL, e.Actual, e.Expected)
+}
+
+type errStatus struct {
+	status    string
+	code      int
+	retryable bool
+}
+
+func (e errStatus) Error() string {
+	return fmt.Sprintf("unexpected status %v", e.status)
+}
+
+// IsNotFound returns true if err is from a download that failed because the server has no file at the URL.
+func IsNotFound(err error) bool {
+	var status errStatus
+	return errors.As(err, &status) && status.code == http.StatusNotFound
+}
+
+// IsURL returns true if s is an http or https URL.
+func IsURL(s string) bool {
+	return strings.HasPrefix(s, "https://") || strings.HasPrefix(s, "http://")
+}
+
+// Parse splits an optional digest pin from a URL. A pin is given as a fragment of the form #sha256=<hex>, and the
+// URL is returned without it.
+func Parse(rawURL string) (string, string, error) {
+	parts := strings.SplitN(rawURL, "#", 2)
+	if len(parts) == 1 {
+		return rawURL, "", nil
+	}
+
+	if !strings.HasPrefix(parts[1], pinPrefix) {
+		return "", "", fmt.Errorf("unsupported digest pin %v, expected #%v<hex>", parts[1], pinPrefix)
+	}
+
+	pin := strings.ToLower(strings.TrimPrefix(parts[1], pinPrefix))
+	if decoded, err := hex.DecodeString(pin); err != nil || len(decoded) != sha256.Size {
+		return "", "", fmt.Errorf("invalid sha256 digest pin %v", pin)
+	}
+
+	return parts[0], pin, nil
+}
+
+// Fetch downloads rawURL, retrying failed requests, and checks the body against the URL's digest pin if it has one.
+// Requests to https URLs without credentials of their own authenticate with the host's credentials in .netrc, if any.
+func Fetch(ctx context.Context, rawURL string, opts Options) ([]byte, error) {
+	url, pin, err := Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.Client == nil {
+		opts.Client = http.DefaultClient
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	if req.URL.Scheme == "https" && req.URL.User == nil {
+		if login, password, ok := netrc.Lookup(req.URL.Host); ok {
+			req.SetBasicAuth(login, password)
+		}
+	}
+
+	backoff := opts.Backoff
+	var body []byte
+	for attempt := 0; ; attempt++ {
+		body, err = get(opts.Client, req, opts.MaxSize)
+		if err == nil {
+			break
+		}
+
+		if attempt >= opts.Retries || !retryable(err) {
+			return nil, fmt.Errorf("failed to fetch %v: %w", url, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+	}
+
+	if pin != "" {
+		digest := sha256.Sum256(body)
+		if actual := hex.EncodeToString(digest[:]); actual != pin {
+			return nil, ErrDigestMismatch{URL: url, Expected: pin, Actual: actual}
+		}
+	}
+
+	return body, nil
+}
+
+func get(client *http.Client, req *http.Request, maxSize int64) ([]byte, error) {
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errStatus{
+			status:    resp.Status,
+			code:      resp.StatusCode,
+			retryable: resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500,
+		}
+	}
+
+	if maxSize > 0 && resp.ContentLength > maxSize {
+		return nil, ErrTooLarge{URL: req.URL.String(), MaxSize: maxSize}
+	}
+
+	reader := io.Reader(resp.Body)
+	if maxSize > 0 {
+		reader = io.LimitReader(resp.Body, maxSize+1)
+	}
+
+	buf := &bytes.Buffer{}
+	if _, err := io.Copy(buf, reader); err != nil {
+		return nil, err
+	}
+
+	if maxSize > 0 && int64(buf.Len()) > maxSize {
+		return nil, ErrTooLarge{URL: req.URL.String(), MaxSize: maxSize}
+	}
+
+	return buf.Bytes(), nil
+}
+
+func retryable(err error) bool {
+	switch e := err.(type) {
+	case ErrTooLarge:
+		return false
+	case errStatus:
+		return e.retryable
+	default:
+		return true
+	}
+}
@@ -0,0 +1,130 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fetch
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	digest := sha256.Sum256([]byte("attestation"))
+	pin := hex.EncodeToString(digest[:])
+
+	url, parsedPin, err := Parse("https://example.com/build.json#sha256=" + strings.ToUpper(pin))
+	if err != nil || url != "https://example.com/build.json" || parsedPin != pin {
+		t.Fatalf("unexpected result: %v %v %v", url, parsedPin, err)
+	}
+
+	if url, parsedPin, err := Parse("https://example.com/build.json"); err != nil || url != "https://example.com/build.json" || parsedPin != "" {
+		t.Fatalf("unexpected result for unpinned url: %v %v %v", url, parsedPin, err)
+	}
+
+	for _, bad := range []string{"https://example.com/a#sha1=abcd", "https://example.com/a#sha256=abcd"} {
+		if _, _, err := Parse(bad); err == nil {
+			t.Errorf("expected error for %v", bad)
+		}
+	}
+}
+
+func TestFetch(t *testing.T) {
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/flaky":
+			attempts++
+			if attempts < 3 {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+		case "/missing":
+			attempts++
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		_, _ = w.Write([]byte("attestation"))
+	}))
+	defer server.Close()
+
+	opts := Options{Client: server.Client(), MaxSize: 1024, Retries: 3}
+	body, err := Fetch(context.Background(), server.URL+"/flaky", opts)
+	if err != nil || string(body) != "attestation" || attempts != 3 {
+		t.Fatalf("unexpected result after %d attempts: %q %v", attempts, body, err)
+	}
+
+	attempts = 0
+	_, err = Fetch(context.Background(), server.URL+"/missing", opts)
+	if !IsNotFound(err) || attempts != 1 {
+		t.Fatalf("expected a 404 to fail without retrying, got %v after %d attempts", err, attempts)
+	}
+
+	attempts = 0
+	opts.Retries = 0
+	if _, err := Fetch(context.Background(), server.URL+"/flaky", opts); err == nil || IsNotFound(err) {
+		t.Fatalf("expected a 503 to fail as something other than not found, got %v", err)
+	}
+
+	opts.Retries = 3
+
+	digest := sha256.Sum256([]byte("attestation"))
+	if _, err := Fetch(context.Background(), server.URL+"/ok#sha256="+hex.EncodeToString(digest[:]), opts); err != nil {
+		t.Fatalf("unexpected error with matching pin: %v", err)
+	}
+
+	wrong := sha256.Sum256([]byte("other"))
+	_, err = Fetch(context.Background(), server.URL+"/ok#sha256="+hex.EncodeToString(wrong[:]), opts)
+	if !errors.As(err, &ErrDigestMismatch{}) {
+		t.Fatalf("expected digest mismatch, got %v", err)
+	}
+
+	opts.MaxSize = 4
+	_, err = Fetch(context.Background(), server.URL+"/ok", opts)
+	if !errors.As(err, &ErrTooLarge{}) {
+		t.Fatalf("expected body to be too large, got %v", err)
+	}
+}
+
+func TestFetchNetrc(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if username, password, ok := r.BasicAuth(); !ok || username != "builder" || password != "s3cret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		_, _ = w.Write([]byte("policy"))
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "netrc")
+	host := strings.TrimPrefix(server.URL, "https://")
+	if err := os.WriteFile(path, []byte("machine "+host+" login builder password s3cret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("NETRC", path)
+	body, err := Fetch(context.Background(), server.URL+"/policy.json", Options{Client: server.Client()})
+	if err != nil || string(body) != "policy" {
+		t.Fatalf("expected the request to authenticate with .netrc, got %q %v", body, err)
+	}
+}
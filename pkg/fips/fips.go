@@ -0,0 +1,141 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fips
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"fmt"
+
+	"github.com/testifysec/go-witness/cryptoutil"
+)
+
+const minRSABits = 2048
+
+var enabled = buildTag
+
+type ErrNotApproved struct {
+	Reason string
+}
+
+func (e ErrNotApproved) Error() string {
+	return fmt.Sprintf("not approved for use in fips mode: %v", e.Reason)
+}
+
+// Enable turns on FIPS mode for the lifetime of the process. Binaries built with the fips tag are always in FIPS mode.
+func Enable() {
+	enabled = true
+}
+
+// Enabled returns true if witness is restricted to FIPS 140-2 approved algorithms.
+func Enabled() bool {
+	return enabled
+}
+
+// BuildTag returns true if the binary was built with the fips build tag.
+func BuildTag() bool {
+	return buildTag
+}
+
+// BoringCrypto returns true if the binary was built against a BoringCrypto toolchain.
+func BoringCrypto() bool {
+	return boringCrypto
+}
+
+// CheckPublicKey returns an error if the public key's algorithm or size is not approved by FIPS 186-4.
+func CheckPublicKey(pub interface{}) error {
+	switch key := pub.(type) {
+	case *rsa.PublicKey:
+		if key.N.BitLen() < minRSABits {
+			return ErrNotApproved{Reason: fmt.Sprintf("rsa key size %d is less than %d bits", key.N.BitLen(), minRSABits)}
+		}
+
+	case *ecdsa.PublicKey:
+		switch key.Curve {
+		case elliptic.P256(), elliptic.P384(), elliptic.P521():
+		default:
+			return ErrNotApproved{Reason: fmt.Sprintf("ecdsa curve %v", key.Curve.Params().Name)}
+		}
+
+	case ed25519.PublicKey:
+		return ErrNotApproved{Reason: "ed25519 keys"}
+
+	case *x509.Certificate:
+		return CheckCertificate(key)
+
+	default:
+		return ErrNotApproved{Reason: fmt.Sprintf("key type %T", pub)}
+	}
+
+	return nil
+}
+
+// CheckCertificate returns an error if the certificate's public key or signature algorithm is not approved.
+func CheckCertificate(cert *x509.Certificate) error {
+	switch cert.SignatureAlgorithm {
+	case x509.SHA256WithRSA, x509.SHA384WithRSA, x509.SHA512WithRSA,
+		x509.SHA256WithRSAPSS, x509.SHA384WithRSAPSS, x509.SHA512WithRSAPSS,
+		x509.ECDSAWithSHA256, x509.ECDSAWithSHA384, x509.ECDSAWithSHA512:
+	default:
+		return ErrNotApproved{Reason: fmt.Sprintf("certificate %v is signed with %v", cert.Subject.CommonName, cert.SignatureAlgorithm)}
+	}
+
+	return CheckPublicKey(cert.PublicKey)
+}
+
+// CheckVerifier returns an error if the verifier's key is not approved.
+func CheckVerifier(verifier cryptoutil.Verifier) error {
+	if x509Verifier, ok := verifier.(*cryptoutil.X509Verifier); ok {
+		return CheckCertificate(x509Verifier.Certificate())
+	}
+
+	keyBytes, err := verifier.Bytes()
+	if err != nil {
+		return err
+	}
+
+	key, err := cryptoutil.TryParseKeyFromReader(bytes.NewReader(keyBytes))
+	if err != nil {
+		return err
+	}
+
+	return CheckPublicKey(key)
+}
+
+// CheckSigner returns an error if the signer's key is not approved.
+func CheckSigner(signer cryptoutil.Signer) error {
+	if trustBundler, ok := signer.(cryptoutil.TrustBundler); ok && trustBundler.Certificate() != nil {
+		if err := CheckCertificate(trustBundler.Certificate()); err != nil {
+			return err
+		}
+
+		for _, intermediate := range trustBundler.Intermediates() {
+			if err := CheckCertificate(intermediate); err != nil {
+				return err
+			}
+		}
+	}
+
+	verifier, err := signer.Verifier()
+	if err != nil {
+		return err
+	}
+
+	return CheckVerifier(verifier)
+}
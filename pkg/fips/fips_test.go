@@ -0,0 +1,79 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fips
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+
+	"github.com/testifysec/go-witness/cryptoutil"
+)
+
+func TestCheckSigner(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	smallRSAKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name     string
+		signer   cryptoutil.Signer
+		approved bool
+	}{
+		{"rsa 2048", cryptoutil.NewRSASigner(rsaKey, crypto.SHA256), true},
+		{"rsa 1024", cryptoutil.NewRSASigner(smallRSAKey, crypto.SHA256), false},
+		{"ecdsa p256", cryptoutil.NewECDSASigner(ecKey, crypto.SHA256), true},
+		{"ed25519", cryptoutil.NewED25519Signer(edKey), false},
+	}
+
+	for _, c := range cases {
+		err := CheckSigner(c.signer)
+		if c.approved && err != nil {
+			t.Errorf("%v: unexpected error: %v", c.name, err)
+		}
+
+		if !c.approved && err == nil {
+			t.Errorf("%v: expected signer to be rejected", c.name)
+		}
+	}
+}
+
+func TestEnable(t *testing.T) {
+	defer func() { enabled = buildTag }()
+	Enable()
+	if !Enabled() {
+		t.Errorf("expected fips mode to be enabled")
+	}
+}
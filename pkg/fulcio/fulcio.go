@@ -0,0 +1,209 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package fulcio signs with ephemeral keys certified by a Sigstore Fulcio CA. The key only exists in memory, and its
+// certificate binds it to the OIDC identity of whoever requested it, such as a CI job or a user who logged in.
+package fulcio
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/testifysec/go-witness/cryptoutil"
+)
+
+// PublicServerURL is the address of Sigstore's public Fulcio instance.
+const PublicServerURL = "https://fulcio.sigstore.dev"
+
+const signingCertPath = "/api/v1/signingCert"
+
+// Options configures how a signing certificate is requested.
+type Options struct {
+	// URL is the address of the Fulcio server.
+	URL string
+	// Token is an OIDC identity token to authenticate with. If empty one is found with IDToken.
+	Token string
+	// OIDCIssuer and OIDCClientID configure the interactive login used when no other token is available.
+	OIDCIssuer   string
+	OIDCClientID string
+	HTTPClient   *http.Client
+}
+
+type publicKey struct {
+	Content   []byte `json:"content"`
+	Algorithm string `json:"algorithm"`
+}
+
+type certificateRequest struct {
+	PublicKey          publicKey `json:"publicKey"`
+	SignedEmailAddress []byte    `json:"signedEmailAddress"`
+}
+
+// Signer generates an ECDSA P-256 key and requests a certificate for it from Fulcio, authenticated with an OIDC
+// identity token. The returned signer embeds the certificate and the intermediates Fulcio returned in the signatures it
+// makes, so envelopes verify against Fulcio's root. The certificate is short-lived, so envelopes signed with it should
+// be uploaded to Rekor to be verified after it expires.
+func Signer(ctx context.Context, opts Options) (cryptoutil.Signer, error) {
+	token := opts.Token
+	if token == "" {
+		var err error
+		token, err = IDToken(ctx, opts.OIDCIssuer, opts.OIDCClientID, opts.HTTPClient)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	subject, err := tokenSubject(token)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate ephemeral key: %w", err)
+	}
+
+	// fulcio requires proof of possession of the key, made by signing the token's subject
+	digest := sha256.Sum256([]byte(subject))
+	proof, err := ecdsa.SignASN1(rand.Reader, key, digest[:])
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign proof of possession: %w", err)
+	}
+
+	pubBytes, err := x509.MarshalPKIXPublicKey(key.Public())
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal ephemeral public key: %w", err)
+	}
+
+	chain, err := signingCert(ctx, opts, token, certificateRequest{
+		PublicKey:          publicKey{Content: pubBytes, Algorithm: "ecdsa"},
+		SignedEmailAddress: proof,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	leaf, intermediates, err := parseChain(chain)
+	if err != nil {
+		return nil, err
+	}
+
+	if !publicKeysEqual(leaf.PublicKey, key.Public()) {
+		return nil, fmt.Errorf("fulcio issued a certificate for a different key")
+	}
+
+	return cryptoutil.NewSigner(key, cryptoutil.SignWithCertificate(leaf), cryptoutil.SignWithIntermediates(intermediates))
+}
+
+// signingCert requests a certificate and returns the PEM encoded chain Fulcio responds with, leaf first.
+func signingCert(ctx context.Context, opts Options, token string, cr certificateRequest) ([]byte, error) {
+	body, err := json.Marshal(cr)
+	if err != nil {
+		return nil, err
+	}
+
+	url := strings.TrimSuffix(opts.URL, "/") + signingCertPath
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := httpClient(opts.HTTPClient).Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to request signing certificate from %v: %w", opts.URL, err)
+	}
+
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read signing certificate from %v: %w", opts.URL, err)
+	}
+
+	if resp.StatusCode != http.StatusCreated {
+		return nil, fmt.Errorf("fulcio returned %v: %v", resp.Status, strings.TrimSpace(string(respBody)))
+	}
+
+	return respBody, nil
+}
+
+// parseChain splits Fulcio's chain into the leaf certificate and the intermediates needed to link it to a root. Self
+// signed certificates are left out, since the verifier must already trust the root.
+func parseChain(chain []byte) (*x509.Certificate, []*x509.Certificate, error) {
+	certs := []*x509.Certificate{}
+	for rest := bytes.TrimSpace(chain); len(rest) > 0; rest = bytes.TrimSpace(rest) {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil || block.Type != "CERTIFICATE" {
+			return nil, nil, fmt.Errorf("failed to parse certificate chain from fulcio")
+		}
+
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to parse certificate from fulcio: %w", err)
+		}
+
+		certs = append(certs, cert)
+	}
+
+	if len(certs) == 0 {
+		return nil, nil, fmt.Errorf("fulcio did not return a certificate")
+	}
+
+	intermediates := []*x509.Certificate{}
+	for _, cert := range certs[1:] {
+		if bytes.Equal(cert.RawIssuer, cert.RawSubject) && cert.CheckSignatureFrom(cert) == nil {
+			continue
+		}
+
+		intermediates = append(intermediates, cert)
+	}
+
+	return certs[0], intermediates, nil
+}
+
+func publicKeysEqual(a, b crypto.PublicKey) bool {
+	aBytes, err := x509.MarshalPKIXPublicKey(a)
+	if err != nil {
+		return false
+	}
+
+	bBytes, err := x509.MarshalPKIXPublicKey(b)
+	if err != nil {
+		return false
+	}
+
+	return bytes.Equal(aBytes, bBytes)
+}
+
+func httpClient(c *http.Client) *http.Client {
+	if c == nil {
+		return http.DefaultClient
+	}
+
+	return c
+}
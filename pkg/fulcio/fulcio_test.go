@@ -0,0 +1,306 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fulcio
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/testifysec/go-witness/dsse"
+)
+
+type testCA struct {
+	root         *x509.Certificate
+	intermediate *x509.Certificate
+	key          *ecdsa.PrivateKey
+}
+
+func newTestCA(t *testing.T) testCA {
+	rootKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	root := createCert(t, &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "fulcio root"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}, nil, &rootKey.PublicKey, rootKey)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	intermediate := createCert(t, &x509.Certificate{
+		SerialNumber:          big.NewInt(2),
+		Subject:               pkix.Name{CommonName: "fulcio intermediate"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}, root, &key.PublicKey, rootKey)
+
+	return testCA{root: root, intermediate: intermediate, key: key}
+}
+
+func createCert(t *testing.T, template, parent *x509.Certificate, pub interface{}, priv *ecdsa.PrivateKey) *x509.Certificate {
+	if parent == nil {
+		parent = template
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, pub, priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return cert
+}
+
+// fakeFulcio issues certificates for the email of the bearer token after checking the proof of possession.
+func fakeFulcio(t *testing.T, ca testCA) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != signingCertPath {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		subject, err := tokenSubject(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+
+		cr := certificateRequest{}
+		if err := json.NewDecoder(r.Body).Decode(&cr); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		pub, err := x509.ParsePKIXPublicKey(cr.PublicKey.Content)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		digest := sha256.Sum256([]byte(subject))
+		if !ecdsa.VerifyASN1(pub.(*ecdsa.PublicKey), digest[:], cr.SignedEmailAddress) {
+			http.Error(w, "invalid proof of possession", http.StatusBadRequest)
+			return
+		}
+
+		leaf := createCert(t, &x509.Certificate{
+			SerialNumber:   big.NewInt(3),
+			NotBefore:      time.Now().Add(-time.Minute),
+			NotAfter:       time.Now().Add(10 * time.Minute),
+			KeyUsage:       x509.KeyUsageDigitalSignature,
+			ExtKeyUsage:    []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+			EmailAddresses: []string{subject},
+		}, ca.intermediate, pub, ca.key)
+
+		w.WriteHeader(http.StatusCreated)
+		for _, cert := range []*x509.Certificate{leaf, ca.intermediate, ca.root} {
+			_ = pem.Encode(w, &pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+		}
+	}))
+}
+
+func testToken(t *testing.T, claims map[string]interface{}) string {
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	encode := base64.RawURLEncoding.EncodeToString
+	return encode([]byte(`{"alg":"RS256"}`)) + "." + encode(payload) + "." + encode([]byte("signature"))
+}
+
+func TestSigner(t *testing.T) {
+	ca := newTestCA(t)
+	server := fakeFulcio(t, ca)
+	defer server.Close()
+
+	token := testToken(t, map[string]interface{}{"sub": "1234", "email": "builder@example.com", "exp": time.Now().Add(time.Hour).Unix()})
+	signer, err := Signer(context.Background(), Options{URL: server.URL, Token: token})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	env, err := dsse.Sign("text/plain", bytes.NewReader([]byte("payload")), signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(env.Signatures) != 1 || len(env.Signatures[0].Certificate) == 0 {
+		t.Fatalf("expected the signature to embed the certificate")
+	}
+
+	if len(env.Signatures[0].Intermediates) != 1 {
+		t.Fatalf("expected only the intermediate to be embedded, got %v certificates", len(env.Signatures[0].Intermediates))
+	}
+
+	verifiers, err := env.Verify(dsse.WithRoots([]*x509.Certificate{ca.root}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(verifiers) != 1 {
+		t.Fatalf("expected 1 verifier, got %v", len(verifiers))
+	}
+
+	other := newTestCA(t)
+	if _, err := env.Verify(dsse.WithRoots([]*x509.Certificate{other.root})); err == nil {
+		t.Fatalf("expected verification against another root to fail")
+	}
+}
+
+func TestSignerRejected(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unsupported issuer", http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	token := testToken(t, map[string]interface{}{"sub": "1234"})
+	_, err := Signer(context.Background(), Options{URL: server.URL, Token: token})
+	if err == nil || !strings.Contains(err.Error(), "unsupported issuer") {
+		t.Fatalf("expected fulcio's error, got %v", err)
+	}
+}
+
+func TestIDToken(t *testing.T) {
+	ambient := testToken(t, map[string]interface{}{"sub": "repo:testifysec/witness:ref:refs/heads/main"})
+	actions := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer request-token" || r.URL.Query().Get("audience") != Audience {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		_ = json.NewEncoder(w).Encode(map[string]string{"value": ambient})
+	}))
+	defer actions.Close()
+
+	interactiveIssuer := ""
+	defer func(original func(string, string) (string, error)) { interactiveToken = original }(interactiveToken)
+	interactiveToken = func(issuer, clientID string) (string, error) {
+		interactiveIssuer = issuer
+		return "interactive", nil
+	}
+
+	tests := []struct {
+		name     string
+		env      map[string]string
+		expected string
+	}{
+		{
+			name:     "environment",
+			env:      map[string]string{TokenEnv: "from-env", "ACTIONS_ID_TOKEN_REQUEST_URL": actions.URL, "ACTIONS_ID_TOKEN_REQUEST_TOKEN": "request-token"},
+			expected: "from-env",
+		},
+		{
+			name:     "github actions",
+			env:      map[string]string{"ACTIONS_ID_TOKEN_REQUEST_URL": actions.URL + "?api-version=2.0", "ACTIONS_ID_TOKEN_REQUEST_TOKEN": "request-token"},
+			expected: ambient,
+		},
+		{
+			name:     "interactive",
+			expected: "interactive",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			for _, name := range []string{TokenEnv, "ACTIONS_ID_TOKEN_REQUEST_URL", "ACTIONS_ID_TOKEN_REQUEST_TOKEN"} {
+				t.Setenv(name, test.env[name])
+			}
+
+			token, err := IDToken(context.Background(), "", "", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if token != test.expected {
+				t.Fatalf("expected token %v, got %v", test.expected, token)
+			}
+		})
+	}
+
+	if interactiveIssuer != DefaultOIDCIssuer {
+		t.Fatalf("expected interactive login through %v, got %v", DefaultOIDCIssuer, interactiveIssuer)
+	}
+}
+
+func TestTokenSubject(t *testing.T) {
+	tests := []struct {
+		claims   map[string]interface{}
+		expected string
+		err      string
+	}{
+		{claims: map[string]interface{}{"sub": "1234", "email": "builder@example.com"}, expected: "builder@example.com"},
+		{claims: map[string]interface{}{"sub": "https://github.com/testifysec/witness/.github/workflows/release.yml@refs/heads/main"}, expected: "https://github.com/testifysec/witness/.github/workflows/release.yml@refs/heads/main"},
+		{claims: map[string]interface{}{"sub": "1234", "exp": time.Now().Add(-time.Minute).Unix()}, err: "expired"},
+		{claims: map[string]interface{}{}, err: "does not have a subject"},
+	}
+
+	for i, test := range tests {
+		t.Run(fmt.Sprint(i), func(t *testing.T) {
+			subject, err := tokenSubject(testToken(t, test.claims))
+			if test.err != "" {
+				if err == nil || !strings.Contains(err.Error(), test.err) {
+					t.Fatalf("expected error containing %q, got %v", test.err, err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if subject != test.expected {
+				t.Fatalf("expected subject %v, got %v", test.expected, subject)
+			}
+		})
+	}
+
+	if _, err := tokenSubject("not-a-jwt"); err == nil {
+		t.Fatalf("expected an error for a token that is not a jwt")
+	}
+}
@@ -0,0 +1,159 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package fulcio
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/sigstore/sigstore/pkg/oauthflow"
+)
+
+const (
+	// Audience is the audience Fulcio requires identity tokens to be issued for.
+	Audience = "sigstore"
+	// DefaultOIDCIssuer and DefaultOIDCClientID are used for interactive logins when no issuer is configured.
+	DefaultOIDCIssuer   = "https://oauth2.sigstore.dev/auth"
+	DefaultOIDCClientID = "sigstore"
+
+	// TokenEnv holds an identity token provided by the environment, such as one requested with GitLab's id_tokens.
+	TokenEnv = "SIGSTORE_ID_TOKEN"
+)
+
+// interactiveToken logs in through the issuer in a browser. It is a variable so tests can replace it.
+var interactiveToken = func(issuer, clientID string) (string, error) {
+	tok, err := oauthflow.OIDConnect(issuer, clientID, "", oauthflow.DefaultIDTokenGetter)
+	if err != nil {
+		return "", err
+	}
+
+	return tok.RawString, nil
+}
+
+// IDToken finds an OIDC identity token to request a certificate with. Ambient credentials are preferred so CI jobs
+// sign without any interaction: a token in SIGSTORE_ID_TOKEN is used first, then one requested from GitHub Actions when
+// the job has the id-token: write permission. Otherwise the user logs in interactively through issuer.
+func IDToken(ctx context.Context, issuer, clientID string, c *http.Client) (string, error) {
+	if token := os.Getenv(TokenEnv); token != "" {
+		return token, nil
+	}
+
+	requestURL := os.Getenv("ACTIONS_ID_TOKEN_REQUEST_URL")
+	requestToken := os.Getenv("ACTIONS_ID_TOKEN_REQUEST_TOKEN")
+	if requestURL != "" && requestToken != "" {
+		return githubActionsToken(ctx, requestURL, requestToken, c)
+	}
+
+	if issuer == "" {
+		issuer = DefaultOIDCIssuer
+	}
+
+	if clientID == "" {
+		clientID = DefaultOIDCClientID
+	}
+
+	token, err := interactiveToken(issuer, clientID)
+	if err != nil {
+		return "", fmt.Errorf("failed to get identity token from %v: %w", issuer, err)
+	}
+
+	return token, nil
+}
+
+// githubActionsToken requests an identity token for the running workflow from GitHub Actions.
+func githubActionsToken(ctx context.Context, requestURL, requestToken string, c *http.Client) (string, error) {
+	u, err := url.Parse(requestURL)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse ACTIONS_ID_TOKEN_REQUEST_URL: %w", err)
+	}
+
+	query := u.Query()
+	query.Set("audience", Audience)
+	u.RawQuery = query.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return "", err
+	}
+
+	req.Header.Set("Authorization", "Bearer "+requestToken)
+	resp, err := httpClient(c).Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to request identity token from github actions: %w", err)
+	}
+
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("github actions returned %v when requesting an identity token", resp.Status)
+	}
+
+	response := struct {
+		Value string `json:"value"`
+	}{}
+
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return "", fmt.Errorf("failed to decode identity token from github actions: %w", err)
+	}
+
+	if response.Value == "" {
+		return "", fmt.Errorf("github actions did not return an identity token")
+	}
+
+	return response.Value, nil
+}
+
+// tokenSubject returns the identity Fulcio will certify for the token: its email if it has one, otherwise its
+// subject. The token's signature is left for Fulcio to verify.
+func tokenSubject(token string) (string, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return "", fmt.Errorf("identity token is not a jwt")
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return "", fmt.Errorf("failed to decode identity token: %w", err)
+	}
+
+	claims := struct {
+		Subject string `json:"sub"`
+		Email   string `json:"email"`
+		Expiry  int64  `json:"exp"`
+	}{}
+
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return "", fmt.Errorf("failed to decode identity token claims: %w", err)
+	}
+
+	if claims.Expiry != 0 && time.Now().After(time.Unix(claims.Expiry, 0)) {
+		return "", fmt.Errorf("identity token expired at %v", time.Unix(claims.Expiry, 0).UTC().Format(time.RFC3339))
+	}
+
+	if claims.Email != "" {
+		return claims.Email, nil
+	}
+
+	if claims.Subject == "" {
+		return "", fmt.Errorf("identity token does not have a subject")
+	}
+
+	return claims.Subject, nil
+}
@@ -0,0 +1,173 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package gcpkms signs with asymmetric keys held in Google Cloud KMS. Credentials come from Google's application
+// default credentials, such as GOOGLE_APPLICATION_CREDENTIALS or the metadata server.
+package gcpkms
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/witness/pkg/kms"
+	"golang.org/x/oauth2/google"
+)
+
+const (
+	// Scheme is the kms reference scheme Cloud KMS keys are registered under.
+	Scheme = "gcpkms"
+	// ReferenceScheme prefixes references to Cloud KMS key versions.
+	ReferenceScheme = Scheme + "://"
+
+	defaultEndpoint = "https://cloudkms.googleapis.com"
+	cloudKMSScope   = "https://www.googleapis.com/auth/cloudkms"
+)
+
+var keyVersionPattern = regexp.MustCompile(`^projects/[^/]+/locations/[^/]+/keyRings/[^/]+/cryptoKeys/[^/]+/cryptoKeyVersions/[^/]+$`)
+
+// supportedAlgorithms are the Cloud KMS algorithms whose signatures witness can verify.
+var supportedAlgorithms = map[string]bool{
+	"EC_SIGN_P256_SHA256":      true,
+	"RSA_SIGN_PSS_2048_SHA256": true,
+	"RSA_SIGN_PSS_3072_SHA256": true,
+	"RSA_SIGN_PSS_4096_SHA256": true,
+}
+
+func init() {
+	kms.Register(Scheme, New)
+}
+
+// Client calls the Cloud KMS REST API. HTTPClient must add credentials to its requests.
+type Client struct {
+	Endpoint   string
+	HTTPClient *http.Client
+}
+
+// ParseReference returns the resource name of the key version a reference names, written as
+// gcpkms://projects/<project>/locations/<location>/keyRings/<ring>/cryptoKeys/<key>/cryptoKeyVersions/<version>.
+func ParseReference(ref string) (string, error) {
+	if !strings.HasPrefix(ref, ReferenceScheme) {
+		return "", fmt.Errorf("kms reference %v does not start with %v", ref, ReferenceScheme)
+	}
+
+	name := strings.TrimPrefix(ref, ReferenceScheme)
+	if !keyVersionPattern.MatchString(name) {
+		return "", fmt.Errorf("kms reference %v does not name a crypto key version", ref)
+	}
+
+	return name, nil
+}
+
+// New returns a signer for the referenced key version using application default credentials.
+func New(ctx context.Context, ref string) (kms.SignerVerifier, error) {
+	name, err := ParseReference(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	httpClient, err := google.DefaultClient(ctx, cloudKMSScope)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find google cloud credentials: %w", err)
+	}
+
+	return Client{HTTPClient: httpClient}.SignerVerifier(ctx, name)
+}
+
+// SignerVerifier returns a signer for the key version. The version must use one of the EC_SIGN_P256_SHA256 or
+// RSA_SIGN_PSS_*_SHA256 algorithms.
+func (c Client) SignerVerifier(ctx context.Context, name string) (kms.SignerVerifier, error) {
+	publicKey := struct {
+		Pem       string `json:"pem"`
+		Algorithm string `json:"algorithm"`
+	}{}
+
+	if err := c.do(ctx, http.MethodGet, name+"/publicKey", nil, &publicKey); err != nil {
+		return nil, fmt.Errorf("failed to get public key of %v: %w", name, err)
+	}
+
+	if !supportedAlgorithms[publicKey.Algorithm] {
+		return nil, fmt.Errorf("%v uses unsupported algorithm %v", name, publicKey.Algorithm)
+	}
+
+	pub, err := cryptoutil.TryParseKeyFromReader(strings.NewReader(publicKey.Pem))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse public key of %v: %w", name, err)
+	}
+
+	return kms.NewSignerVerifier(pub, func(digest []byte) ([]byte, error) {
+		request := map[string]interface{}{
+			"digest": map[string]string{"sha256": base64.StdEncoding.EncodeToString(digest)},
+		}
+
+		signed := struct {
+			Signature []byte `json:"signature"`
+		}{}
+
+		if err := c.do(ctx, http.MethodPost, name+":asymmetricSign", request, &signed); err != nil {
+			return nil, fmt.Errorf("failed to sign with %v: %w", name, err)
+		}
+
+		return signed.Signature, nil
+	})
+}
+
+func (c Client) do(ctx context.Context, method, path string, body interface{}, out interface{}) error {
+	endpoint := c.Endpoint
+	if endpoint == "" {
+		endpoint = defaultEndpoint
+	}
+
+	var reqBody io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+
+		reqBody = bytes.NewReader(encoded)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, fmt.Sprintf("%v/v1/%v", strings.TrimSuffix(endpoint, "/"), path), reqBody)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		response := struct {
+			Error struct {
+				Message string `json:"message"`
+			} `json:"error"`
+		}{}
+
+		_ = json.NewDecoder(resp.Body).Decode(&response)
+		return fmt.Errorf("cloud kms returned %v: %v", resp.Status, response.Error.Message)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,116 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gcpkms
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+const testKeyVersion = "projects/witness/locations/global/keyRings/ci/cryptoKeys/signing/cryptoKeyVersions/1"
+
+func fakeCloudKMS(t *testing.T, priv *rsa.PrivateKey, algorithm string) *httptest.Server {
+	der, err := x509.MarshalPKIXPublicKey(&priv.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/"+testKeyVersion+"/publicKey", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]string{
+			"pem":       string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})),
+			"algorithm": algorithm,
+		})
+	})
+
+	mux.HandleFunc("/v1/"+testKeyVersion+":asymmetricSign", func(w http.ResponseWriter, r *http.Request) {
+		request := struct {
+			Digest struct {
+				SHA256 string `json:"sha256"`
+			} `json:"digest"`
+		}{}
+
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Fatal(err)
+		}
+
+		digest, err := base64.StdEncoding.DecodeString(request.Digest.SHA256)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		sig, err := rsa.SignPSS(rand.Reader, priv, crypto.SHA256, digest, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_ = json.NewEncoder(w).Encode(map[string]string{"signature": base64.StdEncoding.EncodeToString(sig)})
+	})
+
+	return httptest.NewServer(mux)
+}
+
+func TestParseReference(t *testing.T) {
+	name, err := ParseReference(ReferenceScheme + testKeyVersion)
+	if err != nil || name != testKeyVersion {
+		t.Errorf("unexpected key version %v: %v", name, err)
+	}
+
+	for _, invalid := range []string{"gcpkms://projects/witness/locations/global/keyRings/ci/cryptoKeys/signing", "awskms:///" + testKeyVersion} {
+		if _, err := ParseReference(invalid); err == nil {
+			t.Errorf("expected %v to be rejected", invalid)
+		}
+	}
+}
+
+func TestSignerVerifier(t *testing.T) {
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := fakeCloudKMS(t, priv, "RSA_SIGN_PSS_2048_SHA256")
+	defer server.Close()
+
+	signer, err := Client{Endpoint: server.URL, HTTPClient: server.Client()}.SignerVerifier(context.Background(), testKeyVersion)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sig, err := signer.Sign(bytes.NewReader([]byte("attestation")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := signer.Verify(bytes.NewReader([]byte("attestation")), sig); err != nil {
+		t.Errorf("expected signature to verify: %v", err)
+	}
+
+	pkcs1 := fakeCloudKMS(t, priv, "RSA_SIGN_PKCS1_2048_SHA256")
+	defer pkcs1.Close()
+	if _, err := (Client{Endpoint: pkcs1.URL, HTTPClient: pkcs1.Client()}).SignerVerifier(context.Background(), testKeyVersion); err == nil {
+		t.Error("expected PKCS1 key to be rejected")
+	}
+}
@@ -0,0 +1,136 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package github
+
+import (
+	"context"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/url"
+
+	"github.com/testifysec/go-witness/dsse"
+)
+
+// Attestation is the subset of an attestation returned by the GitHub API that witness uses.
+type Attestation struct {
+	Bundle       Bundle `json:"bundle"`
+	RepositoryID int64  `json:"repository_id"`
+}
+
+// Bundle is a sigstore bundle containing a DSSE envelope and the material needed to verify it.
+type Bundle struct {
+	MediaType            string               `json:"mediaType"`
+	VerificationMaterial VerificationMaterial `json:"verificationMaterial"`
+	DSSEEnvelope         *BundleEnvelope      `json:"dsseEnvelope"`
+}
+
+type VerificationMaterial struct {
+	Certificate          *RawCertificate `json:"certificate"`
+	X509CertificateChain *struct {
+		Certificates []RawCertificate `json:"certificates"`
+	} `json:"x509CertificateChain"`
+}
+
+type RawCertificate struct {
+	RawBytes []byte `json:"rawBytes"`
+}
+
+type BundleEnvelope struct {
+	Payload     []byte `json:"payload"`
+	PayloadType string `json:"payloadType"`
+	Signatures  []struct {
+		Sig   []byte `json:"sig"`
+		KeyID string `json:"keyid"`
+	} `json:"signatures"`
+}
+
+type attestationsResponse struct {
+	Attestations []Attestation `json:"attestations"`
+}
+
+type ErrNoDSSEEnvelope struct {
+	MediaType string
+}
+
+func (e ErrNoDSSEEnvelope) Error() string {
+	return fmt.Sprintf("bundle %v does not contain a dsse envelope", e.MediaType)
+}
+
+// FetchAttestations returns the attestations GitHub holds for the subject digest in the repository (owner/repo).
+// digest must be in the form algorithm:hex, for example sha256:abc...
+func (c Client) FetchAttestations(ctx context.Context, repository, digest string) ([]Attestation, error) {
+	if err := checkRepository(repository); err != nil {
+		return nil, err
+	}
+
+	attestations := attestationsResponse{}
+	err := c.get(ctx, fmt.Sprintf("/repos/%s/attestations/%s", repository, url.PathEscape(digest)), &attestations)
+	if errors.Is(err, ErrNotFound) {
+		return []Attestation{}, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to fetch attestations: %w", err)
+	}
+
+	return attestations.Attestations, nil
+}
+
+// Envelope converts the bundle into a DSSE envelope. The bundle's leaf certificate, and any intermediates, are
+// attached to each signature as PEM so the envelope can be verified against a policy's roots.
+func (b Bundle) Envelope() (dsse.Envelope, error) {
+	if b.DSSEEnvelope == nil {
+		return dsse.Envelope{}, ErrNoDSSEEnvelope{MediaType: b.MediaType}
+	}
+
+	var leaf []byte
+	intermediates := make([][]byte, 0)
+	if b.VerificationMaterial.Certificate != nil {
+		leaf = encodeCertificate(b.VerificationMaterial.Certificate.RawBytes)
+	} else if b.VerificationMaterial.X509CertificateChain != nil {
+		for i, cert := range b.VerificationMaterial.X509CertificateChain.Certificates {
+			if i == 0 {
+				leaf = encodeCertificate(cert.RawBytes)
+				continue
+			}
+
+			intermediates = append(intermediates, encodeCertificate(cert.RawBytes))
+		}
+	}
+
+	env := dsse.Envelope{
+		Payload:     b.DSSEEnvelope.Payload,
+		PayloadType: b.DSSEEnvelope.PayloadType,
+		Signatures:  make([]dsse.Signature, 0, len(b.DSSEEnvelope.Signatures)),
+	}
+
+	for _, sig := range b.DSSEEnvelope.Signatures {
+		env.Signatures = append(env.Signatures, dsse.Signature{
+			KeyID:         sig.KeyID,
+			Signature:     sig.Sig,
+			Certificate:   leaf,
+			Intermediates: intermediates,
+		})
+	}
+
+	return env, nil
+}
+
+func encodeCertificate(der []byte) []byte {
+	if len(der) == 0 {
+		return nil
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
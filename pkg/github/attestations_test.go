@@ -0,0 +1,126 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestFetchAttestations(t *testing.T) {
+	var gotPath, gotAuth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.EscapedPath()
+		gotAuth = r.Header.Get("Authorization")
+		if err := json.NewEncoder(w).Encode(map[string]interface{}{
+			"attestations": []map[string]interface{}{
+				{
+					"repository_id": 1,
+					"bundle": map[string]interface{}{
+						"mediaType": "application/vnd.dev.sigstore.bundle+json;version=0.2",
+						"verificationMaterial": map[string]interface{}{
+							"x509CertificateChain": map[string]interface{}{
+								"certificates": []map[string]interface{}{
+									{"rawBytes": []byte("leaf")},
+									{"rawBytes": []byte("intermediate")},
+								},
+							},
+						},
+						"dsseEnvelope": map[string]interface{}{
+							"payload":     []byte("payload"),
+							"payloadType": "application/vnd.in-toto+json",
+							"signatures":  []map[string]interface{}{{"sig": []byte("sig"), "keyid": ""}},
+						},
+					},
+				},
+			},
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}))
+	defer server.Close()
+
+	client := Client{APIURL: server.URL, Token: "token"}
+	attestations, err := client.FetchAttestations(context.Background(), "testifysec/witness", "sha256:abc")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if gotPath != "/repos/testifysec/witness/attestations/sha256:abc" {
+		t.Errorf("unexpected request path: %v", gotPath)
+	}
+
+	if gotAuth != "Bearer token" {
+		t.Errorf("unexpected authorization header: %v", gotAuth)
+	}
+
+	if len(attestations) != 1 {
+		t.Fatalf("expected 1 attestation, got %d", len(attestations))
+	}
+
+	env, err := attestations[0].Bundle.Envelope()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if string(env.Payload) != "payload" || env.PayloadType != "application/vnd.in-toto+json" {
+		t.Errorf("unexpected envelope payload: %v %v", string(env.Payload), env.PayloadType)
+	}
+
+	if len(env.Signatures) != 1 || string(env.Signatures[0].Signature) != "sig" {
+		t.Fatalf("unexpected signatures: %+v", env.Signatures)
+	}
+
+	block, _ := pem.Decode(env.Signatures[0].Certificate)
+	if block == nil || string(block.Bytes) != "leaf" {
+		t.Errorf("expected leaf certificate to be pem encoded")
+	}
+
+	if len(env.Signatures[0].Intermediates) != 1 {
+		t.Errorf("expected 1 intermediate, got %d", len(env.Signatures[0].Intermediates))
+	}
+}
+
+func TestFetchAttestationsNotFound(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	attestations, err := Client{APIURL: server.URL}.FetchAttestations(context.Background(), "testifysec/witness", "sha256:abc")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(attestations) != 0 {
+		t.Errorf("expected no attestations, got %d", len(attestations))
+	}
+}
+
+func TestFetchAttestationsBadRepository(t *testing.T) {
+	if _, err := (Client{}).FetchAttestations(context.Background(), "witness", "sha256:abc"); err == nil {
+		t.Error("expected error for repository without owner")
+	}
+}
+
+func TestEnvelopeWithoutDSSE(t *testing.T) {
+	if _, err := (Bundle{MediaType: "message-signature"}).Envelope(); err == nil {
+		t.Error("expected error for bundle without dsse envelope")
+	}
+}
@@ -0,0 +1,51 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+)
+
+// BranchProtection returns the classic branch protection settings of the branch. Reading them requires a token with
+// administration read access to the repository.
+func (c Client) BranchProtection(ctx context.Context, repository, branch string) (json.RawMessage, error) {
+	if err := checkRepository(repository); err != nil {
+		return nil, err
+	}
+
+	protection := json.RawMessage{}
+	if err := c.get(ctx, fmt.Sprintf("/repos/%s/branches/%s/protection", repository, url.PathEscape(branch)), &protection); err != nil {
+		return nil, fmt.Errorf("failed to get branch protection: %w", err)
+	}
+
+	return protection, nil
+}
+
+// BranchRules returns the repository ruleset rules that apply to the branch.
+func (c Client) BranchRules(ctx context.Context, repository, branch string) (json.RawMessage, error) {
+	if err := checkRepository(repository); err != nil {
+		return nil, err
+	}
+
+	rules := json.RawMessage{}
+	if err := c.get(ctx, fmt.Sprintf("/repos/%s/rules/branches/%s", repository, url.PathEscape(branch)), &rules); err != nil {
+		return nil, fmt.Errorf("failed to get branch rules: %w", err)
+	}
+
+	return rules, nil
+}
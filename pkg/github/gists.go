@@ -0,0 +1,40 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package github
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+type gistFile struct {
+	Content string `json:"content"`
+}
+
+type gistUpdate struct {
+	Files map[string]gistFile `json:"files"`
+}
+
+// UpdateGistFile replaces the content of a file in the gist, creating the file if the gist does not have it.
+func (c Client) UpdateGistFile(ctx context.Context, id, filename, content string) error {
+	update := gistUpdate{Files: map[string]gistFile{filename: {Content: content}}}
+	if err := c.do(ctx, http.MethodPatch, "/gists/"+url.PathEscape(id), update, nil); err != nil {
+		return fmt.Errorf("failed to update gist %v: %w", id, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,62 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package github
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Repository is the subset of a repository's settings returned by the GitHub API that witness uses.
+type Repository struct {
+	Visibility string `json:"visibility"`
+	// SecurityAndAnalysis holds the status of the repository's security features, such as secret_scanning. It is
+	// only returned to tokens with admin or push access to the repository.
+	SecurityAndAnalysis map[string]struct {
+		Status string `json:"status"`
+	} `json:"security_and_analysis"`
+}
+
+// Repository returns the settings of the repository.
+func (c Client) Repository(ctx context.Context, repository string) (Repository, error) {
+	if err := checkRepository(repository); err != nil {
+		return Repository{}, err
+	}
+
+	repo := Repository{}
+	if err := c.get(ctx, fmt.Sprintf("/repos/%s", repository), &repo); err != nil {
+		return Repository{}, fmt.Errorf("failed to get repository: %w", err)
+	}
+
+	return repo, nil
+}
+
+// VulnerabilityAlerts reports whether dependabot alerts are enabled for the repository. GitHub also reports them as
+// disabled to tokens without admin read access to the repository.
+func (c Client) VulnerabilityAlerts(ctx context.Context, repository string) (bool, error) {
+	if err := checkRepository(repository); err != nil {
+		return false, err
+	}
+
+	err := c.get(ctx, fmt.Sprintf("/repos/%s/vulnerability-alerts", repository), nil)
+	if errors.Is(err, ErrNotFound) {
+		return false, nil
+	} else if err != nil {
+		return false, fmt.Errorf("failed to get vulnerability alerts: %w", err)
+	}
+
+	return true, nil
+}
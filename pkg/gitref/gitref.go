@@ -0,0 +1,100 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package gitref resolves artifacts that are refs in a git repository, such as release tags, to commit hashes.
+package gitref
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"strings"
+)
+
+const prefix = "git+"
+
+var commitHash = regexp.MustCompile(`^[0-9a-f]{40}$`)
+
+// Ref is a tag, branch, or commit in a remote repository.
+type Ref struct {
+	URL string
+	Ref string
+}
+
+func (r Ref) String() string {
+	return fmt.Sprintf("%s%s@%s", prefix, r.URL, r.Ref)
+}
+
+// IsRef returns true if artifact is a git artifact reference, such as git+https://github.com/org/repo@v1.2.3.
+func IsRef(artifact string) bool {
+	return strings.HasPrefix(artifact, prefix)
+}
+
+// Parse parses a git artifact reference in the form git+<repository url>@<ref>.
+func Parse(artifact string) (Ref, error) {
+	if !IsRef(artifact) {
+		return Ref{}, fmt.Errorf("%v is not a git artifact reference", artifact)
+	}
+
+	trimmed := strings.TrimPrefix(artifact, prefix)
+	i := strings.LastIndex(trimmed, "@")
+	if i <= 0 || i == len(trimmed)-1 || !strings.Contains(trimmed[:i], "://") {
+		return Ref{}, fmt.Errorf("git artifact reference %v must be in the form git+<repository url>@<ref>", artifact)
+	}
+
+	return Ref{URL: trimmed[:i], Ref: trimmed[i+1:]}, nil
+}
+
+// lsRemote is replaced in tests.
+var lsRemote = func(ctx context.Context, url string, patterns ...string) (string, error) {
+	args := append([]string{"ls-remote", "--", url}, patterns...)
+	out, err := exec.CommandContext(ctx, "git", args...).Output()
+	if err != nil {
+		return "", fmt.Errorf("failed to run git ls-remote %v: %w", url, err)
+	}
+
+	return string(out), nil
+}
+
+// Resolve returns the hash of the commit the ref points to. Annotated tags are peeled to the commit they tag. Refs
+// that are already full commit hashes are returned without contacting the repository.
+func Resolve(ctx context.Context, r Ref) (string, error) {
+	if commitHash.MatchString(r.Ref) {
+		return r.Ref, nil
+	}
+
+	tag := "refs/tags/" + r.Ref
+	branch := "refs/heads/" + r.Ref
+	out, err := lsRemote(ctx, r.URL, tag, tag+"^{}", branch)
+	if err != nil {
+		return "", err
+	}
+
+	refs := map[string]string{}
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 2 {
+			refs[fields[1]] = fields[0]
+		}
+	}
+
+	for _, name := range []string{tag + "^{}", tag, branch} {
+		if hash, ok := refs[name]; ok {
+			return hash, nil
+		}
+	}
+
+	return "", fmt.Errorf("ref %v not found in %v", r.Ref, r.URL)
+}
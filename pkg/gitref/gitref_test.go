@@ -0,0 +1,67 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package gitref
+
+import (
+	"context"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	r, err := Parse("git+ssh://git@github.com/testifysec/witness@v1.2.3")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if r.URL != "ssh://git@github.com/testifysec/witness" || r.Ref != "v1.2.3" {
+		t.Errorf("unexpected ref: %+v", r)
+	}
+
+	for _, invalid := range []string{"https://github.com/testifysec/witness@v1", "git+https://github.com/testifysec/witness", "git+https://github.com/testifysec/witness@"} {
+		if _, err := Parse(invalid); err == nil {
+			t.Errorf("expected %v to be invalid", invalid)
+		}
+	}
+}
+
+func TestResolve(t *testing.T) {
+	lsRemote = func(ctx context.Context, url string, patterns ...string) (string, error) {
+		return "1111111111111111111111111111111111111111\trefs/tags/v1.2.3\n" +
+			"2222222222222222222222222222222222222222\trefs/tags/v1.2.3^{}\n" +
+			"3333333333333333333333333333333333333333\trefs/heads/v1.2.3\n", nil
+	}
+
+	hash, err := Resolve(context.Background(), Ref{URL: "https://github.com/testifysec/witness", Ref: "v1.2.3"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if hash != "2222222222222222222222222222222222222222" {
+		t.Errorf("expected annotated tag to be peeled, got %v", hash)
+	}
+
+	lsRemote = func(ctx context.Context, url string, patterns ...string) (string, error) {
+		return "", nil
+	}
+
+	if _, err := Resolve(context.Background(), Ref{URL: "https://github.com/testifysec/witness", Ref: "v9"}); err == nil {
+		t.Error("expected missing ref to fail")
+	}
+
+	commit := "4444444444444444444444444444444444444444"
+	if hash, err := Resolve(context.Background(), Ref{URL: "https://github.com/testifysec/witness", Ref: commit}); err != nil || hash != commit {
+		t.Errorf("expected commit hash to resolve to itself: %v %v", hash, err)
+	}
+}
@@ -0,0 +1,174 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package groups resolves the members of directory groups, so policies can require approval from a member of a group
+// rather than listing individual identities.
+package groups
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Resolver returns the identities, such as email addresses, of a group's members.
+type Resolver interface {
+	Members(ctx context.Context, group string) ([]string, error)
+}
+
+// ErrUnknownGroup is returned when a group is not found in the directory or snapshot.
+type ErrUnknownGroup struct {
+	Group string
+}
+
+func (e ErrUnknownGroup) Error() string {
+	return fmt.Sprintf("unknown group %v", e.Group)
+}
+
+// ErrStaleMembership is returned when the directory can't be reached and the group's cached membership is too old to
+// use in its place.
+type ErrStaleMembership struct {
+	Group      string
+	ResolvedAt time.Time
+	Err        error
+}
+
+func (e ErrStaleMembership) Error() string {
+	return fmt.Sprintf("failed to resolve members of %v, and its membership resolved at %v is too old to use: %v", e.Group, e.ResolvedAt, e.Err)
+}
+
+func (e ErrStaleMembership) Unwrap() error {
+	return e.Err
+}
+
+// Membership is a group's members as resolved at a point in time.
+type Membership struct {
+	Members    []string  `json:"members"`
+	ResolvedAt time.Time `json:"resolvedAt"`
+}
+
+// Cache remembers the memberships returned by its resolver for TTL. While the resolver fails, an expired membership is
+// still used until it is MaxStale past its TTL, after which the group fails to resolve, so members removed from the
+// directory can't keep approving through an outage. If Resolver is nil only the cached memberships, such as those
+// loaded from a snapshot, are used and they never expire.
+type Cache struct {
+	Resolver Resolver
+	TTL      time.Duration
+	MaxStale time.Duration
+
+	mu          sync.Mutex
+	memberships map[string]Membership
+	now         func() time.Time
+}
+
+func NewCache(resolver Resolver, ttl, maxStale time.Duration) *Cache {
+	return &Cache{
+		Resolver:    resolver,
+		TTL:         ttl,
+		MaxStale:    maxStale,
+		memberships: make(map[string]Membership),
+		now:         time.Now,
+	}
+}
+
+// LoadSnapshot reads memberships previously written with WriteSnapshot into the cache.
+func (c *Cache) LoadSnapshot(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read group snapshot: %w", err)
+	}
+
+	memberships := make(map[string]Membership)
+	if err := json.Unmarshal(data, &memberships); err != nil {
+		return fmt.Errorf("failed to unmarshal group snapshot: %w", err)
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for group, membership := range memberships {
+		c.memberships[group] = membership
+	}
+
+	return nil
+}
+
+// WriteSnapshot writes the cached memberships to the file, for use when the directory can't be reached.
+func (c *Cache) WriteSnapshot(path string) error {
+	c.mu.Lock()
+	data, err := json.MarshalIndent(c.memberships, "", "  ")
+	c.mu.Unlock()
+	if err != nil {
+		return fmt.Errorf("failed to marshal group snapshot: %w", err)
+	}
+
+	return os.WriteFile(path, data, 0644)
+}
+
+func (c *Cache) Members(ctx context.Context, group string) ([]string, error) {
+	c.mu.Lock()
+	membership, ok := c.memberships[group]
+	c.mu.Unlock()
+	if ok && (c.Resolver == nil || c.now().Sub(membership.ResolvedAt) < c.TTL) {
+		return membership.Members, nil
+	}
+
+	if c.Resolver == nil {
+		return nil, ErrUnknownGroup{Group: group}
+	}
+
+	members, err := c.Resolver.Members(ctx, group)
+	if err != nil {
+		var unknown ErrUnknownGroup
+		if errors.As(err, &unknown) {
+			return nil, err
+		}
+
+		// fall back to a recently stale membership rather than failing while the directory is briefly unavailable
+		if ok && c.now().Sub(membership.ResolvedAt) < c.TTL+c.MaxStale {
+			return membership.Members, nil
+		}
+
+		if ok {
+			return nil, ErrStaleMembership{Group: group, ResolvedAt: membership.ResolvedAt, Err: err}
+		}
+
+		return nil, fmt.Errorf("failed to resolve members of %v: %w", group, err)
+	}
+
+	c.mu.Lock()
+	c.memberships[group] = Membership{Members: members, ResolvedAt: c.now()}
+	c.mu.Unlock()
+	return members, nil
+}
+
+// IsMember returns true if the identity is one of the group's members. Identities are compared case insensitively.
+func IsMember(ctx context.Context, resolver Resolver, group, identity string) (bool, error) {
+	members, err := resolver.Members(ctx, group)
+	if err != nil {
+		return false, err
+	}
+
+	for _, member := range members {
+		if strings.EqualFold(member, identity) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
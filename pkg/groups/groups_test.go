@@ -0,0 +1,182 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package groups
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type staticResolver struct {
+	members map[string][]string
+	calls   int
+	err     error
+}
+
+func (r *staticResolver) Members(ctx context.Context, group string) ([]string, error) {
+	r.calls++
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	members, ok := r.members[group]
+	if !ok {
+		return nil, ErrUnknownGroup{Group: group}
+	}
+
+	return members, nil
+}
+
+func TestCache(t *testing.T) {
+	resolver := &staticResolver{members: map[string][]string{"release-managers": {"Alice@example.com"}}}
+	cache := NewCache(resolver, time.Minute, time.Hour)
+	now := time.Now()
+	cache.now = func() time.Time { return now }
+	for i := 0; i < 2; i++ {
+		ok, err := IsMember(context.Background(), cache, "release-managers", "alice@example.com")
+		if err != nil || !ok {
+			t.Fatalf("expected alice to be a member: %v", err)
+		}
+	}
+
+	if resolver.calls != 1 {
+		t.Errorf("expected membership to be cached, resolved %v times", resolver.calls)
+	}
+
+	now = now.Add(2 * time.Minute)
+	resolver.err = fmt.Errorf("directory unavailable")
+	if ok, err := IsMember(context.Background(), cache, "release-managers", "alice@example.com"); err != nil || !ok {
+		t.Errorf("expected stale membership to be used while the directory is unavailable: %v", err)
+	}
+
+	if resolver.calls != 2 {
+		t.Errorf("expected expired membership to be resolved again")
+	}
+
+	// past the staleness bound, an outage fails closed
+	now = now.Add(time.Hour)
+	_, err := IsMember(context.Background(), cache, "release-managers", "alice@example.com")
+	if !errors.As(err, &ErrStaleMembership{}) {
+		t.Errorf("expected a membership past its staleness bound not to be used, got %v", err)
+	}
+
+	resolver.err = nil
+	_, err = cache.Members(context.Background(), "admins")
+	if !errors.As(err, &ErrUnknownGroup{}) {
+		t.Errorf("expected unknown group error, got %v", err)
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "groups.json")
+	cache := NewCache(&staticResolver{members: map[string][]string{"release-managers": {"alice@example.com"}}}, time.Minute, time.Hour)
+	if _, err := cache.Members(context.Background(), "release-managers"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cache.WriteSnapshot(path); err != nil {
+		t.Fatal(err)
+	}
+
+	offline := NewCache(nil, 0, 0)
+	if err := offline.LoadSnapshot(path); err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, err := IsMember(context.Background(), offline, "release-managers", "alice@example.com"); err != nil || !ok {
+		t.Errorf("expected membership from snapshot: %v", err)
+	}
+
+	if _, err := offline.Members(context.Background(), "admins"); err == nil {
+		t.Error("expected group missing from the snapshot to fail")
+	}
+}
+
+func TestSCIM(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		switch {
+		case r.URL.Path == "/Groups" && r.URL.Query().Get("filter") == `displayName eq "release-managers"`:
+			fmt.Fprint(w, `{"Resources": [{"members": [{"value": "1"}, {"value": "2"}]}]}`)
+		case r.URL.Path == "/Groups":
+			fmt.Fprint(w, `{"Resources": []}`)
+		case r.URL.Path == "/Users/1":
+			fmt.Fprint(w, `{"userName": "alice", "emails": [{"value": "alice@example.com"}]}`)
+		case r.URL.Path == "/Users/2":
+			fmt.Fprint(w, `{"userName": "bob@example.com"}`)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	s := SCIM{URL: server.URL, Token: "token"}
+	members, err := s.Members(context.Background(), "release-managers")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Join(members, ",") != "alice@example.com,bob@example.com" {
+		t.Errorf("unexpected members: %v", members)
+	}
+
+	if _, err := s.Members(context.Background(), "admins"); !errors.As(err, &ErrUnknownGroup{}) {
+		t.Errorf("expected unknown group error, got %v", err)
+	}
+}
+
+func TestLDAP(t *testing.T) {
+	oldRun := runCommand
+	defer func() { runCommand = oldRun }()
+	var gotArgs []string
+	runCommand = func(ctx context.Context, name string, args ...string) ([]byte, error) {
+		gotArgs = args
+		return []byte("dn: uid=alice,ou=people,dc=example,dc=com\nmail: alice@example.com\n\n" +
+			"dn: uid=bob,ou=people,dc=example,dc=com\nmail:: Ym9iQGV4YW1wbGUuY29t\n\n" +
+			"dn: uid=carol,ou=people,dc=example,dc=com\nmail: carol@exam\n ple.com\n"), nil
+	}
+
+	l := LDAP{URL: "ldap://ldap.example.com", Base: "dc=example,dc=com", GroupBase: "ou=groups,dc=example,dc=com"}
+	members, err := l.Members(context.Background(), "release(managers)")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Join(members, ",") != "alice@example.com,bob@example.com,carol@example.com" {
+		t.Errorf("unexpected members: %v", members)
+	}
+
+	expectedFilter := `(&(mail=*)(memberOf=cn=release\28managers\29,ou=groups,dc=example,dc=com))`
+	if gotArgs[len(gotArgs)-2] != expectedFilter {
+		t.Errorf("expected filter %v, got %v", expectedFilter, gotArgs[len(gotArgs)-2])
+	}
+}
+
+func TestEscapeDN(t *testing.T) {
+	if escaped := escapeDN("release,managers"); escaped != `release\,managers` {
+		t.Errorf("unexpected escaped dn: %v", escaped)
+	}
+}
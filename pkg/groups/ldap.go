@@ -0,0 +1,141 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package groups
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// runCommand is replaced in tests
+var runCommand = func(ctx context.Context, name string, args ...string) ([]byte, error) {
+	return exec.CommandContext(ctx, name, args...).Output()
+}
+
+// LDAP resolves groups with ldapsearch from the OpenLDAP client tools. Members are the users whose memberOf attribute
+// holds the group's DN, cn=<group>,<GroupBase>, and are identified by their mail attribute.
+type LDAP struct {
+	URL       string
+	Base      string
+	GroupBase string
+	BindDN    string
+	// Password is written to a temporary file for ldapsearch so it doesn't appear in the process list.
+	Password string
+}
+
+func (l LDAP) Members(ctx context.Context, group string) ([]string, error) {
+	groupBase := l.GroupBase
+	if groupBase == "" {
+		groupBase = l.Base
+	}
+
+	groupDN := fmt.Sprintf("cn=%v,%v", escapeDN(group), groupBase)
+	args := []string{"-LLL", "-H", l.URL, "-b", l.Base}
+	if l.BindDN == "" {
+		args = append(args, "-x")
+	} else {
+		passwordFile, err := os.CreateTemp("", "witness-ldap-")
+		if err != nil {
+			return nil, fmt.Errorf("failed to create password file: %w", err)
+		}
+
+		defer os.Remove(passwordFile.Name())
+		_, err = passwordFile.WriteString(l.Password)
+		passwordFile.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to write password file: %w", err)
+		}
+
+		args = append(args, "-x", "-D", l.BindDN, "-y", passwordFile.Name())
+	}
+
+	args = append(args, fmt.Sprintf("(&(mail=*)(memberOf=%v))", escapeFilter(groupDN)), "mail")
+	out, err := runCommand(ctx, "ldapsearch", args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search ldap: %w", err)
+	}
+
+	return parseLDIF(out, "mail")
+}
+
+// parseLDIF returns the values of the attribute from ldapsearch's LDIF output.
+func parseLDIF(data []byte, attribute string) ([]string, error) {
+	// unfold continuation lines, which begin with a single space
+	lines := make([]string, 0)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, " ") && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+
+		lines = append(lines, line)
+	}
+
+	values := make([]string, 0)
+	for _, line := range lines {
+		if strings.HasPrefix(line, attribute+":: ") {
+			value, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(line, attribute+":: "))
+			if err != nil {
+				return nil, fmt.Errorf("failed to decode %v: %w", attribute, err)
+			}
+
+			values = append(values, string(value))
+		} else if strings.HasPrefix(line, attribute+": ") {
+			values = append(values, strings.TrimPrefix(line, attribute+": "))
+		}
+	}
+
+	return values, nil
+}
+
+// escapeDN escapes a value for use in a DN as described by RFC 4514.
+func escapeDN(value string) string {
+	b := strings.Builder{}
+	for i, r := range value {
+		switch {
+		case strings.ContainsRune(`,+"\<>;=`, r),
+			i == 0 && (r == ' ' || r == '#'),
+			i == len(value)-1 && r == ' ':
+			b.WriteRune('\\')
+		}
+
+		b.WriteRune(r)
+	}
+
+	return b.String()
+}
+
+// escapeFilter escapes a value for use in a search filter as described by RFC 4515.
+func escapeFilter(value string) string {
+	b := strings.Builder{}
+	for i := 0; i < len(value); i++ {
+		switch c := value[i]; c {
+		case '*', '(', ')', '\\', 0:
+			fmt.Fprintf(&b, "\\%02x", c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+
+	return b.String()
+}
@@ -0,0 +1,110 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package groups
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// SCIM resolves groups with a SCIM 2.0 service provider, such as Okta or Azure AD. Members are identified by the
+// email addresses of their user resources.
+type SCIM struct {
+	URL        string
+	Token      string
+	HTTPClient *http.Client
+}
+
+type scimGroups struct {
+	Resources []struct {
+		Members []struct {
+			Value string `json:"value"`
+		} `json:"members"`
+	} `json:"Resources"`
+}
+
+type scimUser struct {
+	UserName string `json:"userName"`
+	Emails   []struct {
+		Value string `json:"value"`
+	} `json:"emails"`
+}
+
+func (s SCIM) Members(ctx context.Context, group string) ([]string, error) {
+	filter := fmt.Sprintf("displayName eq %q", group)
+	groups := scimGroups{}
+	if err := s.get(ctx, "/Groups?filter="+url.QueryEscape(filter), &groups); err != nil {
+		return nil, fmt.Errorf("failed to get group %v: %w", group, err)
+	}
+
+	if len(groups.Resources) == 0 {
+		return nil, ErrUnknownGroup{Group: group}
+	}
+
+	members := make([]string, 0)
+	for _, member := range groups.Resources[0].Members {
+		user := scimUser{}
+		if err := s.get(ctx, "/Users/"+url.PathEscape(member.Value), &user); err != nil {
+			return nil, fmt.Errorf("failed to get user %v: %w", member.Value, err)
+		}
+
+		for _, email := range user.Emails {
+			members = append(members, email.Value)
+		}
+
+		if len(user.Emails) == 0 && strings.Contains(user.UserName, "@") {
+			members = append(members, user.UserName)
+		}
+	}
+
+	return members, nil
+}
+
+func (s SCIM) get(ctx context.Context, path string, v interface{}) error {
+	httpClient := s.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(s.URL, "/")+path, nil)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Accept", "application/scim+json")
+	if s.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.Token)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %v", resp.Status)
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return nil
+}
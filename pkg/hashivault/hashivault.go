@@ -0,0 +1,310 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package hashivault signs with keys held in a HashiCorp Vault transit secrets engine. The Vault server and token are
+// read from VAULT_ADDR and VAULT_TOKEN, the Vault Enterprise namespace from VAULT_NAMESPACE, and the engine's mount
+// path from TRANSIT_SECRET_ENGINE_PATH. Without VAULT_TOKEN, witness logs in with the Kubernetes auth method as the
+// role in VAULT_K8S_ROLE.
+package hashivault
+
+import (
+	"bytes"
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/witness/pkg/kms"
+)
+
+const (
+	// Scheme is the kms reference scheme Vault transit keys are registered under.
+	Scheme = "hashivault"
+	// ReferenceScheme prefixes references to Vault transit keys, written as hashivault://<key name>.
+	ReferenceScheme = Scheme + "://"
+
+	defaultMountPath = "transit"
+
+	defaultKubernetesMountPath = "kubernetes"
+	defaultKubernetesTokenPath = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+)
+
+func init() {
+	kms.Register(Scheme, New)
+}
+
+// Client calls a Vault transit secrets engine.
+type Client struct {
+	Address string
+	Token   string
+	// Namespace is the Vault Enterprise namespace the transit engine and auth method are in.
+	Namespace string
+	MountPath string
+	// Kubernetes logs in to get a token when Token is not set.
+	Kubernetes *KubernetesAuth
+	HTTPClient *http.Client
+}
+
+// KubernetesAuth logs in with Vault's Kubernetes auth method using a service account token, so builders running in a
+// cluster don't need a provisioned Vault token.
+type KubernetesAuth struct {
+	Role string
+	// MountPath is the auth method's mount path. Defaults to kubernetes.
+	MountPath string
+	// TokenPath is the service account token to log in with, such as a projected token with Vault as its audience.
+	// Defaults to the pod's service account token.
+	TokenPath string
+}
+
+// ClientFromEnv returns a client configured by VAULT_ADDR, VAULT_TOKEN, VAULT_NAMESPACE, and
+// TRANSIT_SECRET_ENGINE_PATH. Without VAULT_TOKEN, the client logs in with the Kubernetes auth method as the role in
+// VAULT_K8S_ROLE, mounted at VAULT_K8S_MOUNT_PATH, with the service account token at VAULT_K8S_TOKEN_PATH.
+func ClientFromEnv() (Client, error) {
+	c := Client{
+		Address:    os.Getenv("VAULT_ADDR"),
+		Token:      os.Getenv("VAULT_TOKEN"),
+		Namespace:  os.Getenv("VAULT_NAMESPACE"),
+		MountPath:  os.Getenv("TRANSIT_SECRET_ENGINE_PATH"),
+		HTTPClient: http.DefaultClient,
+	}
+
+	if c.Address == "" {
+		return c, fmt.Errorf("VAULT_ADDR must be set to sign with vault")
+	}
+
+	if role := os.Getenv("VAULT_K8S_ROLE"); c.Token == "" && role != "" {
+		c.Kubernetes = &KubernetesAuth{
+			Role:      role,
+			MountPath: os.Getenv("VAULT_K8S_MOUNT_PATH"),
+			TokenPath: os.Getenv("VAULT_K8S_TOKEN_PATH"),
+		}
+	}
+
+	if c.Token == "" && c.Kubernetes == nil {
+		return c, fmt.Errorf("VAULT_TOKEN or VAULT_K8S_ROLE must be set to sign with vault")
+	}
+
+	return c, nil
+}
+
+// Login returns a Vault token for the client's Kubernetes auth role.
+func (c Client) Login(ctx context.Context) (string, error) {
+	if c.Kubernetes == nil {
+		return "", fmt.Errorf("no vault auth method is configured")
+	}
+
+	tokenPath := c.Kubernetes.TokenPath
+	if tokenPath == "" {
+		tokenPath = defaultKubernetesTokenPath
+	}
+
+	jwt, err := os.ReadFile(tokenPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to read service account token: %w", err)
+	}
+
+	mountPath := c.Kubernetes.MountPath
+	if mountPath == "" {
+		mountPath = defaultKubernetesMountPath
+	}
+
+	request := map[string]string{"role": c.Kubernetes.Role, "jwt": strings.TrimSpace(string(jwt))}
+	response, err := c.send(ctx, http.MethodPost, "auth/"+strings.Trim(mountPath, "/")+"/login", request)
+	if err != nil {
+		return "", fmt.Errorf("failed to log in to vault as kubernetes role %v: %w", c.Kubernetes.Role, err)
+	}
+
+	if response.Auth.ClientToken == "" {
+		return "", fmt.Errorf("vault did not return a token for kubernetes role %v", c.Kubernetes.Role)
+	}
+
+	return response.Auth.ClientToken, nil
+}
+
+// ParseReference returns the name of the key a hashivault:// reference names.
+func ParseReference(ref string) (string, error) {
+	if !strings.HasPrefix(ref, ReferenceScheme) {
+		return "", fmt.Errorf("kms reference %v does not start with %v", ref, ReferenceScheme)
+	}
+
+	name := strings.TrimPrefix(ref, ReferenceScheme)
+	if name == "" || strings.Contains(name, "/") {
+		return "", fmt.Errorf("kms reference %v does not name a transit key", ref)
+	}
+
+	return name, nil
+}
+
+// New returns a signer for the referenced transit key using the client configured by the environment.
+func New(ctx context.Context, ref string) (kms.SignerVerifier, error) {
+	name, err := ParseReference(ref)
+	if err != nil {
+		return nil, err
+	}
+
+	c, err := ClientFromEnv()
+	if err != nil {
+		return nil, err
+	}
+
+	if c.Token == "" {
+		if c.Token, err = c.Login(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	return c.SignerVerifier(ctx, name)
+}
+
+type transitKey struct {
+	Type          string `json:"type"`
+	LatestVersion int    `json:"latest_version"`
+	Keys          map[string]struct {
+		PublicKey string `json:"public_key"`
+	} `json:"keys"`
+}
+
+// SignerVerifier returns a signer for the latest version of the transit key. Signatures are always made with that
+// version, even if the key is rotated while witness runs.
+func (c Client) SignerVerifier(ctx context.Context, name string) (kms.SignerVerifier, error) {
+	key := transitKey{}
+	if err := c.do(ctx, http.MethodGet, "keys/"+name, nil, &key); err != nil {
+		return nil, fmt.Errorf("failed to read transit key %v: %w", name, err)
+	}
+
+	version, ok := key.Keys[strconv.Itoa(key.LatestVersion)]
+	if !ok || version.PublicKey == "" {
+		return nil, fmt.Errorf("transit key %v of type %v does not have a public key", name, key.Type)
+	}
+
+	pub, err := cryptoutil.TryParseKeyFromReader(strings.NewReader(version.PublicKey))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse public key of transit key %v: %w", name, err)
+	}
+
+	signatureAlgorithm := ""
+	if _, ok := pub.(*rsa.PublicKey); ok {
+		signatureAlgorithm = "pss"
+	}
+
+	return kms.NewSignerVerifier(pub, func(digest []byte) ([]byte, error) {
+		request := map[string]interface{}{
+			"input":                base64.StdEncoding.EncodeToString(digest),
+			"prehashed":            true,
+			"key_version":          key.LatestVersion,
+			"marshaling_algorithm": "asn1",
+		}
+
+		if signatureAlgorithm != "" {
+			request["signature_algorithm"] = signatureAlgorithm
+		}
+
+		signed := struct {
+			Signature string `json:"signature"`
+		}{}
+
+		if err := c.do(ctx, http.MethodPost, "sign/"+name+"/sha2-256", request, &signed); err != nil {
+			return nil, fmt.Errorf("failed to sign with transit key %v: %w", name, err)
+		}
+
+		// signatures are returned as vault:v<version>:<base64 signature>
+		parts := strings.Split(signed.Signature, ":")
+		sig, err := base64.StdEncoding.DecodeString(parts[len(parts)-1])
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode signature from transit key %v: %w", name, err)
+		}
+
+		return sig, nil
+	})
+}
+
+// vaultResponse is the envelope of Vault API responses.
+type vaultResponse struct {
+	Data json.RawMessage `json:"data"`
+	Auth struct {
+		ClientToken string `json:"client_token"`
+	} `json:"auth"`
+	Errors []string `json:"errors"`
+}
+
+// do calls the transit engine's path and decodes the data of the response into out.
+func (c Client) do(ctx context.Context, method, path string, body interface{}, out interface{}) error {
+	mountPath := c.MountPath
+	if mountPath == "" {
+		mountPath = defaultMountPath
+	}
+
+	response, err := c.send(ctx, method, strings.Trim(mountPath, "/")+"/"+path, body)
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(response.Data, out)
+}
+
+// send calls the Vault API path, relative to /v1, in the client's namespace.
+func (c Client) send(ctx context.Context, method, path string, body interface{}) (vaultResponse, error) {
+	var reqBody io.Reader
+	if body != nil {
+		encoded, err := json.Marshal(body)
+		if err != nil {
+			return vaultResponse{}, err
+		}
+
+		reqBody = bytes.NewReader(encoded)
+	}
+
+	url := fmt.Sprintf("%v/v1/%v", strings.TrimSuffix(c.Address, "/"), path)
+	req, err := http.NewRequestWithContext(ctx, method, url, reqBody)
+	if err != nil {
+		return vaultResponse{}, err
+	}
+
+	if c.Token != "" {
+		req.Header.Set("X-Vault-Token", c.Token)
+	}
+
+	if c.Namespace != "" {
+		req.Header.Set("X-Vault-Namespace", c.Namespace)
+	}
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return vaultResponse{}, err
+	}
+
+	defer resp.Body.Close()
+	response := vaultResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return vaultResponse{}, fmt.Errorf("failed to decode vault response with status %v: %w", resp.Status, err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return vaultResponse{}, fmt.Errorf("vault returned %v: %v", resp.Status, strings.Join(response.Errors, "; "))
+	}
+
+	return response, nil
+}
@@ -0,0 +1,208 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package hashivault
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/testifysec/witness/pkg/kms"
+)
+
+// fakeTransit serves the read key and sign endpoints of a transit engine mounted at transit.
+func fakeTransit(t *testing.T, priv *ecdsa.PrivateKey) *httptest.Server {
+	der, err := x509.MarshalPKIXPublicKey(&priv.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	publicKey := string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}))
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/transit/keys/witness", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Vault-Token") != "token" {
+			w.WriteHeader(http.StatusForbidden)
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"errors": []string{"permission denied"}})
+			return
+		}
+
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"data": map[string]interface{}{
+			"type":           "ecdsa-p256",
+			"latest_version": 2,
+			"keys":           map[string]interface{}{"2": map[string]interface{}{"public_key": publicKey}},
+		}})
+	})
+
+	mux.HandleFunc("/v1/transit/sign/witness/sha2-256", func(w http.ResponseWriter, r *http.Request) {
+		request := struct {
+			Input      string `json:"input"`
+			Prehashed  bool   `json:"prehashed"`
+			KeyVersion int    `json:"key_version"`
+		}{}
+
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil || !request.Prehashed || request.KeyVersion != 2 {
+			w.WriteHeader(http.StatusBadRequest)
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"errors": []string{"bad request"}})
+			return
+		}
+
+		digest, err := base64.StdEncoding.DecodeString(request.Input)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		sig, err := ecdsa.SignASN1(rand.Reader, priv, digest)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"data": map[string]interface{}{
+			"signature": "vault:v2:" + base64.StdEncoding.EncodeToString(sig),
+		}})
+	})
+
+	return httptest.NewServer(mux)
+}
+
+func TestParseReference(t *testing.T) {
+	name, err := ParseReference("hashivault://witness")
+	if err != nil || name != "witness" {
+		t.Errorf("unexpected key name %v: %v", name, err)
+	}
+
+	for _, invalid := range []string{"hashivault://", "hashivault://a/b", "awskms:///witness"} {
+		if _, err := ParseReference(invalid); err == nil {
+			t.Errorf("expected %v to be rejected", invalid)
+		}
+	}
+}
+
+func TestSignerVerifier(t *testing.T) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := fakeTransit(t, priv)
+	defer server.Close()
+
+	c := Client{Address: server.URL, Token: "token", HTTPClient: server.Client()}
+	signer, err := c.SignerVerifier(context.Background(), "witness")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := []byte("attestation")
+	sig, err := signer.Sign(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := signer.Verify(bytes.NewReader(data), sig); err != nil {
+		t.Errorf("expected signature to verify: %v", err)
+	}
+
+	if err := signer.Verify(bytes.NewReader([]byte("tampered")), sig); err == nil {
+		t.Error("expected signature over other data to fail")
+	}
+
+	c.Token = "wrong"
+	if _, err := c.SignerVerifier(context.Background(), "witness"); err == nil {
+		t.Error("expected vault error to be returned")
+	}
+}
+
+func TestRegistered(t *testing.T) {
+	if !kms.IsReference("hashivault://witness") {
+		t.Error("expected hashivault references to be registered")
+	}
+}
+
+func TestKubernetesLogin(t *testing.T) {
+	tokenPath := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenPath, []byte("service-account-jwt\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		request := struct {
+			Role string `json:"role"`
+			JWT  string `json:"jwt"`
+		}{}
+
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil || r.URL.Path != "/v1/auth/k8s-builders/login" ||
+			r.Header.Get("X-Vault-Namespace") != "team-a" || request.Role != "builder" || request.JWT != "service-account-jwt" {
+			w.WriteHeader(http.StatusForbidden)
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"errors": []string{"permission denied"}})
+			return
+		}
+
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"auth": map[string]interface{}{"client_token": "token"}})
+	}))
+	defer server.Close()
+
+	c := Client{
+		Address:    server.URL,
+		Namespace:  "team-a",
+		Kubernetes: &KubernetesAuth{Role: "builder", MountPath: "k8s-builders", TokenPath: tokenPath},
+		HTTPClient: server.Client(),
+	}
+
+	token, err := c.Login(context.Background())
+	if err != nil || token != "token" {
+		t.Fatalf("unexpected token %v: %v", token, err)
+	}
+
+	c.Kubernetes.Role = "other"
+	if _, err := c.Login(context.Background()); err == nil {
+		t.Error("expected a rejected login to fail")
+	}
+}
+
+func TestClientFromEnv(t *testing.T) {
+	t.Setenv("VAULT_ADDR", "https://vault.example.com")
+	t.Setenv("VAULT_TOKEN", "")
+	t.Setenv("VAULT_K8S_ROLE", "")
+	if _, err := ClientFromEnv(); err == nil {
+		t.Error("expected a client without a token or auth method to be rejected")
+	}
+
+	t.Setenv("VAULT_K8S_ROLE", "builder")
+	t.Setenv("VAULT_NAMESPACE", "team-a")
+	c, err := ClientFromEnv()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if c.Kubernetes == nil || c.Kubernetes.Role != "builder" || c.Namespace != "team-a" {
+		t.Errorf("expected kubernetes auth in namespace team-a, got %+v", c)
+	}
+
+	t.Setenv("VAULT_TOKEN", "token")
+	if c, err := ClientFromEnv(); err != nil || c.Kubernetes != nil {
+		t.Errorf("expected VAULT_TOKEN to be used instead of kubernetes auth, got %+v, %v", c, err)
+	}
+}
@@ -0,0 +1,161 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package kms resolves key management service references, such as awskms:///alias/witness or
+// hashivault://witness, to signers that can also verify. Each reference scheme is handled by a provider that registers
+// itself when its package is imported, so new providers don't need any changes to the commands that use them.
+package kms
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/testifysec/go-witness/cryptoutil"
+)
+
+// SignerVerifier signs with a KMS key and verifies signatures made by it.
+type SignerVerifier interface {
+	cryptoutil.Signer
+	Verify(body io.Reader, sig []byte) error
+	Bytes() ([]byte, error)
+}
+
+// Provider returns a SignerVerifier for the key a reference names.
+type Provider func(ctx context.Context, ref string) (SignerVerifier, error)
+
+type ErrUnsupportedScheme string
+
+func (e ErrUnsupportedScheme) Error() string {
+	return fmt.Sprintf("unsupported kms reference scheme %v, expected one of %v", string(e), strings.Join(Schemes(), ", "))
+}
+
+var providers = map[string]Provider{}
+
+// Register makes a provider available for references with the scheme, such as gcpkms.
+func Register(scheme string, provider Provider) {
+	providers[scheme] = provider
+}
+
+// Schemes returns the schemes providers are registered for, sorted.
+func Schemes() []string {
+	schemes := make([]string, 0, len(providers))
+	for scheme := range providers {
+		schemes = append(schemes, scheme)
+	}
+
+	sort.Strings(schemes)
+	return schemes
+}
+
+// IsReference returns true if ref starts with the scheme of a registered provider.
+func IsReference(ref string) bool {
+	_, ok := providers[scheme(ref)]
+	return ok
+}
+
+// Get returns a SignerVerifier for the referenced key from the provider registered for its scheme.
+func Get(ctx context.Context, ref string) (SignerVerifier, error) {
+	s := scheme(ref)
+	if s == "" {
+		return nil, fmt.Errorf("kms reference %v does not have a scheme", ref)
+	}
+
+	provider, ok := providers[s]
+	if !ok {
+		return nil, ErrUnsupportedScheme(s)
+	}
+
+	return provider(ctx, ref)
+}
+
+func scheme(ref string) string {
+	i := strings.Index(ref, "://")
+	if i <= 0 {
+		return ""
+	}
+
+	return ref[:i]
+}
+
+// SignDigest signs a SHA-256 digest with a KMS key.
+type SignDigest func(digest []byte) ([]byte, error)
+
+type signerVerifier struct {
+	sign     SignDigest
+	verifier cryptoutil.Verifier
+}
+
+// NewSignerVerifier returns a SignerVerifier that sends the SHA-256 digest of the data to sign and verifies signatures
+// locally with the key's public key. Only P-256 ECDSA and RSA keys are supported, since witness verifies ECDSA
+// signatures over SHA-256 digests and RSA signatures with RSASSA-PSS. ECDSA signatures must be ASN.1 encoded.
+func NewSignerVerifier(pub crypto.PublicKey, sign SignDigest) (SignerVerifier, error) {
+	if err := CheckPublicKey(pub); err != nil {
+		return nil, err
+	}
+
+	verifier, err := cryptoutil.NewVerifier(pub)
+	if err != nil {
+		return nil, err
+	}
+
+	return &signerVerifier{sign: sign, verifier: verifier}, nil
+}
+
+// CheckPublicKey returns an error if witness can't verify signatures made with the key's private key.
+func CheckPublicKey(pub crypto.PublicKey) error {
+	switch key := pub.(type) {
+	case *ecdsa.PublicKey:
+		if key.Curve != elliptic.P256() {
+			return fmt.Errorf("unsupported ecdsa curve %v", key.Curve.Params().Name)
+		}
+
+		return nil
+	case *rsa.PublicKey:
+		return nil
+	default:
+		return fmt.Errorf("unsupported key type %T", pub)
+	}
+}
+
+func (s *signerVerifier) KeyID() (string, error) {
+	return s.verifier.KeyID()
+}
+
+func (s *signerVerifier) Sign(r io.Reader) ([]byte, error) {
+	digest, err := cryptoutil.Digest(r, crypto.SHA256)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.sign(digest)
+}
+
+func (s *signerVerifier) Verifier() (cryptoutil.Verifier, error) {
+	return s.verifier, nil
+}
+
+func (s *signerVerifier) Verify(body io.Reader, sig []byte) error {
+	return s.verifier.Verify(body, sig)
+}
+
+func (s *signerVerifier) Bytes() ([]byte, error) {
+	return s.verifier.Bytes()
+}
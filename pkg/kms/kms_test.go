@@ -0,0 +1,87 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kms
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"testing"
+)
+
+func TestRegistry(t *testing.T) {
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	Register("testkms", func(ctx context.Context, ref string) (SignerVerifier, error) {
+		return NewSignerVerifier(&priv.PublicKey, func(digest []byte) ([]byte, error) {
+			return rsa.SignPSS(rand.Reader, priv, crypto.SHA256, digest, nil)
+		})
+	})
+
+	if !IsReference("testkms://key") || IsReference("unknownkms://key") || IsReference("key.pem") {
+		t.Error("unexpected reference detection")
+	}
+
+	signer, err := Get(context.Background(), "testkms://key")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sig, err := signer.Sign(bytes.NewReader([]byte("data")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := signer.Verify(bytes.NewReader([]byte("data")), sig); err != nil {
+		t.Errorf("expected signature to verify: %v", err)
+	}
+
+	var unsupported ErrUnsupportedScheme
+	if _, err := Get(context.Background(), "unknownkms://key"); !errors.As(err, &unsupported) {
+		t.Errorf("expected unsupported scheme error, got %v", err)
+	}
+
+	if _, err := Get(context.Background(), "key.pem"); err == nil {
+		t.Error("expected reference without a scheme to fail")
+	}
+}
+
+func TestCheckPublicKey(t *testing.T) {
+	p384, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := CheckPublicKey(&p384.PublicKey); err == nil {
+		t.Error("expected P-384 key to be rejected")
+	}
+
+	p256, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := CheckPublicKey(&p256.PublicKey); err != nil {
+		t.Errorf("expected P-256 key to be accepted: %v", err)
+	}
+}
@@ -0,0 +1,131 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package merge combines signed attestation collections for the same step into a single bundle.
+package merge
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/testifysec/go-witness/attestation"
+	"github.com/testifysec/go-witness/dsse"
+	"github.com/testifysec/go-witness/intoto"
+)
+
+const Type = "https://witness.dev/merged-collections/v0.1"
+
+// Bundle holds signed collections for a single step. The envelopes are unchanged so each keeps its original
+// signatures, and Subjects is the union of their subjects.
+type Bundle struct {
+	Type      string           `json:"type"`
+	Step      string           `json:"step"`
+	Subjects  []intoto.Subject `json:"subjects"`
+	Envelopes []dsse.Envelope  `json:"envelopes"`
+}
+
+type ErrStepMismatch struct {
+	Expected string
+	Actual   string
+}
+
+func (e ErrStepMismatch) Error() string {
+	return fmt.Sprintf("can not merge collection for step %v with collections for step %v", e.Actual, e.Expected)
+}
+
+// Merge bundles envelopes containing attestation collections for the same step.
+func Merge(envs []dsse.Envelope) (Bundle, error) {
+	bundle := Bundle{
+		Type:      Type,
+		Subjects:  make([]intoto.Subject, 0),
+		Envelopes: make([]dsse.Envelope, 0, len(envs)),
+	}
+
+	if len(envs) == 0 {
+		return bundle, fmt.Errorf("no envelopes to merge")
+	}
+
+	seen := map[string]bool{}
+	for i, env := range envs {
+		if len(env.Signatures) == 0 {
+			return bundle, fmt.Errorf("envelope %d is not signed", i)
+		}
+
+		if env.PayloadType != intoto.PayloadType {
+			return bundle, fmt.Errorf("envelope %d has unexpected payload type %v", i, env.PayloadType)
+		}
+
+		// only the collection's name is decoded, so its attestations are not copied out of the payload
+		statement := struct {
+			PredicateType string           `json:"predicateType"`
+			Subject       []intoto.Subject `json:"subject"`
+			Predicate     struct {
+				Name string `json:"name"`
+			} `json:"predicate"`
+		}{}
+
+		if err := json.Unmarshal(env.Payload, &statement); err != nil {
+			return bundle, fmt.Errorf("failed to unmarshal statement in envelope %d: %w", i, err)
+		}
+
+		if statement.PredicateType != attestation.CollectionType {
+			return bundle, fmt.Errorf("envelope %d does not contain an attestation collection", i)
+		}
+
+		collection := statement.Predicate
+		if i == 0 {
+			bundle.Step = collection.Name
+		} else if collection.Name != bundle.Step {
+			return bundle, ErrStepMismatch{Expected: bundle.Step, Actual: collection.Name}
+		}
+
+		for _, subject := range statement.Subject {
+			key := subjectKey(subject)
+			if !seen[key] {
+				seen[key] = true
+				bundle.Subjects = append(bundle.Subjects, subject)
+			}
+		}
+
+		bundle.Envelopes = append(bundle.Envelopes, env)
+	}
+
+	sort.Slice(bundle.Subjects, func(i, j int) bool {
+		return subjectKey(bundle.Subjects[i]) < subjectKey(bundle.Subjects[j])
+	})
+
+	return bundle, nil
+}
+
+// Parse returns the bundle in data. ok is false if data is not a merged bundle, such as a single envelope.
+func Parse(data []byte) (Bundle, bool) {
+	bundle := Bundle{}
+	if err := json.Unmarshal(data, &bundle); err != nil {
+		return bundle, false
+	}
+
+	return bundle, bundle.Type == Type
+}
+
+func subjectKey(subject intoto.Subject) string {
+	digests := make([]string, 0, len(subject.Digest))
+	for name, value := range subject.Digest {
+		digests = append(digests, name+":"+value)
+	}
+
+	sort.Strings(digests)
+	return subject.Name + "@" + strings.Join(digests, ",")
+}
@@ -0,0 +1,109 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package merge
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/testifysec/go-witness/attestation"
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/dsse"
+	"github.com/testifysec/go-witness/intoto"
+)
+
+func testEnvelope(t *testing.T, signer cryptoutil.Signer, step string, subjects map[string]cryptoutil.DigestSet) dsse.Envelope {
+	predicate, err := json.Marshal(map[string]interface{}{"name": step, "attestations": []interface{}{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	statement, err := intoto.NewStatement(attestation.CollectionType, predicate, subjects)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payload, err := json.Marshal(statement)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	env, err := dsse.Sign(intoto.PayloadType, bytes.NewReader(payload), signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return env
+}
+
+func TestMerge(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signer := cryptoutil.NewRSASigner(key, crypto.SHA256)
+	verifier, err := signer.Verifier()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	shared := cryptoutil.DigestSet{crypto.SHA256: "aa"}
+	first := testEnvelope(t, signer, "build", map[string]cryptoutil.DigestSet{"commithash:abc": shared, "amd64/app": {crypto.SHA256: "bb"}})
+	second := testEnvelope(t, signer, "build", map[string]cryptoutil.DigestSet{"commithash:abc": shared, "arm64/app": {crypto.SHA256: "cc"}})
+	bundle, err := Merge([]dsse.Envelope{first, second})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if bundle.Step != "build" || len(bundle.Subjects) != 3 || len(bundle.Envelopes) != 2 {
+		t.Errorf("unexpected bundle: %+v", bundle)
+	}
+
+	b, err := json.Marshal(bundle)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, ok := Parse(b)
+	if !ok || len(parsed.Envelopes) != 2 {
+		t.Fatalf("expected bundle to parse")
+	}
+
+	for _, env := range parsed.Envelopes {
+		if _, err := env.Verify(dsse.WithVerifiers([]cryptoutil.Verifier{verifier})); err != nil {
+			t.Errorf("expected original signature to verify: %v", err)
+		}
+	}
+
+	envBytes, err := json.Marshal(first)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := Parse(envBytes); ok {
+		t.Errorf("expected a single envelope not to parse as a bundle")
+	}
+
+	_, err = Merge([]dsse.Envelope{first, testEnvelope(t, signer, "test", nil)})
+	if !errors.As(err, &ErrStepMismatch{}) {
+		t.Errorf("expected step mismatch, got %v", err)
+	}
+}
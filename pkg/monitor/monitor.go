@@ -0,0 +1,200 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package monitor periodically re-verifies artifacts and reports artifacts that stop passing verification.
+package monitor
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/testifysec/go-witness/log"
+)
+
+// CheckFunc verifies a single target, returning an error if it fails verification.
+type CheckFunc func(ctx context.Context, target string) error
+
+// Status is the result of the most recent check of a target.
+type Status struct {
+	Target    string    `json:"target"`
+	Passing   bool      `json:"passing"`
+	Error     string    `json:"error,omitempty"`
+	CheckedAt time.Time `json:"checkedAt"`
+	// LastPassedAt is the last time the target passed verification, if it ever has.
+	LastPassedAt time.Time `json:"lastPassedAt,omitempty"`
+}
+
+// Alert is raised when a target that previously passed verification fails it.
+type Alert struct {
+	Status
+}
+
+// Monitor checks a set of targets and tracks when they change from passing to failing.
+type Monitor struct {
+	targets []string
+	check   CheckFunc
+	// AlertURL receives a POST of each Alert if set, using AlertClient.
+	AlertURL    string
+	AlertClient *http.Client
+
+	mu     sync.Mutex
+	status map[string]Status
+	alerts uint64
+}
+
+func New(targets []string, check CheckFunc) *Monitor {
+	return &Monitor{
+		targets:     targets,
+		check:       check,
+		AlertClient: http.DefaultClient,
+		status:      make(map[string]Status),
+	}
+}
+
+// Check verifies every target once and returns an Alert for each target that passed its previous check but failed
+// this one. Targets that have never passed do not raise alerts.
+func (m *Monitor) Check(ctx context.Context) []Alert {
+	alerts := make([]Alert, 0)
+	for _, target := range m.targets {
+		err := m.check(ctx, target)
+		now := time.Now()
+
+		m.mu.Lock()
+		previous, checked := m.status[target]
+		status := Status{
+			Target:       target,
+			Passing:      err == nil,
+			CheckedAt:    now,
+			LastPassedAt: previous.LastPassedAt,
+		}
+
+		if err != nil {
+			status.Error = err.Error()
+		} else {
+			status.LastPassedAt = now
+		}
+
+		m.status[target] = status
+		if checked && previous.Passing && !status.Passing {
+			alerts = append(alerts, Alert{Status: status})
+			m.alerts++
+		}
+		m.mu.Unlock()
+
+		if checked && !previous.Passing && status.Passing {
+			log.Infof("%v passes verification again", target)
+		}
+	}
+
+	for _, alert := range alerts {
+		log.Errorf("%v no longer passes verification: %v", alert.Target, alert.Error)
+		if m.AlertURL == "" {
+			continue
+		}
+
+		if err := post(ctx, m.AlertClient, m.AlertURL, alert); err != nil {
+			log.Errorf("failed to send alert to %v: %v", m.AlertURL, err)
+		}
+	}
+
+	return alerts
+}
+
+// Run checks the targets immediately and then every interval until ctx is done.
+func (m *Monitor) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		m.Check(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Statuses returns the result of the most recent check of each target that has been checked, sorted by target.
+func (m *Monitor) Statuses() []Status {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	statuses := make([]Status, 0, len(m.status))
+	for _, status := range m.status {
+		statuses = append(statuses, status)
+	}
+
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Target < statuses[j].Target })
+	return statuses
+}
+
+// ServeHTTP writes metrics about the monitored targets in the prometheus text format.
+func (m *Monitor) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	statuses := m.Statuses()
+	m.mu.Lock()
+	alerts := m.alerts
+	m.mu.Unlock()
+
+	rw.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	fmt.Fprintf(rw, "# HELP witness_verification_passing Whether the target passed its most recent verification.\n")
+	fmt.Fprintf(rw, "# TYPE witness_verification_passing gauge\n")
+	for _, status := range statuses {
+		passing := 0
+		if status.Passing {
+			passing = 1
+		}
+
+		fmt.Fprintf(rw, "witness_verification_passing{target=%q} %d\n", status.Target, passing)
+	}
+
+	fmt.Fprintf(rw, "# HELP witness_verification_checked_timestamp_seconds Time the target was last verified.\n")
+	fmt.Fprintf(rw, "# TYPE witness_verification_checked_timestamp_seconds gauge\n")
+	for _, status := range statuses {
+		fmt.Fprintf(rw, "witness_verification_checked_timestamp_seconds{target=%q} %d\n", status.Target, status.CheckedAt.Unix())
+	}
+
+	fmt.Fprintf(rw, "# HELP witness_verification_alerts_total Targets that started failing verification after passing.\n")
+	fmt.Fprintf(rw, "# TYPE witness_verification_alerts_total counter\n")
+	fmt.Fprintf(rw, "witness_verification_alerts_total %d\n", alerts)
+}
+
+func post(ctx context.Context, client *http.Client, url string, body interface{}) error {
+	b, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %v", resp.Status)
+	}
+
+	return nil
+}
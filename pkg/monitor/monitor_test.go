@@ -0,0 +1,75 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package monitor
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	failing := map[string]bool{"never-passes": true}
+	m := New([]string{"app", "never-passes"}, func(ctx context.Context, target string) error {
+		if failing[target] {
+			return errors.New("key revoked")
+		}
+
+		return nil
+	})
+
+	received := make(chan Alert, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
+		alert := Alert{}
+		if err := json.NewDecoder(r.Body).Decode(&alert); err != nil {
+			t.Error(err)
+		}
+
+		received <- alert
+	}))
+	defer server.Close()
+	m.AlertURL = server.URL
+
+	if alerts := m.Check(context.Background()); len(alerts) != 0 {
+		t.Fatalf("expected no alerts on first check, got %v", alerts)
+	}
+
+	failing["app"] = true
+	alerts := m.Check(context.Background())
+	if len(alerts) != 1 || alerts[0].Target != "app" || alerts[0].Error != "key revoked" || alerts[0].LastPassedAt.IsZero() {
+		t.Fatalf("expected alert for app, got %+v", alerts)
+	}
+
+	if alert := <-received; alert.Target != "app" {
+		t.Errorf("unexpected alert posted: %+v", alert)
+	}
+
+	if alerts := m.Check(context.Background()); len(alerts) != 0 {
+		t.Errorf("expected no repeated alert while app keeps failing, got %v", alerts)
+	}
+
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	metrics := rec.Body.String()
+	for _, expected := range []string{`witness_verification_passing{target="app"} 0`, `witness_verification_passing{target="never-passes"} 0`, "witness_verification_alerts_total 1"} {
+		if !strings.Contains(metrics, expected) {
+			t.Errorf("expected metrics to contain %q:\n%v", expected, metrics)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package netrc reads credentials for a host from the user's .netrc file, the way curl and git do.
+package netrc
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// Machine is the login and password of a machine in a .netrc file. The default entry has an empty name.
+type Machine struct {
+	Name     string
+	Login    string
+	Password string
+}
+
+// Path returns the .netrc file in NETRC, or in the user's home directory. On Windows the file is named _netrc.
+func Path() string {
+	if path := os.Getenv("NETRC"); path != "" {
+		return path
+	}
+
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+
+	name := ".netrc"
+	if runtime.GOOS == "windows" {
+		name = "_netrc"
+	}
+
+	return filepath.Join(home, name)
+}
+
+// Parse returns the machines in a .netrc file in the order they appear. Macro definitions are skipped, and account
+// tokens are ignored.
+func Parse(data []byte) []Machine {
+	machines := make([]Machine, 0)
+	var current *Machine
+	lines := strings.Split(string(data), "\n")
+	for i := 0; i < len(lines); i++ {
+		fields := strings.Fields(lines[i])
+		for j := 0; j < len(fields); j++ {
+			value := func() string {
+				if j+1 < len(fields) {
+					j++
+					return fields[j]
+				}
+
+				return ""
+			}
+
+			switch fields[j] {
+			case "machine":
+				machines = append(machines, Machine{Name: value()})
+				current = &machines[len(machines)-1]
+			case "default":
+				machines = append(machines, Machine{})
+				current = &machines[len(machines)-1]
+			case "login":
+				if current != nil {
+					current.Login = value()
+				}
+			case "password":
+				if current != nil {
+					current.Password = value()
+				}
+			case "account":
+				value()
+			case "macdef":
+				// a macro's body runs until the next empty line
+				for i+1 < len(lines) && strings.TrimSpace(lines[i+1]) != "" {
+					i++
+				}
+
+				j = len(fields)
+			default:
+				if strings.HasPrefix(fields[j], "#") {
+					j = len(fields)
+				}
+			}
+		}
+	}
+
+	return machines
+}
+
+// Lookup returns the login and password for the host from the .netrc file at Path. A host may include a port; a
+// machine named by the host without its port also matches. The default entry is used for hosts no machine is named for.
+func Lookup(host string) (string, string, bool) {
+	path := Path()
+	if path == "" {
+		return "", "", false
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", "", false
+	}
+
+	return lookup(Parse(data), host)
+}
+
+func lookup(machines []Machine, host string) (string, string, bool) {
+	hostname := host
+	if i := strings.LastIndex(host, ":"); i >= 0 && !strings.Contains(host[i:], "]") {
+		hostname = host[:i]
+	}
+
+	var fallback *Machine
+	for i, machine := range machines {
+		switch {
+		case machine.Name == "" && fallback == nil:
+			fallback = &machines[i]
+		case machine.Name != "" && (strings.EqualFold(machine.Name, host) || strings.EqualFold(machine.Name, hostname)):
+			return machine.Login, machine.Password, machine.Login != "" || machine.Password != ""
+		}
+	}
+
+	if fallback != nil {
+		return fallback.Login, fallback.Password, fallback.Login != "" || fallback.Password != ""
+	}
+
+	return "", "", false
+}
@@ -0,0 +1,78 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package netrc
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const testNetrc = `# registries
+machine registry.example.com login builder password s3cret
+machine git.example.com:8443
+  login git
+  password token
+  account ignored
+
+macdef init
+  cd /pub
+  machine evil.example.com login macro password macro
+
+default login anonymous password guest
+`
+
+func TestParse(t *testing.T) {
+	expected := []Machine{
+		{Name: "registry.example.com", Login: "builder", Password: "s3cret"},
+		{Name: "git.example.com:8443", Login: "git", Password: "token"},
+		{Login: "anonymous", Password: "guest"},
+	}
+
+	if machines := Parse([]byte(testNetrc)); !reflect.DeepEqual(machines, expected) {
+		t.Errorf("expected %+v, got %+v", expected, machines)
+	}
+}
+
+func TestLookup(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "netrc")
+	if err := os.WriteFile(path, []byte(testNetrc), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("NETRC", path)
+	cases := map[string][2]string{
+		"registry.example.com":      {"builder", "s3cret"},
+		"registry.example.com:5000": {"builder", "s3cret"},
+		"git.example.com:8443":      {"git", "token"},
+		"evil.example.com":          {"anonymous", "guest"},
+	}
+
+	for host, expected := range cases {
+		login, password, ok := Lookup(host)
+		if !ok || login != expected[0] || password != expected[1] {
+			t.Errorf("%v: expected %v, got %v %v %v", host, expected, login, password, ok)
+		}
+	}
+
+	if err := os.WriteFile(path, []byte("machine registry.example.com login builder password s3cret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, _, ok := Lookup("other.example.com"); ok {
+		t.Error("expected no credentials without a default entry")
+	}
+}
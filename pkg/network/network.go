@@ -0,0 +1,128 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package network applies witness's global network settings: a proxy for all outgoing requests, and an offline mode
+// that refuses to make them.
+package network
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+)
+
+var offline = false
+
+type ErrOffline struct {
+	Operation string
+}
+
+func (e ErrOffline) Error() string {
+	return fmt.Sprintf("%v requires network access, which is disabled by --offline", e.Operation)
+}
+
+// EnableOffline turns on offline mode for the lifetime of the process. Requests made with http.DefaultTransport fail
+// without connecting, and Check fails for operations that need the network.
+func EnableOffline() {
+	offline = true
+	http.DefaultTransport = offlineTransport{}
+}
+
+// Offline returns true if witness is in offline mode.
+func Offline() bool {
+	return offline
+}
+
+// Check returns an error if witness is in offline mode, so operations that need the network fail before doing any
+// work.
+func Check(operation string) error {
+	if offline {
+		return ErrOffline{Operation: operation}
+	}
+
+	return nil
+}
+
+// NewTransport returns a transport with the settings of Go's default transport that honors the proxy set with SetProxy
+// and refuses to connect in offline mode, for clients that need to customize their transport, such as its TLS config.
+// dialTimeout limits connecting, including the TLS handshake. 0 uses the defaults.
+func NewTransport(dialTimeout time.Duration) *http.Transport {
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	tlsHandshakeTimeout := 10 * time.Second
+	if dialTimeout > 0 {
+		dialer.Timeout = dialTimeout
+		tlsHandshakeTimeout = dialTimeout
+	}
+
+	return &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: func(ctx context.Context, network, address string) (net.Conn, error) {
+			if offline {
+				return nil, ErrOffline{Operation: fmt.Sprintf("connecting to %v", address)}
+			}
+
+			return dialer.DialContext(ctx, network, address)
+		},
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   tlsHandshakeTimeout,
+		ExpectContinueTimeout: time.Second,
+	}
+}
+
+type offlineTransport struct{}
+
+func (offlineTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return nil, ErrOffline{Operation: fmt.Sprintf("request to %v", req.URL.Host)}
+}
+
+// SetProxy sends all outgoing http and https requests through the proxy, except those to hosts matched by noProxy,
+// a comma separated list in the format of the NO_PROXY environment variable. The proxy is set in the environment so
+// every client witness uses, including the gRPC clients of signing services, honors it. Commands run by witness
+// inherit the environment as well.
+func SetProxy(proxy, noProxy string) error {
+	if proxy == "" {
+		return nil
+	}
+
+	u, err := url.Parse(proxy)
+	if err != nil || u.Host == "" {
+		return fmt.Errorf("invalid proxy url %v", proxy)
+	}
+
+	env := map[string]string{
+		"HTTP_PROXY":  proxy,
+		"HTTPS_PROXY": proxy,
+		"http_proxy":  proxy,
+		"https_proxy": proxy,
+	}
+
+	if noProxy != "" {
+		env["NO_PROXY"] = noProxy
+		env["no_proxy"] = noProxy
+	}
+
+	for k, v := range env {
+		if err := os.Setenv(k, v); err != nil {
+			return fmt.Errorf("failed to set %v: %w", k, err)
+		}
+	}
+
+	return nil
+}
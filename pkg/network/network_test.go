@@ -0,0 +1,84 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package network
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestOffline(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	oldTransport := http.DefaultTransport
+	defer func() {
+		http.DefaultTransport = oldTransport
+		offline = false
+	}()
+
+	if err := Check("storing attestations in rekor"); err != nil {
+		t.Fatalf("expected check to pass while online: %v", err)
+	}
+
+	EnableOffline()
+	if err := Check("storing attestations in rekor"); !errors.As(err, &ErrOffline{}) {
+		t.Errorf("expected offline error, got %v", err)
+	}
+
+	if _, err := http.Get(server.URL); !errors.As(err, &ErrOffline{}) {
+		t.Errorf("expected request to fail with offline error, got %v", err)
+	}
+
+	client := &http.Client{Transport: NewTransport(0)}
+	if _, err := client.Get(server.URL); !errors.As(err, &ErrOffline{}) {
+		t.Errorf("expected request with a new transport to fail with offline error, got %v", err)
+	}
+}
+
+func TestNewTransport(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	client := &http.Client{Transport: NewTransport(time.Second)}
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("expected request to succeed while online: %v", err)
+	}
+
+	resp.Body.Close()
+}
+
+func TestSetProxy(t *testing.T) {
+	for _, k := range []string{"HTTP_PROXY", "HTTPS_PROXY", "http_proxy", "https_proxy", "NO_PROXY", "no_proxy"} {
+		t.Setenv(k, "")
+	}
+
+	if err := SetProxy("http://proxy.example.com:3128", "localhost,.internal"); err != nil {
+		t.Fatal(err)
+	}
+
+	if os.Getenv("HTTPS_PROXY") != "http://proxy.example.com:3128" || os.Getenv("NO_PROXY") != "localhost,.internal" {
+		t.Errorf("expected proxy to be set in the environment")
+	}
+
+	if err := SetProxy("proxy.example.com", ""); err == nil {
+		t.Error("expected proxy without a scheme to fail")
+	}
+}
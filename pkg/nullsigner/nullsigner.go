@@ -0,0 +1,110 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package nullsigner signs with a throwaway key, so witness can be integrated into a pipeline and policies can be
+// tested before real key material is provisioned.
+package nullsigner
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/dsse"
+)
+
+const (
+	// CommonName is the subject common name of every null signer certificate.
+	CommonName = "witness null signer"
+	// Organization marks null signer certificates as untrusted to anyone reading them.
+	Organization = "UNTRUSTED - for testing only"
+
+	// validity is how long a null signer certificate is valid for.
+	validity = 24 * time.Hour
+	// clockSkew is subtracted from the certificate's start time to tolerate clocks that are slightly behind.
+	clockSkew = 5 * time.Minute
+)
+
+// NewSigner generates an ECDSA P-256 key and a self-signed certificate for it marked as the null signer. The key only
+// exists in memory and is discarded with the signer, so the envelopes it signs are structurally valid but can never
+// satisfy a policy.
+func NewSigner() (cryptoutil.Signer, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate null signer key: %w", err)
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate serial number: %w", err)
+	}
+
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: CommonName, Organization: []string{Organization}},
+		NotBefore:             now.Add(-clockSkew),
+		NotAfter:              now.Add(validity),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+		BasicConstraintsValid: true,
+	}
+
+	certBytes, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create null signer certificate: %w", err)
+	}
+
+	cert, err := x509.ParseCertificate(certBytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse null signer certificate: %w", err)
+	}
+
+	return cryptoutil.NewSigner(key, cryptoutil.SignWithCertificate(cert))
+}
+
+// IsNullCertificate returns true if the certificate was created by the null signer.
+func IsNullCertificate(cert *x509.Certificate) bool {
+	if cert.Subject.CommonName != CommonName || len(cert.Subject.Organization) != 1 || cert.Subject.Organization[0] != Organization {
+		return false
+	}
+
+	return cert.CheckSignature(cert.SignatureAlgorithm, cert.RawTBSCertificate, cert.Signature) == nil
+}
+
+// Signed returns true if any of the envelope's signatures carries a null signer certificate.
+func Signed(env dsse.Envelope) bool {
+	for _, sig := range env.Signatures {
+		if len(sig.Certificate) == 0 {
+			continue
+		}
+
+		cert, err := dsse.TryParseCertificate(sig.Certificate)
+		if err != nil {
+			continue
+		}
+
+		if IsNullCertificate(cert) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,70 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package nullsigner
+
+import (
+	"bytes"
+	"crypto/x509"
+	"testing"
+
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/dsse"
+)
+
+func TestNewSigner(t *testing.T) {
+	signer, err := NewSigner()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	x509Signer, ok := signer.(*cryptoutil.X509Signer)
+	if !ok {
+		t.Fatalf("expected an x509 signer, got %T", signer)
+	}
+
+	if !IsNullCertificate(x509Signer.Certificate()) {
+		t.Error("expected the signer's certificate to be marked as the null signer")
+	}
+
+	env, err := dsse.Sign("https://witness.testifysec.com/test/v0.1", bytes.NewReader([]byte("payload")), signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := env.Verify(dsse.WithRoots([]*x509.Certificate{x509Signer.Certificate()})); err != nil {
+		t.Errorf("expected the envelope to be structurally valid: %v", err)
+	}
+
+	if !Signed(env) {
+		t.Error("expected the envelope to be recognized as signed by the null signer")
+	}
+
+	other, err := NewSigner()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	otherCert := other.(*cryptoutil.X509Signer).Certificate()
+	if _, err := env.Verify(dsse.WithRoots([]*x509.Certificate{otherCert})); err == nil {
+		t.Error("expected each null signer to have its own key")
+	}
+}
+
+func TestSignedWithoutCertificate(t *testing.T) {
+	env := dsse.Envelope{Signatures: []dsse.Signature{{KeyID: "key", Signature: []byte("sig")}}}
+	if Signed(env) {
+		t.Error("expected an envelope without certificates not to be signed by the null signer")
+	}
+}
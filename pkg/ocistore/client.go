@@ -0,0 +1,250 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ocistore
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+)
+
+// CredentialsFunc returns the username and password to authenticate to the registry with, if there are any.
+type CredentialsFunc func(ctx context.Context, registry string) (username, password string, ok bool)
+
+// Client is a minimal client for the OCI distribution API. It authenticates with the registry's token service when
+// the registry asks it to, using the credentials for the registry if there are any and anonymously otherwise.
+type Client struct {
+	HTTPClient  *http.Client
+	Credentials CredentialsFunc
+
+	mu     sync.Mutex
+	tokens map[string]string
+}
+
+// NewClient returns a client that authenticates with the credentials DefaultCredentials finds.
+func NewClient() *Client {
+	return &Client{HTTPClient: http.DefaultClient, Credentials: DefaultCredentials}
+}
+
+// do sends a request to the repository's registry. path is relative to the repository's API, unless it is an
+// absolute URL such as an upload location. A request the registry rejects for missing authorization is sent again
+// once the client has authenticated as the registry's challenge asks.
+func (c *Client) do(ctx context.Context, ref Reference, method, path string, header http.Header, body []byte) (*http.Response, error) {
+	target := path
+	if !strings.HasPrefix(path, "http://") && !strings.HasPrefix(path, "https://") {
+		target = ref.baseURL() + "/v2/" + ref.Repository + path
+	}
+
+	send := func(authorization string) (*http.Response, error) {
+		var reader io.Reader
+		if body != nil {
+			reader = bytes.NewReader(body)
+		}
+
+		req, err := http.NewRequestWithContext(ctx, method, target, reader)
+		if err != nil {
+			return nil, err
+		}
+
+		for key, values := range header {
+			req.Header[key] = values
+		}
+
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+
+		return c.httpClient().Do(req)
+	}
+
+	key := ref.Registry + "/" + ref.Repository
+	resp, err := send(c.token(key))
+	if err != nil || resp.StatusCode != http.StatusUnauthorized {
+		return resp, err
+	}
+
+	challenge := resp.Header.Get("WWW-Authenticate")
+	resp.Body.Close()
+	authorization, err := c.authenticate(ctx, ref.Registry, challenge)
+	if err != nil {
+		return nil, fmt.Errorf("failed to authenticate to %v: %w", ref.Registry, err)
+	}
+
+	c.setToken(key, authorization)
+	return send(authorization)
+}
+
+// authenticate returns the authorization header the challenge asks for. Bearer challenges are answered with a token
+// from the registry's token service, and basic challenges with the registry's credentials. An identity token is
+// exchanged for an access token with the OAuth2 refresh token grant, as docker does.
+func (c *Client) authenticate(ctx context.Context, registry, challenge string) (string, error) {
+	scheme, params := parseChallenge(challenge)
+	username, password, hasCredentials := "", "", false
+	if c.Credentials != nil {
+		username, password, hasCredentials = c.Credentials(ctx, registry)
+	}
+
+	switch strings.ToLower(scheme) {
+	case "basic":
+		if !hasCredentials {
+			return "", fmt.Errorf("registry requires credentials")
+		}
+
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(username+":"+password)), nil
+	case "bearer":
+	default:
+		return "", fmt.Errorf("unsupported authentication challenge %q", challenge)
+	}
+
+	realm, err := url.Parse(params["realm"])
+	if err != nil || params["realm"] == "" {
+		return "", fmt.Errorf("invalid token realm %q", params["realm"])
+	}
+
+	query := realm.Query()
+	for _, param := range []string{"service", "scope"} {
+		if params[param] != "" {
+			query.Set(param, params[param])
+		}
+	}
+
+	var req *http.Request
+	if hasCredentials && username == identityTokenUsername {
+		query.Set("grant_type", "refresh_token")
+		query.Set("refresh_token", password)
+		query.Set("client_id", "witness")
+		req, err = http.NewRequestWithContext(ctx, http.MethodPost, realm.String(), strings.NewReader(query.Encode()))
+		if err != nil {
+			return "", err
+		}
+
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	} else {
+		realm.RawQuery = query.Encode()
+		req, err = http.NewRequestWithContext(ctx, http.MethodGet, realm.String(), nil)
+		if err != nil {
+			return "", err
+		}
+
+		if hasCredentials {
+			req.SetBasicAuth(username, password)
+		}
+	}
+
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return "", err
+	}
+
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token service returned %v", resp.Status)
+	}
+
+	token := struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}{}
+
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", fmt.Errorf("failed to decode token: %w", err)
+	}
+
+	if token.Token == "" {
+		token.Token = token.AccessToken
+	}
+
+	if token.Token == "" {
+		return "", fmt.Errorf("token service did not return a token")
+	}
+
+	return "Bearer " + token.Token, nil
+}
+
+func (c *Client) httpClient() *http.Client {
+	if c.HTTPClient == nil {
+		return http.DefaultClient
+	}
+
+	return c.HTTPClient
+}
+
+func (c *Client) token(key string) string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.tokens[key]
+}
+
+func (c *Client) setToken(key, authorization string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.tokens == nil {
+		c.tokens = make(map[string]string)
+	}
+
+	c.tokens[key] = authorization
+}
+
+// parseChallenge splits a WWW-Authenticate header into its scheme and parameters. Parameter values may be quoted,
+// and quoted values may contain commas, such as a scope of repository:org/repo:pull,push.
+func parseChallenge(challenge string) (string, map[string]string) {
+	params := make(map[string]string)
+	challenge = strings.TrimSpace(challenge)
+	i := strings.IndexByte(challenge, ' ')
+	if i < 0 {
+		return challenge, params
+	}
+
+	scheme, rest := challenge[:i], challenge[i+1:]
+	for rest != "" {
+		rest = strings.TrimLeft(rest, ", ")
+		eq := strings.IndexByte(rest, '=')
+		if eq < 0 {
+			break
+		}
+
+		name := strings.ToLower(strings.TrimSpace(rest[:eq]))
+		rest = rest[eq+1:]
+		value := ""
+		if strings.HasPrefix(rest, "\"") {
+			end := strings.IndexByte(rest[1:], '"')
+			if end < 0 {
+				value, rest = rest[1:], ""
+			} else {
+				value, rest = rest[1:end+1], rest[end+2:]
+			}
+		} else if comma := strings.IndexByte(rest, ','); comma >= 0 {
+			value, rest = rest[:comma], rest[comma+1:]
+		} else {
+			value, rest = rest, ""
+		}
+
+		params[name] = value
+	}
+
+	return scheme, params
+}
+
+func statusError(resp *http.Response) error {
+	message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return fmt.Errorf("unexpected status %v: %s", resp.Status, bytes.TrimSpace(message))
+}
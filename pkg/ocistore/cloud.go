@@ -0,0 +1,269 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ocistore
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/ecr"
+	"github.com/testifysec/go-witness/log"
+)
+
+const (
+	// gcpUsername is the username registries on Google Cloud accept an OAuth2 access token as the password for.
+	gcpUsername = "oauth2accesstoken"
+	// acrUsername is the username ACR accepts a refresh token from its token exchange as the password for.
+	acrUsername = "00000000-0000-0000-0000-000000000000"
+	// azureResource is the resource Azure access tokens are requested for to exchange them with ACR.
+	azureResource = "https://management.azure.com/"
+	// cliTokenLifetime is how long an access token printed by a CLI is reused, since its expiry isn't printed.
+	cliTokenLifetime = 10 * time.Minute
+)
+
+var (
+	ecrPattern = regexp.MustCompile(`^(\d{12})\.dkr\.ecr(?:-fips)?\.([a-z0-9-]+)\.amazonaws\.com(?:\.cn)?$`)
+	gcpPattern = regexp.MustCompile(`^(?:[a-z]+\.)?gcr\.io$|^[a-z0-9-]+-docker\.pkg\.dev$`)
+	acrPattern = regexp.MustCompile(`^[a-z0-9]+\.azurecr\.(?:io|cn|us)$`)
+
+	// metadataClient is used for the instance metadata services, which only answer on the clouds' own instances
+	metadataClient = &http.Client{Timeout: 2 * time.Second}
+)
+
+// The token sources below are variables so tests can replace them.
+var (
+	runCommand = func(ctx context.Context, name string, args ...string) ([]byte, error) {
+		return exec.CommandContext(ctx, name, args...).Output()
+	}
+
+	ecrToken         = awsECRToken
+	gcpAccessToken   = googleAccessToken
+	azureAccessToken = azureADAccessToken
+	acrExchangeURL   = func(registry string) string { return "https://" + registry + "/oauth2/exchange" }
+)
+
+type cloudCredential struct {
+	username string
+	password string
+	expires  time.Time
+}
+
+var (
+	cloudMu    sync.Mutex
+	cloudCache = make(map[string]cloudCredential)
+)
+
+// CloudCredentials exchanges the ambient credentials of a cloud provider for credentials to its registry, so private
+// registries work in CI without a docker login step:
+//
+//   - ECR registries get an authorization token from ECR with the AWS SDK's default credential chain.
+//   - gcr.io and Artifact Registry accept an access token from gcloud, or from the metadata server on Google Cloud.
+//   - ACR exchanges an Azure AD access token from the az CLI, or from the managed identity of an Azure instance, for
+//     a refresh token to the registry.
+//
+// Credentials are reused until shortly before they expire. Other registries have no cloud credentials.
+func CloudCredentials(ctx context.Context, registry string) (string, string, bool) {
+	cloudMu.Lock()
+	cached, ok := cloudCache[registry]
+	cloudMu.Unlock()
+	if ok && time.Now().Add(time.Minute).Before(cached.expires) {
+		return cached.username, cached.password, true
+	}
+
+	var (
+		credential cloudCredential
+		err        error
+	)
+
+	switch {
+	case ecrPattern.MatchString(registry):
+		match := ecrPattern.FindStringSubmatch(registry)
+		credential.username, credential.password, credential.expires, err = ecrToken(ctx, match[2], match[1])
+	case gcpPattern.MatchString(registry):
+		credential.username = gcpUsername
+		credential.password, credential.expires, err = gcpAccessToken(ctx)
+	case acrPattern.MatchString(registry):
+		credential.username = acrUsername
+		credential.password, credential.expires, err = acrRefreshToken(ctx, registry)
+	default:
+		return "", "", false
+	}
+
+	if err != nil {
+		log.Warnf("Failed to get cloud credentials for %v: %v", registry, err)
+		return "", "", false
+	}
+
+	cloudMu.Lock()
+	cloudCache[registry] = credential
+	cloudMu.Unlock()
+	return credential.username, credential.password, true
+}
+
+func awsECRToken(ctx context.Context, region, account string) (string, string, time.Time, error) {
+	sess, err := session.NewSessionWithOptions(session.Options{
+		Config:            *aws.NewConfig().WithRegion(region),
+		SharedConfigState: session.SharedConfigEnable,
+	})
+	if err != nil {
+		return "", "", time.Time{}, fmt.Errorf("failed to create aws session: %w", err)
+	}
+
+	out, err := ecr.New(sess).GetAuthorizationTokenWithContext(ctx, &ecr.GetAuthorizationTokenInput{
+		RegistryIds: []*string{aws.String(account)},
+	})
+	if err != nil {
+		return "", "", time.Time{}, fmt.Errorf("failed to get ecr authorization token: %w", err)
+	}
+
+	if len(out.AuthorizationData) == 0 {
+		return "", "", time.Time{}, fmt.Errorf("ecr did not return an authorization token")
+	}
+
+	data := out.AuthorizationData[0]
+	decoded, err := base64.StdEncoding.DecodeString(aws.StringValue(data.AuthorizationToken))
+	if err != nil {
+		return "", "", time.Time{}, fmt.Errorf("failed to decode ecr authorization token: %w", err)
+	}
+
+	parts := strings.SplitN(string(decoded), ":", 2)
+	if len(parts) != 2 {
+		return "", "", time.Time{}, fmt.Errorf("ecr authorization token is not in username:password form")
+	}
+
+	return parts[0], parts[1], aws.TimeValue(data.ExpiresAt), nil
+}
+
+// googleAccessToken returns an access token from gcloud, or for the instance's service account from the metadata
+// server, which GCE_METADATA_HOST overrides.
+func googleAccessToken(ctx context.Context) (string, time.Time, error) {
+	if out, err := runCommand(ctx, "gcloud", "auth", "print-access-token"); err == nil && len(strings.TrimSpace(string(out))) > 0 {
+		return strings.TrimSpace(string(out)), time.Now().Add(cliTokenLifetime), nil
+	}
+
+	host := os.Getenv("GCE_METADATA_HOST")
+	if host == "" {
+		host = "metadata.google.internal"
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://"+host+"/computeMetadata/v1/instance/service-accounts/default/token", nil)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	req.Header.Set("Metadata-Flavor", "Google")
+	return metadataToken(req, "gcloud is not logged in and the google cloud metadata server")
+}
+
+// azureADAccessToken returns an access token from the az CLI, or for the instance's managed identity from the Azure
+// instance metadata service.
+func azureADAccessToken(ctx context.Context) (string, time.Time, error) {
+	if out, err := runCommand(ctx, "az", "account", "get-access-token", "--resource", azureResource, "--query", "accessToken", "--output", "tsv"); err == nil && len(strings.TrimSpace(string(out))) > 0 {
+		return strings.TrimSpace(string(out)), time.Now().Add(cliTokenLifetime), nil
+	}
+
+	query := url.Values{"api-version": {"2018-02-01"}, "resource": {azureResource}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://169.254.169.254/metadata/identity/oauth2/token?"+query.Encode(), nil)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	req.Header.Set("Metadata", "true")
+	return metadataToken(req, "az is not logged in and the azure instance metadata service")
+}
+
+// metadataToken requests an access token from an instance metadata service. Azure's returns expires_in as a string.
+func metadataToken(req *http.Request, source string) (string, time.Time, error) {
+	resp, err := metadataClient.Do(req)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("%v is not available: %w", source, err)
+	}
+
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", time.Time{}, fmt.Errorf("%v returned %v", source, resp.Status)
+	}
+
+	token := struct {
+		AccessToken string          `json:"access_token"`
+		ExpiresIn   json.RawMessage `json:"expires_in"`
+	}{}
+
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to decode access token: %w", err)
+	}
+
+	if token.AccessToken == "" {
+		return "", time.Time{}, fmt.Errorf("%v did not return an access token", source)
+	}
+
+	seconds := 0
+	if err := json.Unmarshal(token.ExpiresIn, &seconds); err != nil {
+		var s string
+		if json.Unmarshal(token.ExpiresIn, &s) == nil {
+			fmt.Sscan(s, &seconds)
+		}
+	}
+
+	return token.AccessToken, time.Now().Add(time.Duration(seconds) * time.Second), nil
+}
+
+// acrRefreshToken exchanges an Azure AD access token for a refresh token to the registry. ACR's refresh tokens are
+// valid for three hours.
+func acrRefreshToken(ctx context.Context, registry string) (string, time.Time, error) {
+	accessToken, _, err := azureAccessToken(ctx)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	form := url.Values{"grant_type": {"access_token"}, "service": {registry}, "access_token": {accessToken}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, acrExchangeURL(registry), strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to exchange azure access token with %v: %w", registry, err)
+	}
+
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", time.Time{}, fmt.Errorf("%v returned %v when exchanging an azure access token", registry, resp.Status)
+	}
+
+	exchanged := struct {
+		RefreshToken string `json:"refresh_token"`
+	}{}
+
+	if err := json.NewDecoder(resp.Body).Decode(&exchanged); err != nil || exchanged.RefreshToken == "" {
+		return "", time.Time{}, fmt.Errorf("%v did not return a refresh token", registry)
+	}
+
+	return exchanged.RefreshToken, time.Now().Add(3 * time.Hour), nil
+}
@@ -0,0 +1,172 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ocistore
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/testifysec/go-witness/log"
+	"github.com/testifysec/witness/pkg/netrc"
+)
+
+// identityTokenUsername is the username docker config files and credential helpers give for an OAuth2 refresh token,
+// which is exchanged for an access token at the registry's token service instead of being sent as a password.
+const identityTokenUsername = "<token>"
+
+// dockerHubServer is the address docker records Docker Hub's credentials under.
+const dockerHubServer = "https://index.docker.io/v1/"
+
+// credentialHelper runs docker-credential-<helper> get for the server. It is a variable so tests can replace it.
+var credentialHelper = func(ctx context.Context, helper, server string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, "docker-credential-"+helper, "get")
+	cmd.Stdin = strings.NewReader(server)
+	return cmd.Output()
+}
+
+type dockerConfig struct {
+	Auths map[string]struct {
+		Auth          string `json:"auth"`
+		Username      string `json:"username"`
+		Password      string `json:"password"`
+		IdentityToken string `json:"identitytoken"`
+	} `json:"auths"`
+	CredHelpers map[string]string `json:"credHelpers"`
+	CredsStore  string            `json:"credsStore"`
+}
+
+// DefaultCredentials returns the credentials for the registry from the docker config file and its credential helpers,
+// then from the registry's cloud provider for ECR, GCR and Artifact Registry, and ACR, and then from .netrc.
+func DefaultCredentials(ctx context.Context, registry string) (string, string, bool) {
+	for _, credentials := range []CredentialsFunc{DockerConfigCredentials, CloudCredentials} {
+		if username, password, ok := credentials(ctx, registry); ok {
+			return username, password, true
+		}
+	}
+
+	return netrc.Lookup(registry)
+}
+
+// DockerConfigCredentials returns the credentials for the registry stored in the docker config file, which is
+// config.json in DOCKER_CONFIG or ~/.docker. As docker does, the registry's entry in credHelpers names the credential
+// helper to ask, then the credsStore helper is asked, and then the credentials in auths are used. Entries for Docker
+// Hub's legacy index address are used for docker.io.
+func DockerConfigCredentials(ctx context.Context, registry string) (string, string, bool) {
+	dir := os.Getenv("DOCKER_CONFIG")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", "", false
+		}
+
+		dir = filepath.Join(home, ".docker")
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "config.json"))
+	if err != nil {
+		return "", "", false
+	}
+
+	config := dockerConfig{}
+	if err := json.Unmarshal(data, &config); err != nil {
+		return "", "", false
+	}
+
+	helpers := make([]string, 0, 2)
+	for address, helper := range config.CredHelpers {
+		if configRegistry(address) == configRegistry(registry) {
+			helpers = append(helpers, helper)
+		}
+	}
+
+	if config.CredsStore != "" {
+		helpers = append(helpers, config.CredsStore)
+	}
+
+	for _, helper := range helpers {
+		if username, password, ok := helperCredentials(ctx, helper, registry); ok {
+			return username, password, true
+		}
+	}
+
+	for address, auth := range config.Auths {
+		if configRegistry(address) != configRegistry(registry) {
+			continue
+		}
+
+		if auth.IdentityToken != "" {
+			return identityTokenUsername, auth.IdentityToken, true
+		}
+
+		if auth.Username != "" {
+			return auth.Username, auth.Password, true
+		}
+
+		decoded, err := base64.StdEncoding.DecodeString(auth.Auth)
+		if err != nil {
+			continue
+		}
+
+		if parts := strings.SplitN(string(decoded), ":", 2); len(parts) == 2 {
+			return parts[0], parts[1], true
+		}
+	}
+
+	return "", "", false
+}
+
+// helperCredentials asks a docker credential helper for the registry's credentials. Helpers exit with an error when
+// they have none.
+func helperCredentials(ctx context.Context, helper, registry string) (string, string, bool) {
+	server := registry
+	if configRegistry(registry) == "docker.io" {
+		server = dockerHubServer
+	}
+
+	out, err := credentialHelper(ctx, helper, server)
+	if err != nil {
+		log.Debugf("(oci) docker-credential-%v has no credentials for %v: %v", helper, registry, err)
+		return "", "", false
+	}
+
+	credentials := struct {
+		Username string `json:"Username"`
+		Secret   string `json:"Secret"`
+	}{}
+
+	if err := json.Unmarshal(bytes.TrimSpace(out), &credentials); err != nil || credentials.Secret == "" {
+		return "", "", false
+	}
+
+	return credentials.Username, credentials.Secret, true
+}
+
+// configRegistry returns the registry host of a docker config address, which may be a URL.
+func configRegistry(address string) string {
+	address = strings.TrimPrefix(strings.TrimPrefix(address, "https://"), "http://")
+	address = strings.Split(address, "/")[0]
+	switch address {
+	case "index.docker.io", "registry-1.docker.io":
+		return "docker.io"
+	}
+
+	return address
+}
@@ -0,0 +1,221 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ocistore
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeDockerConfig(t *testing.T, config map[string]interface{}) {
+	dir := t.TempDir()
+	data, err := json.Marshal(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "config.json"), data, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("DOCKER_CONFIG", dir)
+}
+
+func TestDockerConfigCredentialHelpers(t *testing.T) {
+	writeDockerConfig(t, map[string]interface{}{
+		"credHelpers": map[string]string{"registry.example.com": "corp"},
+		"credsStore":  "desktop",
+		"auths": map[string]interface{}{
+			"quay.io":              map[string]string{},
+			"registry.example.com": map[string]string{"username": "stale", "password": "stale"},
+			"azurecr.example.com":  map[string]string{"identitytoken": "refresh"},
+		},
+	})
+
+	oldHelper := credentialHelper
+	defer func() { credentialHelper = oldHelper }()
+	asked := make([]string, 0)
+	credentialHelper = func(ctx context.Context, helper, server string) ([]byte, error) {
+		asked = append(asked, helper+" "+server)
+		switch {
+		case helper == "corp" && server == "registry.example.com":
+			return []byte(`{"ServerURL": "registry.example.com", "Username": "builder", "Secret": "s3cret"}`), nil
+		case helper == "desktop" && server == dockerHubServer:
+			return []byte(`{"ServerURL": "https://index.docker.io/v1/", "Username": "hub", "Secret": "hubsecret"}`), nil
+		default:
+			return nil, fmt.Errorf("credentials not found in native keychain")
+		}
+	}
+
+	if username, password, ok := DockerConfigCredentials(context.Background(), "registry.example.com"); !ok || username != "builder" || password != "s3cret" {
+		t.Errorf("expected the credHelpers helper's credentials, got %v %v %v", username, password, ok)
+	}
+
+	if username, password, ok := DockerConfigCredentials(context.Background(), "docker.io"); !ok || username != "hub" || password != "hubsecret" {
+		t.Errorf("expected the credsStore helper's credentials for docker hub, got %v %v %v", username, password, ok)
+	}
+
+	if username, password, ok := DockerConfigCredentials(context.Background(), "azurecr.example.com"); !ok || username != identityTokenUsername || password != "refresh" {
+		t.Errorf("expected the identity token, got %v %v %v", username, password, ok)
+	}
+
+	if _, _, ok := DockerConfigCredentials(context.Background(), "quay.io"); ok {
+		t.Error("expected no credentials for an empty auths entry")
+	}
+
+	if asked[0] != "corp registry.example.com" {
+		t.Errorf("expected the credHelpers helper to be asked first, got %v", asked)
+	}
+}
+
+func TestIdentityTokenExchange(t *testing.T) {
+	var grant, refreshToken string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/token" {
+			if req.Method != http.MethodPost {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+
+			_ = req.ParseForm()
+			grant, refreshToken = req.PostForm.Get("grant_type"), req.PostForm.Get("refresh_token")
+			fmt.Fprint(w, `{"access_token": "access"}`)
+			return
+		}
+
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	client := &Client{Credentials: func(context.Context, string) (string, string, bool) { return identityTokenUsername, "refresh", true }}
+	authorization, err := client.authenticate(context.Background(), "registry.example.com", fmt.Sprintf(`Bearer realm="%v/token",service="registry.example.com",scope="repository:org/app:pull"`, server.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if authorization != "Bearer access" || grant != "refresh_token" || refreshToken != "refresh" {
+		t.Errorf("expected a refresh token grant, got %v %v %v", authorization, grant, refreshToken)
+	}
+}
+
+func TestCloudCredentials(t *testing.T) {
+	oldECR, oldGCP, oldAzure, oldExchange := ecrToken, gcpAccessToken, azureAccessToken, acrExchangeURL
+	defer func() {
+		ecrToken, gcpAccessToken, azureAccessToken, acrExchangeURL = oldECR, oldGCP, oldAzure, oldExchange
+		cloudCache = make(map[string]cloudCredential)
+	}()
+
+	ecrCalls := 0
+	ecrToken = func(ctx context.Context, region, account string) (string, string, time.Time, error) {
+		ecrCalls++
+		if region != "us-west-2" || account != "123456789012" {
+			return "", "", time.Time{}, fmt.Errorf("unexpected registry %v %v", region, account)
+		}
+
+		return "AWS", "ecrpassword", time.Now().Add(12 * time.Hour), nil
+	}
+
+	gcpAccessToken = func(ctx context.Context) (string, time.Time, error) {
+		return "ya29.token", time.Now().Add(time.Hour), nil
+	}
+
+	azureAccessToken = func(ctx context.Context) (string, time.Time, error) {
+		return "aad", time.Now().Add(time.Hour), nil
+	}
+
+	exchange := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		_ = req.ParseForm()
+		if req.PostForm.Get("grant_type") != "access_token" || req.PostForm.Get("access_token") != "aad" || req.PostForm.Get("service") != "myregistry.azurecr.io" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		fmt.Fprint(w, `{"refresh_token": "acrrefresh"}`)
+	}))
+	defer exchange.Close()
+	acrExchangeURL = func(registry string) string { return exchange.URL + "/oauth2/exchange" }
+
+	cases := map[string][2]string{
+		"123456789012.dkr.ecr.us-west-2.amazonaws.com": {"AWS", "ecrpassword"},
+		"gcr.io":                     {gcpUsername, "ya29.token"},
+		"eu.gcr.io":                  {gcpUsername, "ya29.token"},
+		"us-central1-docker.pkg.dev": {gcpUsername, "ya29.token"},
+		"myregistry.azurecr.io":      {acrUsername, "acrrefresh"},
+	}
+
+	for registry, expected := range cases {
+		username, password, ok := CloudCredentials(context.Background(), registry)
+		if !ok || username != expected[0] || password != expected[1] {
+			t.Errorf("%v: expected %v, got %v %v %v", registry, expected, username, password, ok)
+		}
+	}
+
+	if _, _, ok := CloudCredentials(context.Background(), "123456789012.dkr.ecr.us-west-2.amazonaws.com"); !ok || ecrCalls != 1 {
+		t.Errorf("expected the ecr token to be reused, got %v calls", ecrCalls)
+	}
+
+	if _, _, ok := CloudCredentials(context.Background(), "ghcr.io"); ok {
+		t.Error("expected no cloud credentials for ghcr.io")
+	}
+}
+
+func TestMetadataToken(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Header.Get("Metadata") != "true" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		// azure's instance metadata service returns expires_in as a string
+		fmt.Fprint(w, `{"access_token": "aad", "expires_in": "3599"}`)
+	}))
+	defer server.Close()
+
+	req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req.Header.Set("Metadata", "true")
+	token, expires, err := metadataToken(req, "metadata")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if token != "aad" || time.Until(expires) < 59*time.Minute {
+		t.Errorf("unexpected token %v expiring at %v", token, expires)
+	}
+}
+
+func TestDefaultCredentialsNetrc(t *testing.T) {
+	writeDockerConfig(t, map[string]interface{}{"auths": map[string]interface{}{}})
+	path := filepath.Join(t.TempDir(), "netrc")
+	if err := os.WriteFile(path, []byte("machine registry.example.com:5000 login builder password s3cret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("NETRC", path)
+	username, password, ok := DefaultCredentials(context.Background(), "registry.example.com:5000")
+	if !ok || username != "builder" || password != "s3cret" {
+		t.Errorf("expected the .netrc credentials, got %v %v %v", username, password, ok)
+	}
+}
@@ -0,0 +1,129 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ocistore stores DSSE envelopes in OCI registries, attached to the image they are about, and finds the
+// envelopes attached to an image. Envelopes are stored the way cosign stores attestations, in a manifest tagged
+// sha256-<hex>.att, with the image as the manifest's subject so registries with the referrers API also list them.
+package ocistore
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const Scheme = "oci://"
+
+var (
+	repositoryPattern = regexp.MustCompile(`^[a-z0-9]+(?:(?:[._]|__|-+)[a-z0-9]+)*(?:/[a-z0-9]+(?:(?:[._]|__|-+)[a-z0-9]+)*)*$`)
+	digestPattern     = regexp.MustCompile(`^sha256:[a-f0-9]{64}$`)
+	tagPattern        = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.-]{0,127}$`)
+)
+
+// Reference is a repository in an OCI registry, and optionally the digest of a manifest in it.
+type Reference struct {
+	Registry   string
+	Repository string
+	Digest     string
+}
+
+// IsReference returns true if s is in the form of a registry reference, oci://<registry>/<repository>[@<digest>].
+func IsReference(s string) bool {
+	return strings.HasPrefix(s, Scheme)
+}
+
+// ParseReference parses a reference in the form oci://<registry>/<repository>[@<digest>]. Repositories on docker.io
+// without a namespace are in the library namespace, as they are for docker.
+func ParseReference(s string) (Reference, error) {
+	if !IsReference(s) {
+		return Reference{}, fmt.Errorf("registry reference must start with %v: %v", Scheme, s)
+	}
+
+	rest := strings.TrimPrefix(s, Scheme)
+	ref := Reference{}
+	if i := strings.Index(rest, "@"); i >= 0 {
+		ref.Digest = rest[i+1:]
+		rest = rest[:i]
+		if !digestPattern.MatchString(ref.Digest) {
+			return Reference{}, fmt.Errorf("registry reference digest must be a sha256 digest in the form sha256:<hex>: %v", s)
+		}
+	}
+
+	parts := strings.SplitN(strings.TrimSuffix(rest, "/"), "/", 2)
+	if len(parts) != 2 || parts[0] == "" {
+		return Reference{}, fmt.Errorf("registry reference must be in the form %v<registry>/<repository>[@<digest>]: %v", Scheme, s)
+	}
+
+	ref.Registry, ref.Repository = parts[0], parts[1]
+	if !repositoryPattern.MatchString(ref.Repository) {
+		return Reference{}, fmt.Errorf("invalid repository %v, registry references can not have tags", ref.Repository)
+	}
+
+	if ref.Registry == "docker.io" && !strings.Contains(ref.Repository, "/") {
+		ref.Repository = "library/" + ref.Repository
+	}
+
+	return ref, nil
+}
+
+// ParseTaggedReference parses a reference to a tag in the form oci://<registry>/<repository>:<tag>.
+func ParseTaggedReference(s string) (Reference, string, error) {
+	i := strings.LastIndex(s, ":")
+	if i < 0 || i < strings.LastIndex(s, "/") || strings.Contains(s, "@") {
+		return Reference{}, "", fmt.Errorf("registry reference must be in the form %v<registry>/<repository>:<tag>: %v", Scheme, s)
+	}
+
+	ref, err := ParseReference(s[:i])
+	if err != nil {
+		return Reference{}, "", err
+	}
+
+	tag := s[i+1:]
+	if !tagPattern.MatchString(tag) {
+		return Reference{}, "", fmt.Errorf("invalid tag %v", tag)
+	}
+
+	return ref, tag, nil
+}
+
+// WithDigest returns the reference to the manifest or blob with the digest in the same repository.
+func (r Reference) WithDigest(digest string) Reference {
+	r.Digest = digest
+	return r
+}
+
+func (r Reference) String() string {
+	s := Scheme + r.Registry + "/" + r.Repository
+	if r.Digest != "" {
+		s += "@" + r.Digest
+	}
+
+	return s
+}
+
+// baseURL returns the URL of the registry's API. Registries on the loopback interface are reached over plain http.
+func (r Reference) baseURL() string {
+	host := r.Registry
+	if host == "docker.io" {
+		host = "registry-1.docker.io"
+	}
+
+	scheme := "https"
+	hostname := strings.Split(host, ":")[0]
+	if hostname == "localhost" || hostname == "127.0.0.1" {
+		scheme = "http"
+	}
+
+	return scheme + "://" + host
+}
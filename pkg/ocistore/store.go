@@ -0,0 +1,466 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ocistore
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/testifysec/go-witness/dsse"
+	"github.com/testifysec/go-witness/log"
+)
+
+const (
+	EnvelopeMediaType = "application/vnd.dsse.envelope.v1+json"
+	ManifestMediaType = "application/vnd.oci.image.manifest.v1+json"
+	IndexMediaType    = "application/vnd.oci.image.index.v1+json"
+	configMediaType   = "application/vnd.oci.image.config.v1+json"
+
+	// cosign marks the layers of attestation manifests with this annotation, which holds the signature of simple
+	// signing payloads and is empty for DSSE envelopes.
+	cosignSignatureAnnotation = "dev.cosignproject.cosign/signature"
+	predicateTypeAnnotation   = "predicateType"
+)
+
+// manifestMediaTypes are the manifest types an image may be stored as.
+var manifestMediaTypes = []string{
+	ManifestMediaType,
+	IndexMediaType,
+	"application/vnd.docker.distribution.manifest.v2+json",
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+}
+
+// maxEnvelopeSize limits the size of envelopes read from a registry.
+const maxEnvelopeSize = 32 << 20
+
+type Descriptor struct {
+	MediaType    string            `json:"mediaType"`
+	Digest       string            `json:"digest"`
+	Size         int64             `json:"size"`
+	ArtifactType string            `json:"artifactType,omitempty"`
+	Annotations  map[string]string `json:"annotations,omitempty"`
+}
+
+type Manifest struct {
+	SchemaVersion int          `json:"schemaVersion"`
+	MediaType     string       `json:"mediaType"`
+	ArtifactType  string       `json:"artifactType,omitempty"`
+	Config        Descriptor   `json:"config"`
+	Layers        []Descriptor `json:"layers"`
+	Subject       *Descriptor  `json:"subject,omitempty"`
+}
+
+type index struct {
+	Manifests []Descriptor `json:"manifests"`
+}
+
+// Found is an envelope found in a registry and the reference of the blob it was read from.
+type Found struct {
+	Envelope  dsse.Envelope
+	Reference string
+}
+
+// AttestationTag returns the tag cosign stores the attestations about the digest under, sha256-<hex>.att.
+func AttestationTag(digest string) string {
+	return strings.Replace(digest, ":", "-", 1) + ".att"
+}
+
+// Resolve returns the descriptor of the manifest with the reference's digest, and false if the repository has no
+// such manifest.
+func (c *Client) Resolve(ctx context.Context, ref Reference) (Descriptor, bool, error) {
+	header := http.Header{"Accept": {strings.Join(manifestMediaTypes, ", ")}}
+	resp, err := c.do(ctx, ref, http.MethodHead, "/manifests/"+ref.Digest, header, nil)
+	if err != nil {
+		return Descriptor{}, false, err
+	}
+
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return Descriptor{}, false, nil
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return Descriptor{}, false, statusError(resp)
+	}
+
+	size, _ := strconv.ParseInt(resp.Header.Get("Content-Length"), 10, 64)
+	return Descriptor{MediaType: resp.Header.Get("Content-Type"), Digest: ref.Digest, Size: size}, true, nil
+}
+
+// Inspect returns the descriptor of the manifest with the tag or digest, with the digest of its content, and the
+// manifest itself if it is an image manifest rather than an index. Manifests fetched by digest are checked against
+// it.
+func (c *Client) Inspect(ctx context.Context, ref Reference, tagOrDigest string) (Descriptor, *Manifest, error) {
+	header := http.Header{"Accept": {strings.Join(manifestMediaTypes, ", ")}}
+	resp, err := c.do(ctx, ref, http.MethodGet, "/manifests/"+tagOrDigest, header, nil)
+	if err != nil {
+		return Descriptor{}, nil, err
+	}
+
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return Descriptor{}, nil, fmt.Errorf("failed to get manifest %v: %w", tagOrDigest, statusError(resp))
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxEnvelopeSize))
+	if err != nil {
+		return Descriptor{}, nil, err
+	}
+
+	desc := Descriptor{MediaType: resp.Header.Get("Content-Type"), Digest: digestOf(data), Size: int64(len(data))}
+	if digestPattern.MatchString(tagOrDigest) && desc.Digest != tagOrDigest {
+		return Descriptor{}, nil, fmt.Errorf("manifest %v has digest %v", tagOrDigest, desc.Digest)
+	}
+
+	manifest := Manifest{}
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return Descriptor{}, nil, fmt.Errorf("failed to decode manifest %v: %w", tagOrDigest, err)
+	}
+
+	if manifest.MediaType != "" {
+		desc.MediaType = manifest.MediaType
+	}
+
+	if manifest.Config.Digest == "" {
+		return desc, nil, nil
+	}
+
+	return desc, &manifest, nil
+}
+
+// Push attaches the envelope to the manifest with the reference's digest, adding it to the manifest of the
+// attestations already attached to the image. It returns the reference of the envelope's blob.
+func (c *Client) Push(ctx context.Context, ref Reference, env dsse.Envelope) (string, error) {
+	envelopeBytes, err := json.Marshal(&env)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal envelope: %w", err)
+	}
+
+	return c.PushData(ctx, ref, envelopeBytes)
+}
+
+// PushData is Push for the JSON encoding of an envelope. The envelope is stored as given, so fields dsse.Envelope
+// doesn't have, such as signature timestamps, are kept.
+func (c *Client) PushData(ctx context.Context, ref Reference, envelopeBytes []byte) (string, error) {
+	env := dsse.Envelope{}
+	if err := json.Unmarshal(envelopeBytes, &env); err != nil {
+		return "", fmt.Errorf("failed to unmarshal envelope: %w", err)
+	}
+
+	subject, ok, err := c.Resolve(ctx, ref)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %v: %w", ref, err)
+	}
+
+	if !ok {
+		return "", fmt.Errorf("%v does not exist", ref)
+	}
+
+	layer, err := c.pushBlob(ctx, ref, EnvelopeMediaType, envelopeBytes)
+	if err != nil {
+		return "", fmt.Errorf("failed to upload envelope: %w", err)
+	}
+
+	layer.Annotations = map[string]string{cosignSignatureAnnotation: ""}
+	statement := struct {
+		PredicateType string `json:"predicateType"`
+	}{}
+
+	if err := json.Unmarshal(env.Payload, &statement); err == nil && statement.PredicateType != "" {
+		layer.Annotations[predicateTypeAnnotation] = statement.PredicateType
+	}
+
+	config, err := c.pushBlob(ctx, ref, configMediaType, []byte("{}"))
+	if err != nil {
+		return "", fmt.Errorf("failed to upload manifest config: %w", err)
+	}
+
+	tag := AttestationTag(ref.Digest)
+	manifest, err := c.manifest(ctx, ref, tag)
+	if err != nil {
+		return "", fmt.Errorf("failed to read attestation manifest: %w", err)
+	}
+
+	if manifest == nil {
+		manifest = &Manifest{SchemaVersion: 2, MediaType: ManifestMediaType, ArtifactType: EnvelopeMediaType}
+	}
+
+	manifest.Config = config
+	manifest.Subject = &subject
+	for _, existing := range manifest.Layers {
+		if existing.Digest == layer.Digest {
+			return ref.WithDigest(layer.Digest).String(), nil
+		}
+	}
+
+	manifest.Layers = append(manifest.Layers, layer)
+	manifestBytes, err := json.Marshal(manifest)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := c.do(ctx, ref, http.MethodPut, "/manifests/"+tag, http.Header{"Content-Type": {ManifestMediaType}}, manifestBytes)
+	if err != nil {
+		return "", fmt.Errorf("failed to push attestation manifest: %w", err)
+	}
+
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to push attestation manifest: %w", statusError(resp))
+	}
+
+	return ref.WithDigest(layer.Digest).String(), nil
+}
+
+// Fetch returns the envelopes attached to the manifest with the reference's digest. Envelopes are found in the
+// manifests the referrers API lists for the digest, where the registry supports it, and in the manifest tagged with
+// the digest's cosign attestation tag. Blobs that aren't DSSE envelopes are skipped.
+func (c *Client) Fetch(ctx context.Context, ref Reference) ([]Found, error) {
+	manifests := make([]*Manifest, 0)
+	referrers, err := c.referrers(ctx, ref)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, referrer := range referrers {
+		manifest, err := c.manifest(ctx, ref, referrer.Digest)
+		if err != nil {
+			return nil, err
+		}
+
+		if manifest != nil {
+			manifests = append(manifests, manifest)
+		}
+	}
+
+	tagged, err := c.manifest(ctx, ref, AttestationTag(ref.Digest))
+	if err != nil {
+		return nil, err
+	}
+
+	if tagged != nil {
+		manifests = append(manifests, tagged)
+	}
+
+	seen := make(map[string]bool)
+	found := make([]Found, 0)
+	for _, manifest := range manifests {
+		for _, layer := range manifest.Layers {
+			if layer.MediaType != EnvelopeMediaType || seen[layer.Digest] {
+				continue
+			}
+
+			seen[layer.Digest] = true
+			env, err := c.envelope(ctx, ref, layer.Digest)
+			if err != nil {
+				log.Debugf("(oci) skipping envelope %v: %v", layer.Digest, err)
+				continue
+			}
+
+			found = append(found, Found{Envelope: env, Reference: ref.WithDigest(layer.Digest).String()})
+		}
+	}
+
+	return found, nil
+}
+
+// TaggedEnvelope returns the content of the first DSSE envelope layer of the image manifest with the tag, such as a
+// signed policy pushed with oras. The content is checked against the layer's digest.
+func (c *Client) TaggedEnvelope(ctx context.Context, ref Reference, tag string) ([]byte, error) {
+	_, manifest, err := c.Inspect(ctx, ref, tag)
+	if err != nil {
+		return nil, err
+	}
+
+	if manifest == nil {
+		return nil, fmt.Errorf("%v:%v is not an image manifest", ref, tag)
+	}
+
+	for _, layer := range manifest.Layers {
+		if layer.MediaType == EnvelopeMediaType {
+			return c.blob(ctx, ref, layer.Digest)
+		}
+	}
+
+	return nil, fmt.Errorf("%v:%v has no envelope layer", ref, tag)
+}
+
+// referrers returns the manifests that have the reference's digest as their subject and an envelope artifact type.
+// Registries without the referrers API have none.
+func (c *Client) referrers(ctx context.Context, ref Reference) ([]Descriptor, error) {
+	resp, err := c.do(ctx, ref, http.MethodGet, "/referrers/"+ref.Digest, http.Header{"Accept": {IndexMediaType}}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to list referrers of %v: %w", ref, statusError(resp))
+	}
+
+	referrers := index{}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxEnvelopeSize)).Decode(&referrers); err != nil {
+		return nil, fmt.Errorf("failed to decode referrers of %v: %w", ref, err)
+	}
+
+	descriptors := make([]Descriptor, 0, len(referrers.Manifests))
+	for _, descriptor := range referrers.Manifests {
+		if descriptor.ArtifactType == EnvelopeMediaType {
+			descriptors = append(descriptors, descriptor)
+		}
+	}
+
+	return descriptors, nil
+}
+
+// manifest returns the image manifest with the tag or digest, or nil if there is none.
+func (c *Client) manifest(ctx context.Context, ref Reference, tagOrDigest string) (*Manifest, error) {
+	resp, err := c.do(ctx, ref, http.MethodGet, "/manifests/"+tagOrDigest, http.Header{"Accept": {ManifestMediaType}}, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to get manifest %v: %w", tagOrDigest, statusError(resp))
+	}
+
+	manifest := Manifest{}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxEnvelopeSize)).Decode(&manifest); err != nil {
+		return nil, fmt.Errorf("failed to decode manifest %v: %w", tagOrDigest, err)
+	}
+
+	return &manifest, nil
+}
+
+// blob downloads the blob with the digest and checks that its content has the digest.
+func (c *Client) blob(ctx context.Context, ref Reference, digest string) ([]byte, error) {
+	resp, err := c.do(ctx, ref, http.MethodGet, "/blobs/"+digest, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, statusError(resp)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxEnvelopeSize))
+	if err != nil {
+		return nil, err
+	}
+
+	if digestOf(data) != digest {
+		return nil, fmt.Errorf("blob content does not match its digest")
+	}
+
+	return data, nil
+}
+
+// envelope downloads the blob with the digest and decodes it as an envelope.
+func (c *Client) envelope(ctx context.Context, ref Reference, digest string) (dsse.Envelope, error) {
+	data, err := c.blob(ctx, ref, digest)
+	if err != nil {
+		return dsse.Envelope{}, err
+	}
+
+	env := dsse.Envelope{}
+	if err := json.Unmarshal(data, &env); err != nil {
+		return dsse.Envelope{}, fmt.Errorf("failed to decode envelope: %w", err)
+	}
+
+	return env, nil
+}
+
+// pushBlob uploads the data as a blob unless the repository already has it, and returns its descriptor.
+func (c *Client) pushBlob(ctx context.Context, ref Reference, mediaType string, data []byte) (Descriptor, error) {
+	descriptor := Descriptor{MediaType: mediaType, Digest: digestOf(data), Size: int64(len(data))}
+	resp, err := c.do(ctx, ref, http.MethodHead, "/blobs/"+descriptor.Digest, nil, nil)
+	if err != nil {
+		return Descriptor{}, err
+	}
+
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		return descriptor, nil
+	}
+
+	resp, err = c.do(ctx, ref, http.MethodPost, "/blobs/uploads/", nil, nil)
+	if err != nil {
+		return Descriptor{}, err
+	}
+
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusAccepted {
+		return Descriptor{}, fmt.Errorf("failed to start upload: %v", resp.Status)
+	}
+
+	location, err := uploadLocation(ref, resp.Header.Get("Location"), descriptor.Digest)
+	if err != nil {
+		return Descriptor{}, err
+	}
+
+	resp, err = c.do(ctx, ref, http.MethodPut, location, http.Header{"Content-Type": {"application/octet-stream"}}, data)
+	if err != nil {
+		return Descriptor{}, err
+	}
+
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		return Descriptor{}, fmt.Errorf("failed to finish upload: %w", statusError(resp))
+	}
+
+	return descriptor, nil
+}
+
+// uploadLocation resolves the upload location the registry returned, which may be relative, and adds the digest of
+// the blob being uploaded to it.
+func uploadLocation(ref Reference, location, digest string) (string, error) {
+	base, err := url.Parse(ref.baseURL() + "/")
+	if err != nil {
+		return "", err
+	}
+
+	upload, err := base.Parse(location)
+	if err != nil || location == "" {
+		return "", fmt.Errorf("invalid upload location %q", location)
+	}
+
+	query := upload.Query()
+	query.Set("digest", digest)
+	upload.RawQuery = query.Encode()
+	return upload.String(), nil
+}
+
+func digestOf(data []byte) string {
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
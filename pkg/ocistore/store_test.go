@@ -0,0 +1,342 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ocistore
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/testifysec/go-witness/dsse"
+)
+
+// testRegistry is an in memory registry that requires a bearer token from its token service.
+type testRegistry struct {
+	mu        sync.Mutex
+	blobs     map[string][]byte
+	manifests map[string][]byte
+	server    *httptest.Server
+	username  string
+}
+
+func newTestRegistry(t *testing.T) *testRegistry {
+	r := &testRegistry{blobs: map[string][]byte{}, manifests: map[string][]byte{}}
+	r.server = httptest.NewServer(http.HandlerFunc(r.serve))
+	t.Cleanup(r.server.Close)
+	return r
+}
+
+func (r *testRegistry) serve(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if req.URL.Path == "/token" {
+		if username, _, ok := req.BasicAuth(); ok {
+			r.username = username
+		}
+
+		fmt.Fprint(w, `{"token": "secret"}`)
+		return
+	}
+
+	if req.Header.Get("Authorization") != "Bearer secret" {
+		w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%v/token",service="test",scope="repository:org/app:pull,push"`, r.server.URL))
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	path := strings.TrimPrefix(req.URL.Path, "/v2/org/app")
+	body, _ := io.ReadAll(req.Body)
+	switch {
+	case req.Method == http.MethodPost && path == "/blobs/uploads/":
+		w.Header().Set("Location", "/v2/org/app/blobs/uploads/1?state=abc")
+		w.WriteHeader(http.StatusAccepted)
+	case req.Method == http.MethodPut && strings.HasPrefix(path, "/blobs/uploads/"):
+		if req.URL.Query().Get("state") != "abc" || digestOf(body) != req.URL.Query().Get("digest") {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		r.blobs[digestOf(body)] = body
+		w.WriteHeader(http.StatusCreated)
+	case strings.HasPrefix(path, "/blobs/"):
+		r.write(w, req, r.blobs[strings.TrimPrefix(path, "/blobs/")], "application/octet-stream")
+	case req.Method == http.MethodPut && strings.HasPrefix(path, "/manifests/"):
+		r.manifests[strings.TrimPrefix(path, "/manifests/")] = body
+		r.manifests[digestOf(body)] = body
+		w.WriteHeader(http.StatusCreated)
+	case strings.HasPrefix(path, "/manifests/"):
+		r.write(w, req, r.manifests[strings.TrimPrefix(path, "/manifests/")], ManifestMediaType)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (r *testRegistry) write(w http.ResponseWriter, req *http.Request, data []byte, mediaType string) {
+	if data == nil {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", mediaType)
+	w.Header().Set("Content-Length", fmt.Sprint(len(data)))
+	if req.Method != http.MethodHead {
+		w.Write(data)
+	}
+}
+
+func TestPushAndFetch(t *testing.T) {
+	registry := newTestRegistry(t)
+	image := []byte(`{"schemaVersion": 2, "mediaType": "application/vnd.oci.image.manifest.v1+json"}`)
+	registry.manifests[digestOf(image)] = image
+
+	ref, err := ParseReference("oci://" + strings.TrimPrefix(registry.server.URL, "http://") + "/org/app@" + digestOf(image))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := &Client{Credentials: func(context.Context, string) (string, string, bool) { return "builder", "password", true }}
+	envelopes := []dsse.Envelope{
+		{PayloadType: "text", Payload: []byte("one")},
+		{PayloadType: "text", Payload: []byte("two")},
+	}
+
+	for _, env := range append(envelopes, envelopes[0]) {
+		if _, err := client.Push(context.Background(), ref, env); err != nil {
+			t.Fatalf("failed to push envelope: %v", err)
+		}
+	}
+
+	if registry.username != "builder" {
+		t.Errorf("expected the token to be requested with the registry's credentials")
+	}
+
+	manifest := Manifest{}
+	if err := json.Unmarshal(registry.manifests[AttestationTag(ref.Digest)], &manifest); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(manifest.Layers) != 2 || manifest.Subject == nil || manifest.Subject.Digest != ref.Digest {
+		t.Errorf("expected one layer per envelope and the image as subject, got %+v", manifest)
+	}
+
+	found, err := client.Fetch(context.Background(), ref)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(found) != 2 || string(found[0].Envelope.Payload) != "one" || string(found[1].Envelope.Payload) != "two" {
+		t.Fatalf("expected both envelopes, got %+v", found)
+	}
+
+	if !strings.HasPrefix(found[0].Reference, Scheme) || !strings.Contains(found[0].Reference, "@sha256:") {
+		t.Errorf("unexpected reference %v", found[0].Reference)
+	}
+
+	missing := ref.WithDigest(digestOf([]byte("missing")))
+	if _, err := client.Push(context.Background(), missing, envelopes[0]); err == nil {
+		t.Error("expected pushing to a missing image to fail")
+	}
+
+	if found, err := client.Fetch(context.Background(), missing); err != nil || len(found) != 0 {
+		t.Errorf("expected no envelopes for an image without attestations, got %v, %v", found, err)
+	}
+}
+
+func TestPushData(t *testing.T) {
+	registry := newTestRegistry(t)
+	image := []byte(`{"schemaVersion": 2, "mediaType": "application/vnd.oci.image.manifest.v1+json"}`)
+	registry.manifests[digestOf(image)] = image
+
+	ref, err := ParseReference("oci://" + strings.TrimPrefix(registry.server.URL, "http://") + "/org/app@" + digestOf(image))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := &Client{}
+	stamped := []byte(`{"payload":"b25l","payloadType":"text","signatures":[{"keyid":"key","sig":"c2ln","timestamps":[{"type":"tsp","data":"dG9rZW4="}]}]}`)
+	if _, err := client.PushData(context.Background(), ref, stamped); err != nil {
+		t.Fatalf("failed to push envelope: %v", err)
+	}
+
+	if string(registry.blobs[digestOf(stamped)]) != string(stamped) {
+		t.Errorf("expected the envelope to be stored as given")
+	}
+
+	if _, err := client.PushData(context.Background(), ref, []byte("not json")); err == nil {
+		t.Error("expected invalid envelope to be rejected")
+	}
+}
+
+func TestParseReference(t *testing.T) {
+	digest := "sha256:" + strings.Repeat("a", 64)
+	tests := []struct {
+		reference string
+		expected  Reference
+		valid     bool
+	}{
+		{"oci://ghcr.io/org/repo", Reference{Registry: "ghcr.io", Repository: "org/repo"}, true},
+		{"oci://ghcr.io/org/repo@" + digest, Reference{Registry: "ghcr.io", Repository: "org/repo", Digest: digest}, true},
+		{"oci://docker.io/alpine", Reference{Registry: "docker.io", Repository: "library/alpine"}, true},
+		{"oci://localhost:5000/app/", Reference{Registry: "localhost:5000", Repository: "app"}, true},
+		{"ghcr.io/org/repo", Reference{}, false},
+		{"oci://ghcr.io", Reference{}, false},
+		{"oci://ghcr.io/org/repo:latest", Reference{}, false},
+		{"oci://ghcr.io/Org/repo", Reference{}, false},
+		{"oci://ghcr.io/org/repo@sha256:abc", Reference{}, false},
+	}
+
+	for _, test := range tests {
+		ref, err := ParseReference(test.reference)
+		if test.valid && (err != nil || ref != test.expected) {
+			t.Errorf("%v: expected %+v, got %+v, %v", test.reference, test.expected, ref, err)
+		} else if !test.valid && err == nil {
+			t.Errorf("%v: expected the reference to be rejected", test.reference)
+		}
+	}
+
+	if url := (Reference{Registry: "docker.io", Repository: "library/alpine"}).baseURL(); url != "https://registry-1.docker.io" {
+		t.Errorf("unexpected docker hub url %v", url)
+	}
+}
+
+func TestParseChallenge(t *testing.T) {
+	scheme, params := parseChallenge(`Bearer realm="https://ghcr.io/token",service="ghcr.io",scope="repository:org/repo:pull,push"`)
+	if scheme != "Bearer" || params["realm"] != "https://ghcr.io/token" || params["service"] != "ghcr.io" || params["scope"] != "repository:org/repo:pull,push" {
+		t.Errorf("unexpected challenge %v %v", scheme, params)
+	}
+}
+
+func TestDockerConfigCredentials(t *testing.T) {
+	dir := t.TempDir()
+	config := map[string]interface{}{"auths": map[string]interface{}{
+		"https://index.docker.io/v1/": map[string]string{"auth": base64.StdEncoding.EncodeToString([]byte("hub:secret"))},
+		"ghcr.io":                     map[string]string{"username": "gh", "password": "token"},
+	}}
+
+	data, err := json.Marshal(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "config.json"), data, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("DOCKER_CONFIG", dir)
+	if username, password, ok := DockerConfigCredentials(context.Background(), "docker.io"); !ok || username != "hub" || password != "secret" {
+		t.Errorf("unexpected docker hub credentials %v %v %v", username, password, ok)
+	}
+
+	if username, password, ok := DockerConfigCredentials(context.Background(), "ghcr.io"); !ok || username != "gh" || password != "token" {
+		t.Errorf("unexpected ghcr credentials %v %v %v", username, password, ok)
+	}
+
+	if _, _, ok := DockerConfigCredentials(context.Background(), "quay.io"); ok {
+		t.Error("expected no credentials for quay.io")
+	}
+}
+
+func TestInspect(t *testing.T) {
+	registry := newTestRegistry(t)
+	configDigest := digestOf([]byte("config"))
+	image := []byte(fmt.Sprintf(`{"schemaVersion": 2, "mediaType": "%v", "config": {"digest": "%v"}}`, ManifestMediaType, configDigest))
+	index := []byte(fmt.Sprintf(`{"schemaVersion": 2, "mediaType": "%v", "manifests": [{"digest": "%v"}]}`, IndexMediaType, digestOf(image)))
+	registry.manifests["v1"] = image
+	registry.manifests["multi"] = index
+	registry.manifests[digestOf(image)] = image
+	ref, err := ParseReference("oci://" + strings.TrimPrefix(registry.server.URL, "http://") + "/org/app")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := &Client{}
+	desc, manifest, err := client.Inspect(context.Background(), ref, "v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if desc.Digest != digestOf(image) || manifest == nil || manifest.Config.Digest != configDigest {
+		t.Fatalf("unexpected manifest %+v, %+v", desc, manifest)
+	}
+
+	desc, manifest, err = client.Inspect(context.Background(), ref, "multi")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if desc.Digest != digestOf(index) || desc.MediaType != IndexMediaType || manifest != nil {
+		t.Fatalf("expected an index without a config, got %+v, %+v", desc, manifest)
+	}
+
+	registry.manifests[digestOf(index)] = image
+	if _, _, err := client.Inspect(context.Background(), ref, digestOf(index)); err == nil {
+		t.Fatal("expected a manifest that doesn't match its digest to fail")
+	}
+
+	if _, _, err := client.Inspect(context.Background(), ref, "missing"); err == nil {
+		t.Fatal("expected a missing tag to fail")
+	}
+}
+
+func TestTaggedEnvelope(t *testing.T) {
+	registry := newTestRegistry(t)
+	envelope := []byte(`{"payloadType": "https://witness.testifysec.com/policy/v0.1", "payload": "", "signatures": []}`)
+	registry.blobs[digestOf(envelope)] = envelope
+	manifest, err := json.Marshal(Manifest{
+		SchemaVersion: 2,
+		MediaType:     ManifestMediaType,
+		Config:        Descriptor{MediaType: "application/vnd.oci.empty.v1+json", Digest: digestOf([]byte("{}")), Size: 2},
+		Layers:        []Descriptor{{MediaType: EnvelopeMediaType, Digest: digestOf(envelope), Size: int64(len(envelope))}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	registry.manifests["prod"] = manifest
+	ref, tag, err := ParseTaggedReference("oci://" + strings.TrimPrefix(registry.server.URL, "http://") + "/org/app:prod")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := &Client{}
+	data, err := client.TaggedEnvelope(context.Background(), ref, tag)
+	if err != nil || string(data) != string(envelope) {
+		t.Fatalf("expected the envelope layer, got %s, %v", data, err)
+	}
+
+	registry.blobs[digestOf(envelope)] = []byte("tampered")
+	if _, err := client.TaggedEnvelope(context.Background(), ref, tag); err == nil {
+		t.Error("expected a blob that does not match its digest to be rejected")
+	}
+
+	if _, err := client.TaggedEnvelope(context.Background(), ref, "missing"); err == nil {
+		t.Error("expected a missing tag to fail")
+	}
+
+	for _, invalid := range []string{"oci://ghcr.io/org/repo", "oci://localhost:5000/app", "oci://ghcr.io/org/repo@sha256:abc", "oci://ghcr.io/org/repo:-bad"} {
+		if _, _, err := ParseTaggedReference(invalid); err == nil {
+			t.Errorf("%v: expected the reference to be rejected", invalid)
+		}
+	}
+}
@@ -0,0 +1,208 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oidctoken
+
+import (
+	"context"
+	"crypto"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/testifysec/go-witness/cryptoutil"
+	"gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
+)
+
+const (
+	// TokenEnv holds an ID token for witness to verify and record, such as one requested with GitLab's id_tokens or
+	// bound from a Jenkins OIDC credential.
+	TokenEnv = "WITNESS_ID_TOKEN"
+	// AudienceEnv is the audience the ID token must be issued for. The audience isn't checked if it is not set.
+	AudienceEnv = "WITNESS_ID_TOKEN_AUDIENCE"
+
+	// leeway allows for clock skew between the issuer and the builder when checking the token's validity period.
+	leeway = time.Minute
+)
+
+// Token is an ID token that was verified against the keys its issuer publishes. Its claims are the attributes the
+// issuer vouches for, unlike the environment variables a job can set for itself.
+type Token struct {
+	Issuer  string                 `json:"issuer"`
+	JWKSURL string                 `json:"jwksurl"`
+	KeyID   string                 `json:"keyid,omitempty"`
+	Claims  map[string]interface{} `json:"claims"`
+}
+
+type Options struct {
+	// Issuers are the issuers the token may be issued by. A token from any other issuer is rejected before its keys are
+	// fetched.
+	Issuers []string
+	// Audience is the audience the token must be issued for, if set.
+	Audience   string
+	HTTPClient *http.Client
+}
+
+// Verify checks the token's signature with the keys listed in its issuer's OpenID discovery document, and that it is
+// within its validity period and was issued by one of the issuers and for the audience in the options.
+func Verify(ctx context.Context, token string, opts Options) (*Token, error) {
+	parsed, err := jwt.ParseSigned(token)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse id token: %w", err)
+	}
+
+	unverified := jwt.Claims{}
+	if err := parsed.UnsafeClaimsWithoutVerification(&unverified); err != nil {
+		return nil, fmt.Errorf("failed to decode id token claims: %w", err)
+	}
+
+	issuer, ok := trustedIssuer(unverified.Issuer, opts.Issuers)
+	if !ok {
+		return nil, fmt.Errorf("id token was issued by %q, expected one of %v", unverified.Issuer, strings.Join(opts.Issuers, ", "))
+	}
+
+	c := opts.HTTPClient
+	if c == nil {
+		c = http.DefaultClient
+	}
+
+	jwksURL, err := discoverJWKS(ctx, c, issuer)
+	if err != nil {
+		return nil, err
+	}
+
+	jwks := jose.JSONWebKeySet{}
+	if err := getJSON(ctx, c, jwksURL, &jwks); err != nil {
+		return nil, fmt.Errorf("failed to get the keys of %v: %w", issuer, err)
+	}
+
+	standard := jwt.Claims{}
+	claims := make(map[string]interface{})
+	if err := parsed.Claims(jwks, &standard, &claims); err != nil {
+		return nil, fmt.Errorf("failed to verify id token: %w", err)
+	}
+
+	expected := jwt.Expected{Issuer: unverified.Issuer, Time: time.Now()}
+	if opts.Audience != "" {
+		expected.Audience = jwt.Audience{opts.Audience}
+	}
+
+	if err := standard.ValidateWithLeeway(expected, leeway); err != nil {
+		return nil, fmt.Errorf("id token is not valid: %w", err)
+	}
+
+	verified := &Token{Issuer: unverified.Issuer, JWKSURL: jwksURL, Claims: claims}
+	for _, header := range parsed.Headers {
+		if header.KeyID != "" {
+			verified.KeyID = header.KeyID
+			break
+		}
+	}
+
+	return verified, nil
+}
+
+// trustedIssuer returns the issuer the token claims to be from, without a trailing slash, if it is one of the trusted
+// issuers.
+func trustedIssuer(issuer string, trusted []string) (string, bool) {
+	issuer = strings.TrimSuffix(issuer, "/")
+	for _, t := range trusted {
+		if issuer != "" && issuer == strings.TrimSuffix(t, "/") {
+			return issuer, true
+		}
+	}
+
+	return "", false
+}
+
+func discoverJWKS(ctx context.Context, c *http.Client, issuer string) (string, error) {
+	discovery := struct {
+		Issuer  string `json:"issuer"`
+		JWKSURI string `json:"jwks_uri"`
+	}{}
+
+	if err := getJSON(ctx, c, issuer+"/.well-known/openid-configuration", &discovery); err != nil {
+		return "", fmt.Errorf("failed to get the discovery document of %v: %w", issuer, err)
+	}
+
+	if strings.TrimSuffix(discovery.Issuer, "/") != issuer {
+		return "", fmt.Errorf("discovery document of %v is for issuer %q", issuer, discovery.Issuer)
+	}
+
+	if discovery.JWKSURI == "" {
+		return "", fmt.Errorf("discovery document of %v does not list its keys", issuer)
+	}
+
+	return discovery.JWKSURI, nil
+}
+
+func getJSON(ctx context.Context, c *http.Client, url string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%v returned %v", url, resp.Status)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// String returns a claim's value as a string. Claims that aren't strings, such as GitLab's ref_protected, are formatted
+// the way they appear in JSON.
+func (t *Token) String(claim string) (string, bool) {
+	value, ok := t.Claims[claim]
+	if !ok || value == nil {
+		return "", false
+	}
+
+	if s, ok := value.(string); ok {
+		return s, true
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return "", false
+	}
+
+	return string(data), true
+}
+
+// Subjects returns a <prefix>:<claim>=<value> subject for each of the claims the token has, with the sha256 digest of
+// <claim>=<value>, so collections can be looked up by the attributes the issuer vouched for.
+func (t *Token) Subjects(prefix string, claims []string) map[string]cryptoutil.DigestSet {
+	subjects := make(map[string]cryptoutil.DigestSet)
+	for _, claim := range claims {
+		value, ok := t.String(claim)
+		if !ok {
+			continue
+		}
+
+		attribute := fmt.Sprintf("%v=%v", claim, value)
+		subjects[prefix+":"+attribute] = cryptoutil.DigestSet{crypto.SHA256: fmt.Sprintf("%x", sha256.Sum256([]byte(attribute)))}
+	}
+
+	return subjects
+}
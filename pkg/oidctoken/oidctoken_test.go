@@ -0,0 +1,146 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oidctoken
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
+)
+
+type testIssuer struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+}
+
+// newTestIssuer serves a discovery document and the public key of the key it signs tokens with.
+func newTestIssuer(t *testing.T) *testIssuer {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	issuer := &testIssuer{key: key}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]string{"issuer": issuer.server.URL, "jwks_uri": issuer.server.URL + "/keys"})
+	})
+
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(jose.JSONWebKeySet{Keys: []jose.JSONWebKey{{Key: &key.PublicKey, KeyID: "kid1", Algorithm: "RS256", Use: "sig"}}})
+	})
+
+	issuer.server = httptest.NewServer(mux)
+	t.Cleanup(issuer.server.Close)
+	return issuer
+}
+
+func (i *testIssuer) sign(t *testing.T, key *rsa.PrivateKey, claims map[string]interface{}) string {
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.RS256, Key: key}, (&jose.SignerOptions{}).WithType("JWT").WithHeader("kid", "kid1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := jwt.Signed(signer).Claims(claims).CompactSerialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return token
+}
+
+func (i *testIssuer) claims(extra map[string]interface{}) map[string]interface{} {
+	claims := map[string]interface{}{
+		"iss": i.server.URL,
+		"sub": "project_path:group/app:ref_type:branch:ref:main",
+		"aud": "witness",
+		"iat": time.Now().Unix(),
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}
+
+	for k, v := range extra {
+		claims[k] = v
+	}
+
+	return claims
+}
+
+func TestVerify(t *testing.T) {
+	issuer := newTestIssuer(t)
+	token := issuer.sign(t, issuer.key, issuer.claims(map[string]interface{}{"project_path": "group/app", "ref_protected": true}))
+	verified, err := Verify(context.Background(), token, Options{Issuers: []string{issuer.server.URL + "/"}, Audience: "witness"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if verified.Issuer != issuer.server.URL || verified.JWKSURL != issuer.server.URL+"/keys" || verified.KeyID != "kid1" {
+		t.Errorf("unexpected verified token %+v", verified)
+	}
+
+	if path, ok := verified.String("project_path"); !ok || path != "group/app" {
+		t.Errorf("expected project_path group/app, got %q", path)
+	}
+
+	if protected, _ := verified.String("ref_protected"); protected != "true" {
+		t.Errorf("expected ref_protected true, got %q", protected)
+	}
+
+	subjects := verified.Subjects("gitlabclaim", []string{"project_path", "environment"})
+	subject, ok := subjects["gitlabclaim:project_path=group/app"]
+	if len(subjects) != 1 || !ok || len(subject[crypto.SHA256]) != 64 {
+		t.Errorf("expected a subject for project_path only, got %v", subjects)
+	}
+}
+
+func TestVerifyRejects(t *testing.T) {
+	issuer := newTestIssuer(t)
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	trusted := Options{Issuers: []string{issuer.server.URL}, Audience: "witness"}
+	cases := []struct {
+		name     string
+		token    string
+		opts     Options
+		expected string
+	}{
+		{"untrusted issuer", issuer.sign(t, issuer.key, issuer.claims(nil)), Options{Issuers: []string{"https://gitlab.com"}}, "expected one of https://gitlab.com"},
+		{"wrong key", issuer.sign(t, other, issuer.claims(nil)), trusted, "failed to verify id token"},
+		{"expired", issuer.sign(t, issuer.key, issuer.claims(map[string]interface{}{"exp": time.Now().Add(-time.Hour).Unix()})), trusted, "not valid"},
+		{"wrong audience", issuer.sign(t, issuer.key, issuer.claims(map[string]interface{}{"aud": "sigstore"})), trusted, "not valid"},
+		{"not a jwt", "abc", trusted, "failed to parse id token"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			_, err := Verify(context.Background(), c.token, c.opts)
+			if err == nil || !strings.Contains(err.Error(), c.expected) {
+				t.Errorf("expected an error containing %q, got %v", c.expected, err)
+			}
+		})
+	}
+}
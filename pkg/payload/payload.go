@@ -0,0 +1,90 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package payload decides how witness reads the payload of a DSSE envelope from its payloadType, so envelopes holding
+// SBOMs, policies, or other documents can be signed and verified alongside in-toto statements.
+package payload
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"strings"
+
+	"github.com/testifysec/go-witness/intoto"
+)
+
+// PolicyType is the payload type of signed witness policies.
+const PolicyType = "https://witness.testifysec.com/policy/v0.1"
+
+// Kind is how a payload is read.
+type Kind string
+
+const (
+	// KindStatement payloads are in-toto statements, such as attestation collections.
+	KindStatement Kind = "statement"
+	// KindPolicy payloads are witness policies.
+	KindPolicy Kind = "policy"
+	// KindJSON payloads are JSON documents, such as CycloneDX or SPDX JSON SBOMs, with a JSON media type.
+	KindJSON Kind = "json"
+	// KindOpaque payloads are only checked by their signatures.
+	KindOpaque Kind = "opaque"
+)
+
+// KindOf returns the kind of payloads with the payload type. Media types of application/json, or with a +json suffix
+// such as application/spdx+json, are JSON.
+func KindOf(payloadType string) Kind {
+	switch payloadType {
+	case intoto.PayloadType:
+		return KindStatement
+	case PolicyType:
+		return KindPolicy
+	}
+
+	mediaType, _, err := mime.ParseMediaType(payloadType)
+	if err == nil && (mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")) {
+		return KindJSON
+	}
+
+	return KindOpaque
+}
+
+// Check returns an error if the payload can't be read as its payload type declares.
+func Check(payloadType string, payload []byte) error {
+	switch KindOf(payloadType) {
+	case KindStatement:
+		statement := intoto.Statement{}
+		if err := json.Unmarshal(payload, &statement); err != nil {
+			return fmt.Errorf("payload is not an in-toto statement: %w", err)
+		}
+
+		if statement.Type == "" || statement.PredicateType == "" {
+			return fmt.Errorf("payload is not an in-toto statement: missing _type or predicateType")
+		}
+	case KindPolicy:
+		policy := struct {
+			Steps map[string]json.RawMessage `json:"steps"`
+		}{}
+
+		if err := json.Unmarshal(payload, &policy); err != nil {
+			return fmt.Errorf("payload is not a witness policy: %w", err)
+		}
+	case KindJSON:
+		if !json.Valid(payload) {
+			return fmt.Errorf("payload of type %v is not valid json", payloadType)
+		}
+	}
+
+	return nil
+}
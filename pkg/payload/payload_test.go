@@ -0,0 +1,61 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package payload
+
+import (
+	"testing"
+
+	"github.com/testifysec/go-witness/intoto"
+)
+
+func TestKindOf(t *testing.T) {
+	tests := map[string]Kind{
+		intoto.PayloadType:                      KindStatement,
+		PolicyType:                              KindPolicy,
+		"application/json":                      KindJSON,
+		"application/vnd.cyclonedx+json":        KindJSON,
+		"application/spdx+json; charset=utf-8":  KindJSON,
+		"text/spdx":                             KindOpaque,
+		"https://example.com/custom-payload/v1": KindOpaque,
+	}
+
+	for payloadType, kind := range tests {
+		if got := KindOf(payloadType); got != kind {
+			t.Errorf("expected %v to be %v, got %v", payloadType, kind, got)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		payloadType string
+		payload     string
+		valid       bool
+	}{
+		{intoto.PayloadType, `{"_type": "https://in-toto.io/Statement/v0.1", "predicateType": "https://slsa.dev/provenance/v0.2", "subject": []}`, true},
+		{intoto.PayloadType, `{"bomFormat": "CycloneDX"}`, false},
+		{PolicyType, `{"steps": {"build": {"name": "build"}}}`, true},
+		{PolicyType, `not a policy`, false},
+		{"application/vnd.cyclonedx+json", `{"bomFormat": "CycloneDX"}`, true},
+		{"application/vnd.cyclonedx+json", `{"bomFormat": `, false},
+		{"text/plain", `anything`, true},
+	}
+
+	for _, test := range tests {
+		if err := Check(test.payloadType, []byte(test.payload)); (err == nil) != test.valid {
+			t.Errorf("expected %v payload %v to be valid: %v, got %v", test.payloadType, test.payload, test.valid, err)
+		}
+	}
+}
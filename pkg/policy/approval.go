@@ -0,0 +1,199 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/x509"
+	"fmt"
+	"strings"
+
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/dsse"
+	"github.com/testifysec/witness/pkg/groups"
+)
+
+// Root is a root of trust from the policy, used to verify the certificates that approvers sign with.
+type Root struct {
+	Certificate   []byte   `json:"certificate"`
+	Intermediates [][]byte `json:"intermediates,omitempty"`
+}
+
+// ApprovalConstraint requires a step's verified collections to be signed by Count distinct members of Group, such as
+// the approvers of a release. Approvers are identified by the email addresses of the certificates they signed with,
+// for example those issued by Fulcio for an OIDC identity.
+type ApprovalConstraint struct {
+	Group string `json:"group"`
+	Count int    `json:"count,omitempty"`
+}
+
+// Approver is a key that signed a collection, identified by the sha256 of its public key, and the email addresses of
+// its certificate.
+type Approver struct {
+	KeyID      string
+	Identities []string
+}
+
+// Verify checks that enough distinct approvers are members of the group. Each signing key counts at most once however
+// many member addresses its certificate carries, and keys whose certificates share a member address count once
+// between them, so no one approver can be counted twice.
+func (a ApprovalConstraint) Verify(ctx context.Context, resolver groups.Resolver, approvers []Approver) error {
+	if resolver == nil {
+		return fmt.Errorf("no group resolver is configured to resolve %v", a.Group)
+	}
+
+	required := a.Count
+	if required < 1 {
+		required = 1
+	}
+
+	// approvers that share a key or a member address are merged into one
+	parent := make([]int, len(approvers))
+	var find func(i int) int
+	find = func(i int) int {
+		if parent[i] != i {
+			parent[i] = find(parent[i])
+		}
+
+		return parent[i]
+	}
+
+	byIdentity := make(map[string]int)
+	byKey := make(map[string]int)
+	members := make([]bool, len(approvers))
+	for i, approver := range approvers {
+		parent[i] = i
+		for _, identity := range approver.Identities {
+			member, err := groups.IsMember(ctx, resolver, a.Group, identity)
+			if err != nil {
+				return err
+			}
+
+			if !member {
+				continue
+			}
+
+			members[i] = true
+			identity = strings.ToLower(identity)
+			if j, ok := byIdentity[identity]; ok {
+				parent[find(i)] = find(j)
+			} else {
+				byIdentity[identity] = i
+			}
+		}
+
+		if !members[i] {
+			continue
+		}
+
+		if j, ok := byKey[approver.KeyID]; ok {
+			parent[find(i)] = find(j)
+		} else {
+			byKey[approver.KeyID] = i
+		}
+	}
+
+	distinct := make(map[int]struct{})
+	for i := range approvers {
+		if members[i] {
+			distinct[find(i)] = struct{}{}
+		}
+	}
+
+	if len(distinct) < required {
+		return fmt.Errorf("approved by %v members of %v, %v required", len(distinct), a.Group, required)
+	}
+
+	return nil
+}
+
+// signerApprovers returns the keys of the certificates that signed the envelope and chain to one of the policy's
+// roots, with the email addresses of each certificate.
+func (p Policy) signerApprovers(env dsse.Envelope) []Approver {
+	approvers := make([]Approver, 0)
+	for _, cert := range p.signerCertificates(env) {
+		approvers = append(approvers, Approver{
+			KeyID:      fmt.Sprintf("%x", sha256.Sum256(cert.RawSubjectPublicKeyInfo)),
+			Identities: cert.EmailAddresses,
+		})
+	}
+
+	return approvers
+}
+
+// signerSPIFFEIDs returns the SPIFFE IDs of the certificates that signed the envelope and chain to one of the policy's
+// roots.
+func (p Policy) signerSPIFFEIDs(env dsse.Envelope) []string {
+	ids := make([]string, 0)
+	for _, cert := range p.signerCertificates(env) {
+		for _, uri := range cert.URIs {
+			if uri.Scheme == "spiffe" {
+				ids = append(ids, uri.String())
+			}
+		}
+	}
+
+	return ids
+}
+
+// signerCertificates returns the certificates that signed the envelope and chain to one of the policy's roots.
+func (p Policy) signerCertificates(env dsse.Envelope) []*x509.Certificate {
+	verifiers := p.signerVerifiers(env)
+	certs := make([]*x509.Certificate, 0, len(verifiers))
+	for _, verifier := range verifiers {
+		certs = append(certs, verifier.Certificate())
+	}
+
+	return certs
+}
+
+// signerVerifiers returns the verifiers of the certificates that signed the envelope and chain to one of the policy's
+// roots.
+func (p Policy) signerVerifiers(env dsse.Envelope) []*cryptoutil.X509Verifier {
+	roots := make([]*x509.Certificate, 0, len(p.Roots))
+	intermediates := make([]*x509.Certificate, 0)
+	for _, root := range p.Roots {
+		cert, err := dsse.TryParseCertificate(root.Certificate)
+		if err != nil {
+			continue
+		}
+
+		roots = append(roots, cert)
+		for _, intermediate := range root.Intermediates {
+			if cert, err := dsse.TryParseCertificate(intermediate); err == nil {
+				intermediates = append(intermediates, cert)
+			}
+		}
+	}
+
+	if len(roots) == 0 {
+		return nil
+	}
+
+	verifiers, err := env.Verify(dsse.WithRoots(roots), dsse.WithIntermediates(intermediates))
+	if err != nil {
+		return nil
+	}
+
+	x509Verifiers := make([]*cryptoutil.X509Verifier, 0, len(verifiers))
+	for _, verifier := range verifiers {
+		if x509Verifier, ok := verifier.(*cryptoutil.X509Verifier); ok {
+			x509Verifiers = append(x509Verifiers, x509Verifier)
+		}
+	}
+
+	return x509Verifiers
+}
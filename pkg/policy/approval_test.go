@@ -0,0 +1,164 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/dsse"
+)
+
+type testGroups map[string][]string
+
+func (g testGroups) Members(ctx context.Context, group string) ([]string, error) {
+	return g[group], nil
+}
+
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+func newTestCA(t testing.TB) testCA {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test root"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return testCA{cert: cert, key: key}
+}
+
+func (ca testCA) pem() []byte {
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.cert.Raw})
+}
+
+// sign signs the step's envelope with a certificate for the email issued by the ca.
+func (ca testCA) sign(t testing.TB, env dsse.Envelope, email string) dsse.Envelope {
+	return ca.signWith(t, env, &x509.Certificate{EmailAddresses: []string{email}})
+}
+
+// signWith signs the step's envelope with a certificate issued by the ca for the template's identities.
+func (ca testCA) signWith(t testing.TB, env dsse.Envelope, template *x509.Certificate) dsse.Envelope {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template.SerialNumber = big.NewInt(time.Now().UnixNano())
+	template.NotBefore = time.Now().Add(-time.Hour)
+	template.NotAfter = time.Now().Add(time.Hour)
+	template.KeyUsage = x509.KeyUsageDigitalSignature
+	template.ExtKeyUsage = []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signer, err := cryptoutil.NewX509Signer(cryptoutil.NewECDSASigner(key, crypto.SHA256), cert, nil, []*x509.Certificate{ca.cert})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signed, err := dsse.Sign(env.PayloadType, bytes.NewReader(env.Payload), signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return signed
+}
+
+func TestApprovalConstraint(t *testing.T) {
+	ca := newTestCA(t)
+	untrusted := newTestCA(t)
+	p := Policy{
+		Roots:  map[string]Root{"root": {Certificate: ca.pem()}},
+		Steps:  map[string]Step{"approve": {Name: "approve", Approvals: []ApprovalConstraint{{Group: "release-managers", Count: 2}}}},
+		Groups: testGroups{"release-managers": {"alice@example.com", "bob@example.com"}},
+	}
+
+	env := testEnvelope(t, "approve", nil)
+	alice := ca.sign(t, env, "alice@example.com")
+	bob := ca.sign(t, env, "Bob@example.com")
+	if _, err := p.Verify([]dsse.Envelope{alice, bob}); err != nil {
+		t.Errorf("expected two members of the group to approve: %v", err)
+	}
+
+	if _, err := p.Verify([]dsse.Envelope{alice, alice}); err == nil {
+		t.Error("expected the same approver to be counted once")
+	}
+
+	if _, err := p.Verify([]dsse.Envelope{alice, ca.sign(t, env, "alice@example.com")}); err == nil {
+		t.Error("expected two keys certified for the same approver to be counted once")
+	}
+
+	both := ca.signWith(t, env, &x509.Certificate{EmailAddresses: []string{"alice@example.com", "bob@example.com"}})
+	if _, err := p.Verify([]dsse.Envelope{both}); err == nil {
+		t.Error("expected one certificate carrying two members' addresses to be counted as one approver")
+	}
+
+	if _, err := p.Verify([]dsse.Envelope{both, bob}); err == nil {
+		t.Error("expected a certificate sharing a member's address with another signer to be counted with it")
+	}
+
+	if _, err := p.Verify([]dsse.Envelope{alice, ca.sign(t, env, "mallory@example.com")}); err == nil {
+		t.Error("expected a non-member's approval not to count")
+	}
+
+	if _, err := p.Verify([]dsse.Envelope{alice, untrusted.sign(t, env, "bob@example.com")}); err == nil {
+		t.Error("expected a certificate from an untrusted root not to count")
+	}
+
+	p.Groups = nil
+	if _, err := p.Verify([]dsse.Envelope{alice, bob}); err == nil {
+		t.Error("expected approvals to fail without a group resolver")
+	}
+}
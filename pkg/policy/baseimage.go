@@ -0,0 +1,115 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/testifysec/go-witness/dsse"
+)
+
+const BaseImageType = "https://witness.dev/attestations/base-image/v0.1"
+
+// BaseImageConstraint requires the base image of the container image a step builds to satisfy another policy, such as
+// one published by the maintainers of the base image. The base image's digest is read from the base-image attestation
+// of the step's collections, and attestations with the digest as a subject are verified against the base image policy.
+// The base image policy must be signed by one of this policy's public keys named in Keys, or with a certificate
+// chaining to one of its roots named in Roots. Base image policies may have base image constraints of their own.
+type BaseImageConstraint struct {
+	Policy string   `json:"policy"`
+	Keys   []string `json:"keys,omitempty"`
+	Roots  []string `json:"roots,omitempty"`
+}
+
+type baseImageAttestation struct {
+	Base *struct {
+		Reference string            `json:"reference"`
+		Digest    map[string]string `json:"digest"`
+	} `json:"base"`
+}
+
+// BaseImageSteps returns the steps of the policy with a base image constraint, sorted by name.
+func (p Policy) BaseImageSteps() []string {
+	steps := make([]string, 0)
+	for name, step := range p.Steps {
+		if step.BaseImage != nil {
+			steps = append(steps, name)
+		}
+	}
+
+	sort.Strings(steps)
+	return steps
+}
+
+// VerifyBaseImagePolicy checks that the base image policy for the step is signed by one of the constraint's keys or
+// roots.
+func (p Policy) VerifyBaseImagePolicy(step string, env dsse.Envelope) error {
+	s, ok := p.Steps[step]
+	if !ok || s.BaseImage == nil {
+		return fmt.Errorf("step %v does not have a base image constraint", step)
+	}
+
+	if err := p.verifyReferencedPolicy(s.BaseImage.Keys, s.BaseImage.Roots, env); err != nil {
+		return fmt.Errorf("base image policy for step %v: %w", step, err)
+	}
+
+	return nil
+}
+
+// BaseImageDigests returns the sha256 digests of the base images recorded by the step's collections in the envelopes,
+// sorted and without duplicates. Every collection for the step must record the digest of its base image.
+func BaseImageDigests(step string, envelopes []dsse.Envelope) ([]string, error) {
+	seen := make(map[string]bool)
+	digests := make([]string, 0)
+	for _, indexed := range NewIndex(envelopes).ByPredicateType(CollectionType) {
+		collection, err := collectionFromPredicate(indexed.Statement.PredicateType, indexed.Statement.Predicate)
+		if err != nil || collection.Name != step {
+			continue
+		}
+
+		raw, ok := collection.Attestation(BaseImageType)
+		if !ok {
+			return nil, fmt.Errorf("collection for step %v does not have a base-image attestation", step)
+		}
+
+		attestation := baseImageAttestation{}
+		if err := json.Unmarshal(raw, &attestation); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal base-image attestation: %w", err)
+		}
+
+		if attestation.Base == nil {
+			return nil, fmt.Errorf("collection for step %v does not record a base image", step)
+		}
+
+		digest := attestation.Base.Digest["sha256"]
+		if digest == "" {
+			return nil, fmt.Errorf("collection for step %v does not record the digest of base image %v", step, attestation.Base.Reference)
+		}
+
+		if !seen[digest] {
+			seen[digest] = true
+			digests = append(digests, digest)
+		}
+	}
+
+	if len(digests) == 0 {
+		return nil, fmt.Errorf("no collections for step %v record a base image", step)
+	}
+
+	sort.Strings(digests)
+	return digests, nil
+}
@@ -0,0 +1,126 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/dsse"
+)
+
+func baseImageEnvelope(t *testing.T, step string, base map[string]interface{}) dsse.Envelope {
+	attestation := map[string]interface{}{}
+	if base != nil {
+		attestation["base"] = base
+	}
+
+	return testEnvelope(t, step, map[string]interface{}{BaseImageType: attestation})
+}
+
+func TestBaseImageDigests(t *testing.T) {
+	alpine := map[string]interface{}{"reference": "alpine:3.18", "digest": map[string]string{"sha256": "aaaa"}}
+	debian := map[string]interface{}{"reference": "debian:12", "digest": map[string]string{"sha256": "bbbb"}}
+	digests, err := BaseImageDigests("build", []dsse.Envelope{
+		baseImageEnvelope(t, "build", debian),
+		baseImageEnvelope(t, "build", alpine),
+		baseImageEnvelope(t, "build", alpine),
+		baseImageEnvelope(t, "test", nil),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(digests, []string{"aaaa", "bbbb"}) {
+		t.Fatalf("unexpected digests: %v", digests)
+	}
+
+	tests := map[string][]dsse.Envelope{
+		"does not have a base-image attestation": {commandEnvelope(t, "build", "docker", "build", ".")},
+		"does not record a base image":           {baseImageEnvelope(t, "build", nil)},
+		"does not record the digest":             {baseImageEnvelope(t, "build", map[string]interface{}{"reference": "alpine"})},
+		"no collections":                         {baseImageEnvelope(t, "test", alpine)},
+	}
+
+	for expected, envelopes := range tests {
+		if _, err := BaseImageDigests("build", envelopes); err == nil || !strings.Contains(err.Error(), expected) {
+			t.Errorf("expected error containing %q, got %v", expected, err)
+		}
+	}
+}
+
+func TestVerifyBaseImagePolicy(t *testing.T) {
+	maintainer, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	der, err := x509.MarshalPKIXPublicKey(&maintainer.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := Policy{
+		PublicKeys: map[string]PublicKey{"maintainer": {KeyID: "maintainer", Key: pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})}},
+		Steps: map[string]Step{
+			"build":   {Name: "build", BaseImage: &BaseImageConstraint{Policy: "base.json", Keys: []string{"maintainer"}}},
+			"package": {Name: "package", BaseImage: &BaseImageConstraint{Policy: "base.json"}},
+			"test":    {Name: "test"},
+		},
+	}
+
+	if got := p.BaseImageSteps(); !reflect.DeepEqual(got, []string{"build", "package"}) {
+		t.Fatalf("unexpected base image steps: %v", got)
+	}
+
+	sign := func(key *ecdsa.PrivateKey) dsse.Envelope {
+		env, err := dsse.Sign("https://witness.testifysec.com/policy/v0.1", bytes.NewReader([]byte(`{}`)), cryptoutil.NewECDSASigner(key, crypto.SHA256))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return env
+	}
+
+	if err := p.VerifyBaseImagePolicy("build", sign(maintainer)); err != nil {
+		t.Fatalf("expected base image policy signed by the maintainer's key to verify: %v", err)
+	}
+
+	other, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := p.VerifyBaseImagePolicy("build", sign(other)); err == nil {
+		t.Fatal("expected base image policy signed by another key to fail")
+	}
+
+	if err := p.VerifyBaseImagePolicy("package", sign(maintainer)); err == nil {
+		t.Fatal("expected constraint without keys or roots to fail")
+	}
+
+	if err := p.VerifyBaseImagePolicy("test", sign(maintainer)); err == nil {
+		t.Fatal("expected step without a base image constraint to fail")
+	}
+}
@@ -0,0 +1,147 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/testifysec/go-witness/dsse"
+)
+
+// Check is the outcome of one of a step's witness specific constraints for a single collection.
+type Check struct {
+	Constraint string `json:"constraint"`
+	Passed     bool   `json:"passed"`
+	Reason     string `json:"reason,omitempty"`
+}
+
+// Explain checks the collection signed in the envelope against each of its step's witness specific constraints on
+// their own, so every constraint it fails is reported rather than only the first, and returns the step's name.
+// Approval constraints and thresholds are checked against the envelope's signers alone, and matrix constraints pass if
+// the collection is one of the step's variants, since a step with any of them can still need other collections.
+// Delegated steps are verified against their delegated policy, which Explain doesn't check.
+func (p Policy) Explain(env dsse.Envelope) (string, []Check, error) {
+	collection, err := CollectionFromEnvelope(env)
+	if err != nil {
+		return "", nil, err
+	}
+
+	step, ok := p.Steps[collection.Name]
+	if !ok {
+		return collection.Name, nil, fmt.Errorf("step %v is not in the policy", collection.Name)
+	}
+
+	checks := make([]Check, 0)
+	check := func(constraint string, err error) {
+		c := Check{Constraint: constraint, Passed: err == nil}
+		if err != nil {
+			c.Reason = err.Error()
+		}
+
+		checks = append(checks, c)
+	}
+
+	if step.hasIdentityConstraints() {
+		check("functionaries", p.verifyFunctionaries(step, env))
+	}
+
+	for _, forbidden := range step.Forbidden {
+		check(fmt.Sprintf("forbidden %v", forbidden.Type), forbidden.Check(collection))
+	}
+
+	if step.Command != nil {
+		check("command", step.Command.Verify(collection))
+	}
+
+	if step.BuildCounter != nil {
+		check("buildCounter", step.BuildCounter.Verify(collection))
+	}
+
+	for i, approval := range step.Approvals {
+		check(fmt.Sprintf("approvals[%d]", i), approval.Verify(context.Background(), p.Groups, p.signerApprovers(env)))
+	}
+
+	if step.Threshold > 0 {
+		check("threshold", p.verifyThreshold(step, []dsse.Envelope{env}, []error{nil}))
+	}
+
+	if step.KeyAttestation != nil {
+		check("keyAttestation", step.KeyAttestation.Verify(p.Roots, collection, env))
+	}
+
+	if step.SBOM != nil {
+		check("sbom", step.SBOM.Verify(collection))
+	}
+
+	if step.SBOMCompleteness != nil {
+		check("sbomCompleteness", step.SBOMCompleteness.Verify(collection))
+	}
+
+	if step.SPIFFE != nil {
+		check("spiffe", step.SPIFFE.Verify(p.signerSPIFFEIDs(env)))
+	}
+
+	if step.Matrix != nil {
+		check("matrix", explainMatrix(*step.Matrix, collection))
+	}
+
+	if step.Subjects != nil {
+		check("subjects", step.Subjects.Verify(collection, env))
+	}
+
+	if step.Worktree != nil {
+		check("worktree", step.Worktree.Verify(collection))
+	}
+
+	if step.Versions != nil {
+		check("versions", step.Versions.Verify(collection))
+	}
+
+	if step.LogInclusion != nil {
+		check("logInclusion", step.LogInclusion.Verify(p.TransparencyLogs, p.LogBundles, env))
+	}
+
+	for _, r := range step.Rego {
+		check(fmt.Sprintf("rego %v", r.Name), r.Verify(collection))
+	}
+
+	return collection.Name, checks, nil
+}
+
+// explainMatrix returns an error if the collection's coordinates are not one of the constraint's variants.
+func explainMatrix(m MatrixConstraint, collection Collection) error {
+	variants, err := m.Variants()
+	if err != nil {
+		return err
+	}
+
+	coordinates, err := collectionCoordinates(collection)
+	if err != nil {
+		return err
+	}
+
+	if len(coordinates) == 0 {
+		return fmt.Errorf("collection has no matrix attestation")
+	}
+
+	for _, variant := range variants {
+		if matchesVariant(coordinates, variant) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("collection's matrix coordinates %v are not a variant of the matrix", variantString(coordinates))
+}
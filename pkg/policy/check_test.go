@@ -0,0 +1,69 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestExplain(t *testing.T) {
+	p := Policy{Steps: map[string]Step{"build": {
+		Name:     "build",
+		Command:  &CommandConstraint{Exact: []string{"make", "release"}},
+		Matrix:   &MatrixConstraint{Dimensions: map[string][]string{"os": {"linux", "darwin"}}},
+		Worktree: &WorktreeConstraint{},
+	}}}
+
+	step, checks, err := p.Explain(matrixEnvelope(t, "build", map[string]string{"os": "linux"}, "make", "release"))
+	if err != nil {
+		t.Fatalf("failed to explain collection: %v", err)
+	}
+
+	if step != "build" || len(checks) != 3 {
+		t.Fatalf("expected three checks for build, got %v %+v", step, checks)
+	}
+
+	expected := []struct {
+		constraint string
+		reason     string
+	}{
+		{"command", ""},
+		{"matrix", ""},
+		{"worktree", "no git attestation"},
+	}
+
+	for i, e := range expected {
+		c := checks[i]
+		if c.Constraint != e.constraint || c.Passed != (e.reason == "") || !strings.Contains(c.Reason, e.reason) {
+			t.Errorf("expected %v check with reason %q, got %+v", e.constraint, e.reason, c)
+		}
+	}
+
+	_, checks, err = p.Explain(matrixEnvelope(t, "build", map[string]string{"os": "windows"}, "make"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range checks {
+		if c.Passed {
+			t.Errorf("expected every check to fail, got %+v", c)
+		}
+	}
+
+	if _, _, err := p.Explain(commandEnvelope(t, "deploy", "make")); err == nil {
+		t.Error("expected a collection for a step not in the policy to fail")
+	}
+}
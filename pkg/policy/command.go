@@ -0,0 +1,80 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+)
+
+const CommandRunType = "https://witness.dev/attestations/command-run/v0.1"
+
+// CommandConstraint restricts the command recorded by a step's command-run attestation, similar to in-toto's
+// expected_command. Exact requires the command and its arguments to match exactly. Glob matches each argument
+// against a pattern using path.Match syntax. If both are set the command must satisfy both.
+type CommandConstraint struct {
+	Exact []string `json:"exact,omitempty"`
+	Glob  []string `json:"glob,omitempty"`
+}
+
+// Verify checks the command recorded by the collection's command-run attestation against the constraint.
+func (c CommandConstraint) Verify(collection Collection) error {
+	raw, ok := collection.Attestation(CommandRunType)
+	if !ok {
+		return fmt.Errorf("collection has no command-run attestation")
+	}
+
+	commandRun := struct {
+		Cmd []string `json:"cmd"`
+	}{}
+
+	if err := json.Unmarshal(raw, &commandRun); err != nil {
+		return fmt.Errorf("failed to unmarshal command-run attestation: %w", err)
+	}
+
+	cmd := commandRun.Cmd
+	if c.Exact != nil {
+		if len(cmd) != len(c.Exact) {
+			return fmt.Errorf("command %q does not match %q", strings.Join(cmd, " "), strings.Join(c.Exact, " "))
+		}
+
+		for i := range cmd {
+			if cmd[i] != c.Exact[i] {
+				return fmt.Errorf("command %q does not match %q", strings.Join(cmd, " "), strings.Join(c.Exact, " "))
+			}
+		}
+	}
+
+	if c.Glob != nil {
+		if len(cmd) != len(c.Glob) {
+			return fmt.Errorf("command %q does not match pattern %q", strings.Join(cmd, " "), strings.Join(c.Glob, " "))
+		}
+
+		for i := range cmd {
+			matched, err := path.Match(c.Glob[i], cmd[i])
+			if err != nil {
+				return fmt.Errorf("invalid command pattern %q: %w", c.Glob[i], err)
+			}
+
+			if !matched {
+				return fmt.Errorf("command %q does not match pattern %q", strings.Join(cmd, " "), strings.Join(c.Glob, " "))
+			}
+		}
+	}
+
+	return nil
+}
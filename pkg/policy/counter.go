@@ -0,0 +1,145 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/testifysec/go-witness/dsse"
+)
+
+const BuildCounterType = "https://witness.dev/attestations/build-counter/v0.1"
+
+// CounterConstraint requires a step's collection to carry a build-counter attestation from the source and counter
+// name, if set, with a counter no lower than Minimum.
+type CounterConstraint struct {
+	Source  string `json:"source,omitempty"`
+	Name    string `json:"name,omitempty"`
+	Minimum uint64 `json:"minimum,omitempty"`
+}
+
+type buildCounter struct {
+	Source  string `json:"source"`
+	Name    string `json:"name"`
+	Counter uint64 `json:"counter"`
+}
+
+// Verify checks the collection's build-counter attestation against the constraint.
+func (c CounterConstraint) Verify(collection Collection) error {
+	counter, ok, err := collectionCounter(collection)
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		return fmt.Errorf("collection has no build-counter attestation")
+	}
+
+	if c.Source != "" && counter.Source != c.Source {
+		return fmt.Errorf("build counter source %v does not match %v", counter.Source, c.Source)
+	}
+
+	if c.Name != "" && counter.Name != c.Name {
+		return fmt.Errorf("build counter name %v does not match %v", counter.Name, c.Name)
+	}
+
+	if counter.Counter < c.Minimum {
+		return fmt.Errorf("build counter %v is lower than the minimum %v", counter.Counter, c.Minimum)
+	}
+
+	return nil
+}
+
+func collectionCounter(collection Collection) (buildCounter, bool, error) {
+	raw, ok := collection.Attestation(BuildCounterType)
+	if !ok {
+		return buildCounter{}, false, nil
+	}
+
+	counter := buildCounter{}
+	if err := json.Unmarshal(raw, &counter); err != nil {
+		return buildCounter{}, false, fmt.Errorf("failed to unmarshal build-counter attestation: %w", err)
+	}
+
+	return counter, true, nil
+}
+
+// CounterState holds the highest build counter a verifier has accepted for each step. Checking verified collections
+// against it rejects an older build that is replayed as the latest release.
+type CounterState map[string]uint64
+
+// LoadCounterState reads the counter state from the file. A missing file is an empty state.
+func LoadCounterState(path string) (CounterState, error) {
+	state := CounterState{}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read counter state: %w", err)
+	}
+
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal counter state: %w", err)
+	}
+
+	return state, nil
+}
+
+// Save writes the counter state to the file.
+func (s CounterState) Save(path string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal counter state: %w", err)
+	}
+
+	return os.WriteFile(path, data, 0644)
+}
+
+// Check fails if the highest build counter of any step's verified collections is lower than the highest counter
+// previously accepted for the step, and otherwise records the new highest counters. Verifying the same build again
+// passes.
+func (s CounterState) Check(envelopes []dsse.Envelope) error {
+	highest := make(map[string]uint64)
+	for _, env := range envelopes {
+		collection, err := CollectionFromEnvelope(env)
+		if err != nil {
+			continue
+		}
+
+		counter, ok, err := collectionCounter(collection)
+		if err != nil {
+			return err
+		}
+
+		if ok && counter.Counter >= highest[collection.Name] {
+			highest[collection.Name] = counter.Counter
+		}
+	}
+
+	for step, counter := range highest {
+		if counter < s[step] {
+			return ErrConstraintFailed{Step: step, Reason: fmt.Sprintf("build counter %v is lower than previously accepted counter %v", counter, s[step])}
+		}
+	}
+
+	for step, counter := range highest {
+		s[step] = counter
+	}
+
+	return nil
+}
@@ -0,0 +1,81 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/testifysec/go-witness/dsse"
+)
+
+func counterEnvelope(t *testing.T, step string, counter uint64) dsse.Envelope {
+	return testEnvelope(t, step, map[string]interface{}{
+		BuildCounterType: map[string]interface{}{"source": "tpm:0x1500016", "counter": counter},
+	})
+}
+
+func TestCounterConstraint(t *testing.T) {
+	p := Policy{Steps: map[string]Step{"build": {Name: "build", BuildCounter: &CounterConstraint{Source: "tpm:0x1500016", Minimum: 10}}}}
+	if _, err := p.Verify([]dsse.Envelope{counterEnvelope(t, "build", 10)}); err != nil {
+		t.Errorf("expected counter at the minimum to pass: %v", err)
+	}
+
+	if _, err := p.Verify([]dsse.Envelope{counterEnvelope(t, "build", 9)}); err == nil {
+		t.Error("expected counter below the minimum to fail")
+	}
+
+	if _, err := p.Verify([]dsse.Envelope{testEnvelope(t, "build", nil)}); err == nil {
+		t.Error("expected collection without a build counter to fail")
+	}
+
+	p.Steps["build"].BuildCounter.Source = "https://archivista.example.com/sequence"
+	if _, err := p.Verify([]dsse.Envelope{counterEnvelope(t, "build", 10)}); err == nil {
+		t.Error("expected counter from another source to fail")
+	}
+}
+
+func TestCounterState(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "counters.json")
+	state, err := LoadCounterState(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := state.Check([]dsse.Envelope{counterEnvelope(t, "build", 5), counterEnvelope(t, "build", 7)}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := state.Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	state, err = LoadCounterState(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if state["build"] != 7 {
+		t.Errorf("expected highest counter to be recorded, got %v", state["build"])
+	}
+
+	if err := state.Check([]dsse.Envelope{counterEnvelope(t, "build", 7)}); err != nil {
+		t.Errorf("expected the same build to verify again: %v", err)
+	}
+
+	if err := state.Check([]dsse.Envelope{counterEnvelope(t, "build", 5)}); err == nil {
+		t.Error("expected an older build to be rejected")
+	}
+}
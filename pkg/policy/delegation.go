@@ -0,0 +1,163 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/dsse"
+)
+
+// DelegationConstraint satisfies a step with a policy maintained by someone else, such as the supplier of a third
+// party component. The delegated policy must be signed by one of the parent policy's public keys named in Keys, or
+// with a certificate chaining to one of its roots named in Roots. The step's collections are verified against the
+// delegated policy instead of the step's functionaries and attestations.
+type DelegationConstraint struct {
+	Policy string   `json:"policy"`
+	Keys   []string `json:"keys,omitempty"`
+	Roots  []string `json:"roots,omitempty"`
+}
+
+// PublicKey is a public key from the policy.
+type PublicKey struct {
+	KeyID string `json:"keyid"`
+	Key   []byte `json:"key"`
+}
+
+// Delegations returns the delegated steps of the policy, sorted by name.
+func (p Policy) Delegations() []string {
+	steps := make([]string, 0)
+	for name, step := range p.Steps {
+		if step.Delegation != nil {
+			steps = append(steps, name)
+		}
+	}
+
+	sort.Strings(steps)
+	return steps
+}
+
+// VerifyDelegatedPolicy checks that the delegated policy for the step is signed by one of the delegation's keys or
+// roots.
+func (p Policy) VerifyDelegatedPolicy(step string, env dsse.Envelope) error {
+	s, ok := p.Steps[step]
+	if !ok || s.Delegation == nil {
+		return fmt.Errorf("step %v is not delegated", step)
+	}
+
+	if err := p.verifyReferencedPolicy(s.Delegation.Keys, s.Delegation.Roots, env); err != nil {
+		return fmt.Errorf("delegated policy for step %v: %w", step, err)
+	}
+
+	return nil
+}
+
+// verifyReferencedPolicy checks that a policy referenced by this one is signed by one of the named public keys, or
+// with a certificate chaining to one of the named roots.
+func (p Policy) verifyReferencedPolicy(keyIDs, rootNames []string, env dsse.Envelope) error {
+	verifiers := make([]cryptoutil.Verifier, 0, len(keyIDs))
+	for _, keyID := range keyIDs {
+		key, ok := p.PublicKeys[keyID]
+		if !ok {
+			return fmt.Errorf("key %v is not in the policy", keyID)
+		}
+
+		verifier, err := cryptoutil.NewVerifierFromReader(bytes.NewReader(key.Key))
+		if err != nil {
+			return fmt.Errorf("failed to load key %v: %w", keyID, err)
+		}
+
+		verifiers = append(verifiers, verifier)
+	}
+
+	roots := make([]*x509.Certificate, 0, len(rootNames))
+	intermediates := make([]*x509.Certificate, 0)
+	for _, name := range rootNames {
+		root, ok := p.Roots[name]
+		if !ok {
+			return fmt.Errorf("root %v is not in the policy", name)
+		}
+
+		cert, err := dsse.TryParseCertificate(root.Certificate)
+		if err != nil {
+			return fmt.Errorf("failed to parse root %v: %w", name, err)
+		}
+
+		roots = append(roots, cert)
+		for _, intermediate := range root.Intermediates {
+			if cert, err := dsse.TryParseCertificate(intermediate); err == nil {
+				intermediates = append(intermediates, cert)
+			}
+		}
+	}
+
+	if len(verifiers) == 0 && len(roots) == 0 {
+		return fmt.Errorf("no keys or roots are named to verify the policy with")
+	}
+
+	if _, err := env.Verify(dsse.WithVerifiers(verifiers), dsse.WithRoots(roots), dsse.WithIntermediates(intermediates)); err != nil {
+		return fmt.Errorf("policy is not signed by a named key or root: %w", err)
+	}
+
+	return nil
+}
+
+// WithoutSteps returns the policy json with the steps removed, along with any references to them in the other steps'
+// artifactsFrom. Fields witness does not know about are kept.
+func WithoutSteps(payload []byte, steps []string) ([]byte, error) {
+	p := make(map[string]interface{})
+	if err := json.Unmarshal(payload, &p); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal policy: %w", err)
+	}
+
+	removed := make(map[string]bool, len(steps))
+	for _, step := range steps {
+		removed[step] = true
+	}
+
+	policySteps, _ := p["steps"].(map[string]interface{})
+	for name, step := range policySteps {
+		if removed[name] {
+			delete(policySteps, name)
+			continue
+		}
+
+		s, ok := step.(map[string]interface{})
+		if !ok {
+			continue
+		}
+
+		artifactsFrom, ok := s["artifactsFrom"].([]interface{})
+		if !ok {
+			continue
+		}
+
+		kept := make([]interface{}, 0, len(artifactsFrom))
+		for _, from := range artifactsFrom {
+			if name, ok := from.(string); !ok || !removed[name] {
+				kept = append(kept, from)
+			}
+		}
+
+		s["artifactsFrom"] = kept
+	}
+
+	return json.Marshal(p)
+}
@@ -0,0 +1,132 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"testing"
+
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/dsse"
+)
+
+func TestVerifyDelegatedPolicy(t *testing.T) {
+	supplier, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	der, err := x509.MarshalPKIXPublicKey(&supplier.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ca := newTestCA(t)
+	p := Policy{
+		Roots:      map[string]Root{"supplier-ca": {Certificate: ca.pem()}},
+		PublicKeys: map[string]PublicKey{"supplier": {KeyID: "supplier", Key: pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})}},
+		Steps: map[string]Step{
+			"build":   {Name: "build"},
+			"vendor":  {Name: "vendor", Delegation: &DelegationConstraint{Policy: "vendor.json", Keys: []string{"supplier"}}},
+			"lib":     {Name: "lib", Delegation: &DelegationConstraint{Policy: "lib.json", Roots: []string{"supplier-ca"}}},
+			"unknown": {Name: "unknown", Delegation: &DelegationConstraint{Policy: "unknown.json", Keys: []string{"missing"}}},
+		},
+	}
+
+	if got := p.Delegations(); len(got) != 3 || got[0] != "lib" || got[1] != "unknown" || got[2] != "vendor" {
+		t.Fatalf("unexpected delegations: %v", got)
+	}
+
+	sign := func(key *ecdsa.PrivateKey) dsse.Envelope {
+		env, err := dsse.Sign("https://witness.testifysec.com/policy/v0.1", bytes.NewReader([]byte(`{}`)), cryptoutil.NewECDSASigner(key, crypto.SHA256))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return env
+	}
+
+	if err := p.VerifyDelegatedPolicy("vendor", sign(supplier)); err != nil {
+		t.Fatalf("expected delegated policy signed by the supplier's key to verify: %v", err)
+	}
+
+	other, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := p.VerifyDelegatedPolicy("vendor", sign(other)); err == nil {
+		t.Fatal("expected delegated policy signed by another key to fail")
+	}
+
+	if err := p.VerifyDelegatedPolicy("lib", ca.sign(t, dsse.Envelope{PayloadType: "https://witness.testifysec.com/policy/v0.1", Payload: []byte(`{}`)}, "supplier@example.com")); err != nil {
+		t.Fatalf("expected delegated policy signed under the supplier's root to verify: %v", err)
+	}
+
+	if err := p.VerifyDelegatedPolicy("unknown", sign(supplier)); err == nil {
+		t.Fatal("expected delegation naming an unknown key to fail")
+	}
+
+	if err := p.VerifyDelegatedPolicy("build", sign(supplier)); err == nil {
+		t.Fatal("expected step without a delegation to fail")
+	}
+}
+
+func TestWithoutSteps(t *testing.T) {
+	payload := []byte(`{
+		"expires": "2030-01-01T00:00:00Z",
+		"steps": {
+			"vendor": {"name": "vendor", "delegation": {"policy": "vendor.json"}},
+			"build": {"name": "build", "artifactsFrom": ["vendor", "checkout"], "extra": true},
+			"checkout": {"name": "checkout"}
+		}
+	}`)
+
+	stripped, err := WithoutSteps(payload, []string{"vendor"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := struct {
+		Expires string `json:"expires"`
+		Steps   map[string]struct {
+			ArtifactsFrom []string `json:"artifactsFrom"`
+			Extra         bool     `json:"extra"`
+		} `json:"steps"`
+	}{}
+	if err := json.Unmarshal(stripped, &p); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := p.Steps["vendor"]; ok {
+		t.Fatal("expected the delegated step to be removed")
+	}
+
+	build := p.Steps["build"]
+	if len(build.ArtifactsFrom) != 1 || build.ArtifactsFrom[0] != "checkout" || !build.Extra {
+		t.Fatalf("unexpected build step: %+v", build)
+	}
+
+	if p.Expires != "2030-01-01T00:00:00Z" {
+		t.Fatalf("expected other fields to be kept, got expires %q", p.Expires)
+	}
+}
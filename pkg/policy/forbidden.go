@@ -0,0 +1,163 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// ForbiddenAttestation describes an attestation that must not appear in any verified collection for a step. Without
+// conditions any attestation of the type is forbidden. With conditions, an attestation of the type is forbidden if
+// it matches all of them.
+type ForbiddenAttestation struct {
+	Type       string      `json:"type"`
+	Conditions []Condition `json:"conditions,omitempty"`
+}
+
+// Condition matches the values found at a dot separated path within an attestation. Arrays along the path, and at
+// its end, are searched element by element. If Equals is set the condition matches when any value equals it. If
+// NotIn is set it matches when any value is not in the list. Otherwise it matches when the path exists.
+type Condition struct {
+	Path   string          `json:"path"`
+	Equals json.RawMessage `json:"equals,omitempty"`
+	NotIn  []string        `json:"notIn,omitempty"`
+}
+
+// Check returns an error describing the first attestation in the collection that is forbidden.
+func (f ForbiddenAttestation) Check(collection Collection) error {
+	for _, attestation := range collection.Attestations {
+		if attestation.Type != f.Type {
+			continue
+		}
+
+		matched, reason, err := f.matches(attestation.Attestation)
+		if err != nil {
+			return err
+		}
+
+		if matched {
+			if reason == "" {
+				return fmt.Errorf("collection contains forbidden attestation %v", f.Type)
+			}
+
+			return fmt.Errorf("collection contains forbidden attestation %v: %v", f.Type, reason)
+		}
+	}
+
+	return nil
+}
+
+func (f ForbiddenAttestation) matches(raw json.RawMessage) (bool, string, error) {
+	if len(f.Conditions) == 0 {
+		return true, "", nil
+	}
+
+	var attestation interface{}
+	if err := json.Unmarshal(raw, &attestation); err != nil {
+		return false, "", fmt.Errorf("failed to unmarshal %v attestation: %w", f.Type, err)
+	}
+
+	reasons := make([]string, 0, len(f.Conditions))
+	for _, condition := range f.Conditions {
+		reason, matched, err := condition.match(attestation)
+		if err != nil || !matched {
+			return false, "", err
+		}
+
+		reasons = append(reasons, reason)
+	}
+
+	return true, strings.Join(reasons, ", "), nil
+}
+
+func (c Condition) match(attestation interface{}) (string, bool, error) {
+	values := valuesAt(attestation, strings.Split(c.Path, "."))
+	if len(values) == 0 {
+		return "", false, nil
+	}
+
+	if len(c.Equals) > 0 {
+		var expected interface{}
+		if err := json.Unmarshal(c.Equals, &expected); err != nil {
+			return "", false, fmt.Errorf("invalid equals value for %v: %w", c.Path, err)
+		}
+
+		expectedJSON, err := json.Marshal(expected)
+		if err != nil {
+			return "", false, err
+		}
+
+		for _, value := range values {
+			valueJSON, err := json.Marshal(value)
+			if err != nil {
+				return "", false, err
+			}
+
+			if bytes.Equal(valueJSON, expectedJSON) {
+				return fmt.Sprintf("%v is %s", c.Path, expectedJSON), true, nil
+			}
+		}
+
+		return "", false, nil
+	}
+
+	if c.NotIn != nil {
+		allowed := make(map[string]bool, len(c.NotIn))
+		for _, a := range c.NotIn {
+			allowed[a] = true
+		}
+
+		for _, value := range values {
+			s := fmt.Sprint(value)
+			if !allowed[s] {
+				return fmt.Sprintf("%v contains %v", c.Path, s), true, nil
+			}
+		}
+
+		return "", false, nil
+	}
+
+	return fmt.Sprintf("%v is present", c.Path), true, nil
+}
+
+func valuesAt(value interface{}, path []string) []interface{} {
+	if arr, ok := value.([]interface{}); ok {
+		values := make([]interface{}, 0)
+		for _, item := range arr {
+			values = append(values, valuesAt(item, path)...)
+		}
+
+		return values
+	}
+
+	if len(path) == 0 || (len(path) == 1 && path[0] == "") {
+		return []interface{}{value}
+	}
+
+	obj, ok := value.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+
+	next, ok := obj[path[0]]
+	if !ok {
+		return nil
+	}
+
+	return valuesAt(next, path[1:])
+}
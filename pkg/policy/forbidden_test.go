@@ -0,0 +1,121 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/testifysec/go-witness/dsse"
+)
+
+const networkType = "https://example.com/attestations/network/v0.1"
+
+func networkEnvelope(t *testing.T, hosts ...string) dsse.Envelope {
+	connections := make([]map[string]interface{}, 0, len(hosts))
+	for _, host := range hosts {
+		connections = append(connections, map[string]interface{}{"host": host, "port": 443})
+	}
+
+	return testEnvelope(t, "build", map[string]interface{}{
+		networkType:    map[string]interface{}{"connections": connections},
+		CommandRunType: map[string]interface{}{"cmd": []string{"make"}, "debugger": false},
+	})
+}
+
+func TestForbidden(t *testing.T) {
+	tests := []struct {
+		name      string
+		forbidden ForbiddenAttestation
+		envelope  func(t *testing.T) dsse.Envelope
+		pass      bool
+	}{
+		{
+			name:      "type present",
+			forbidden: ForbiddenAttestation{Type: networkType},
+			envelope:  func(t *testing.T) dsse.Envelope { return networkEnvelope(t, "proxy.golang.org") },
+		},
+		{
+			name:      "type absent",
+			forbidden: ForbiddenAttestation{Type: networkType},
+			envelope:  func(t *testing.T) dsse.Envelope { return commandEnvelope(t, "build", "make") },
+			pass:      true,
+		},
+		{
+			name: "hosts allowed",
+			forbidden: ForbiddenAttestation{Type: networkType, Conditions: []Condition{
+				{Path: "connections.host", NotIn: []string{"proxy.golang.org", "github.com"}},
+			}},
+			envelope: func(t *testing.T) dsse.Envelope { return networkEnvelope(t, "proxy.golang.org", "github.com") },
+			pass:     true,
+		},
+		{
+			name: "host not allowed",
+			forbidden: ForbiddenAttestation{Type: networkType, Conditions: []Condition{
+				{Path: "connections.host", NotIn: []string{"proxy.golang.org"}},
+			}},
+			envelope: func(t *testing.T) dsse.Envelope { return networkEnvelope(t, "proxy.golang.org", "evil.example.com") },
+		},
+		{
+			name: "equals mismatch",
+			forbidden: ForbiddenAttestation{Type: CommandRunType, Conditions: []Condition{
+				{Path: "debugger", Equals: json.RawMessage("true")},
+			}},
+			envelope: func(t *testing.T) dsse.Envelope { return networkEnvelope(t) },
+			pass:     true,
+		},
+		{
+			name: "equals match",
+			forbidden: ForbiddenAttestation{Type: CommandRunType, Conditions: []Condition{
+				{Path: "debugger", Equals: json.RawMessage("false")},
+			}},
+			envelope: func(t *testing.T) dsse.Envelope { return networkEnvelope(t) },
+		},
+		{
+			name: "all conditions must match",
+			forbidden: ForbiddenAttestation{Type: networkType, Conditions: []Condition{
+				{Path: "connections.port", Equals: json.RawMessage("443")},
+				{Path: "connections.protocol"},
+			}},
+			envelope: func(t *testing.T) dsse.Envelope { return networkEnvelope(t, "github.com") },
+			pass:     true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := Policy{Steps: map[string]Step{"build": {Name: "build", Forbidden: []ForbiddenAttestation{test.forbidden}}}}
+			_, err := p.Verify([]dsse.Envelope{test.envelope(t)})
+			if test.pass && err != nil {
+				t.Errorf("expected step to pass: %v", err)
+			} else if !test.pass && err == nil {
+				t.Error("expected step to fail")
+			}
+		})
+	}
+}
+
+func TestForbiddenAnyCollection(t *testing.T) {
+	p := Policy{Steps: map[string]Step{"build": {
+		Name:      "build",
+		Command:   &CommandConstraint{Exact: []string{"make"}},
+		Forbidden: []ForbiddenAttestation{{Type: networkType}},
+	}}}
+
+	envelopes := []dsse.Envelope{commandEnvelope(t, "build", "make"), networkEnvelope(t, "github.com")}
+	if _, err := p.Verify(envelopes); err == nil {
+		t.Error("expected a forbidden attestation in any collection to fail the step")
+	}
+}
@@ -0,0 +1,75 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// AddPublicKeyFunctionaries adds the keys to the policy's publickeys and trusts them as publickey functionaries of the
+// steps, returning the updated policy json. Keys and functionaries already in the policy are not duplicated, and fields
+// of the policy that witness doesn't read are kept.
+func AddPublicKeyFunctionaries(payload []byte, keys []PublicKey, steps []string) ([]byte, error) {
+	doc := make(map[string]interface{})
+	if err := json.Unmarshal(payload, &doc); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal policy: %w", err)
+	}
+
+	publicKeys, ok := doc["publickeys"].(map[string]interface{})
+	if !ok {
+		publicKeys = make(map[string]interface{})
+	}
+
+	for _, key := range keys {
+		publicKeys[key.KeyID] = key
+	}
+
+	doc["publickeys"] = publicKeys
+	policySteps, _ := doc["steps"].(map[string]interface{})
+	for _, name := range steps {
+		step, ok := policySteps[name].(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("step %v is not in the policy", name)
+		}
+
+		functionaries, _ := step["functionaries"].([]interface{})
+		trusted := make(map[string]bool)
+		for _, f := range functionaries {
+			if functionary, ok := f.(map[string]interface{}); ok && functionary["type"] == "publickey" {
+				if keyID, ok := functionary["publickeyid"].(string); ok {
+					trusted[keyID] = true
+				}
+			}
+		}
+
+		for _, key := range keys {
+			if trusted[key.KeyID] {
+				continue
+			}
+
+			functionaries = append(functionaries, map[string]interface{}{"type": "publickey", "publickeyid": key.KeyID})
+		}
+
+		step["functionaries"] = functionaries
+	}
+
+	updated, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal policy: %w", err)
+	}
+
+	return updated, nil
+}
@@ -0,0 +1,71 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestAddPublicKeyFunctionaries(t *testing.T) {
+	payload := []byte(`{
+  "expires": "2030-01-01T00:00:00Z",
+  "publickeys": {"existing": {"keyid": "existing", "key": "a2V5"}},
+  "steps": {
+    "build": {"name": "build", "functionaries": [{"type": "publickey", "publickeyid": "alice"}], "buildCounter": {"minimum": 3}},
+    "test": {"name": "test"}
+  }
+}`)
+
+	keys := []PublicKey{{KeyID: "alice", Key: []byte("alice key")}, {KeyID: "bob", Key: []byte("bob key")}}
+	updated, err := AddPublicKeyFunctionaries(payload, keys, []string{"build"})
+	if err != nil {
+		t.Fatalf("failed to add functionaries: %v", err)
+	}
+
+	doc := struct {
+		Expires    string               `json:"expires"`
+		PublicKeys map[string]PublicKey `json:"publickeys"`
+		Steps      map[string]struct {
+			Functionaries []map[string]string `json:"functionaries"`
+			BuildCounter  *CounterConstraint  `json:"buildCounter"`
+		} `json:"steps"`
+	}{}
+
+	if err := json.Unmarshal(updated, &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(doc.PublicKeys) != 3 || string(doc.PublicKeys["bob"].Key) != "bob key" {
+		t.Errorf("expected the keys to be added to publickeys, got %v", doc.PublicKeys)
+	}
+
+	build := doc.Steps["build"]
+	if len(build.Functionaries) != 2 || build.Functionaries[1]["publickeyid"] != "bob" {
+		t.Errorf("expected bob to be added once as a functionary, got %v", build.Functionaries)
+	}
+
+	if build.BuildCounter == nil || doc.Expires == "" {
+		t.Error("expected the policy's other fields to be kept")
+	}
+
+	if len(doc.Steps["test"].Functionaries) != 0 {
+		t.Error("expected steps that weren't named to be left alone")
+	}
+
+	if _, err := AddPublicKeyFunctionaries(payload, keys, []string{"deploy"}); err == nil {
+		t.Error("expected a missing step to fail")
+	}
+}
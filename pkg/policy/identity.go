@@ -0,0 +1,266 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/asn1"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+
+	gwpolicy "github.com/testifysec/go-witness/policy"
+
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/dsse"
+)
+
+var (
+	// oidFulcioIssuer is the OIDC issuer extension of certificates issued by older versions of Fulcio, which holds the
+	// issuer's url as raw bytes.
+	oidFulcioIssuer = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 1}
+	// oidFulcioIssuerV2 is the DER encoded OIDC issuer extension that replaced oidFulcioIssuer.
+	oidFulcioIssuerV2 = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 8}
+)
+
+// Functionary is a functionary of a step, as go-witness reads it along with the identity constraints witness adds to
+// its certConstraint.
+type Functionary struct {
+	Type           string         `json:"type"`
+	CertConstraint CertConstraint `json:"certConstraint,omitempty"`
+	PublicKeyID    string         `json:"publickeyid,omitempty"`
+}
+
+// CertConstraint adds constraints on who issued a functionary's certificate to go-witness's constraints on its
+// subject. Each value may be a pattern matched with path.Match.
+type CertConstraint struct {
+	gwpolicy.CertConstraint
+
+	// Issuers are the common names of the CAs that may issue the certificate.
+	Issuers []string `json:"issuers,omitempty"`
+	// OIDCIssuers are the OIDC issuers that may have authenticated the signer, read from the issuer extension of
+	// certificates issued by Fulcio.
+	OIDCIssuers []string `json:"oidcIssuers,omitempty"`
+	// Extensions maps extension OIDs, such as Fulcio's 1.3.6.1.4.1.57264.1.12 source repository URI, to the value
+	// the certificate's extension must have.
+	Extensions map[string]string `json:"extensions,omitempty"`
+}
+
+// HasIdentityConstraints returns true if the constraint sets any of the constraints witness adds.
+func (c CertConstraint) HasIdentityConstraints() bool {
+	return len(c.Issuers) > 0 || len(c.OIDCIssuers) > 0 || len(c.Extensions) > 0
+}
+
+// CheckIdentity checks the certificate against the constraint's issuers, OIDC issuers, and extensions.
+func (c CertConstraint) CheckIdentity(cert *x509.Certificate) error {
+	if len(c.Issuers) > 0 && !matchAny(c.Issuers, cert.Issuer.CommonName) {
+		return fmt.Errorf("certificate issuer %q is not one of %q", cert.Issuer.CommonName, c.Issuers)
+	}
+
+	if len(c.OIDCIssuers) > 0 {
+		issuer, ok := extensionValue(cert, oidFulcioIssuerV2)
+		if !ok {
+			issuer, ok = extensionValue(cert, oidFulcioIssuer)
+		}
+
+		if !ok {
+			return fmt.Errorf("certificate has no oidc issuer")
+		}
+
+		if !matchAny(c.OIDCIssuers, issuer) {
+			return fmt.Errorf("certificate oidc issuer %q is not one of %q", issuer, c.OIDCIssuers)
+		}
+	}
+
+	oids := make([]string, 0, len(c.Extensions))
+	for oid := range c.Extensions {
+		oids = append(oids, oid)
+	}
+
+	sort.Strings(oids)
+	for _, oid := range oids {
+		parsed, err := ParseOID(oid)
+		if err != nil {
+			return err
+		}
+
+		value, ok := extensionValue(cert, parsed)
+		if !ok {
+			return fmt.Errorf("certificate has no extension %v", oid)
+		}
+
+		if !matchAny([]string{c.Extensions[oid]}, value) {
+			return fmt.Errorf("certificate extension %v is %q, expected %q", oid, value, c.Extensions[oid])
+		}
+	}
+
+	return nil
+}
+
+// ParseOID parses an object identifier in dotted form, such as 1.3.6.1.4.1.57264.1.8.
+func ParseOID(oid string) (asn1.ObjectIdentifier, error) {
+	parts := strings.Split(oid, ".")
+	if len(parts) < 2 {
+		return nil, fmt.Errorf("invalid oid %v", oid)
+	}
+
+	parsed := make(asn1.ObjectIdentifier, 0, len(parts))
+	for _, part := range parts {
+		n := 0
+		if part == "" {
+			return nil, fmt.Errorf("invalid oid %v", oid)
+		}
+
+		for _, c := range part {
+			if c < '0' || c > '9' || n > (1<<31-1)/10 {
+				return nil, fmt.Errorf("invalid oid %v", oid)
+			}
+
+			n = n*10 + int(c-'0')
+		}
+
+		parsed = append(parsed, n)
+	}
+
+	return parsed, nil
+}
+
+// extensionValue returns the string value of a certificate extension. Values that are a DER encoded string are
+// decoded, and others, like the extensions of older Fulcio certificates, are returned as they are.
+func extensionValue(cert *x509.Certificate, oid asn1.ObjectIdentifier) (string, bool) {
+	for _, ext := range cert.Extensions {
+		if !ext.Id.Equal(oid) {
+			continue
+		}
+
+		var value string
+		if rest, err := asn1.Unmarshal(ext.Value, &value); err == nil && len(rest) == 0 {
+			return value, true
+		}
+
+		return string(ext.Value), true
+	}
+
+	return "", false
+}
+
+func matchAny(patterns []string, value string) bool {
+	for _, pattern := range patterns {
+		if pattern == value {
+			return true
+		}
+
+		if matched, err := path.Match(pattern, value); err == nil && matched {
+			return true
+		}
+	}
+
+	return false
+}
+
+// hasIdentityConstraints returns true if any of the step's functionaries has identity constraints.
+func (s Step) hasIdentityConstraints() bool {
+	for _, f := range s.Functionaries {
+		if f.CertConstraint.HasIdentityConstraints() {
+			return true
+		}
+	}
+
+	return false
+}
+
+// verifyFunctionaries checks that the envelope is signed by one of the step's functionaries, including the identity
+// constraints of their certConstraints. go-witness checks functionaries without the identity constraints, so a
+// certificate must satisfy all the constraints of a single functionary to pass.
+func (p Policy) verifyFunctionaries(s Step, env dsse.Envelope) error {
+	bundles := p.trustBundles()
+	verifiers := p.signerVerifiers(env)
+	reasons := make([]string, 0)
+	for _, f := range s.Functionaries {
+		if f.Type == "publickey" {
+			if err := p.verifyPublicKey(f.PublicKeyID, env); err != nil {
+				reasons = append(reasons, err.Error())
+				continue
+			}
+
+			return nil
+		}
+
+		for _, verifier := range verifiers {
+			if err := f.CertConstraint.Check(verifier, bundles); err != nil {
+				reasons = append(reasons, err.Error())
+				continue
+			}
+
+			if err := f.CertConstraint.CheckIdentity(verifier.Certificate()); err != nil {
+				reasons = append(reasons, err.Error())
+				continue
+			}
+
+			return nil
+		}
+	}
+
+	if len(reasons) == 0 {
+		reasons = append(reasons, "no signer has a certificate chaining to the policy's roots")
+	}
+
+	return fmt.Errorf("collection is not signed by a functionary of the step: %v", strings.Join(reasons, "; "))
+}
+
+func (p Policy) verifyPublicKey(keyID string, env dsse.Envelope) error {
+	for id, key := range p.PublicKeys {
+		if id != keyID && key.KeyID != keyID {
+			continue
+		}
+
+		verifier, err := cryptoutil.NewVerifierFromReader(bytes.NewReader(key.Key))
+		if err != nil {
+			return fmt.Errorf("failed to load key %v: %w", keyID, err)
+		}
+
+		if _, err := env.Verify(dsse.WithVerifiers([]cryptoutil.Verifier{verifier})); err != nil {
+			return fmt.Errorf("not signed by key %v", keyID)
+		}
+
+		return nil
+	}
+
+	return fmt.Errorf("key %v is not in the policy", keyID)
+}
+
+// trustBundles returns the policy's roots in the form go-witness checks certificates against.
+func (p Policy) trustBundles() map[string]gwpolicy.TrustBundle {
+	bundles := make(map[string]gwpolicy.TrustBundle)
+	for id, root := range p.Roots {
+		cert, err := dsse.TryParseCertificate(root.Certificate)
+		if err != nil {
+			continue
+		}
+
+		bundle := gwpolicy.TrustBundle{Root: cert}
+		for _, intermediate := range root.Intermediates {
+			if cert, err := dsse.TryParseCertificate(intermediate); err == nil {
+				bundle.Intermediates = append(bundle.Intermediates, cert)
+			}
+		}
+
+		bundles[id] = bundle
+	}
+
+	return bundles
+}
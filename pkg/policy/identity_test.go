@@ -0,0 +1,159 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import (
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"testing"
+
+	gwpolicy "github.com/testifysec/go-witness/policy"
+
+	"github.com/testifysec/go-witness/dsse"
+)
+
+func fulcioExtensions(t *testing.T, issuer, repository string) []pkix.Extension {
+	issuerValue, err := asn1.Marshal(issuer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	repositoryValue, err := asn1.Marshal(repository)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return []pkix.Extension{
+		{Id: oidFulcioIssuerV2, Value: issuerValue},
+		{Id: asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 12}, Value: repositoryValue},
+	}
+}
+
+func TestParseOID(t *testing.T) {
+	oid, err := ParseOID("1.3.6.1.4.1.57264.1.8")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !oid.Equal(oidFulcioIssuerV2) {
+		t.Errorf("expected %v, got %v", oidFulcioIssuerV2, oid)
+	}
+
+	for _, invalid := range []string{"", "1", "1..2", "1.a.2", "1.2."} {
+		if _, err := ParseOID(invalid); err == nil {
+			t.Errorf("expected %q to be rejected", invalid)
+		}
+	}
+}
+
+func TestIdentityConstraints(t *testing.T) {
+	ca := newTestCA(t)
+	functionary := func(c CertConstraint) Policy {
+		c.Emails = []string{"*"}
+		c.Roots = []string{"root"}
+		return Policy{
+			Roots: map[string]Root{"root": {Certificate: ca.pem()}},
+			Steps: map[string]Step{"build": {Name: "build", Functionaries: []Functionary{{Type: "root", CertConstraint: c}}}},
+		}
+	}
+
+	env := testEnvelope(t, "build", nil)
+	signed := func(issuer, repository string) dsse.Envelope {
+		return ca.signWith(t, env, &x509.Certificate{
+			EmailAddresses:  []string{"alice@example.com"},
+			ExtraExtensions: fulcioExtensions(t, issuer, repository),
+		})
+	}
+
+	github := signed("https://token.actions.githubusercontent.com", "https://github.com/testifysec/witness")
+	tests := []struct {
+		name       string
+		constraint CertConstraint
+		envelope   dsse.Envelope
+		pass       bool
+	}{
+		{"issuer", CertConstraint{Issuers: []string{"test root"}}, github, true},
+		{"other issuer", CertConstraint{Issuers: []string{"sigstore-intermediate"}}, github, false},
+		{"oidc issuer", CertConstraint{OIDCIssuers: []string{"https://token.actions.githubusercontent.com"}}, github, true},
+		{"other oidc issuer", CertConstraint{OIDCIssuers: []string{"https://accounts.google.com"}}, github, false},
+		{"no oidc issuer", CertConstraint{OIDCIssuers: []string{"*"}}, ca.sign(t, env, "alice@example.com"), false},
+		{"extension pattern", CertConstraint{Extensions: map[string]string{"1.3.6.1.4.1.57264.1.12": "https://github.com/testifysec/*"}}, github, true},
+		{"other extension", CertConstraint{Extensions: map[string]string{"1.3.6.1.4.1.57264.1.12": "https://github.com/other/*"}}, github, false},
+		{"missing extension", CertConstraint{Extensions: map[string]string{"1.3.6.1.4.1.57264.1.9": "*"}}, github, false},
+	}
+
+	for _, test := range tests {
+		_, err := functionary(test.constraint).Verify([]dsse.Envelope{test.envelope})
+		if test.pass && err != nil {
+			t.Errorf("%v: expected the collection to pass: %v", test.name, err)
+		} else if !test.pass && err == nil {
+			t.Errorf("%v: expected the collection to fail", test.name)
+		}
+	}
+
+	p := functionary(CertConstraint{OIDCIssuers: []string{"https://accounts.google.com"}})
+	step := p.Steps["build"]
+	step.Functionaries = append(step.Functionaries, Functionary{
+		Type: "root",
+		CertConstraint: CertConstraint{
+			CertConstraint: gwpolicy.CertConstraint{Emails: []string{"*"}, Roots: []string{"root"}},
+			OIDCIssuers:    []string{"https://token.actions.githubusercontent.com"},
+		},
+	})
+
+	p.Steps["build"] = step
+	if _, err := p.Verify([]dsse.Envelope{github}); err != nil {
+		t.Errorf("expected any functionary's constraints to satisfy the step: %v", err)
+	}
+}
+
+func TestParseIdentityConstraints(t *testing.T) {
+	p, err := Parse([]byte(`{"steps": {"build": {"name": "build", "functionaries": [{"type": "root", "certConstraint": {
+		"emails": ["alice@example.com"], "roots": ["root"], "oidcIssuers": ["https://accounts.google.com"]}}]}}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	constraint := p.Steps["build"].Functionaries[0].CertConstraint
+	if len(constraint.Emails) != 1 || len(constraint.Roots) != 1 || len(constraint.OIDCIssuers) != 1 {
+		t.Errorf("expected the go-witness and witness constraints to be parsed, got %+v", constraint)
+	}
+}
+
+func BenchmarkSignEnvelope(b *testing.B) {
+	signer, _ := newTestKey(b)
+	env := buildEnvelope(b, 1000)
+	b.ReportAllocs()
+	b.SetBytes(int64(len(env.Payload)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		signTestEnvelope(b, env, signer)
+	}
+}
+
+func BenchmarkVerifyPublicKey(b *testing.B) {
+	signer, key := newTestKey(b)
+	p := Policy{PublicKeys: map[string]PublicKey{key.KeyID: key}}
+	env := signTestEnvelope(b, buildEnvelope(b, 1000), signer)
+	b.ReportAllocs()
+	b.SetBytes(int64(len(env.Payload)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := p.verifyPublicKey(key.KeyID, env); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
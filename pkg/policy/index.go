@@ -0,0 +1,166 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import (
+	"crypto"
+	"encoding/json"
+	"sort"
+	"strings"
+
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/dsse"
+	"github.com/testifysec/go-witness/intoto"
+)
+
+// Index holds statements indexed by subject digest and predicate type. Attestations of directory trees can have tens
+// of thousands of subjects, so witness verify's artifact, archive, and base image subject checks look up each digest
+// in the index instead of scanning every subject of every statement. go-witness matches collections to steps and
+// evaluates rego policies over the envelopes itself, without the index.
+type Index struct {
+	statements  []IndexedStatement
+	subjects    []indexedSubject
+	bySubject   map[digestKey][]int
+	byPredicate map[string][]int
+}
+
+// IndexedStatement is a statement and the envelope it was signed in.
+type IndexedStatement struct {
+	Envelope  dsse.Envelope
+	Statement intoto.Statement
+}
+
+// indexedSubject is a distinct subject digest and the statements that have it.
+type indexedSubject struct {
+	digest     cryptoutil.DigestSet
+	statements []int
+}
+
+type digestKey struct {
+	hash   crypto.Hash
+	digest string
+}
+
+// NewIndex indexes the statements in the envelopes. Envelopes that don't hold an in-toto statement are skipped, and
+// subjects repeated within or across statements are indexed once.
+func NewIndex(envelopes []dsse.Envelope) *Index {
+	index := &Index{
+		bySubject:   make(map[digestKey][]int),
+		byPredicate: make(map[string][]int),
+	}
+
+	subjectsByDigest := make(map[string]int)
+	for _, env := range envelopes {
+		statement := intoto.Statement{}
+		if err := json.Unmarshal(env.Payload, &statement); err != nil {
+			continue
+		}
+
+		statementIndex := len(index.statements)
+		index.statements = append(index.statements, IndexedStatement{Envelope: env, Statement: statement})
+		index.byPredicate[statement.PredicateType] = append(index.byPredicate[statement.PredicateType], statementIndex)
+		for _, subject := range statement.Subject {
+			ds, err := cryptoutil.NewDigestSet(subject.Digest)
+			if err != nil || len(ds) == 0 {
+				continue
+			}
+
+			canonical := canonicalDigest(subject.Digest)
+			subjectIndex, ok := subjectsByDigest[canonical]
+			if !ok {
+				subjectIndex = len(index.subjects)
+				subjectsByDigest[canonical] = subjectIndex
+				index.subjects = append(index.subjects, indexedSubject{digest: ds})
+				for hash, digest := range ds {
+					key := digestKey{hash: hash, digest: digest}
+					index.bySubject[key] = append(index.bySubject[key], subjectIndex)
+				}
+			}
+
+			subject := &index.subjects[subjectIndex]
+			if n := len(subject.statements); n == 0 || subject.statements[n-1] != statementIndex {
+				subject.statements = append(subject.statements, statementIndex)
+			}
+		}
+	}
+
+	return index
+}
+
+func canonicalDigest(digests map[string]string) string {
+	parts := make([]string, 0, len(digests))
+	for name, digest := range digests {
+		parts = append(parts, name+":"+digest)
+	}
+
+	sort.Strings(parts)
+	return strings.Join(parts, ",")
+}
+
+// MatchesSubject returns true if any statement has a subject equal to the digest set.
+func (i *Index) MatchesSubject(ds cryptoutil.DigestSet) bool {
+	matched := false
+	i.eachSubject(ds, func(indexedSubject) bool {
+		matched = true
+		return false
+	})
+
+	return matched
+}
+
+// BySubject returns the statements with a subject equal to the digest set.
+func (i *Index) BySubject(ds cryptoutil.DigestSet) []IndexedStatement {
+	seen := make(map[int]bool)
+	statements := make([]IndexedStatement, 0)
+	i.eachSubject(ds, func(s indexedSubject) bool {
+		for _, statementIndex := range s.statements {
+			if !seen[statementIndex] {
+				seen[statementIndex] = true
+				statements = append(statements, i.statements[statementIndex])
+			}
+		}
+
+		return true
+	})
+
+	return statements
+}
+
+// eachSubject calls fn with each distinct subject equal to the digest set until fn returns false.
+func (i *Index) eachSubject(ds cryptoutil.DigestSet, fn func(indexedSubject) bool) {
+	seen := make(map[int]bool)
+	for hash, digest := range ds {
+		for _, subjectIndex := range i.bySubject[digestKey{hash: hash, digest: digest}] {
+			if seen[subjectIndex] {
+				continue
+			}
+
+			seen[subjectIndex] = true
+			if i.subjects[subjectIndex].digest.Equal(ds) && !fn(i.subjects[subjectIndex]) {
+				return
+			}
+		}
+	}
+}
+
+// ByPredicateType returns the statements with the predicate type, in the order their envelopes were indexed.
+func (i *Index) ByPredicateType(predicateType string) []IndexedStatement {
+	statements := make([]IndexedStatement, 0, len(i.byPredicate[predicateType]))
+	for _, statementIndex := range i.byPredicate[predicateType] {
+		statements = append(statements, i.statements[statementIndex])
+	}
+
+	return statements
+}
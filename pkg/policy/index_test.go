@@ -0,0 +1,98 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import (
+	"crypto"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/dsse"
+	"github.com/testifysec/go-witness/intoto"
+)
+
+func subjectEnvelope(t testing.TB, predicateType string, subjects ...intoto.Subject) dsse.Envelope {
+	statement, err := json.Marshal(intoto.Statement{
+		Type:          intoto.StatementType,
+		PredicateType: predicateType,
+		Subject:       subjects,
+		Predicate:     json.RawMessage(`{}`),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return dsse.Envelope{Payload: statement, PayloadType: intoto.PayloadType}
+}
+
+func fileSubjects(n int) []intoto.Subject {
+	subjects := make([]intoto.Subject, 0, n)
+	for i := 0; i < n; i++ {
+		subjects = append(subjects, intoto.Subject{
+			Name:   fmt.Sprintf("file:dir/%d", i),
+			Digest: map[string]string{"sha256": fmt.Sprintf("%064x", i)},
+		})
+	}
+
+	return subjects
+}
+
+func TestIndex(t *testing.T) {
+	subjects := fileSubjects(20000)
+	// the same file under another name, and in a second statement, is indexed once
+	duplicate := intoto.Subject{Name: "file:copy", Digest: subjects[7].Digest}
+	index := NewIndex([]dsse.Envelope{
+		subjectEnvelope(t, CollectionType, append(subjects, duplicate)...),
+		subjectEnvelope(t, "https://slsa.dev/provenance/v0.2", subjects[7], intoto.Subject{
+			Name:   "file:both",
+			Digest: map[string]string{"sha256": fmt.Sprintf("%064x", 30000), "sha1": "abc"},
+		}),
+		{Payload: []byte("not a statement")},
+	})
+
+	for _, i := range []int{0, 7, 19999} {
+		if !index.MatchesSubject(cryptoutil.DigestSet{crypto.SHA256: fmt.Sprintf("%064x", i)}) {
+			t.Errorf("expected subject %d to match", i)
+		}
+	}
+
+	if index.MatchesSubject(cryptoutil.DigestSet{crypto.SHA256: fmt.Sprintf("%064x", 20000)}) {
+		t.Error("expected unknown digest not to match")
+	}
+
+	if matches := index.BySubject(cryptoutil.DigestSet{crypto.SHA256: fmt.Sprintf("%064x", 7)}); len(matches) != 2 {
+		t.Errorf("expected duplicated subject to be found in both statements once, got %d", len(matches))
+	}
+
+	both := cryptoutil.DigestSet{crypto.SHA256: fmt.Sprintf("%064x", 30000), crypto.SHA1: "def"}
+	if index.MatchesSubject(both) {
+		t.Error("expected subject with a differing digest for a common hash not to match")
+	}
+
+	if len(index.ByPredicateType(CollectionType)) != 1 || len(index.ByPredicateType("https://slsa.dev/provenance/v0.2")) != 1 {
+		t.Error("unexpected statements by predicate type")
+	}
+}
+
+func BenchmarkIndexMatchesSubject(b *testing.B) {
+	subjects := fileSubjects(20000)
+	index := NewIndex([]dsse.Envelope{subjectEnvelope(b, CollectionType, subjects...)})
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		index.MatchesSubject(cryptoutil.DigestSet{crypto.SHA256: subjects[i%len(subjects)].Digest["sha256"]})
+	}
+}
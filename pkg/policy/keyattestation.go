@@ -0,0 +1,128 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/dsse"
+)
+
+const KeyAttestationType = "https://witness.dev/attestations/key-attestation/v0.1"
+
+// KeyAttestationConstraint requires a step's collection to be signed with a key that the HSM or key management
+// service holding it attested to, showing the private key can not be exported. Roots names the policy roots of the
+// vendors' attestation CAs. Roots used for other purposes, such as Fulcio's, must not be listed since they also
+// certify the signing key.
+type KeyAttestationConstraint struct {
+	Roots []string `json:"roots"`
+}
+
+type keyAttestation struct {
+	Certificates []string `json:"certificates"`
+}
+
+// Verify checks that the collection's key attestation chains to one of the constraint's roots and that the envelope
+// was signed with the attested key.
+func (k KeyAttestationConstraint) Verify(policyRoots map[string]Root, collection Collection, env dsse.Envelope) error {
+	raw, ok := collection.Attestation(KeyAttestationType)
+	if !ok {
+		return fmt.Errorf("collection has no key-attestation attestation")
+	}
+
+	attestation := keyAttestation{}
+	if err := json.Unmarshal(raw, &attestation); err != nil {
+		return fmt.Errorf("failed to unmarshal key-attestation attestation: %w", err)
+	}
+
+	certs := make([]*x509.Certificate, 0, len(attestation.Certificates))
+	for _, certPEM := range attestation.Certificates {
+		block, _ := pem.Decode([]byte(certPEM))
+		if block == nil {
+			return fmt.Errorf("key attestation certificate is not PEM encoded")
+		}
+
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return fmt.Errorf("failed to parse key attestation certificate: %w", err)
+		}
+
+		certs = append(certs, cert)
+	}
+
+	if len(certs) == 0 {
+		return fmt.Errorf("key attestation does not contain any certificates")
+	}
+
+	opts := x509.VerifyOptions{
+		Roots:         x509.NewCertPool(),
+		Intermediates: x509.NewCertPool(),
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+		// attestation certificates are issued when the key is created and often outlive their validity period
+		CurrentTime: certs[0].NotBefore,
+	}
+
+	for _, cert := range certs[1:] {
+		opts.Intermediates.AddCert(cert)
+	}
+
+	for _, name := range k.Roots {
+		root, ok := policyRoots[name]
+		if !ok {
+			return fmt.Errorf("key attestation root %v is not in the policy", name)
+		}
+
+		cert, err := dsse.TryParseCertificate(root.Certificate)
+		if err != nil {
+			return fmt.Errorf("failed to parse key attestation root %v: %w", name, err)
+		}
+
+		opts.Roots.AddCert(cert)
+		for _, intermediate := range root.Intermediates {
+			if cert, err := dsse.TryParseCertificate(intermediate); err == nil {
+				opts.Intermediates.AddCert(cert)
+			}
+		}
+	}
+
+	if _, err := certs[0].Verify(opts); err != nil {
+		return fmt.Errorf("key attestation does not chain to a trusted root: %w", err)
+	}
+
+	verifier, err := cryptoutil.NewVerifier(certs[0].PublicKey)
+	if err != nil {
+		return fmt.Errorf("failed to create verifier for attested key: %w", err)
+	}
+
+	// the signature's own certificate is verified against the functionary roots by go-witness, so only the attested
+	// key is checked here
+	for _, sig := range env.Signatures {
+		signed := dsse.Envelope{
+			Payload:     env.Payload,
+			PayloadType: env.PayloadType,
+			Signatures:  []dsse.Signature{{KeyID: sig.KeyID, Signature: sig.Signature}},
+		}
+
+		if _, err := signed.Verify(dsse.WithVerifiers([]cryptoutil.Verifier{verifier})); err == nil {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("collection was not signed with the attested key")
+}
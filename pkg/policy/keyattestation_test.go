@@ -0,0 +1,95 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/dsse"
+)
+
+// attestKey issues a key attestation certificate for the key, as an HSM vendor's attestation CA would.
+func (ca testCA) attestKey(t *testing.T, key *ecdsa.PrivateKey) string {
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		NotBefore:    time.Now().Add(-30 * time.Minute),
+		NotAfter:     time.Now().Add(-10 * time.Minute),
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+}
+
+func TestKeyAttestationConstraint(t *testing.T) {
+	hsm := newTestCA(t)
+	untrusted := newTestCA(t)
+	p := Policy{
+		Roots: map[string]Root{"hsm": {Certificate: hsm.pem()}, "other": {Certificate: untrusted.pem()}},
+		Steps: map[string]Step{"build": {Name: "build", KeyAttestation: &KeyAttestationConstraint{Roots: []string{"hsm"}}}},
+	}
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sign := func(certificate string, key *ecdsa.PrivateKey) dsse.Envelope {
+		env := testEnvelope(t, "build", map[string]interface{}{
+			KeyAttestationType: map[string]interface{}{"certificates": []string{certificate}},
+		})
+
+		signed, err := dsse.Sign(env.PayloadType, bytes.NewReader(env.Payload), cryptoutil.NewECDSASigner(key, crypto.SHA256))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return signed
+	}
+
+	if _, err := p.Verify([]dsse.Envelope{sign(hsm.attestKey(t, key), key)}); err != nil {
+		t.Errorf("expected collection signed with the attested key to pass: %v", err)
+	}
+
+	if _, err := p.Verify([]dsse.Envelope{sign(hsm.attestKey(t, otherKey), key)}); err == nil {
+		t.Error("expected collection signed with a key other than the attested key to fail")
+	}
+
+	if _, err := p.Verify([]dsse.Envelope{sign(untrusted.attestKey(t, key), key)}); err == nil {
+		t.Error("expected key attestation from a root not listed in the constraint to fail")
+	}
+
+	if _, err := p.Verify([]dsse.Envelope{testEnvelope(t, "build", nil)}); err == nil {
+		t.Error("expected collection without a key attestation to fail")
+	}
+}
@@ -0,0 +1,79 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/dsse"
+	"github.com/testifysec/witness/pkg/rekorentry"
+)
+
+// TransparencyLog is a Rekor log the policy trusts to prove collections were published, by the log's public key.
+type TransparencyLog struct {
+	Key []byte `json:"key"`
+}
+
+// LogInclusionConstraint requires a step's collection to be proven, by Rekor bundles, to be logged in at least
+// Threshold of the listed transparency logs. Logs names the policy's transparency logs, and defaults to all of them.
+// Threshold defaults to 1.
+type LogInclusionConstraint struct {
+	Logs      []string `json:"logs,omitempty"`
+	Threshold int      `json:"threshold,omitempty"`
+}
+
+// Verify checks that the bundles prove the envelope was logged in enough of the constraint's logs.
+func (c LogInclusionConstraint) Verify(logs map[string]TransparencyLog, bundles []rekorentry.Bundle, env dsse.Envelope) error {
+	names := c.Logs
+	if len(names) == 0 {
+		names = make([]string, 0, len(logs))
+		for name := range logs {
+			names = append(names, name)
+		}
+
+		sort.Strings(names)
+	}
+
+	verifiers := make(map[string]cryptoutil.Verifier, len(names))
+	for _, name := range names {
+		log, ok := logs[name]
+		if !ok {
+			return fmt.Errorf("transparency log %v is not in the policy", name)
+		}
+
+		verifier, err := cryptoutil.NewVerifierFromReader(bytes.NewReader(log.Key))
+		if err != nil {
+			return fmt.Errorf("failed to parse the key of transparency log %v: %w", name, err)
+		}
+
+		verifiers[name] = verifier
+	}
+
+	threshold := c.Threshold
+	if threshold == 0 {
+		threshold = 1
+	}
+
+	found := rekorentry.IncludedIn(bundles, env, verifiers)
+	if len(found) >= threshold {
+		return nil
+	}
+
+	return fmt.Errorf("logged in %d of the %d transparency logs required: found [%v] of [%v]", len(found), threshold, strings.Join(found, ", "), strings.Join(names, ", "))
+}
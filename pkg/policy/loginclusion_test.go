@@ -0,0 +1,71 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/testifysec/go-witness/dsse"
+	"github.com/testifysec/witness/pkg/rekorentry"
+)
+
+func TestLogInclusion(t *testing.T) {
+	_, publicLog := newTestKey(t)
+	_, privateLog := newTestKey(t)
+	logs := map[string]TransparencyLog{"public": {Key: publicLog.Key}, "private": {Key: privateLog.Key}}
+	env := testEnvelope(t, "build", nil)
+	pending := []rekorentry.Bundle{{Pending: &rekorentry.Pending{Server: "https://rekor.example.com"}}}
+
+	tests := []struct {
+		name       string
+		constraint LogInclusionConstraint
+		logs       map[string]TransparencyLog
+		wantErr    string
+	}{
+		{"all logs", LogInclusionConstraint{Threshold: 2}, logs, "logged in 0 of the 2 transparency logs required: found [] of [private, public]"},
+		{"default threshold", LogInclusionConstraint{Logs: []string{"public"}}, logs, "logged in 0 of the 1 transparency logs required: found [] of [public]"},
+		{"unknown log", LogInclusionConstraint{Logs: []string{"internal"}}, logs, "transparency log internal is not in the policy"},
+		{"invalid key", LogInclusionConstraint{}, map[string]TransparencyLog{"public": {Key: []byte("not a key")}}, "failed to parse the key of transparency log public"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.constraint.Verify(tt.logs, pending, env)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+
+	p := Policy{
+		TransparencyLogs: logs,
+		LogBundles:       pending,
+		Steps:            map[string]Step{"build": {Name: "build", LogInclusion: &LogInclusionConstraint{Threshold: 1}}},
+	}
+
+	if _, err := p.Verify([]dsse.Envelope{env}); err == nil || !strings.Contains(err.Error(), "logged in 0 of the 1") {
+		t.Errorf("expected a collection without bundles to fail the step, got %v", err)
+	}
+
+	_, checks, err := p.Explain(env)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(checks) != 1 || checks[0].Constraint != "logInclusion" || checks[0].Passed {
+		t.Errorf("expected a failed logInclusion check, got %+v", checks)
+	}
+}
@@ -0,0 +1,157 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const MatrixType = "https://witness.dev/attestations/matrix/v0.1"
+
+// MatrixConstraint requires a collection for every combination of the values of its dimensions, such as each os and
+// arch a step runs on. Each variant's collection must also satisfy the step's other constraints.
+type MatrixConstraint struct {
+	Dimensions map[string][]string `json:"dimensions"`
+}
+
+type matrixAttestation struct {
+	Coordinates map[string]string `json:"coordinates"`
+}
+
+// Variants returns every combination of the dimensions' values.
+func (m MatrixConstraint) Variants() ([]map[string]string, error) {
+	if len(m.Dimensions) == 0 {
+		return nil, fmt.Errorf("matrix constraint does not list any dimensions")
+	}
+
+	dimensions := make([]string, 0, len(m.Dimensions))
+	for dimension, values := range m.Dimensions {
+		if len(values) == 0 {
+			return nil, fmt.Errorf("matrix dimension %v does not list any values", dimension)
+		}
+
+		dimensions = append(dimensions, dimension)
+	}
+
+	sort.Strings(dimensions)
+	variants := []map[string]string{{}}
+	for _, dimension := range dimensions {
+		next := make([]map[string]string, 0, len(variants)*len(m.Dimensions[dimension]))
+		for _, variant := range variants {
+			for _, value := range m.Dimensions[dimension] {
+				extended := map[string]string{dimension: value}
+				for d, v := range variant {
+					extended[d] = v
+				}
+
+				next = append(next, extended)
+			}
+		}
+
+		variants = next
+	}
+
+	return variants, nil
+}
+
+func verifyMatrix(s Step, collections []Collection, errs []error) error {
+	variants, err := s.Matrix.Variants()
+	if err != nil {
+		return err
+	}
+
+	coordinates := make([]map[string]string, len(collections))
+	for i, collection := range collections {
+		if coordinates[i], err = collectionCoordinates(collection); err != nil {
+			return err
+		}
+	}
+
+	missing := make([]string, 0)
+	for _, variant := range variants {
+		attested := false
+		var lastErr error
+		for i := range collections {
+			if !matchesVariant(coordinates[i], variant) {
+				continue
+			}
+
+			if lastErr = errs[i]; lastErr == nil {
+				attested = true
+				break
+			}
+		}
+
+		if attested {
+			continue
+		}
+
+		if lastErr != nil {
+			return fmt.Errorf("matrix variant %v: %w", variantString(variant), lastErr)
+		}
+
+		missing = append(missing, variantString(variant))
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf("matrix variants are not attested: %v", strings.Join(missing, "; "))
+	}
+
+	return nil
+}
+
+func collectionCoordinates(collection Collection) (map[string]string, error) {
+	raw, ok := collection.Attestation(MatrixType)
+	if !ok {
+		return nil, nil
+	}
+
+	matrix := matrixAttestation{}
+	if err := json.Unmarshal(raw, &matrix); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal matrix attestation: %w", err)
+	}
+
+	return matrix.Coordinates, nil
+}
+
+// matchesVariant returns true if the coordinates have the variant's value for each of its dimensions. Coordinates for
+// dimensions the constraint doesn't list are ignored.
+func matchesVariant(coordinates, variant map[string]string) bool {
+	for dimension, value := range variant {
+		if coordinate, ok := coordinates[dimension]; !ok || coordinate != value {
+			return false
+		}
+	}
+
+	return true
+}
+
+func variantString(variant map[string]string) string {
+	dimensions := make([]string, 0, len(variant))
+	for dimension := range variant {
+		dimensions = append(dimensions, dimension)
+	}
+
+	sort.Strings(dimensions)
+	pairs := make([]string, 0, len(dimensions))
+	for _, dimension := range dimensions {
+		pairs = append(pairs, fmt.Sprintf("%v=%v", dimension, variant[dimension]))
+	}
+
+	return strings.Join(pairs, ",")
+}
@@ -0,0 +1,85 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/testifysec/go-witness/dsse"
+)
+
+func matrixEnvelope(t *testing.T, step string, coordinates map[string]string, cmd ...string) dsse.Envelope {
+	attestations := map[string]interface{}{MatrixType: map[string]interface{}{"coordinates": coordinates}}
+	if len(cmd) > 0 {
+		attestations[CommandRunType] = map[string]interface{}{"cmd": cmd, "exitcode": 0}
+	}
+
+	return testEnvelope(t, step, attestations)
+}
+
+func TestMatrixVariants(t *testing.T) {
+	variants, err := MatrixConstraint{Dimensions: map[string][]string{"os": {"linux", "darwin"}, "arch": {"amd64", "arm64"}}}.Variants()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	seen := map[string]bool{}
+	for _, variant := range variants {
+		seen[variantString(variant)] = true
+	}
+
+	if len(variants) != 4 || len(seen) != 4 || !seen["arch=arm64,os=darwin"] {
+		t.Errorf("unexpected variants: %v", variants)
+	}
+
+	if _, err := (MatrixConstraint{}).Variants(); err == nil {
+		t.Error("expected matrix without dimensions to be rejected")
+	}
+
+	if _, err := (MatrixConstraint{Dimensions: map[string][]string{"os": {}}}).Variants(); err == nil {
+		t.Error("expected dimension without values to be rejected")
+	}
+}
+
+func TestMatrixConstraint(t *testing.T) {
+	p := Policy{Steps: map[string]Step{"test": {
+		Name:   "test",
+		Matrix: &MatrixConstraint{Dimensions: map[string][]string{"os": {"linux", "windows"}, "arch": {"amd64"}}},
+	}}}
+
+	linux := matrixEnvelope(t, "test", map[string]string{"os": "linux", "arch": "amd64", "go": "1.17"})
+	windows := matrixEnvelope(t, "test", map[string]string{"os": "windows", "arch": "amd64"})
+	if _, err := p.Verify([]dsse.Envelope{linux, windows}); err != nil {
+		t.Errorf("expected all variants to pass: %v", err)
+	}
+
+	_, err := p.Verify([]dsse.Envelope{linux, testEnvelope(t, "test", nil)})
+	if err == nil || !strings.Contains(err.Error(), "arch=amd64,os=windows") {
+		t.Errorf("expected missing windows variant to fail, got %v", err)
+	}
+
+	p.Steps["test"] = Step{Name: "test", Matrix: p.Steps["test"].Matrix, Command: &CommandConstraint{Exact: []string{"go", "test"}}}
+	linux = matrixEnvelope(t, "test", map[string]string{"os": "linux", "arch": "amd64"}, "go", "test")
+	windows = matrixEnvelope(t, "test", map[string]string{"os": "windows", "arch": "amd64"}, "make")
+	if _, err := p.Verify([]dsse.Envelope{linux, windows}); err == nil {
+		t.Error("expected variant failing the command constraint to fail")
+	}
+
+	windowsRetry := matrixEnvelope(t, "test", map[string]string{"os": "windows", "arch": "amd64"}, "go", "test")
+	if _, err := p.Verify([]dsse.Envelope{linux, windows, windowsRetry}); err != nil {
+		t.Errorf("expected a passing collection for each variant to pass: %v", err)
+	}
+}
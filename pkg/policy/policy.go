@@ -0,0 +1,393 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package policy enforces the witness policy fields that are evaluated by witness itself, after go-witness has
+// verified the policy's signatures, functionaries, and rego policies.
+package policy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/testifysec/go-witness/dsse"
+	"github.com/testifysec/witness/pkg/groups"
+	"github.com/testifysec/witness/pkg/rekorentry"
+	"github.com/testifysec/witness/pkg/subjects"
+)
+
+const CollectionType = "https://witness.testifysec.com/attestation-collection/v0.1"
+
+// Policy holds the witness specific fields of a policy document.
+type Policy struct {
+	// Version orders policies that replace each other, so long running services don't load an older policy in place
+	// of a newer one.
+	Version    int                  `json:"version,omitempty"`
+	Roots      map[string]Root      `json:"roots"`
+	PublicKeys map[string]PublicKey `json:"publickeys"`
+	Steps      map[string]Step      `json:"steps"`
+
+	// TimestampAuthorities are the roots of the RFC 3161 timestamp authorities trusted to timestamp signatures.
+	// Certificates of signatures with a timestamp from one of them are verified at the timestamped time.
+	TimestampAuthorities map[string]Root `json:"timestampauthorities,omitempty"`
+
+	// TransparencyLogs are the Rekor logs, by name, that steps' log inclusion constraints can require collections to
+	// be logged in.
+	TransparencyLogs map[string]TransparencyLog `json:"transparencylogs,omitempty"`
+
+	// Groups resolves the groups of the steps' approval constraints.
+	Groups groups.Resolver `json:"-"`
+
+	// LogBundles are the Rekor bundles that prove collections were logged, for the steps' log inclusion constraints.
+	LogBundles []rekorentry.Bundle `json:"-"`
+}
+
+type Step struct {
+	Name             string                      `json:"name"`
+	Functionaries    []Functionary               `json:"functionaries,omitempty"`
+	Threshold        int                         `json:"threshold,omitempty"`
+	Command          *CommandConstraint          `json:"command,omitempty"`
+	Forbidden        []ForbiddenAttestation      `json:"forbidden,omitempty"`
+	BuildCounter     *CounterConstraint          `json:"buildCounter,omitempty"`
+	Approvals        []ApprovalConstraint        `json:"approvals,omitempty"`
+	KeyAttestation   *KeyAttestationConstraint   `json:"keyAttestation,omitempty"`
+	SBOM             *SBOMConstraint             `json:"sbom,omitempty"`
+	SBOMCompleteness *SBOMCompletenessConstraint `json:"sbomCompleteness,omitempty"`
+	SPIFFE           *SPIFFEConstraint           `json:"spiffe,omitempty"`
+	Delegation       *DelegationConstraint       `json:"delegation,omitempty"`
+	Matrix           *MatrixConstraint           `json:"matrix,omitempty"`
+	BaseImage        *BaseImageConstraint        `json:"baseImage,omitempty"`
+	Subjects         *SubjectConstraint          `json:"subjects,omitempty"`
+	Worktree         *WorktreeConstraint         `json:"worktree,omitempty"`
+	Versions         *VersionConstraint          `json:"versions,omitempty"`
+	LogInclusion     *LogInclusionConstraint     `json:"logInclusion,omitempty"`
+	Rego             []RegoConstraint            `json:"rego,omitempty"`
+}
+
+// Collection is an attestation collection with its attestations left as json so they can be inspected without
+// registering their attestors. Subjects records the attestors that contributed each subject, if the collection was
+// recorded with them.
+type Collection struct {
+	Name         string                  `json:"name"`
+	Attestations []CollectionAttestation `json:"attestations"`
+	Subjects     []subjects.Subject      `json:"subjects,omitempty"`
+}
+
+type CollectionAttestation struct {
+	Type        string          `json:"type"`
+	Attestation json.RawMessage `json:"attestation"`
+}
+
+type ErrConstraintFailed struct {
+	Step   string
+	Reason string
+}
+
+func (e ErrConstraintFailed) Error() string {
+	return fmt.Sprintf("step %v failed policy constraint: %v", e.Step, e.Reason)
+}
+
+// Parse reads the witness specific fields from a policy's json.
+func Parse(payload []byte) (Policy, error) {
+	p := Policy{}
+	if err := json.Unmarshal(payload, &p); err != nil {
+		return p, fmt.Errorf("failed to unmarshal policy: %w", err)
+	}
+
+	return p, nil
+}
+
+// CollectionFromEnvelope returns the attestation collection signed in the envelope.
+func CollectionFromEnvelope(env dsse.Envelope) (Collection, error) {
+	statement := predicateStatement{}
+	if err := json.Unmarshal(env.Payload, &statement); err != nil {
+		return Collection{}, fmt.Errorf("failed to unmarshal statement: %w", err)
+	}
+
+	return collectionFromPredicate(statement.PredicateType, statement.Predicate)
+}
+
+// predicateStatement is the part of an in-toto statement a collection is read from. The statement's subjects, which
+// can number in the thousands, are skipped rather than decoded for every envelope that is verified.
+type predicateStatement struct {
+	PredicateType string          `json:"predicateType"`
+	Predicate     json.RawMessage `json:"predicate"`
+}
+
+func collectionFromPredicate(predicateType string, predicate json.RawMessage) (Collection, error) {
+	if predicateType != CollectionType {
+		return Collection{}, fmt.Errorf("predicate type is not a collection: %v", predicateType)
+	}
+
+	collection := Collection{}
+	if err := json.Unmarshal(predicate, &collection); err != nil {
+		return Collection{}, fmt.Errorf("failed to unmarshal collection: %w", err)
+	}
+
+	return collection, nil
+}
+
+// Attestation returns the json of the first attestation in the collection with the type, if there is one.
+func (c Collection) Attestation(attestationType string) (json.RawMessage, bool) {
+	for _, attestation := range c.Attestations {
+		if attestation.Type == attestationType {
+			return attestation.Attestation, true
+		}
+	}
+
+	return nil, false
+}
+
+// Verify checks the verified envelopes against the policy's witness specific constraints. Only collections signed by
+// one of a step's functionaries, including the identity constraints of their certConstraints, are considered for it.
+// A step passes if any such collection satisfies all of its constraints and none contains a forbidden attestation.
+// Approval constraints are satisfied by the signers of all of the step's collections together, and thresholds by the
+// signers of the step's collections attesting to the same build. Steps with a matrix constraint instead need a
+// collection satisfying their constraints for every variant of the matrix.
+//
+// The result records every step's outcome and the collections considered for it. The error is the failure of the
+// first step, by name, that failed.
+func (p Policy) Verify(envelopes []dsse.Envelope) (Result, error) {
+	collectionsByStep := make(map[string][]Collection)
+	envelopesByStep := make(map[string][]dsse.Envelope)
+	resultsByStep := make(map[string][]CollectionResult)
+	for i, env := range envelopes {
+		collection, err := CollectionFromEnvelope(env)
+		if err != nil {
+			continue
+		}
+
+		collectionsByStep[collection.Name] = append(collectionsByStep[collection.Name], collection)
+		envelopesByStep[collection.Name] = append(envelopesByStep[collection.Name], env)
+		resultsByStep[collection.Name] = append(resultsByStep[collection.Name], CollectionResult{Envelope: i, Signers: p.signerNames(env)})
+	}
+
+	names := make([]string, 0, len(p.Steps))
+	for name := range p.Steps {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	result := Result{Steps: make([]StepResult, 0, len(names))}
+	var failed error
+	for _, name := range names {
+		stepResult := StepResult{Name: name, Passed: true, Collections: resultsByStep[name]}
+		if stepResult.Collections == nil {
+			stepResult.Collections = []CollectionResult{}
+		}
+
+		step := p.Steps[name]
+		kept := p.attributeToStep(step, envelopesByStep[name], stepResult.Collections)
+		collections := make([]Collection, 0, len(kept))
+		stepEnvelopes := make([]dsse.Envelope, 0, len(kept))
+		collectionResults := make([]CollectionResult, 0, len(kept))
+		for _, i := range kept {
+			collections = append(collections, collectionsByStep[name][i])
+			stepEnvelopes = append(stepEnvelopes, envelopesByStep[name][i])
+			collectionResults = append(collectionResults, stepResult.Collections[i])
+		}
+
+		err := p.verifyStep(step, collections, stepEnvelopes, collectionResults)
+		for j, i := range kept {
+			stepResult.Collections[i] = collectionResults[j]
+		}
+
+		if err != nil {
+			stepResult.Passed = false
+			stepResult.Reason = err.Error()
+			if failed == nil {
+				failed = ErrConstraintFailed{Step: name, Reason: err.Error()}
+			}
+		}
+
+		result.Steps = append(result.Steps, stepResult)
+	}
+
+	return result, failed
+}
+
+// attributeToStep returns the indexes of the envelopes signed by a functionary of the step. go-witness accepts an
+// envelope for every step if it is signed by any of the policy's keys or roots, so without this a functionary of one
+// step could sign a collection named after another and satisfy its constraints. The other envelopes' results are
+// marked as failed. Steps without functionaries, which go-witness never passes, keep all their envelopes.
+func (p Policy) attributeToStep(step Step, envelopes []dsse.Envelope, results []CollectionResult) []int {
+	kept := make([]int, 0, len(envelopes))
+	for i, env := range envelopes {
+		if len(step.Functionaries) > 0 {
+			if err := p.verifyFunctionaries(step, env); err != nil {
+				results[i].Reason = err.Error()
+				continue
+			}
+		}
+
+		kept = append(kept, i)
+	}
+
+	return kept
+}
+
+// verifyStep checks the step's collections against its constraints, recording each collection's outcome in results.
+func (p Policy) verifyStep(step Step, collections []Collection, envelopes []dsse.Envelope, results []CollectionResult) error {
+	var forbiddenErr error
+	errs := make([]error, len(collections))
+	for i, collection := range collections {
+		for _, forbidden := range step.Forbidden {
+			if err := forbidden.Check(collection); err != nil {
+				errs[i] = err
+				break
+			}
+		}
+
+		if errs[i] != nil {
+			if forbiddenErr == nil {
+				forbiddenErr = errs[i]
+			}
+		} else if step.hasCollectionConstraints() {
+			errs[i] = p.verifyCollection(step, collection, envelopes[i])
+		}
+
+		results[i].Passed = errs[i] == nil
+		if errs[i] != nil {
+			results[i].Reason = errs[i].Error()
+		}
+	}
+
+	if forbiddenErr != nil {
+		return forbiddenErr
+	}
+
+	if len(step.Approvals) > 0 {
+		approvers := make([]Approver, 0)
+		for _, env := range envelopes {
+			approvers = append(approvers, p.signerApprovers(env)...)
+		}
+
+		for _, approval := range step.Approvals {
+			if err := approval.Verify(context.Background(), p.Groups, approvers); err != nil {
+				return err
+			}
+		}
+	}
+
+	if step.Threshold > 0 {
+		if err := p.verifyThreshold(step, envelopes, errs); err != nil {
+			return err
+		}
+	}
+
+	if step.Matrix != nil {
+		return verifyMatrix(step, collections, errs)
+	}
+
+	if !step.hasCollectionConstraints() {
+		return nil
+	}
+
+	var lastErr error
+	for _, err := range errs {
+		if err == nil {
+			return nil
+		}
+
+		lastErr = err
+	}
+
+	if lastErr == nil {
+		lastErr = fmt.Errorf("no verified collections found")
+	}
+
+	return lastErr
+}
+
+// hasCollectionConstraints returns true if the step has constraints that a single collection must satisfy.
+func (s Step) hasCollectionConstraints() bool {
+	return s.Command != nil || s.BuildCounter != nil || s.KeyAttestation != nil || s.SBOM != nil || s.SBOMCompleteness != nil || s.SPIFFE != nil || s.Subjects != nil || s.Worktree != nil || s.Versions != nil || s.LogInclusion != nil || len(s.Rego) > 0 || s.hasIdentityConstraints()
+}
+
+func (p Policy) verifyCollection(s Step, collection Collection, env dsse.Envelope) error {
+	if s.hasIdentityConstraints() {
+		if err := p.verifyFunctionaries(s, env); err != nil {
+			return err
+		}
+	}
+
+	if s.Command != nil {
+		if err := s.Command.Verify(collection); err != nil {
+			return err
+		}
+	}
+
+	if s.BuildCounter != nil {
+		if err := s.BuildCounter.Verify(collection); err != nil {
+			return err
+		}
+	}
+
+	if s.KeyAttestation != nil {
+		if err := s.KeyAttestation.Verify(p.Roots, collection, env); err != nil {
+			return err
+		}
+	}
+
+	if s.SBOM != nil {
+		if err := s.SBOM.Verify(collection); err != nil {
+			return err
+		}
+	}
+
+	if s.SBOMCompleteness != nil {
+		if err := s.SBOMCompleteness.Verify(collection); err != nil {
+			return err
+		}
+	}
+
+	if s.SPIFFE != nil {
+		if err := s.SPIFFE.Verify(p.signerSPIFFEIDs(env)); err != nil {
+			return err
+		}
+	}
+
+	if s.Subjects != nil {
+		if err := s.Subjects.Verify(collection, env); err != nil {
+			return err
+		}
+	}
+
+	if s.Worktree != nil {
+		if err := s.Worktree.Verify(collection); err != nil {
+			return err
+		}
+	}
+
+	if s.Versions != nil {
+		if err := s.Versions.Verify(collection); err != nil {
+			return err
+		}
+	}
+
+	if s.LogInclusion != nil {
+		if err := s.LogInclusion.Verify(p.TransparencyLogs, p.LogBundles, env); err != nil {
+			return err
+		}
+	}
+
+	for _, r := range s.Rego {
+		if err := r.Verify(collection); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
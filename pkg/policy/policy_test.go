@@ -0,0 +1,254 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/testifysec/go-witness/dsse"
+	"github.com/testifysec/go-witness/intoto"
+	gwpolicy "github.com/testifysec/go-witness/policy"
+)
+
+// testEnvelope returns an unsigned envelope holding a collection with the attestations, keyed by type.
+func testEnvelope(t testing.TB, name string, attestations map[string]interface{}) dsse.Envelope {
+	collection := map[string]interface{}{"name": name}
+	collectionAttestations := make([]map[string]interface{}, 0)
+	for attestationType, attestation := range attestations {
+		collectionAttestations = append(collectionAttestations, map[string]interface{}{
+			"type":        attestationType,
+			"attestation": attestation,
+		})
+	}
+
+	collection["attestations"] = collectionAttestations
+	predicate, err := json.Marshal(collection)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	statement, err := json.Marshal(intoto.Statement{
+		Type:          intoto.StatementType,
+		PredicateType: CollectionType,
+		Subject:       []intoto.Subject{},
+		Predicate:     predicate,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return dsse.Envelope{Payload: statement, PayloadType: intoto.PayloadType}
+}
+
+func commandEnvelope(t testing.TB, step string, cmd ...string) dsse.Envelope {
+	return testEnvelope(t, step, map[string]interface{}{
+		CommandRunType: map[string]interface{}{"cmd": cmd, "exitcode": 0},
+	})
+}
+
+func TestParse(t *testing.T) {
+	p, err := Parse([]byte(`{"expires": "2030-01-01T00:00:00Z", "steps": {"build": {"name": "build", "command": {"exact": ["make", "release"]}}}}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if p.Steps["build"].Command == nil || len(p.Steps["build"].Command.Exact) != 2 {
+		t.Errorf("expected command constraint to be parsed: %+v", p.Steps["build"])
+	}
+}
+
+func TestCommandConstraint(t *testing.T) {
+	tests := []struct {
+		name       string
+		constraint CommandConstraint
+		cmd        []string
+		pass       bool
+	}{
+		{"exact match", CommandConstraint{Exact: []string{"make", "release"}}, []string{"make", "release"}, true},
+		{"exact mismatch", CommandConstraint{Exact: []string{"make", "release"}}, []string{"make", "test"}, false},
+		{"exact extra argument", CommandConstraint{Exact: []string{"make"}}, []string{"make", "release"}, false},
+		{"glob match", CommandConstraint{Glob: []string{"make", "release-*"}}, []string{"make", "release-linux"}, true},
+		{"glob mismatch", CommandConstraint{Glob: []string{"make", "release-*"}}, []string{"make", "test"}, false},
+		{"exact and glob", CommandConstraint{Exact: []string{"go", "build"}, Glob: []string{"go", "*"}}, []string{"go", "build"}, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := Policy{Steps: map[string]Step{"build": {Name: "build", Command: &test.constraint}}}
+			_, err := p.Verify([]dsse.Envelope{commandEnvelope(t, "build", test.cmd...)})
+			if test.pass && err != nil {
+				t.Errorf("expected constraint to pass: %v", err)
+			} else if !test.pass && err == nil {
+				t.Error("expected constraint to fail")
+			}
+		})
+	}
+}
+
+func TestCommandConstraintAnyCollection(t *testing.T) {
+	p := Policy{Steps: map[string]Step{"build": {Name: "build", Command: &CommandConstraint{Exact: []string{"make", "release"}}}}}
+	envelopes := []dsse.Envelope{
+		commandEnvelope(t, "build", "make", "test"),
+		commandEnvelope(t, "build", "make", "release"),
+		commandEnvelope(t, "test", "make", "release"),
+	}
+
+	if _, err := p.Verify(envelopes); err != nil {
+		t.Errorf("expected one matching collection to satisfy the step: %v", err)
+	}
+
+	if _, err := p.Verify(envelopes[2:]); err == nil {
+		t.Error("expected step without collections to fail")
+	}
+
+	if _, err := p.Verify([]dsse.Envelope{testEnvelope(t, "build", nil)}); err == nil {
+		t.Error("expected collection without a command-run attestation to fail")
+	}
+}
+
+func TestVerifyResult(t *testing.T) {
+	p := Policy{Steps: map[string]Step{
+		"build": {Name: "build", Command: &CommandConstraint{Exact: []string{"make", "release"}}},
+		"test":  {Name: "test", Command: &CommandConstraint{Exact: []string{"make", "test"}}},
+	}}
+
+	envelopes := []dsse.Envelope{
+		commandEnvelope(t, "test", "make", "lint"),
+		commandEnvelope(t, "build", "make", "test"),
+		commandEnvelope(t, "build", "make", "release"),
+	}
+
+	result, err := p.Verify(envelopes)
+	if err == nil || result.Passed() {
+		t.Fatal("expected the test step to fail")
+	}
+
+	if len(result.Steps) != 2 || result.Steps[0].Name != "build" || result.Steps[1].Name != "test" {
+		t.Fatalf("expected a result for each step ordered by name, got %+v", result.Steps)
+	}
+
+	build := result.Steps[0]
+	if !build.Passed || len(build.Collections) != 2 {
+		t.Fatalf("expected build to pass with two collections, got %+v", build)
+	}
+
+	if build.Collections[0].Envelope != 1 || build.Collections[0].Passed || build.Collections[0].Reason == "" {
+		t.Errorf("expected the first build collection to fail with a reason, got %+v", build.Collections[0])
+	}
+
+	if build.Collections[1].Envelope != 2 || !build.Collections[1].Passed {
+		t.Errorf("expected the second build collection to pass, got %+v", build.Collections[1])
+	}
+
+	test := result.Steps[1]
+	if test.Passed || test.Reason == "" || len(test.Collections) != 1 || test.Collections[0].Envelope != 0 {
+		t.Errorf("expected test to fail with a reason, got %+v", test)
+	}
+}
+
+// buildEnvelope returns an unsigned envelope holding the build step's collection with a command attestation and n
+// file subjects, the shape of a typical build's attestation.
+func buildEnvelope(t testing.TB, n int) dsse.Envelope {
+	env := commandEnvelope(t, "build", "make", "release")
+	statement := intoto.Statement{}
+	if err := json.Unmarshal(env.Payload, &statement); err != nil {
+		t.Fatal(err)
+	}
+
+	statement.Subject = fileSubjects(n)
+	payload, err := json.Marshal(statement)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	env.Payload = payload
+	return env
+}
+
+func BenchmarkCollectionFromEnvelope(b *testing.B) {
+	env := buildEnvelope(b, 1000)
+	b.ReportAllocs()
+	b.SetBytes(int64(len(env.Payload)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := CollectionFromEnvelope(env); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkVerify(b *testing.B) {
+	ca := newTestCA(b)
+	module := []byte("package cmd\n\ndeny[msg] {\n  input.exitcode != 0\n  msg := \"build failed\"\n}\n")
+	p := Policy{
+		Roots: map[string]Root{"root": {Certificate: ca.pem()}},
+		Steps: map[string]Step{"build": {
+			Name: "build",
+			Functionaries: []Functionary{{Type: "root", CertConstraint: CertConstraint{
+				CertConstraint: gwpolicy.CertConstraint{Emails: []string{"*"}, Roots: []string{"root"}},
+				Issuers:        []string{"test root"},
+			}}},
+			Command: &CommandConstraint{Exact: []string{"make", "release"}},
+			Rego:    []RegoConstraint{{Attestation: CommandRunType, Name: "exit code", Module: module}},
+		}},
+	}
+
+	envelopes := []dsse.Envelope{ca.sign(b, buildEnvelope(b, 1000), "alice@example.com")}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := p.Verify(envelopes); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestVerifyOtherStepFunctionary(t *testing.T) {
+	builder, builderKey := newTestKey(t)
+	tester, testerKey := newTestKey(t)
+	p := Policy{
+		PublicKeys: map[string]PublicKey{builderKey.KeyID: builderKey, testerKey.KeyID: testerKey},
+		Steps: map[string]Step{
+			"build": {
+				Name:          "build",
+				Functionaries: []Functionary{{Type: "publickey", PublicKeyID: builderKey.KeyID}},
+				Command:       &CommandConstraint{Exact: []string{"make", "release"}},
+			},
+			"test": {
+				Name:          "test",
+				Functionaries: []Functionary{{Type: "publickey", PublicKeyID: testerKey.KeyID}},
+			},
+		},
+	}
+
+	release := commandEnvelope(t, "build", "make", "release")
+	tested := signTestEnvelope(t, testEnvelope(t, "test", nil), tester)
+	result, err := p.Verify([]dsse.Envelope{signTestEnvelope(t, release, tester), tested})
+	if err == nil {
+		t.Fatal("expected a build collection signed by the test step's functionary to be rejected")
+	}
+
+	build := result.Steps[0]
+	if build.Passed || len(build.Collections) != 1 || build.Collections[0].Passed ||
+		!strings.Contains(build.Collections[0].Reason, "not signed by a functionary of the step") {
+		t.Fatalf("expected the collection to fail functionary attribution, got %+v", build)
+	}
+
+	if _, err := p.Verify([]dsse.Envelope{signTestEnvelope(t, release, builder), tested}); err != nil {
+		t.Fatalf("expected a build collection signed by the build step's functionary to pass: %v", err)
+	}
+}
@@ -0,0 +1,121 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/open-policy-agent/opa/ast"
+	"github.com/open-policy-agent/opa/rego"
+)
+
+// RegoConstraint evaluates an embedded Rego module against the attestation of type Attestation in each of a step's
+// collections. The attestation is the module's input, exactly as it was recorded, and every message in the module's
+// deny set rejects the collection. Unlike the regopolicies of a step's expected attestations, the attestation does
+// not need an attestor built into witness to be checked. Without an Attestation, the input is the whole collection,
+// including the attestors that contributed each of its subjects.
+type RegoConstraint struct {
+	Attestation string `json:"attestation"`
+	Name        string `json:"name"`
+	Module      []byte `json:"module"`
+}
+
+// Verify evaluates the module against the collection's attestation.
+func (c RegoConstraint) Verify(collection Collection) error {
+	if c.Attestation == "" {
+		raw, err := json.Marshal(collection)
+		if err != nil {
+			return fmt.Errorf("failed to marshal collection for rego policy %v: %w", c.Name, err)
+		}
+
+		reasons, err := EvaluateRego(c.Name, c.Module, raw)
+		if err != nil {
+			return err
+		}
+
+		if len(reasons) > 0 {
+			return fmt.Errorf("rego policy %v denied the collection: %v", c.Name, strings.Join(reasons, "; "))
+		}
+
+		return nil
+	}
+
+	raw, ok := collection.Attestation(c.Attestation)
+	if !ok {
+		return fmt.Errorf("collection has no %v attestation for rego policy %v", c.Attestation, c.Name)
+	}
+
+	reasons, err := EvaluateRego(c.Name, c.Module, raw)
+	if err != nil {
+		return err
+	}
+
+	if len(reasons) > 0 {
+		return fmt.Errorf("rego policy %v denied the %v attestation: %v", c.Name, c.Attestation, strings.Join(reasons, "; "))
+	}
+
+	return nil
+}
+
+// EvaluateRego evaluates a Rego module with the json input and returns the messages in its deny set.
+func EvaluateRego(name string, module []byte, input json.RawMessage) ([]string, error) {
+	parsed, err := ast.ParseModule(name, string(module))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse rego policy %v: %w", name, err)
+	}
+
+	if parsed == nil {
+		return nil, fmt.Errorf("rego policy %v is empty", name)
+	}
+
+	// numbers are kept exact rather than converted to floats
+	decoder := json.NewDecoder(bytes.NewReader(input))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, fmt.Errorf("failed to decode input of rego policy %v: %w", name, err)
+	}
+
+	query := fmt.Sprintf("%v.deny", parsed.Package.Path)
+	rs, err := rego.New(rego.Query(query), rego.ParsedModule(parsed), rego.Input(value)).Eval(context.Background())
+	if err != nil {
+		return nil, fmt.Errorf("failed to evaluate rego policy %v: %w", name, err)
+	}
+
+	reasons := make([]string, 0)
+	for _, result := range rs {
+		for _, expression := range result.Expressions {
+			denials, ok := expression.Value.([]interface{})
+			if !ok {
+				return nil, fmt.Errorf("rego policy %v: expected %v to be a set of strings, got %T", name, expression.Text, expression.Value)
+			}
+
+			for _, denial := range denials {
+				reason, ok := denial.(string)
+				if !ok {
+					return nil, fmt.Errorf("rego policy %v: expected %v to be a set of strings, got %T", name, expression.Text, denial)
+				}
+
+				reasons = append(reasons, reason)
+			}
+		}
+	}
+
+	return reasons, nil
+}
@@ -0,0 +1,80 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/testifysec/go-witness/dsse"
+)
+
+const cleanWorktree = `package git
+
+deny[msg] {
+  count(input.status) > 0
+  msg := "worktree is not clean"
+}
+`
+
+func gitEnvelope(t *testing.T, status map[string]interface{}) dsse.Envelope {
+	return testEnvelope(t, "build", map[string]interface{}{
+		gitType: map[string]interface{}{"commithash": "abc123", "status": status},
+	})
+}
+
+func TestRegoConstraint(t *testing.T) {
+	p := Policy{Steps: map[string]Step{"build": {Name: "build", Rego: []RegoConstraint{
+		{Attestation: gitType, Name: "clean worktree", Module: []byte(cleanWorktree)},
+	}}}}
+
+	if _, err := p.Verify([]dsse.Envelope{gitEnvelope(t, map[string]interface{}{})}); err != nil {
+		t.Errorf("expected a clean worktree to pass: %v", err)
+	}
+
+	_, err := p.Verify([]dsse.Envelope{gitEnvelope(t, map[string]interface{}{"main.go": map[string]interface{}{"worktree": "modified"}})})
+	if err == nil || !strings.Contains(err.Error(), "worktree is not clean") {
+		t.Errorf("expected a dirty worktree to fail with the deny message, got %v", err)
+	}
+
+	if _, err := p.Verify([]dsse.Envelope{testEnvelope(t, "build", nil)}); err == nil {
+		t.Error("expected a collection without a git attestation to fail")
+	}
+}
+
+func TestEvaluateRego(t *testing.T) {
+	module := []byte("package cmd\n\ndeny[msg] {\n  input.exitcode != 0\n  msg := sprintf(\"exit code was %v\", [input.exitcode])\n}\n")
+	reasons, err := EvaluateRego("exit", module, []byte(`{"exitcode": 2}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(reasons) != 1 || reasons[0] != "exit code was 2" {
+		t.Errorf("unexpected reasons: %v", reasons)
+	}
+
+	if reasons, err := EvaluateRego("exit", module, []byte(`{"exitcode": 0}`)); err != nil || len(reasons) != 0 {
+		t.Errorf("expected no reasons, got %v, %v", reasons, err)
+	}
+
+	if _, err := EvaluateRego("bad", []byte("package"), []byte(`{}`)); err == nil {
+		t.Error("expected an invalid module to fail")
+	}
+
+	notStrings := []byte("package bad\n\ndeny[n] {\n  n := 1\n}\n")
+	if _, err := EvaluateRego("bad", notStrings, []byte(`{}`)); err == nil {
+		t.Error("expected a deny set that is not strings to fail")
+	}
+}
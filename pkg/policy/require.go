@@ -0,0 +1,145 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/testifysec/go-witness/attestation"
+	"github.com/testifysec/go-witness/dsse"
+)
+
+// Requirement is an ad hoc check passed to witness verify with --require. It is satisfied if any verified collection
+// has an attestation of the type matching any of the conditions, or any attestation of the type if there are none.
+type Requirement struct {
+	Expression string
+	Type       string
+	Conditions []Condition
+}
+
+// ParseRequirement parses a requirement in the form <attestor> or <attestor>.<path>=<value>, where attestor is the
+// name of a registered attestor and path is a dot separated path within its attestation. A value that is valid json,
+// such as 0 or true, matches that json value or the same string. Any other value matches a string.
+func ParseRequirement(expression string) (Requirement, error) {
+	selector, value := expression, ""
+	hasValue := false
+	if i := strings.Index(expression, "="); i >= 0 {
+		selector, value, hasValue = expression[:i], expression[i+1:], true
+	}
+
+	name, path := selector, ""
+	if i := strings.Index(selector, "."); i >= 0 {
+		name, path = selector[:i], selector[i+1:]
+	}
+
+	if hasValue && path == "" {
+		return Requirement{}, fmt.Errorf("requirement %v must be in the form <attestor>.<path>=<value>", expression)
+	}
+
+	factory, ok := attestation.FactoryByName(name)
+	if !ok {
+		return Requirement{}, fmt.Errorf("requirement %v names unknown attestor %v", expression, name)
+	}
+
+	r := Requirement{Expression: expression, Type: factory().Type()}
+	if !hasValue {
+		if path != "" {
+			r.Conditions = []Condition{{Path: path}}
+		}
+
+		return r, nil
+	}
+
+	asString, err := json.Marshal(value)
+	if err != nil {
+		return Requirement{}, err
+	}
+
+	r.Conditions = []Condition{{Path: path, Equals: asString}}
+	if value != "" && json.Valid([]byte(value)) {
+		r.Conditions = append(r.Conditions, Condition{Path: path, Equals: json.RawMessage(value)})
+	}
+
+	return r, nil
+}
+
+// Satisfied returns true if the collection satisfies the requirement.
+func (r Requirement) Satisfied(collection Collection) (bool, error) {
+	for _, a := range collection.Attestations {
+		if a.Type != r.Type {
+			continue
+		}
+
+		if len(r.Conditions) == 0 {
+			return true, nil
+		}
+
+		var value interface{}
+		if err := json.Unmarshal(a.Attestation, &value); err != nil {
+			return false, fmt.Errorf("failed to unmarshal %v attestation: %w", r.Type, err)
+		}
+
+		for _, condition := range r.Conditions {
+			if _, matched, err := condition.match(value); err != nil {
+				return false, err
+			} else if matched {
+				return true, nil
+			}
+		}
+	}
+
+	return false, nil
+}
+
+// CheckRequirements returns an error listing the requirements not satisfied by any of the verified envelopes.
+func CheckRequirements(requirements []Requirement, envelopes []dsse.Envelope) error {
+	collections := make([]Collection, 0, len(envelopes))
+	for _, env := range envelopes {
+		collection, err := CollectionFromEnvelope(env)
+		if err != nil {
+			return err
+		}
+
+		collections = append(collections, collection)
+	}
+
+	unsatisfied := make([]string, 0)
+	for _, r := range requirements {
+		satisfied := false
+		for _, collection := range collections {
+			ok, err := r.Satisfied(collection)
+			if err != nil {
+				return err
+			}
+
+			if ok {
+				satisfied = true
+				break
+			}
+		}
+
+		if !satisfied {
+			unsatisfied = append(unsatisfied, r.Expression)
+		}
+	}
+
+	if len(unsatisfied) > 0 {
+		return fmt.Errorf("no verified attestation satisfies %v", strings.Join(unsatisfied, ", "))
+	}
+
+	return nil
+}
@@ -0,0 +1,86 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import (
+	"testing"
+
+	"github.com/testifysec/go-witness/attestation"
+	_ "github.com/testifysec/go-witness/attestation/commandrun"
+	"github.com/testifysec/go-witness/dsse"
+)
+
+const gitType = "https://witness.dev/attestations/git/v0.1"
+
+type testGitAttestor struct{}
+
+func (testGitAttestor) Name() string                                     { return "git" }
+func (testGitAttestor) Type() string                                     { return gitType }
+func (testGitAttestor) RunType() attestation.RunType                     { return attestation.PreRunType }
+func (testGitAttestor) Attest(ctx *attestation.AttestationContext) error { return nil }
+
+func init() {
+	// the git attestor's dependencies are heavy, so register a stand in with its name and type
+	attestation.RegisterAttestation("git", gitType, attestation.PreRunType, func() attestation.Attestor { return testGitAttestor{} })
+}
+
+func TestRequirements(t *testing.T) {
+	envelopes := []dsse.Envelope{
+		testEnvelope(t, "checkout", map[string]interface{}{
+			gitType: map[string]interface{}{"commithash": "1234", "status": map[string]interface{}{}},
+		}),
+		commandEnvelope(t, "build", "make"),
+	}
+
+	tests := []struct {
+		requirements []string
+		pass         bool
+	}{
+		{requirements: []string{"git"}, pass: true},
+		{requirements: []string{"command-run.exitcode=0", "git.commithash=1234"}, pass: true},
+		{requirements: []string{"command-run.cmd=make"}, pass: true},
+		{requirements: []string{"command-run.exitcode=1"}},
+		{requirements: []string{"git.commithash=5678"}},
+		{requirements: []string{"git.branch"}},
+		{requirements: []string{"command-run.exitcode=0", "git.branch=main"}},
+	}
+
+	for _, test := range tests {
+		requirements := make([]Requirement, 0, len(test.requirements))
+		for _, expression := range test.requirements {
+			r, err := ParseRequirement(expression)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			requirements = append(requirements, r)
+		}
+
+		err := CheckRequirements(requirements, envelopes)
+		if test.pass && err != nil {
+			t.Errorf("%v: %v", test.requirements, err)
+		} else if !test.pass && err == nil {
+			t.Errorf("%v: expected requirements to fail", test.requirements)
+		}
+	}
+}
+
+func TestParseRequirementErrors(t *testing.T) {
+	for _, expression := range []string{"unknown.field=1", "git=main"} {
+		if _, err := ParseRequirement(expression); err == nil {
+			t.Errorf("%v: expected parse to fail", expression)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import (
+	"sort"
+
+	"github.com/testifysec/go-witness/dsse"
+)
+
+// Result is the outcome of checking envelopes against a policy's witness specific constraints.
+type Result struct {
+	Steps []StepResult `json:"steps"`
+}
+
+// StepResult is the outcome of a policy step and the collections that were considered for it.
+type StepResult struct {
+	Name        string             `json:"name"`
+	Passed      bool               `json:"passed"`
+	Reason      string             `json:"reason,omitempty"`
+	Collections []CollectionResult `json:"collections"`
+}
+
+// CollectionResult is the outcome of checking one collection against its step's constraints. A step can pass even
+// though some of its collections failed, as long as one satisfies the step.
+type CollectionResult struct {
+	// Envelope is the index of the collection's envelope in the envelopes that were verified.
+	Envelope int `json:"envelope"`
+	// Signers are the identities of the certificates that signed the envelope and chain to the policy's roots, and
+	// the ids of the policy's public keys that signed it.
+	Signers []string `json:"signers"`
+	Passed  bool     `json:"passed"`
+	Reason  string   `json:"reason,omitempty"`
+}
+
+// Passed returns true if every step passed.
+func (r Result) Passed() bool {
+	for _, step := range r.Steps {
+		if !step.Passed {
+			return false
+		}
+	}
+
+	return true
+}
+
+// signerNames returns the identities of the envelope's signers that the policy trusts. Certificates are named by
+// their emails, URIs, or else common name.
+func (p Policy) signerNames(env dsse.Envelope) []string {
+	names := make([]string, 0)
+	for _, cert := range p.signerCertificates(env) {
+		switch {
+		case len(cert.EmailAddresses) > 0:
+			names = append(names, cert.EmailAddresses...)
+		case len(cert.URIs) > 0:
+			for _, uri := range cert.URIs {
+				names = append(names, uri.String())
+			}
+		default:
+			names = append(names, cert.Subject.CommonName)
+		}
+	}
+
+	keyIDs := make([]string, 0)
+	for id := range p.PublicKeys {
+		if err := p.verifyPublicKey(id, env); err == nil {
+			keyIDs = append(keyIDs, id)
+		}
+	}
+
+	sort.Strings(keyIDs)
+	return append(names, keyIDs...)
+}
@@ -0,0 +1,169 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/testifysec/go-witness/cryptoutil"
+)
+
+const (
+	SBOMCompletenessType = "https://witness.dev/attestations/sbom-completeness/v0.1"
+	SBOMType             = "https://witness.dev/attestations/sbom/v0.1"
+	ProductType          = "https://witness.dev/attestations/product/v0.1"
+)
+
+// SBOMConstraint requires a step that produced artifacts to carry an sbom attestation describing every one of them.
+// A step without products passes, so the constraint can be set on every step of a policy. Formats limits the SBOM to
+// spdx or cyclonedx.
+type SBOMConstraint struct {
+	Formats []string `json:"formats,omitempty"`
+}
+
+type sbomAttestation struct {
+	Format    string                          `json:"format"`
+	Path      string                          `json:"path"`
+	Artifacts map[string]cryptoutil.DigestSet `json:"artifacts"`
+}
+
+// Verify checks that the collection's sbom attestation describes each of its products.
+func (c SBOMConstraint) Verify(collection Collection) error {
+	products := map[string]struct {
+		Digest cryptoutil.DigestSet `json:"digest"`
+	}{}
+
+	if raw, ok := collection.Attestation(ProductType); ok {
+		if err := json.Unmarshal(raw, &products); err != nil {
+			return fmt.Errorf("failed to unmarshal product attestation: %w", err)
+		}
+	}
+
+	if len(products) == 0 {
+		return nil
+	}
+
+	raw, ok := collection.Attestation(SBOMType)
+	if !ok {
+		return fmt.Errorf("collection has products but no sbom attestation")
+	}
+
+	sbom := sbomAttestation{}
+	if err := json.Unmarshal(raw, &sbom); err != nil {
+		return fmt.Errorf("failed to unmarshal sbom attestation: %w", err)
+	}
+
+	if sbom.Format == "" {
+		return fmt.Errorf("sbom attestation has no sbom")
+	}
+
+	if len(c.Formats) > 0 && !contains(c.Formats, sbom.Format) {
+		return fmt.Errorf("sbom is in %v format, expected one of %v", sbom.Format, strings.Join(c.Formats, ", "))
+	}
+
+	undescribed := make([]string, 0)
+	for path, product := range products {
+		if path == sbom.Path {
+			continue
+		}
+
+		if digest, ok := sbom.Artifacts[path]; !ok || !digest.Equal(product.Digest) {
+			undescribed = append(undescribed, path)
+		}
+	}
+
+	if len(undescribed) == 0 {
+		return nil
+	}
+
+	sort.Strings(undescribed)
+	if len(undescribed) > maxReportedFiles {
+		return fmt.Errorf("sbom does not describe products %v, and %d more", strings.Join(undescribed[:maxReportedFiles], ", "), len(undescribed)-maxReportedFiles)
+	}
+
+	return fmt.Errorf("sbom does not describe products %v", strings.Join(undescribed, ", "))
+}
+
+// SBOMCompletenessConstraint requires a step's collection to carry an sbom-completeness attestation scoring at least
+// one SBOM, with every SBOM scoring at least MinimumScore against the NTIA minimum elements. Elements sets minimums
+// for individual elements, such as requiring every component to have a supplier.
+type SBOMCompletenessConstraint struct {
+	MinimumScore float64            `json:"minimumScore,omitempty"`
+	Elements     map[string]float64 `json:"elements,omitempty"`
+}
+
+type sbomCompleteness struct {
+	SBOMs map[string]struct {
+		Elements map[string]float64 `json:"elements"`
+		Score    float64            `json:"score"`
+	} `json:"sboms"`
+}
+
+// Verify checks the collection's sbom-completeness attestation against the constraint.
+func (c SBOMCompletenessConstraint) Verify(collection Collection) error {
+	raw, ok := collection.Attestation(SBOMCompletenessType)
+	if !ok {
+		return fmt.Errorf("collection has no sbom-completeness attestation")
+	}
+
+	completeness := sbomCompleteness{}
+	if err := json.Unmarshal(raw, &completeness); err != nil {
+		return fmt.Errorf("failed to unmarshal sbom-completeness attestation: %w", err)
+	}
+
+	if len(completeness.SBOMs) == 0 {
+		return fmt.Errorf("sbom-completeness attestation has no sboms")
+	}
+
+	names := make([]string, 0, len(completeness.SBOMs))
+	for name := range completeness.SBOMs {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	elements := make([]string, 0, len(c.Elements))
+	for element := range c.Elements {
+		elements = append(elements, element)
+	}
+
+	sort.Strings(elements)
+	for _, name := range names {
+		sbom := completeness.SBOMs[name]
+		if sbom.Score < c.MinimumScore {
+			return fmt.Errorf("sbom %v has completeness score %v, lower than the minimum %v", name, sbom.Score, c.MinimumScore)
+		}
+
+		for _, element := range elements {
+			if sbom.Elements[element] < c.Elements[element] {
+				return fmt.Errorf("sbom %v has %v completeness %v, lower than the minimum %v", name, element, sbom.Elements[element], c.Elements[element])
+			}
+		}
+	}
+
+	return nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,89 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/testifysec/go-witness/dsse"
+)
+
+func sbomEnvelope(t *testing.T, step string, score, supplier float64) dsse.Envelope {
+	return testEnvelope(t, step, map[string]interface{}{
+		SBOMCompletenessType: map[string]interface{}{"sboms": map[string]interface{}{
+			"sbom.spdx.json": map[string]interface{}{"score": score, "elements": map[string]interface{}{"supplier": supplier}},
+		}},
+	})
+}
+
+func TestSBOMCompletenessConstraint(t *testing.T) {
+	p := Policy{Steps: map[string]Step{"sbom": {Name: "sbom", SBOMCompleteness: &SBOMCompletenessConstraint{MinimumScore: 80}}}}
+	if _, err := p.Verify([]dsse.Envelope{sbomEnvelope(t, "sbom", 85.7, 0.5)}); err != nil {
+		t.Errorf("expected score above the minimum to pass: %v", err)
+	}
+
+	if _, err := p.Verify([]dsse.Envelope{sbomEnvelope(t, "sbom", 78.6, 1)}); err == nil {
+		t.Error("expected score below the minimum to fail")
+	}
+
+	if _, err := p.Verify([]dsse.Envelope{testEnvelope(t, "sbom", nil)}); err == nil {
+		t.Error("expected collection without an sbom-completeness attestation to fail")
+	}
+
+	p.Steps["sbom"].SBOMCompleteness.Elements = map[string]float64{"supplier": 1}
+	if _, err := p.Verify([]dsse.Envelope{sbomEnvelope(t, "sbom", 85.7, 0.5)}); err == nil {
+		t.Error("expected element below its minimum to fail")
+	}
+}
+
+func TestSBOMConstraint(t *testing.T) {
+	products := map[string]interface{}{
+		"app":       map[string]interface{}{"mime_type": "application/octet-stream", "digest": map[string]string{"sha256": "aaaa"}},
+		"sbom.json": map[string]interface{}{"mime_type": "text/plain", "digest": map[string]string{"sha256": "bbbb"}},
+	}
+
+	sbom := func(format string, artifacts map[string]interface{}) map[string]interface{} {
+		return map[string]interface{}{"format": format, "source": "product", "path": "sbom.json", "artifacts": artifacts}
+	}
+
+	p := Policy{Steps: map[string]Step{"build": {Name: "build", SBOM: &SBOMConstraint{Formats: []string{"cyclonedx"}}}}}
+	described := map[string]interface{}{"app": map[string]string{"sha256": "aaaa"}}
+	if _, err := p.Verify([]dsse.Envelope{testEnvelope(t, "build", map[string]interface{}{ProductType: products, SBOMType: sbom("cyclonedx", described)})}); err != nil {
+		t.Errorf("expected sbom describing every product to pass: %v", err)
+	}
+
+	if _, err := p.Verify([]dsse.Envelope{testEnvelope(t, "build", map[string]interface{}{ProductType: map[string]interface{}{}})}); err != nil {
+		t.Errorf("expected step without products to pass: %v", err)
+	}
+
+	tests := []struct {
+		name         string
+		attestations map[string]interface{}
+		err          string
+	}{
+		{"no sbom", map[string]interface{}{ProductType: products}, "no sbom attestation"},
+		{"format", map[string]interface{}{ProductType: products, SBOMType: sbom("spdx", described)}, "spdx format"},
+		{"missing product", map[string]interface{}{ProductType: products, SBOMType: sbom("cyclonedx", map[string]interface{}{})}, "does not describe products app"},
+		{"changed product", map[string]interface{}{ProductType: products, SBOMType: sbom("cyclonedx", map[string]interface{}{"app": map[string]string{"sha256": "cccc"}})}, "does not describe products app"},
+	}
+
+	for _, test := range tests {
+		_, err := p.Verify([]dsse.Envelope{testEnvelope(t, "build", test.attestations)})
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("%v: expected error containing %q, got %v", test.name, test.err, err)
+		}
+	}
+}
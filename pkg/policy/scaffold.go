@@ -0,0 +1,222 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/dsse"
+)
+
+const MaterialType = "https://witness.dev/attestations/material/v0.1"
+
+// scaffoldPolicy is the policy Scaffold writes. It only holds the fields go-witness reads, so authors can add
+// constraints to it.
+type scaffoldPolicy struct {
+	Expires    time.Time               `json:"expires"`
+	PublicKeys map[string]PublicKey    `json:"publickeys,omitempty"`
+	Steps      map[string]scaffoldStep `json:"steps"`
+}
+
+type scaffoldStep struct {
+	Name          string                `json:"name"`
+	Functionaries []scaffoldFunctionary `json:"functionaries"`
+	Attestations  []scaffoldAttestation `json:"attestations"`
+	ArtifactsFrom []string              `json:"artifactsFrom,omitempty"`
+}
+
+type scaffoldFunctionary struct {
+	Type        string `json:"type"`
+	PublicKeyID string `json:"publickeyid"`
+}
+
+type scaffoldAttestation struct {
+	Type string `json:"type"`
+}
+
+// Scaffold returns an unsigned policy with a step for each step recorded by the collections signed in the envelopes.
+// Each step requires the attestation types every one of its collections recorded, and trusts the keys that signed
+// any of them as functionaries. Keys that signed none of the collections are left out. A step uses the artifacts of
+// another step if one of its materials is a product of the other step. Steps signed only with certificates have no
+// functionaries, since the roots their certificates chain to aren't in the envelopes.
+func Scaffold(envelopes []dsse.Envelope, keys []PublicKey, expires time.Time) ([]byte, error) {
+	collectionsByStep := make(map[string][]Collection)
+	envelopesByStep := make(map[string][]dsse.Envelope)
+	for _, env := range envelopes {
+		collection, err := CollectionFromEnvelope(env)
+		if err != nil {
+			return nil, err
+		}
+
+		collectionsByStep[collection.Name] = append(collectionsByStep[collection.Name], collection)
+		envelopesByStep[collection.Name] = append(envelopesByStep[collection.Name], env)
+	}
+
+	if len(collectionsByStep) == 0 {
+		return nil, fmt.Errorf("no collections to scaffold a policy from")
+	}
+
+	verifiers := make(map[string]cryptoutil.Verifier, len(keys))
+	for _, key := range keys {
+		verifier, err := cryptoutil.NewVerifierFromReader(bytes.NewReader(key.Key))
+		if err != nil {
+			return nil, fmt.Errorf("failed to load public key %v: %w", key.KeyID, err)
+		}
+
+		verifiers[key.KeyID] = verifier
+	}
+
+	products, err := stepProducts(collectionsByStep)
+	if err != nil {
+		return nil, err
+	}
+
+	p := scaffoldPolicy{Expires: expires.UTC(), PublicKeys: map[string]PublicKey{}, Steps: map[string]scaffoldStep{}}
+	for name, collections := range collectionsByStep {
+		step := scaffoldStep{Name: name, Functionaries: []scaffoldFunctionary{}, Attestations: []scaffoldAttestation{}}
+		for _, attestationType := range commonTypes(collections) {
+			step.Attestations = append(step.Attestations, scaffoldAttestation{Type: attestationType})
+		}
+
+		for _, key := range keys {
+			if !signedByAny(envelopesByStep[name], verifiers[key.KeyID]) {
+				continue
+			}
+
+			p.PublicKeys[key.KeyID] = key
+			step.Functionaries = append(step.Functionaries, scaffoldFunctionary{Type: "publickey", PublicKeyID: key.KeyID})
+		}
+
+		if step.ArtifactsFrom, err = artifactsFrom(name, collections, products); err != nil {
+			return nil, err
+		}
+
+		p.Steps[name] = step
+	}
+
+	data, err := json.MarshalIndent(p, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal policy: %w", err)
+	}
+
+	return data, nil
+}
+
+// commonTypes returns the sorted attestation types that every collection has.
+func commonTypes(collections []Collection) []string {
+	counts := make(map[string]int)
+	for _, collection := range collections {
+		seen := make(map[string]bool)
+		for _, attestation := range collection.Attestations {
+			if !seen[attestation.Type] {
+				seen[attestation.Type] = true
+				counts[attestation.Type]++
+			}
+		}
+	}
+
+	types := make([]string, 0, len(counts))
+	for attestationType, count := range counts {
+		if count == len(collections) {
+			types = append(types, attestationType)
+		}
+	}
+
+	sort.Strings(types)
+	return types
+}
+
+func signedByAny(envelopes []dsse.Envelope, verifier cryptoutil.Verifier) bool {
+	for _, env := range envelopes {
+		if _, err := env.Verify(dsse.WithVerifiers([]cryptoutil.Verifier{verifier})); err == nil {
+			return true
+		}
+	}
+
+	return false
+}
+
+// stepProducts returns the steps that produced each digest, keyed by algorithm:value.
+func stepProducts(collectionsByStep map[string][]Collection) (map[string]map[string]bool, error) {
+	products := make(map[string]map[string]bool)
+	for name, collections := range collectionsByStep {
+		for _, collection := range collections {
+			raw, ok := collection.Attestation(ProductType)
+			if !ok {
+				continue
+			}
+
+			recorded := map[string]struct {
+				Digest map[string]string `json:"digest"`
+			}{}
+
+			if err := json.Unmarshal(raw, &recorded); err != nil {
+				return nil, fmt.Errorf("failed to unmarshal product attestation: %w", err)
+			}
+
+			for _, product := range recorded {
+				for algorithm, value := range product.Digest {
+					key := algorithm + ":" + value
+					if products[key] == nil {
+						products[key] = make(map[string]bool)
+					}
+
+					products[key][name] = true
+				}
+			}
+		}
+	}
+
+	return products, nil
+}
+
+// artifactsFrom returns the sorted steps, other than the named one, that produced any of the collections' materials.
+func artifactsFrom(name string, collections []Collection, products map[string]map[string]bool) ([]string, error) {
+	from := make(map[string]bool)
+	for _, collection := range collections {
+		raw, ok := collection.Attestation(MaterialType)
+		if !ok {
+			continue
+		}
+
+		materials := map[string]map[string]string{}
+		if err := json.Unmarshal(raw, &materials); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal material attestation: %w", err)
+		}
+
+		for _, digest := range materials {
+			for algorithm, value := range digest {
+				for step := range products[algorithm+":"+value] {
+					if step != name {
+						from[step] = true
+					}
+				}
+			}
+		}
+	}
+
+	steps := make([]string, 0, len(from))
+	for step := range from {
+		steps = append(steps, step)
+	}
+
+	sort.Strings(steps)
+	return steps, nil
+}
@@ -0,0 +1,125 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/dsse"
+	"github.com/testifysec/go-witness/intoto"
+)
+
+func newTestKey(t testing.TB) (cryptoutil.Signer, PublicKey) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signer := cryptoutil.NewECDSASigner(key, crypto.SHA256)
+	verifier, err := signer.Verifier()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyID, err := verifier.KeyID()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keyBytes, err := verifier.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return signer, PublicKey{KeyID: keyID, Key: keyBytes}
+}
+
+func signTestEnvelope(t testing.TB, env dsse.Envelope, signer cryptoutil.Signer) dsse.Envelope {
+	signed, err := dsse.Sign(intoto.PayloadType, bytes.NewReader(env.Payload), signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return signed
+}
+
+func TestScaffold(t *testing.T) {
+	alice, aliceKey := newTestKey(t)
+	_, bobKey := newTestKey(t)
+	binary := map[string]interface{}{"digest": map[string]string{"sha256": "abc"}, "mime_type": "application/octet-stream"}
+	build := func(extra string) dsse.Envelope {
+		attestations := map[string]interface{}{
+			MaterialType:   map[string]interface{}{"main.go": map[string]string{"sha256": "def"}},
+			CommandRunType: map[string]interface{}{"cmd": []string{"make"}},
+			ProductType:    map[string]interface{}{"bin/app": binary},
+		}
+
+		if extra != "" {
+			attestations[extra] = map[string]interface{}{}
+		}
+
+		return signTestEnvelope(t, testEnvelope(t, "build", attestations), alice)
+	}
+
+	envelopes := []dsse.Envelope{
+		build(gitType),
+		build(""),
+		signTestEnvelope(t, testEnvelope(t, "package", map[string]interface{}{
+			MaterialType: map[string]interface{}{"bin/app": map[string]string{"sha256": "abc"}},
+		}), alice),
+	}
+
+	expires := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	data, err := Scaffold(envelopes, []PublicKey{aliceKey, bobKey}, expires)
+	if err != nil {
+		t.Fatalf("failed to scaffold policy: %v", err)
+	}
+
+	p := scaffoldPolicy{}
+	if err := json.Unmarshal(data, &p); err != nil {
+		t.Fatal(err)
+	}
+
+	if !p.Expires.Equal(expires) || len(p.PublicKeys) != 1 || p.PublicKeys[aliceKey.KeyID].KeyID != aliceKey.KeyID {
+		t.Errorf("expected only the signing key to be trusted until %v, got %v until %v", expires, p.PublicKeys, p.Expires)
+	}
+
+	expected := scaffoldStep{
+		Name:          "build",
+		Functionaries: []scaffoldFunctionary{{Type: "publickey", PublicKeyID: aliceKey.KeyID}},
+		Attestations:  []scaffoldAttestation{{Type: CommandRunType}, {Type: MaterialType}, {Type: ProductType}},
+	}
+
+	if !reflect.DeepEqual(p.Steps["build"], expected) {
+		t.Errorf("expected build step %+v, got %+v", expected, p.Steps["build"])
+	}
+
+	if from := p.Steps["package"].ArtifactsFrom; !reflect.DeepEqual(from, []string{"build"}) {
+		t.Errorf("expected package to use the artifacts of build, got %v", from)
+	}
+
+	if _, err := Scaffold(nil, nil, expires); err == nil {
+		t.Error("expected scaffolding without collections to fail")
+	}
+}
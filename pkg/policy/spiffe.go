@@ -0,0 +1,89 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// descendantsWildcard ends a SPIFFE ID pattern that matches the path before it and everything under it.
+const descendantsWildcard = "/**"
+
+// SPIFFEConstraint requires a step's collection to be signed with an X.509 SVID whose SPIFFE ID matches one of IDs,
+// such as spiffe://corp/ci/prod/*, so only the workloads of a particular build farm can satisfy the step. The SVID's
+// certificate must chain to one of the policy's roots.
+type SPIFFEConstraint struct {
+	IDs []string `json:"ids"`
+}
+
+// Verify checks that one of the signers' SPIFFE IDs matches one of the constraint's patterns.
+func (s SPIFFEConstraint) Verify(ids []string) error {
+	if len(s.IDs) == 0 {
+		return fmt.Errorf("spiffe constraint does not list any ids")
+	}
+
+	for _, pattern := range s.IDs {
+		for _, id := range ids {
+			matched, err := MatchSPIFFEID(pattern, id)
+			if err != nil {
+				return err
+			}
+
+			if matched {
+				return nil
+			}
+		}
+	}
+
+	if len(ids) == 0 {
+		return fmt.Errorf("collection is not signed with a trusted spiffe svid")
+	}
+
+	return fmt.Errorf("signer spiffe ids %v do not match %v", ids, s.IDs)
+}
+
+// MatchSPIFFEID reports whether the SPIFFE ID matches the pattern. The trust domains must be equal, and the pattern's
+// path is matched against the ID's path with path.Match, so * matches a single path segment. A pattern ending in /**
+// matches its path and every path under it.
+func MatchSPIFFEID(pattern, id string) (bool, error) {
+	p, err := url.Parse(pattern)
+	if err != nil || p.Scheme != "spiffe" || p.Host == "" {
+		return false, fmt.Errorf("invalid spiffe id pattern %v", pattern)
+	}
+
+	parsed, err := url.Parse(id)
+	if err != nil || parsed.Scheme != "spiffe" {
+		return false, nil
+	}
+
+	if !strings.EqualFold(p.Host, parsed.Host) {
+		return false, nil
+	}
+
+	if strings.HasSuffix(p.Path, descendantsWildcard) {
+		prefix := strings.TrimSuffix(p.Path, descendantsWildcard)
+		return parsed.Path == prefix || strings.HasPrefix(parsed.Path, prefix+"/"), nil
+	}
+
+	matched, err := path.Match(p.Path, parsed.Path)
+	if err != nil {
+		return false, fmt.Errorf("invalid spiffe id pattern %v: %w", pattern, err)
+	}
+
+	return matched, nil
+}
@@ -0,0 +1,91 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import (
+	"crypto/x509"
+	"net/url"
+	"testing"
+
+	"github.com/testifysec/go-witness/dsse"
+)
+
+func TestMatchSPIFFEID(t *testing.T) {
+	tests := []struct {
+		pattern string
+		id      string
+		match   bool
+	}{
+		{"spiffe://corp/ci/prod/*", "spiffe://corp/ci/prod/runner-1", true},
+		{"spiffe://corp/ci/prod/*", "spiffe://corp/ci/prod/pool/runner-1", false},
+		{"spiffe://corp/ci/prod/*", "spiffe://corp/ci/dev/runner-1", false},
+		{"spiffe://corp/ci/prod/*", "spiffe://other/ci/prod/runner-1", false},
+		{"spiffe://corp/ci/**", "spiffe://corp/ci/prod/pool/runner-1", true},
+		{"spiffe://corp/ci/**", "spiffe://corp/ci", true},
+		{"spiffe://corp/ci/**", "spiffe://corp/cid/runner-1", false},
+		{"spiffe://corp/ci/prod/runner-1", "spiffe://corp/ci/prod/runner-1", true},
+		{"spiffe://corp/ci/prod/*", "https://corp/ci/prod/runner-1", false},
+	}
+
+	for _, test := range tests {
+		matched, err := MatchSPIFFEID(test.pattern, test.id)
+		if err != nil {
+			t.Fatalf("failed to match %v against %v: %v", test.id, test.pattern, err)
+		}
+
+		if matched != test.match {
+			t.Errorf("expected %v matching %v to be %v", test.id, test.pattern, test.match)
+		}
+	}
+
+	if _, err := MatchSPIFFEID("https://corp/ci/*", "spiffe://corp/ci/runner"); err == nil {
+		t.Error("expected a pattern without the spiffe scheme to be rejected")
+	}
+}
+
+func TestSPIFFEConstraint(t *testing.T) {
+	ca := newTestCA(t)
+	untrusted := newTestCA(t)
+	p := Policy{
+		Roots: map[string]Root{"root": {Certificate: ca.pem()}},
+		Steps: map[string]Step{"build": {Name: "build", SPIFFE: &SPIFFEConstraint{IDs: []string{"spiffe://corp/ci/prod/*"}}}},
+	}
+
+	env := testEnvelope(t, "build", nil)
+	svid := func(ca testCA, id string) dsse.Envelope {
+		uri, err := url.Parse(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return ca.signWith(t, env, &x509.Certificate{URIs: []*url.URL{uri}})
+	}
+
+	if _, err := p.Verify([]dsse.Envelope{svid(ca, "spiffe://corp/ci/prod/runner-1")}); err != nil {
+		t.Errorf("expected a matching svid to satisfy the step: %v", err)
+	}
+
+	if _, err := p.Verify([]dsse.Envelope{svid(ca, "spiffe://corp/ci/dev/runner-1")}); err == nil {
+		t.Error("expected an svid outside the pattern to fail")
+	}
+
+	if _, err := p.Verify([]dsse.Envelope{svid(untrusted, "spiffe://corp/ci/prod/runner-1")}); err == nil {
+		t.Error("expected an svid from an untrusted root to fail")
+	}
+
+	if _, err := p.Verify([]dsse.Envelope{ca.sign(t, env, "alice@example.com")}); err == nil {
+		t.Error("expected a certificate without a spiffe id to fail")
+	}
+}
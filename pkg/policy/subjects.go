@@ -0,0 +1,85 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/testifysec/go-witness/dsse"
+	"github.com/testifysec/go-witness/intoto"
+)
+
+// SubjectConstraint requires every subject of a step's collection to have been contributed by one of Attestors, such
+// as the product attestor, so a digest only another attestor put in the statement, such as a label, can not stand in
+// for an artifact. Collections that do not record which attestors contributed their subjects fail the constraint.
+type SubjectConstraint struct {
+	Attestors []string `json:"attestors"`
+}
+
+// Verify checks each subject of the envelope's statement against the collection's record of its attestors.
+func (c SubjectConstraint) Verify(collection Collection, env dsse.Envelope) error {
+	if len(c.Attestors) == 0 {
+		return fmt.Errorf("subject constraint does not list any attestors")
+	}
+
+	if collection.Subjects == nil {
+		return fmt.Errorf("collection does not record the attestors of its subjects")
+	}
+
+	statement := intoto.Statement{}
+	if err := json.Unmarshal(env.Payload, &statement); err != nil {
+		return fmt.Errorf("failed to unmarshal statement: %w", err)
+	}
+
+	allowed := make(map[string]bool, len(c.Attestors))
+	for _, attestor := range c.Attestors {
+		allowed[attestor] = true
+	}
+
+	for _, subject := range statement.Subject {
+		attestors, ok := collection.subjectAttestors(subject)
+		if !ok {
+			return fmt.Errorf("collection does not record the attestors of subject %v", subject.Name)
+		}
+
+		contributed := false
+		for _, attestor := range attestors {
+			if allowed[attestor] {
+				contributed = true
+				break
+			}
+		}
+
+		if !contributed {
+			return fmt.Errorf("subject %v was contributed by %v, not one of %v", subject.Name, attestors, c.Attestors)
+		}
+	}
+
+	return nil
+}
+
+// subjectAttestors returns the attestors the collection records for the statement subject with the same name and
+// digest.
+func (c Collection) subjectAttestors(subject intoto.Subject) ([]string, bool) {
+	for _, s := range c.Subjects {
+		if s.Name == subject.Name && reflect.DeepEqual(s.Digest, subject.Digest) {
+			return s.Attestors, true
+		}
+	}
+
+	return nil, false
+}
@@ -0,0 +1,106 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/testifysec/go-witness/dsse"
+	"github.com/testifysec/go-witness/intoto"
+	"github.com/testifysec/witness/pkg/subjects"
+)
+
+const (
+	productType = "https://witness.dev/attestations/product/v0.1"
+	labelsType  = "https://witness.dev/attestations/labels/v0.1"
+)
+
+// provenanceEnvelope returns a build collection whose statement has the subjects, recording their attestors if
+// recorded is set.
+func provenanceEnvelope(t *testing.T, recorded []subjects.Subject, statementSubjects ...subjects.Subject) dsse.Envelope {
+	predicate, err := json.Marshal(map[string]interface{}{"name": "build", "attestations": []interface{}{}, "subjects": recorded})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	statement := intoto.Statement{Type: intoto.StatementType, PredicateType: CollectionType, Predicate: predicate}
+	for _, s := range statementSubjects {
+		statement.Subject = append(statement.Subject, intoto.Subject{Name: s.Name, Digest: s.Digest})
+	}
+
+	payload, err := json.Marshal(statement)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return dsse.Envelope{Payload: payload, PayloadType: intoto.PayloadType}
+}
+
+func TestSubjectConstraint(t *testing.T) {
+	product := subjects.Subject{Name: productType + "/file:app", Digest: map[string]string{"sha256": "aaaa"}, Attestors: []string{productType}}
+	label := subjects.Subject{Name: labelsType + "/label:app", Digest: map[string]string{"sha256": "bbbb"}, Attestors: []string{labelsType}}
+	relabeled := subjects.Subject{Name: labelsType + "/label:app", Digest: map[string]string{"sha256": "aaaa"}, Attestors: []string{labelsType, productType}}
+	p := Policy{Steps: map[string]Step{"build": {Name: "build", Subjects: &SubjectConstraint{Attestors: []string{productType}}}}}
+
+	tests := []struct {
+		name     string
+		envelope dsse.Envelope
+		pass     bool
+	}{
+		{"product", provenanceEnvelope(t, []subjects.Subject{product}, product), true},
+		{"label digest also hashed by the product attestor", provenanceEnvelope(t, []subjects.Subject{product, relabeled}, product, relabeled), true},
+		{"injected label", provenanceEnvelope(t, []subjects.Subject{product, label}, product, label), false},
+		{"subject missing from the record", provenanceEnvelope(t, []subjects.Subject{product}, product, label), false},
+		{"no record", provenanceEnvelope(t, nil, product), false},
+	}
+
+	for _, test := range tests {
+		_, err := p.Verify([]dsse.Envelope{test.envelope})
+		if test.pass && err != nil {
+			t.Errorf("%v: expected the collection to pass: %v", test.name, err)
+		} else if !test.pass && err == nil {
+			t.Errorf("%v: expected the collection to fail", test.name)
+		}
+	}
+}
+
+func TestRegoCollectionInput(t *testing.T) {
+	module := `package subjects
+
+deny[msg] {
+  subject := input.subjects[_]
+  not productSubject(subject)
+  msg := sprintf("%v was not recorded by the product attestor", [subject.name])
+}
+
+productSubject(subject) {
+  subject.attestors[_] == "` + productType + `"
+}
+`
+
+	p := Policy{Steps: map[string]Step{"build": {Name: "build", Rego: []RegoConstraint{{Name: "product subjects", Module: []byte(module)}}}}}
+	product := subjects.Subject{Name: productType + "/file:app", Digest: map[string]string{"sha256": "aaaa"}, Attestors: []string{productType}}
+	label := subjects.Subject{Name: labelsType + "/label:app", Digest: map[string]string{"sha256": "bbbb"}, Attestors: []string{labelsType}}
+	if _, err := p.Verify([]dsse.Envelope{provenanceEnvelope(t, []subjects.Subject{product}, product)}); err != nil {
+		t.Errorf("expected product subjects to pass: %v", err)
+	}
+
+	_, err := p.Verify([]dsse.Envelope{provenanceEnvelope(t, []subjects.Subject{product, label}, product, label)})
+	if err == nil || !strings.Contains(err.Error(), "label:app was not recorded by the product attestor") {
+		t.Errorf("expected the injected label to be denied, got %v", err)
+	}
+}
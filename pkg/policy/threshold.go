@@ -0,0 +1,173 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import (
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/testifysec/go-witness/dsse"
+)
+
+// verifyThreshold checks that one of the step's builds is attested by at least the step's threshold of distinct
+// functionaries. Collections attest to the same build if their statements have the same subjects and they record the
+// same products, so functionaries who each attested an unrelated build do not count together. Collections are skipped
+// if errs, indexed like the envelopes, holds an error for them. Public key functionaries count once per key, and
+// certificate functionaries once per identity that signed, so two people signing with certificates from the same root
+// count as two functionaries.
+func (p Policy) verifyThreshold(s Step, envelopes []dsse.Envelope, errs []error) error {
+	signersByBuild := make(map[string]map[string]struct{})
+	builds := make([]string, 0)
+	for i, env := range envelopes {
+		if errs[i] != nil {
+			continue
+		}
+
+		build := attestedBuild(env)
+		if _, ok := signersByBuild[build]; !ok {
+			signersByBuild[build] = make(map[string]struct{})
+			builds = append(builds, build)
+		}
+
+		for _, name := range p.stepFunctionaries(s, env) {
+			signersByBuild[build][name] = struct{}{}
+		}
+	}
+
+	sort.Strings(builds)
+	found := make(map[string]struct{})
+	for _, build := range builds {
+		if len(signersByBuild[build]) > len(found) {
+			found = signersByBuild[build]
+		}
+	}
+
+	if len(found) >= s.Threshold {
+		return nil
+	}
+
+	names := make([]string, 0, len(found))
+	for name := range found {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	trusted := make([]string, 0, len(s.Functionaries))
+	for _, f := range s.Functionaries {
+		trusted = append(trusted, f.String())
+	}
+
+	return fmt.Errorf("signed by %d of the %d distinct functionaries required: found [%v] of [%v]", len(found), s.Threshold, strings.Join(names, ", "), strings.Join(trusted, ", "))
+}
+
+// attestedBuild identifies what the envelope's collection attests to by the digest of its statement's subjects and the
+// products the collection records, each decoded and encoded again so the order they were written in doesn't matter.
+func attestedBuild(env dsse.Envelope) string {
+	statement := struct {
+		Subject   []interface{}   `json:"subject"`
+		Predicate json.RawMessage `json:"predicate"`
+	}{}
+
+	if err := json.Unmarshal(env.Payload, &statement); err != nil {
+		return fmt.Sprintf("%x", sha256.Sum256(env.Payload))
+	}
+
+	subjects := make([]string, 0, len(statement.Subject))
+	for _, subject := range statement.Subject {
+		encoded, _ := json.Marshal(subject)
+		subjects = append(subjects, string(encoded))
+	}
+
+	sort.Strings(subjects)
+	var products interface{}
+	if collection, err := collectionFromPredicate(CollectionType, statement.Predicate); err == nil {
+		if raw, ok := collection.Attestation(ProductType); ok {
+			_ = json.Unmarshal(raw, &products)
+		}
+	}
+
+	encoded, _ := json.Marshal(struct {
+		Subjects []string    `json:"subjects"`
+		Products interface{} `json:"products"`
+	}{subjects, products})
+
+	return fmt.Sprintf("%x", sha256.Sum256(encoded))
+}
+
+// stepFunctionaries returns the names of the step's functionaries that signed the envelope: the key id of each
+// public key functionary, and the identity of each certificate that satisfies a certificate functionary.
+func (p Policy) stepFunctionaries(s Step, env dsse.Envelope) []string {
+	bundles := p.trustBundles()
+	verifiers := p.signerVerifiers(env)
+	names := make([]string, 0)
+	seen := make(map[string]bool)
+	add := func(name string) {
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+
+	for _, f := range s.Functionaries {
+		if f.Type == "publickey" {
+			if err := p.verifyPublicKey(f.PublicKeyID, env); err == nil {
+				add(f.PublicKeyID)
+			}
+
+			continue
+		}
+
+		for _, verifier := range verifiers {
+			if f.CertConstraint.Check(verifier, bundles) != nil || f.CertConstraint.CheckIdentity(verifier.Certificate()) != nil {
+				continue
+			}
+
+			add(certificateName(verifier.Certificate()))
+		}
+	}
+
+	return names
+}
+
+// certificateName identifies the holder of a certificate by its email addresses, its URIs, or its common name, in
+// that order of preference.
+func certificateName(cert *x509.Certificate) string {
+	switch {
+	case len(cert.EmailAddresses) > 0:
+		return strings.Join(cert.EmailAddresses, ",")
+	case len(cert.URIs) > 0:
+		uris := make([]string, 0, len(cert.URIs))
+		for _, uri := range cert.URIs {
+			uris = append(uris, uri.String())
+		}
+
+		return strings.Join(uris, ",")
+	default:
+		return cert.Subject.CommonName
+	}
+}
+
+// String describes the functionary for error messages.
+func (f Functionary) String() string {
+	if f.Type == "publickey" {
+		return "publickey " + f.PublicKeyID
+	}
+
+	return fmt.Sprintf("%v with roots %v", f.Type, strings.Join(f.CertConstraint.Roots, ","))
+}
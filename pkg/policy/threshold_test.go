@@ -0,0 +1,106 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/testifysec/go-witness/dsse"
+	gwpolicy "github.com/testifysec/go-witness/policy"
+)
+
+func TestThresholdPublicKeys(t *testing.T) {
+	first, firstKey := newTestKey(t)
+	second, secondKey := newTestKey(t)
+	p := Policy{
+		PublicKeys: map[string]PublicKey{firstKey.KeyID: firstKey, secondKey.KeyID: secondKey},
+		Steps: map[string]Step{"release": {
+			Name: "release",
+			Functionaries: []Functionary{
+				{Type: "publickey", PublicKeyID: firstKey.KeyID},
+				{Type: "publickey", PublicKeyID: secondKey.KeyID},
+			},
+			Threshold: 2,
+		}},
+	}
+
+	env := testEnvelope(t, "release", nil)
+	byFirst := signTestEnvelope(t, env, first)
+	bySecond := signTestEnvelope(t, env, second)
+
+	_, err := p.Verify([]dsse.Envelope{byFirst, signTestEnvelope(t, env, first)})
+	if err == nil {
+		t.Fatal("expected two collections signed by the same key to count as one functionary")
+	}
+
+	if !strings.Contains(err.Error(), "signed by 1 of the 2") || !strings.Contains(err.Error(), "found ["+firstKey.KeyID+"]") ||
+		!strings.Contains(err.Error(), "publickey "+secondKey.KeyID) {
+		t.Errorf("expected the error to list the found and trusted functionaries, got %v", err)
+	}
+
+	if _, err := p.Verify([]dsse.Envelope{byFirst, bySecond}); err != nil {
+		t.Errorf("expected collections signed by both keys to meet the threshold: %v", err)
+	}
+
+	if _, err := p.Verify([]dsse.Envelope{byFirst, testEnvelope(t, "release", nil)}); err == nil {
+		t.Error("expected an unsigned collection not to count towards the threshold")
+	}
+
+	products := func(digest string) dsse.Envelope {
+		return testEnvelope(t, "release", map[string]interface{}{
+			ProductType: map[string]interface{}{"app": map[string]interface{}{"digest": map[string]string{"sha256": digest}}},
+		})
+	}
+
+	_, err = p.Verify([]dsse.Envelope{signTestEnvelope(t, products("aa"), first), signTestEnvelope(t, products("bb"), second)})
+	if err == nil || !strings.Contains(err.Error(), "signed by 1 of the 2") {
+		t.Errorf("expected functionaries attesting different products not to count together, got %v", err)
+	}
+
+	if _, err := p.Verify([]dsse.Envelope{signTestEnvelope(t, products("aa"), first), signTestEnvelope(t, products("aa"), second)}); err != nil {
+		t.Errorf("expected functionaries attesting the same products to meet the threshold: %v", err)
+	}
+}
+
+func TestThresholdCertificates(t *testing.T) {
+	ca := newTestCA(t)
+	p := Policy{
+		Roots: map[string]Root{"root": {Certificate: ca.pem()}},
+		Steps: map[string]Step{"release": {
+			Name: "release",
+			Functionaries: []Functionary{{Type: "root", CertConstraint: CertConstraint{
+				CertConstraint: gwpolicy.CertConstraint{Emails: []string{"*"}, Roots: []string{"root"}},
+			}}},
+			Command:   &CommandConstraint{Exact: []string{"make", "release"}},
+			Threshold: 2,
+		}},
+	}
+
+	release := commandEnvelope(t, "release", "make", "release")
+	alice := ca.sign(t, release, "alice@example.com")
+	if _, err := p.Verify([]dsse.Envelope{alice, ca.sign(t, release, "alice@example.com")}); err == nil {
+		t.Error("expected two certificates for the same identity to count as one functionary")
+	}
+
+	if _, err := p.Verify([]dsse.Envelope{alice, ca.sign(t, release, "bob@example.com")}); err != nil {
+		t.Errorf("expected certificates for two identities from the same root to meet the threshold: %v", err)
+	}
+
+	bob := ca.sign(t, commandEnvelope(t, "release", "make", "test"), "bob@example.com")
+	if _, err := p.Verify([]dsse.Envelope{alice, bob}); err == nil || !strings.Contains(err.Error(), "found [alice@example.com]") {
+		t.Errorf("expected a collection failing the step's constraints not to count towards the threshold, got %v", err)
+	}
+}
@@ -0,0 +1,47 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import (
+	"crypto/x509"
+	"fmt"
+
+	"github.com/testifysec/go-witness/dsse"
+)
+
+// TimestampAuthorityCertificates returns the roots and intermediates of the timestamp authorities the policy trusts to
+// timestamp signatures.
+func (p Policy) TimestampAuthorityCertificates() ([]*x509.Certificate, []*x509.Certificate, error) {
+	roots := make([]*x509.Certificate, 0, len(p.TimestampAuthorities))
+	intermediates := make([]*x509.Certificate, 0)
+	for name, authority := range p.TimestampAuthorities {
+		cert, err := dsse.TryParseCertificate(authority.Certificate)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to parse timestamp authority %v: %w", name, err)
+		}
+
+		roots = append(roots, cert)
+		for i, intermediate := range authority.Intermediates {
+			cert, err := dsse.TryParseCertificate(intermediate)
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to parse intermediate %v of timestamp authority %v: %w", i, name, err)
+			}
+
+			intermediates = append(intermediates, cert)
+		}
+	}
+
+	return roots, intermediates, nil
+}
@@ -0,0 +1,43 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import "testing"
+
+func TestTimestampAuthorityCertificates(t *testing.T) {
+	tsa := newTestCA(t)
+	intermediate := newTestCA(t)
+	p := Policy{TimestampAuthorities: map[string]Root{
+		"tsa": {Certificate: tsa.pem(), Intermediates: [][]byte{intermediate.pem()}},
+	}}
+
+	roots, intermediates, err := p.TimestampAuthorityCertificates()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(roots) != 1 || !roots[0].Equal(tsa.cert) {
+		t.Errorf("expected the timestamp authority's root, got %v", roots)
+	}
+
+	if len(intermediates) != 1 || !intermediates[0].Equal(intermediate.cert) {
+		t.Errorf("expected the timestamp authority's intermediate, got %v", intermediates)
+	}
+
+	p.TimestampAuthorities["bad"] = Root{Certificate: []byte("not a certificate")}
+	if _, _, err := p.TimestampAuthorityCertificates(); err == nil {
+		t.Error("expected an invalid certificate to fail")
+	}
+}
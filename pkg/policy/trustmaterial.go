@@ -0,0 +1,75 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// TrustMaterial is trust distributed apart from the policy, such as by a TUF repository, under the names policies
+// refer to it by.
+type TrustMaterial struct {
+	Roots                map[string]Root
+	TimestampAuthorities map[string]Root
+	TransparencyLogs     map[string]TransparencyLog
+}
+
+// WithTrustMaterial returns the policy payload with the trust material in place of the policy's own roots, timestamp
+// authorities, and transparency logs of the same names, so rotating the material does not require re-signing the
+// policy. Other fields of the policy are kept as they are.
+func WithTrustMaterial(payload []byte, m TrustMaterial) ([]byte, error) {
+	p := make(map[string]interface{})
+	if err := json.Unmarshal(payload, &p); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal policy: %w", err)
+	}
+
+	for key, entries := range map[string]interface{}{
+		"roots":                m.Roots,
+		"timestampauthorities": m.TimestampAuthorities,
+		"transparencylogs":     m.TransparencyLogs,
+	} {
+		if err := mergeEntries(p, key, entries); err != nil {
+			return nil, err
+		}
+	}
+
+	return json.Marshal(p)
+}
+
+// mergeEntries sets the entries, a map by name, in the policy's object under key, replacing entries of the same names.
+func mergeEntries(p map[string]interface{}, key string, entries interface{}) error {
+	encoded, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+
+	decoded := make(map[string]interface{})
+	if err := json.Unmarshal(encoded, &decoded); err != nil || len(decoded) == 0 {
+		return err
+	}
+
+	existing, ok := p[key].(map[string]interface{})
+	if !ok {
+		existing = make(map[string]interface{})
+	}
+
+	for name, entry := range decoded {
+		existing[name] = entry
+	}
+
+	p[key] = existing
+	return nil
+}
@@ -0,0 +1,61 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWithTrustMaterial(t *testing.T) {
+	payload := []byte(`{
+		"expires": "2030-01-01T00:00:00Z",
+		"roots": {
+			"acme": {"certificate": "b2xk"},
+			"partner": {"certificate": "cGFydG5lcg=="}
+		},
+		"steps": {"build": {"name": "build", "extra": true}}
+	}`)
+
+	updated, err := WithTrustMaterial(payload, TrustMaterial{
+		Roots:                map[string]Root{"acme": {Certificate: []byte("rotated")}},
+		TimestampAuthorities: map[string]Root{"tsa": {Certificate: []byte("tsa")}},
+		TransparencyLogs:     map[string]TransparencyLog{"rekor": {Key: []byte("rekor key")}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := Parse(updated)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !bytes.Equal(p.Roots["acme"].Certificate, []byte("rotated")) {
+		t.Fatalf("expected the acme root to be replaced, got %q", p.Roots["acme"].Certificate)
+	}
+
+	if !bytes.Equal(p.Roots["partner"].Certificate, []byte("partner")) {
+		t.Fatalf("expected the partner root to be kept, got %q", p.Roots["partner"].Certificate)
+	}
+
+	if !bytes.Equal(p.TimestampAuthorities["tsa"].Certificate, []byte("tsa")) || !bytes.Equal(p.TransparencyLogs["rekor"].Key, []byte("rekor key")) {
+		t.Fatalf("expected the timestamp authority and transparency log to be added, got %+v %+v", p.TimestampAuthorities, p.TransparencyLogs)
+	}
+
+	if p.Steps["build"].Name != "build" || !bytes.Contains(updated, []byte(`"extra":true`)) {
+		t.Fatalf("expected the rest of the policy to be kept, got %s", updated)
+	}
+}
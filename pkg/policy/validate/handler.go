@@ -0,0 +1,50 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+)
+
+// MaxPolicySize is the largest policy Handler validates, in bytes.
+const MaxPolicySize = 4 << 20
+
+// Handler returns an http handler that validates the policy in the body of POST requests and responds with the report
+// as json, so web UIs and webhooks can check policies as they are written.
+func Handler(opts ...Option) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "policies must be POSTed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		body, err := io.ReadAll(io.LimitReader(r.Body, MaxPolicySize+1))
+		if err != nil {
+			http.Error(w, "failed to read policy", http.StatusBadRequest)
+			return
+		}
+
+		if len(body) > MaxPolicySize {
+			http.Error(w, "policy is too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(Validate(body, opts...))
+	})
+}
@@ -0,0 +1,166 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var identifierPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_-]*$`)
+
+// field returns the path of the key within the object at parent. Keys that aren't identifiers, such as step names
+// with dots, are quoted in brackets: steps["release.linux"].
+func field(parent, key string) string {
+	if !identifierPattern.MatchString(key) {
+		return fmt.Sprintf("%v[%v]", parent, strconv.Quote(key))
+	}
+
+	if parent == "" {
+		return key
+	}
+
+	return parent + "." + key
+}
+
+// index returns the path of the element within the array at parent.
+func index(parent string, i int) string {
+	return fmt.Sprintf("%v[%d]", parent, i)
+}
+
+// parentPath returns the path of the object or array holding the value at path.
+func parentPath(path string) string {
+	if strings.HasSuffix(path, "\"]") {
+		if i := strings.LastIndex(path, "[\""); i >= 0 {
+			return path[:i]
+		}
+	}
+
+	if strings.HasSuffix(path, "]") {
+		if i := strings.LastIndex(path, "["); i >= 0 {
+			return path[:i]
+		}
+	}
+
+	if i := strings.LastIndex(path, "."); i >= 0 {
+		return path[:i]
+	}
+
+	return ""
+}
+
+// locator maps the paths of the values in a json document to their offsets. Keys given more than once in an object are
+// kept in duplicates, at the offset of their first repetition. Decoding keeps only the last value of such a key.
+type locator struct {
+	data       []byte
+	offsets    map[string]int
+	duplicates map[string]int
+}
+
+func newLocator(data []byte) locator {
+	l := locator{data: data, offsets: map[string]int{}, duplicates: map[string]int{}}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	// the document has already been checked to be valid json, and a partial map still locates what it can
+	_ = l.walk(dec, "")
+	return l
+}
+
+func (l locator) walk(dec *json.Decoder, path string) error {
+	start := l.skip(int(dec.InputOffset()))
+	tok, err := dec.Token()
+	if err != nil {
+		return err
+	}
+
+	l.offsets[path] = start
+	switch tok {
+	case json.Delim('{'):
+		keys := map[string]bool{}
+		for dec.More() {
+			keyStart := l.skip(int(dec.InputOffset()))
+			key, err := dec.Token()
+			if err != nil {
+				return err
+			}
+
+			keyPath := field(path, fmt.Sprint(key))
+			if _, repeated := l.duplicates[keyPath]; keys[keyPath] && !repeated {
+				l.duplicates[keyPath] = keyStart
+			}
+
+			keys[keyPath] = true
+			if err := l.walk(dec, keyPath); err != nil {
+				return err
+			}
+		}
+
+		_, err = dec.Token()
+	case json.Delim('['):
+		for i := 0; dec.More(); i++ {
+			if err := l.walk(dec, index(path, i)); err != nil {
+				return err
+			}
+		}
+
+		_, err = dec.Token()
+	}
+
+	return err
+}
+
+// skip returns the offset of the next token at or after offset, skipping whitespace and the separators the decoder
+// consumes with tokens.
+func (l locator) skip(offset int) int {
+	for offset < len(l.data) {
+		switch l.data[offset] {
+		case ' ', '\t', '\r', '\n', ',', ':':
+			offset++
+		default:
+			return offset
+		}
+	}
+
+	return offset
+}
+
+// position returns the line and column of the value at path, or of its closest ancestor if the value is missing.
+func (l locator) position(path string) (int, int) {
+	for {
+		if offset, ok := l.offsets[path]; ok {
+			return lineColumn(l.data, offset)
+		}
+
+		if path == "" {
+			return 1, 1
+		}
+
+		path = parentPath(path)
+	}
+}
+
+// lineColumn returns the one based line and column of the byte offset.
+func lineColumn(data []byte, offset int) (int, int) {
+	if offset > len(data) {
+		offset = len(data)
+	}
+
+	line := 1 + bytes.Count(data[:offset], []byte("\n"))
+	column := offset - bytes.LastIndexByte(data[:offset], '\n')
+	return line, column
+}
@@ -0,0 +1,189 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate
+
+import (
+	"fmt"
+	"sort"
+)
+
+// node describes the fields of a policy object that witness reads. Fields without a node are values witness doesn't
+// look inside of, such as strings and arrays of strings.
+type node struct {
+	fields map[string]*node
+	// elements describes each element of an array, or each value of an object keyed by names such as step names.
+	elements *node
+}
+
+func object(fields map[string]*node) *node {
+	return &node{fields: fields}
+}
+
+// collection returns a node for an array, or an object keyed by names, whose elements are all the node.
+func collection(elements *node) *node {
+	return &node{elements: elements}
+}
+
+var schema = object(map[string]*node{
+	"expires": nil,
+	"version": nil,
+	"roots": collection(object(map[string]*node{
+		"certificate":   nil,
+		"intermediates": nil,
+	})),
+	"publickeys": collection(object(map[string]*node{
+		"keyid": nil,
+		"key":   nil,
+	})),
+	"timestampauthorities": collection(object(map[string]*node{
+		"certificate":   nil,
+		"intermediates": nil,
+	})),
+	"transparencylogs": collection(object(map[string]*node{
+		"key": nil,
+	})),
+	"steps": collection(object(map[string]*node{
+		"name": nil,
+		"functionaries": collection(object(map[string]*node{
+			"type": nil,
+			"certConstraint": object(map[string]*node{
+				"commonname":    nil,
+				"dnsnames":      nil,
+				"emails":        nil,
+				"organizations": nil,
+				"uris":          nil,
+				"roots":         nil,
+				"issuers":       nil,
+				"oidcIssuers":   nil,
+				"extensions":    nil,
+			}),
+			"publickeyid": nil,
+		})),
+		"threshold": nil,
+		"attestations": collection(object(map[string]*node{
+			"type": nil,
+			"regopolicies": collection(object(map[string]*node{
+				"module": nil,
+				"name":   nil,
+			})),
+		})),
+		"artifactsFrom": nil,
+		"command": object(map[string]*node{
+			"exact": nil,
+			"glob":  nil,
+		}),
+		"forbidden": collection(object(map[string]*node{
+			"type": nil,
+			"conditions": collection(object(map[string]*node{
+				"path":   nil,
+				"equals": nil,
+				"notIn":  nil,
+			})),
+		})),
+		"buildCounter": object(map[string]*node{
+			"source":  nil,
+			"name":    nil,
+			"minimum": nil,
+		}),
+		"approvals": collection(object(map[string]*node{
+			"group": nil,
+			"count": nil,
+		})),
+		"keyAttestation": object(map[string]*node{
+			"roots": nil,
+		}),
+		"sbom": object(map[string]*node{
+			"formats": nil,
+		}),
+		"sbomCompleteness": object(map[string]*node{
+			"minimumScore": nil,
+			"elements":     nil,
+		}),
+		"spiffe": object(map[string]*node{
+			"ids": nil,
+		}),
+		"delegation": object(map[string]*node{
+			"policy": nil,
+			"keys":   nil,
+			"roots":  nil,
+		}),
+		"matrix": object(map[string]*node{
+			"dimensions": nil,
+		}),
+		"baseImage": object(map[string]*node{
+			"policy": nil,
+			"keys":   nil,
+			"roots":  nil,
+		}),
+		"subjects": object(map[string]*node{
+			"attestors": nil,
+		}),
+		"worktree": object(map[string]*node{
+			"allowedFiles": nil,
+		}),
+		"versions": object(map[string]*node{
+			"witness":   nil,
+			"attestors": nil,
+		}),
+		"logInclusion": object(map[string]*node{
+			"logs":      nil,
+			"threshold": nil,
+		}),
+		"rego": collection(object(map[string]*node{
+			"attestation": nil,
+			"name":        nil,
+			"module":      nil,
+		})),
+	})),
+})
+
+// checkFields warns about the fields of the value that witness ignores, which are usually misspelled.
+func (v *validator) checkFields(n *node, value interface{}, path string) {
+	if n == nil {
+		return
+	}
+
+	switch value := value.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(value))
+		for key := range value {
+			keys = append(keys, key)
+		}
+
+		sort.Strings(keys)
+		for _, key := range keys {
+			if n.elements != nil {
+				v.checkFields(n.elements, value[key], field(path, key))
+				continue
+			}
+
+			child, ok := n.fields[key]
+			if !ok {
+				v.warn(field(path, key), fmt.Sprintf("unknown field %v is ignored", key))
+				continue
+			}
+
+			v.checkFields(child, value[key], field(path, key))
+		}
+	case []interface{}:
+		if n.elements == nil {
+			return
+		}
+
+		for i, element := range value {
+			v.checkFields(n.elements, element, index(path, i))
+		}
+	}
+}
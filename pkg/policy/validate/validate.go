@@ -0,0 +1,726 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package validate checks witness policies before they are signed, reporting every problem it finds along with the
+// path and position of the field that caused it. witness policy validate reports the same diagnostics, so editors and
+// web UIs that use this package agree with the CLI.
+package validate
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/open-policy-agent/opa/ast"
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/dsse"
+	gwpolicy "github.com/testifysec/go-witness/policy"
+	"github.com/testifysec/witness/pkg/payload"
+	"github.com/testifysec/witness/pkg/policy"
+	"github.com/testifysec/witness/pkg/version"
+)
+
+type Severity string
+
+const (
+	// SeverityError diagnostics make the policy fail verification, or keep a step from ever being satisfied.
+	SeverityError Severity = "error"
+	// SeverityWarning diagnostics are probably mistakes, but don't stop the policy from being used.
+	SeverityWarning Severity = "warning"
+)
+
+// Diagnostic is a problem found in a policy. Path is the field the problem is with, such as
+// steps.build.functionaries[0].publickeyid, and Line and Column are the one based position of its value. Fields that
+// are missing are positioned at the object that should hold them.
+type Diagnostic struct {
+	Severity Severity `json:"severity"`
+	Path     string   `json:"path"`
+	Line     int      `json:"line"`
+	Column   int      `json:"column"`
+	Message  string   `json:"message"`
+}
+
+func (d Diagnostic) String() string {
+	if d.Path == "" {
+		return fmt.Sprintf("%d:%d: %v: %v", d.Line, d.Column, d.Severity, d.Message)
+	}
+
+	return fmt.Sprintf("%d:%d: %v: %v: %v", d.Line, d.Column, d.Severity, d.Path, d.Message)
+}
+
+// Report holds the diagnostics for a policy, ordered by position.
+type Report struct {
+	// Signed is true if the policy was validated from the payload of a DSSE envelope. Positions are then within the
+	// decoded payload.
+	Signed      bool         `json:"signed"`
+	Diagnostics []Diagnostic `json:"diagnostics"`
+}
+
+// HasErrors returns true if any diagnostic is an error.
+func (r Report) HasErrors() bool {
+	for _, d := range r.Diagnostics {
+		if d.Severity == SeverityError {
+			return true
+		}
+	}
+
+	return false
+}
+
+type Option func(*options)
+
+type options struct {
+	now time.Time
+}
+
+// WithTime sets the time the policy's expiration is checked against. Defaults to now.
+func WithTime(now time.Time) Option {
+	return func(o *options) {
+		o.now = now
+	}
+}
+
+type validator struct {
+	now     time.Time
+	locator locator
+	report  Report
+	seen    map[string]bool
+}
+
+// Validate checks the policy json, or a DSSE envelope holding it, and returns every problem it finds. Signatures are
+// not verified.
+func Validate(data []byte, opts ...Option) Report {
+	o := options{now: time.Now()}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	v := &validator{now: o.now, locator: locator{data: data, offsets: map[string]int{}}, seen: map[string]bool{}}
+	v.report.Diagnostics = make([]Diagnostic, 0)
+	if !v.checkSyntax(data) {
+		return v.report
+	}
+
+	env := dsse.Envelope{}
+	if err := json.Unmarshal(data, &env); err == nil && env.PayloadType != "" && len(env.Payload) > 0 {
+		v.report.Signed = true
+		if env.PayloadType != payload.PolicyType {
+			v.error("payloadType", fmt.Sprintf("envelope payload type %v is not %v", env.PayloadType, payload.PolicyType))
+			return v.report
+		}
+
+		data = env.Payload
+		v.locator = locator{data: data, offsets: map[string]int{}}
+		if !v.checkSyntax(data) {
+			return v.report
+		}
+	}
+
+	v.locator = newLocator(data)
+	v.validate(data)
+	sort.SliceStable(v.report.Diagnostics, func(i, j int) bool {
+		a, b := v.report.Diagnostics[i], v.report.Diagnostics[j]
+		return a.Line < b.Line || (a.Line == b.Line && a.Column < b.Column)
+	})
+
+	return v.report
+}
+
+func (v *validator) add(severity Severity, path, message string) {
+	if v.seen[path+message] {
+		return
+	}
+
+	v.seen[path+message] = true
+	line, column := v.locator.position(path)
+	v.report.Diagnostics = append(v.report.Diagnostics, Diagnostic{
+		Severity: severity,
+		Path:     path,
+		Line:     line,
+		Column:   column,
+		Message:  message,
+	})
+}
+
+func (v *validator) error(path, message string) {
+	v.add(SeverityError, path, message)
+}
+
+func (v *validator) warn(path, message string) {
+	v.add(SeverityWarning, path, message)
+}
+
+// addAt adds an error positioned at a byte offset rather than at a path, for errors from the json decoder.
+func (v *validator) addAt(offset int64, path, message string) {
+	if v.seen[path+message] {
+		return
+	}
+
+	v.seen[path+message] = true
+	line, column := lineColumn(v.locator.data, int(offset))
+	v.report.Diagnostics = append(v.report.Diagnostics, Diagnostic{
+		Severity: SeverityError,
+		Path:     path,
+		Line:     line,
+		Column:   column,
+		Message:  message,
+	})
+}
+
+func (v *validator) checkSyntax(data []byte) bool {
+	var doc interface{}
+	err := json.Unmarshal(data, &doc)
+	if err == nil {
+		return true
+	}
+
+	syntaxErr := &json.SyntaxError{}
+	if errors.As(err, &syntaxErr) {
+		v.addAt(syntaxErr.Offset, "", fmt.Sprintf("invalid json: %v", syntaxErr))
+	} else {
+		v.addAt(int64(len(data)), "", fmt.Sprintf("invalid json: %v", err))
+	}
+
+	return false
+}
+
+// checkDecode reports the type errors from decoding the policy. Decoding continues past type errors, so the rest of
+// the policy can still be checked.
+func (v *validator) checkDecode(err error) {
+	if err == nil {
+		return
+	}
+
+	typeErr := &json.UnmarshalTypeError{}
+	if errors.As(err, &typeErr) {
+		v.addAt(typeErr.Offset, typeErr.Field, fmt.Sprintf("expected %v, got %v", typeErr.Type, typeErr.Value))
+		return
+	}
+
+	v.error("", err.Error())
+}
+
+// document holds the fields go-witness reads from a policy. Expires is kept raw so a malformed time doesn't stop the
+// rest of the policy from being decoded.
+type document struct {
+	Expires    json.RawMessage               `json:"expires"`
+	Roots      map[string]gwpolicy.Root      `json:"roots"`
+	PublicKeys map[string]gwpolicy.PublicKey `json:"publickeys"`
+	Steps      map[string]gwpolicy.Step      `json:"steps"`
+
+	TimestampAuthorities map[string]gwpolicy.Root          `json:"timestampauthorities"`
+	TransparencyLogs     map[string]policy.TransparencyLog `json:"transparencylogs"`
+}
+
+func (v *validator) validate(data []byte) {
+	var raw interface{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		v.error("", err.Error())
+		return
+	}
+
+	if _, ok := raw.(map[string]interface{}); !ok {
+		v.error("", "policy must be a json object")
+		return
+	}
+
+	v.checkFields(schema, raw, "")
+	v.checkDuplicates()
+
+	doc := document{}
+	v.checkDecode(json.Unmarshal(data, &doc))
+	p := policy.Policy{}
+	v.checkDecode(json.Unmarshal(data, &p))
+
+	v.checkExpires(doc.Expires)
+	v.checkRoots("roots", doc.Roots)
+	v.checkRoots("timestampauthorities", doc.TimestampAuthorities)
+	v.checkPublicKeys(doc.PublicKeys)
+	v.checkTransparencyLogs(doc.TransparencyLogs)
+
+	if len(doc.Steps) == 0 {
+		v.error("steps", "policy has no steps")
+	}
+
+	names := make([]string, 0, len(doc.Steps))
+	for name := range doc.Steps {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	for _, name := range names {
+		v.checkStep(field("steps", name), name, doc, doc.Steps[name], p.Steps[name])
+	}
+}
+
+// checkDuplicates reports keys given more than once in an object, such as two steps with the same name, since only the
+// last of them is used.
+func (v *validator) checkDuplicates() {
+	for _, path := range sortedKeys(v.locator.duplicates) {
+		if parentPath(path) == "steps" {
+			v.addAt(int64(v.locator.duplicates[path]), path, "step is defined more than once, so only its last definition is used")
+		} else {
+			v.addAt(int64(v.locator.duplicates[path]), path, "key is given more than once, so only its last value is used")
+		}
+	}
+}
+
+func (v *validator) checkExpires(raw json.RawMessage) {
+	if len(raw) == 0 {
+		v.error("expires", "policy has no expiration")
+		return
+	}
+
+	expires := time.Time{}
+	if err := json.Unmarshal(raw, &expires); err != nil {
+		v.error("expires", fmt.Sprintf("expiration is not an RFC 3339 time: %v", err))
+		return
+	}
+
+	if expires.Before(v.now) {
+		v.warn("expires", fmt.Sprintf("policy expired at %v and will fail verification", expires.Format(time.RFC3339)))
+	}
+}
+
+func (v *validator) checkRoots(rootsPath string, roots map[string]gwpolicy.Root) {
+	for _, id := range sortedKeys(roots) {
+		root := field(rootsPath, id)
+		if err := checkCertificate(roots[id].Certificate); err != nil {
+			v.error(field(root, "certificate"), err.Error())
+		}
+
+		for i, intermediate := range roots[id].Intermediates {
+			if err := checkCertificate(intermediate); err != nil {
+				v.error(index(field(root, "intermediates"), i), err.Error())
+			}
+		}
+	}
+}
+
+func checkCertificate(data []byte) error {
+	if len(data) == 0 {
+		return fmt.Errorf("certificate is empty")
+	}
+
+	cert, err := dsse.TryParseCertificate(data)
+	if err != nil {
+		return fmt.Errorf("failed to parse certificate: %v", err)
+	}
+
+	if cert == nil {
+		return fmt.Errorf("not a certificate")
+	}
+
+	return nil
+}
+
+func (v *validator) checkPublicKeys(keys map[string]gwpolicy.PublicKey) {
+	for _, id := range sortedKeys(keys) {
+		key := field("publickeys", id)
+		verifier, err := cryptoutil.NewVerifierFromReader(bytes.NewReader(keys[id].Key))
+		if err != nil {
+			v.error(field(key, "key"), fmt.Sprintf("failed to parse public key: %v", err))
+			continue
+		}
+
+		keyID, err := verifier.KeyID()
+		if err != nil {
+			v.error(field(key, "key"), fmt.Sprintf("failed to compute key id: %v", err))
+			continue
+		}
+
+		if keys[id].KeyID != keyID {
+			v.error(field(key, "keyid"), fmt.Sprintf("key id %v does not match the key's id %v", keys[id].KeyID, keyID))
+		} else if id != keyID {
+			v.warn(key, fmt.Sprintf("key is listed under %v rather than its key id %v", id, keyID))
+		}
+	}
+}
+
+func (v *validator) checkTransparencyLogs(logs map[string]policy.TransparencyLog) {
+	for _, name := range sortedKeys(logs) {
+		if _, err := cryptoutil.NewVerifierFromReader(bytes.NewReader(logs[name].Key)); err != nil {
+			v.error(field(field("transparencylogs", name), "key"), fmt.Sprintf("failed to parse public key: %v", err))
+		}
+	}
+}
+
+func (v *validator) checkStep(stepPath, name string, doc document, step gwpolicy.Step, constraints policy.Step) {
+	if step.Name != name {
+		v.error(field(stepPath, "name"), fmt.Sprintf("step name %q does not match its key %q, so no collection can satisfy it", step.Name, name))
+	}
+
+	if constraints.Delegation != nil {
+		v.checkDelegation(field(stepPath, "delegation"), doc, *constraints.Delegation)
+	} else if len(step.Functionaries) == 0 {
+		v.error(field(stepPath, "functionaries"), "step has no functionaries, so no collection can satisfy it")
+	}
+
+	for i, functionary := range step.Functionaries {
+		v.checkFunctionary(index(field(stepPath, "functionaries"), i), doc, functionary)
+	}
+
+	if constraints.Delegation == nil {
+		v.checkThreshold(field(stepPath, "threshold"), step.Functionaries, constraints.Threshold)
+	}
+
+	types := map[string]bool{}
+	for i, attestation := range step.Attestations {
+		attestationPath := index(field(stepPath, "attestations"), i)
+		if attestation.Type == "" {
+			v.error(field(attestationPath, "type"), "attestation has no type")
+		} else if types[attestation.Type] {
+			v.warn(field(attestationPath, "type"), fmt.Sprintf("attestation %v is listed more than once", attestation.Type))
+		}
+
+		types[attestation.Type] = true
+		for j, rego := range attestation.RegoPolicies {
+			v.checkRego(index(field(attestationPath, "regopolicies"), j), rego)
+		}
+	}
+
+	for i, from := range step.ArtifactsFrom {
+		fromPath := index(field(stepPath, "artifactsFrom"), i)
+		if from == name {
+			v.error(fromPath, "step can not use artifacts from itself")
+		} else if _, ok := doc.Steps[from]; !ok {
+			v.error(fromPath, fmt.Sprintf("step %v is not in the policy", from))
+		}
+	}
+
+	v.checkConstraints(stepPath, doc, constraints)
+}
+
+// checkThreshold reports thresholds that are negative or need more distinct functionaries than the step trusts.
+// Certificate functionaries are satisfied by any number of identities, so only steps that trust public keys alone can
+// be found to never meet their threshold.
+func (v *validator) checkThreshold(thresholdPath string, functionaries []gwpolicy.Functionary, threshold int) {
+	if threshold < 0 {
+		v.error(thresholdPath, "threshold can not be negative")
+		return
+	}
+
+	keys := map[string]bool{}
+	for _, functionary := range functionaries {
+		if functionary.Type != "publickey" {
+			return
+		}
+
+		keys[functionary.PublicKeyID] = true
+	}
+
+	if threshold > len(keys) {
+		v.error(thresholdPath, fmt.Sprintf("threshold of %d is more than the step's %d public key functionaries, so no collection can satisfy it", threshold, len(keys)))
+	}
+}
+
+func (v *validator) checkFunctionary(functionaryPath string, doc document, functionary gwpolicy.Functionary) {
+	if functionary.PublicKeyID != "" {
+		if !hasKeyID(doc.PublicKeys, functionary.PublicKeyID) {
+			v.error(field(functionaryPath, "publickeyid"), fmt.Sprintf("public key %v is not in the policy's publickeys", functionary.PublicKeyID))
+		}
+
+		return
+	}
+
+	roots := field(field(functionaryPath, "certConstraint"), "roots")
+	if len(functionary.CertConstraint.Roots) == 0 {
+		v.error(roots, "functionary has no publickeyid or certConstraint roots, so it trusts no signer")
+		return
+	}
+
+	if len(functionary.CertConstraint.Roots) == 1 && functionary.CertConstraint.Roots[0] == gwpolicy.AllowAllConstraint {
+		if len(doc.Roots) == 0 {
+			v.error(roots, "functionary trusts all roots, but the policy has none")
+		}
+
+		return
+	}
+
+	v.checkRootRefs(roots, doc, functionary.CertConstraint.Roots)
+}
+
+func (v *validator) checkRootRefs(rootsPath string, doc document, roots []string) {
+	for i, root := range roots {
+		if _, ok := doc.Roots[root]; !ok {
+			v.error(index(rootsPath, i), fmt.Sprintf("root %v is not in the policy's roots", root))
+		}
+	}
+}
+
+func hasKeyID(keys map[string]gwpolicy.PublicKey, keyID string) bool {
+	for id, key := range keys {
+		if id == keyID || key.KeyID == keyID {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (v *validator) checkRego(regoPath string, rego gwpolicy.RegoPolicy) {
+	if len(rego.Module) == 0 {
+		v.error(field(regoPath, "module"), "rego policy has no module")
+		return
+	}
+
+	module, err := ast.ParseModule(rego.Name, string(rego.Module))
+	if err != nil {
+		v.error(field(regoPath, "module"), fmt.Sprintf("failed to parse rego module: %v", err))
+		return
+	}
+
+	if module == nil {
+		v.error(field(regoPath, "module"), "rego module is empty")
+		return
+	}
+
+	for _, rule := range module.Rules {
+		if rule.Head.Name.String() == "deny" {
+			return
+		}
+	}
+
+	v.warn(field(regoPath, "module"), fmt.Sprintf("rego package %v has no deny rule, so it never rejects a collection", module.Package.Path))
+}
+
+func (v *validator) checkDelegation(delegationPath string, doc document, delegation policy.DelegationConstraint) {
+	v.checkPolicyReference(delegationPath, doc, "delegation", delegation.Policy, delegation.Keys, delegation.Roots)
+}
+
+// checkPolicyReference checks a constraint that references another policy, which must be signed by one of the named
+// keys or roots of this policy.
+func (v *validator) checkPolicyReference(refPath string, doc document, constraint, policyRef string, keys, roots []string) {
+	if policyRef == "" {
+		v.error(field(refPath, "policy"), fmt.Sprintf("%v has no policy", constraint))
+	}
+
+	if len(keys) == 0 && len(roots) == 0 {
+		v.error(refPath, fmt.Sprintf("%v does not name any keys or roots to verify the referenced policy with", constraint))
+	}
+
+	for i, key := range keys {
+		if _, ok := doc.PublicKeys[key]; !ok {
+			v.error(index(field(refPath, "keys"), i), fmt.Sprintf("public key %v is not in the policy's publickeys", key))
+		}
+	}
+
+	v.checkRootRefs(field(refPath, "roots"), doc, roots)
+}
+
+func (v *validator) checkIdentityConstraints(constraintPath string, c policy.CertConstraint) {
+	patterns := map[string][]string{"issuers": c.Issuers, "oidcIssuers": c.OIDCIssuers}
+	for _, key := range []string{"issuers", "oidcIssuers"} {
+		for i, pattern := range patterns[key] {
+			if _, err := path.Match(pattern, ""); err != nil {
+				v.error(index(field(constraintPath, key), i), fmt.Sprintf("invalid pattern %v: %v", pattern, err))
+			}
+		}
+	}
+
+	for oid, pattern := range c.Extensions {
+		extensionPath := field(field(constraintPath, "extensions"), oid)
+		if _, err := policy.ParseOID(oid); err != nil {
+			v.error(extensionPath, err.Error())
+		} else if _, err := path.Match(pattern, ""); err != nil {
+			v.error(extensionPath, fmt.Sprintf("invalid pattern %v: %v", pattern, err))
+		}
+	}
+}
+
+func (v *validator) checkConstraints(stepPath string, doc document, s policy.Step) {
+	if s.Command != nil {
+		commandPath := field(stepPath, "command")
+		if len(s.Command.Exact) == 0 && len(s.Command.Glob) == 0 {
+			v.warn(commandPath, "command constraint has no exact or glob, so it allows any command")
+		}
+
+		for i, pattern := range s.Command.Glob {
+			if _, err := path.Match(pattern, ""); err != nil {
+				v.error(index(field(commandPath, "glob"), i), fmt.Sprintf("invalid pattern %v: %v", pattern, err))
+			}
+		}
+	}
+
+	for i, forbidden := range s.Forbidden {
+		forbiddenPath := index(field(stepPath, "forbidden"), i)
+		if forbidden.Type == "" {
+			v.error(field(forbiddenPath, "type"), "forbidden attestation has no type")
+		}
+
+		for j, condition := range forbidden.Conditions {
+			if condition.Path == "" {
+				v.error(field(index(field(forbiddenPath, "conditions"), j), "path"), "condition has no path")
+			}
+		}
+	}
+
+	for i, approval := range s.Approvals {
+		approvalPath := index(field(stepPath, "approvals"), i)
+		if approval.Group == "" {
+			v.error(field(approvalPath, "group"), "approval has no group")
+		}
+
+		if approval.Count < 0 {
+			v.error(field(approvalPath, "count"), "approval count can not be negative")
+		}
+	}
+
+	if s.KeyAttestation != nil {
+		rootsPath := field(field(stepPath, "keyAttestation"), "roots")
+		if len(s.KeyAttestation.Roots) == 0 {
+			v.error(rootsPath, "key attestation constraint does not name any roots")
+		}
+
+		v.checkRootRefs(rootsPath, doc, s.KeyAttestation.Roots)
+	}
+
+	if s.SBOM != nil {
+		for i, format := range s.SBOM.Formats {
+			if format != "spdx" && format != "cyclonedx" {
+				v.error(index(field(field(stepPath, "sbom"), "formats"), i), fmt.Sprintf("unknown sbom format %v, expected spdx or cyclonedx", format))
+			}
+		}
+	}
+
+	if s.SBOMCompleteness != nil {
+		sbomPath := field(stepPath, "sbomCompleteness")
+		if s.SBOMCompleteness.MinimumScore < 0 || s.SBOMCompleteness.MinimumScore > 100 {
+			v.error(field(sbomPath, "minimumScore"), "minimum score must be between 0 and 100")
+		}
+
+		for _, element := range sortedKeys(s.SBOMCompleteness.Elements) {
+			if completeness := s.SBOMCompleteness.Elements[element]; completeness < 0 || completeness > 1 {
+				v.error(field(field(sbomPath, "elements"), element), "element completeness must be between 0 and 1")
+			}
+		}
+	}
+
+	if s.SPIFFE != nil {
+		idsPath := field(field(stepPath, "spiffe"), "ids")
+		if len(s.SPIFFE.IDs) == 0 {
+			v.error(idsPath, "spiffe constraint does not list any ids")
+		}
+
+		for i, pattern := range s.SPIFFE.IDs {
+			if _, err := policy.MatchSPIFFEID(pattern, "spiffe://example.org"); err != nil {
+				v.error(index(idsPath, i), err.Error())
+			}
+		}
+	}
+
+	for i, functionary := range s.Functionaries {
+		v.checkIdentityConstraints(field(index(field(stepPath, "functionaries"), i), "certConstraint"), functionary.CertConstraint)
+	}
+
+	if s.Matrix != nil {
+		if _, err := s.Matrix.Variants(); err != nil {
+			v.error(field(field(stepPath, "matrix"), "dimensions"), err.Error())
+		}
+	}
+
+	if s.BaseImage != nil {
+		v.checkPolicyReference(field(stepPath, "baseImage"), doc, "base image constraint", s.BaseImage.Policy, s.BaseImage.Keys, s.BaseImage.Roots)
+	}
+
+	if s.Subjects != nil && len(s.Subjects.Attestors) == 0 {
+		v.error(field(field(stepPath, "subjects"), "attestors"), "subject constraint does not list any attestors")
+	}
+
+	if s.Worktree != nil {
+		for i, pattern := range s.Worktree.AllowedFiles {
+			if _, err := path.Match(pattern, ""); err != nil {
+				v.error(index(field(field(stepPath, "worktree"), "allowedFiles"), i), fmt.Sprintf("invalid pattern %v: %v", pattern, err))
+			}
+		}
+	}
+
+	if s.Versions != nil {
+		v.checkVersions(field(stepPath, "versions"), *s.Versions)
+	}
+
+	if s.LogInclusion != nil {
+		v.checkLogInclusion(field(stepPath, "logInclusion"), doc, *s.LogInclusion)
+	}
+
+	for i, r := range s.Rego {
+		regoPath := index(field(stepPath, "rego"), i)
+		v.checkRego(regoPath, gwpolicy.RegoPolicy{Name: r.Name, Module: r.Module})
+	}
+}
+
+func (v *validator) checkVersions(versionsPath string, c policy.VersionConstraint) {
+	if c.Witness == "" && len(c.Attestors) == 0 {
+		v.warn(versionsPath, "version constraint does not require any versions")
+	}
+
+	if c.Witness != "" {
+		if _, err := version.Parse(c.Witness); err != nil {
+			v.error(field(versionsPath, "witness"), err.Error())
+		}
+	}
+
+	for _, attestorType := range sortedKeys(c.Attestors) {
+		attestorPath := field(field(versionsPath, "attestors"), attestorType)
+		if base, _, ok := version.SplitType(attestorType); ok {
+			v.error(attestorPath, fmt.Sprintf("attestors are named by their type without its version, such as %v", base))
+		}
+
+		if _, err := version.Parse(c.Attestors[attestorType]); err != nil {
+			v.error(attestorPath, err.Error())
+		}
+	}
+}
+
+// checkLogInclusion reports logs that aren't in the policy and thresholds higher than the number of logs the constraint
+// counts, which no collection could meet.
+func (v *validator) checkLogInclusion(inclusionPath string, doc document, c policy.LogInclusionConstraint) {
+	logs := len(doc.TransparencyLogs)
+	if len(c.Logs) > 0 {
+		logs = len(c.Logs)
+	}
+
+	if logs == 0 {
+		v.error(inclusionPath, "log inclusion constraint has no transparency logs to require, since the policy has none")
+	}
+
+	for i, name := range c.Logs {
+		if _, ok := doc.TransparencyLogs[name]; !ok {
+			v.error(index(field(inclusionPath, "logs"), i), fmt.Sprintf("transparency log %v is not in the policy's transparencylogs", name))
+		}
+	}
+
+	thresholdPath := field(inclusionPath, "threshold")
+	if c.Threshold < 0 {
+		v.error(thresholdPath, "threshold can not be negative")
+	} else if logs > 0 && c.Threshold > logs {
+		v.error(thresholdPath, fmt.Sprintf("threshold of %d is more than the constraint's %d transparency logs, so no collection can satisfy it", c.Threshold, logs))
+	}
+}
+
+// sortedKeys returns the keys of a map with string keys, sorted so diagnostics are reported in a stable order.
+func sortedKeys(m interface{}) []string {
+	keys := make([]string, 0)
+	for _, key := range reflect.ValueOf(m).MapKeys() {
+		keys = append(keys, key.String())
+	}
+
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,295 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package validate
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/dsse"
+	"github.com/testifysec/witness/pkg/payload"
+)
+
+var testNow = time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC)
+
+func testKey(t *testing.T) (string, string) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	verifier := cryptoutil.NewECDSAVerifier(&priv.PublicKey, crypto.SHA256)
+	keyID, err := verifier.KeyID()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pem, err := verifier.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return keyID, base64.StdEncoding.EncodeToString(pem)
+}
+
+func testPolicy(keyID, key, steps string) string {
+	return fmt.Sprintf(`{
+  "expires": "2023-01-01T00:00:00Z",
+  "publickeys": {
+    %q: {"keyid": %q, "key": %q}
+  },
+  "steps": {%v}
+}`, keyID, keyID, key, steps)
+}
+
+func find(report Report, path string) (Diagnostic, bool) {
+	for _, d := range report.Diagnostics {
+		if d.Path == path {
+			return d, true
+		}
+	}
+
+	return Diagnostic{}, false
+}
+
+func TestValidPolicy(t *testing.T) {
+	keyID, key := testKey(t)
+	rego := base64.StdEncoding.EncodeToString([]byte("package cmd\n\ndeny[msg] {\n  input.exitcode != 0\n  msg := \"failed\"\n}\n"))
+	steps := fmt.Sprintf(`
+    "build": {
+      "name": "build",
+      "functionaries": [{"type": "publickey", "publickeyid": %q}],
+      "attestations": [{"type": "https://witness.dev/attestations/command-run/v0.1", "regopolicies": [{"name": "exit", "module": %q}]}],
+      "command": {"glob": ["make", "release-*"]},
+      "matrix": {"dimensions": {"os": ["linux", "darwin"]}},
+      "baseImage": {"policy": "https://example.com/base-policy.json", "keys": [%q]},
+      "sbom": {"formats": ["spdx", "cyclonedx"]},
+      "worktree": {"allowedFiles": [".ci/*"]},
+      "versions": {"witness": "v0.1.12", "attestors": {"https://witness.dev/attestations/command-run": "v0.1"}},
+      "logInclusion": {"logs": ["public"], "threshold": 1},
+      "threshold": 1,
+      "rego": [{"attestation": "https://witness.dev/attestations/command-run/v0.1", "name": "exit", "module": %q}]
+    }`, keyID, rego, keyID, rego)
+
+	policy := strings.Replace(testPolicy(keyID, key, steps), "{\n", fmt.Sprintf("{\n  \"transparencylogs\": {\"public\": {\"key\": %q}},\n", key), 1)
+	report := Validate([]byte(policy), WithTime(testNow))
+	if len(report.Diagnostics) != 0 || report.HasErrors() {
+		t.Errorf("expected no diagnostics, got %v", report.Diagnostics)
+	}
+}
+
+func TestSyntaxError(t *testing.T) {
+	report := Validate([]byte("{\n  \"expires\": \"2023-01-01T00:00:00Z\",\n  \"steps\": {,}\n}"))
+	if !report.HasErrors() || len(report.Diagnostics) != 1 {
+		t.Fatalf("expected a single syntax error, got %v", report.Diagnostics)
+	}
+
+	if d := report.Diagnostics[0]; d.Line != 3 || !strings.Contains(d.Message, "invalid json") {
+		t.Errorf("unexpected syntax diagnostic: %v", d)
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	keyID, key := testKey(t)
+	steps := `
+    "build": {
+      "name": "build",
+      "functionaries": [{"type": "publickey", "publickeyid": "unknown"}],
+      "artifactsFrom": ["checkout"],
+      "comand": {"exact": ["make"]},
+      "command": {"glob": ["[make"]},
+      "spiffe": {"ids": ["https://corp/ci"]},
+      "matrix": {"dimensions": {}},
+      "baseImage": {"policy": "base-policy.json", "keys": ["missing"]},
+      "subjects": {"attestors": []},
+      "sbom": {"formats": ["swid"]},
+      "worktree": {"allowedFiles": ["[ci"]},
+      "versions": {"witness": "dev", "attestors": {"https://example.com/build/v1": "v0.3"}},
+      "logInclusion": {"logs": ["internal"], "threshold": 3},
+      "threshold": 2,
+      "rego": [{"name": "clean", "module": "cGFja2FnZSBnaXQ="}]
+    },
+    "release.linux": {
+      "name": "release",
+      "functionaries": [{"type": "root", "certConstraint": {"roots": ["missing"], "oidcIssuers": ["[bad"], "extensions": {"1.x": "*"}}}],
+      "attestations": [{"type": "https://witness.dev/attestations/git/v0.1", "regopolicies": [{"name": "bad", "module": "cGFja2FnZQ=="}]}]
+    }`
+
+	report := Validate([]byte(testPolicy(keyID, key, steps)), WithTime(testNow.AddDate(2, 0, 0)))
+	expected := map[string]Severity{
+		"expires": SeverityWarning,
+		"steps.build.functionaries[0].publickeyid":                                 SeverityError,
+		"steps.build.artifactsFrom[0]":                                             SeverityError,
+		"steps.build.comand":                                                       SeverityWarning,
+		"steps.build.command.glob[0]":                                              SeverityError,
+		"steps.build.spiffe.ids[0]":                                                SeverityError,
+		"steps.build.matrix.dimensions":                                            SeverityError,
+		"steps.build.baseImage.keys[0]":                                            SeverityError,
+		"steps.build.subjects.attestors":                                           SeverityError,
+		"steps.build.sbom.formats[0]":                                              SeverityError,
+		"steps.build.worktree.allowedFiles[0]":                                     SeverityError,
+		"steps.build.versions.witness":                                             SeverityError,
+		`steps.build.versions.attestors["https://example.com/build/v1"]`:           SeverityError,
+		"steps.build.logInclusion.logs[0]":                                         SeverityError,
+		"steps.build.logInclusion.threshold":                                       SeverityError,
+		"steps.build.threshold":                                                    SeverityError,
+		"steps.build.rego[0].module":                                               SeverityWarning,
+		`steps["release.linux"].name`:                                              SeverityError,
+		`steps["release.linux"].functionaries[0].certConstraint.roots[0]`:          SeverityError,
+		`steps["release.linux"].functionaries[0].certConstraint.oidcIssuers[0]`:    SeverityError,
+		`steps["release.linux"].functionaries[0].certConstraint.extensions["1.x"]`: SeverityError,
+		`steps["release.linux"].attestations[0].regopolicies[0].module`:            SeverityError,
+	}
+
+	for path, severity := range expected {
+		d, ok := find(report, path)
+		if !ok {
+			t.Errorf("expected a diagnostic for %v, got %v", path, report.Diagnostics)
+			continue
+		}
+
+		if d.Severity != severity {
+			t.Errorf("expected %v diagnostic for %v, got %v", severity, path, d)
+		}
+	}
+
+	if len(report.Diagnostics) != len(expected) {
+		t.Errorf("expected %v diagnostics, got %v", len(expected), report.Diagnostics)
+	}
+
+	if d, _ := find(report, "steps.build.comand"); d.Line != 11 || d.Column != 17 {
+		t.Errorf("expected unknown field at 11:17, got %v", d)
+	}
+
+	for i := 1; i < len(report.Diagnostics); i++ {
+		if report.Diagnostics[i].Line < report.Diagnostics[i-1].Line {
+			t.Errorf("expected diagnostics ordered by position: %v", report.Diagnostics)
+		}
+	}
+}
+
+func TestTimestampAuthorities(t *testing.T) {
+	keyID, key := testKey(t)
+	steps := fmt.Sprintf(`"build": {"name": "build", "functionaries": [{"type": "publickey", "publickeyid": %q}]}`, keyID)
+	policy := strings.Replace(testPolicy(keyID, key, steps), "{\n", "{\n  \"timestampauthorities\": {\"tsa\": {\"certificate\": \"bm90IGEgY2VydA==\"}},\n", 1)
+
+	report := Validate([]byte(policy), WithTime(testNow))
+	d, ok := find(report, "timestampauthorities.tsa.certificate")
+	if !ok || d.Severity != SeverityError || d.Line != 2 {
+		t.Errorf("expected an invalid timestamp authority certificate on line 2, got %v", report.Diagnostics)
+	}
+
+	if len(report.Diagnostics) != 1 {
+		t.Errorf("expected 1 diagnostic, got %v", report.Diagnostics)
+	}
+}
+
+func TestTransparencyLogs(t *testing.T) {
+	keyID, key := testKey(t)
+	steps := fmt.Sprintf(`"build": {"name": "build", "functionaries": [{"type": "publickey", "publickeyid": %q}], "logInclusion": {}}`, keyID)
+	policy := strings.Replace(testPolicy(keyID, key, steps), "{\n", "{\n  \"transparencylogs\": {\"rekor\": {\"key\": \"bm90IGEga2V5\"}},\n", 1)
+
+	report := Validate([]byte(policy), WithTime(testNow))
+	d, ok := find(report, "transparencylogs.rekor.key")
+	if !ok || d.Severity != SeverityError || d.Line != 2 {
+		t.Errorf("expected an invalid transparency log key on line 2, got %v", report.Diagnostics)
+	}
+
+	if len(report.Diagnostics) != 1 {
+		t.Errorf("expected 1 diagnostic, got %v", report.Diagnostics)
+	}
+
+	report = Validate([]byte(testPolicy(keyID, key, steps)), WithTime(testNow))
+	if d, ok := find(report, "steps.build.logInclusion"); !ok || d.Severity != SeverityError {
+		t.Errorf("expected a log inclusion constraint without transparency logs to be an error, got %v", report.Diagnostics)
+	}
+}
+
+func TestDuplicateSteps(t *testing.T) {
+	keyID, key := testKey(t)
+	step := fmt.Sprintf(`"build": {"name": "build", "functionaries": [{"type": "publickey", "publickeyid": %q}]}`, keyID)
+	policy := testPolicy(keyID, key, "\n    "+step+",\n    "+step+"\n  ")
+	policy = strings.Replace(policy, `"expires"`, `"expires": "2022-01-01T00:00:00Z",
+  "expires"`, 1)
+
+	report := Validate([]byte(policy), WithTime(testNow))
+	d, ok := find(report, "steps.build")
+	if !ok || d.Severity != SeverityError || d.Line != 9 || d.Column != 5 || !strings.Contains(d.Message, "defined more than once") {
+		t.Errorf("expected a duplicate step at 9:5, got %v", report.Diagnostics)
+	}
+
+	if d, ok := find(report, "expires"); !ok || d.Line != 3 || !strings.Contains(d.Message, "given more than once") {
+		t.Errorf("expected a duplicate expiration on line 3, got %v", report.Diagnostics)
+	}
+
+	if len(report.Diagnostics) != 2 {
+		t.Errorf("expected 2 diagnostics, got %v", report.Diagnostics)
+	}
+}
+
+func TestMissingFields(t *testing.T) {
+	report := Validate([]byte("{\n  \"steps\": {\n    \"build\": {\"name\": \"build\"}\n  }\n}"))
+	if d, ok := find(report, "expires"); !ok || d.Line != 1 || d.Column != 1 {
+		t.Errorf("expected missing expiration at the policy object, got %v", report.Diagnostics)
+	}
+
+	if d, ok := find(report, "steps.build.functionaries"); !ok || d.Line != 3 {
+		t.Errorf("expected missing functionaries at the step, got %v", report.Diagnostics)
+	}
+
+	report = Validate([]byte(`{"expires": "2023-01-01T00:00:00Z", "steps": {"build": {"name": 5}}}`), WithTime(testNow))
+	typeErrors := 0
+	for _, d := range report.Diagnostics {
+		if d.Path == "steps.build.name" && strings.Contains(d.Message, "expected string") {
+			typeErrors++
+		}
+	}
+
+	if typeErrors != 1 {
+		t.Errorf("expected one type error for the step name, got %v", report.Diagnostics)
+	}
+}
+
+func TestSignedPolicy(t *testing.T) {
+	keyID, key := testKey(t)
+	steps := fmt.Sprintf(`"build": {"name": "build", "functionaries": [{"publickeyid": %q}]}`, keyID)
+	env, err := json.Marshal(dsse.Envelope{PayloadType: payload.PolicyType, Payload: []byte(testPolicy(keyID, key, steps))})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report := Validate(env, WithTime(testNow))
+	if !report.Signed || len(report.Diagnostics) != 0 {
+		t.Errorf("expected signed policy without diagnostics, got %+v", report)
+	}
+
+	env, err = json.Marshal(dsse.Envelope{PayloadType: "application/vnd.in-toto+json", Payload: []byte("{}")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if report := Validate(env); !report.HasErrors() {
+		t.Error("expected envelope of another payload type to fail")
+	}
+}
@@ -0,0 +1,123 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/testifysec/witness/pkg/version"
+)
+
+const VersionsType = "https://witness.dev/attestations/versions/v0.1"
+
+// VersionConstraint requires a collection to have been signed by witness Witness or newer, and each attestor in
+// Attestors to have recorded at least its schema version, so a collection recorded by an old client that lacks a
+// check can not satisfy the step. Attestors are keyed by their type without its version, such as
+// https://witness.dev/attestations/command-run, and their schema versions are read from the end of the types of the
+// collection's attestations. The witness version is read from the versions attestation every collection records,
+// so collections signed by releases that did not record it, or by development builds, fail a Witness constraint.
+type VersionConstraint struct {
+	Witness   string            `json:"witness,omitempty"`
+	Attestors map[string]string `json:"attestors,omitempty"`
+}
+
+// Verify checks the versions recorded in the collection against the constraint.
+func (c VersionConstraint) Verify(collection Collection) error {
+	if c.Witness != "" {
+		if err := c.verifyWitness(collection); err != nil {
+			return err
+		}
+	}
+
+	types := make([]string, 0, len(c.Attestors))
+	for attestorType := range c.Attestors {
+		types = append(types, attestorType)
+	}
+
+	sort.Strings(types)
+	for _, attestorType := range types {
+		if err := verifyAttestorVersion(collection, attestorType, c.Attestors[attestorType]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c VersionConstraint) verifyWitness(collection Collection) error {
+	minimum, err := version.Parse(c.Witness)
+	if err != nil {
+		return fmt.Errorf("invalid minimum witness version: %w", err)
+	}
+
+	raw, ok := collection.Attestation(VersionsType)
+	if !ok {
+		return fmt.Errorf("collection does not record the witness version that signed it, witness %v or newer is required", c.Witness)
+	}
+
+	versions := struct {
+		Witness string `json:"witness"`
+	}{}
+
+	if err := json.Unmarshal(raw, &versions); err != nil {
+		return fmt.Errorf("failed to unmarshal versions attestation: %w", err)
+	}
+
+	recorded, err := version.Parse(versions.Witness)
+	if err != nil {
+		return fmt.Errorf("collection was signed by witness %q, which is not a release version", versions.Witness)
+	}
+
+	if recorded.Compare(minimum) < 0 {
+		return fmt.Errorf("collection was signed by witness %v, witness %v or newer is required", versions.Witness, c.Witness)
+	}
+
+	return nil
+}
+
+// verifyAttestorVersion requires the collection to have an attestation of the type, and every attestation of the
+// type to be at least the minimum schema version.
+func verifyAttestorVersion(collection Collection, attestorType, minimumVersion string) error {
+	minimum, err := version.Parse(minimumVersion)
+	if err != nil {
+		return fmt.Errorf("invalid minimum version of %v: %w", attestorType, err)
+	}
+
+	found := false
+	for _, attestation := range collection.Attestations {
+		base, schema, ok := version.SplitType(attestation.Type)
+		if !ok || base != attestorType {
+			continue
+		}
+
+		found = true
+		recorded, err := version.Parse(schema)
+		if err != nil {
+			return fmt.Errorf("invalid schema version of %v: %w", attestation.Type, err)
+		}
+
+		if recorded.Compare(minimum) < 0 {
+			return fmt.Errorf("collection has %v, %v %v or newer is required", attestation.Type, attestorType, minimumVersion)
+		}
+	}
+
+	if !found {
+		return fmt.Errorf("collection has no %v attestation, %v or newer is required", attestorType, minimumVersion)
+	}
+
+	return nil
+}
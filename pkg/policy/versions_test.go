@@ -0,0 +1,62 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/testifysec/go-witness/dsse"
+)
+
+func TestVersionConstraint(t *testing.T) {
+	commandRun := "https://witness.dev/attestations/command-run"
+	p := Policy{Steps: map[string]Step{"build": {Name: "build", Versions: &VersionConstraint{
+		Witness:   "v0.1.12",
+		Attestors: map[string]string{commandRun: "v0.3"},
+	}}}}
+
+	recorded := func(witness, commandRunVersion string) dsse.Envelope {
+		attestations := map[string]interface{}{commandRun + "/" + commandRunVersion: map[string]interface{}{"exitcode": 0}}
+		if witness != "" {
+			attestations[VersionsType] = map[string]interface{}{"witness": witness}
+		}
+
+		return testEnvelope(t, "build", attestations)
+	}
+
+	tests := []struct {
+		name     string
+		envelope dsse.Envelope
+		err      string
+	}{
+		{"current", recorded("v0.1.12-4f3c2a1", "v0.3"), ""},
+		{"newer", recorded("v0.2.0", "v1.0"), ""},
+		{"old witness", recorded("v0.1.11-9e8d7c6", "v0.3"), "witness v0.1.12 or newer is required"},
+		{"development build", recorded("dev", "v0.3"), "not a release version"},
+		{"unrecorded witness", recorded("", "v0.3"), "does not record the witness version"},
+		{"old attestor", recorded("v0.1.12", "v0.1"), "command-run/v0.1"},
+		{"missing attestor", testEnvelope(t, "build", map[string]interface{}{VersionsType: map[string]interface{}{"witness": "v0.1.12"}}), "no https://witness.dev/attestations/command-run attestation"},
+	}
+
+	for _, test := range tests {
+		_, err := p.Verify([]dsse.Envelope{test.envelope})
+		if test.err == "" && err != nil {
+			t.Errorf("%v: expected the collection to pass: %v", test.name, err)
+		} else if test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)) {
+			t.Errorf("%v: expected an error containing %q, got %v", test.name, test.err, err)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+const (
+	GitType      = "https://witness.dev/attestations/git/v0.1"
+	WorktreeType = "https://witness.dev/attestations/worktree/v0.1"
+
+	// maxReportedFiles limits how many changed files are named in errors.
+	maxReportedFiles = 5
+)
+
+// WorktreeConstraint requires the git worktree to have been clean when the step ran, so the commit the collection
+// names is the tree that was built. Files changed in the worktree are read from the git attestation's status and from
+// the worktree attestation witness run records with --dirty-worktree record. AllowedFiles are path.Match patterns of
+// changed files that are tolerated, such as files the CI system writes into the checkout.
+type WorktreeConstraint struct {
+	AllowedFiles []string `json:"allowedFiles,omitempty"`
+}
+
+// Verify checks the files changed in the collection's git worktree against the constraint.
+func (w WorktreeConstraint) Verify(collection Collection) error {
+	files, err := changedFiles(collection)
+	if err != nil {
+		return err
+	}
+
+	disallowed := make([]string, 0)
+	for _, file := range files {
+		allowed, err := w.allows(file)
+		if err != nil {
+			return err
+		}
+
+		if !allowed {
+			disallowed = append(disallowed, file)
+		}
+	}
+
+	if len(disallowed) == 0 {
+		return nil
+	}
+
+	if len(disallowed) > maxReportedFiles {
+		return fmt.Errorf("git worktree had uncommitted changes or untracked files: %v, and %d more", strings.Join(disallowed[:maxReportedFiles], ", "), len(disallowed)-maxReportedFiles)
+	}
+
+	return fmt.Errorf("git worktree had uncommitted changes or untracked files: %v", strings.Join(disallowed, ", "))
+}
+
+func (w WorktreeConstraint) allows(file string) (bool, error) {
+	for _, pattern := range w.AllowedFiles {
+		matched, err := path.Match(pattern, file)
+		if err != nil {
+			return false, fmt.Errorf("invalid allowed file pattern %q: %w", pattern, err)
+		}
+
+		if matched {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// changedFiles returns the sorted files changed in the collection's git worktree. A collection that records neither a
+// git nor a worktree attestation is an error, since its worktree can't be shown to be clean.
+func changedFiles(collection Collection) ([]string, error) {
+	gitRaw, hasGit := collection.Attestation(GitType)
+	worktreeRaw, hasWorktree := collection.Attestation(WorktreeType)
+	if !hasGit && !hasWorktree {
+		return nil, fmt.Errorf("collection has no git attestation")
+	}
+
+	changed := map[string]struct{}{}
+	if hasGit {
+		git := struct {
+			Status map[string]json.RawMessage `json:"status"`
+		}{}
+
+		if err := json.Unmarshal(gitRaw, &git); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal git attestation: %w", err)
+		}
+
+		for file := range git.Status {
+			changed[file] = struct{}{}
+		}
+	}
+
+	if hasWorktree {
+		worktree := struct {
+			Files []string `json:"files"`
+		}{}
+
+		if err := json.Unmarshal(worktreeRaw, &worktree); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal worktree attestation: %w", err)
+		}
+
+		for _, file := range worktree.Files {
+			changed[file] = struct{}{}
+		}
+	}
+
+	files := make([]string, 0, len(changed))
+	for file := range changed {
+		files = append(files, file)
+	}
+
+	sort.Strings(files)
+	return files, nil
+}
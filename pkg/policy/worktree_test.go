@@ -0,0 +1,55 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policy
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/testifysec/go-witness/dsse"
+)
+
+func TestWorktreeConstraint(t *testing.T) {
+	p := Policy{Steps: map[string]Step{"build": {Name: "build", Worktree: &WorktreeConstraint{AllowedFiles: []string{".ci/*"}}}}}
+	untracked := map[string]interface{}{"worktree": "untracked"}
+	recorded := func(files ...string) dsse.Envelope {
+		return testEnvelope(t, "build", map[string]interface{}{
+			gitType:      map[string]interface{}{"commithash": "abc123"},
+			WorktreeType: map[string]interface{}{"commithash": "abc123", "files": files},
+		})
+	}
+
+	tests := []struct {
+		name     string
+		envelope dsse.Envelope
+		err      string
+	}{
+		{"clean", gitEnvelope(t, map[string]interface{}{}), ""},
+		{"allowed file", gitEnvelope(t, map[string]interface{}{".ci/cache": untracked}), ""},
+		{"dirty", gitEnvelope(t, map[string]interface{}{"main.go": map[string]interface{}{"worktree": "modified"}}), "main.go"},
+		{"recorded", recorded("go.sum", ".ci/cache"), "go.sum"},
+		{"elided", recorded("a", "b", "c", "d", "e", "f", "g"), "e, and 2 more"},
+		{"no git attestation", testEnvelope(t, "build", nil), "no git attestation"},
+	}
+
+	for _, test := range tests {
+		_, err := p.Verify([]dsse.Envelope{test.envelope})
+		if test.err == "" && err != nil {
+			t.Errorf("%v: expected the collection to pass: %v", test.name, err)
+		} else if test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)) {
+			t.Errorf("%v: expected an error containing %q, got %v", test.name, test.err, err)
+		}
+	}
+}
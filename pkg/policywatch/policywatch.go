@@ -0,0 +1,240 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package policywatch keeps a signed policy up to date for long running services, reloading it from a file, an OCI
+// registry, or an Archivista server.
+package policywatch
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/dsse"
+	"github.com/testifysec/go-witness/log"
+)
+
+// Active is the policy currently in use.
+type Active struct {
+	Envelope dsse.Envelope
+	// Digest is the sha256 of the policy envelope.
+	Digest string
+	// Version and Expires are read from the policy, and order policies so an older one can't replace a newer one.
+	Version  int
+	Expires  time.Time
+	LoadedAt time.Time
+}
+
+// ErrPolicyExpired is returned when the newest policy found has expired.
+type ErrPolicyExpired struct {
+	Digest  string
+	Expires time.Time
+}
+
+func (e ErrPolicyExpired) Error() string {
+	return fmt.Sprintf("policy %v expired at %v", e.Digest, e.Expires.Format(time.RFC3339))
+}
+
+// ErrPolicyRollback is returned when the newest policy found is older than the active policy, as a source serving a
+// previously signed policy would be.
+type ErrPolicyRollback struct {
+	Digest        string
+	Version       int
+	Expires       time.Time
+	ActiveVersion int
+	ActiveExpires time.Time
+}
+
+func (e ErrPolicyRollback) Error() string {
+	return fmt.Sprintf("policy %v (version %v, expires %v) is not newer than the active policy (version %v, expires %v)",
+		e.Digest, e.Version, e.Expires.Format(time.RFC3339), e.ActiveVersion, e.ActiveExpires.Format(time.RFC3339))
+}
+
+// header holds the policy fields that order policies.
+type header struct {
+	Version int       `json:"version"`
+	Expires time.Time `json:"expires"`
+}
+
+// newer returns true if a policy with header a replaces one with header b: it has a higher version, or the same
+// version and a later expiry.
+func newer(a, b header) bool {
+	if a.Version != b.Version {
+		return a.Version > b.Version
+	}
+
+	return a.Expires.After(b.Expires)
+}
+
+// Watcher reloads a policy from its source when it changes. A new policy is only used once its signature has been
+// verified, it hasn't expired, and it is newer than the active policy, so an invalid, unsigned, or previously signed
+// policy served by the source leaves the active policy in place.
+type Watcher struct {
+	source    Source
+	verifiers []cryptoutil.Verifier
+	active    atomic.Value
+	failures  uint64
+	reloads   uint64
+	now       func() time.Time
+}
+
+// New loads and verifies the newest policy from the source. It returns an error if no valid policy can be loaded.
+func New(ctx context.Context, source Source, verifiers []cryptoutil.Verifier) (*Watcher, error) {
+	w := &Watcher{
+		source:    source,
+		verifiers: verifiers,
+		now:       time.Now,
+	}
+
+	if _, err := w.Reload(ctx); err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+// Policy returns the active policy.
+func (w *Watcher) Policy() Active {
+	return w.active.Load().(Active)
+}
+
+// Reload reads the policies from the source and, if the newest one with a valid signature differs from the active
+// policy, makes it the active policy. It returns true if the active policy changed.
+func (w *Watcher) Reload(ctx context.Context) (bool, error) {
+	candidates, err := w.source.Policies(ctx)
+	if err != nil {
+		return false, w.fail(fmt.Errorf("failed to read policy from %v: %w", w.source, err))
+	}
+
+	var (
+		best    *Active
+		lastErr error
+	)
+
+	for _, policyBytes := range candidates {
+		candidate, err := w.load(policyBytes)
+		if err != nil {
+			log.Debugf("(policywatch) skipping policy from %v: %v", w.source, err)
+			lastErr = err
+			continue
+		}
+
+		if best == nil || newer(header{candidate.Version, candidate.Expires}, header{best.Version, best.Expires}) {
+			best = &candidate
+		}
+	}
+
+	if best == nil {
+		if lastErr == nil {
+			lastErr = fmt.Errorf("no policy found in %v", w.source)
+		}
+
+		return false, w.fail(lastErr)
+	}
+
+	current, ok := w.active.Load().(Active)
+	if ok && current.Digest == best.Digest {
+		return false, nil
+	}
+
+	if !best.Expires.After(w.now()) {
+		return false, w.fail(ErrPolicyExpired{Digest: best.Digest, Expires: best.Expires})
+	}
+
+	if ok && !newer(header{best.Version, best.Expires}, header{current.Version, current.Expires}) {
+		return false, w.fail(ErrPolicyRollback{
+			Digest:        best.Digest,
+			Version:       best.Version,
+			Expires:       best.Expires,
+			ActiveVersion: current.Version,
+			ActiveExpires: current.Expires,
+		})
+	}
+
+	best.LoadedAt = w.now()
+	w.active.Store(*best)
+	atomic.AddUint64(&w.reloads, 1)
+	return true, nil
+}
+
+// load verifies the policy envelope's signature and reads its version and expiry.
+func (w *Watcher) load(policyBytes []byte) (Active, error) {
+	env := dsse.Envelope{}
+	if err := json.Unmarshal(policyBytes, &env); err != nil {
+		return Active{}, fmt.Errorf("could not unmarshal policy envelope: %w", err)
+	}
+
+	if _, err := env.Verify(dsse.WithVerifiers(w.verifiers)); err != nil {
+		return Active{}, fmt.Errorf("could not verify policy: %w", err)
+	}
+
+	h := header{}
+	if err := json.Unmarshal(env.Payload, &h); err != nil {
+		return Active{}, fmt.Errorf("could not unmarshal policy: %w", err)
+	}
+
+	return Active{
+		Envelope: env,
+		Digest:   fmt.Sprintf("sha256:%x", sha256.Sum256(policyBytes)),
+		Version:  h.Version,
+		Expires:  h.Expires,
+	}, nil
+}
+
+func (w *Watcher) fail(err error) error {
+	atomic.AddUint64(&w.failures, 1)
+	return err
+}
+
+// Run checks the source for a new policy every interval until ctx is done.
+func (w *Watcher) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			changed, err := w.Reload(ctx)
+			if err != nil {
+				log.Errorf("failed to reload policy, keeping %v: %v", w.Policy().Digest, err)
+			} else if changed {
+				log.Infof("Loaded policy %v", w.Policy().Digest)
+			}
+		}
+	}
+}
+
+// ServeHTTP writes metrics about the active policy in the prometheus text format.
+func (w *Watcher) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	active := w.Policy()
+	rw.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	fmt.Fprintf(rw, "# HELP witness_policy_info Digest of the active policy.\n")
+	fmt.Fprintf(rw, "# TYPE witness_policy_info gauge\n")
+	fmt.Fprintf(rw, "witness_policy_info{digest=%q} 1\n", active.Digest)
+	fmt.Fprintf(rw, "# HELP witness_policy_loaded_timestamp_seconds Time the active policy was loaded.\n")
+	fmt.Fprintf(rw, "# TYPE witness_policy_loaded_timestamp_seconds gauge\n")
+	fmt.Fprintf(rw, "witness_policy_loaded_timestamp_seconds %d\n", active.LoadedAt.Unix())
+	fmt.Fprintf(rw, "# HELP witness_policy_reloads_total Policies loaded since startup.\n")
+	fmt.Fprintf(rw, "# TYPE witness_policy_reloads_total counter\n")
+	fmt.Fprintf(rw, "witness_policy_reloads_total %d\n", atomic.LoadUint64(&w.reloads))
+	fmt.Fprintf(rw, "# HELP witness_policy_reload_failures_total Policies that failed to load or verify.\n")
+	fmt.Fprintf(rw, "# TYPE witness_policy_reload_failures_total counter\n")
+	fmt.Fprintf(rw, "witness_policy_reload_failures_total %d\n", atomic.LoadUint64(&w.failures))
+}
@@ -0,0 +1,209 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policywatch
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/dsse"
+)
+
+func newSigner(t *testing.T) cryptoutil.Signer {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return cryptoutil.NewRSASigner(key, crypto.SHA256)
+}
+
+func signPolicy(t *testing.T, payload string, signer cryptoutil.Signer) []byte {
+	env, err := dsse.Sign(PolicyPayloadType, bytes.NewReader([]byte(payload)), signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := json.Marshal(env)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return b
+}
+
+func writePolicy(t *testing.T, path string, payload string, signer cryptoutil.Signer) {
+	if err := os.WriteFile(path, signPolicy(t, payload, signer), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// policy returns a policy payload with the version and expiry.
+func policy(version int, expires time.Time, step string) string {
+	return fmt.Sprintf(`{"version": %d, "expires": %q, "steps": {%q: {}}}`, version, expires.Format(time.RFC3339), step)
+}
+
+// candidates is a source serving fixed policies.
+type candidates [][]byte
+
+func (c candidates) Policies(context.Context) ([][]byte, error) {
+	return c, nil
+}
+
+func (c candidates) String() string {
+	return "test"
+}
+
+func TestReload(t *testing.T) {
+	signer := newSigner(t)
+	verifier, err := signer.Verifier()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	expires := time.Now().Add(time.Hour)
+	path := filepath.Join(t.TempDir(), "policy.json")
+	writePolicy(t, path, policy(1, expires, "build"), signer)
+	w, err := New(ctx, File(path), []cryptoutil.Verifier{verifier})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	first := w.Policy()
+	if changed, err := w.Reload(ctx); err != nil || changed {
+		t.Errorf("expected unchanged policy not to reload: %v %v", changed, err)
+	}
+
+	writePolicy(t, path, policy(2, expires, "test"), signer)
+	if changed, err := w.Reload(ctx); err != nil || !changed {
+		t.Fatalf("expected new policy to be loaded: %v %v", changed, err)
+	}
+
+	second := w.Policy()
+	if second.Digest == first.Digest || second.Version != 2 || !strings.Contains(string(second.Envelope.Payload), `"test"`) {
+		t.Errorf("expected active policy to change")
+	}
+
+	writePolicy(t, path, policy(3, expires, "evil"), newSigner(t))
+	if changed, err := w.Reload(ctx); err == nil || changed {
+		t.Errorf("expected policy signed by another key to be rejected")
+	}
+
+	if w.Policy().Digest != second.Digest {
+		t.Errorf("expected previous policy to remain active")
+	}
+
+	rec := httptest.NewRecorder()
+	w.ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	metrics := rec.Body.String()
+	for _, expected := range []string{`witness_policy_info{digest="` + second.Digest + `"} 1`, "witness_policy_reloads_total 2", "witness_policy_reload_failures_total 1"} {
+		if !strings.Contains(metrics, expected) {
+			t.Errorf("expected metrics to contain %q:\n%v", expected, metrics)
+		}
+	}
+}
+
+func TestReloadRejectsRollbackAndExpiry(t *testing.T) {
+	signer := newSigner(t)
+	verifier, err := signer.Verifier()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	now := time.Now()
+	path := filepath.Join(t.TempDir(), "policy.json")
+	writePolicy(t, path, policy(2, now.Add(time.Hour), "build"), signer)
+	w, err := New(ctx, File(path), []cryptoutil.Verifier{verifier})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	active := w.Policy().Digest
+	tests := map[string]string{
+		"older version":                policy(1, now.Add(48*time.Hour), "old"),
+		"same version, same expiry":    policy(2, now.Add(time.Hour), "other"),
+		"same version, earlier expiry": policy(2, now.Add(time.Minute), "old"),
+	}
+
+	for name, payload := range tests {
+		writePolicy(t, path, payload, signer)
+		if changed, err := w.Reload(ctx); !errors.As(err, &ErrPolicyRollback{}) || changed {
+			t.Errorf("%v: expected the rollback to be rejected, got %v", name, err)
+		}
+	}
+
+	writePolicy(t, path, policy(3, now.Add(-time.Minute), "expired"), signer)
+	if changed, err := w.Reload(ctx); !errors.As(err, &ErrPolicyExpired{}) || changed {
+		t.Errorf("expected the expired policy to be rejected, got %v", err)
+	}
+
+	if w.Policy().Digest != active {
+		t.Errorf("expected the active policy to be kept")
+	}
+
+	writePolicy(t, path, policy(2, now.Add(2*time.Hour), "renewed"), signer)
+	if changed, err := w.Reload(ctx); err != nil || !changed {
+		t.Errorf("expected the same version with a later expiry to be loaded: %v", err)
+	}
+
+	if _, err := New(ctx, File(path+".missing"), []cryptoutil.Verifier{verifier}); err == nil {
+		t.Error("expected a missing policy to fail to load")
+	}
+
+	writePolicy(t, path, policy(1, now.Add(-time.Minute), "expired"), signer)
+	if _, err := New(ctx, File(path), []cryptoutil.Verifier{verifier}); !errors.As(err, &ErrPolicyExpired{}) {
+		t.Errorf("expected an expired policy to fail to load, got %v", err)
+	}
+}
+
+func TestReloadChoosesNewest(t *testing.T) {
+	signer := newSigner(t)
+	verifier, err := signer.Verifier()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expires := time.Now().Add(time.Hour)
+	source := candidates{
+		signPolicy(t, policy(1, expires, "first"), signer),
+		signPolicy(t, policy(3, expires, "forged"), newSigner(t)),
+		signPolicy(t, policy(2, expires, "second"), signer),
+		[]byte("not an envelope"),
+	}
+
+	w, err := New(context.Background(), source, []cryptoutil.Verifier{verifier})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if active := w.Policy(); active.Version != 2 || !strings.Contains(string(active.Envelope.Payload), `"second"`) {
+		t.Errorf("expected the newest validly signed policy, got version %v", active.Version)
+	}
+}
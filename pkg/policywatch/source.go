@@ -0,0 +1,83 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package policywatch
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/testifysec/witness/pkg/ocistore"
+	"github.com/testifysec/witness/pkg/search"
+)
+
+// PolicyPayloadType is the payload type of signed policy envelopes.
+const PolicyPayloadType = "https://witness.testifysec.com/policy/v0.1"
+
+// Source returns the candidate policy envelopes a Watcher chooses the newest valid policy from.
+type Source interface {
+	Policies(ctx context.Context) ([][]byte, error)
+	String() string
+}
+
+// File reads the policy from a file.
+type File string
+
+func (f File) Policies(ctx context.Context) ([][]byte, error) {
+	policyBytes, err := os.ReadFile(string(f))
+	if err != nil {
+		return nil, err
+	}
+
+	return [][]byte{policyBytes}, nil
+}
+
+func (f File) String() string {
+	return string(f)
+}
+
+// OCI reads the policy from the envelope layer of the image with the tag, such as a policy pushed with
+// oras push registry/policies:prod policy.signed.json:application/vnd.dsse.envelope.v1+json.
+type OCI struct {
+	Client    *ocistore.Client
+	Reference ocistore.Reference
+	Tag       string
+}
+
+func (o OCI) Policies(ctx context.Context) ([][]byte, error) {
+	policyBytes, err := o.Client.TaggedEnvelope(ctx, o.Reference, o.Tag)
+	if err != nil {
+		return nil, err
+	}
+
+	return [][]byte{policyBytes}, nil
+}
+
+func (o OCI) String() string {
+	return fmt.Sprintf("%v:%v", o.Reference, o.Tag)
+}
+
+// Archivist reads every signed policy stored in an Archivista server. The newest one signed by the policy key is used.
+type Archivist struct {
+	Archivist *search.Archivist
+}
+
+func (a Archivist) Policies(ctx context.Context) ([][]byte, error) {
+	return a.Archivist.Envelopes(ctx, PolicyPayloadType)
+}
+
+func (a Archivist) String() string {
+	return a.Archivist.URL
+}
@@ -0,0 +1,126 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package preflight checks that witness can sign and publish attestations before a long build runs.
+package preflight
+
+import (
+	"context"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+type Status string
+
+const (
+	Pass Status = "PASS"
+	Warn Status = "WARN"
+	Fail Status = "FAIL"
+)
+
+// Result is the outcome of a single check.
+type Result struct {
+	Check   string
+	Status  Status
+	Message string
+}
+
+// Check tests one part of witness's configuration.
+type Check struct {
+	Name string
+	Run  func(ctx context.Context) (Status, string)
+}
+
+// Run runs each check in order.
+func Run(ctx context.Context, checks []Check) []Result {
+	results := make([]Result, 0, len(checks))
+	for _, check := range checks {
+		status, message := check.Run(ctx)
+		results = append(results, Result{Check: check.Name, Status: status, Message: message})
+	}
+
+	return results
+}
+
+// Report writes a line for each result to w and returns false if any check failed.
+func Report(w io.Writer, results []Result) bool {
+	ready := true
+	for _, result := range results {
+		fmt.Fprintf(w, "%-4s  %-12s  %s\n", result.Status, result.Check, result.Message)
+		if result.Status == Fail {
+			ready = false
+		}
+	}
+
+	return ready
+}
+
+// ClockSkew returns how far the local clock is ahead of the server at url, based on the Date header of its response.
+// Date headers have a resolution of one second.
+func ClockSkew(ctx context.Context, client *http.Client, url string) (time.Duration, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+	if err != nil {
+		return 0, err
+	}
+
+	sent := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+
+	received := time.Now()
+	resp.Body.Close()
+	date, err := http.ParseTime(resp.Header.Get("Date"))
+	if err != nil {
+		return 0, fmt.Errorf("server did not return a valid date: %w", err)
+	}
+
+	// assume the server set the date half way through the round trip
+	local := sent.Add(received.Sub(sent) / 2)
+	return local.Sub(date), nil
+}
+
+// CheckClockSkew fails if the local clock differs from the server at url by more than maxSkew.
+func CheckClockSkew(client *http.Client, url string, maxSkew time.Duration) func(ctx context.Context) (Status, string) {
+	return func(ctx context.Context) (Status, string) {
+		skew, err := ClockSkew(ctx, client, url)
+		if err != nil {
+			return Fail, fmt.Sprintf("failed to reach %v: %v", url, err)
+		}
+
+		if skew > maxSkew || skew < -maxSkew {
+			return Fail, fmt.Sprintf("local clock differs from %v by %v", url, skew.Round(time.Second))
+		}
+
+		return Pass, fmt.Sprintf("reachable, clock skew %v", skew.Round(time.Second))
+	}
+}
+
+// CheckCertificate fails if the certificate is not valid at now and warns if it expires within warnWithin.
+func CheckCertificate(cert *x509.Certificate, now time.Time, warnWithin time.Duration) (Status, string) {
+	switch {
+	case now.Before(cert.NotBefore):
+		return Fail, fmt.Sprintf("certificate %v is not valid until %v", cert.Subject.CommonName, cert.NotBefore)
+	case now.After(cert.NotAfter):
+		return Fail, fmt.Sprintf("certificate %v expired at %v", cert.Subject.CommonName, cert.NotAfter)
+	case now.Add(warnWithin).After(cert.NotAfter):
+		return Warn, fmt.Sprintf("certificate %v expires at %v", cert.Subject.CommonName, cert.NotAfter)
+	}
+
+	return Pass, fmt.Sprintf("certificate %v valid until %v", cert.Subject.CommonName, cert.NotAfter)
+}
@@ -0,0 +1,78 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package preflight
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCheckClockSkew(t *testing.T) {
+	offset := time.Duration(0)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Date", time.Now().Add(offset).UTC().Format(http.TimeFormat))
+	}))
+	defer server.Close()
+
+	if status, message := CheckClockSkew(server.Client(), server.URL, time.Minute)(context.Background()); status != Pass {
+		t.Errorf("expected pass, got %v: %v", status, message)
+	}
+
+	offset = -10 * time.Minute
+	if status, message := CheckClockSkew(server.Client(), server.URL, time.Minute)(context.Background()); status != Fail || !strings.Contains(message, "differs") {
+		t.Errorf("expected skewed clock to fail, got %v: %v", status, message)
+	}
+}
+
+func TestCheckCertificate(t *testing.T) {
+	now := time.Now()
+	cert := &x509.Certificate{Subject: pkix.Name{CommonName: "build"}, NotBefore: now.Add(-time.Hour), NotAfter: now.Add(time.Hour)}
+	for _, tc := range []struct {
+		now      time.Time
+		expected Status
+	}{
+		{now.Add(-2 * time.Hour), Fail},
+		{now, Pass},
+		{now.Add(45 * time.Minute), Warn},
+		{now.Add(2 * time.Hour), Fail},
+	} {
+		if status, message := CheckCertificate(cert, tc.now, 30*time.Minute); status != tc.expected {
+			t.Errorf("expected %v at %v, got %v: %v", tc.expected, tc.now, status, message)
+		}
+	}
+}
+
+func TestReport(t *testing.T) {
+	results := Run(context.Background(), []Check{
+		{Name: "signer", Run: func(ctx context.Context) (Status, string) { return Pass, "ok" }},
+		{Name: "rekor", Run: func(ctx context.Context) (Status, string) { return Fail, "unreachable" }},
+	})
+
+	out := &bytes.Buffer{}
+	if Report(out, results) {
+		t.Error("expected report with a failure not to be ready")
+	}
+
+	if !strings.Contains(out.String(), "FAIL  rekor") {
+		t.Errorf("unexpected report: %q", out.String())
+	}
+}
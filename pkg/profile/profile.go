@@ -0,0 +1,76 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package profile reads signed verification profiles, which fix the policy, trust material, and flags witness verify
+// uses so every verifier in a fleet is configured the same way.
+package profile
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/dsse"
+)
+
+const PayloadType = "https://witness.dev/verification-profile/v0.1"
+
+// Profile configures witness verify. Policy is a path or http(s) URL, and URLs may be pinned with a #sha256=<hex>
+// suffix. PolicyPublicKey is the PEM encoded key the policy must be signed by. Flags are set on witness verify and
+// may not be given different values on the command line.
+type Profile struct {
+	Expires         time.Time         `json:"expires"`
+	Policy          string            `json:"policy"`
+	PolicyPublicKey string            `json:"policyPublicKey,omitempty"`
+	RekorServer     string            `json:"rekorServer,omitempty"`
+	Flags           map[string]string `json:"flags,omitempty"`
+}
+
+type ErrProfileExpired time.Time
+
+func (e ErrProfileExpired) Error() string {
+	return fmt.Sprintf("verification profile expired at %v", time.Time(e))
+}
+
+// Load parses a signed profile, verifies its signature with the verifiers, and checks that it has not expired.
+func Load(data []byte, now time.Time, verifiers ...cryptoutil.Verifier) (Profile, error) {
+	env := dsse.Envelope{}
+	if err := json.Unmarshal(data, &env); err != nil {
+		return Profile{}, fmt.Errorf("failed to parse verification profile envelope: %w", err)
+	}
+
+	if env.PayloadType != PayloadType {
+		return Profile{}, fmt.Errorf("unexpected verification profile payload type: %v", env.PayloadType)
+	}
+
+	if _, err := env.Verify(dsse.WithVerifiers(verifiers)); err != nil {
+		return Profile{}, fmt.Errorf("failed to verify verification profile signature: %w", err)
+	}
+
+	p := Profile{}
+	if err := json.Unmarshal(env.Payload, &p); err != nil {
+		return Profile{}, fmt.Errorf("failed to unmarshal verification profile: %w", err)
+	}
+
+	if p.Expires.IsZero() || now.After(p.Expires) {
+		return Profile{}, ErrProfileExpired(p.Expires)
+	}
+
+	if p.Policy == "" {
+		return Profile{}, fmt.Errorf("verification profile does not set a policy")
+	}
+
+	return p, nil
+}
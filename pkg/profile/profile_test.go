@@ -0,0 +1,105 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package profile
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/dsse"
+)
+
+func newSigner(t *testing.T) cryptoutil.Signer {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return cryptoutil.NewECDSASigner(key, crypto.SHA256)
+}
+
+func signProfile(t *testing.T, signer cryptoutil.Signer, payloadType string, p Profile) []byte {
+	payload, err := json.Marshal(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	env, err := dsse.Sign(payloadType, bytes.NewReader(payload), signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := json.Marshal(env)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return data
+}
+
+func TestLoad(t *testing.T) {
+	now := time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC)
+	signer := newSigner(t)
+	verifier, err := signer.Verifier()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := Profile{
+		Expires:     now.Add(time.Hour),
+		Policy:      "https://example.com/policy.signed.json#sha256=" + string(bytes.Repeat([]byte("a"), 64)),
+		RekorServer: "https://rekor.example.com",
+		Flags:       map[string]string{"expand-archive": "true"},
+	}
+
+	loaded, err := Load(signProfile(t, signer, PayloadType, p), now, verifier)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if loaded.Policy != p.Policy || loaded.RekorServer != p.RekorServer || loaded.Flags["expand-archive"] != "true" {
+		t.Errorf("unexpected profile: %+v", loaded)
+	}
+
+	if _, err := Load(signProfile(t, signer, PayloadType, p), now.Add(2*time.Hour), verifier); !errors.As(err, &ErrProfileExpired{}) {
+		t.Errorf("expected expired profile to be rejected, got %v", err)
+	}
+
+	other, err := newSigner(t).Verifier()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Load(signProfile(t, signer, PayloadType, p), now, other); err == nil {
+		t.Error("expected profile signed by another key to be rejected")
+	}
+
+	if _, err := Load(signProfile(t, signer, "https://witness.testifysec.com/policy/v0.1", p), now, verifier); err == nil {
+		t.Error("expected envelope of another payload type to be rejected")
+	}
+
+	p.Policy = ""
+	if _, err := Load(signProfile(t, signer, PayloadType, p), now, verifier); err == nil {
+		t.Error("expected profile without a policy to be rejected")
+	}
+}
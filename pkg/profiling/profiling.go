@@ -0,0 +1,59 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package profiling serves the Go runtime's pprof profiles from witness's long running commands, so the memory,
+// allocations, and contention of a service verifying attestations can be inspected while it runs.
+package profiling
+
+import (
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+)
+
+// Config sets how often the runtime samples the events its profiles record.
+type Config struct {
+	// MemProfileRate is the average number of bytes allocated between samples of the heap and allocs profiles.
+	MemProfileRate int
+
+	// BlockProfileRate is the number of nanoseconds blocked between samples of the block profile. 0 disables it.
+	BlockProfileRate int
+
+	// MutexProfileFraction samples 1 in this many contended mutexes in the mutex profile. 0 disables it.
+	MutexProfileFraction int
+}
+
+// Apply sets the runtime's sampling rates. Allocations made before the memory profile rate is changed are sampled at
+// the previous rate, so Apply should be called before the work being profiled starts.
+func (c Config) Apply() {
+	if c.MemProfileRate > 0 {
+		runtime.MemProfileRate = c.MemProfileRate
+	}
+
+	runtime.SetBlockProfileRate(c.BlockProfileRate)
+	runtime.SetMutexProfileFraction(c.MutexProfileFraction)
+}
+
+// Handler serves the pprof index on /debug/pprof/ and each profile beneath it, such as /debug/pprof/heap. Importing
+// net/http/pprof also registers these handlers with http.DefaultServeMux, which witness never serves, so profiles are
+// only reachable where this handler is mounted.
+func Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	return mux
+}
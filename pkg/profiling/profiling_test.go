@@ -0,0 +1,72 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package profiling
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+)
+
+func TestHandler(t *testing.T) {
+	server := httptest.NewServer(Handler())
+	defer server.Close()
+
+	for _, path := range []string{"/debug/pprof/", "/debug/pprof/heap", "/debug/pprof/allocs?debug=1", "/debug/pprof/goroutine"} {
+		resp, err := http.Get(server.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("expected %v to be served, got %v", path, resp.Status)
+		}
+	}
+
+	resp, err := http.Get(server.URL + "/metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusNotFound {
+		t.Errorf("expected only profiles to be served, got %v for /metrics", resp.Status)
+	}
+}
+
+func TestApply(t *testing.T) {
+	previous := runtime.MemProfileRate
+	defer func() {
+		runtime.MemProfileRate = previous
+		runtime.SetBlockProfileRate(0)
+		runtime.SetMutexProfileFraction(0)
+	}()
+
+	Config{MemProfileRate: 4096, MutexProfileFraction: 5}.Apply()
+	if runtime.MemProfileRate != 4096 {
+		t.Errorf("expected memory profile rate 4096, got %v", runtime.MemProfileRate)
+	}
+
+	if fraction := runtime.SetMutexProfileFraction(-1); fraction != 5 {
+		t.Errorf("expected mutex profile fraction 5, got %v", fraction)
+	}
+
+	Config{}.Apply()
+	if runtime.MemProfileRate != 4096 {
+		t.Errorf("expected an unset memory profile rate to keep the current rate, got %v", runtime.MemProfileRate)
+	}
+}
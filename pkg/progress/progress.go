@@ -0,0 +1,132 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package progress reports long running phases, such as hashing a workspace or searching Rekor, so witness doesn't
+// appear to hang.
+package progress
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	// LogInterval is how often a phase that is still running is reported when the output is not a terminal.
+	LogInterval = 30 * time.Second
+	// TTYInterval is how often the status line is redrawn when the output is a terminal.
+	TTYInterval = 200 * time.Millisecond
+)
+
+var (
+	mu       sync.Mutex
+	reporter *Reporter
+)
+
+// Reporter writes the status of running phases to out. Terminals get a status line that is redrawn in place, other
+// outputs get a line each interval.
+type Reporter struct {
+	out      io.Writer
+	tty      bool
+	interval time.Duration
+}
+
+// NewReporter creates a reporter writing to out. tty controls whether the status line is redrawn in place.
+func NewReporter(out io.Writer, tty bool) *Reporter {
+	interval := LogInterval
+	if tty {
+		interval = TTYInterval
+	}
+
+	return &Reporter{
+		out:      out,
+		tty:      tty,
+		interval: interval,
+	}
+}
+
+// Enable reports progress to f for the lifetime of the process.
+func Enable(f *os.File) {
+	mu.Lock()
+	defer mu.Unlock()
+	reporter = NewReporter(f, isTerminal(f))
+}
+
+// Start reports that a phase started and returns a function to call when it finishes. If progress reporting is not
+// enabled, the returned function does nothing.
+func Start(phase string) func() {
+	mu.Lock()
+	r := reporter
+	mu.Unlock()
+	if r == nil {
+		return func() {}
+	}
+
+	return r.Start(phase)
+}
+
+func (r *Reporter) Start(phase string) func() {
+	started := time.Now()
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+		for frame := 0; ; frame++ {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				r.report(phase, time.Now().Sub(started), frame, false)
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(done)
+			<-stopped
+			r.report(phase, time.Now().Sub(started), 0, true)
+		})
+	}
+}
+
+var spinner = []string{"|", "/", "-", "\\"}
+
+func (r *Reporter) report(phase string, elapsed time.Duration, frame int, finished bool) {
+	elapsed = elapsed.Round(time.Second)
+	switch {
+	case r.tty && finished:
+		fmt.Fprintf(r.out, "\r\033[K%s done (%v)\n", phase, elapsed)
+	case r.tty:
+		fmt.Fprintf(r.out, "\r\033[K%s %s (%v)", spinner[frame%len(spinner)], phase, elapsed)
+	case finished:
+		fmt.Fprintf(r.out, "%s done (%v)\n", phase, elapsed)
+	default:
+		fmt.Fprintf(r.out, "%s still running (%v)\n", phase, elapsed)
+	}
+}
+
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+
+	return info.Mode()&os.ModeCharDevice != 0
+}
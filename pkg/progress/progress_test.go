@@ -0,0 +1,76 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package progress
+
+import (
+	"bytes"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestReporter(t *testing.T) {
+	out := &syncBuffer{}
+	r := NewReporter(out, false)
+	r.interval = 10 * time.Millisecond
+	done := r.Start("hashing materials")
+	time.Sleep(35 * time.Millisecond)
+	done()
+	done()
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) < 2 || !strings.HasPrefix(lines[0], "hashing materials still running") {
+		t.Fatalf("expected periodic progress lines, got %q", out.String())
+	}
+
+	if !strings.HasPrefix(lines[len(lines)-1], "hashing materials done") || strings.Count(out.String(), "done") != 1 {
+		t.Errorf("expected a single finished line, got %q", out.String())
+	}
+}
+
+func TestReporterTTY(t *testing.T) {
+	out := &syncBuffer{}
+	r := NewReporter(out, true)
+	r.interval = 10 * time.Millisecond
+	done := r.Start("searching rekor")
+	time.Sleep(25 * time.Millisecond)
+	done()
+
+	if strings.Count(out.String(), "\n") != 1 || !strings.Contains(out.String(), "\r\033[Ksearching rekor done") {
+		t.Errorf("expected status line to be redrawn in place, got %q", out.String())
+	}
+}
+
+func TestStartDisabled(t *testing.T) {
+	Start("noop")()
+}
@@ -0,0 +1,135 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package publish stores a signed envelope in several destinations at once, such as Rekor logs, OCI registries, and
+// Archivista, and reports the outcome of each.
+package publish
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/testifysec/go-witness/dsse"
+)
+
+type Status string
+
+const (
+	// Stored destinations hold the envelope.
+	Stored Status = "stored"
+	// Deferred destinations couldn't be reached, and the envelope was saved to be sent to them later.
+	Deferred Status = "deferred"
+	// Failed destinations don't hold the envelope.
+	Failed Status = "failed"
+)
+
+// ErrDeferred is returned by a destination that couldn't store the envelope, but saved it at Path to be sent later,
+// such as with witness sync.
+type ErrDeferred struct {
+	Path string
+	Err  error
+}
+
+func (e ErrDeferred) Error() string {
+	return fmt.Sprintf("deferred to %v: %v", e.Path, e.Err)
+}
+
+func (e ErrDeferred) Unwrap() error {
+	return e.Err
+}
+
+// Destination is a place to publish envelopes to. Publish is given the envelope along with its JSON encoding, which may
+// carry fields dsse.Envelope doesn't have, such as signature timestamps, and returns where the envelope was stored,
+// such as the URL of its Rekor entry.
+type Destination struct {
+	Kind    string
+	Target  string
+	Publish func(ctx context.Context, env dsse.Envelope, envBytes []byte) ([]string, error)
+}
+
+// Result is the outcome of publishing to a single destination.
+type Result struct {
+	Kind      string   `json:"kind"`
+	Target    string   `json:"target"`
+	Status    Status   `json:"status"`
+	Locations []string `json:"locations,omitempty"`
+	Error     string   `json:"error,omitempty"`
+}
+
+// Report is the outcome of publishing to each destination, in the order the destinations were given.
+type Report struct {
+	Destinations []Result `json:"destinations"`
+}
+
+// Publish stores the envelope in every destination at the same time, so a slow or unreachable destination doesn't
+// hold up the others, and waits for all of them to finish. envBytes is the JSON encoding of env that is stored where
+// destinations keep the envelope as is.
+func Publish(ctx context.Context, env dsse.Envelope, envBytes []byte, destinations []Destination) Report {
+	results := make([]Result, len(destinations))
+	wg := sync.WaitGroup{}
+	for i, destination := range destinations {
+		wg.Add(1)
+		go func(i int, destination Destination) {
+			defer wg.Done()
+			locations, err := destination.Publish(ctx, env, envBytes)
+			result := Result{Kind: destination.Kind, Target: destination.Target, Status: Stored, Locations: locations}
+			deferred := ErrDeferred{}
+			if errors.As(err, &deferred) {
+				result.Status = Deferred
+				result.Locations = []string{deferred.Path}
+				result.Error = deferred.Err.Error()
+			} else if err != nil {
+				result.Status = Failed
+				result.Locations = nil
+				result.Error = err.Error()
+			}
+
+			results[i] = result
+		}(i, destination)
+	}
+
+	wg.Wait()
+	return Report{Destinations: results}
+}
+
+// Count returns the number of destinations with the status.
+func (r Report) Count(status Status) int {
+	count := 0
+	for _, result := range r.Destinations {
+		if result.Status == status {
+			count++
+		}
+	}
+
+	return count
+}
+
+// Err returns an error listing the destinations that failed, or nil if none did.
+func (r Report) Err() error {
+	failures := make([]string, 0)
+	for _, result := range r.Destinations {
+		if result.Status == Failed {
+			failures = append(failures, fmt.Sprintf("%v %v: %v", result.Kind, result.Target, result.Error))
+		}
+	}
+
+	if len(failures) == 0 {
+		return nil
+	}
+
+	return fmt.Errorf("failed to publish to %d of %d destinations: %v", len(failures), len(r.Destinations), strings.Join(failures, "; "))
+}
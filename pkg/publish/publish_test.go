@@ -0,0 +1,93 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package publish
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/testifysec/go-witness/dsse"
+)
+
+func TestPublish(t *testing.T) {
+	// the rekor destinations only finish once both have started, which they can only do if they run at the same time
+	started := sync.WaitGroup{}
+	started.Add(2)
+	rekor := func(location string) func(context.Context, dsse.Envelope, []byte) ([]string, error) {
+		return func(ctx context.Context, env dsse.Envelope, envBytes []byte) ([]string, error) {
+			started.Done()
+			done := make(chan struct{})
+			go func() {
+				started.Wait()
+				close(done)
+			}()
+
+			select {
+			case <-done:
+				return []string{location}, nil
+			case <-time.After(5 * time.Second):
+				return nil, errors.New("destinations were not published to concurrently")
+			}
+		}
+	}
+
+	destinations := []Destination{
+		{Kind: "rekor", Target: "https://rekor.sigstore.dev", Publish: rekor("https://rekor.sigstore.dev/api/v1/log/entries/a")},
+		{Kind: "rekor", Target: "https://rekor.internal", Publish: rekor("https://rekor.internal/api/v1/log/entries/b")},
+		{Kind: "archivista", Target: "https://archivista.internal", Publish: func(ctx context.Context, env dsse.Envelope, envBytes []byte) ([]string, error) {
+			return nil, ErrDeferred{Path: "/spool/abc.json", Err: errors.New("connection refused")}
+		}},
+		{Kind: "oci", Target: "oci://ghcr.io/org/repo", Publish: func(ctx context.Context, env dsse.Envelope, envBytes []byte) ([]string, error) {
+			return []string{"partial"}, errors.New("unauthorized")
+		}},
+	}
+
+	report := Publish(context.Background(), dsse.Envelope{}, []byte("{}"), destinations)
+	expected := []Result{
+		{Kind: "rekor", Target: "https://rekor.sigstore.dev", Status: Stored, Locations: []string{"https://rekor.sigstore.dev/api/v1/log/entries/a"}},
+		{Kind: "rekor", Target: "https://rekor.internal", Status: Stored, Locations: []string{"https://rekor.internal/api/v1/log/entries/b"}},
+		{Kind: "archivista", Target: "https://archivista.internal", Status: Deferred, Locations: []string{"/spool/abc.json"}, Error: "connection refused"},
+		{Kind: "oci", Target: "oci://ghcr.io/org/repo", Status: Failed, Error: "unauthorized"},
+	}
+
+	if len(report.Destinations) != len(expected) {
+		t.Fatalf("expected %d results, got %+v", len(expected), report.Destinations)
+	}
+
+	for i, want := range expected {
+		got := report.Destinations[i]
+		if got.Kind != want.Kind || got.Target != want.Target || got.Status != want.Status || got.Error != want.Error ||
+			strings.Join(got.Locations, ",") != strings.Join(want.Locations, ",") {
+			t.Errorf("expected result %d to be %+v, got %+v", i, want, got)
+		}
+	}
+
+	if report.Count(Stored) != 2 || report.Count(Deferred) != 1 || report.Count(Failed) != 1 {
+		t.Errorf("unexpected counts in %+v", report.Destinations)
+	}
+
+	err := report.Err()
+	if err == nil || !strings.Contains(err.Error(), "1 of 4 destinations: oci oci://ghcr.io/org/repo: unauthorized") {
+		t.Errorf("expected the error to list the failed destination, got %v", err)
+	}
+
+	if err := Publish(context.Background(), dsse.Envelope{}, []byte("{}"), destinations[2:3]).Err(); err != nil {
+		t.Errorf("expected a deferred destination not to be an error, got %v", err)
+	}
+}
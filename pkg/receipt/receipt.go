@@ -0,0 +1,167 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package receipt
+
+import (
+	"bytes"
+	"crypto"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/dsse"
+)
+
+const PayloadType = "https://witness.dev/verification-receipt/v0.1"
+
+// Receipt records a successful verification of a set of attestations against a policy for a subject.
+type Receipt struct {
+	PolicyDigest    string    `json:"policydigest"`
+	SubjectDigest   string    `json:"subjectdigest"`
+	EnvelopesDigest string    `json:"envelopesdigest"`
+	OptionsDigest   string    `json:"optionsdigest"`
+	Evidence        []string  `json:"evidence"`
+	VerifiedAt      time.Time `json:"verifiedat"`
+}
+
+type ErrReceiptMismatch struct {
+	Field string
+}
+
+func (e ErrReceiptMismatch) Error() string {
+	return fmt.Sprintf("receipt %v does not match", e.Field)
+}
+
+type ErrReceiptExpired time.Time
+
+func (e ErrReceiptExpired) Error() string {
+	return fmt.Sprintf("receipt was issued at %v and has expired", time.Time(e))
+}
+
+// New creates a receipt keyed by the digests of the policy, subject, and the set of envelopes that were verified.
+// The digest of the envelope set does not depend on the order the envelopes were provided in. options holds the
+// verification settings and trust material the result depends on, and is digested as JSON.
+func New(policyEnvelope dsse.Envelope, subjectDigest string, envelopes []dsse.Envelope, options interface{}, evidence []string) (Receipt, error) {
+	policyDigest, err := digestJSON(policyEnvelope)
+	if err != nil {
+		return Receipt{}, fmt.Errorf("failed to digest policy: %w", err)
+	}
+
+	envelopeDigests := make([]string, 0, len(envelopes))
+	for _, env := range envelopes {
+		digest, err := digestJSON(env)
+		if err != nil {
+			return Receipt{}, fmt.Errorf("failed to digest envelope: %w", err)
+		}
+
+		envelopeDigests = append(envelopeDigests, digest)
+	}
+
+	sort.Strings(envelopeDigests)
+	envelopesDigest, err := digestJSON(envelopeDigests)
+	if err != nil {
+		return Receipt{}, fmt.Errorf("failed to digest envelope set: %w", err)
+	}
+
+	optionsDigest, err := digestJSON(options)
+	if err != nil {
+		return Receipt{}, fmt.Errorf("failed to digest verification options: %w", err)
+	}
+
+	return Receipt{
+		PolicyDigest:    policyDigest,
+		SubjectDigest:   subjectDigest,
+		EnvelopesDigest: envelopesDigest,
+		OptionsDigest:   optionsDigest,
+		Evidence:        evidence,
+		VerifiedAt:      time.Now().UTC(),
+	}, nil
+}
+
+// Check returns an error if the receipt was not created for the same inputs as other, or is older than maxAge.
+// A maxAge of 0 disables the expiration check.
+func (r Receipt) Check(other Receipt, maxAge time.Duration) error {
+	if r.PolicyDigest != other.PolicyDigest {
+		return ErrReceiptMismatch{Field: "policy digest"}
+	}
+
+	if r.SubjectDigest != other.SubjectDigest {
+		return ErrReceiptMismatch{Field: "subject digest"}
+	}
+
+	if r.EnvelopesDigest != other.EnvelopesDigest {
+		return ErrReceiptMismatch{Field: "envelope set digest"}
+	}
+
+	if r.OptionsDigest != other.OptionsDigest {
+		return ErrReceiptMismatch{Field: "options digest"}
+	}
+
+	if maxAge > 0 && time.Since(r.VerifiedAt) > maxAge {
+		return ErrReceiptExpired(r.VerifiedAt)
+	}
+
+	return nil
+}
+
+// Sign wraps the receipt in a DSSE envelope signed by signer.
+func Sign(r Receipt, signer cryptoutil.Signer) (dsse.Envelope, error) {
+	data, err := json.Marshal(&r)
+	if err != nil {
+		return dsse.Envelope{}, err
+	}
+
+	return dsse.Sign(PayloadType, bytes.NewReader(data), signer)
+}
+
+// Load reads a signed receipt and verifies its signature with the provided verifiers.
+func Load(r io.Reader, verifiers ...cryptoutil.Verifier) (Receipt, error) {
+	env := dsse.Envelope{}
+	if err := json.NewDecoder(r).Decode(&env); err != nil {
+		return Receipt{}, fmt.Errorf("failed to parse receipt envelope: %w", err)
+	}
+
+	if env.PayloadType != PayloadType {
+		return Receipt{}, fmt.Errorf("unexpected receipt payload type: %v", env.PayloadType)
+	}
+
+	if _, err := env.Verify(dsse.WithVerifiers(verifiers)); err != nil {
+		return Receipt{}, fmt.Errorf("failed to verify receipt signature: %w", err)
+	}
+
+	receipt := Receipt{}
+	if err := json.Unmarshal(env.Payload, &receipt); err != nil {
+		return Receipt{}, fmt.Errorf("failed to unmarshal receipt: %w", err)
+	}
+
+	return receipt, nil
+}
+
+func digestJSON(v interface{}) (string, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+
+	digest, err := cryptoutil.DigestBytes(data, crypto.SHA256)
+	if err != nil {
+		return "", err
+	}
+
+	return string(cryptoutil.HexEncode(digest)), nil
+}
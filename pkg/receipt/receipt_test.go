@@ -0,0 +1,106 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package receipt
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/dsse"
+)
+
+func TestSignAndLoad(t *testing.T) {
+	privKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signer := cryptoutil.NewRSASigner(privKey, crypto.SHA256)
+	verifier := cryptoutil.NewRSAVerifier(&privKey.PublicKey, crypto.SHA256)
+	policyEnv := dsse.Envelope{Payload: []byte("policy"), PayloadType: "policy"}
+	envs := []dsse.Envelope{
+		{Payload: []byte("one"), PayloadType: "text"},
+		{Payload: []byte("two"), PayloadType: "text"},
+	}
+	opts := map[string]string{"rekorserver": "https://rekor.example.com"}
+
+	r, err := New(policyEnv, "abc123", envs, opts, []string{"one", "two"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signed, err := Sign(r, signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signedBytes, err := json.Marshal(&signed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := Load(bytes.NewReader(signedBytes), verifier)
+	if err != nil {
+		t.Fatalf("unexpected error loading receipt: %v", err)
+	}
+
+	reordered, err := New(policyEnv, "abc123", []dsse.Envelope{envs[1], envs[0]}, opts, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := loaded.Check(reordered, time.Hour); err != nil {
+		t.Errorf("expected receipt to match: %v", err)
+	}
+
+	other, err := New(policyEnv, "def456", envs, opts, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := loaded.Check(other, time.Hour); err == nil {
+		t.Errorf("expected receipt with different subject to not match")
+	}
+
+	otherOpts, err := New(policyEnv, "abc123", envs, map[string]string{"rekorserver": "https://other.example.com"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := loaded.Check(otherOpts, time.Hour); err == nil {
+		t.Errorf("expected receipt with different options to not match")
+	}
+
+	loaded.VerifiedAt = time.Now().Add(-2 * time.Hour)
+	if err := loaded.Check(reordered, time.Hour); err == nil {
+		t.Errorf("expected expired receipt to fail")
+	}
+
+	signed.Payload = []byte(`{"policydigest":"tampered"}`)
+	tamperedBytes, err := json.Marshal(&signed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Load(bytes.NewReader(tamperedBytes), verifier); err == nil {
+		t.Errorf("expected tampered receipt to fail verification")
+	}
+}
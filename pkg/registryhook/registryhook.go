@@ -0,0 +1,412 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registryhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/testifysec/go-witness/log"
+	"github.com/testifysec/witness/pkg/ocistore"
+)
+
+// Event is an image pushed to a registry.
+type Event struct {
+	Repository string `json:"repository"`
+	Tag        string `json:"tag,omitempty"`
+	Digest     string `json:"digest"`
+	// CallbackURL is set by registries that accept a status report for the push, such as Docker Hub.
+	CallbackURL string `json:"-"`
+}
+
+// Result is the outcome of verifying a pushed image.
+type Result struct {
+	Event    Event    `json:"event"`
+	Verified bool     `json:"verified"`
+	Error    string   `json:"error,omitempty"`
+	Evidence []string `json:"evidence,omitempty"`
+}
+
+// VerifyFunc verifies the pushed image identified by digest and returns references to the evidence used.
+type VerifyFunc func(ctx context.Context, event Event) ([]string, error)
+
+// ResolveFunc returns the digest the tag of a pushed image refers to, for registries whose webhooks only name the tag.
+type ResolveFunc func(ctx context.Context, event Event) (string, error)
+
+// TagResolver resolves tags by fetching their manifests from the registry with client. The digest is of the manifest
+// the tag refers to when the webhook is handled, which is the pushed one unless the tag was pushed to again since.
+func TagResolver(client *ocistore.Client, registry string) ResolveFunc {
+	return func(ctx context.Context, event Event) (string, error) {
+		ref, err := ocistore.ParseReference(ocistore.Scheme + registry + "/" + event.Repository)
+		if err != nil {
+			return "", err
+		}
+
+		desc, _, err := client.Inspect(ctx, ref, event.Tag)
+		if err != nil {
+			return "", err
+		}
+
+		return desc.Digest, nil
+	}
+}
+
+type harborPayload struct {
+	Type      string `json:"type"`
+	EventData struct {
+		Resources []struct {
+			Digest string `json:"digest"`
+			Tag    string `json:"tag"`
+		} `json:"resources"`
+		Repository struct {
+			RepoFullName string `json:"repo_full_name"`
+		} `json:"repository"`
+	} `json:"event_data"`
+}
+
+type dockerHubPayload struct {
+	CallbackURL string `json:"callback_url"`
+	PushData    struct {
+		Tag string `json:"tag"`
+	} `json:"push_data"`
+	Repository struct {
+		RepoName string `json:"repo_name"`
+	} `json:"repository"`
+}
+
+// ParseHarbor returns the events in a Harbor webhook payload. Payloads other than artifact pushes have no events.
+func ParseHarbor(r io.Reader) ([]Event, error) {
+	payload := harborPayload{}
+	if err := json.NewDecoder(r).Decode(&payload); err != nil {
+		return nil, fmt.Errorf("failed to decode harbor payload: %w", err)
+	}
+
+	events := make([]Event, 0)
+	if payload.Type != "PUSH_ARTIFACT" {
+		return events, nil
+	}
+
+	for _, resource := range payload.EventData.Resources {
+		events = append(events, Event{
+			Repository: payload.EventData.Repository.RepoFullName,
+			Tag:        resource.Tag,
+			Digest:     resource.Digest,
+		})
+	}
+
+	return events, nil
+}
+
+// ParseDockerHub returns the event in a Docker Hub webhook payload. Docker Hub does not include the pushed digest,
+// so the returned event only identifies the repository and tag, and the handler's Resolve looks up the digest.
+func ParseDockerHub(r io.Reader) ([]Event, error) {
+	payload := dockerHubPayload{}
+	if err := json.NewDecoder(r).Decode(&payload); err != nil {
+		return nil, fmt.Errorf("failed to decode docker hub payload: %w", err)
+	}
+
+	return []Event{{
+		Repository:  payload.Repository.RepoName,
+		Tag:         payload.PushData.Tag,
+		CallbackURL: payload.CallbackURL,
+	}}, nil
+}
+
+// MaxBodySize is the largest webhook payload the handler reads.
+const MaxBodySize = 1 << 20
+
+// DefaultCallbackHosts are the hosts Docker Hub sends status callback URLs for.
+var DefaultCallbackHosts = []string{"registry.hub.docker.com", "registry-1.docker.io", "hub.docker.com"}
+
+// Handler receives registry webhooks, verifies each pushed image, and reports the results.
+type Handler struct {
+	Verify VerifyFunc
+	// NotifyURL receives a POST of each Result if set, using NotifyClient.
+	NotifyURL    string
+	NotifyClient *http.Client
+	// HTTPClient is used to report status to registry callbacks.
+	HTTPClient *http.Client
+	// CallbackHosts are the only hosts status is reported to. Callback URLs in payloads for other hosts are ignored.
+	CallbackHosts []string
+	// Harbor labels pushed artifacts with the result of their verification if set.
+	Harbor *HarborLabels
+	// Resolve looks up the digest of Docker Hub pushes, which only name the tag. If it is nil, Docker Hub pushes fail
+	// verification.
+	Resolve ResolveFunc
+	secret  string
+	mux     *http.ServeMux
+}
+
+// NewHandler returns a handler that only accepts webhooks carrying secret, either as the Authorization header, which
+// Harbor sends as its auth header, or as the token query parameter, since Docker Hub can not set headers. An empty
+// secret rejects every webhook.
+func NewHandler(verify VerifyFunc, notifyURL, secret string) *Handler {
+	h := &Handler{
+		Verify:        verify,
+		NotifyURL:     notifyURL,
+		NotifyClient:  http.DefaultClient,
+		HTTPClient:    http.DefaultClient,
+		CallbackHosts: DefaultCallbackHosts,
+		secret:        secret,
+		mux:           http.NewServeMux(),
+	}
+
+	h.mux.HandleFunc("/harbor", h.handle(ParseHarbor, false, h.labelHarbor))
+	h.mux.HandleFunc("/dockerhub", h.handle(ParseDockerHub, true, h.reportCallback))
+	return h
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.mux.ServeHTTP(w, r)
+}
+
+// handle returns a handler for the registry's webhooks. If resolve is set, events without a digest are resolved with
+// the handler's Resolve.
+func (h *Handler) handle(parse func(io.Reader) ([]Event, error), resolve bool, report func(context.Context, Result)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		if !h.authorized(r) {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		events, err := parse(http.MaxBytesReader(w, r.Body, MaxBodySize))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		results := make([]Result, 0, len(events))
+		for _, event := range events {
+			result := h.verify(r.Context(), event, resolve)
+			results = append(results, result)
+			h.notify(r.Context(), result)
+			report(r.Context(), result)
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(results); err != nil {
+			log.Errorf("failed to write response: %v", err)
+		}
+	}
+}
+
+func (h *Handler) verify(ctx context.Context, event Event, resolve bool) Result {
+	result := Result{Event: event}
+	if event.Digest == "" && (!resolve || h.Resolve == nil || event.Tag == "") {
+		result.Error = "registry did not provide the pushed digest"
+		return result
+	}
+
+	if event.Digest == "" {
+		digest, err := h.Resolve(ctx, event)
+		if err != nil {
+			result.Error = fmt.Sprintf("failed to resolve %v:%v to a digest: %v", event.Repository, event.Tag, err)
+			log.Infof("Verification failed for %v:%v: %v", event.Repository, event.Tag, err)
+			return result
+		}
+
+		event.Digest = digest
+		result.Event = event
+		log.Infof("Resolved %v:%v to %v", event.Repository, event.Tag, digest)
+	}
+
+	evidence, err := h.Verify(ctx, event)
+	if err != nil {
+		result.Error = err.Error()
+		log.Infof("Verification failed for %v@%v: %v", event.Repository, event.Digest, err)
+		return result
+	}
+
+	result.Verified = true
+	result.Evidence = evidence
+	log.Infof("Verification succeeded for %v@%v", event.Repository, event.Digest)
+	return result
+}
+
+// authorized returns whether the request carries the handler's secret.
+func (h *Handler) authorized(r *http.Request) bool {
+	if h.secret == "" {
+		return false
+	}
+
+	provided := r.URL.Query().Get("token")
+	if auth := r.Header.Get("Authorization"); auth != "" {
+		provided = strings.TrimPrefix(auth, "Bearer ")
+	}
+
+	return subtle.ConstantTimeCompare([]byte(provided), []byte(h.secret)) == 1
+}
+
+func (h *Handler) notify(ctx context.Context, result Result) {
+	if h.NotifyURL == "" {
+		return
+	}
+
+	if err := post(ctx, h.NotifyClient, h.NotifyURL, result, nil); err != nil {
+		log.Errorf("failed to notify %v: %v", h.NotifyURL, err)
+	}
+}
+
+// reportCallback reports the result to the callback URL of a Docker Hub push if its host is allowed.
+func (h *Handler) reportCallback(ctx context.Context, result Result) {
+	if result.Event.CallbackURL == "" {
+		return
+	}
+
+	if err := h.checkCallback(result.Event.CallbackURL); err != nil {
+		log.Errorf("not reporting status to callback: %v", err)
+		return
+	}
+
+	state := "failure"
+	description := result.Error
+	if result.Verified {
+		state = "success"
+		description = "witness verification succeeded"
+	}
+
+	callback := map[string]string{
+		"state":       state,
+		"description": description,
+		"context":     "witness",
+	}
+
+	if err := post(ctx, h.HTTPClient, result.Event.CallbackURL, callback, nil); err != nil {
+		log.Errorf("failed to report status to callback: %v", err)
+	}
+}
+
+func (h *Handler) checkCallback(callbackURL string) error {
+	u, err := url.Parse(callbackURL)
+	if err != nil {
+		return fmt.Errorf("invalid callback url: %w", err)
+	}
+
+	if u.Scheme != "https" {
+		return fmt.Errorf("callback url %v is not https", callbackURL)
+	}
+
+	for _, host := range h.CallbackHosts {
+		if strings.EqualFold(u.Hostname(), host) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("callback host %v is not allowed", u.Hostname())
+}
+
+func (h *Handler) labelHarbor(ctx context.Context, result Result) {
+	if h.Harbor == nil || result.Event.Digest == "" {
+		return
+	}
+
+	if err := h.Harbor.Label(ctx, result); err != nil {
+		log.Errorf("failed to label %v@%v: %v", result.Event.Repository, result.Event.Digest, err)
+	}
+}
+
+// HarborLabels adds a label to each verified or failed artifact through Harbor's API, so Harbor's tag retention,
+// immutability, and replication rules can act on the result.
+type HarborLabels struct {
+	// URL is the base URL of the Harbor instance, such as https://harbor.example.com.
+	URL      string
+	Client   *http.Client
+	Username string
+	Password string
+	// VerifiedLabel and FailedLabel are the IDs of the labels to add. A zero ID adds no label.
+	VerifiedLabel int64
+	FailedLabel   int64
+}
+
+// Label adds the label for the result to the artifact in the result's event.
+func (l HarborLabels) Label(ctx context.Context, result Result) error {
+	label := l.FailedLabel
+	if result.Verified {
+		label = l.VerifiedLabel
+	}
+
+	if label == 0 {
+		return nil
+	}
+
+	parts := strings.SplitN(result.Event.Repository, "/", 2)
+	if len(parts) != 2 {
+		return fmt.Errorf("repository %v is not of the form project/repository", result.Event.Repository)
+	}
+
+	// harbor requires slashes in repository names to be escaped twice
+	labelURL := fmt.Sprintf("%v/api/v2.0/projects/%v/repositories/%v/artifacts/%v/labels", strings.TrimSuffix(l.URL, "/"),
+		url.PathEscape(parts[0]), url.PathEscape(url.PathEscape(parts[1])), url.PathEscape(result.Event.Digest))
+
+	client := l.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	return post(ctx, client, labelURL, map[string]int64{"id": label}, func(req *http.Request) {
+		req.SetBasicAuth(l.Username, l.Password)
+	})
+}
+
+func post(ctx context.Context, client *http.Client, url string, body interface{}, prepare func(*http.Request)) error {
+	b, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	if prepare != nil {
+		prepare(req)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %v", resp.Status)
+	}
+
+	return nil
+}
+
+// SplitDigest splits a digest of the form algorithm:hex.
+func SplitDigest(digest string) (string, string, error) {
+	parts := strings.SplitN(digest, ":", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", fmt.Errorf("invalid digest: %v", digest)
+	}
+
+	return parts[0], parts[1], nil
+}
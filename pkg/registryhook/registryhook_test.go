@@ -0,0 +1,270 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package registryhook
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/testifysec/witness/pkg/ocistore"
+)
+
+const harborPush = `{
+  "type": "PUSH_ARTIFACT",
+  "event_data": {
+    "resources": [{"digest": "sha256:abc", "tag": "latest"}],
+    "repository": {"repo_full_name": "library/app"}
+  }
+}`
+
+const testSecret = "webhook-secret"
+
+func TestParseHarbor(t *testing.T) {
+	events, err := ParseHarbor(strings.NewReader(harborPush))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(events) != 1 {
+		t.Fatalf("expected 1 event, got %d", len(events))
+	}
+
+	expected := Event{Repository: "library/app", Tag: "latest", Digest: "sha256:abc"}
+	if events[0] != expected {
+		t.Errorf("expected %+v, got %+v", expected, events[0])
+	}
+
+	events, err = ParseHarbor(strings.NewReader(`{"type": "DELETE_ARTIFACT"}`))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(events) != 0 {
+		t.Errorf("expected no events for non push payload, got %d", len(events))
+	}
+}
+
+func TestHandlerHarbor(t *testing.T) {
+	var notified Result
+	notify := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&notified); err != nil {
+			t.Error(err)
+		}
+	}))
+	defer notify.Close()
+
+	verify := func(ctx context.Context, event Event) ([]string, error) {
+		return []string{"evidence"}, nil
+	}
+
+	var labelPath string
+	var labelID map[string]int64
+	harbor := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		labelPath = r.URL.EscapedPath()
+		if user, password, ok := r.BasicAuth(); !ok || user != "robot" || password != "pass" {
+			t.Errorf("expected basic auth credentials, got %v %v", user, password)
+		}
+
+		if err := json.NewDecoder(r.Body).Decode(&labelID); err != nil {
+			t.Error(err)
+		}
+	}))
+	defer harbor.Close()
+
+	handler := NewHandler(verify, notify.URL, testSecret)
+	handler.Harbor = &HarborLabels{URL: harbor.URL, Username: "robot", Password: "pass", VerifiedLabel: 7, FailedLabel: 8}
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	req, err := http.NewRequest(http.MethodPost, server.URL+"/harbor", strings.NewReader(harborPush))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req.Header.Set("Authorization", testSecret)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer resp.Body.Close()
+	results := []Result{}
+	if err := json.NewDecoder(resp.Body).Decode(&results); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(results) != 1 || !results[0].Verified {
+		t.Fatalf("expected one verified result, got %+v", results)
+	}
+
+	if !notified.Verified || notified.Event.Digest != "sha256:abc" {
+		t.Errorf("unexpected notification: %+v", notified)
+	}
+
+	if labelPath != "/api/v2.0/projects/library/repositories/app/artifacts/sha256:abc/labels" || labelID["id"] != 7 {
+		t.Errorf("unexpected label request to %v: %+v", labelPath, labelID)
+	}
+}
+
+func TestHandlerRequiresSecret(t *testing.T) {
+	verify := func(ctx context.Context, event Event) ([]string, error) {
+		t.Error("verify should not be called for an unauthorized webhook")
+		return nil, nil
+	}
+
+	for _, secret := range []string{"", testSecret} {
+		server := httptest.NewServer(NewHandler(verify, "", secret))
+		resp, err := http.Post(server.URL+"/harbor?token=wrong", "application/json", strings.NewReader(harborPush))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		resp.Body.Close()
+		server.Close()
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("expected unauthorized status with secret %q, got %v", secret, resp.Status)
+		}
+	}
+}
+
+func TestHandlerBodyLimit(t *testing.T) {
+	verify := func(ctx context.Context, event Event) ([]string, error) {
+		return nil, nil
+	}
+
+	server := httptest.NewServer(NewHandler(verify, "", testSecret))
+	defer server.Close()
+
+	payload := `{"type": "PUSH_ARTIFACT", "padding": "` + strings.Repeat("a", MaxBodySize) + `"}`
+	resp, err := http.Post(server.URL+"/harbor?token="+testSecret, "application/json", strings.NewReader(payload))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("expected oversized payload to be rejected, got %v", resp.Status)
+	}
+}
+
+func TestHandlerDockerHubCallback(t *testing.T) {
+	var state map[string]string
+	callback := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&state); err != nil {
+			t.Error(err)
+		}
+	}))
+	defer callback.Close()
+
+	verify := func(ctx context.Context, event Event) ([]string, error) {
+		return nil, errors.New("should not be called without a digest")
+	}
+
+	handler := NewHandler(verify, "", testSecret)
+	handler.HTTPClient = callback.Client()
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	push := func() {
+		payload := `{"callback_url": "` + callback.URL + `", "push_data": {"tag": "latest"}, "repository": {"repo_name": "user/app"}}`
+		resp, err := http.Post(server.URL+"/dockerhub?token="+testSecret, "application/json", strings.NewReader(payload))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		resp.Body.Close()
+	}
+
+	push()
+	if state != nil {
+		t.Errorf("expected no status to be reported to a callback host that is not allowed, got %+v", state)
+	}
+
+	handler.CallbackHosts = []string{"127.0.0.1"}
+	push()
+	if state["state"] != "failure" {
+		t.Errorf("expected failure state to be reported, got %+v", state)
+	}
+}
+
+func TestHandlerDockerHubResolve(t *testing.T) {
+	manifest := []byte(`{"schemaVersion": 2, "mediaType": "application/vnd.oci.image.manifest.v1+json", "config": {"digest": "sha256:abc"}}`)
+	registry := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v2/user/app/manifests/latest" {
+			http.NotFound(w, r)
+			return
+		}
+
+		_, _ = w.Write(manifest)
+	}))
+	defer registry.Close()
+
+	var verified Event
+	verify := func(ctx context.Context, event Event) ([]string, error) {
+		verified = event
+		return nil, nil
+	}
+
+	handler := NewHandler(verify, "", testSecret)
+	handler.Resolve = TagResolver(ocistore.NewClient(), strings.TrimPrefix(registry.URL, "http://"))
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	push := func(tag string) []Result {
+		payload := `{"push_data": {"tag": "` + tag + `"}, "repository": {"repo_name": "user/app"}}`
+		resp, err := http.Post(server.URL+"/dockerhub?token="+testSecret, "application/json", strings.NewReader(payload))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		defer resp.Body.Close()
+		results := []Result{}
+		if err := json.NewDecoder(resp.Body).Decode(&results); err != nil {
+			t.Fatal(err)
+		}
+
+		return results
+	}
+
+	digest := fmt.Sprintf("sha256:%x", sha256.Sum256(manifest))
+	results := push("latest")
+	if len(results) != 1 || !results[0].Verified || results[0].Event.Digest != digest || verified.Digest != digest {
+		t.Errorf("expected the tag to be verified at digest %v, got %+v", digest, results)
+	}
+
+	verified = Event{}
+	results = push("missing")
+	if len(results) != 1 || results[0].Verified || verified.Repository != "" {
+		t.Errorf("expected a tag that can't be resolved to fail without verifying, got %+v", results)
+	}
+}
+
+func TestSplitDigest(t *testing.T) {
+	alg, hex, err := SplitDigest("sha256:abc")
+	if err != nil || alg != "sha256" || hex != "abc" {
+		t.Errorf("unexpected result: %v %v %v", alg, hex, err)
+	}
+
+	if _, _, err := SplitDigest("abc"); err == nil {
+		t.Error("expected error for digest without algorithm")
+	}
+}
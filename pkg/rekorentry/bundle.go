@@ -0,0 +1,196 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rekorentry
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/dsse"
+)
+
+// Bundle is a Rekor entry along with the proof that it is in the log: the signed entry timestamp, the inclusion proof,
+// and the checkpoint the proof leads to. A bundle can be verified against the log's public key without contacting
+// the Rekor server. A bundle written for an upload that failed holds only the pending upload, until it is resumed.
+type Bundle struct {
+	UUID           string        `json:"uuid,omitempty"`
+	Body           []byte        `json:"body,omitempty"`
+	IntegratedTime int64         `json:"integratedTime,omitempty"`
+	LogID          string        `json:"logID,omitempty"`
+	LogIndex       int64         `json:"logIndex,omitempty"`
+	Verification   *verification `json:"verification,omitempty"`
+	Pending        *Pending      `json:"pending,omitempty"`
+}
+
+// UUIDFromLocation returns the UUID of the entry at a location returned when the entry was created.
+func UUIDFromLocation(location string) string {
+	location = strings.TrimSuffix(location, "/")
+	return location[strings.LastIndex(location, "/")+1:]
+}
+
+// GetBundle returns the bundle of the entry with the UUID. Rekor servers that do not return inclusion proofs and
+// checkpoints with their entries can not produce bundles.
+func (c *Client) GetBundle(ctx context.Context, uuid string) (Bundle, error) {
+	entries, err := c.getEntries(ctx, uuid)
+	if err != nil {
+		return Bundle{}, err
+	}
+
+	for entryUUID, entry := range entries {
+		if c.PublicKey != nil {
+			if err := verifyEntry(entry, c.PublicKey); err != nil {
+				return Bundle{}, fmt.Errorf("failed to verify rekor entry %v: %w", entryUUID, err)
+			}
+		}
+
+		v := entry.Verification
+		if v == nil || len(v.SignedEntryTimestamp) == 0 || v.InclusionProof == nil || v.InclusionProof.Checkpoint == "" {
+			return Bundle{}, fmt.Errorf("rekor entry %v does not include a signed entry timestamp, inclusion proof, and checkpoint", entryUUID)
+		}
+
+		return Bundle{
+			UUID:           entryUUID,
+			Body:           entry.Body,
+			IntegratedTime: entry.IntegratedTime,
+			LogID:          entry.LogID,
+			LogIndex:       entry.LogIndex,
+			Verification:   entry.Verification,
+		}, nil
+	}
+
+	return Bundle{}, fmt.Errorf("rekor entry %v not found", uuid)
+}
+
+// VerifyBundle checks, without contacting Rekor, that the bundle's entry was signed by the log with the verifier's key,
+// is included in a checkpoint signed by it, and logs a signature of the envelope.
+func VerifyBundle(b Bundle, env dsse.Envelope, verifier cryptoutil.Verifier) error {
+	entry := logEntry{
+		Body:           b.Body,
+		IntegratedTime: b.IntegratedTime,
+		LogID:          b.LogID,
+		LogIndex:       b.LogIndex,
+		Verification:   b.Verification,
+	}
+
+	if err := verifyEntry(entry, verifier); err != nil {
+		return err
+	}
+
+	logged, err := loggedSignatures(b.Body)
+	if err != nil {
+		return err
+	}
+
+	for _, sig := range env.Signatures {
+		for _, loggedSig := range logged {
+			if len(sig.Signature) > 0 && bytes.Equal(sig.Signature, loggedSig) {
+				return nil
+			}
+		}
+	}
+
+	return fmt.Errorf("rekor entry %v does not log a signature of the envelope", b.UUID)
+}
+
+// IncludedIn returns the names of the logs, keyed by name with their public keys, that one of the bundles proves the
+// envelope was logged in, sorted. Pending uploads and bundles from logs that aren't listed prove nothing.
+func IncludedIn(bundles []Bundle, env dsse.Envelope, logs map[string]cryptoutil.Verifier) []string {
+	names := make([]string, 0, len(logs))
+	for name, verifier := range logs {
+		for _, b := range bundles {
+			if b.Pending == nil && VerifyBundle(b, env, verifier) == nil {
+				names = append(names, name)
+				break
+			}
+		}
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// loggedSignatures returns the envelope signatures an entry body records. Entries store signatures differently by
+// kind: dsse entries base64 encode them once, under signature or, for witness's Rekor, sig, and intoto entries encode
+// them twice.
+func loggedSignatures(body []byte) ([][]byte, error) {
+	decoded := entryBody{}
+	if err := json.Unmarshal(body, &decoded); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal entry body: %w", err)
+	}
+
+	type signature struct {
+		Signature string `json:"signature"`
+		Sig       string `json:"sig"`
+	}
+
+	signatures := []signature{}
+	switch decoded.Kind {
+	case DSSE:
+		spec := struct {
+			Signatures []signature `json:"signatures"`
+		}{}
+
+		if err := json.Unmarshal(decoded.Spec, &spec); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal dsse entry: %w", err)
+		}
+
+		signatures = spec.Signatures
+	case Intoto:
+		spec := struct {
+			Content struct {
+				Envelope struct {
+					Signatures []signature `json:"signatures"`
+				} `json:"envelope"`
+			} `json:"content"`
+		}{}
+
+		if err := json.Unmarshal(decoded.Spec, &spec); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal intoto entry: %w", err)
+		}
+
+		signatures = spec.Content.Envelope.Signatures
+	default:
+		return nil, ErrUnknownKind(decoded.Kind)
+	}
+
+	logged := make([][]byte, 0, len(signatures))
+	for _, sig := range signatures {
+		var (
+			value []byte
+			err   error
+		)
+
+		switch {
+		case decoded.Kind == Intoto:
+			value, err = decodeTwice(sig.Sig)
+		case sig.Signature != "":
+			value, err = base64.StdEncoding.DecodeString(sig.Signature)
+		default:
+			value, err = base64.StdEncoding.DecodeString(sig.Sig)
+		}
+
+		if err == nil {
+			logged = append(logged, value)
+		}
+	}
+
+	return logged, nil
+}
@@ -0,0 +1,201 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rekorentry
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/dsse"
+)
+
+func TestUUIDFromLocation(t *testing.T) {
+	for location, expected := range map[string]string{
+		"/api/v1/log/entries/abcd":                           "abcd",
+		"https://rekor.example.com/api/v1/log/entries/abcd/": "abcd",
+		"abcd": "abcd",
+	} {
+		if uuid := UUIDFromLocation(location); uuid != expected {
+			t.Errorf("expected %v for %v, got %v", expected, location, uuid)
+		}
+	}
+}
+
+func TestBundle(t *testing.T) {
+	l := newTestLog(t)
+	env := testEnvelope(t, nil)
+	unproven := l.entry(intotoBody(t, env), 0)
+	delete(unproven["verification"].(map[string]interface{}), "inclusionProof")
+
+	fake := &fakeRekor{t: t, entries: map[string]map[string]interface{}{
+		"valid":     l.entry(intotoBody(t, env), 2),
+		"unproven":  unproven,
+		"other-log": newTestLog(t).entry(intotoBody(t, env), 2),
+	}}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	verifier, err := l.signer.Verifier()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := New(server.URL)
+	bundle, err := c.GetBundle(context.Background(), "valid")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.GetBundle(context.Background(), "unproven"); err == nil {
+		t.Error("expected an entry without an inclusion proof to have no bundle")
+	}
+
+	c.PublicKey = verifier
+	if _, err := c.GetBundle(context.Background(), "other-log"); err == nil {
+		t.Error("expected an entry from another log to fail verification")
+	}
+
+	// bundles are verified from their json without the server
+	server.Close()
+	bundleJSON, err := json.Marshal(bundle)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	bundle = Bundle{}
+	if err := json.Unmarshal(bundleJSON, &bundle); err != nil {
+		t.Fatal(err)
+	}
+
+	if bundle.UUID != "valid" || bundle.LogIndex != 1002 {
+		t.Fatalf("unexpected bundle: %+v", bundle)
+	}
+
+	if err := VerifyBundle(bundle, env, verifier); err != nil {
+		t.Fatal(err)
+	}
+
+	otherEnv := testEnvelope(t, nil)
+	otherEnv.Signatures = []dsse.Signature{{KeyID: "key", Signature: []byte("other signature")}}
+	if err := VerifyBundle(bundle, otherEnv, verifier); err == nil {
+		t.Error("expected a bundle to fail for an envelope it does not log")
+	}
+
+	otherVerifier, err := newTestLog(t).signer.Verifier()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := VerifyBundle(bundle, env, otherVerifier); err == nil {
+		t.Error("expected a bundle to fail against another log's key")
+	}
+
+	tampered := bundle
+	tampered.IntegratedTime++
+	if err := VerifyBundle(tampered, env, verifier); err == nil {
+		t.Error("expected a bundle with an altered integrated time to fail")
+	}
+}
+
+func TestLoggedSignatures(t *testing.T) {
+	sig := base64.StdEncoding.EncodeToString([]byte("signature"))
+	for name, spec := range map[string]map[string]interface{}{
+		"signature": {"signatures": []map[string]string{{"signature": sig}}},
+		"sig":       {"signatures": []map[string]string{{"sig": sig}}},
+	} {
+		body, err := json.Marshal(map[string]interface{}{"kind": DSSE, "apiVersion": "0.0.1", "spec": spec})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		logged, err := loggedSignatures(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(logged) != 1 || string(logged[0]) != "signature" {
+			t.Errorf("%v: unexpected signatures %q", name, logged)
+		}
+	}
+
+	logged, err := loggedSignatures(intotoBody(t, testEnvelope(t, nil)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(logged) != 1 || string(logged[0]) != "signature" {
+		t.Errorf("intoto: unexpected signatures %q", logged)
+	}
+}
+
+func TestIncludedIn(t *testing.T) {
+	env := testEnvelope(t, nil)
+	public, private, unlisted := newTestLog(t), newTestLog(t), newTestLog(t)
+	bundle := func(l *testLog) Bundle {
+		entryJSON, err := json.Marshal(l.entry(intotoBody(t, env), 1))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		b := Bundle{}
+		if err := json.Unmarshal(entryJSON, &b); err != nil {
+			t.Fatal(err)
+		}
+
+		return b
+	}
+
+	logs := map[string]cryptoutil.Verifier{}
+	for name, l := range map[string]*testLog{"public": public, "private": private} {
+		verifier, err := l.signer.Verifier()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		logs[name] = verifier
+	}
+
+	pending := Bundle{Pending: &Pending{Server: "https://rekor.example.com"}}
+	tests := []struct {
+		name    string
+		bundles []Bundle
+		want    []string
+	}{
+		{"no bundles", nil, []string{}},
+		{"one log", []Bundle{bundle(public), pending}, []string{"public"}},
+		{"both logs", []Bundle{bundle(private), bundle(unlisted), bundle(public)}, []string{"private", "public"}},
+		{"unlisted log", []Bundle{bundle(unlisted)}, []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := IncludedIn(tt.bundles, env, logs)
+			if strings.Join(got, ",") != strings.Join(tt.want, ",") {
+				t.Fatalf("expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+
+	other := testEnvelope(t, nil)
+	other.Signatures = []dsse.Signature{{KeyID: "key", Signature: []byte("other signature")}}
+	if got := IncludedIn([]Bundle{bundle(public)}, other, logs); len(got) != 0 {
+		t.Fatalf("expected a bundle to prove nothing for an envelope it does not log, got %v", got)
+	}
+}
@@ -0,0 +1,488 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package rekorentry creates and reads Rekor entries of both the dsse and intoto kinds over Rekor's REST API.
+// Deployments of Rekor index attestations under different kinds, and tools other than witness often upload intoto
+// entries, so searches that only understand one kind miss evidence.
+package rekorentry
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/dsse"
+	"github.com/testifysec/go-witness/intoto"
+	"github.com/testifysec/go-witness/log"
+	"github.com/testifysec/witness/pkg/sslib"
+)
+
+const (
+	// DSSE is Rekor's dsse entry kind, created with API version 0.0.1.
+	DSSE = "dsse"
+	// Intoto is Rekor's intoto entry kind, created with API version 0.0.2.
+	Intoto = "intoto"
+
+	refString = "%s/api/v1/log/entries?logIndex=%d"
+)
+
+var (
+	apiVersions = map[string]string{DSSE: "0.0.1", Intoto: "0.0.2"}
+	// searchHashes are the digests Rekor's index can be searched by.
+	searchHashes = map[crypto.Hash]string{crypto.SHA256: "sha256", crypto.SHA1: "sha1"}
+	// backRefs are the subjects followed to find evidence from earlier steps, matching go-witness.
+	backRefs = []string{
+		"https://witness.dev/attestations/gitlab/v0.1/pipelineurl",
+		"https://witness.dev/attestations/git/v0.1/commithash",
+	}
+)
+
+type ErrUnknownKind string
+
+func (e ErrUnknownKind) Error() string {
+	return fmt.Sprintf("unknown rekor entry kind %v, expected %v or %v", string(e), DSSE, Intoto)
+}
+
+// Client talks to a Rekor server.
+type Client struct {
+	URL        string
+	HTTPClient *http.Client
+	// PublicKey is the Rekor server's pinned public key. If set, entries are only read if their signed entry timestamp
+	// and the signed tree head they are included in are signed by it, rather than trusting the server's TLS
+	// certificate alone.
+	PublicKey cryptoutil.Verifier
+}
+
+// Entry is a log entry holding a DSSE envelope.
+type Entry struct {
+	UUID     string
+	LogIndex int64
+	Kind     string
+	Envelope dsse.Envelope
+}
+
+type logEntry struct {
+	Body           []byte        `json:"body"`
+	IntegratedTime int64         `json:"integratedTime"`
+	LogID          string        `json:"logID"`
+	LogIndex       int64         `json:"logIndex"`
+	Verification   *verification `json:"verification"`
+	Attestation    *struct {
+		Data []byte `json:"data"`
+	} `json:"attestation"`
+}
+
+type entryBody struct {
+	Kind       string          `json:"kind"`
+	APIVersion string          `json:"apiVersion"`
+	Spec       json.RawMessage `json:"spec"`
+}
+
+func New(url string) *Client {
+	return &Client{URL: strings.TrimSuffix(url, "/"), HTTPClient: http.DefaultClient}
+}
+
+// ValidateKind returns an error if kind is not an entry kind witness can create.
+func ValidateKind(kind string) error {
+	if _, ok := apiVersions[kind]; !ok {
+		return ErrUnknownKind(kind)
+	}
+
+	return nil
+}
+
+// ProposedEntry returns the request body that creates an entry of the kind for the envelope, signed by the key with
+// the PEM encoded publicKey.
+func ProposedEntry(kind string, env dsse.Envelope, publicKey []byte) ([]byte, error) {
+	if err := ValidateKind(kind); err != nil {
+		return nil, err
+	}
+
+	var spec interface{}
+	switch kind {
+	case DSSE:
+		envJSON, err := json.Marshal(env)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal envelope: %w", err)
+		}
+
+		spec = map[string]interface{}{
+			"proposedContent": map[string]interface{}{
+				"envelope":  string(envJSON),
+				"verifiers": [][]byte{publicKey},
+			},
+		}
+
+	case Intoto:
+		// intoto v0.0.2 expects the payload and signatures base64 encoded a second time
+		signatures := make([]map[string]interface{}, 0, len(env.Signatures))
+		for _, sig := range env.Signatures {
+			signatures = append(signatures, map[string]interface{}{
+				"keyid":     sig.KeyID,
+				"sig":       encodeTwice(sig.Signature),
+				"publicKey": publicKey,
+			})
+		}
+
+		spec = map[string]interface{}{
+			"content": map[string]interface{}{
+				"envelope": map[string]interface{}{
+					"payloadType": env.PayloadType,
+					"payload":     encodeTwice(env.Payload),
+					"signatures":  signatures,
+				},
+			},
+		}
+	}
+
+	return json.Marshal(map[string]interface{}{
+		"apiVersion": apiVersions[kind],
+		"kind":       kind,
+		"spec":       spec,
+	})
+}
+
+// Store creates an entry of the kind for the envelope and returns the entry's URL.
+func (c *Client) Store(ctx context.Context, kind string, env dsse.Envelope, publicKey []byte) (string, error) {
+	proposed, err := ProposedEntry(kind, env, publicKey)
+	if err != nil {
+		return "", err
+	}
+
+	location, err := c.create(ctx, proposed)
+	if err != nil {
+		return "", fmt.Errorf("failed to create rekor entry: %w", err)
+	}
+
+	return location, nil
+}
+
+// Search returns the UUIDs of the entries indexed under any of the digests.
+func (c *Client) Search(ctx context.Context, ds cryptoutil.DigestSet) ([]string, error) {
+	uuids := make([]string, 0)
+	for hash, name := range searchHashes {
+		value, ok := ds[hash]
+		if !ok {
+			continue
+		}
+
+		query, err := json.Marshal(map[string]string{"hash": fmt.Sprintf("%v:%v", name, value)})
+		if err != nil {
+			return nil, err
+		}
+
+		resp, err := c.do(ctx, http.MethodPost, "/api/v1/index/retrieve", query)
+		if err != nil {
+			return nil, fmt.Errorf("failed to search rekor: %w", err)
+		}
+
+		found := []string{}
+		err = decodeResponse(resp, &found)
+		if err != nil {
+			return nil, fmt.Errorf("failed to search rekor: %w", err)
+		}
+
+		uuids = append(uuids, found...)
+	}
+
+	return uuids, nil
+}
+
+// Get returns the entry with the UUID.
+func (c *Client) Get(ctx context.Context, uuid string) (Entry, error) {
+	entries, err := c.getEntries(ctx, uuid)
+	if err != nil {
+		return Entry{}, err
+	}
+
+	for entryUUID, entry := range entries {
+		if c.PublicKey != nil {
+			if err := verifyEntry(entry, c.PublicKey); err != nil {
+				return Entry{}, fmt.Errorf("failed to verify rekor entry %v: %w", entryUUID, err)
+			}
+		}
+
+		return ParseEntry(entryUUID, entry.Body, entry.LogIndex, attestationData(entry))
+	}
+
+	return Entry{}, fmt.Errorf("rekor entry %v not found", uuid)
+}
+
+func (c *Client) getEntries(ctx context.Context, uuid string) (map[string]logEntry, error) {
+	resp, err := c.do(ctx, http.MethodGet, "/api/v1/log/entries/"+uuid, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rekor entry %v: %w", uuid, err)
+	}
+
+	entries := map[string]logEntry{}
+	if err := decodeResponse(resp, &entries); err != nil {
+		return nil, fmt.Errorf("failed to get rekor entry %v: %w", uuid, err)
+	}
+
+	return entries, nil
+}
+
+// EntryURL returns the URL of the entry at the log index.
+func (c *Client) EntryURL(logIndex int64) string {
+	return fmt.Sprintf(refString, c.URL, logIndex)
+}
+
+func attestationData(entry logEntry) []byte {
+	if entry.Attestation == nil {
+		return nil
+	}
+
+	return entry.Attestation.Data
+}
+
+// ParseEntry reads the envelope from an entry. Rekor stores the envelope of dsse and intoto entries as the entry's
+// attestation. Entries created by older Rekor servers may hold the envelope in their body instead.
+func ParseEntry(uuid string, body []byte, logIndex int64, attestation []byte) (Entry, error) {
+	entry := Entry{UUID: uuid, LogIndex: logIndex}
+	decoded := entryBody{}
+	if err := json.Unmarshal(body, &decoded); err != nil {
+		return entry, fmt.Errorf("failed to unmarshal entry body: %w", err)
+	}
+
+	entry.Kind = decoded.Kind
+	if len(attestation) > 0 {
+		env, err := sslib.ParseEnvelope(attestation)
+		if err == nil {
+			entry.Envelope = env
+			return entry, nil
+		}
+	}
+
+	var err error
+	switch decoded.Kind {
+	case DSSE:
+		entry.Envelope, err = envelopeFromDSSESpec(decoded.Spec)
+	case Intoto:
+		entry.Envelope, err = envelopeFromIntotoSpec(decoded.Spec)
+	default:
+		err = ErrUnknownKind(decoded.Kind)
+	}
+
+	return entry, err
+}
+
+func envelopeFromDSSESpec(spec json.RawMessage) (dsse.Envelope, error) {
+	dsseSpec := struct {
+		Envelope json.RawMessage `json:"envelope"`
+	}{}
+
+	if err := json.Unmarshal(spec, &dsseSpec); err != nil || len(dsseSpec.Envelope) == 0 {
+		return dsse.Envelope{}, fmt.Errorf("dsse entry does not include its envelope")
+	}
+
+	// the envelope is stored either as an object or as a string holding its json
+	envJSON := []byte(dsseSpec.Envelope)
+	var s string
+	if err := json.Unmarshal(dsseSpec.Envelope, &s); err == nil {
+		envJSON = []byte(s)
+	}
+
+	return sslib.ParseEnvelope(envJSON)
+}
+
+func envelopeFromIntotoSpec(spec json.RawMessage) (dsse.Envelope, error) {
+	intotoSpec := struct {
+		Content struct {
+			Envelope *struct {
+				PayloadType string `json:"payloadType"`
+				Payload     string `json:"payload"`
+				Signatures  []struct {
+					KeyID string `json:"keyid"`
+					Sig   string `json:"sig"`
+				} `json:"signatures"`
+			} `json:"envelope"`
+		} `json:"content"`
+	}{}
+
+	if err := json.Unmarshal(spec, &intotoSpec); err != nil {
+		return dsse.Envelope{}, fmt.Errorf("failed to unmarshal intoto entry: %w", err)
+	}
+
+	raw := intotoSpec.Content.Envelope
+	if raw == nil || raw.Payload == "" {
+		return dsse.Envelope{}, fmt.Errorf("intoto entry does not include its payload")
+	}
+
+	payload, err := decodeTwice(raw.Payload)
+	if err != nil {
+		return dsse.Envelope{}, fmt.Errorf("failed to decode intoto payload: %w", err)
+	}
+
+	env := dsse.Envelope{PayloadType: raw.PayloadType, Payload: payload}
+	for _, sig := range raw.Signatures {
+		decoded, err := decodeTwice(sig.Sig)
+		if err != nil {
+			return dsse.Envelope{}, fmt.Errorf("failed to decode intoto signature: %w", err)
+		}
+
+		env.Signatures = append(env.Signatures, dsse.Signature{KeyID: sig.KeyID, Signature: decoded})
+	}
+
+	return env, nil
+}
+
+func encodeTwice(b []byte) string {
+	return base64.StdEncoding.EncodeToString([]byte(base64.StdEncoding.EncodeToString(b)))
+}
+
+func decodeTwice(s string) ([]byte, error) {
+	once, err := base64.StdEncoding.DecodeString(s)
+	if err != nil {
+		return nil, err
+	}
+
+	return base64.StdEncoding.DecodeString(string(once))
+}
+
+// Evidence is an envelope and a reference to where it was found, such as the URL of its Rekor entry.
+type Evidence struct {
+	Envelope  dsse.Envelope
+	Reference string
+}
+
+// VerifyFunc evaluates the candidate evidence against a policy and returns the evidence that satisfied it.
+type VerifyFunc func([]Evidence) ([]Evidence, error)
+
+// FindEvidence searches Rekor for entries of any kind indexed under the subjects and evaluates them, with the known
+// evidence, using verify. If verification fails, the back reference subjects of the entries found, such as git
+// commits, are searched in turn, up to depth times.
+func (c *Client) FindEvidence(ctx context.Context, subjects []cryptoutil.DigestSet, known []Evidence, verify VerifyFunc, depth int) ([]Evidence, error) {
+	searched := map[string]bool{}
+	candidates := append([]Evidence{}, known...)
+	for i := 0; ; i++ {
+		found := make([]Evidence, 0)
+		for _, ds := range subjects {
+			uuids, err := c.Search(ctx, ds)
+			if err != nil {
+				return nil, err
+			}
+
+			for _, uuid := range uuids {
+				if searched[uuid] {
+					continue
+				}
+
+				searched[uuid] = true
+				entry, err := c.Get(ctx, uuid)
+				if err != nil {
+					log.Debugf("(rekor) skipping entry %v: %v", uuid, err)
+					continue
+				}
+
+				found = append(found, Evidence{
+					Envelope:  entry.Envelope,
+					Reference: c.EntryURL(entry.LogIndex),
+				})
+			}
+		}
+
+		candidates = append(candidates, found...)
+		verified, err := verify(candidates)
+		if err == nil {
+			return dedupe(verified), nil
+		}
+
+		subjects = backRefSubjects(found)
+		if i >= depth || len(subjects) == 0 {
+			return nil, err
+		}
+	}
+}
+
+func dedupe(envelopes []Evidence) []Evidence {
+	seen := map[string]bool{}
+	deduped := make([]Evidence, 0, len(envelopes))
+	for _, e := range envelopes {
+		if !seen[e.Reference] {
+			seen[e.Reference] = true
+			deduped = append(deduped, e)
+		}
+	}
+
+	return deduped
+}
+
+func backRefSubjects(envelopes []Evidence) []cryptoutil.DigestSet {
+	subjects := make([]cryptoutil.DigestSet, 0)
+	for _, e := range envelopes {
+		statement := intoto.Statement{}
+		if err := json.Unmarshal(e.Envelope.Payload, &statement); err != nil {
+			continue
+		}
+
+		for _, subject := range statement.Subject {
+			for _, prefix := range backRefs {
+				if !strings.HasPrefix(subject.Name, prefix) {
+					continue
+				}
+
+				ds := cryptoutil.DigestSet{}
+				for hash, name := range searchHashes {
+					if value, ok := subject.Digest[name]; ok {
+						ds[hash] = value
+					}
+				}
+
+				subjects = append(subjects, ds)
+			}
+		}
+	}
+
+	return subjects
+}
+
+func (c *Client) do(ctx context.Context, method, path string, body []byte) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.URL+path, reader)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	return c.HTTPClient.Do(req)
+}
+
+func decodeResponse(resp *http.Response, v interface{}) error {
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return responseError(resp)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+func responseError(resp *http.Response) error {
+	message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return fmt.Errorf("unexpected status %v: %s", resp.Status, bytes.TrimSpace(message))
+}
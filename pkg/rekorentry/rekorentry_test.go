@@ -0,0 +1,207 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rekorentry
+
+import (
+	"context"
+	"crypto"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/dsse"
+	"github.com/testifysec/go-witness/intoto"
+)
+
+func testEnvelope(t *testing.T, subjects map[string]string) dsse.Envelope {
+	statement := intoto.Statement{Type: intoto.StatementType, PredicateType: "https://example.com/predicate", Predicate: json.RawMessage(`{}`)}
+	for name, digest := range subjects {
+		statement.Subject = append(statement.Subject, intoto.Subject{Name: name, Digest: map[string]string{"sha256": digest}})
+	}
+
+	payload, err := json.Marshal(statement)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return dsse.Envelope{
+		PayloadType: intoto.PayloadType,
+		Payload:     payload,
+		Signatures:  []dsse.Signature{{KeyID: "key", Signature: []byte("signature")}},
+	}
+}
+
+func intotoBody(t *testing.T, env dsse.Envelope) []byte {
+	proposed, err := ProposedEntry(Intoto, env, []byte("public key"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return proposed
+}
+
+func dsseBody(t *testing.T) []byte {
+	body, err := json.Marshal(map[string]interface{}{"kind": DSSE, "apiVersion": "0.0.1", "spec": map[string]interface{}{"payloadHash": map[string]string{}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return body
+}
+
+type fakeRekor struct {
+	t       *testing.T
+	index   map[string][]string
+	entries map[string]map[string]interface{}
+	created []entryBody
+}
+
+func (f *fakeRekor) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == "/api/v1/log/entries":
+		body := entryBody{}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			f.t.Fatal(err)
+		}
+
+		f.created = append(f.created, body)
+		w.Header().Set("Location", "/api/v1/log/entries/new")
+		w.WriteHeader(http.StatusCreated)
+	case r.Method == http.MethodPost && r.URL.Path == "/api/v1/index/retrieve":
+		query := map[string]string{}
+		if err := json.NewDecoder(r.Body).Decode(&query); err != nil {
+			f.t.Fatal(err)
+		}
+
+		uuids := f.index[query["hash"]]
+		if uuids == nil {
+			uuids = []string{}
+		}
+
+		_ = json.NewEncoder(w).Encode(uuids)
+	case r.Method == http.MethodGet:
+		uuid := r.URL.Path[len("/api/v1/log/entries/"):]
+		entry, ok := f.entries[uuid]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{uuid: entry})
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestProposedEntry(t *testing.T) {
+	env := testEnvelope(t, nil)
+	proposed := intotoBody(t, env)
+	body := entryBody{}
+	if err := json.Unmarshal(proposed, &body); err != nil {
+		t.Fatal(err)
+	}
+
+	if body.Kind != Intoto || body.APIVersion != "0.0.2" {
+		t.Fatalf("unexpected entry kind %v version %v", body.Kind, body.APIVersion)
+	}
+
+	parsed, err := envelopeFromIntotoSpec(body.Spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(parsed.Payload) != string(env.Payload) || string(parsed.Signatures[0].Signature) != "signature" {
+		t.Errorf("intoto entry does not round trip: %+v", parsed)
+	}
+
+	if _, err := ProposedEntry("rekord", env, nil); !errors.As(err, new(ErrUnknownKind)) {
+		t.Errorf("expected unknown kind error, got %v", err)
+	}
+}
+
+func TestStore(t *testing.T) {
+	fake := &fakeRekor{t: t}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	c := New(server.URL)
+	for _, kind := range []string{DSSE, Intoto} {
+		location, err := c.Store(context.Background(), kind, testEnvelope(t, nil), []byte("public key"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if location != server.URL+"/api/v1/log/entries/new" {
+			t.Errorf("unexpected location %v", location)
+		}
+	}
+
+	if len(fake.created) != 2 || fake.created[0].Kind != DSSE || fake.created[1].Kind != Intoto {
+		t.Errorf("unexpected entries created: %+v", fake.created)
+	}
+}
+
+func TestFindEvidence(t *testing.T) {
+	artifact := "aaaa"
+	commit := "bbbb"
+	build := testEnvelope(t, map[string]string{"https://witness.dev/attestations/product/v0.1/file:app": artifact, "https://witness.dev/attestations/git/v0.1/commithash:" + commit: commit})
+	source := testEnvelope(t, map[string]string{"https://witness.dev/attestations/git/v0.1/commithash:" + commit: commit})
+	sourceJSON, err := json.Marshal(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fake := &fakeRekor{
+		t: t,
+		index: map[string][]string{
+			"sha256:" + artifact: {"build"},
+			"sha256:" + commit:   {"build", "source"},
+		},
+		entries: map[string]map[string]interface{}{
+			"build":  {"body": base64.StdEncoding.EncodeToString(intotoBody(t, build)), "logIndex": 1},
+			"source": {"body": base64.StdEncoding.EncodeToString(dsseBody(t)), "logIndex": 2, "attestation": map[string]string{"data": base64.StdEncoding.EncodeToString(sourceJSON)}},
+		},
+	}
+
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	c := New(server.URL)
+	verify := func(candidates []Evidence) ([]Evidence, error) {
+		if len(candidates) < 2 {
+			return nil, fmt.Errorf("expected build and source evidence")
+		}
+
+		return append(candidates, candidates[0]), nil
+	}
+
+	evidence, err := c.FindEvidence(context.Background(), []cryptoutil.DigestSet{{crypto.SHA256: artifact}}, nil, verify, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(evidence) != 2 || evidence[0].Reference != fmt.Sprintf(refString, server.URL, 1) || evidence[1].Reference != fmt.Sprintf(refString, server.URL, 2) {
+		t.Fatalf("unexpected evidence: %+v", evidence)
+	}
+
+	if _, err := c.FindEvidence(context.Background(), []cryptoutil.DigestSet{{crypto.SHA256: artifact}}, nil, verify, 0); err == nil {
+		t.Error("expected back references not to be followed with a depth of 0")
+	}
+}
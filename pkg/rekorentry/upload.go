@@ -0,0 +1,172 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rekorentry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// UploadOptions controls how Upload retries failed attempts.
+type UploadOptions struct {
+	// Retries is how many times a failed attempt is retried. Entries Rekor rejects with a 4xx status other than 429
+	// are not retried.
+	Retries int
+	// Backoff is the wait before the first retry. It doubles after each retry.
+	Backoff time.Duration
+}
+
+func DefaultUploadOptions() UploadOptions {
+	return UploadOptions{
+		Retries: 3,
+		Backoff: time.Second,
+	}
+}
+
+// Pending is an upload that failed. It holds the proposed entry, which already contains the signed envelope and the
+// signer's public key, so the upload can be resumed without the signer.
+type Pending struct {
+	Server      string          `json:"server"`
+	Kind        string          `json:"kind"`
+	Entry       json.RawMessage `json:"entry"`
+	Error       string          `json:"error"`
+	LastAttempt time.Time       `json:"lastAttempt"`
+}
+
+type errStatus struct {
+	status    string
+	message   []byte
+	retryable bool
+}
+
+func (e errStatus) Error() string {
+	return fmt.Sprintf("unexpected status %v: %s", e.status, e.message)
+}
+
+// Upload creates the proposed entry and returns its location, retrying attempts that fail because Rekor couldn't be
+// reached or was overloaded. An entry Rekor already has, such as one created by an attempt whose response was lost,
+// is returned as if it was created.
+func (c *Client) Upload(ctx context.Context, proposed []byte, opts UploadOptions) (string, error) {
+	backoff := opts.Backoff
+	for attempt := 0; ; attempt++ {
+		location, err := c.create(ctx, proposed)
+		if err == nil {
+			return location, nil
+		}
+
+		if attempt >= opts.Retries || !retryable(err) {
+			return "", fmt.Errorf("failed to create rekor entry after %d attempts: %w", attempt+1, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+	}
+}
+
+// create makes a single attempt to create the proposed entry. Rekor answers 409 with the existing entry's location
+// when it already has the entry.
+func (c *Client) create(ctx context.Context, proposed []byte) (string, error) {
+	resp, err := c.do(ctx, http.MethodPost, "/api/v1/log/entries", proposed)
+	if err != nil {
+		return "", err
+	}
+
+	defer resp.Body.Close()
+	location := resp.Header.Get("Location")
+	if resp.StatusCode != http.StatusCreated && (resp.StatusCode != http.StatusConflict || location == "") {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return "", errStatus{
+			status:    resp.Status,
+			message:   bytes.TrimSpace(message),
+			retryable: resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500,
+		}
+	}
+
+	if strings.HasPrefix(location, "/") {
+		location = c.URL + location
+	}
+
+	return location, nil
+}
+
+// retryable returns true for errors that may succeed if tried again, which are the errors of an unreachable or
+// overloaded server rather than one that rejected the entry.
+func retryable(err error) bool {
+	var status errStatus
+	if errors.As(err, &status) {
+		return status.retryable
+	}
+
+	return !errors.Is(err, context.Canceled)
+}
+
+// ReadBundle reads a bundle, or a pending upload recorded in its place, from a file.
+func ReadBundle(path string) (Bundle, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Bundle{}, err
+	}
+
+	bundle := Bundle{}
+	if err := json.Unmarshal(data, &bundle); err != nil {
+		return Bundle{}, fmt.Errorf("failed to parse rekor bundle %v: %w", path, err)
+	}
+
+	return bundle, nil
+}
+
+// WriteBundle writes the bundle to path, through a temporary file so a bundle being resumed is never left partially
+// written.
+func WriteBundle(path string, bundle Bundle) error {
+	data, err := json.MarshalIndent(bundle, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".rekor-*")
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), path)
+}
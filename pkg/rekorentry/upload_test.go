@@ -0,0 +1,108 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rekorentry
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestUploadRetries(t *testing.T) {
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if attempts < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		w.Header().Set("Location", "/api/v1/log/entries/new")
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	c := New(server.URL)
+	proposed := intotoBody(t, testEnvelope(t, nil))
+	if _, err := c.Upload(context.Background(), proposed, UploadOptions{Retries: 1}); err == nil {
+		t.Fatal("expected the upload to fail once its retries ran out")
+	}
+
+	location, err := c.Upload(context.Background(), proposed, UploadOptions{Retries: 1, Backoff: time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if location != server.URL+"/api/v1/log/entries/new" || attempts != 3 {
+		t.Fatalf("unexpected location %v after %d attempts", location, attempts)
+	}
+}
+
+func TestUploadExistingEntry(t *testing.T) {
+	status := http.StatusConflict
+	attempts := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts++
+		if status == http.StatusConflict {
+			w.Header().Set("Location", "/api/v1/log/entries/existing")
+		}
+
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	c := New(server.URL)
+	proposed := intotoBody(t, testEnvelope(t, nil))
+	location, err := c.Upload(context.Background(), proposed, DefaultUploadOptions())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if location != server.URL+"/api/v1/log/entries/existing" {
+		t.Errorf("expected the existing entry's location, got %v", location)
+	}
+
+	status, attempts = http.StatusBadRequest, 0
+	if _, err := c.Upload(context.Background(), proposed, DefaultUploadOptions()); err == nil || attempts != 1 {
+		t.Errorf("expected a rejected entry to fail without retrying, got %v after %d attempts", err, attempts)
+	}
+}
+
+func TestPendingBundle(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "build.json.rekor.json")
+	proposed := intotoBody(t, testEnvelope(t, nil))
+	pending := Bundle{Pending: &Pending{Server: "https://rekor.example.com", Kind: Intoto, Entry: proposed, Error: "unreachable"}}
+	if err := WriteBundle(path, pending); err != nil {
+		t.Fatal(err)
+	}
+
+	bundle, err := ReadBundle(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if bundle.Pending == nil || bundle.UUID != "" || bundle.Pending.Kind != Intoto {
+		t.Fatalf("unexpected bundle %+v", bundle)
+	}
+
+	body := entryBody{}
+	if err := json.Unmarshal(bundle.Pending.Entry, &body); err != nil || body.Kind != Intoto {
+		t.Fatalf("expected the proposed entry to round trip, got %+v: %v", body, err)
+	}
+}
@@ -0,0 +1,197 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rekorentry
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/witness/pkg/tlog"
+)
+
+// verification is the proof Rekor returns with an entry that the entry is in its log.
+type verification struct {
+	SignedEntryTimestamp []byte          `json:"signedEntryTimestamp"`
+	InclusionProof       *inclusionProof `json:"inclusionProof"`
+}
+
+type inclusionProof struct {
+	LogIndex   int64    `json:"logIndex"`
+	TreeSize   int64    `json:"treeSize"`
+	RootHash   string   `json:"rootHash"`
+	Hashes     []string `json:"hashes"`
+	Checkpoint string   `json:"checkpoint"`
+}
+
+// LogID returns the id Rekor identifies its log by, the hex encoded sha256 digest of the DER encoded public key.
+func LogID(verifier cryptoutil.Verifier) (string, error) {
+	pemBytes, err := verifier.Bytes()
+	if err != nil {
+		return "", fmt.Errorf("failed to encode rekor public key: %w", err)
+	}
+
+	block, _ := pem.Decode(pemBytes)
+	if block == nil {
+		return "", fmt.Errorf("rekor public key is not pem encoded")
+	}
+
+	digest := sha256.Sum256(block.Bytes)
+	return hex.EncodeToString(digest[:]), nil
+}
+
+// verifyEntry checks that the entry was signed by the Rekor server's key and is included in a tree head signed by it.
+func verifyEntry(entry logEntry, verifier cryptoutil.Verifier) error {
+	logID, err := LogID(verifier)
+	if err != nil {
+		return err
+	}
+
+	if entry.LogID != logID {
+		return fmt.Errorf("entry is from log %v, not the pinned log %v", entry.LogID, logID)
+	}
+
+	if entry.Verification == nil || len(entry.Verification.SignedEntryTimestamp) == 0 {
+		return fmt.Errorf("entry has no signed entry timestamp")
+	}
+
+	// the signed entry timestamp signs the canonical json of these fields, whose keys are already in sorted order
+	payload, err := json.Marshal(struct {
+		Body           string `json:"body"`
+		IntegratedTime int64  `json:"integratedTime"`
+		LogID          string `json:"logID"`
+		LogIndex       int64  `json:"logIndex"`
+	}{base64.StdEncoding.EncodeToString(entry.Body), entry.IntegratedTime, entry.LogID, entry.LogIndex})
+	if err != nil {
+		return err
+	}
+
+	if err := verifier.Verify(bytes.NewReader(payload), entry.Verification.SignedEntryTimestamp); err != nil {
+		return fmt.Errorf("signed entry timestamp is not signed by the pinned key: %w", err)
+	}
+
+	proof := entry.Verification.InclusionProof
+	if proof == nil {
+		return fmt.Errorf("entry has no inclusion proof")
+	}
+
+	root, err := decodeHexHash(proof.RootHash)
+	if err != nil {
+		return fmt.Errorf("failed to decode inclusion proof root: %w", err)
+	}
+
+	hashes := make([]tlog.Hash, 0, len(proof.Hashes))
+	for _, h := range proof.Hashes {
+		hash, err := decodeHexHash(h)
+		if err != nil {
+			return fmt.Errorf("failed to decode inclusion proof: %w", err)
+		}
+
+		hashes = append(hashes, hash)
+	}
+
+	if proof.LogIndex < 0 || proof.TreeSize < 0 {
+		return fmt.Errorf("inclusion proof has a negative index or size")
+	}
+
+	if err := tlog.VerifyInclusion(tlog.LeafHash(entry.Body), uint64(proof.LogIndex), uint64(proof.TreeSize), hashes, root); err != nil {
+		return err
+	}
+
+	size, checkpointRoot, err := verifyCheckpoint(proof.Checkpoint, verifier)
+	if err != nil {
+		return err
+	}
+
+	if size != uint64(proof.TreeSize) || checkpointRoot != root {
+		return fmt.Errorf("signed tree head does not match the inclusion proof")
+	}
+
+	return nil
+}
+
+// verifyCheckpoint checks the signature on a Rekor checkpoint, a signed note holding the log's tree head, and returns
+// the tree size and root hash it signs.
+func verifyCheckpoint(checkpoint string, verifier cryptoutil.Verifier) (uint64, tlog.Hash, error) {
+	i := strings.Index(checkpoint, "\n\n")
+	if i < 0 {
+		return 0, tlog.Hash{}, fmt.Errorf("checkpoint has no signatures")
+	}
+
+	text := checkpoint[:i+1]
+	verified := false
+	for _, line := range strings.Split(strings.TrimSpace(checkpoint[i+2:]), "\n") {
+		// signature lines are "— <name> <base64 of a 4 byte key hint followed by the signature>"
+		fields := strings.Fields(strings.TrimPrefix(line, "— "))
+		if len(fields) != 2 {
+			continue
+		}
+
+		sig, err := base64.StdEncoding.DecodeString(fields[1])
+		if err != nil || len(sig) <= 4 {
+			continue
+		}
+
+		if verifier.Verify(strings.NewReader(text), sig[4:]) == nil {
+			verified = true
+			break
+		}
+	}
+
+	if !verified {
+		return 0, tlog.Hash{}, fmt.Errorf("signed tree head is not signed by the pinned key")
+	}
+
+	lines := strings.Split(text, "\n")
+	if len(lines) < 3 {
+		return 0, tlog.Hash{}, fmt.Errorf("checkpoint is missing its tree size or root hash")
+	}
+
+	size, err := strconv.ParseUint(lines[1], 10, 64)
+	if err != nil {
+		return 0, tlog.Hash{}, fmt.Errorf("failed to parse checkpoint tree size: %w", err)
+	}
+
+	rootBytes, err := base64.StdEncoding.DecodeString(lines[2])
+	if err != nil || len(rootBytes) != len(tlog.Hash{}) {
+		return 0, tlog.Hash{}, fmt.Errorf("failed to parse checkpoint root hash")
+	}
+
+	root := tlog.Hash{}
+	copy(root[:], rootBytes)
+	return size, root, nil
+}
+
+func decodeHexHash(encoded string) (tlog.Hash, error) {
+	decoded, err := hex.DecodeString(encoded)
+	if err != nil {
+		return tlog.Hash{}, err
+	}
+
+	if len(decoded) != len(tlog.Hash{}) {
+		return tlog.Hash{}, fmt.Errorf("hash is %d bytes, expected %d", len(decoded), len(tlog.Hash{}))
+	}
+
+	hash := tlog.Hash{}
+	copy(hash[:], decoded)
+	return hash, nil
+}
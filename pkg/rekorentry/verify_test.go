@@ -0,0 +1,194 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rekorentry
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/witness/pkg/tlog"
+)
+
+type testLog struct {
+	t      *testing.T
+	signer cryptoutil.Signer
+	logID  string
+	leaves []tlog.Hash
+}
+
+func newTestLog(t *testing.T) *testLog {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signer := cryptoutil.NewECDSASigner(key, crypto.SHA256)
+	verifier, err := signer.Verifier()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logID, err := LogID(verifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l := &testLog{t: t, signer: signer, logID: logID}
+	for i := 0; i < 5; i++ {
+		l.leaves = append(l.leaves, tlog.LeafHash([]byte(fmt.Sprintf("other entry %d", i))))
+	}
+
+	return l
+}
+
+func (l *testLog) sign(data []byte) []byte {
+	sig, err := l.signer.Sign(bytes.NewReader(data))
+	if err != nil {
+		l.t.Fatal(err)
+	}
+
+	return sig
+}
+
+// entry adds body to the log and returns the entry Rekor would return for it.
+func (l *testLog) entry(body []byte, index int) map[string]interface{} {
+	leaves := append(append(append([]tlog.Hash{}, l.leaves[:index]...), tlog.LeafHash(body)), l.leaves[index:]...)
+	proof, err := tlog.InclusionProof(leaves, uint64(index))
+	if err != nil {
+		l.t.Fatal(err)
+	}
+
+	hashes := make([]string, 0, len(proof))
+	for _, h := range proof {
+		hashes = append(hashes, hex.EncodeToString(h[:]))
+	}
+
+	root := tlog.RootHash(leaves)
+	note := fmt.Sprintf("rekor.example.com - 1\n%d\n%s\n", len(leaves), base64.StdEncoding.EncodeToString(root[:]))
+	checkpoint := note + "\n— rekor.example.com " + base64.StdEncoding.EncodeToString(append([]byte{0, 0, 0, 0}, l.sign([]byte(note))...)) + "\n"
+
+	logIndex := int64(1000 + index)
+	set, err := json.Marshal(map[string]interface{}{
+		"body":           base64.StdEncoding.EncodeToString(body),
+		"integratedTime": 1700000000,
+		"logID":          l.logID,
+		"logIndex":       logIndex,
+	})
+	if err != nil {
+		l.t.Fatal(err)
+	}
+
+	return map[string]interface{}{
+		"body":           body,
+		"integratedTime": 1700000000,
+		"logID":          l.logID,
+		"logIndex":       logIndex,
+		"verification": map[string]interface{}{
+			"signedEntryTimestamp": l.sign(set),
+			"inclusionProof": map[string]interface{}{
+				"logIndex":   index,
+				"treeSize":   len(leaves),
+				"rootHash":   hex.EncodeToString(root[:]),
+				"hashes":     hashes,
+				"checkpoint": checkpoint,
+			},
+		},
+	}
+}
+
+func TestPinnedPublicKey(t *testing.T) {
+	l := newTestLog(t)
+	env := testEnvelope(t, nil)
+	body := intotoBody(t, env)
+
+	valid := l.entry(body, 2)
+	tamperedBody := l.entry(body, 3)
+	tamperedBody["body"] = intotoBody(t, testEnvelope(t, map[string]string{"file": "abcd"}))
+	tamperedProof := l.entry(body, 1)
+	tamperedProof["verification"].(map[string]interface{})["inclusionProof"].(map[string]interface{})["logIndex"] = 4
+	unsigned := l.entry(body, 0)
+	delete(unsigned, "verification")
+	otherLog := newTestLog(t).entry(body, 2)
+
+	fake := &fakeRekor{t: t, entries: map[string]map[string]interface{}{
+		"valid":          valid,
+		"tampered-body":  tamperedBody,
+		"tampered-proof": tamperedProof,
+		"unsigned":       unsigned,
+		"other-log":      otherLog,
+	}}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	verifier, err := l.signer.Verifier()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c := New(server.URL)
+	c.PublicKey = verifier
+	entry, err := c.Get(context.Background(), "valid")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if entry.LogIndex != 1002 || string(entry.Envelope.Payload) != string(env.Payload) {
+		t.Fatalf("unexpected entry: %+v", entry)
+	}
+
+	for _, uuid := range []string{"tampered-body", "tampered-proof", "unsigned", "other-log"} {
+		if _, err := c.Get(context.Background(), uuid); err == nil {
+			t.Errorf("expected %v entry to fail verification", uuid)
+		}
+	}
+
+	// without a pinned key the server is trusted
+	if _, err := New(server.URL).Get(context.Background(), "tampered-body"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestVerifyCheckpoint(t *testing.T) {
+	l := newTestLog(t)
+	verifier, err := l.signer.Verifier()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkpoint := l.entry([]byte("entry"), 0)["verification"].(map[string]interface{})["inclusionProof"].(map[string]interface{})["checkpoint"].(string)
+	size, _, err := verifyCheckpoint(checkpoint, verifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if size != 6 {
+		t.Fatalf("expected tree size 6, got %d", size)
+	}
+
+	if _, _, err := verifyCheckpoint("rekor.example.com - 1\n7"+checkpoint[len("rekor.example.com - 1\n6"):], verifier); err == nil {
+		t.Fatal("expected altered checkpoint to fail")
+	}
+}
@@ -0,0 +1,270 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package remoteverify delegates verification to a remote verification service, for thin clients such as admission
+// webhooks that can't fetch and evaluate evidence themselves. The client sends the artifact's digests and a reference
+// to the policy, and the service answers with a verification summary attestation signed by its key, which the client
+// only trusts if it is signed by a key pinned for the service.
+package remoteverify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/dsse"
+	"github.com/testifysec/go-witness/intoto"
+	"github.com/testifysec/witness/pkg/vsa"
+)
+
+// maxResultSize limits the size of a verification result the client will read.
+const maxResultSize = 4 << 20
+
+// clockSkew is how far in the future a result's verification time may be, to allow for the service's clock.
+const clockSkew = time.Minute
+
+// Request is the body POSTed to the service's /verify endpoint.
+type Request struct {
+	// Policy references the policy the service verifies against, such as a name or URL the service knows it by.
+	Policy  string  `json:"policy"`
+	Subject Subject `json:"subject"`
+}
+
+// Subject is the artifact to verify. Name is informational, and the service finds evidence by the digests.
+type Subject struct {
+	Name   string            `json:"name"`
+	Digest map[string]string `json:"digest"`
+}
+
+// Options controls requests to the service and which results are accepted.
+type Options struct {
+	// Client is used for requests to the service. If nil, http.DefaultClient is used.
+	Client *http.Client
+	// Timeout limits the request to the service. 0 means no limit.
+	Timeout time.Duration
+	// MaxAge is the oldest a result's verification time may be. 0 disables the check.
+	MaxAge time.Duration
+}
+
+func DefaultOptions() Options {
+	return Options{
+		Timeout: 30 * time.Second,
+		MaxAge:  5 * time.Minute,
+	}
+}
+
+// Result is a verification result whose signature, subject, policy, and age have been checked.
+type Result struct {
+	Envelope dsse.Envelope
+	Summary  vsa.Summary
+}
+
+// Passed returns true if the service verified the artifact against the policy.
+func (r Result) Passed() bool {
+	return r.Summary.VerificationResult == vsa.ResultPassed
+}
+
+// Client sends verification requests to a service and checks its results against the service's pinned keys.
+type Client struct {
+	URL       string
+	verifiers []cryptoutil.Verifier
+	opts      Options
+}
+
+func New(url string, verifiers []cryptoutil.Verifier, opts Options) *Client {
+	if opts.Client == nil {
+		opts.Client = http.DefaultClient
+	}
+
+	return &Client{URL: strings.TrimSuffix(url, "/"), verifiers: verifiers, opts: opts}
+}
+
+// Verify asks the service to verify the artifact with the digests against the policy. The returned result has been
+// checked, and an error is returned along with it if the service reported that verification failed.
+func (c *Client) Verify(ctx context.Context, policy, name string, digest cryptoutil.DigestSet) (Result, error) {
+	digests, err := digest.ToNameMap()
+	if err != nil {
+		return Result{}, err
+	}
+
+	req := Request{Policy: policy, Subject: Subject{Name: name, Digest: digests}}
+	env, err := c.send(ctx, req)
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to verify with %v: %w", c.URL, err)
+	}
+
+	result, err := Check(env, c.verifiers, req, c.opts.MaxAge, time.Now())
+	if err != nil {
+		return Result{}, fmt.Errorf("verification result from %v is not trusted: %w", c.URL, err)
+	}
+
+	if !result.Passed() {
+		return result, fmt.Errorf("%v reported that %v does not satisfy policy %v", verifierName(result.Summary, c.URL), name, policy)
+	}
+
+	return result, nil
+}
+
+func verifierName(summary vsa.Summary, url string) string {
+	if summary.Verifier.ID != "" {
+		return summary.Verifier.ID
+	}
+
+	return url
+}
+
+func (c *Client) send(ctx context.Context, r Request) (dsse.Envelope, error) {
+	if c.opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.opts.Timeout)
+		defer cancel()
+	}
+
+	body, err := json.Marshal(&r)
+	if err != nil {
+		return dsse.Envelope{}, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.URL+"/verify", bytes.NewReader(body))
+	if err != nil {
+		return dsse.Envelope{}, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	resp, err := c.opts.Client.Do(req)
+	if err != nil {
+		return dsse.Envelope{}, err
+	}
+
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		if message = bytes.TrimSpace(message); len(message) > 0 {
+			return dsse.Envelope{}, fmt.Errorf("unexpected status %v: %s", resp.Status, message)
+		}
+
+		return dsse.Envelope{}, fmt.Errorf("unexpected status %v", resp.Status)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxResultSize+1))
+	if err != nil {
+		return dsse.Envelope{}, err
+	}
+
+	if len(data) > maxResultSize {
+		return dsse.Envelope{}, fmt.Errorf("verification result is larger than %d bytes", maxResultSize)
+	}
+
+	env := dsse.Envelope{}
+	if err := json.Unmarshal(data, &env); err != nil {
+		return dsse.Envelope{}, fmt.Errorf("failed to unmarshal verification result: %w", err)
+	}
+
+	return env, nil
+}
+
+// Check returns the result in a verification summary attestation if it is signed by one of the verifiers, is about the
+// request's subject and policy, and was verified no longer than maxAge before now.
+func Check(env dsse.Envelope, verifiers []cryptoutil.Verifier, req Request, maxAge time.Duration, now time.Time) (Result, error) {
+	if _, err := env.Verify(dsse.WithVerifiers(verifiers)); err != nil {
+		return Result{}, fmt.Errorf("not signed by a pinned key: %w", err)
+	}
+
+	if env.PayloadType != intoto.PayloadType {
+		return Result{}, fmt.Errorf("unexpected payload type %v", env.PayloadType)
+	}
+
+	statement := intoto.Statement{}
+	if err := json.Unmarshal(env.Payload, &statement); err != nil {
+		return Result{}, fmt.Errorf("failed to unmarshal statement: %w", err)
+	}
+
+	if statement.PredicateType != vsa.PredicateType {
+		return Result{}, fmt.Errorf("unexpected predicate type %v", statement.PredicateType)
+	}
+
+	summary := vsa.Summary{}
+	if err := json.Unmarshal(statement.Predicate, &summary); err != nil {
+		return Result{}, fmt.Errorf("failed to unmarshal verification summary: %w", err)
+	}
+
+	if !hasSubject(statement, req.Subject.Digest) {
+		return Result{}, fmt.Errorf("result is not about the requested artifact")
+	}
+
+	if summary.Policy.URI != req.Policy {
+		return Result{}, fmt.Errorf("result is for policy %v, not %v", summary.Policy.URI, req.Policy)
+	}
+
+	if summary.TimeVerified.After(now.Add(clockSkew)) {
+		return Result{}, fmt.Errorf("result was verified in the future, at %v", summary.TimeVerified)
+	}
+
+	if maxAge > 0 && summary.TimeVerified.Before(now.Add(-maxAge)) {
+		return Result{}, fmt.Errorf("result was verified at %v, more than %v ago", summary.TimeVerified, maxAge)
+	}
+
+	switch summary.VerificationResult {
+	case vsa.ResultPassed, vsa.ResultFailed:
+	default:
+		return Result{}, fmt.Errorf("unknown verification result %v", summary.VerificationResult)
+	}
+
+	return Result{Envelope: env, Summary: summary}, nil
+}
+
+// hasSubject returns true if a subject of the statement has every digest requested.
+func hasSubject(statement intoto.Statement, digest map[string]string) bool {
+	if len(digest) == 0 {
+		return false
+	}
+
+	for _, subject := range statement.Subject {
+		matches := true
+		for algorithm, value := range digest {
+			if !strings.EqualFold(subject.Digest[algorithm], value) {
+				matches = false
+				break
+			}
+		}
+
+		if matches {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ParseDigest parses a digest of the form algorithm:hex, such as sha256:<hex>.
+func ParseDigest(digest string) (cryptoutil.DigestSet, error) {
+	parts := strings.SplitN(digest, ":", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return nil, fmt.Errorf("invalid digest %v, expected algorithm:hex", digest)
+	}
+
+	ds, err := cryptoutil.NewDigestSet(map[string]string{strings.ToLower(parts[0]): parts[1]})
+	if err != nil {
+		return nil, fmt.Errorf("invalid digest %v: %w", digest, err)
+	}
+
+	return ds, nil
+}
@@ -0,0 +1,196 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package remoteverify
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/dsse"
+	"github.com/testifysec/witness/pkg/vsa"
+)
+
+const testDigest = "3a8b5e0c7f1d9e2a4b6c8d0e1f2a3b4c5d6e7f8091a2b3c4d5e6f708192a3b4c"
+
+func newSigner(t *testing.T) cryptoutil.Signer {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return cryptoutil.NewECDSASigner(key, crypto.SHA256)
+}
+
+func verifierOf(t *testing.T, signer cryptoutil.Signer) cryptoutil.Verifier {
+	verifier, err := signer.Verifier()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return verifier
+}
+
+func signResult(t *testing.T, signer cryptoutil.Signer, policy, digest, result string, verified time.Time) dsse.Envelope {
+	summary := vsa.Summary{
+		Verifier:           vsa.Verifier{ID: "https://judge.example.com"},
+		TimeVerified:       verified,
+		ResourceURI:        "app",
+		Policy:             vsa.ResourceDescriptor{URI: policy},
+		VerificationResult: result,
+	}
+
+	env, err := vsa.Sign(summary, map[string]cryptoutil.DigestSet{"app": {crypto.SHA256: digest}}, signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return env
+}
+
+// newService serves signed results for the requests it receives, recording the last request.
+func newService(t *testing.T, signer cryptoutil.Signer, result string, received *Request) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/verify" {
+			http.NotFound(w, r)
+			return
+		}
+
+		if err := json.NewDecoder(r.Body).Decode(received); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		env := signResult(t, signer, received.Policy, received.Subject.Digest["sha256"], result, time.Now())
+		if err := json.NewEncoder(w).Encode(&env); err != nil {
+			t.Error(err)
+		}
+	}))
+}
+
+func TestVerify(t *testing.T) {
+	signer := newSigner(t)
+	received := Request{}
+	server := newService(t, signer, vsa.ResultPassed, &received)
+	defer server.Close()
+
+	client := New(server.URL+"/", []cryptoutil.Verifier{verifierOf(t, signer)}, DefaultOptions())
+	result, err := client.Verify(context.Background(), "prod-images", "app", cryptoutil.DigestSet{crypto.SHA256: testDigest})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !result.Passed() || result.Summary.Verifier.ID != "https://judge.example.com" {
+		t.Errorf("unexpected result: %+v", result.Summary)
+	}
+
+	if received.Policy != "prod-images" || received.Subject.Name != "app" || received.Subject.Digest["sha256"] != testDigest {
+		t.Errorf("unexpected request: %+v", received)
+	}
+}
+
+func TestVerifyFailed(t *testing.T) {
+	signer := newSigner(t)
+	server := newService(t, signer, vsa.ResultFailed, &Request{})
+	defer server.Close()
+
+	client := New(server.URL, []cryptoutil.Verifier{verifierOf(t, signer)}, DefaultOptions())
+	result, err := client.Verify(context.Background(), "prod-images", "app", cryptoutil.DigestSet{crypto.SHA256: testDigest})
+	if err == nil || !strings.Contains(err.Error(), "does not satisfy policy prod-images") {
+		t.Fatalf("expected a failed verification error, got %v", err)
+	}
+
+	if result.Passed() || len(result.Envelope.Signatures) == 0 {
+		t.Errorf("expected the signed failed result to be returned, got %+v", result)
+	}
+}
+
+func TestVerifyUnpinnedKey(t *testing.T) {
+	server := newService(t, newSigner(t), vsa.ResultPassed, &Request{})
+	defer server.Close()
+
+	client := New(server.URL, []cryptoutil.Verifier{verifierOf(t, newSigner(t))}, DefaultOptions())
+	_, err := client.Verify(context.Background(), "prod-images", "app", cryptoutil.DigestSet{crypto.SHA256: testDigest})
+	if err == nil || !strings.Contains(err.Error(), "not signed by a pinned key") {
+		t.Errorf("expected a result signed by another key to be rejected, got %v", err)
+	}
+}
+
+func TestVerifyServiceError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unknown policy", http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	client := New(server.URL, []cryptoutil.Verifier{verifierOf(t, newSigner(t))}, DefaultOptions())
+	_, err := client.Verify(context.Background(), "prod-images", "app", cryptoutil.DigestSet{crypto.SHA256: testDigest})
+	if err == nil || !strings.Contains(err.Error(), "unknown policy") {
+		t.Errorf("expected the service's error, got %v", err)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	signer := newSigner(t)
+	verifiers := []cryptoutil.Verifier{verifierOf(t, signer)}
+	now := time.Now()
+	req := Request{Policy: "prod-images", Subject: Subject{Name: "app", Digest: map[string]string{"sha256": testDigest}}}
+	tests := []struct {
+		name string
+		env  dsse.Envelope
+		err  string
+	}{
+		{"passed", signResult(t, signer, "prod-images", testDigest, vsa.ResultPassed, now), ""},
+		{"other artifact", signResult(t, signer, "prod-images", strings.Repeat("0", 64), vsa.ResultPassed, now), "not about the requested artifact"},
+		{"other policy", signResult(t, signer, "dev-images", testDigest, vsa.ResultPassed, now), "for policy dev-images"},
+		{"stale", signResult(t, signer, "prod-images", testDigest, vsa.ResultPassed, now.Add(-time.Hour)), "more than 5m0s ago"},
+		{"future", signResult(t, signer, "prod-images", testDigest, vsa.ResultPassed, now.Add(time.Hour)), "in the future"},
+		{"unknown result", signResult(t, signer, "prod-images", testDigest, "MAYBE", now), "unknown verification result"},
+	}
+
+	for _, test := range tests {
+		_, err := Check(test.env, verifiers, req, 5*time.Minute, now)
+		if test.err == "" && err != nil {
+			t.Errorf("%v: unexpected error: %v", test.name, err)
+		} else if test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)) {
+			t.Errorf("%v: expected error containing %q, got %v", test.name, test.err, err)
+		}
+	}
+}
+
+func TestParseDigest(t *testing.T) {
+	ds, err := ParseDigest("SHA256:" + testDigest)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ds[crypto.SHA256] != testDigest {
+		t.Errorf("unexpected digest set: %v", ds)
+	}
+
+	for _, digest := range []string{testDigest, "sha256:", "md4:abcd"} {
+		if _, err := ParseDigest(digest); err == nil {
+			t.Errorf("expected %v to be invalid", digest)
+		}
+	}
+}
@@ -0,0 +1,128 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replay
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/testifysec/go-witness/attestation"
+)
+
+// ErrMismatch is returned when replayed attestors produce different attestations than they did when recorded.
+type ErrMismatch struct {
+	Types []string
+}
+
+func (e ErrMismatch) Error() string {
+	return fmt.Sprintf("attestations differ from the fixture: %v", strings.Join(e.Types, ", "))
+}
+
+// Attest runs the attestors in the working directory, without running a command, and returns their attestations
+// keyed by type.
+func Attest(ctx context.Context, attestorNames []string, workingDir string) (map[string]interface{}, error) {
+	attestors, err := attestation.Attestors(attestorNames)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create attestors: %w", err)
+	}
+
+	opts := []attestation.AttestationContextOption{attestation.WithContext(ctx)}
+	if workingDir != "" {
+		opts = append(opts, attestation.WithWorkingDir(workingDir))
+	}
+
+	attestCtx, err := attestation.NewContext(attestors, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create attestation context: %w", err)
+	}
+
+	if err := attestCtx.RunAttestors(); err != nil {
+		return nil, err
+	}
+
+	attestations := make(map[string]interface{})
+	for _, attestor := range attestCtx.CompletedAttestors() {
+		data, err := json.Marshal(attestor)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal %v attestation: %w", attestor.Name(), err)
+		}
+
+		var v interface{}
+		if err := json.Unmarshal(data, &v); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal %v attestation: %w", attestor.Name(), err)
+		}
+
+		attestations[attestor.Type()] = v
+	}
+
+	return attestations, nil
+}
+
+// RecordFixture runs the attestors while recording their inputs, and returns a fixture holding the inputs and the
+// attestations they produced.
+func RecordFixture(ctx context.Context, attestorNames []string, workingDir string) (*Fixture, error) {
+	stop, err := Record(attestorNames)
+	if err != nil {
+		return nil, err
+	}
+
+	attestations, err := Attest(ctx, attestorNames, workingDir)
+	fixture := stop()
+	if err != nil {
+		return nil, err
+	}
+
+	fixture.Attestations = attestations
+	return fixture, nil
+}
+
+// Check replays the fixture and returns the attestations the attestors produced. If they differ from the fixture's
+// attestations an ErrMismatch is returned along with them.
+func Check(ctx context.Context, fixture *Fixture, workingDir string) (map[string]interface{}, error) {
+	stop, err := Replay(fixture)
+	if err != nil {
+		return nil, err
+	}
+
+	attestations, err := Attest(ctx, fixture.Attestors, workingDir)
+	stop()
+	if err != nil {
+		return nil, err
+	}
+
+	mismatched := make([]string, 0)
+	for attestationType, expected := range fixture.Attestations {
+		if !reflect.DeepEqual(expected, attestations[attestationType]) {
+			mismatched = append(mismatched, attestationType)
+		}
+	}
+
+	for attestationType := range attestations {
+		if _, ok := fixture.Attestations[attestationType]; !ok {
+			mismatched = append(mismatched, attestationType)
+		}
+	}
+
+	if len(mismatched) > 0 {
+		sort.Strings(mismatched)
+		return attestations, ErrMismatch{Types: mismatched}
+	}
+
+	return attestations, nil
+}
@@ -0,0 +1,360 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package replay records the inputs attestors read from their environment into fixtures, and replays fixtures so
+// attestors can be run again deterministically and offline. Attestors read environment variables with os.Getenv and
+// make requests with http.DefaultTransport as usual; they run commands, read host files, and make requests with their
+// own transports through this package so those inputs can be recorded and replayed too.
+package replay
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Fixture holds the inputs the attestors read while they were recorded and the attestations they produced.
+type Fixture struct {
+	Attestors    []string               `json:"attestors"`
+	RecordedAt   time.Time              `json:"recordedAt"`
+	Env          map[string]string      `json:"env"`
+	Files        map[string]*File       `json:"files,omitempty"`
+	Commands     []Command              `json:"commands,omitempty"`
+	Requests     []Request              `json:"requests,omitempty"`
+	Attestations map[string]interface{} `json:"attestations"`
+}
+
+// File is a host file read by an attestor. Missing files are recorded so they are missing when replayed as well.
+type File struct {
+	Exists  bool   `json:"exists"`
+	Content []byte `json:"content,omitempty"`
+}
+
+type Command struct {
+	Name   string   `json:"name"`
+	Args   []string `json:"args,omitempty"`
+	Output []byte   `json:"output,omitempty"`
+	Error  string   `json:"error,omitempty"`
+}
+
+// Request is an http request and its response. Request headers and bodies are not recorded, so credentials sent with
+// requests are not stored in fixtures.
+type Request struct {
+	Method string      `json:"method"`
+	URL    string      `json:"url"`
+	Status int         `json:"status,omitempty"`
+	Header http.Header `json:"header,omitempty"`
+	Body   []byte      `json:"body,omitempty"`
+	Error  string      `json:"error,omitempty"`
+}
+
+// ErrNotRecorded is returned when an attestor reads an input during replay that wasn't read while recording.
+type ErrNotRecorded struct {
+	Input string
+}
+
+func (e ErrNotRecorded) Error() string {
+	return fmt.Sprintf("%v was not recorded in the fixture", e.Input)
+}
+
+// sensitive environment variable names contain one of these, and are not recorded.
+var sensitive = []string{"TOKEN", "SECRET", "PASSWORD", "PASSWD", "CREDENTIAL", "PRIVATE", "KEY", "AUTH", "COOKIE"}
+
+var (
+	mu        sync.Mutex
+	recording *Fixture
+	replaying *player
+)
+
+type player struct {
+	fixture  *Fixture
+	commands []Command
+	requests []Request
+}
+
+// Record captures the inputs read through this package, and the environment, until stop is called. stop returns the
+// recorded fixture, without attestations.
+func Record(attestors []string) (stop func() *Fixture, err error) {
+	mu.Lock()
+	defer mu.Unlock()
+	if recording != nil || replaying != nil {
+		return nil, fmt.Errorf("already recording or replaying")
+	}
+
+	fixture := &Fixture{
+		Attestors:  attestors,
+		RecordedAt: time.Now().UTC(),
+		Env:        environment(),
+		Files:      make(map[string]*File),
+	}
+
+	recording = fixture
+	restoreTransport := wrapDefaultTransport()
+	return func() *Fixture {
+		mu.Lock()
+		defer mu.Unlock()
+		recording = nil
+		restoreTransport()
+		return fixture
+	}, nil
+}
+
+// Replay serves the fixture's inputs, and sets the environment to the fixture's, until stop is called.
+func Replay(fixture *Fixture) (stop func(), err error) {
+	mu.Lock()
+	defer mu.Unlock()
+	if recording != nil || replaying != nil {
+		return nil, fmt.Errorf("already recording or replaying")
+	}
+
+	original := os.Environ()
+	if err := setEnvironment(fixture.Env); err != nil {
+		return nil, err
+	}
+
+	replaying = &player{
+		fixture:  fixture,
+		commands: append([]Command{}, fixture.Commands...),
+		requests: append([]Request{}, fixture.Requests...),
+	}
+
+	restoreTransport := wrapDefaultTransport()
+	return func() {
+		mu.Lock()
+		defer mu.Unlock()
+		replaying = nil
+		restoreTransport()
+		restore := make(map[string]string)
+		for _, kv := range original {
+			parts := strings.SplitN(kv, "=", 2)
+			if len(parts) == 2 {
+				restore[parts[0]] = parts[1]
+			}
+		}
+
+		_ = setEnvironment(restore)
+	}, nil
+}
+
+func environment() map[string]string {
+	env := make(map[string]string)
+	for _, kv := range os.Environ() {
+		parts := strings.SplitN(kv, "=", 2)
+		if len(parts) != 2 || isSensitive(parts[0]) {
+			continue
+		}
+
+		env[parts[0]] = parts[1]
+	}
+
+	return env
+}
+
+func isSensitive(name string) bool {
+	upper := strings.ToUpper(name)
+	for _, s := range sensitive {
+		if strings.Contains(upper, s) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func setEnvironment(env map[string]string) error {
+	os.Clearenv()
+	for k, v := range env {
+		if err := os.Setenv(k, v); err != nil {
+			return fmt.Errorf("failed to set %v: %w", k, err)
+		}
+	}
+
+	return nil
+}
+
+// Output runs the command and returns its standard output.
+func Output(ctx context.Context, name string, args ...string) ([]byte, error) {
+	return run(name, args, func() ([]byte, error) {
+		return exec.CommandContext(ctx, name, args...).Output()
+	})
+}
+
+// CombinedOutput runs the command and returns its standard output and standard error.
+func CombinedOutput(ctx context.Context, name string, args ...string) ([]byte, error) {
+	return run(name, args, func() ([]byte, error) {
+		return exec.CommandContext(ctx, name, args...).CombinedOutput()
+	})
+}
+
+func run(name string, args []string, live func() ([]byte, error)) ([]byte, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	if replaying != nil {
+		for i, cmd := range replaying.commands {
+			if cmd.Name != name || strings.Join(cmd.Args, "\x00") != strings.Join(args, "\x00") {
+				continue
+			}
+
+			replaying.commands = append(replaying.commands[:i], replaying.commands[i+1:]...)
+			if cmd.Error != "" {
+				return cmd.Output, errors.New(cmd.Error)
+			}
+
+			return cmd.Output, nil
+		}
+
+		return nil, ErrNotRecorded{Input: fmt.Sprintf("command %q", strings.Join(append([]string{name}, args...), " "))}
+	}
+
+	out, err := live()
+	if recording != nil {
+		cmd := Command{Name: name, Args: args, Output: out}
+		if err != nil {
+			cmd.Error = err.Error()
+		}
+
+		recording.Commands = append(recording.Commands, cmd)
+	}
+
+	return out, err
+}
+
+// ReadFile reads a file from the host.
+func ReadFile(path string) ([]byte, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	if replaying != nil {
+		file, ok := replaying.fixture.Files[path]
+		if !ok {
+			return nil, ErrNotRecorded{Input: fmt.Sprintf("file %v", path)}
+		}
+
+		if !file.Exists {
+			return nil, &os.PathError{Op: "open", Path: path, Err: os.ErrNotExist}
+		}
+
+		return file.Content, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if recording != nil {
+		recording.Files[path] = &File{Exists: err == nil, Content: data}
+	}
+
+	return data, err
+}
+
+// Exists returns true if the path exists on the host. Its content is not recorded.
+func Exists(path string) bool {
+	mu.Lock()
+	defer mu.Unlock()
+	if replaying != nil {
+		file, ok := replaying.fixture.Files[path]
+		return ok && file.Exists
+	}
+
+	_, err := os.Stat(path)
+	if recording != nil {
+		if _, ok := recording.Files[path]; !ok {
+			recording.Files[path] = &File{Exists: err == nil}
+		}
+	}
+
+	return err == nil
+}
+
+type transport struct {
+	base http.RoundTripper
+}
+
+// Transport returns a round tripper that records the responses of base, or replays them from the fixture.
+func Transport(base http.RoundTripper) http.RoundTripper {
+	return transport{base: base}
+}
+
+func wrapDefaultTransport() func() {
+	original := http.DefaultTransport
+	http.DefaultTransport = Transport(original)
+	return func() {
+		http.DefaultTransport = original
+	}
+}
+
+func (t transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	mu.Lock()
+	player, recorder := replaying, recording
+	mu.Unlock()
+	if player != nil {
+		return player.roundTrip(req)
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if recorder == nil {
+		return resp, err
+	}
+
+	recorded := Request{Method: req.Method, URL: req.URL.String()}
+	if err != nil {
+		recorded.Error = err.Error()
+	} else {
+		body, readErr := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if readErr != nil {
+			return nil, readErr
+		}
+
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+		recorded.Status = resp.StatusCode
+		recorded.Header = resp.Header
+		recorded.Body = body
+	}
+
+	mu.Lock()
+	recorder.Requests = append(recorder.Requests, recorded)
+	mu.Unlock()
+	return resp, err
+}
+
+func (p *player) roundTrip(req *http.Request) (*http.Response, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	for i, recorded := range p.requests {
+		if recorded.Method != req.Method || recorded.URL != req.URL.String() {
+			continue
+		}
+
+		p.requests = append(p.requests[:i], p.requests[i+1:]...)
+		if recorded.Error != "" {
+			return nil, errors.New(recorded.Error)
+		}
+
+		return &http.Response{
+			Status:     fmt.Sprintf("%d %s", recorded.Status, http.StatusText(recorded.Status)),
+			StatusCode: recorded.Status,
+			Header:     recorded.Header,
+			Body:       io.NopCloser(bytes.NewReader(recorded.Body)),
+			Request:    req,
+		}, nil
+	}
+
+	return nil, ErrNotRecorded{Input: fmt.Sprintf("request %v %v", req.Method, req.URL)}
+}
@@ -0,0 +1,141 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replay
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/testifysec/go-witness/attestation"
+)
+
+const testType = "https://witness.dev/attestations/replay-test/v0.1"
+
+// testURL is the url the test attestor fetches, set by each test.
+var testURL string
+
+type testAttestor struct {
+	Greeting string `json:"greeting"`
+	File     string `json:"file"`
+	Command  string `json:"command"`
+	Response string `json:"response"`
+	Mounted  bool   `json:"mounted"`
+}
+
+func (a *testAttestor) Name() string                 { return "replay-test" }
+func (a *testAttestor) Type() string                 { return testType }
+func (a *testAttestor) RunType() attestation.RunType { return attestation.PreRunType }
+
+func (a *testAttestor) Attest(ctx *attestation.AttestationContext) error {
+	a.Greeting = os.Getenv("REPLAY_TEST_GREETING")
+	data, err := ReadFile(filepath.Join(ctx.WorkingDir(), "input.txt"))
+	if err != nil {
+		return err
+	}
+
+	a.File = string(data)
+	out, err := Output(ctx.Context(), "echo", "hello")
+	if err != nil {
+		return err
+	}
+
+	a.Command = strings.TrimSpace(string(out))
+	a.Mounted = Exists(filepath.Join(ctx.WorkingDir(), "mounted"))
+	resp, err := http.Get(testURL)
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	a.Response = string(body)
+	return err
+}
+
+func init() {
+	attestation.RegisterAttestation("replay-test", testType, attestation.PreRunType, func() attestation.Attestor {
+		return &testAttestor{}
+	})
+}
+
+func TestRecordAndReplay(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "from server")
+	}))
+	testURL = server.URL
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "input.txt"), []byte("recorded input"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("REPLAY_TEST_GREETING", "hi")
+	t.Setenv("REPLAY_TEST_TOKEN", "secret")
+	fixture, err := RecordFixture(context.Background(), []string{"replay-test"}, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := fixture.Env["REPLAY_TEST_TOKEN"]; ok {
+		t.Error("expected sensitive environment variables not to be recorded")
+	}
+
+	// replay from json, without the server, input file, or environment variable
+	data, err := json.Marshal(fixture)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server.Close()
+	os.Remove(filepath.Join(dir, "input.txt"))
+	os.Unsetenv("REPLAY_TEST_GREETING")
+	replayed := &Fixture{}
+	if err := json.Unmarshal(data, replayed); err != nil {
+		t.Fatal(err)
+	}
+
+	attestations, err := Check(context.Background(), replayed, dir)
+	if err != nil {
+		t.Fatalf("expected replay to match the recording: %v", err)
+	}
+
+	got := attestations[testType].(map[string]interface{})
+	if got["greeting"] != "hi" || got["file"] != "recorded input" || got["command"] != "hello" || got["response"] != "from server" || got["mounted"] != false {
+		t.Errorf("unexpected replayed attestation: %v", got)
+	}
+
+	if os.Getenv("REPLAY_TEST_GREETING") != "" {
+		t.Error("expected environment to be restored after replay")
+	}
+
+	replayed.Env["REPLAY_TEST_GREETING"] = "hello"
+	if _, err := Check(context.Background(), replayed, dir); !errors.As(err, &ErrMismatch{}) {
+		t.Errorf("expected changed environment to produce a mismatch, got %v", err)
+	}
+
+	replayed.Commands = nil
+	if _, err := Check(context.Background(), replayed, dir); !errors.As(err, &ErrNotRecorded{}) {
+		t.Errorf("expected missing command to fail, got %v", err)
+	}
+}
@@ -0,0 +1,338 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package search
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/testifysec/go-witness/dsse"
+	"github.com/testifysec/go-witness/log"
+)
+
+const (
+	archivistDigestQuery = `query($value: String!) {
+  dsses(where: {hasStatementWith: {hasSubjectsWith: {hasSubjectDigestsWith: {value: $value}}}}) {
+    edges { node { gitoidSha256 } }
+  }
+}`
+
+	archivistNameQuery = `query($value: String!) {
+  dsses(where: {hasStatementWith: {hasSubjectsWith: {name: $value}}}) {
+    edges { node { gitoidSha256 } }
+  }
+}`
+
+	archivistPayloadTypeQuery = `query($value: String!) {
+  dsses(where: {payloadType: $value}) {
+    edges { node { gitoidSha256 } }
+  }
+}`
+)
+
+const gitoidPrefix = "gitoid:blob:sha256:"
+
+// ErrUnpinnedServer is returned when an Archivista server's TLS certificate doesn't hold the pinned public key.
+type ErrUnpinnedServer struct{}
+
+func (e ErrUnpinnedServer) Error() string {
+	return "archivist server's certificate does not hold the pinned public key"
+}
+
+// PinnedTLSConfig returns a TLS config that only accepts a server whose certificate holds the pinned public key, given
+// as a PEM encoded public key or certificate. The pinned key replaces the CA and host name checks, since self hosted
+// Archivista servers often have self signed certificates. Responses over a connection to the server are then known to
+// come from the holder of the key, and to be current, since TLS sessions can't be replayed.
+func PinnedTLSConfig(keyPEM []byte) (*tls.Config, error) {
+	spki, err := pinnedKey(keyPEM)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		// the certificate is checked against the pinned key instead, by VerifyPeerCertificate
+		InsecureSkipVerify: true, //nolint:gosec
+		VerifyPeerCertificate: func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			if len(rawCerts) == 0 {
+				return ErrUnpinnedServer{}
+			}
+
+			leaf, err := x509.ParseCertificate(rawCerts[0])
+			if err != nil {
+				return err
+			}
+
+			if !bytes.Equal(leaf.RawSubjectPublicKeyInfo, spki) {
+				return ErrUnpinnedServer{}
+			}
+
+			return nil
+		},
+	}, nil
+}
+
+// pinnedKey returns the DER encoded SubjectPublicKeyInfo of a PEM encoded public key or certificate.
+func pinnedKey(keyPEM []byte) ([]byte, error) {
+	block, _ := pem.Decode(keyPEM)
+	if block == nil {
+		return nil, fmt.Errorf("pinned archivist key is not PEM encoded")
+	}
+
+	if block.Type == "CERTIFICATE" {
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse pinned archivist certificate: %w", err)
+		}
+
+		return cert.RawSubjectPublicKeyInfo, nil
+	}
+
+	if _, err := x509.ParsePKIXPublicKey(block.Bytes); err != nil {
+		return nil, fmt.Errorf("failed to parse pinned archivist key: %w", err)
+	}
+
+	return block.Bytes, nil
+}
+
+// Archivist searches an Archivista server with its GraphQL API and downloads the envelopes it finds. Each downloaded
+// envelope is checked against the gitoid the server listed it by.
+type Archivist struct {
+	URL        string
+	HTTPClient *http.Client
+	// PinnedKey is the sha256 digest of the public key the server's TLS certificate must hold, set by Pin. Envelopes a
+	// pinned server lists but that can't be downloaded, or don't match their gitoids, fail the search instead of being
+	// skipped, since they could hide a policy violation.
+	PinnedKey string
+}
+
+func NewArchivist(url string) *Archivist {
+	return &Archivist{URL: strings.TrimSuffix(url, "/"), HTTPClient: http.DefaultClient}
+}
+
+// Pin makes the client only connect to the server if its TLS certificate holds the PEM encoded public key or
+// certificate, see PinnedTLSConfig. Requests are sent with transport.
+func (a *Archivist) Pin(keyPEM []byte, transport *http.Transport) error {
+	tlsConfig, err := PinnedTLSConfig(keyPEM)
+	if err != nil {
+		return err
+	}
+
+	spki, err := pinnedKey(keyPEM)
+	if err != nil {
+		return err
+	}
+
+	transport.TLSClientConfig = tlsConfig
+	a.HTTPClient = &http.Client{Transport: transport}
+	a.PinnedKey = fmt.Sprintf("sha256:%x", sha256.Sum256(spki))
+	return nil
+}
+
+func (a *Archivist) Name() string {
+	return "archivist"
+}
+
+type archivistResponse struct {
+	Data struct {
+		DSSEs struct {
+			Edges []struct {
+				Node struct {
+					GitoidSHA256 string `json:"gitoidSha256"`
+				} `json:"node"`
+			} `json:"edges"`
+		} `json:"dsses"`
+	} `json:"data"`
+	Errors []struct {
+		Message string `json:"message"`
+	} `json:"errors"`
+}
+
+func (a *Archivist) Search(ctx context.Context, q Query) ([]Found, error) {
+	query, value := archivistNameQuery, q.Subject
+	if _, digest, ok := q.Digest(); ok {
+		query, value = archivistDigestQuery, digest
+	}
+
+	gitoids, err := a.query(ctx, query, value)
+	if err != nil {
+		return nil, err
+	}
+
+	found := make([]Found, 0, len(gitoids))
+	for _, gitoid := range gitoids {
+		env, err := a.download(ctx, gitoid)
+		if err != nil && a.PinnedKey != "" {
+			// the pinned server listed the envelope, so failing to get it could hide a violation
+			return nil, fmt.Errorf("failed to download archivist result %v: %w", gitoid, err)
+		} else if err != nil {
+			log.Debugf("(search) skipping archivist envelope %v: %v", gitoid, err)
+			continue
+		}
+
+		found = append(found, Found{Envelope: env, Reference: a.URL + "/download/" + gitoid})
+	}
+
+	return found, nil
+}
+
+// Envelopes returns the content of every envelope with the payload type stored in the server, such as every signed
+// policy. Envelopes that can't be downloaded are skipped unless the server is pinned.
+func (a *Archivist) Envelopes(ctx context.Context, payloadType string) ([][]byte, error) {
+	gitoids, err := a.query(ctx, archivistPayloadTypeQuery, payloadType)
+	if err != nil {
+		return nil, err
+	}
+
+	envelopes := make([][]byte, 0, len(gitoids))
+	for _, gitoid := range gitoids {
+		data, err := a.downloadData(ctx, gitoid)
+		if err != nil && a.PinnedKey != "" {
+			return nil, fmt.Errorf("failed to download archivist result %v: %w", gitoid, err)
+		} else if err != nil {
+			log.Debugf("(search) skipping archivist envelope %v: %v", gitoid, err)
+			continue
+		}
+
+		envelopes = append(envelopes, data)
+	}
+
+	return envelopes, nil
+}
+
+// query runs the GraphQL query for dsses with the value and returns the gitoids of the envelopes found.
+func (a *Archivist) query(ctx context.Context, query, value string) ([]string, error) {
+	body, err := json.Marshal(map[string]interface{}{
+		"query":     query,
+		"variables": map[string]string{"value": value},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := a.do(ctx, http.MethodPost, "/query", body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search archivist: %w", err)
+	}
+
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to search archivist: %w", statusError(resp))
+	}
+
+	result := archivistResponse{}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode archivist response: %w", err)
+	}
+
+	if len(result.Errors) > 0 {
+		return nil, fmt.Errorf("archivist query failed: %v", result.Errors[0].Message)
+	}
+
+	gitoids := make([]string, 0, len(result.Data.DSSEs.Edges))
+	for _, edge := range result.Data.DSSEs.Edges {
+		gitoids = append(gitoids, edge.Node.GitoidSHA256)
+	}
+
+	return gitoids, nil
+}
+
+func (a *Archivist) download(ctx context.Context, gitoid string) (dsse.Envelope, error) {
+	data, err := a.downloadData(ctx, gitoid)
+	if err != nil {
+		return dsse.Envelope{}, err
+	}
+
+	env := dsse.Envelope{}
+	if err := json.Unmarshal(data, &env); err != nil {
+		return dsse.Envelope{}, fmt.Errorf("failed to decode envelope: %w", err)
+	}
+
+	return env, nil
+}
+
+// downloadData returns the content of the envelope with the gitoid.
+func (a *Archivist) downloadData(ctx context.Context, gitoid string) ([]byte, error) {
+	resp, err := a.do(ctx, http.MethodGet, "/download/"+gitoid, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, statusError(resp)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read envelope: %w", err)
+	}
+
+	if err := checkGitoid(gitoid, data); err != nil {
+		return nil, err
+	}
+
+	return data, nil
+}
+
+// checkGitoid returns an error if data is not the content of the sha256 git blob gitoid.
+func checkGitoid(gitoid string, data []byte) error {
+	if !strings.HasPrefix(gitoid, gitoidPrefix) {
+		return fmt.Errorf("unsupported gitoid %v", gitoid)
+	}
+
+	h := sha256.New()
+	fmt.Fprintf(h, "blob %d\x00", len(data))
+	h.Write(data)
+	if digest := hex.EncodeToString(h.Sum(nil)); !strings.EqualFold(digest, strings.TrimPrefix(gitoid, gitoidPrefix)) {
+		return fmt.Errorf("downloaded envelope does not match gitoid %v", gitoid)
+	}
+
+	return nil
+}
+
+func (a *Archivist) do(ctx context.Context, method, path string, body []byte) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, a.URL+path, reader)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	return a.HTTPClient.Do(req)
+}
+
+func statusError(resp *http.Response) error {
+	message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	return fmt.Errorf("unexpected status %v: %s", resp.Status, bytes.TrimSpace(message))
+}
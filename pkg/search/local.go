@@ -0,0 +1,46 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package search
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/testifysec/witness/pkg/simulate"
+)
+
+// Local searches a directory of attestation envelopes, such as one kept by witness store. Tombstones are skipped.
+type Local struct {
+	Directory string
+}
+
+func (l Local) Name() string {
+	return "local"
+}
+
+func (l Local) Search(ctx context.Context, q Query) ([]Found, error) {
+	attestations, err := simulate.Load(l.Directory, time.Time{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to search %v: %w", l.Directory, err)
+	}
+
+	found := make([]Found, 0, len(attestations))
+	for _, attestation := range attestations {
+		found = append(found, Found{Envelope: attestation.Envelope, Reference: attestation.Path})
+	}
+
+	return found, nil
+}
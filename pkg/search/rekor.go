@@ -0,0 +1,64 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package search
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/log"
+	"github.com/testifysec/witness/pkg/rekorentry"
+)
+
+// Rekor searches a Rekor server's index for entries of any kind. Rekor only indexes digests, so it can't be searched by
+// subject name.
+type Rekor struct {
+	Client *rekorentry.Client
+}
+
+func (r Rekor) Name() string {
+	return "rekor"
+}
+
+func (r Rekor) Search(ctx context.Context, q Query) ([]Found, error) {
+	algorithm, value, ok := q.Digest()
+	if !ok {
+		return nil, fmt.Errorf("rekor can only be searched by subject digest")
+	}
+
+	ds, err := cryptoutil.NewDigestSet(map[string]string{algorithm: value})
+	if err != nil {
+		return nil, err
+	}
+
+	uuids, err := r.Client.Search(ctx, ds)
+	if err != nil {
+		return nil, err
+	}
+
+	found := make([]Found, 0, len(uuids))
+	for _, uuid := range uuids {
+		entry, err := r.Client.Get(ctx, uuid)
+		if err != nil {
+			log.Debugf("(search) skipping rekor entry %v: %v", uuid, err)
+			continue
+		}
+
+		found = append(found, Found{Envelope: entry.Envelope, Reference: r.Client.EntryURL(entry.LogIndex)})
+	}
+
+	return found, nil
+}
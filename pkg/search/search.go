@@ -0,0 +1,148 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package search finds attestations by subject and predicate type across the places witness reads them from, such as
+// Rekor, Archivista, and local directories of attestations, and reports where each envelope was found.
+package search
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/testifysec/go-witness/dsse"
+	"github.com/testifysec/go-witness/intoto"
+)
+
+// Query selects attestations by subject and predicate type.
+type Query struct {
+	// Subject is a subject name, such as file:main.go, or a digest in the form <algorithm>:<hex>. Bare sha256 and sha1
+	// hex digests are also accepted.
+	Subject string
+	// PredicateType optionally limits the results to statements of the predicate type.
+	PredicateType string
+}
+
+// Digest returns the algorithm and value of the subject if it is a digest rather than a name.
+func (q Query) Digest() (string, string, bool) {
+	algorithm, value := "", q.Subject
+	if parts := strings.SplitN(q.Subject, ":", 2); len(parts) == 2 {
+		algorithm, value = parts[0], parts[1]
+	}
+
+	if _, err := hex.DecodeString(value); err != nil {
+		return "", "", false
+	}
+
+	switch {
+	case algorithm == "" && len(value) == sha256.Size*2:
+		return "sha256", strings.ToLower(value), true
+	case algorithm == "" && len(value) == 40:
+		return "sha1", strings.ToLower(value), true
+	case algorithm == "sha256" || algorithm == "sha1":
+		return algorithm, strings.ToLower(value), true
+	default:
+		return "", "", false
+	}
+}
+
+// Matches reports whether the statement has a subject and predicate type matching the query.
+func (q Query) Matches(statement intoto.Statement) bool {
+	if q.PredicateType != "" && statement.PredicateType != q.PredicateType {
+		return false
+	}
+
+	algorithm, value, isDigest := q.Digest()
+	for _, subject := range statement.Subject {
+		if isDigest && strings.EqualFold(subject.Digest[algorithm], value) {
+			return true
+		}
+
+		if !isDigest && subject.Name == q.Subject {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Found is an envelope a backend returned and a reference to where it was found, such as the URL of its Rekor entry.
+type Found struct {
+	Envelope  dsse.Envelope
+	Reference string
+}
+
+// Backend is a place attestations can be searched for. Backends may return envelopes that don't match the query, such
+// as Rekor entries of other predicate types; Search filters them out.
+type Backend interface {
+	Name() string
+	Search(ctx context.Context, q Query) ([]Found, error)
+}
+
+// Source is a backend an envelope was found in.
+type Source struct {
+	Backend   string `json:"backend"`
+	Reference string `json:"reference"`
+}
+
+// Result is an envelope matching the query along with every backend it was found in.
+type Result struct {
+	PredicateType string           `json:"predicateType"`
+	Subjects      []intoto.Subject `json:"subjects"`
+	Sources       []Source         `json:"sources"`
+	Envelope      dsse.Envelope    `json:"envelope"`
+}
+
+// Search queries each backend and returns the matching envelopes in the order they were first found. Envelopes with
+// the same payload found in several backends are returned once with all of their sources. The errors of the backends
+// that failed are returned by backend name along with the results of the others.
+func Search(ctx context.Context, backends []Backend, q Query) ([]Result, map[string]error) {
+	results := make([]Result, 0)
+	byPayload := make(map[string]int)
+	failed := make(map[string]error)
+	for _, backend := range backends {
+		found, err := backend.Search(ctx, q)
+		if err != nil {
+			failed[backend.Name()] = err
+			continue
+		}
+
+		for _, f := range found {
+			statement := intoto.Statement{}
+			if err := json.Unmarshal(f.Envelope.Payload, &statement); err != nil || !q.Matches(statement) {
+				continue
+			}
+
+			source := Source{Backend: backend.Name(), Reference: f.Reference}
+			key := fmt.Sprintf("%x", sha256.Sum256(f.Envelope.Payload))
+			if i, ok := byPayload[key]; ok {
+				results[i].Sources = append(results[i].Sources, source)
+				continue
+			}
+
+			byPayload[key] = len(results)
+			results = append(results, Result{
+				PredicateType: statement.PredicateType,
+				Subjects:      statement.Subject,
+				Sources:       []Source{source},
+				Envelope:      f.Envelope,
+			})
+		}
+	}
+
+	return results, failed
+}
@@ -0,0 +1,318 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package search
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/testifysec/go-witness/dsse"
+	"github.com/testifysec/go-witness/intoto"
+)
+
+const testDigest = "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+
+func testEnvelope(t *testing.T, predicateType, name string) dsse.Envelope {
+	statement, err := json.Marshal(intoto.Statement{
+		Type:          intoto.StatementType,
+		PredicateType: predicateType,
+		Subject:       []intoto.Subject{{Name: name, Digest: map[string]string{"sha256": testDigest}}},
+		Predicate:     json.RawMessage(`{}`),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return dsse.Envelope{
+		Payload:     statement,
+		PayloadType: intoto.PayloadType,
+		Signatures:  []dsse.Signature{{KeyID: "test", Signature: []byte("sig")}},
+	}
+}
+
+type testBackend struct {
+	name  string
+	found []Found
+	err   error
+}
+
+func (b testBackend) Name() string {
+	return b.name
+}
+
+func (b testBackend) Search(ctx context.Context, q Query) ([]Found, error) {
+	return b.found, b.err
+}
+
+func TestQueryDigest(t *testing.T) {
+	tests := []struct {
+		subject   string
+		algorithm string
+		isDigest  bool
+	}{
+		{"sha256:" + testDigest, "sha256", true},
+		{strings.ToUpper(testDigest), "sha256", true},
+		{"sha1:" + testDigest[:40], "sha1", true},
+		{testDigest[:40], "sha1", true},
+		{"file:main.go", "", false},
+		{"commithash:" + testDigest[:40], "", false},
+	}
+
+	for _, test := range tests {
+		algorithm, _, ok := Query{Subject: test.subject}.Digest()
+		if ok != test.isDigest || algorithm != test.algorithm {
+			t.Errorf("expected %v to be a %v digest: %v", test.subject, test.algorithm, ok)
+		}
+	}
+}
+
+func TestSearch(t *testing.T) {
+	collection := testEnvelope(t, "https://witness.testifysec.com/attestation-collection/v0.1", "file:main.go")
+	provenance := testEnvelope(t, "https://slsa.dev/provenance/v0.2", "file:main.go")
+	other := testEnvelope(t, "https://slsa.dev/provenance/v0.2", "file:other.go")
+	backends := []Backend{
+		testBackend{name: "rekor", found: []Found{{Envelope: collection, Reference: "rekor/1"}, {Envelope: provenance, Reference: "rekor/2"}}},
+		testBackend{name: "archivist", err: fmt.Errorf("unavailable")},
+		testBackend{name: "local", found: []Found{{Envelope: collection, Reference: "attestations/build.json"}, {Envelope: other, Reference: "attestations/other.json"}}},
+	}
+
+	results, failed := Search(context.Background(), backends, Query{Subject: "file:main.go"})
+	if len(failed) != 1 || failed["archivist"] == nil {
+		t.Errorf("expected the archivist failure to be reported, got %v", failed)
+	}
+
+	if len(results) != 2 {
+		t.Fatalf("expected 2 results, got %v", len(results))
+	}
+
+	if len(results[0].Sources) != 2 || results[0].Sources[1] != (Source{Backend: "local", Reference: "attestations/build.json"}) {
+		t.Errorf("expected the collection's sources to be merged, got %v", results[0].Sources)
+	}
+
+	results, _ = Search(context.Background(), backends, Query{Subject: "sha256:" + testDigest, PredicateType: "https://slsa.dev/provenance/v0.2"})
+	if len(results) != 2 || results[0].PredicateType != "https://slsa.dev/provenance/v0.2" {
+		t.Errorf("expected both provenance statements, got %v", results)
+	}
+}
+
+func TestLocal(t *testing.T) {
+	dir := t.TempDir()
+	env := testEnvelope(t, "https://slsa.dev/provenance/v0.2", "file:main.go")
+	data, err := json.Marshal(env)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "build.json"), data, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("not an envelope"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	found, err := Local{Directory: dir}.Search(context.Background(), Query{Subject: "file:main.go"})
+	if err != nil {
+		t.Fatalf("failed to search directory: %v", err)
+	}
+
+	if len(found) != 1 || found[0].Reference != filepath.Join(dir, "build.json") {
+		t.Errorf("expected the envelope to be found, got %v", found)
+	}
+}
+
+func TestArchivist(t *testing.T) {
+	envBytes, err := json.Marshal(testEnvelope(t, "https://slsa.dev/provenance/v0.2", "file:main.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gitoid := testGitoid(envBytes)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/query":
+			request := struct {
+				Query     string            `json:"query"`
+				Variables map[string]string `json:"variables"`
+			}{}
+
+			if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+				t.Error(err)
+			}
+
+			if request.Variables["value"] != testDigest || !strings.Contains(request.Query, "hasSubjectDigestsWith") {
+				t.Errorf("unexpected query %v %v", request.Query, request.Variables)
+			}
+
+			fmt.Fprint(w, `{"data": {"dsses": {"edges": [{"node": {"gitoidSha256": "`+gitoid+`"}}, {"node": {"gitoidSha256": "missing"}}]}}}`)
+		case "/download/" + gitoid:
+			_, _ = w.Write(envBytes)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	archivist := NewArchivist(server.URL + "/")
+	found, err := archivist.Search(context.Background(), Query{Subject: "sha256:" + testDigest})
+	if err != nil {
+		t.Fatalf("failed to search archivist: %v", err)
+	}
+
+	if len(found) != 1 || found[0].Reference != server.URL+"/download/"+gitoid {
+		t.Errorf("expected the downloadable envelope to be found, got %v", found)
+	}
+}
+
+func TestArchivistEnvelopes(t *testing.T) {
+	envBytes, err := json.Marshal(testEnvelope(t, "https://witness.testifysec.com/policy/v0.1", "file:main.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gitoid := testGitoid(envBytes)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/query":
+			request := struct {
+				Query     string            `json:"query"`
+				Variables map[string]string `json:"variables"`
+			}{}
+
+			if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+				t.Error(err)
+			}
+
+			if request.Variables["value"] != "https://witness.testifysec.com/policy/v0.1" || !strings.Contains(request.Query, "payloadType") {
+				t.Errorf("unexpected query %v %v", request.Query, request.Variables)
+			}
+
+			fmt.Fprint(w, `{"data": {"dsses": {"edges": [{"node": {"gitoidSha256": "`+gitoid+`"}}, {"node": {"gitoidSha256": "missing"}}]}}}`)
+		case "/download/" + gitoid:
+			_, _ = w.Write(envBytes)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	envelopes, err := NewArchivist(server.URL).Envelopes(context.Background(), "https://witness.testifysec.com/policy/v0.1")
+	if err != nil {
+		t.Fatalf("failed to list envelopes: %v", err)
+	}
+
+	if len(envelopes) != 1 || !bytes.Equal(envelopes[0], envBytes) {
+		t.Errorf("expected the downloadable envelope's content, got %s", envelopes)
+	}
+}
+
+func TestArchivistPinned(t *testing.T) {
+	envBytes, err := json.Marshal(testEnvelope(t, "https://slsa.dev/provenance/v0.2", "file:main.go"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gitoid := testGitoid(envBytes)
+	tampered := false
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/query":
+			fmt.Fprint(w, `{"data": {"dsses": {"edges": [{"node": {"gitoidSha256": "`+gitoid+`"}}]}}}`)
+		case "/download/" + gitoid:
+			if tampered {
+				_, _ = w.Write(append(envBytes, ' '))
+				return
+			}
+
+			_, _ = w.Write(envBytes)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	pinnedClient := func(keyPEM []byte) *Archivist {
+		archivist := NewArchivist(server.URL)
+		if err := archivist.Pin(keyPEM, &http.Transport{}); err != nil {
+			t.Fatalf("failed to pin archivist key: %v", err)
+		}
+
+		return archivist
+	}
+
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	found, err := pinnedClient(certPEM).Search(context.Background(), Query{Subject: "sha256:" + testDigest})
+	if err != nil {
+		t.Fatalf("failed to search pinned archivist: %v", err)
+	}
+
+	if len(found) != 1 {
+		t.Fatalf("expected the envelope to be found, got %v", found)
+	}
+
+	serverKey := sha256.Sum256(server.Certificate().RawSubjectPublicKeyInfo)
+	if pinned := pinnedClient(certPEM).PinnedKey; pinned != "sha256:"+hex.EncodeToString(serverKey[:]) {
+		t.Errorf("expected the pinned key to be the server's key's digest, got %v", pinned)
+	}
+
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	otherDER, err := x509.MarshalPKIXPublicKey(&otherKey.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	otherPEM := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: otherDER})
+	_, err = pinnedClient(otherPEM).Search(context.Background(), Query{Subject: "sha256:" + testDigest})
+	if !errors.As(err, &ErrUnpinnedServer{}) {
+		t.Errorf("expected a server without the pinned key to be rejected, got %v", err)
+	}
+
+	// a pinned server's listed envelope must be the one it serves
+	tampered = true
+	if _, err := pinnedClient(certPEM).Search(context.Background(), Query{Subject: "sha256:" + testDigest}); err == nil {
+		t.Error("expected an envelope that doesn't match its gitoid to be rejected")
+	}
+
+	if _, err := PinnedTLSConfig([]byte("not a key")); err == nil {
+		t.Error("expected a key that isn't PEM encoded to be rejected")
+	}
+}
+
+func testGitoid(data []byte) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "blob %d\x00", len(data))
+	h.Write(data)
+	return gitoidPrefix + hex.EncodeToString(h.Sum(nil))
+}
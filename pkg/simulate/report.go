@@ -0,0 +1,49 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package simulate
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+// Result is the outcome of evaluating a policy against a build. Err is nil if the build satisfies the policy.
+type Result struct {
+	Build Build
+	Err   error
+}
+
+// Report writes a line for each result and a summary, and returns how many builds would fail the policy.
+func Report(w io.Writer, results []Result) int {
+	failed := 0
+	for _, result := range results {
+		status := "PASS"
+		if result.Err != nil {
+			status = "FAIL"
+			failed++
+		}
+
+		line := fmt.Sprintf("%v %v (%d attestations, %v)", status, result.Build.Key, len(result.Build.Attestations), result.Build.Latest.UTC().Format(time.RFC3339))
+		if result.Err != nil {
+			line += ": " + result.Err.Error()
+		}
+
+		fmt.Fprintln(w, line)
+	}
+
+	fmt.Fprintf(w, "%d of %d builds would fail the policy\n", failed, len(results))
+	return failed
+}
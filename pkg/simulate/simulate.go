@@ -0,0 +1,156 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package simulate groups previously stored attestations into the builds they were recorded for, so a proposed policy
+// can be evaluated against past builds before it is rolled out.
+package simulate
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/testifysec/go-witness/dsse"
+	"github.com/testifysec/go-witness/intoto"
+	"github.com/testifysec/witness/pkg/sslib"
+	"github.com/testifysec/witness/pkg/store"
+)
+
+// Attestation is a stored attestation envelope.
+type Attestation struct {
+	Path     string
+	ModTime  time.Time
+	Envelope dsse.Envelope
+}
+
+// Build is the attestations recorded for one build, identified by the subject they share.
+type Build struct {
+	Key          string
+	Latest       time.Time
+	Attestations []Attestation
+}
+
+// ParseSince parses a duration such as 30d, 2w, or 12h. Days and weeks are supported in addition to the units of
+// time.ParseDuration.
+func ParseSince(since string) (time.Duration, error) {
+	for suffix, unit := range map[string]time.Duration{"d": 24 * time.Hour, "w": 7 * 24 * time.Hour} {
+		if !strings.HasSuffix(since, suffix) {
+			continue
+		}
+
+		n, err := strconv.Atoi(strings.TrimSuffix(since, suffix))
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid duration %v", since)
+		}
+
+		return time.Duration(n) * unit, nil
+	}
+
+	d, err := time.ParseDuration(since)
+	if err != nil {
+		return 0, fmt.Errorf("invalid duration %v: %w", since, err)
+	}
+
+	return d, nil
+}
+
+// Load returns the attestation envelopes in dir last modified at or after since. Files that are not DSSE envelopes and
+// tombstones left by garbage collection are skipped.
+func Load(dir string, since time.Time) ([]Attestation, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read attestation directory: %w", err)
+	}
+
+	attestations := make([]Attestation, 0)
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasSuffix(entry.Name(), store.TombstoneSuffix) {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+
+		if info.ModTime().Before(since) {
+			continue
+		}
+
+		path := filepath.Join(dir, entry.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %v: %w", path, err)
+		}
+
+		env, err := sslib.ParseEnvelope(data)
+		if err != nil || len(env.Signatures) == 0 {
+			continue
+		}
+
+		attestations = append(attestations, Attestation{Path: path, ModTime: info.ModTime(), Envelope: env})
+	}
+
+	return attestations, nil
+}
+
+// GroupBuilds groups the attestations by their subject with the prefix, such as commithash. Attestations without such a
+// subject are each their own build. Builds are returned newest first.
+func GroupBuilds(attestations []Attestation, prefix string) []Build {
+	prefix = strings.TrimSuffix(prefix, ":") + ":"
+	builds := make(map[string]*Build)
+	for _, attestation := range attestations {
+		key := attestation.Path
+		statement := intoto.Statement{}
+		if err := json.Unmarshal(attestation.Envelope.Payload, &statement); err == nil {
+			for _, subject := range statement.Subject {
+				if strings.HasPrefix(subject.Name, prefix) {
+					key = subject.Name
+					break
+				}
+			}
+		}
+
+		build, ok := builds[key]
+		if !ok {
+			build = &Build{Key: key}
+			builds[key] = build
+		}
+
+		build.Attestations = append(build.Attestations, attestation)
+		if attestation.ModTime.After(build.Latest) {
+			build.Latest = attestation.ModTime
+		}
+	}
+
+	sorted := make([]Build, 0, len(builds))
+	for _, build := range builds {
+		sorted = append(sorted, *build)
+	}
+
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Latest.Equal(sorted[j].Latest) {
+			return sorted[i].Key < sorted[j].Key
+		}
+
+		return sorted[i].Latest.After(sorted[j].Latest)
+	})
+
+	return sorted
+}
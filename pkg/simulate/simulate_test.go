@@ -0,0 +1,127 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package simulate
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/testifysec/go-witness/dsse"
+	"github.com/testifysec/go-witness/intoto"
+)
+
+func writeAttestation(t *testing.T, dir, name string, modTime time.Time, subjects ...string) {
+	statement := intoto.Statement{Type: intoto.StatementType, PredicateType: "test", Predicate: json.RawMessage(`{}`)}
+	for _, subject := range subjects {
+		statement.Subject = append(statement.Subject, intoto.Subject{Name: subject, Digest: map[string]string{"sha256": "abc"}})
+	}
+
+	payload, err := json.Marshal(statement)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	env, err := json.Marshal(dsse.Envelope{
+		Payload:     payload,
+		PayloadType: intoto.PayloadType,
+		Signatures:  []dsse.Signature{{KeyID: "key", Signature: []byte("sig")}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, env, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestParseSince(t *testing.T) {
+	tests := map[string]time.Duration{"30d": 30 * 24 * time.Hour, "2w": 14 * 24 * time.Hour, "12h": 12 * time.Hour}
+	for since, expected := range tests {
+		d, err := ParseSince(since)
+		if err != nil || d != expected {
+			t.Errorf("expected %v to parse as %v, got %v: %v", since, expected, d, err)
+		}
+	}
+
+	if _, err := ParseSince("30x"); err == nil {
+		t.Error("expected invalid duration to fail")
+	}
+}
+
+func TestLoadAndGroup(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	writeAttestation(t, dir, "build-1.json", now.Add(-2*time.Hour), "commithash:aaa", "file:bin/app")
+	writeAttestation(t, dir, "test-1.json", now.Add(-time.Hour), "commithash:aaa")
+	writeAttestation(t, dir, "build-2.json", now.Add(-30*time.Minute), "commithash:bbb")
+	writeAttestation(t, dir, "scan.json", now.Add(-10*time.Minute), "file:report.json")
+	writeAttestation(t, dir, "old.json", now.Add(-40*24*time.Hour), "commithash:ccc")
+	if err := os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("not an envelope"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	attestations, err := Load(dir, now.Add(-30*24*time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(attestations) != 4 {
+		t.Fatalf("expected 4 recent attestations, got %v", len(attestations))
+	}
+
+	builds := GroupBuilds(attestations, "commithash")
+	if len(builds) != 3 {
+		t.Fatalf("expected 3 builds, got %+v", builds)
+	}
+
+	if builds[0].Key != filepath.Join(dir, "scan.json") || builds[1].Key != "commithash:bbb" || builds[2].Key != "commithash:aaa" {
+		t.Errorf("expected builds newest first, got %v, %v, %v", builds[0].Key, builds[1].Key, builds[2].Key)
+	}
+
+	if len(builds[2].Attestations) != 2 {
+		t.Errorf("expected both attestations for commit aaa to be grouped, got %v", len(builds[2].Attestations))
+	}
+}
+
+func TestReport(t *testing.T) {
+	latest := time.Date(2022, 6, 1, 10, 0, 0, 0, time.UTC)
+	results := []Result{
+		{Build: Build{Key: "commithash:aaa", Latest: latest, Attestations: make([]Attestation, 2)}},
+		{Build: Build{Key: "commithash:bbb", Latest: latest, Attestations: make([]Attestation, 1)}, Err: fmt.Errorf("step build failed")},
+	}
+
+	out := bytes.Buffer{}
+	if failed := Report(&out, results); failed != 1 {
+		t.Errorf("expected 1 failed build, got %v", failed)
+	}
+
+	expected := "PASS commithash:aaa (2 attestations, 2022-06-01T10:00:00Z)\n" +
+		"FAIL commithash:bbb (1 attestations, 2022-06-01T10:00:00Z): step build failed\n" +
+		"1 of 2 builds would fail the policy\n"
+	if out.String() != expected {
+		t.Errorf("unexpected report:\n%v", out.String())
+	}
+}
@@ -0,0 +1,300 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package sink stores signed envelopes in attestation collectors such as Archivista. Envelopes that can't be stored
+// because the collector is unreachable are spooled to a local directory and sent later with Sync.
+package sink
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/testifysec/go-witness/dsse"
+	"github.com/testifysec/witness/pkg/transport"
+	"google.golang.org/grpc"
+)
+
+const gitoidPrefix = "gitoid:blob:sha256:"
+
+// Options controls how envelopes are sent to a collector.
+type Options struct {
+	// Client is used for requests to the collector. If nil, a client for the collector's address is created with
+	// transport.HTTPClient, so the address may be a unix socket or a comma separated list to fail over between.
+	Client *http.Client
+	// DialTimeout limits how long connecting to the collector may take. 0 means the default limit.
+	DialTimeout time.Duration
+	// TLSConfig is used for https connections to the collector, such as one that authenticates with SPIFFE mTLS.
+	TLSConfig *tls.Config
+	// Timeout limits each attempt to store an envelope, including connecting. 0 means no limit.
+	Timeout time.Duration
+	// Retries is how many times a failed attempt is retried. Envelopes the collector rejects with a 4xx status other
+	// than 429 are not retried.
+	Retries int
+	// Backoff is the wait before the first retry. It doubles after each retry.
+	Backoff time.Duration
+	// SpoolDir is the directory envelopes are spooled to when the collector is still unreachable after the retries.
+	// Empty disables spooling.
+	SpoolDir string
+	// GRPC streams envelopes to the collector's Collector.Store RPC in chunks instead of posting them to its http upload
+	// API.
+	GRPC bool
+}
+
+func DefaultOptions() Options {
+	return Options{
+		DialTimeout: 10 * time.Second,
+		Timeout:     30 * time.Second,
+		Retries:     3,
+		Backoff:     time.Second,
+	}
+}
+
+// ErrSpooled is returned by Store when the envelope couldn't be stored and was spooled instead.
+type ErrSpooled struct {
+	Path string
+	Err  error
+}
+
+func (e ErrSpooled) Error() string {
+	return fmt.Sprintf("envelope spooled to %v: %v", e.Path, e.Err)
+}
+
+func (e ErrSpooled) Unwrap() error {
+	return e.Err
+}
+
+type errStatus struct {
+	status    string
+	message   []byte
+	retryable bool
+}
+
+func (e errStatus) Error() string {
+	if len(e.message) == 0 {
+		return fmt.Sprintf("unexpected status %v", e.status)
+	}
+
+	return fmt.Sprintf("unexpected status %v: %s", e.status, e.message)
+}
+
+// ErrGitoidMismatch is returned when the gitoid the collector acknowledged an envelope with is not the gitoid of the
+// envelope that was sent, so the collector may not hold the envelope as it was signed.
+type ErrGitoidMismatch struct {
+	Expected     string
+	Acknowledged string
+}
+
+func (e ErrGitoidMismatch) Error() string {
+	if e.Acknowledged == "" {
+		return fmt.Sprintf("archivist did not acknowledge the envelope with its gitoid %v", e.Expected)
+	}
+
+	return fmt.Sprintf("archivist acknowledged the envelope with gitoid %v, but its gitoid is %v", e.Acknowledged, e.Expected)
+}
+
+// errAccepted is an error reading the response to an upload the collector accepted. Sending the envelope again would
+// store it twice, so it is not retried.
+type errAccepted struct {
+	err error
+}
+
+func (e errAccepted) Error() string {
+	return fmt.Sprintf("archivist accepted the envelope but its response could not be read: %v", e.err)
+}
+
+func (e errAccepted) Unwrap() error {
+	return e.err
+}
+
+// Archivist stores envelopes in an Archivista server with its upload API, or its Collector gRPC service.
+type Archivist struct {
+	URL  string
+	opts Options
+	conn *grpc.ClientConn
+}
+
+// NewArchivist returns a sink for the Archivista server at address. If address fails over between several servers,
+// they are health checked until ctx is cancelled. A gRPC connection is closed when ctx is cancelled.
+func NewArchivist(ctx context.Context, address string, opts Options) (*Archivist, error) {
+	if opts.GRPC {
+		conn, err := dialCollector(ctx, address, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create archivist client: %w", err)
+		}
+
+		go func() {
+			<-ctx.Done()
+			conn.Close()
+		}()
+
+		return &Archivist{URL: strings.TrimSuffix(address, "/"), opts: opts, conn: conn}, nil
+	}
+
+	if opts.Client == nil {
+		var err error
+		opts.Client, address, err = transport.HTTPClient(ctx, address, transport.WithDialTimeout(opts.DialTimeout), transport.WithTLSConfig(opts.TLSConfig))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create archivist client: %w", err)
+		}
+	}
+
+	return &Archivist{URL: strings.TrimSuffix(address, "/"), opts: opts}, nil
+}
+
+// Store uploads the envelope and returns its gitoid, retrying failed attempts. If the collector is still unreachable
+// after the retries and a spool directory is set, the envelope is spooled and an ErrSpooled is returned.
+func (a *Archivist) Store(ctx context.Context, env dsse.Envelope) (string, error) {
+	body, err := json.Marshal(env)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal envelope: %w", err)
+	}
+
+	return a.StoreData(ctx, body)
+}
+
+// StoreData is Store for the JSON encoding of an envelope. The envelope is uploaded as given, so fields dsse.Envelope
+// doesn't have, such as signature timestamps, are kept.
+func (a *Archivist) StoreData(ctx context.Context, body []byte) (string, error) {
+	gitoid, err := a.storeWithRetries(ctx, body)
+	if err == nil {
+		return gitoid, nil
+	}
+
+	if a.opts.SpoolDir == "" || !retryable(err) {
+		return "", err
+	}
+
+	path, spoolErr := Spool(a.opts.SpoolDir, body)
+	if spoolErr != nil {
+		return "", fmt.Errorf("%v, and failed to spool the envelope: %w", err, spoolErr)
+	}
+
+	return "", ErrSpooled{Path: path, Err: err}
+}
+
+func (a *Archivist) storeWithRetries(ctx context.Context, body []byte) (string, error) {
+	backoff := a.opts.Backoff
+	for attempt := 0; ; attempt++ {
+		gitoid, err := a.upload(ctx, body)
+		if err == nil {
+			return gitoid, nil
+		}
+
+		if attempt >= a.opts.Retries || !retryable(err) {
+			return "", fmt.Errorf("failed to store envelope in archivist: %w", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return "", ctx.Err()
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+	}
+}
+
+// upload sends the envelope to the collector once, and checks that the collector acknowledged it with its gitoid.
+func (a *Archivist) upload(ctx context.Context, body []byte) (string, error) {
+	if a.opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, a.opts.Timeout)
+		defer cancel()
+	}
+
+	send := a.post
+	if a.conn != nil {
+		send = a.stream
+	}
+
+	acknowledged, err := send(ctx, body)
+	if err != nil {
+		return "", err
+	}
+
+	expected := Gitoid(body)
+	if !strings.EqualFold(strings.TrimPrefix(acknowledged, gitoidPrefix), strings.TrimPrefix(expected, gitoidPrefix)) {
+		return "", ErrGitoidMismatch{Expected: expected, Acknowledged: acknowledged}
+	}
+
+	return acknowledged, nil
+}
+
+// post sends the envelope to the collector's http upload API and returns the gitoid it acknowledged the envelope with.
+func (a *Archivist) post(ctx context.Context, body []byte) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.URL+"/upload", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	resp, err := a.opts.Client.Do(req)
+	if err != nil {
+		return "", err
+	}
+
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return "", errStatus{
+			status:    resp.Status,
+			message:   bytes.TrimSpace(message),
+			retryable: resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500,
+		}
+	}
+
+	result := struct {
+		Gitoid string `json:"gitoid"`
+	}{}
+
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return "", errAccepted{err: err}
+	}
+
+	return result.Gitoid, nil
+}
+
+// Gitoid returns the sha256 git blob gitoid of the marshaled envelope, which Archivista identifies envelopes by.
+func Gitoid(body []byte) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "blob %d\x00", len(body))
+	h.Write(body)
+	return gitoidPrefix + hex.EncodeToString(h.Sum(nil))
+}
+
+// retryable returns true for errors that may succeed if tried again, which are the errors of an unreachable or
+// overloaded collector rather than one that rejected the envelope.
+func retryable(err error) bool {
+	var status errStatus
+	if errors.As(err, &status) {
+		return status.retryable
+	}
+
+	if errors.As(err, &errAccepted{}) || errors.As(err, &ErrGitoidMismatch{}) {
+		return false
+	}
+
+	return !errors.Is(err, context.Canceled)
+}
@@ -0,0 +1,306 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sink
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/testifysec/go-witness/dsse"
+)
+
+func testOptions(spoolDir string) Options {
+	return Options{Timeout: time.Second, Retries: 2, Backoff: time.Millisecond, SpoolDir: spoolDir}
+}
+
+func newArchivist(t *testing.T, address string, opts Options) *Archivist {
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	archivist, err := NewArchivist(ctx, address, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return archivist
+}
+
+func gitoidOf(t *testing.T, env dsse.Envelope) string {
+	body, err := json.Marshal(env)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return Gitoid(body)
+}
+
+// collector returns an archivist server that fails the first failures uploads with status, and records the payload
+// types of the envelopes it stores.
+func collector(t *testing.T, failures int32, status int) (*httptest.Server, *[]string) {
+	var attempts int32
+	stored := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/upload" || r.Method != http.MethodPost {
+			http.NotFound(w, r)
+			return
+		}
+
+		if atomic.AddInt32(&attempts, 1) <= failures {
+			http.Error(w, "unavailable", status)
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Error(err)
+		}
+
+		env := dsse.Envelope{}
+		if err := json.Unmarshal(body, &env); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		stored = append(stored, env.PayloadType)
+		_ = json.NewEncoder(w).Encode(map[string]string{"gitoid": strings.TrimPrefix(Gitoid(body), gitoidPrefix)})
+	}))
+
+	t.Cleanup(server.Close)
+	return server, &stored
+}
+
+func TestStoreRetries(t *testing.T) {
+	server, stored := collector(t, 2, http.StatusServiceUnavailable)
+	gitoid, err := newArchivist(t, server.URL+"/", testOptions(t.TempDir())).Store(context.Background(), dsse.Envelope{PayloadType: "build"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if gitoid != strings.TrimPrefix(gitoidOf(t, dsse.Envelope{PayloadType: "build"}), gitoidPrefix) || len(*stored) != 1 {
+		t.Errorf("expected the envelope to be stored after retrying, got %v and %v", gitoid, *stored)
+	}
+}
+
+func TestStoreRejected(t *testing.T) {
+	server, _ := collector(t, 1, http.StatusBadRequest)
+	spoolDir := t.TempDir()
+	_, err := newArchivist(t, server.URL, testOptions(spoolDir)).Store(context.Background(), dsse.Envelope{PayloadType: "build"})
+	if err == nil || !strings.Contains(err.Error(), "400") {
+		t.Fatalf("expected the rejection to be returned, got %v", err)
+	}
+
+	if spooled, _ := Spooled(spoolDir); len(spooled) != 0 {
+		t.Errorf("expected a rejected envelope not to be spooled, got %v", spooled)
+	}
+}
+
+func TestStoreAcceptedWithUnreadableResponse(t *testing.T) {
+	var attempts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		_, _ = w.Write([]byte("stored"))
+	}))
+	defer server.Close()
+
+	spoolDir := t.TempDir()
+	_, err := newArchivist(t, server.URL, testOptions(spoolDir)).Store(context.Background(), dsse.Envelope{PayloadType: "build"})
+	if err == nil || errors.As(err, &ErrSpooled{}) {
+		t.Fatalf("expected the unreadable response to be returned without spooling, got %v", err)
+	}
+
+	if n := atomic.LoadInt32(&attempts); n != 1 {
+		t.Errorf("expected an accepted upload not to be retried, got %v attempts", n)
+	}
+
+	if _, err := Spool(spoolDir, []byte(`{"payloadType": "build"}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := newArchivist(t, server.URL, testOptions("")).Sync(context.Background(), spoolDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(results) != 1 || results[0].Err == nil {
+		t.Fatalf("expected the unreadable response to be reported, got %+v", results)
+	}
+
+	if remaining, _ := Spooled(spoolDir); len(remaining) != 0 || atomic.LoadInt32(&attempts) != 2 {
+		t.Errorf("expected the accepted envelope to be removed from the spool after one upload, got %v", remaining)
+	}
+}
+
+func TestStoreGitoidMismatch(t *testing.T) {
+	var attempts int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&attempts, 1)
+		_ = json.NewEncoder(w).Encode(map[string]string{"gitoid": gitoidOf(t, dsse.Envelope{PayloadType: "other"})})
+	}))
+	defer server.Close()
+
+	spoolDir := t.TempDir()
+	_, err := newArchivist(t, server.URL, testOptions(spoolDir)).Store(context.Background(), dsse.Envelope{PayloadType: "build"})
+	if !errors.As(err, &ErrGitoidMismatch{}) {
+		t.Fatalf("expected the acknowledged gitoid to be rejected, got %v", err)
+	}
+
+	if n := atomic.LoadInt32(&attempts); n != 1 {
+		t.Errorf("expected a mismatched acknowledgement not to be retried, got %v attempts", n)
+	}
+
+	if spooled, _ := Spooled(spoolDir); len(spooled) != 0 {
+		t.Errorf("expected a mismatched acknowledgement not to be spooled, got %v", spooled)
+	}
+}
+
+func TestStoreFailover(t *testing.T) {
+	server, stored := collector(t, 0, http.StatusOK)
+	unreachable := httptest.NewServer(http.NotFoundHandler())
+	unreachable.Close()
+
+	opts := testOptions("")
+	opts.Retries = 0
+	archivist := newArchivist(t, unreachable.URL+","+server.URL, opts)
+	for i := 0; i < 2; i++ {
+		if _, err := archivist.Store(context.Background(), dsse.Envelope{PayloadType: "build"}); err != nil {
+			t.Fatalf("expected the envelope to be stored on the available collector: %v", err)
+		}
+	}
+
+	if len(*stored) != 2 {
+		t.Errorf("expected both envelopes to be stored, got %v", *stored)
+	}
+}
+
+func TestStoreDataSpoolsAsGiven(t *testing.T) {
+	unreachable := httptest.NewServer(http.NotFoundHandler())
+	unreachable.Close()
+
+	spoolDir := filepath.Join(t.TempDir(), "spool")
+	archivist := newArchivist(t, unreachable.URL, testOptions(spoolDir))
+	stamped := []byte(`{"payload":"e30=","payloadType":"build","signatures":[{"keyid":"key","sig":"c2ln","timestamps":[{"type":"tsp","data":"dG9rZW4="}]}]}`)
+	_, err := archivist.StoreData(context.Background(), stamped)
+	spooled := ErrSpooled{}
+	if !errors.As(err, &spooled) {
+		t.Fatalf("expected the envelope to be spooled, got %v", err)
+	}
+
+	data, err := os.ReadFile(spooled.Path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(data) != string(stamped) {
+		t.Errorf("expected the envelope to be spooled as given, got %s", data)
+	}
+}
+
+func TestStoreSpoolsAndSyncs(t *testing.T) {
+	server, stored := collector(t, 0, http.StatusOK)
+	unreachable := httptest.NewServer(http.NotFoundHandler())
+	unreachable.Close()
+
+	spoolDir := filepath.Join(t.TempDir(), "spool")
+	archivist := newArchivist(t, unreachable.URL, testOptions(spoolDir))
+	for _, payloadType := range []string{"build", "test", "build"} {
+		_, err := archivist.Store(context.Background(), dsse.Envelope{PayloadType: payloadType})
+		spooled := ErrSpooled{}
+		if !errors.As(err, &spooled) {
+			t.Fatalf("expected the envelope to be spooled, got %v", err)
+		}
+	}
+
+	spooled, err := Spooled(spoolDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(spooled) != 2 {
+		t.Fatalf("expected the identical envelopes to be spooled once, got %v", spooled)
+	}
+
+	if err := os.WriteFile(filepath.Join(spoolDir, "notes.txt"), []byte("not an envelope"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	results, err := newArchivist(t, server.URL, testOptions("")).Sync(context.Background(), spoolDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, result := range results {
+		if result.Err != nil || result.Gitoid == "" {
+			t.Errorf("expected %v to be synced, got %+v", result.Path, result)
+		}
+	}
+
+	if len(results) != 2 || len(*stored) != 2 {
+		t.Errorf("expected both spooled envelopes to be stored, got %v", *stored)
+	}
+
+	if remaining, _ := Spooled(spoolDir); len(remaining) != 0 {
+		t.Errorf("expected the synced envelopes to be removed, got %v", remaining)
+	}
+}
+
+func TestSyncKeepsFailures(t *testing.T) {
+	spoolDir := t.TempDir()
+	if _, err := Spool(spoolDir, []byte(`{"payloadType": "build"}`)); err != nil {
+		t.Fatal(err)
+	}
+
+	server, _ := collector(t, 100, http.StatusInternalServerError)
+	results, err := newArchivist(t, server.URL, testOptions("")).Sync(context.Background(), spoolDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(results) != 1 || results[0].Err == nil {
+		t.Fatalf("expected the sync to fail, got %+v", results)
+	}
+
+	if remaining, _ := Spooled(spoolDir); len(remaining) != 1 {
+		t.Errorf("expected the failed envelope to stay in the spool, got %v", remaining)
+	}
+}
+
+func TestStoreTimeout(t *testing.T) {
+	done := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-done
+	}))
+
+	defer slow.Close()
+	defer close(done)
+	opts := testOptions("")
+	opts.Timeout = 20 * time.Millisecond
+	start := time.Now()
+	if _, err := newArchivist(t, slow.URL, opts).Store(context.Background(), dsse.Envelope{}); err == nil {
+		t.Fatal("expected the store to time out")
+	}
+
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("expected each attempt to be limited by the timeout, took %v", elapsed)
+	}
+}
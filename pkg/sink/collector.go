@@ -0,0 +1,194 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sink
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/backoff"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+const (
+	// collectorStoreMethod is Archivista's client streaming RPC that stores an envelope sent in chunks and acknowledges
+	// it with the envelope's gitoid.
+	collectorStoreMethod = "/archivist.Collector/Store"
+	// chunkSize is the size of the chunks envelopes are streamed in.
+	chunkSize = 64 * 1024
+)
+
+// chunk is archivist.Chunk, a piece of the envelope being stored.
+type chunk struct {
+	data []byte
+}
+
+// storeResponse is archivist.StoreResponse, the collector's acknowledgement of a stored envelope.
+type storeResponse struct {
+	gitoid string
+}
+
+// collectorCodec encodes the Collector service's messages in the protobuf wire format. They only have a single field
+// each, so they are encoded by hand rather than with generated code.
+type collectorCodec struct{}
+
+func (collectorCodec) Name() string {
+	return "proto"
+}
+
+func (collectorCodec) Marshal(v interface{}) ([]byte, error) {
+	switch m := v.(type) {
+	case *chunk:
+		return protowire.AppendBytes(protowire.AppendTag(nil, 1, protowire.BytesType), m.data), nil
+	case *storeResponse:
+		return protowire.AppendString(protowire.AppendTag(nil, 1, protowire.BytesType), m.gitoid), nil
+	default:
+		return nil, fmt.Errorf("unsupported collector message %T", v)
+	}
+}
+
+func (collectorCodec) Unmarshal(data []byte, v interface{}) error {
+	var field []byte
+	for len(data) > 0 {
+		num, typ, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+
+		data = data[n:]
+		if num == 1 && typ == protowire.BytesType {
+			value, n := protowire.ConsumeBytes(data)
+			if n < 0 {
+				return protowire.ParseError(n)
+			}
+
+			field, data = value, data[n:]
+			continue
+		}
+
+		n = protowire.ConsumeFieldValue(num, typ, data)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+
+		data = data[n:]
+	}
+
+	switch m := v.(type) {
+	case *chunk:
+		m.data = append([]byte(nil), field...)
+	case *storeResponse:
+		m.gitoid = string(field)
+	default:
+		return fmt.Errorf("unsupported collector message %T", v)
+	}
+
+	return nil
+}
+
+// dialCollector connects to the Collector service at address. https:// addresses use TLS with the options' TLS
+// config, http:// addresses are plaintext, and unix:///path and unix:@name connect to a unix or abstract socket.
+func dialCollector(ctx context.Context, address string, opts Options) (*grpc.ClientConn, error) {
+	if strings.Contains(address, ",") {
+		return nil, fmt.Errorf("failing over between collectors is only supported with the http upload api")
+	}
+
+	target := address
+	creds := insecure.NewCredentials()
+	switch {
+	case strings.HasPrefix(address, "unix:@"):
+		target = "unix-abstract:" + strings.TrimPrefix(address, "unix:@")
+	case strings.HasPrefix(address, "unix://"):
+	default:
+		u, err := url.Parse(address)
+		if err != nil || u.Host == "" {
+			return nil, fmt.Errorf("invalid collector address %v", address)
+		}
+
+		target = u.Host
+		if u.Scheme == "https" {
+			creds = credentials.NewTLS(opts.TLSConfig)
+			if !strings.Contains(u.Host, ":") {
+				target += ":443"
+			}
+		} else if u.Scheme != "http" {
+			return nil, fmt.Errorf("unsupported collector address scheme %v", u.Scheme)
+		}
+	}
+
+	dialOpts := []grpc.DialOption{grpc.WithTransportCredentials(creds)}
+	if opts.DialTimeout > 0 {
+		dialOpts = append(dialOpts, grpc.WithConnectParams(grpc.ConnectParams{Backoff: backoff.DefaultConfig, MinConnectTimeout: opts.DialTimeout}))
+	}
+
+	return grpc.DialContext(ctx, target, dialOpts...)
+}
+
+// stream sends the envelope to the Collector service in chunks and returns the gitoid it acknowledged the envelope
+// with.
+func (a *Archivist) stream(ctx context.Context, body []byte) (string, error) {
+	stream, err := a.conn.NewStream(ctx, &grpc.StreamDesc{ClientStreams: true}, collectorStoreMethod, grpc.ForceCodec(collectorCodec{}))
+	if err != nil {
+		return "", collectorError(err)
+	}
+
+	for start := 0; start < len(body); start += chunkSize {
+		end := start + chunkSize
+		if end > len(body) {
+			end = len(body)
+		}
+
+		if err := stream.SendMsg(&chunk{data: body[start:end]}); err != nil {
+			// the stream's status is only returned by RecvMsg
+			break
+		}
+	}
+
+	if err := stream.CloseSend(); err != nil {
+		return "", collectorError(err)
+	}
+
+	resp := storeResponse{}
+	if err := stream.RecvMsg(&resp); err != nil {
+		return "", collectorError(err)
+	}
+
+	return resp.gitoid, nil
+}
+
+// collectorError converts the status of a failed RPC to an error that is retried if the collector was unreachable or
+// overloaded.
+func collectorError(err error) error {
+	s, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
+
+	switch s.Code() {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.ResourceExhausted, codes.Aborted:
+		return errStatus{status: s.Code().String(), message: []byte(s.Message()), retryable: true}
+	case codes.Canceled:
+		return context.Canceled
+	default:
+		return errStatus{status: s.Code().String(), message: []byte(s.Message())}
+	}
+}
@@ -0,0 +1,154 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sink
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/testifysec/go-witness/dsse"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// grpcCollector serves the Collector service, failing the first failures streams with code, and records the envelopes
+// it stores and the number of chunks each was sent in.
+func grpcCollector(t *testing.T, failures int32, code codes.Code) (string, *[]dsse.Envelope, *[]int) {
+	var attempts int32
+	stored := []dsse.Envelope{}
+	chunks := []int{}
+	server := grpc.NewServer(grpc.ForceServerCodec(collectorCodec{}))
+	server.RegisterService(&grpc.ServiceDesc{
+		ServiceName: "archivist.Collector",
+		HandlerType: (*interface{})(nil),
+		Streams: []grpc.StreamDesc{{
+			StreamName:    "Store",
+			ClientStreams: true,
+			Handler: func(_ interface{}, stream grpc.ServerStream) error {
+				if atomic.AddInt32(&attempts, 1) <= failures {
+					return status.Error(code, "unavailable")
+				}
+
+				body := bytes.Buffer{}
+				received := 0
+				for {
+					c := chunk{}
+					err := stream.RecvMsg(&c)
+					if errors.Is(err, io.EOF) {
+						break
+					} else if err != nil {
+						return err
+					}
+
+					body.Write(c.data)
+					received++
+				}
+
+				env := dsse.Envelope{}
+				if err := json.Unmarshal(body.Bytes(), &env); err != nil {
+					return status.Error(codes.InvalidArgument, err.Error())
+				}
+
+				stored = append(stored, env)
+				chunks = append(chunks, received)
+				return stream.SendMsg(&storeResponse{gitoid: Gitoid(body.Bytes())})
+			},
+		}},
+	}, nil)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go func() {
+		_ = server.Serve(listener)
+	}()
+
+	t.Cleanup(server.Stop)
+	return "http://" + listener.Addr().String(), &stored, &chunks
+}
+
+func grpcOptions(spoolDir string) Options {
+	opts := testOptions(spoolDir)
+	opts.GRPC = true
+	return opts
+}
+
+func TestStoreGRPC(t *testing.T) {
+	address, stored, chunks := grpcCollector(t, 1, codes.Unavailable)
+	env := dsse.Envelope{PayloadType: "build", Payload: bytes.Repeat([]byte("a"), 3*chunkSize)}
+	gitoid, err := newArchivist(t, address, grpcOptions(t.TempDir())).Store(context.Background(), env)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if gitoid != gitoidOf(t, env) {
+		t.Errorf("expected the acknowledged gitoid %v, got %v", gitoidOf(t, env), gitoid)
+	}
+
+	if len(*stored) != 1 || !bytes.Equal((*stored)[0].Payload, env.Payload) || (*chunks)[0] < 4 {
+		t.Errorf("expected the envelope to be streamed in chunks after a retry, got %d envelopes in %v chunks", len(*stored), *chunks)
+	}
+}
+
+func TestStoreGRPCRejected(t *testing.T) {
+	address, _, _ := grpcCollector(t, 1, codes.InvalidArgument)
+	spoolDir := t.TempDir()
+	_, err := newArchivist(t, address, grpcOptions(spoolDir)).Store(context.Background(), dsse.Envelope{PayloadType: "build"})
+	if err == nil || !strings.Contains(err.Error(), "InvalidArgument") {
+		t.Fatalf("expected the rejection to be returned, got %v", err)
+	}
+
+	if spooled, _ := Spooled(spoolDir); len(spooled) != 0 {
+		t.Errorf("expected a rejected envelope not to be spooled, got %v", spooled)
+	}
+}
+
+func TestStoreGRPCSpools(t *testing.T) {
+	address, _, _ := grpcCollector(t, 100, codes.Unavailable)
+	spoolDir := t.TempDir()
+	_, err := newArchivist(t, address, grpcOptions(spoolDir)).Store(context.Background(), dsse.Envelope{PayloadType: "build"})
+	if !errors.As(err, &ErrSpooled{}) {
+		t.Fatalf("expected the envelope to be spooled, got %v", err)
+	}
+}
+
+func TestCollectorCodec(t *testing.T) {
+	codec := collectorCodec{}
+	data, err := codec.Marshal(&storeResponse{gitoid: "gitoid:blob:sha256:abc"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// an unknown field, as a newer collector might add, is skipped
+	data = append(data, 0x10, 0x01)
+	resp := storeResponse{}
+	if err := codec.Unmarshal(data, &resp); err != nil || resp.gitoid != "gitoid:blob:sha256:abc" {
+		t.Errorf("expected the gitoid to be decoded, got %+v, %v", resp, err)
+	}
+
+	if err := codec.Unmarshal([]byte{0x0a, 0x05}, &resp); err == nil {
+		t.Error("expected a truncated message to fail to decode")
+	}
+}
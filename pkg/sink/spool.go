@@ -0,0 +1,136 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sink
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const spoolSuffix = ".json"
+
+// DefaultSpoolDir returns the witness/spool directory in the user's cache directory.
+func DefaultSpoolDir() (string, error) {
+	cache, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find the user cache directory: %w", err)
+	}
+
+	return filepath.Join(cache, "witness", "spool"), nil
+}
+
+// Spool writes the marshaled envelope to dir, named by its sha256 digest so an envelope spooled twice is only sent
+// once, and returns its path.
+func Spool(dir string, body []byte) (string, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", err
+	}
+
+	digest := sha256.Sum256(body)
+	path := filepath.Join(dir, hex.EncodeToString(digest[:])+spoolSuffix)
+	// written to a temporary file first so Sync never reads a partially written envelope
+	tmp, err := os.CreateTemp(dir, ".spool-*")
+	if err != nil {
+		return "", err
+	}
+
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(body); err != nil {
+		tmp.Close()
+		return "", err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return "", err
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return "", err
+	}
+
+	return path, nil
+}
+
+// Spooled returns the paths of the envelopes spooled in dir, sorted. A directory that doesn't exist has none.
+func Spooled(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read spool directory: %w", err)
+	}
+
+	paths := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") || !strings.HasSuffix(entry.Name(), spoolSuffix) {
+			continue
+		}
+
+		paths = append(paths, filepath.Join(dir, entry.Name()))
+	}
+
+	sort.Strings(paths)
+	return paths, nil
+}
+
+// SyncResult is the outcome of sending a spooled envelope.
+type SyncResult struct {
+	Path   string
+	Gitoid string
+	Err    error
+}
+
+// Sync sends each envelope spooled in dir to the collector, retrying as Store does, and removes the envelopes that
+// were stored. Envelopes that fail are left in the spool to be sent again, and Sync goes on to the next one.
+func (a *Archivist) Sync(ctx context.Context, dir string) ([]SyncResult, error) {
+	paths, err := Spooled(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]SyncResult, 0, len(paths))
+	for _, path := range paths {
+		if err := ctx.Err(); err != nil {
+			return results, err
+		}
+
+		result := SyncResult{Path: path}
+		body, err := os.ReadFile(path)
+		if err != nil {
+			result.Err = err
+			results = append(results, result)
+			continue
+		}
+
+		result.Gitoid, result.Err = a.storeWithRetries(ctx, body)
+		// an envelope the collector accepted is removed even if its gitoid couldn't be read, so it isn't sent again
+		if result.Err == nil || errors.As(result.Err, &errAccepted{}) {
+			if err := os.Remove(path); err != nil {
+				result.Err = fmt.Errorf("envelope was stored but could not be removed from the spool: %w", err)
+			}
+		}
+
+		results = append(results, result)
+	}
+
+	return results, nil
+}
@@ -0,0 +1,92 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package spiffeauth builds authorizers for SPIFFE mTLS connections to witness servers.
+package spiffeauth
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"strings"
+
+	"github.com/spiffe/go-spiffe/v2/bundle/x509bundle"
+	"github.com/spiffe/go-spiffe/v2/spiffeid"
+	"github.com/spiffe/go-spiffe/v2/spiffetls/tlsconfig"
+	"github.com/spiffe/go-spiffe/v2/svid/x509svid"
+)
+
+const trustDomainWildcard = "/*"
+
+type ErrUnauthorizedID struct {
+	ID string
+}
+
+func (e ErrUnauthorizedID) Error() string {
+	return fmt.Sprintf("spiffe id %v is not authorized", e.ID)
+}
+
+// Authorizer returns an authorizer that accepts a peer whose SPIFFE ID matches any of the patterns. A pattern is
+// either an exact SPIFFE ID, such as spiffe://example.org/archivista, or a trust domain followed by /*, such as
+// spiffe://example.org/*, which accepts any ID in that trust domain.
+func Authorizer(patterns []string) (tlsconfig.Authorizer, error) {
+	if len(patterns) == 0 {
+		return nil, fmt.Errorf("at least one authorized spiffe id is required")
+	}
+
+	ids := make(map[string]struct{})
+	trustDomains := make(map[string]struct{})
+	for _, pattern := range patterns {
+		if strings.HasSuffix(pattern, trustDomainWildcard) {
+			td, err := spiffeid.TrustDomainFromString(strings.TrimSuffix(pattern, trustDomainWildcard))
+			if err != nil {
+				return nil, fmt.Errorf("invalid trust domain in %v: %w", pattern, err)
+			}
+
+			trustDomains[td.String()] = struct{}{}
+			continue
+		}
+
+		id, err := spiffeid.FromString(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid spiffe id %v: %w", pattern, err)
+		}
+
+		ids[id.String()] = struct{}{}
+	}
+
+	return func(id spiffeid.ID, _ [][]*x509.Certificate) error {
+		if _, ok := ids[id.String()]; ok {
+			return nil
+		}
+
+		if _, ok := trustDomains[id.TrustDomain().String()]; ok {
+			return nil
+		}
+
+		return ErrUnauthorizedID{ID: id.String()}
+	}, nil
+}
+
+// ClientConfig returns the TLS config of an mTLS client that presents the X.509 SVID from svid, verifies servers with
+// the bundles from bundle, and only connects to servers whose SPIFFE ID matches one of the patterns, see Authorizer.
+// The sources are usually a Workload API X.509 source, which keeps the SVID and bundles current.
+func ClientConfig(svid x509svid.Source, bundle x509bundle.Source, patterns []string) (*tls.Config, error) {
+	authorizer, err := Authorizer(patterns)
+	if err != nil {
+		return nil, err
+	}
+
+	return tlsconfig.MTLSClientConfig(svid, bundle, authorizer), nil
+}
@@ -0,0 +1,170 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package spiffeauth
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io"
+	"log"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/spiffe/go-spiffe/v2/bundle/x509bundle"
+	"github.com/spiffe/go-spiffe/v2/spiffeid"
+	"github.com/spiffe/go-spiffe/v2/spiffetls/tlsconfig"
+	"github.com/spiffe/go-spiffe/v2/svid/x509svid"
+)
+
+func TestAuthorizer(t *testing.T) {
+	authorize, err := Authorizer([]string{"spiffe://example.org/archivista", "spiffe://prod.example.org/*"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := map[string]bool{
+		"spiffe://example.org/archivista":      true,
+		"spiffe://example.org/other":           false,
+		"spiffe://prod.example.org/archivista": true,
+		"spiffe://prod.example.org/a/b":        true,
+		"spiffe://dev.example.org/archivista":  false,
+	}
+
+	for idStr, allowed := range tests {
+		id, err := spiffeid.FromString(idStr)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = authorize(id, nil)
+		if allowed && err != nil {
+			t.Errorf("expected %v to be authorized: %v", idStr, err)
+		} else if !allowed && err == nil {
+			t.Errorf("expected %v to be unauthorized", idStr)
+		}
+	}
+}
+
+func TestAuthorizerInvalid(t *testing.T) {
+	for _, patterns := range [][]string{{}, {"not-a-spiffe-id"}, {"spiffe:///*"}} {
+		if _, err := Authorizer(patterns); err == nil {
+			t.Errorf("expected error for %v", patterns)
+		}
+	}
+}
+
+func TestClientConfig(t *testing.T) {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "example.org"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ca, err := x509.ParseCertificate(caDER)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newSVID := func(serial int64, id string) *x509svid.SVID {
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		uri, err := url.Parse(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		template := &x509.Certificate{
+			SerialNumber: big.NewInt(serial),
+			NotBefore:    time.Now().Add(-time.Hour),
+			NotAfter:     time.Now().Add(time.Hour),
+			KeyUsage:     x509.KeyUsageDigitalSignature,
+			ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+			URIs:         []*url.URL{uri},
+		}
+
+		der, err := x509.CreateCertificate(rand.Reader, template, ca, &key.PublicKey, caKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return &x509svid.SVID{ID: spiffeid.RequireFromString(id), Certificates: []*x509.Certificate{cert}, PrivateKey: key}
+	}
+
+	bundle := x509bundle.FromX509Authorities(spiffeid.RequireTrustDomainFromString("example.org"), []*x509.Certificate{ca})
+	// httptest's StartTLS replaces the server's certificate, so the SVID is served with a TLS listener instead
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	serverConfig := tlsconfig.MTLSServerConfig(newSVID(2, "spiffe://example.org/archivista"), bundle, tlsconfig.AuthorizeAny())
+	server.Listener = tls.NewListener(server.Listener, serverConfig)
+	server.Config.ErrorLog = log.New(io.Discard, "", 0)
+	server.Start()
+	defer server.Close()
+
+	clientSVID := newSVID(3, "spiffe://example.org/ci")
+	tests := map[string]bool{
+		"spiffe://example.org/archivista": true,
+		"spiffe://example.org/*":          true,
+		"spiffe://example.org/other":      false,
+		"spiffe://other.org/*":            false,
+	}
+
+	for pattern, allowed := range tests {
+		tlsConfig, err := ClientConfig(clientSVID, bundle, []string{pattern})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig}}
+		resp, err := client.Get("https://" + server.Listener.Addr().String())
+		if err == nil {
+			resp.Body.Close()
+		}
+
+		if allowed && err != nil {
+			t.Errorf("expected connection with %v to succeed: %v", pattern, err)
+		} else if !allowed && err == nil {
+			t.Errorf("expected connection with %v to fail", pattern)
+		}
+	}
+}
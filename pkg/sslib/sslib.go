@@ -0,0 +1,310 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package sslib lets witness read DSSE envelopes and keys produced by securesystemslib, the signing library used by
+// the python in-toto tools.
+package sslib
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/testifysec/go-witness/dsse"
+)
+
+// Key is the securesystemslib representation of a public key that its keyids are calculated over.
+type Key struct {
+	KeyType             string            `json:"keytype"`
+	Scheme              string            `json:"scheme"`
+	KeyIDHashAlgorithms []string          `json:"keyid_hash_algorithms,omitempty"`
+	KeyVal              map[string]string `json:"keyval"`
+}
+
+// NewKey returns the securesystemslib representation of pub. Only P-256 ecdsa, ed25519, and rsa keys are supported,
+// with the ecdsa-sha2-nistp256, ed25519, and rsassa-pss-sha256 schemes respectively.
+func NewKey(pub crypto.PublicKey) (Key, error) {
+	switch k := pub.(type) {
+	case *ecdsa.PublicKey:
+		if k.Curve != elliptic.P256() {
+			return Key{}, fmt.Errorf("unsupported ecdsa curve %v", k.Curve.Params().Name)
+		}
+
+		public, err := publicPEM(pub)
+		if err != nil {
+			return Key{}, err
+		}
+
+		return Key{KeyType: "ecdsa", Scheme: "ecdsa-sha2-nistp256", KeyVal: map[string]string{"public": public}}, nil
+	case ed25519.PublicKey:
+		return Key{KeyType: "ed25519", Scheme: "ed25519", KeyVal: map[string]string{"public": hex.EncodeToString(k)}}, nil
+	case *rsa.PublicKey:
+		public, err := publicPEM(pub)
+		if err != nil {
+			return Key{}, err
+		}
+
+		return Key{KeyType: "rsa", Scheme: "rsassa-pss-sha256", KeyVal: map[string]string{"public": public}}, nil
+	default:
+		return Key{}, fmt.Errorf("unsupported key type %T", pub)
+	}
+}
+
+// PublicKey parses the key's public value. ed25519 keys are hex encoded. ecdsa and rsa keys are PEM encoded, though
+// ecdsa keys written by older TUF tools are the hex encoded uncompressed point instead.
+func (k Key) PublicKey() (crypto.PublicKey, error) {
+	public := k.KeyVal["public"]
+	switch k.KeyType {
+	case "ed25519":
+		raw, err := hex.DecodeString(public)
+		if err != nil || len(raw) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid ed25519 public key")
+		}
+
+		return ed25519.PublicKey(raw), nil
+	case "ecdsa", "ecdsa-sha2-nistp256", "rsa":
+		if block, _ := pem.Decode([]byte(public)); block != nil {
+			pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse public key: %w", err)
+			}
+
+			return pub, nil
+		}
+
+		if raw, err := hex.DecodeString(public); err == nil && k.KeyType != "rsa" {
+			x, y := elliptic.Unmarshal(elliptic.P256(), raw)
+			if x != nil {
+				return &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}, nil
+			}
+		}
+
+		return nil, fmt.Errorf("invalid %v public key", k.KeyType)
+	default:
+		return nil, fmt.Errorf("unsupported key type %v", k.KeyType)
+	}
+}
+
+func publicPEM(pub crypto.PublicKey) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal public key: %w", err)
+	}
+
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})), nil
+}
+
+// KeyID returns the keyid securesystemslib calculates for the key: the hex encoded sha256 digest of the key's
+// canonical json.
+func (k Key) KeyID() (string, error) {
+	canonical, err := CanonicalJSON(k)
+	if err != nil {
+		return "", err
+	}
+
+	digest := sha256.Sum256(canonical)
+	return hex.EncodeToString(digest[:]), nil
+}
+
+// KeyIDs returns the keyids securesystemslib may have assigned to pub. Current releases hash the key with its PEM
+// as written by the cryptography library. Older releases, and keys imported with the legacy interface, also record
+// the keyid hash algorithms and strip the PEM's trailing newline.
+func KeyIDs(pub crypto.PublicKey) ([]string, error) {
+	key, err := NewKey(pub)
+	if err != nil {
+		return nil, err
+	}
+
+	current, err := key.KeyID()
+	if err != nil {
+		return nil, err
+	}
+
+	legacy := Key{
+		KeyType:             key.KeyType,
+		Scheme:              key.Scheme,
+		KeyIDHashAlgorithms: []string{"sha256", "sha512"},
+		KeyVal:              map[string]string{"public": strings.TrimSuffix(key.KeyVal["public"], "\n")},
+	}
+
+	legacyID, err := legacy.KeyID()
+	if err != nil {
+		return nil, err
+	}
+
+	return []string{current, legacyID}, nil
+}
+
+type envelope struct {
+	PayloadType string      `json:"payloadType"`
+	Payload     []byte      `json:"payload"`
+	Signatures  []signature `json:"signatures"`
+}
+
+type signature struct {
+	KeyID         string   `json:"keyid"`
+	Sig           string   `json:"sig"`
+	Certificate   []byte   `json:"certificate,omitempty"`
+	Intermediates [][]byte `json:"intermediates,omitempty"`
+}
+
+// ParseEnvelope decodes a DSSE envelope written by witness or by securesystemslib. securesystemslib hex encodes
+// signatures rather than base64 encoding them as the DSSE specification and witness do, so signatures made up
+// entirely of lower case hex digits are hex decoded.
+func ParseEnvelope(data []byte) (dsse.Envelope, error) {
+	raw := envelope{}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return dsse.Envelope{}, fmt.Errorf("failed to unmarshal envelope: %w", err)
+	}
+
+	env := dsse.Envelope{
+		PayloadType: raw.PayloadType,
+		Payload:     raw.Payload,
+		Signatures:  make([]dsse.Signature, 0, len(raw.Signatures)),
+	}
+
+	for i, sig := range raw.Signatures {
+		decoded, err := decodeSignature(sig.Sig)
+		if err != nil {
+			return dsse.Envelope{}, fmt.Errorf("failed to decode signature %d: %w", i, err)
+		}
+
+		env.Signatures = append(env.Signatures, dsse.Signature{
+			KeyID:         sig.KeyID,
+			Signature:     decoded,
+			Certificate:   sig.Certificate,
+			Intermediates: sig.Intermediates,
+		})
+	}
+
+	return env, nil
+}
+
+func decodeSignature(sig string) ([]byte, error) {
+	if isHex(sig) {
+		return hex.DecodeString(sig)
+	}
+
+	return base64.StdEncoding.DecodeString(sig)
+}
+
+func isHex(s string) bool {
+	if len(s) == 0 || len(s)%2 != 0 {
+		return false
+	}
+
+	for _, c := range s {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+
+	return true
+}
+
+// CanonicalJSON encodes v as OLPC canonical json, which securesystemslib uses to calculate keyids and TUF signs
+// metadata over: object keys are sorted, there is no insignificant whitespace, and only backslashes and quotes are
+// escaped in strings.
+func CanonicalJSON(v interface{}) ([]byte, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	var generic interface{}
+	if err := json.Unmarshal(b, &generic); err != nil {
+		return nil, err
+	}
+
+	buf := &bytes.Buffer{}
+	if err := writeCanonical(buf, generic); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func writeCanonical(buf *bytes.Buffer, v interface{}) error {
+	switch val := v.(type) {
+	case nil:
+		buf.WriteString("null")
+	case bool:
+		if val {
+			buf.WriteString("true")
+		} else {
+			buf.WriteString("false")
+		}
+	case float64:
+		if val != float64(int64(val)) {
+			return fmt.Errorf("canonical json does not support floating point numbers")
+		}
+
+		fmt.Fprintf(buf, "%d", int64(val))
+	case string:
+		buf.WriteByte('"')
+		buf.WriteString(strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(val))
+		buf.WriteByte('"')
+	case []interface{}:
+		buf.WriteByte('[')
+		for i, item := range val {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+
+			if err := writeCanonical(buf, item); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte(']')
+	case map[string]interface{}:
+		keys := make([]string, 0, len(val))
+		for k := range val {
+			keys = append(keys, k)
+		}
+
+		sort.Strings(keys)
+		buf.WriteByte('{')
+		for i, k := range keys {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+
+			if err := writeCanonical(buf, k); err != nil {
+				return err
+			}
+
+			buf.WriteByte(':')
+			if err := writeCanonical(buf, val[k]); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+	default:
+		return fmt.Errorf("unsupported canonical json type %T", v)
+	}
+
+	return nil
+}
@@ -0,0 +1,220 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sslib
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/dsse"
+)
+
+func TestCanonicalJSON(t *testing.T) {
+	key := Key{
+		KeyType:             "ecdsa",
+		Scheme:              "ecdsa-sha2-nistp256",
+		KeyIDHashAlgorithms: []string{"sha256", "sha512"},
+		KeyVal:              map[string]string{"public": "-----BEGIN PUBLIC KEY-----\nabc\"\\<>\n-----END PUBLIC KEY-----"},
+	}
+
+	canonical, err := CanonicalJSON(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `{"keyid_hash_algorithms":["sha256","sha512"],"keytype":"ecdsa","keyval":{"public":"-----BEGIN PUBLIC KEY-----` +
+		"\n" + `abc\"\\<>` + "\n" + `-----END PUBLIC KEY-----"},"scheme":"ecdsa-sha2-nistp256"}`
+	if string(canonical) != expected {
+		t.Fatalf("unexpected canonical json:\n%s\nexpected:\n%s", canonical, expected)
+	}
+
+	keyID, err := key.KeyID()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	digest := sha256.Sum256([]byte(expected))
+	if keyID != hex.EncodeToString(digest[:]) {
+		t.Fatalf("unexpected keyid %v", keyID)
+	}
+}
+
+func TestKeyIDs(t *testing.T) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ids, err := KeyIDs(&priv.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(ids) != 2 || ids[0] == ids[1] {
+		t.Fatalf("expected two distinct keyids, got %v", ids)
+	}
+
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := NewKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if key.KeyVal["public"] != hex.EncodeToString(pub) {
+		t.Fatalf("expected ed25519 key to be hex encoded, got %v", key.KeyVal["public"])
+	}
+
+	p384, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := KeyIDs(&p384.PublicKey); err == nil {
+		t.Fatal("expected an error for a P-384 key")
+	}
+}
+
+func TestKeyPublicKey(t *testing.T) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	edPub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, pub := range []crypto.PublicKey{&priv.PublicKey, edPub} {
+		key, err := NewKey(pub)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		parsed, err := key.PublicKey()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !pub.(interface{ Equal(crypto.PublicKey) bool }).Equal(parsed) {
+			t.Fatalf("parsed %v key does not match", key.KeyType)
+		}
+	}
+
+	legacy := Key{
+		KeyType: "ecdsa-sha2-nistp256",
+		Scheme:  "ecdsa-sha2-nistp256",
+		KeyVal:  map[string]string{"public": hex.EncodeToString(elliptic.Marshal(elliptic.P256(), priv.X, priv.Y))},
+	}
+
+	parsed, err := legacy.PublicKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !priv.PublicKey.Equal(parsed) {
+		t.Fatal("parsed hex encoded ecdsa key does not match")
+	}
+
+	if _, err := (Key{KeyType: "ed25519", KeyVal: map[string]string{"public": "abcd"}}).PublicKey(); err == nil {
+		t.Fatal("expected an error for a short ed25519 key")
+	}
+}
+
+func TestParseEnvelope(t *testing.T) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signer := cryptoutil.NewECDSASigner(priv, crypto.SHA256)
+	verifier, err := signer.Verifier()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signed, err := dsse.Sign("application/vnd.in-toto+json", bytes.NewReader([]byte(`{"_type":"link"}`)), signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	witnessJSON, err := json.Marshal(signed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ids, err := KeyIDs(&priv.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sslibJSON := fmt.Sprintf(`{"payload":%q,"payloadType":%q,"signatures":[{"keyid":%q,"sig":%q}]}`,
+		base64.StdEncoding.EncodeToString(signed.Payload), signed.PayloadType, ids[0], hex.EncodeToString(signed.Signatures[0].Signature))
+
+	for name, data := range map[string][]byte{"witness": witnessJSON, "securesystemslib": []byte(sslibJSON)} {
+		t.Run(name, func(t *testing.T) {
+			env, err := ParseEnvelope(data)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if _, err := env.Verify(dsse.WithVerifiers([]cryptoutil.Verifier{verifier})); err != nil {
+				t.Fatalf("failed to verify envelope: %v", err)
+			}
+		})
+	}
+}
+
+func BenchmarkParseEnvelope(b *testing.B) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	payload := bytes.Repeat([]byte(`{"name":"file:dir/app","digest":{"sha256":"aa"}},`), 1000)
+	signed, err := dsse.Sign("application/vnd.in-toto+json", bytes.NewReader(payload), cryptoutil.NewECDSASigner(priv, crypto.SHA256))
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	data, err := json.Marshal(signed)
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(data)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := ParseEnvelope(data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,185 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package store manages a local directory of attestation envelopes, such as those written by witness run.
+package store
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/testifysec/go-witness/dsse"
+	"github.com/testifysec/go-witness/intoto"
+)
+
+const (
+	TombstoneType   = "https://witness.dev/tombstone/v0.1"
+	TombstoneSuffix = ".tombstone.json"
+)
+
+// Tombstone replaces an attestation removed by garbage collection. It preserves enough of the attestation to prove it
+// existed and identify what it was about, without keeping the attestation's contents.
+type Tombstone struct {
+	Type string `json:"type"`
+	// Digest is the sha256 of the removed file, in the same form witness verify uses as an evidence reference.
+	Digest        string           `json:"digest"`
+	Name          string           `json:"name"`
+	Size          int64            `json:"size"`
+	PayloadType   string           `json:"payloadtype,omitempty"`
+	PredicateType string           `json:"predicatetype,omitempty"`
+	Subjects      []intoto.Subject `json:"subjects,omitempty"`
+	KeyIDs        []string         `json:"keyids,omitempty"`
+	ModifiedAt    time.Time        `json:"modifiedat"`
+	RemovedAt     time.Time        `json:"removedat"`
+}
+
+// GCOptions configure a garbage collection of a store directory.
+type GCOptions struct {
+	// OlderThan is the minimum age of an attestation to be removed, based on the file's modification time.
+	OlderThan time.Duration
+	// DryRun returns the tombstones that would be written without changing the directory.
+	DryRun bool
+	Now    time.Time
+}
+
+// GC replaces attestation envelopes in dir older than opts.OlderThan with tombstones and returns the tombstones.
+// Files that are not DSSE envelopes and existing tombstones are left alone.
+func GC(dir string, opts GCOptions) ([]Tombstone, error) {
+	if opts.Now.IsZero() {
+		opts.Now = time.Now()
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read store directory: %w", err)
+	}
+
+	tombstones := make([]Tombstone, 0)
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasSuffix(entry.Name(), TombstoneSuffix) {
+			continue
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+
+		if opts.Now.Sub(info.ModTime()) < opts.OlderThan {
+			continue
+		}
+
+		path := filepath.Join(dir, entry.Name())
+		tombstone, ok, err := newTombstone(path, info, opts.Now)
+		if err != nil {
+			return nil, err
+		}
+
+		if !ok {
+			continue
+		}
+
+		tombstones = append(tombstones, tombstone)
+		if opts.DryRun {
+			continue
+		}
+
+		if err := writeTombstone(path+TombstoneSuffix, tombstone); err != nil {
+			return nil, err
+		}
+
+		if err := os.Remove(path); err != nil {
+			return nil, fmt.Errorf("failed to remove attestation: %w", err)
+		}
+	}
+
+	return tombstones, nil
+}
+
+// LoadTombstones returns the tombstones in dir.
+func LoadTombstones(dir string) ([]Tombstone, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*"+TombstoneSuffix))
+	if err != nil {
+		return nil, err
+	}
+
+	tombstones := make([]Tombstone, 0, len(paths))
+	for _, path := range paths {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		tombstone := Tombstone{}
+		if err := json.Unmarshal(b, &tombstone); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal tombstone %v: %w", path, err)
+		}
+
+		tombstones = append(tombstones, tombstone)
+	}
+
+	return tombstones, nil
+}
+
+func newTombstone(path string, info os.FileInfo, now time.Time) (Tombstone, bool, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return Tombstone{}, false, err
+	}
+
+	env := dsse.Envelope{}
+	if err := json.Unmarshal(b, &env); err != nil || len(env.Signatures) == 0 {
+		return Tombstone{}, false, nil
+	}
+
+	tombstone := Tombstone{
+		Type:        TombstoneType,
+		Digest:      fmt.Sprintf("sha256:%x", sha256.Sum256(b)),
+		Name:        info.Name(),
+		Size:        info.Size(),
+		PayloadType: env.PayloadType,
+		ModifiedAt:  info.ModTime().UTC(),
+		RemovedAt:   now.UTC(),
+	}
+
+	for _, sig := range env.Signatures {
+		tombstone.KeyIDs = append(tombstone.KeyIDs, sig.KeyID)
+	}
+
+	statement := intoto.Statement{}
+	if env.PayloadType == intoto.PayloadType && json.Unmarshal(env.Payload, &statement) == nil {
+		tombstone.PredicateType = statement.PredicateType
+		tombstone.Subjects = statement.Subject
+	}
+
+	return tombstone, true, nil
+}
+
+func writeTombstone(path string, tombstone Tombstone) error {
+	b, err := json.Marshal(tombstone)
+	if err != nil {
+		return err
+	}
+
+	if err := os.WriteFile(path, b, 0644); err != nil {
+		return fmt.Errorf("failed to write tombstone: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,126 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package store
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/testifysec/go-witness/dsse"
+	"github.com/testifysec/go-witness/intoto"
+)
+
+func writeEnvelope(t *testing.T, path string, modTime time.Time) {
+	statement := intoto.Statement{
+		Type:          intoto.StatementType,
+		PredicateType: "https://witness.testifysec.com/attestation-collection/v0.1",
+		Subject:       []intoto.Subject{{Name: "file:app", Digest: map[string]string{"sha256": "abc"}}},
+		Predicate:     json.RawMessage(`{}`),
+	}
+
+	payload, err := json.Marshal(statement)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := json.Marshal(dsse.Envelope{
+		Payload:     payload,
+		PayloadType: intoto.PayloadType,
+		Signatures:  []dsse.Signature{{KeyID: "key", Signature: []byte("sig")}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(path, b, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestGC(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	writeEnvelope(t, filepath.Join(dir, "old.json"), now.Add(-48*time.Hour))
+	writeEnvelope(t, filepath.Join(dir, "new.json"), now)
+	if err := os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("not an envelope"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.Chtimes(filepath.Join(dir, "notes.txt"), now.Add(-48*time.Hour), now.Add(-48*time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+
+	tombstones, err := GC(dir, GCOptions{OlderThan: 24 * time.Hour, Now: now})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(tombstones) != 1 {
+		t.Fatalf("expected 1 tombstone, got %d", len(tombstones))
+	}
+
+	tombstone := tombstones[0]
+	if tombstone.Name != "old.json" || len(tombstone.Subjects) != 1 || tombstone.Subjects[0].Digest["sha256"] != "abc" {
+		t.Errorf("unexpected tombstone: %+v", tombstone)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "old.json")); !os.IsNotExist(err) {
+		t.Error("expected old attestation to be removed")
+	}
+
+	for _, name := range []string{"new.json", "notes.txt"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err != nil {
+			t.Errorf("expected %v to be kept: %v", name, err)
+		}
+	}
+
+	loaded, err := LoadTombstones(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(loaded) != 1 || loaded[0].Digest != tombstone.Digest {
+		t.Errorf("unexpected tombstones loaded: %+v", loaded)
+	}
+}
+
+func TestGCDryRun(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	writeEnvelope(t, filepath.Join(dir, "old.json"), now.Add(-48*time.Hour))
+	tombstones, err := GC(dir, GCOptions{OlderThan: 24 * time.Hour, DryRun: true, Now: now})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(tombstones) != 1 {
+		t.Fatalf("expected 1 tombstone, got %d", len(tombstones))
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "old.json")); err != nil {
+		t.Errorf("expected attestation to be kept on dry run: %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "old.json"+TombstoneSuffix)); !os.IsNotExist(err) {
+		t.Error("expected no tombstone to be written on dry run")
+	}
+}
@@ -0,0 +1,117 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package subjects records which attestors contributed each subject of a collection. A statement's subjects are only
+// names and digests, so without this a verifier can not tell a product the product attestor hashed from a digest
+// another attestor, such as labels, put in the statement.
+package subjects
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/testifysec/go-witness/attestation"
+	"github.com/testifysec/go-witness/cryptoutil"
+)
+
+// Subject is a subject of a collection's statement and the types of the attestors that contributed it. Besides the
+// attestor that named the subject, any attestor that reported a subject with the same digest contributed it.
+type Subject struct {
+	Name      string            `json:"name"`
+	Digest    map[string]string `json:"digest"`
+	Attestors []string          `json:"attestors"`
+}
+
+// Collection is a collection extended with the provenance of its subjects. It marshals to the collection format with
+// an additional subjects field, which verifiers that do not know of it ignore.
+type Collection struct {
+	attestation.Collection
+	Subjects []Subject `json:"subjects"`
+}
+
+// NewCollection extends the collection with the provenance of its subjects.
+func NewCollection(c attestation.Collection) Collection {
+	return Collection{Collection: c, Subjects: Provenance(c)}
+}
+
+// Provenance returns the collection's subjects, named as they are in its statement, sorted by name.
+func Provenance(c attestation.Collection) []Subject {
+	named := c.Subjects()
+	attestorsByDigest := map[string]map[string]bool{}
+	for _, ca := range c.Attestations {
+		subjecter, ok := ca.Attestation.(attestation.Subjecter)
+		if !ok {
+			continue
+		}
+
+		for _, ds := range subjecter.Subjects() {
+			for _, digest := range digestKeys(nameMap(ds)) {
+				if attestorsByDigest[digest] == nil {
+					attestorsByDigest[digest] = map[string]bool{}
+				}
+
+				attestorsByDigest[digest][ca.Type] = true
+			}
+		}
+	}
+
+	subjects := make([]Subject, 0, len(named))
+	for name, ds := range named {
+		digest := nameMap(ds)
+		attestors := map[string]bool{}
+		for _, key := range digestKeys(digest) {
+			for attestor := range attestorsByDigest[key] {
+				attestors[attestor] = true
+			}
+		}
+
+		subject := Subject{Name: name, Digest: digest, Attestors: make([]string, 0, len(attestors))}
+		for attestor := range attestors {
+			subject.Attestors = append(subject.Attestors, attestor)
+		}
+
+		sort.Strings(subject.Attestors)
+		subjects = append(subjects, subject)
+	}
+
+	sort.Slice(subjects, func(i, j int) bool { return subjects[i].Name < subjects[j].Name })
+	return subjects
+}
+
+// nameMap returns the digests of the set by algorithm name, leaving out any digest of an algorithm without a name.
+func nameMap(ds cryptoutil.DigestSet) map[string]string {
+	names := make(map[string]string, len(ds))
+	for hash, value := range ds {
+		named, err := cryptoutil.DigestSet{hash: value}.ToNameMap()
+		if err != nil {
+			continue
+		}
+
+		for name, value := range named {
+			names[name] = value
+		}
+	}
+
+	return names
+}
+
+// digestKeys returns an algorithm:value key for each digest.
+func digestKeys(digest map[string]string) []string {
+	keys := make([]string, 0, len(digest))
+	for algorithm, value := range digest {
+		keys = append(keys, fmt.Sprintf("%v:%v", algorithm, value))
+	}
+
+	return keys
+}
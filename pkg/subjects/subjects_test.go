@@ -0,0 +1,82 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package subjects
+
+import (
+	"crypto"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/testifysec/go-witness/attestation"
+	"github.com/testifysec/go-witness/cryptoutil"
+)
+
+type subjecter struct {
+	attestorType string
+	subjects     map[string]cryptoutil.DigestSet
+}
+
+func (s *subjecter) Name() string                                     { return "subjecter" }
+func (s *subjecter) Type() string                                     { return s.attestorType }
+func (s *subjecter) RunType() attestation.RunType                     { return attestation.PostRunType }
+func (s *subjecter) Attest(ctx *attestation.AttestationContext) error { return nil }
+func (s *subjecter) Subjects() map[string]cryptoutil.DigestSet        { return s.subjects }
+
+func TestProvenance(t *testing.T) {
+	product := &subjecter{"https://example.com/product", map[string]cryptoutil.DigestSet{
+		"file:app": {crypto.SHA256: "aaaa"},
+		"file:lib": {crypto.SHA256: "bbbb"},
+	}}
+
+	labels := &subjecter{"https://example.com/labels", map[string]cryptoutil.DigestSet{
+		"label:app": {crypto.SHA256: "aaaa"},
+		"label:env": {crypto.SHA256: "cccc"},
+	}}
+
+	subjects := Provenance(attestation.NewCollection("build", []attestation.Attestor{product, labels}))
+	expected := []Subject{
+		{Name: "https://example.com/labels/label:app", Digest: map[string]string{"sha256": "aaaa"}, Attestors: []string{"https://example.com/labels", "https://example.com/product"}},
+		{Name: "https://example.com/labels/label:env", Digest: map[string]string{"sha256": "cccc"}, Attestors: []string{"https://example.com/labels"}},
+		{Name: "https://example.com/product/file:app", Digest: map[string]string{"sha256": "aaaa"}, Attestors: []string{"https://example.com/labels", "https://example.com/product"}},
+		{Name: "https://example.com/product/file:lib", Digest: map[string]string{"sha256": "bbbb"}, Attestors: []string{"https://example.com/product"}},
+	}
+
+	if !reflect.DeepEqual(subjects, expected) {
+		t.Fatalf("expected %+v, got %+v", expected, subjects)
+	}
+}
+
+func TestCollectionJSON(t *testing.T) {
+	product := &subjecter{"https://example.com/product", map[string]cryptoutil.DigestSet{"file:app": {crypto.SHA256: "aaaa"}}}
+	data, err := json.Marshal(NewCollection(attestation.NewCollection("build", []attestation.Attestor{product})))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decoded := struct {
+		Name         string            `json:"name"`
+		Attestations []json.RawMessage `json:"attestations"`
+		Subjects     []Subject         `json:"subjects"`
+	}{}
+
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	if decoded.Name != "build" || len(decoded.Attestations) != 1 || len(decoded.Subjects) != 1 {
+		t.Fatalf("expected the collection's fields alongside its subjects, got %s", data)
+	}
+}
@@ -0,0 +1,200 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+// Package supervise lets witness run as the entrypoint of a container. As the init process of the container's pid
+// namespace witness must forward the signals the container runtime sends to the processes it started, and reap the
+// processes orphaned in the namespace, or they are left as zombies.
+package supervise
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/testifysec/go-witness/log"
+)
+
+// forwarded are the signals passed on to child processes. Signals the go runtime uses internally, such as SIGURG,
+// and signals that only make sense for witness itself, such as SIGPIPE, are not forwarded.
+var forwarded = []os.Signal{syscall.SIGTERM, syscall.SIGINT, syscall.SIGHUP, syscall.SIGQUIT, syscall.SIGUSR1, syscall.SIGUSR2, syscall.SIGWINCH}
+
+// supervisedEnv is set in the environment of the witness process Reexec starts.
+const supervisedEnv = "WITNESS_SUPERVISED"
+
+// forward installs signal forwarding, and is replaced in tests.
+var forward = Forward
+
+// IsInit reports whether witness is the init process of its pid namespace, such as a container's entrypoint.
+func IsInit() bool {
+	return os.Getpid() == 1
+}
+
+// IsSupervised reports whether witness was started by Reexec as the child of a container's init process.
+func IsSupervised() bool {
+	return os.Getenv(supervisedEnv) == "1"
+}
+
+// ForwardIfSupervised calls Forward if witness is the init process or was started by Reexec, where signals must be
+// passed on for the command to see them. Otherwise signals keep their default behavior, so a terminal's SIGINT still
+// stops witness along with the command, and it returns a function that does nothing.
+func ForwardIfSupervised() func() {
+	if !IsInit() && !IsSupervised() {
+		return func() {}
+	}
+
+	return forward()
+}
+
+// Reexec runs witness again with the same arguments as a child of this process, forwarding signals to it and reaping
+// every process orphaned into the pid namespace until it exits. It returns the child's exit code, or 128 plus the
+// signal number if the child was killed by a signal, as a shell would.
+func Reexec() (int, error) {
+	signals := make(chan os.Signal, 32)
+	signal.Notify(signals, append([]os.Signal{syscall.SIGCHLD}, forwarded...)...)
+	defer signal.Stop(signals)
+
+	c := exec.Command("/proc/self/exe", os.Args[1:]...)
+	c.Args[0] = os.Args[0]
+	c.Env = append(os.Environ(), supervisedEnv+"=1")
+	c.Stdin = os.Stdin
+	c.Stdout = os.Stdout
+	c.Stderr = os.Stderr
+	if err := c.Start(); err != nil {
+		return 0, fmt.Errorf("failed to start witness as a child of the init process: %w", err)
+	}
+
+	// the child is reaped below along with the orphans rather than with c.Wait, which would race with the reaping
+	for sig := range signals {
+		if sig != syscall.SIGCHLD {
+			if err := c.Process.Signal(sig); err != nil {
+				log.Debugf("(supervise) failed to forward %v: %v", sig, err)
+			}
+
+			continue
+		}
+
+		for {
+			var status syscall.WaitStatus
+			pid, err := syscall.Wait4(-1, &status, syscall.WNOHANG, nil)
+			if err != nil || pid <= 0 {
+				break
+			}
+
+			if pid == c.Process.Pid {
+				return exitCode(status), nil
+			}
+		}
+	}
+
+	return 0, fmt.Errorf("stopped receiving signals before witness exited")
+}
+
+func exitCode(status syscall.WaitStatus) int {
+	if status.Signaled() {
+		return 128 + int(status.Signal())
+	}
+
+	return status.ExitStatus()
+}
+
+// Forward passes the signals witness receives on to its child processes, such as the command being recorded by
+// witness run, until the returned function is called. A child that leads its own process group is signalled along
+// with its group. While Forward is active the signals don't stop witness, so a SIGTERM that arrives after the command
+// exited lets witness finish signing and storing the attestation before it exits.
+func Forward() func() {
+	signals := make(chan os.Signal, 32)
+	signal.Notify(signals, forwarded...)
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		for {
+			select {
+			case <-done:
+				return
+			case sig := <-signals:
+				children := children()
+				if len(children) == 0 {
+					log.Infof("Received %v, exiting once the attestation is finished", sig)
+					continue
+				}
+
+				for _, pid := range children {
+					signalChild(pid, sig.(syscall.Signal))
+				}
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(signals)
+		close(done)
+		<-stopped
+	}
+}
+
+func signalChild(pid int, sig syscall.Signal) {
+	target := pid
+	if pgid, err := syscall.Getpgid(pid); err == nil && pgid == pid && pgid != syscall.Getpgrp() {
+		target = -pgid
+	}
+
+	if err := syscall.Kill(target, sig); err != nil {
+		log.Debugf("(supervise) failed to forward %v to %v: %v", sig, pid, err)
+	}
+}
+
+// children returns the pids of the running processes whose parent is witness.
+func children() []int {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil
+	}
+
+	self := os.Getpid()
+	pids := make([]int, 0)
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+
+		status, err := os.ReadFile(filepath.Join("/proc", entry.Name(), "status"))
+		if err != nil {
+			continue
+		}
+
+		parent, zombie := -1, false
+		for _, line := range strings.Split(string(status), "\n") {
+			if strings.HasPrefix(line, "PPid:") {
+				parent, _ = strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "PPid:")))
+			} else if strings.HasPrefix(line, "State:") {
+				zombie = strings.HasPrefix(strings.TrimSpace(strings.TrimPrefix(line, "State:")), "Z")
+			}
+		}
+
+		if parent == self && !zombie {
+			pids = append(pids, pid)
+		}
+	}
+
+	return pids
+}
@@ -0,0 +1,109 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux
+
+package supervise
+
+import (
+	"errors"
+	"os/exec"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestForward(t *testing.T) {
+	c := exec.Command("sleep", "30")
+	if err := c.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	stop := Forward()
+	defer stop()
+
+	exited := make(chan error, 1)
+	go func() { exited <- c.Wait() }()
+
+	// the child may not be visible in /proc until it has exec'd, so keep signalling until it exits
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+	timeout := time.After(10 * time.Second)
+	for {
+		select {
+		case err := <-exited:
+			exitErr := &exec.ExitError{}
+			if !errors.As(err, &exitErr) {
+				t.Fatalf("expected the child to be killed, got %v", err)
+			}
+
+			if status := exitErr.Sys().(syscall.WaitStatus); !status.Signaled() || status.Signal() != syscall.SIGTERM {
+				t.Fatalf("expected the child to be killed by SIGTERM, got %v", status)
+			}
+
+			return
+		case <-ticker.C:
+			if err := syscall.Kill(syscall.Getpid(), syscall.SIGTERM); err != nil {
+				t.Fatal(err)
+			}
+		case <-timeout:
+			_ = c.Process.Kill()
+			t.Fatal("SIGTERM was not forwarded to the child")
+		}
+	}
+}
+
+func TestForwardIfSupervised(t *testing.T) {
+	forwarding := false
+	forward = func() func() {
+		forwarding = true
+		return func() { forwarding = false }
+	}
+	defer func() { forward = Forward }()
+
+	// outside a container, witness and the command keep the default signal behavior
+	t.Setenv(supervisedEnv, "")
+	stop := ForwardIfSupervised()
+	if forwarding {
+		t.Error("expected signals not to be forwarded when witness is not the init process or its child")
+	}
+
+	stop()
+	t.Setenv(supervisedEnv, "1")
+	stop = ForwardIfSupervised()
+	if !forwarding {
+		t.Error("expected signals to be forwarded when witness was started by the init process")
+	}
+
+	stop()
+	if forwarding {
+		t.Error("expected forwarding to stop")
+	}
+}
+
+func TestExitCode(t *testing.T) {
+	for _, test := range []struct {
+		args     []string
+		expected int
+	}{
+		{[]string{"-c", "exit 3"}, 3},
+		{[]string{"-c", "kill -TERM $$"}, 128 + int(syscall.SIGTERM)},
+	} {
+		c := exec.Command("sh", test.args...)
+		_ = c.Run()
+		if code := exitCode(c.ProcessState.Sys().(syscall.WaitStatus)); code != test.expected {
+			t.Fatalf("expected exit code %v for %v, got %v", test.expected, test.args, code)
+		}
+	}
+}
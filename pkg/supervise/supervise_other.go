@@ -0,0 +1,43 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !linux
+
+package supervise
+
+import "fmt"
+
+// IsInit is only implemented on linux, where containers run.
+func IsInit() bool {
+	return false
+}
+
+// IsSupervised is only implemented on linux, where containers run.
+func IsSupervised() bool {
+	return false
+}
+
+func Reexec() (int, error) {
+	return 0, fmt.Errorf("running as an init process is only supported on linux")
+}
+
+// Forward is only implemented on linux. Elsewhere signals keep their default behavior.
+func Forward() func() {
+	return func() {}
+}
+
+// ForwardIfSupervised is only implemented on linux. Elsewhere signals keep their default behavior.
+func ForwardIfSupervised() func() {
+	return func() {}
+}
@@ -0,0 +1,163 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package tektonchains stores signed collections the way Tekton Chains' tekton storage backend stores attestations,
+// as annotations on the TaskRun they were recorded for, so witness attestations can be kept alongside and verified
+// with existing Chains storage.
+package tektonchains
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/testifysec/go-witness/dsse"
+)
+
+const (
+	// Prefix is the prefix of the annotations Chains stores attestations in.
+	Prefix = "chains.tekton.dev/"
+	// SignedAnnotation marks a TaskRun as signed so Chains does not sign it again.
+	SignedAnnotation = Prefix + "signed"
+
+	payloadPrefix   = Prefix + "payload-"
+	signaturePrefix = Prefix + "signature-"
+	certPrefix      = Prefix + "cert-"
+	chainPrefix     = Prefix + "chain-"
+)
+
+// Patch is a merge patch that adds annotations to a Kubernetes object, such as with
+// kubectl patch taskrun <name> --type merge --patch-file <file>. TaskRuns read with kubectl get -o json have the same
+// shape.
+type Patch struct {
+	Metadata struct {
+		Annotations map[string]string `json:"annotations"`
+	} `json:"metadata"`
+}
+
+// Annotations returns the annotations Chains would store the envelope in for key, such as taskrun-<uid>. Like
+// Chains' in-toto format, the signature annotation holds the whole envelope and the payload annotation holds its
+// payload. The certificate and chain annotations are set if the first signature has a certificate.
+func Annotations(env dsse.Envelope, key string) (map[string]string, error) {
+	envBytes, err := json.Marshal(env)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal envelope: %w", err)
+	}
+
+	return envelopeAnnotations(env, envBytes, key)
+}
+
+func envelopeAnnotations(env dsse.Envelope, envBytes []byte, key string) (map[string]string, error) {
+	if key == "" {
+		return nil, fmt.Errorf("a key is required to store an envelope in tekton chains annotations")
+	}
+
+	if len(env.Signatures) == 0 {
+		return nil, fmt.Errorf("envelope is not signed")
+	}
+
+	annotations := map[string]string{
+		SignedAnnotation:      "true",
+		payloadPrefix + key:   base64.StdEncoding.EncodeToString(env.Payload),
+		signaturePrefix + key: base64.StdEncoding.EncodeToString(envBytes),
+	}
+
+	sig := env.Signatures[0]
+	if len(sig.Certificate) > 0 {
+		annotations[certPrefix+key] = base64.StdEncoding.EncodeToString(sig.Certificate)
+	}
+
+	if len(sig.Intermediates) > 0 {
+		chain := make([]byte, 0)
+		for _, intermediate := range sig.Intermediates {
+			chain = append(chain, intermediate...)
+		}
+
+		annotations[chainPrefix+key] = base64.StdEncoding.EncodeToString(chain)
+	}
+
+	return annotations, nil
+}
+
+// NewPatch returns a merge patch adding the annotations for key of the envelope encoded in envBytes. The envelope is
+// stored as given, so fields dsse.Envelope doesn't have, such as signature timestamps, are kept.
+func NewPatch(envBytes []byte, key string) (Patch, error) {
+	env := dsse.Envelope{}
+	if err := json.Unmarshal(envBytes, &env); err != nil {
+		return Patch{}, fmt.Errorf("failed to unmarshal envelope: %w", err)
+	}
+
+	annotations, err := envelopeAnnotations(env, envBytes, key)
+	if err != nil {
+		return Patch{}, err
+	}
+
+	patch := Patch{}
+	patch.Metadata.Annotations = annotations
+	return patch, nil
+}
+
+// Envelopes returns the envelopes stored in the signature annotations, sorted by key. Signatures that are not DSSE
+// envelopes, such as those Chains stores for its simplesigning format, are skipped.
+func Envelopes(annotations map[string]string) ([]dsse.Envelope, error) {
+	envs, _, err := envelopes(annotations)
+	return envs, err
+}
+
+func envelopes(annotations map[string]string) ([]dsse.Envelope, [][]byte, error) {
+	keys := make([]string, 0)
+	for name := range annotations {
+		if strings.HasPrefix(name, signaturePrefix) {
+			keys = append(keys, name)
+		}
+	}
+
+	sort.Strings(keys)
+	envs := make([]dsse.Envelope, 0, len(keys))
+	raw := make([][]byte, 0, len(keys))
+	for _, name := range keys {
+		data, err := base64.StdEncoding.DecodeString(annotations[name])
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to decode %v: %w", name, err)
+		}
+
+		env := dsse.Envelope{}
+		if err := json.Unmarshal(data, &env); err != nil || len(env.Signatures) == 0 {
+			continue
+		}
+
+		envs = append(envs, env)
+		raw = append(raw, data)
+	}
+
+	return envs, raw, nil
+}
+
+// Parse returns the envelopes stored in a patch or TaskRun, along with the JSON each was stored as, which may carry
+// signature timestamps. ok is false if data has no Chains signature annotations.
+func Parse(data []byte) (envs []dsse.Envelope, raw [][]byte, ok bool) {
+	patch := Patch{}
+	if err := json.Unmarshal(data, &patch); err != nil {
+		return nil, nil, false
+	}
+
+	envs, raw, err := envelopes(patch.Metadata.Annotations)
+	if err != nil || len(envs) == 0 {
+		return nil, nil, false
+	}
+
+	return envs, raw, true
+}
@@ -0,0 +1,107 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tektonchains
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+
+	"github.com/testifysec/go-witness/dsse"
+)
+
+func TestRoundTrip(t *testing.T) {
+	env := dsse.Envelope{
+		Payload:     []byte(`{"_type":"https://in-toto.io/Statement/v0.1"}`),
+		PayloadType: "application/vnd.in-toto+json",
+		Signatures: []dsse.Signature{{
+			KeyID:         "key",
+			Signature:     []byte("sig"),
+			Certificate:   []byte("-----BEGIN CERTIFICATE-----\nleaf\n-----END CERTIFICATE-----\n"),
+			Intermediates: [][]byte{[]byte("-----BEGIN CERTIFICATE-----\nintermediate\n-----END CERTIFICATE-----\n")},
+		}},
+	}
+
+	envBytes, err := json.Marshal(env)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	patch, err := NewPatch(envBytes, "taskrun-1234")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	annotations := patch.Metadata.Annotations
+	if annotations[SignedAnnotation] != "true" {
+		t.Errorf("expected taskrun to be marked signed: %v", annotations)
+	}
+
+	payload, err := base64.StdEncoding.DecodeString(annotations["chains.tekton.dev/payload-taskrun-1234"])
+	if err != nil || string(payload) != string(env.Payload) {
+		t.Errorf("unexpected payload annotation: %v", annotations)
+	}
+
+	if annotations["chains.tekton.dev/cert-taskrun-1234"] == "" || annotations["chains.tekton.dev/chain-taskrun-1234"] == "" {
+		t.Errorf("expected certificate and chain annotations: %v", annotations)
+	}
+
+	data, err := json.Marshal(patch)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	envs, _, ok := Parse(data)
+	if !ok || len(envs) != 1 || envs[0].Signatures[0].KeyID != "key" || string(envs[0].Payload) != string(env.Payload) {
+		t.Errorf("expected envelope to round trip, got %+v", envs)
+	}
+}
+
+func TestParseIgnoresOtherDocuments(t *testing.T) {
+	simpleSigning := `{"metadata":{"annotations":{"chains.tekton.dev/signature-taskrun-1234":"` +
+		base64.StdEncoding.EncodeToString([]byte("MEUCIQ")) + `"}}}`
+	for _, data := range []string{`{"payload":"e30=","signatures":[]}`, `{"metadata":{"annotations":{}}}`, simpleSigning} {
+		if _, _, ok := Parse([]byte(data)); ok {
+			t.Errorf("expected %v to be ignored", data)
+		}
+	}
+
+	if _, err := Annotations(dsse.Envelope{}, "taskrun-1234"); err == nil {
+		t.Error("expected unsigned envelope to be rejected")
+	}
+}
+
+func TestNewPatchKeepsTimestamps(t *testing.T) {
+	stamped := `{"payload":"e30=","payloadType":"application/vnd.in-toto+json",` +
+		`"signatures":[{"keyid":"key","sig":"c2ln","timestamps":[{"type":"tsp","data":"dG9rZW4="}]}]}`
+	patch, err := NewPatch([]byte(stamped), "taskrun-1234")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	data, err := json.Marshal(patch)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	envs, raw, ok := Parse(data)
+	if !ok || len(envs) != 1 || len(raw) != 1 || string(raw[0]) != stamped {
+		t.Errorf("expected the stamped envelope to be stored as given, got %s", raw)
+	}
+
+	if _, err := NewPatch([]byte("not json"), "taskrun-1234"); err == nil {
+		t.Error("expected invalid envelope to be rejected")
+	}
+}
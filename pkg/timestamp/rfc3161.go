@@ -0,0 +1,546 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package timestamp
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"time"
+
+	// registered for the hashes timestamp authorities may use
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+)
+
+const (
+	queryContentType = "application/timestamp-query"
+	replyContentType = "application/timestamp-reply"
+	// maxResponseSize is the largest timestamp response read from a server.
+	maxResponseSize = 1 << 20
+)
+
+var (
+	oidSignedData    = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+	oidTSTInfo       = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 1, 4}
+	oidContentType   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 3}
+	oidMessageDigest = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 4}
+
+	hashOIDs = map[crypto.Hash]asn1.ObjectIdentifier{
+		crypto.SHA256: {2, 16, 840, 1, 101, 3, 4, 2, 1},
+		crypto.SHA384: {2, 16, 840, 1, 101, 3, 4, 2, 2},
+		crypto.SHA512: {2, 16, 840, 1, 101, 3, 4, 2, 3},
+	}
+)
+
+type messageImprint struct {
+	HashAlgorithm pkix.AlgorithmIdentifier
+	HashedMessage []byte
+}
+
+type timeStampReq struct {
+	Version        int
+	MessageImprint messageImprint
+	ReqPolicy      asn1.ObjectIdentifier `asn1:"optional"`
+	Nonce          *big.Int              `asn1:"optional"`
+	CertReq        bool                  `asn1:"optional"`
+}
+
+type pkiStatusInfo struct {
+	Status       int
+	StatusString []string       `asn1:"optional,utf8"`
+	FailInfo     asn1.BitString `asn1:"optional"`
+}
+
+type timeStampResp struct {
+	Status         pkiStatusInfo
+	TimeStampToken asn1.RawValue `asn1:"optional"`
+}
+
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue
+}
+
+type tstInfo struct {
+	Version        int
+	Policy         asn1.ObjectIdentifier
+	MessageImprint messageImprint
+	SerialNumber   *big.Int
+	GenTime        time.Time `asn1:"generalized"`
+	Accuracy       accuracy  `asn1:"optional"`
+	Ordering       bool      `asn1:"optional"`
+	Nonce          *big.Int  `asn1:"optional"`
+}
+
+type accuracy struct {
+	Seconds int `asn1:"optional"`
+	Millis  int `asn1:"optional,tag:0"`
+	Micros  int `asn1:"optional,tag:1"`
+}
+
+type issuerAndSerialNumber struct {
+	Issuer       asn1.RawValue
+	SerialNumber *big.Int
+}
+
+type attribute struct {
+	Type   asn1.ObjectIdentifier
+	Values asn1.RawValue
+}
+
+// signedData holds the fields of a CMS SignedData structure that are needed to verify a timestamp token.
+type signedData struct {
+	eContentType asn1.ObjectIdentifier
+	eContent     []byte
+	certificates []*x509.Certificate
+	signerInfos  []signerInfo
+}
+
+type signerInfo struct {
+	sid                asn1.RawValue
+	digestAlgorithm    pkix.AlgorithmIdentifier
+	signedAttrs        []byte
+	signatureAlgorithm pkix.AlgorithmIdentifier
+	signature          []byte
+}
+
+// Request asks the RFC 3161 timestamp authority at url for a timestamp over data and returns the DER encoded
+// timestamp token. The token is checked to be over data, but it is not verified against any roots.
+func Request(ctx context.Context, client *http.Client, url string, data []byte) ([]byte, error) {
+	nonce, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 64))
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	imprint, err := newMessageImprint(crypto.SHA256, data)
+	if err != nil {
+		return nil, err
+	}
+
+	query, err := asn1.Marshal(timeStampReq{Version: 1, MessageImprint: imprint, Nonce: nonce, CertReq: true})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal timestamp request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(query))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create timestamp request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", queryContentType)
+	req.Header.Set("Accept", replyContentType)
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to request timestamp from %v: %w", url, err)
+	}
+
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read timestamp response from %v: %w", url, err)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("timestamp authority %v returned %v: %s", url, resp.Status, bytes.TrimSpace(body))
+	}
+
+	token, err := parseResponse(body)
+	if err != nil {
+		return nil, fmt.Errorf("timestamp authority %v: %w", url, err)
+	}
+
+	info, _, err := parseToken(token)
+	if err != nil {
+		return nil, fmt.Errorf("timestamp authority %v returned an invalid token: %w", url, err)
+	}
+
+	if info.Nonce == nil || info.Nonce.Cmp(nonce) != 0 {
+		return nil, fmt.Errorf("timestamp authority %v returned a token for a different request", url)
+	}
+
+	if err := checkImprint(info.MessageImprint, data); err != nil {
+		return nil, fmt.Errorf("timestamp authority %v: %w", url, err)
+	}
+
+	return token, nil
+}
+
+// VerifyToken verifies that the DER encoded timestamp token is over data and signed by a timestamp authority whose
+// certificate chains to one of the roots, and returns the time it was issued.
+func VerifyToken(token, data []byte, roots, intermediates []*x509.Certificate) (time.Time, error) {
+	info, sd, err := parseToken(token)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if err := checkImprint(info.MessageImprint, data); err != nil {
+		return time.Time{}, err
+	}
+
+	if len(sd.signerInfos) != 1 {
+		return time.Time{}, fmt.Errorf("timestamp token has %v signers, expected 1", len(sd.signerInfos))
+	}
+
+	si := sd.signerInfos[0]
+	cert, err := signerCertificate(si.sid, sd.certificates)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	if err := checkSignature(cert, si, sd); err != nil {
+		return time.Time{}, err
+	}
+
+	rootPool := x509.NewCertPool()
+	for _, root := range roots {
+		rootPool.AddCert(root)
+	}
+
+	intermediatePool := x509.NewCertPool()
+	for _, intermediate := range append(sd.certificates, intermediates...) {
+		intermediatePool.AddCert(intermediate)
+	}
+
+	if _, err := cert.Verify(x509.VerifyOptions{
+		Roots:         rootPool,
+		Intermediates: intermediatePool,
+		CurrentTime:   info.GenTime,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageTimeStamping},
+	}); err != nil {
+		return time.Time{}, fmt.Errorf("timestamp authority certificate is not trusted: %w", err)
+	}
+
+	return info.GenTime, nil
+}
+
+func newMessageImprint(hash crypto.Hash, data []byte) (messageImprint, error) {
+	oid, ok := hashOIDs[hash]
+	if !ok {
+		return messageImprint{}, fmt.Errorf("unsupported hash %v", hash)
+	}
+
+	h := hash.New()
+	h.Write(data)
+	return messageImprint{
+		HashAlgorithm: pkix.AlgorithmIdentifier{Algorithm: oid, Parameters: asn1.NullRawValue},
+		HashedMessage: h.Sum(nil),
+	}, nil
+}
+
+func checkImprint(imprint messageImprint, data []byte) error {
+	hash, ok := hashFromOID(imprint.HashAlgorithm.Algorithm)
+	if !ok {
+		return fmt.Errorf("timestamp uses unsupported hash %v", imprint.HashAlgorithm.Algorithm)
+	}
+
+	expected, err := newMessageImprint(hash, data)
+	if err != nil {
+		return err
+	}
+
+	if !bytes.Equal(expected.HashedMessage, imprint.HashedMessage) {
+		return fmt.Errorf("timestamp is not over the signature")
+	}
+
+	return nil
+}
+
+func hashFromOID(oid asn1.ObjectIdentifier) (crypto.Hash, bool) {
+	for hash, hashOID := range hashOIDs {
+		if oid.Equal(hashOID) {
+			return hash, true
+		}
+	}
+
+	return 0, false
+}
+
+// parseResponse returns the timestamp token of a TimeStampResp, if the request was granted.
+func parseResponse(data []byte) ([]byte, error) {
+	resp := timeStampResp{}
+	if rest, err := asn1.Unmarshal(data, &resp); err != nil {
+		return nil, fmt.Errorf("failed to parse timestamp response: %w", err)
+	} else if len(rest) > 0 {
+		return nil, fmt.Errorf("timestamp response has trailing data")
+	}
+
+	// 0 is granted and 1 is granted with modifications
+	if resp.Status.Status != 0 && resp.Status.Status != 1 {
+		return nil, fmt.Errorf("timestamp request was rejected with status %v: %v", resp.Status.Status, resp.Status.StatusString)
+	}
+
+	if len(resp.TimeStampToken.FullBytes) == 0 {
+		return nil, fmt.Errorf("timestamp response does not have a token")
+	}
+
+	return resp.TimeStampToken.FullBytes, nil
+}
+
+// parseToken parses a timestamp token, a CMS SignedData content info wrapping a TSTInfo.
+func parseToken(token []byte) (tstInfo, signedData, error) {
+	ci := contentInfo{}
+	if _, err := asn1.Unmarshal(token, &ci); err != nil {
+		return tstInfo{}, signedData{}, fmt.Errorf("failed to parse timestamp token: %w", err)
+	}
+
+	if !ci.ContentType.Equal(oidSignedData) || ci.Content.Class != asn1.ClassContextSpecific || ci.Content.Tag != 0 {
+		return tstInfo{}, signedData{}, fmt.Errorf("timestamp token is not signed data")
+	}
+
+	sd, err := parseSignedData(ci.Content.Bytes)
+	if err != nil {
+		return tstInfo{}, signedData{}, err
+	}
+
+	if !sd.eContentType.Equal(oidTSTInfo) {
+		return tstInfo{}, signedData{}, fmt.Errorf("timestamp token does not contain timestamp info")
+	}
+
+	info := tstInfo{}
+	if _, err := asn1.Unmarshal(sd.eContent, &info); err != nil {
+		return tstInfo{}, signedData{}, fmt.Errorf("failed to parse timestamp info: %w", err)
+	}
+
+	return info, sd, nil
+}
+
+// parseSignedData parses a SignedData, whose optional certificates and crls are told apart by their tags.
+func parseSignedData(data []byte) (signedData, error) {
+	elements, err := sequenceElements(data)
+	if err != nil || len(elements) < 4 {
+		return signedData{}, fmt.Errorf("failed to parse signed data")
+	}
+
+	sd := signedData{}
+	encap, err := sequenceElements(elements[2].FullBytes)
+	if err != nil || len(encap) != 2 || encap[1].Class != asn1.ClassContextSpecific || encap[1].Tag != 0 {
+		return signedData{}, fmt.Errorf("failed to parse signed data content")
+	}
+
+	if _, err := asn1.Unmarshal(encap[0].FullBytes, &sd.eContentType); err != nil {
+		return signedData{}, fmt.Errorf("failed to parse signed data content type: %w", err)
+	}
+
+	if _, err := asn1.Unmarshal(encap[1].Bytes, &sd.eContent); err != nil {
+		return signedData{}, fmt.Errorf("failed to parse signed data content: %w", err)
+	}
+
+	for _, element := range elements[3 : len(elements)-1] {
+		if element.Class == asn1.ClassContextSpecific && element.Tag == 0 {
+			if sd.certificates, err = x509.ParseCertificates(element.Bytes); err != nil {
+				return signedData{}, fmt.Errorf("failed to parse timestamp certificates: %w", err)
+			}
+		}
+	}
+
+	signerInfos, err := setElements(elements[len(elements)-1])
+	if err != nil {
+		return signedData{}, err
+	}
+
+	for _, raw := range signerInfos {
+		si, err := parseSignerInfo(raw.FullBytes)
+		if err != nil {
+			return signedData{}, err
+		}
+
+		sd.signerInfos = append(sd.signerInfos, si)
+	}
+
+	return sd, nil
+}
+
+func parseSignerInfo(data []byte) (signerInfo, error) {
+	elements, err := sequenceElements(data)
+	if err != nil || len(elements) < 5 {
+		return signerInfo{}, fmt.Errorf("failed to parse signer info")
+	}
+
+	si := signerInfo{sid: elements[1]}
+	if _, err := asn1.Unmarshal(elements[2].FullBytes, &si.digestAlgorithm); err != nil {
+		return signerInfo{}, fmt.Errorf("failed to parse signer digest algorithm: %w", err)
+	}
+
+	next := 3
+	if elements[next].Class == asn1.ClassContextSpecific && elements[next].Tag == 0 {
+		// the signed attributes are signed with their universal SET tag rather than the implicit tag
+		si.signedAttrs = append([]byte{0x31}, elements[next].FullBytes[1:]...)
+		next++
+	}
+
+	if len(elements) < next+2 {
+		return signerInfo{}, fmt.Errorf("failed to parse signer info")
+	}
+
+	if _, err := asn1.Unmarshal(elements[next].FullBytes, &si.signatureAlgorithm); err != nil {
+		return signerInfo{}, fmt.Errorf("failed to parse signature algorithm: %w", err)
+	}
+
+	if _, err := asn1.Unmarshal(elements[next+1].FullBytes, &si.signature); err != nil {
+		return signerInfo{}, fmt.Errorf("failed to parse signature: %w", err)
+	}
+
+	return si, nil
+}
+
+// signerCertificate returns the certificate identified by a signer info's issuer and serial number or subject key id.
+func signerCertificate(sid asn1.RawValue, certs []*x509.Certificate) (*x509.Certificate, error) {
+	if sid.Class == asn1.ClassContextSpecific && sid.Tag == 0 {
+		for _, cert := range certs {
+			if bytes.Equal(cert.SubjectKeyId, sid.Bytes) {
+				return cert, nil
+			}
+		}
+
+		return nil, fmt.Errorf("timestamp token does not include the signer's certificate")
+	}
+
+	ias := issuerAndSerialNumber{}
+	if _, err := asn1.Unmarshal(sid.FullBytes, &ias); err != nil {
+		return nil, fmt.Errorf("failed to parse timestamp signer: %w", err)
+	}
+
+	for _, cert := range certs {
+		if bytes.Equal(cert.RawIssuer, ias.Issuer.FullBytes) && cert.SerialNumber.Cmp(ias.SerialNumber) == 0 {
+			return cert, nil
+		}
+	}
+
+	return nil, fmt.Errorf("timestamp token does not include the signer's certificate")
+}
+
+// checkSignature verifies the signer's signature over its signed attributes, and that the attributes hold the digest
+// of the timestamp info.
+func checkSignature(cert *x509.Certificate, si signerInfo, sd signedData) error {
+	hash, ok := hashFromOID(si.digestAlgorithm.Algorithm)
+	if !ok {
+		return fmt.Errorf("timestamp signer uses unsupported hash %v", si.digestAlgorithm.Algorithm)
+	}
+
+	if len(si.signedAttrs) == 0 {
+		return fmt.Errorf("timestamp signer does not have signed attributes")
+	}
+
+	attributes := []attribute{}
+	if _, err := asn1.UnmarshalWithParams(si.signedAttrs, &attributes, "set"); err != nil {
+		return fmt.Errorf("failed to parse signed attributes: %w", err)
+	}
+
+	h := hash.New()
+	h.Write(sd.eContent)
+	digestFound := false
+	for _, attr := range attributes {
+		switch {
+		case attr.Type.Equal(oidMessageDigest):
+			digest := []byte{}
+			if _, err := asn1.Unmarshal(attr.Values.Bytes, &digest); err != nil || !bytes.Equal(digest, h.Sum(nil)) {
+				return fmt.Errorf("timestamp signature is not over the timestamp info")
+			}
+
+			digestFound = true
+		case attr.Type.Equal(oidContentType):
+			contentType := asn1.ObjectIdentifier{}
+			if _, err := asn1.Unmarshal(attr.Values.Bytes, &contentType); err != nil || !contentType.Equal(oidTSTInfo) {
+				return fmt.Errorf("timestamp signature has the wrong content type")
+			}
+		}
+	}
+
+	if !digestFound {
+		return fmt.Errorf("timestamp signer's attributes do not have a message digest")
+	}
+
+	algorithm, err := signatureAlgorithm(cert.PublicKey, hash)
+	if err != nil {
+		return err
+	}
+
+	if err := cert.CheckSignature(algorithm, si.signedAttrs, si.signature); err != nil {
+		return fmt.Errorf("failed to verify timestamp signature: %w", err)
+	}
+
+	return nil
+}
+
+func signatureAlgorithm(pub crypto.PublicKey, hash crypto.Hash) (x509.SignatureAlgorithm, error) {
+	algorithms := map[crypto.Hash][2]x509.SignatureAlgorithm{
+		crypto.SHA256: {x509.SHA256WithRSA, x509.ECDSAWithSHA256},
+		crypto.SHA384: {x509.SHA384WithRSA, x509.ECDSAWithSHA384},
+		crypto.SHA512: {x509.SHA512WithRSA, x509.ECDSAWithSHA512},
+	}
+
+	switch pub.(type) {
+	case *rsa.PublicKey:
+		return algorithms[hash][0], nil
+	case *ecdsa.PublicKey:
+		return algorithms[hash][1], nil
+	case ed25519.PublicKey:
+		return x509.PureEd25519, nil
+	default:
+		return x509.UnknownSignatureAlgorithm, fmt.Errorf("unsupported timestamp authority key type %T", pub)
+	}
+}
+
+func sequenceElements(data []byte) ([]asn1.RawValue, error) {
+	seq := asn1.RawValue{}
+	if _, err := asn1.Unmarshal(data, &seq); err != nil {
+		return nil, err
+	}
+
+	if seq.Class != asn1.ClassUniversal || seq.Tag != asn1.TagSequence {
+		return nil, fmt.Errorf("expected a sequence")
+	}
+
+	return elements(seq.Bytes)
+}
+
+func setElements(set asn1.RawValue) ([]asn1.RawValue, error) {
+	if set.Class != asn1.ClassUniversal || set.Tag != asn1.TagSet {
+		return nil, fmt.Errorf("expected a set")
+	}
+
+	return elements(set.Bytes)
+}
+
+func elements(data []byte) ([]asn1.RawValue, error) {
+	elements := []asn1.RawValue{}
+	for rest := data; len(rest) > 0; {
+		element := asn1.RawValue{}
+		var err error
+		if rest, err = asn1.Unmarshal(rest, &element); err != nil {
+			return nil, err
+		}
+
+		elements = append(elements, element)
+	}
+
+	return elements, nil
+}
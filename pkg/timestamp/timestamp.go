@@ -0,0 +1,149 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package timestamp requests RFC 3161 timestamps over DSSE signatures and verifies them. A timestamp from a trusted
+// authority proves a signature existed while its certificate was valid, so envelopes signed with short-lived
+// certificates can still be verified after the certificates expire.
+package timestamp
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/testifysec/go-witness/dsse"
+	"github.com/testifysec/go-witness/log"
+)
+
+// TypeRFC3161 is the type of a timestamp that holds a DER encoded RFC 3161 timestamp token.
+const TypeRFC3161 = "tsp"
+
+// Timestamp is a timestamp over a signature, stored in the signature's entry of the envelope.
+type Timestamp struct {
+	Type string `json:"type"`
+	Data []byte `json:"data"`
+}
+
+// Envelope is a DSSE envelope whose signatures may carry timestamps. It marshals to the same JSON as dsse.Envelope
+// with an added timestamps field in each signature, so tools that don't know about timestamps still read it.
+type Envelope struct {
+	Payload     []byte      `json:"payload"`
+	PayloadType string      `json:"payloadType"`
+	Signatures  []Signature `json:"signatures"`
+}
+
+// Signature is a DSSE signature along with the timestamps over it.
+type Signature struct {
+	KeyID         string      `json:"keyid"`
+	Signature     []byte      `json:"sig"`
+	Certificate   []byte      `json:"certificate,omitempty"`
+	Intermediates [][]byte    `json:"intermediates,omitempty"`
+	Timestamps    []Timestamp `json:"timestamps,omitempty"`
+}
+
+// Stamp requests a timestamp over each of the envelope's signatures from every timestamp authority in servers.
+func Stamp(ctx context.Context, env dsse.Envelope, servers []string, client *http.Client) (Envelope, error) {
+	stamped := Envelope{
+		Payload:     env.Payload,
+		PayloadType: env.PayloadType,
+	}
+
+	for _, sig := range env.Signatures {
+		stampedSig := Signature{
+			KeyID:         sig.KeyID,
+			Signature:     sig.Signature,
+			Certificate:   sig.Certificate,
+			Intermediates: sig.Intermediates,
+		}
+
+		for _, server := range servers {
+			token, err := Request(ctx, client, server, sig.Signature)
+			if err != nil {
+				return Envelope{}, err
+			}
+
+			stampedSig.Timestamps = append(stampedSig.Timestamps, Timestamp{Type: TypeRFC3161, Data: token})
+		}
+
+		stamped.Signatures = append(stamped.Signatures, stampedSig)
+	}
+
+	return stamped, nil
+}
+
+// Verifier verifies timestamps against the certificates of trusted timestamp authorities.
+type Verifier struct {
+	Roots         []*x509.Certificate
+	Intermediates []*x509.Certificate
+}
+
+// Apply reads the timestamps from the JSON encoding of env and returns env with each signature's certificate chain to
+// be verified at the earliest time a trusted authority timestamped the signature, rather than the current time.
+// Signatures without a valid timestamp are left unchanged. A nil Verifier returns env unchanged.
+func (v *Verifier) Apply(data []byte, env dsse.Envelope) dsse.Envelope {
+	if v == nil || len(v.Roots) == 0 {
+		return env
+	}
+
+	stamped := Envelope{}
+	if err := json.Unmarshal(data, &stamped); err != nil || len(stamped.Signatures) != len(env.Signatures) {
+		return env
+	}
+
+	signatures := make([]dsse.Signature, 0, len(env.Signatures))
+	for i, sig := range env.Signatures {
+		trustedTime, ok := v.earliest(sig.Signature, stamped.Signatures[i].Timestamps)
+		if !ok {
+			signatures = append(signatures, sig)
+			continue
+		}
+
+		log.Debugf("(timestamp) verifying signature from %v at %v", sig.KeyID, trustedTime)
+		signatures = append(signatures, dsse.NewSignature(
+			sig.KeyID,
+			sig.Signature,
+			dsse.SignatureWithCertificate(sig.Certificate),
+			dsse.SignatureWithIntermediates(sig.Intermediates),
+			dsse.SignatureWithTrustedTime(trustedTime),
+		))
+	}
+
+	env.Signatures = signatures
+	return env
+}
+
+// earliest returns the earliest time among the valid timestamps over sig.
+func (v *Verifier) earliest(sig []byte, timestamps []Timestamp) (time.Time, bool) {
+	var earliest time.Time
+	for _, ts := range timestamps {
+		if ts.Type != TypeRFC3161 {
+			log.Debugf("(timestamp) skipping timestamp of unknown type %v", ts.Type)
+			continue
+		}
+
+		genTime, err := VerifyToken(ts.Data, sig, v.Roots, v.Intermediates)
+		if err != nil {
+			log.Debugf("(timestamp) skipping invalid timestamp: %v", err)
+			continue
+		}
+
+		if earliest.IsZero() || genTime.Before(earliest) {
+			earliest = genTime
+		}
+	}
+
+	return earliest, !earliest.IsZero()
+}
@@ -0,0 +1,354 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package timestamp
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/json"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/dsse"
+)
+
+type testTSA struct {
+	root    *x509.Certificate
+	cert    *x509.Certificate
+	key     *ecdsa.PrivateKey
+	genTime time.Time
+	status  int
+}
+
+func newTestTSA(t *testing.T, genTime time.Time) *testTSA {
+	rootKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	root := createCert(t, &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "tsa root"},
+		NotBefore:             genTime.Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}, nil, &rootKey.PublicKey, rootKey)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert := createCert(t, &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "tsa"},
+		NotBefore:    genTime.Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageTimeStamping},
+	}, root, &key.PublicKey, rootKey)
+
+	return &testTSA{root: root, cert: cert, key: key, genTime: genTime.UTC().Truncate(time.Second)}
+}
+
+func createCert(t *testing.T, template, parent *x509.Certificate, pub interface{}, priv *ecdsa.PrivateKey) *x509.Certificate {
+	if parent == nil {
+		parent = template
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, parent, pub, priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return cert
+}
+
+func (tsa *testTSA) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	req := timeStampReq{}
+	if _, err := asn1.Unmarshal(body, &req); err != nil || r.Header.Get("Content-Type") != queryContentType {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	resp := timeStampResp{Status: pkiStatusInfo{Status: tsa.status}}
+	if tsa.status == 0 {
+		token, err := tsa.token(req.MessageImprint, req.Nonce)
+		if err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		resp.TimeStampToken = asn1.RawValue{FullBytes: token}
+	}
+
+	der, err := asn1.Marshal(resp)
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", replyContentType)
+	_, _ = w.Write(der)
+}
+
+// token builds a timestamp token the way RFC 3161 and RFC 5652 describe.
+func (tsa *testTSA) token(imprint messageImprint, nonce *big.Int) ([]byte, error) {
+	info, err := asn1.Marshal(tstInfo{
+		Version:        1,
+		Policy:         asn1.ObjectIdentifier{1, 2, 3, 4},
+		MessageImprint: imprint,
+		SerialNumber:   big.NewInt(42),
+		GenTime:        tsa.genTime,
+		Nonce:          nonce,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	infoDigest := sha256.Sum256(info)
+	digestValue, err := asn1.Marshal(infoDigest[:])
+	if err != nil {
+		return nil, err
+	}
+
+	contentTypeValue, err := asn1.Marshal(oidTSTInfo)
+	if err != nil {
+		return nil, err
+	}
+
+	attrs, err := asn1.MarshalWithParams([]attribute{
+		{Type: oidContentType, Values: set(contentTypeValue)},
+		{Type: oidMessageDigest, Values: set(digestValue)},
+	}, "set")
+	if err != nil {
+		return nil, err
+	}
+
+	attrsDigest := sha256.Sum256(attrs)
+	signature, err := ecdsa.SignASN1(rand.Reader, tsa.key, attrsDigest[:])
+	if err != nil {
+		return nil, err
+	}
+
+	digestAlgorithm := pkix.AlgorithmIdentifier{Algorithm: hashOIDs[crypto.SHA256], Parameters: asn1.NullRawValue}
+	signedAttrs := append([]byte{0xa0}, attrs[1:]...)
+	signerInfo, err := asn1.Marshal(struct {
+		Version            int
+		SID                issuerAndSerialNumber
+		DigestAlgorithm    pkix.AlgorithmIdentifier
+		SignedAttrs        asn1.RawValue
+		SignatureAlgorithm pkix.AlgorithmIdentifier
+		Signature          []byte
+	}{
+		Version:            1,
+		SID:                issuerAndSerialNumber{Issuer: asn1.RawValue{FullBytes: tsa.cert.RawIssuer}, SerialNumber: tsa.cert.SerialNumber},
+		DigestAlgorithm:    digestAlgorithm,
+		SignedAttrs:        asn1.RawValue{FullBytes: signedAttrs},
+		SignatureAlgorithm: pkix.AlgorithmIdentifier{Algorithm: asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}},
+		Signature:          signature,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	eContent, err := asn1.Marshal(info)
+	if err != nil {
+		return nil, err
+	}
+
+	sd, err := asn1.Marshal(struct {
+		Version          int
+		DigestAlgorithms []pkix.AlgorithmIdentifier `asn1:"set"`
+		EncapContentInfo struct {
+			EContentType asn1.ObjectIdentifier
+			EContent     asn1.RawValue
+		}
+		Certificates asn1.RawValue
+		SignerInfos  asn1.RawValue
+	}{
+		Version:          3,
+		DigestAlgorithms: []pkix.AlgorithmIdentifier{digestAlgorithm},
+		EncapContentInfo: struct {
+			EContentType asn1.ObjectIdentifier
+			EContent     asn1.RawValue
+		}{oidTSTInfo, asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: eContent}},
+		Certificates: asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: tsa.cert.Raw},
+		SignerInfos:  set(signerInfo),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return asn1.Marshal(contentInfo{
+		ContentType: oidSignedData,
+		Content:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: sd},
+	})
+}
+
+func set(der []byte) asn1.RawValue {
+	return asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSet, IsCompound: true, Bytes: der}
+}
+
+func TestRequestAndVerifyToken(t *testing.T) {
+	tsa := newTestTSA(t, time.Now().Add(-time.Minute))
+	server := httptest.NewServer(tsa)
+	defer server.Close()
+
+	data := []byte("signature")
+	token, err := Request(context.Background(), server.Client(), server.URL, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	genTime, err := VerifyToken(token, data, []*x509.Certificate{tsa.root}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !genTime.Equal(tsa.genTime) {
+		t.Errorf("expected time %v, got %v", tsa.genTime, genTime)
+	}
+
+	if _, err := VerifyToken(token, []byte("other signature"), []*x509.Certificate{tsa.root}, nil); err == nil {
+		t.Error("expected a token over other data to fail")
+	}
+
+	untrusted := newTestTSA(t, time.Now())
+	if _, err := VerifyToken(token, data, []*x509.Certificate{untrusted.root}, nil); err == nil {
+		t.Error("expected a token from an untrusted authority to fail")
+	}
+
+	tampered := bytes.Replace(token, []byte("tsa"), []byte("tsb"), 1)
+	if _, err := VerifyToken(tampered, data, []*x509.Certificate{tsa.root}, nil); err == nil {
+		t.Error("expected a tampered token to fail")
+	}
+}
+
+func TestRequestRejected(t *testing.T) {
+	tsa := newTestTSA(t, time.Now())
+	tsa.status = 2
+	server := httptest.NewServer(tsa)
+	defer server.Close()
+
+	_, err := Request(context.Background(), server.Client(), server.URL, []byte("signature"))
+	if err == nil || !strings.Contains(err.Error(), "rejected") {
+		t.Errorf("expected a rejected request, got %v", err)
+	}
+}
+
+func TestStampAndApply(t *testing.T) {
+	// the signing certificate expired an hour ago, but the signature was timestamped while it was valid
+	signedAt := time.Now().Add(-90 * time.Minute)
+	tsa := newTestTSA(t, signedAt)
+	server := httptest.NewServer(tsa)
+	defer server.Close()
+
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ca := createCert(t, &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "signing root"},
+		NotBefore:             signedAt.Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}, nil, &caKey.PublicKey, caKey)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	leaf := createCert(t, &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "signer"},
+		NotBefore:    signedAt.Add(-10 * time.Minute),
+		NotAfter:     time.Now().Add(-time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+	}, ca, &key.PublicKey, caKey)
+
+	signer, err := cryptoutil.NewSigner(key, cryptoutil.SignWithCertificate(leaf))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	env, err := dsse.Sign("text/plain", strings.NewReader("payload"), signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stamped, err := Stamp(context.Background(), env, []string{server.URL}, server.Client())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := json.Marshal(stamped)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed := dsse.Envelope{}
+	if err := json.Unmarshal(data, &parsed); err != nil {
+		t.Fatal(err)
+	}
+
+	roots := []*x509.Certificate{ca}
+	if _, err := parsed.Verify(dsse.WithRoots(roots)); err == nil {
+		t.Fatal("expected the expired certificate to fail without a timestamp")
+	}
+
+	var nilVerifier *Verifier
+	if _, err := nilVerifier.Apply(data, parsed).Verify(dsse.WithRoots(roots)); err == nil {
+		t.Error("expected a nil verifier to leave the envelope unchanged")
+	}
+
+	untrusted := &Verifier{Roots: []*x509.Certificate{newTestTSA(t, signedAt).root}}
+	if _, err := untrusted.Apply(data, parsed).Verify(dsse.WithRoots(roots)); err == nil {
+		t.Error("expected a timestamp from an untrusted authority to be ignored")
+	}
+
+	verifier := &Verifier{Roots: []*x509.Certificate{tsa.root}}
+	if _, err := verifier.Apply(data, parsed).Verify(dsse.WithRoots(roots)); err != nil {
+		t.Errorf("expected the timestamped signature to verify: %v", err)
+	}
+}
@@ -0,0 +1,258 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package tlog is an append-only Merkle log of attestations kept in a local directory. It gives teams that do not run
+// Rekor tamper evidence for their attestation stream: signed checkpoints commit to every entry in the log, inclusion
+// proofs show an attestation is in the log, and consistency proofs show the log was only appended to.
+package tlog
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/dsse"
+)
+
+const (
+	CheckpointPayloadType = "https://witness.dev/log-checkpoint/v0.1"
+
+	leavesFile     = "leaves"
+	entriesFile    = "entries.jsonl"
+	checkpointFile = "checkpoint.json"
+)
+
+// Entry records an attestation added to the log. Digest is the hex encoded sha256 digest of the attestation file.
+type Entry struct {
+	Index  uint64    `json:"index"`
+	Digest string    `json:"digest"`
+	Name   string    `json:"name"`
+	Time   time.Time `json:"time"`
+}
+
+// Checkpoint commits to the first Size entries of the log.
+type Checkpoint struct {
+	Size uint64    `json:"size"`
+	Root string    `json:"root"`
+	Time time.Time `json:"time"`
+}
+
+// Log is a log stored in a directory. The leaf hashes are stored in one file so the tree can be rebuilt without the
+// attestations, and the entries recording what each leaf is are stored in another.
+type Log struct {
+	dir     string
+	leaves  []Hash
+	entries []Entry
+}
+
+// Open reads the log in dir, creating the directory if it does not exist.
+func Open(dir string) (*Log, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create log directory: %w", err)
+	}
+
+	l := &Log{dir: dir}
+	data, err := os.ReadFile(filepath.Join(dir, leavesFile))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read log leaves: %w", err)
+	}
+
+	if len(data)%sha256.Size != 0 {
+		return nil, fmt.Errorf("log leaves file is truncated")
+	}
+
+	for i := 0; i < len(data); i += sha256.Size {
+		leaf := Hash{}
+		copy(leaf[:], data[i:])
+		l.leaves = append(l.leaves, leaf)
+	}
+
+	f, err := os.Open(filepath.Join(dir, entriesFile))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read log entries: %w", err)
+	} else if err == nil {
+		defer f.Close()
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			entry := Entry{}
+			if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+				return nil, fmt.Errorf("failed to parse log entry %d: %w", len(l.entries), err)
+			}
+
+			l.entries = append(l.entries, entry)
+		}
+
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("failed to read log entries: %w", err)
+		}
+	}
+
+	if len(l.entries) != len(l.leaves) {
+		return nil, fmt.Errorf("log has %d entries but %d leaves", len(l.entries), len(l.leaves))
+	}
+
+	return l, nil
+}
+
+func (l *Log) Size() uint64 {
+	return uint64(len(l.leaves))
+}
+
+func (l *Log) Root() Hash {
+	return RootHash(l.leaves)
+}
+
+// Leaves returns the leaf hashes of the log.
+func (l *Log) Leaves() []Hash {
+	return l.leaves
+}
+
+// Append adds an attestation to the log.
+func (l *Log) Append(name string, data []byte, now time.Time) (Entry, error) {
+	leaf := LeafHash(data)
+	digest := sha256.Sum256(data)
+	entry := Entry{Index: l.Size(), Digest: hex.EncodeToString(digest[:]), Name: name, Time: now.UTC()}
+	entryBytes, err := json.Marshal(entry)
+	if err != nil {
+		return Entry{}, err
+	}
+
+	if err := appendFile(filepath.Join(l.dir, leavesFile), leaf[:]); err != nil {
+		return Entry{}, fmt.Errorf("failed to append log leaf: %w", err)
+	}
+
+	if err := appendFile(filepath.Join(l.dir, entriesFile), append(entryBytes, '\n')); err != nil {
+		return Entry{}, fmt.Errorf("failed to append log entry: %w", err)
+	}
+
+	l.leaves = append(l.leaves, leaf)
+	l.entries = append(l.entries, entry)
+	return entry, nil
+}
+
+func appendFile(path string, data []byte) error {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// Find returns the first entry for the attestation with the digest.
+func (l *Log) Find(digest string) (Entry, bool) {
+	for _, entry := range l.entries {
+		if entry.Digest == digest {
+			return entry, true
+		}
+	}
+
+	return Entry{}, false
+}
+
+// Sign signs a checkpoint of the log's current state and stores it as the log's latest checkpoint.
+func (l *Log) Sign(signer cryptoutil.Signer, now time.Time) (dsse.Envelope, error) {
+	root := l.Root()
+	payload, err := json.Marshal(Checkpoint{Size: l.Size(), Root: hex.EncodeToString(root[:]), Time: now.UTC()})
+	if err != nil {
+		return dsse.Envelope{}, err
+	}
+
+	env, err := dsse.Sign(CheckpointPayloadType, bytes.NewReader(payload), signer)
+	if err != nil {
+		return dsse.Envelope{}, fmt.Errorf("failed to sign checkpoint: %w", err)
+	}
+
+	data, err := json.Marshal(env)
+	if err != nil {
+		return dsse.Envelope{}, err
+	}
+
+	if err := os.WriteFile(filepath.Join(l.dir, checkpointFile), data, 0644); err != nil {
+		return dsse.Envelope{}, fmt.Errorf("failed to write checkpoint: %w", err)
+	}
+
+	return env, nil
+}
+
+// LatestCheckpoint returns the last checkpoint signed with Sign.
+func (l *Log) LatestCheckpoint() (dsse.Envelope, error) {
+	data, err := os.ReadFile(filepath.Join(l.dir, checkpointFile))
+	if err != nil {
+		return dsse.Envelope{}, fmt.Errorf("failed to read checkpoint: %w", err)
+	}
+
+	env := dsse.Envelope{}
+	if err := json.Unmarshal(data, &env); err != nil {
+		return dsse.Envelope{}, fmt.Errorf("failed to parse checkpoint: %w", err)
+	}
+
+	return env, nil
+}
+
+// VerifyCheckpoint checks the checkpoint's signature and returns it.
+func VerifyCheckpoint(env dsse.Envelope, verifiers ...cryptoutil.Verifier) (Checkpoint, error) {
+	if _, err := env.Verify(dsse.WithVerifiers(verifiers)); err != nil {
+		return Checkpoint{}, fmt.Errorf("failed to verify checkpoint signature: %w", err)
+	}
+
+	return ParseCheckpoint(env)
+}
+
+// ParseCheckpoint returns the checkpoint in the envelope without checking its signature.
+func ParseCheckpoint(env dsse.Envelope) (Checkpoint, error) {
+	if env.PayloadType != CheckpointPayloadType {
+		return Checkpoint{}, fmt.Errorf("unexpected checkpoint payload type: %v", env.PayloadType)
+	}
+
+	checkpoint := Checkpoint{}
+	if err := json.Unmarshal(env.Payload, &checkpoint); err != nil {
+		return Checkpoint{}, fmt.Errorf("failed to unmarshal checkpoint: %w", err)
+	}
+
+	return checkpoint, nil
+}
+
+// Audit checks that the checkpoint commits to the log's entries, failing if any entry it covers was changed or
+// removed.
+func (l *Log) Audit(checkpoint Checkpoint) error {
+	if checkpoint.Size > l.Size() {
+		return fmt.Errorf("checkpoint covers %d entries but the log has %d", checkpoint.Size, l.Size())
+	}
+
+	root := RootHash(l.leaves[:checkpoint.Size])
+	if hex.EncodeToString(root[:]) != checkpoint.Root {
+		return fmt.Errorf("log does not match the checkpoint of size %d signed at %v", checkpoint.Size, checkpoint.Time)
+	}
+
+	return nil
+}
@@ -0,0 +1,259 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tlog
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/testifysec/go-witness/cryptoutil"
+)
+
+func newSigner(t *testing.T) cryptoutil.Signer {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return cryptoutil.NewECDSASigner(key, crypto.SHA256)
+}
+
+func appendN(t *testing.T, l *Log, from, to int) {
+	for i := from; i < to; i++ {
+		if _, err := l.Append(fmt.Sprintf("att-%d.json", i), []byte(fmt.Sprintf("attestation %d", i)), time.Now()); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestLog(t *testing.T) {
+	dir := t.TempDir()
+	signer := newSigner(t)
+	verifier := newVerifierFrom(t, signer)
+	l, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	appendN(t, l, 0, 3)
+	oldEnv, err := l.Sign(signer, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	old, err := VerifyCheckpoint(oldEnv, verifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	appendN(t, l, 3, 7)
+	if _, err := l.Sign(signer, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if reopened.Size() != 7 || reopened.Root() != l.Root() {
+		t.Fatalf("reopened log does not match: size %d", reopened.Size())
+	}
+
+	env, err := reopened.LatestCheckpoint()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkpoint, err := VerifyCheckpoint(env, verifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := []byte("attestation 1")
+	entry, ok := reopened.Find(fmt.Sprintf("%x", sha256.Sum256(data)))
+	if !ok || entry.Name != "att-1.json" {
+		t.Fatalf("failed to find entry: %+v", entry)
+	}
+
+	proof, err := reopened.Prove(entry, env, checkpoint, old.Size)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	proofBytes, err := json.Marshal(proof)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decoded := Proof{}
+	if err := json.Unmarshal(proofBytes, &decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	verified, err := decoded.Verify(data, verifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := decoded.VerifyConsistency(old, verified); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := decoded.Verify([]byte("attestation 2"), verifier); err == nil {
+		t.Error("expected proof for another attestation to fail")
+	}
+
+	if _, err := decoded.Verify(data, newVerifier(t)); err == nil {
+		t.Error("expected checkpoint signed by another key to fail")
+	}
+}
+
+func TestAuditDetectsRewrite(t *testing.T) {
+	dir := t.TempDir()
+	signer := newSigner(t)
+	l, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	appendN(t, l, 0, 4)
+	env, err := l.Sign(signer, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	checkpoint, err := VerifyCheckpoint(env, newVerifierFrom(t, signer))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := l.Audit(checkpoint); err != nil {
+		t.Fatal(err)
+	}
+
+	leaves, err := os.ReadFile(filepath.Join(dir, leavesFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rewritten := LeafHash([]byte("rewritten"))
+	copy(leaves, rewritten[:])
+	if err := os.WriteFile(filepath.Join(dir, leavesFile), leaves, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tampered, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := tampered.Audit(checkpoint); err == nil {
+		t.Error("expected rewritten log to fail audit")
+	}
+}
+
+func TestPublish(t *testing.T) {
+	var gotMethod, gotPath, gotAuth string
+	var gotBody []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotMethod, gotPath, gotAuth = r.Method, r.URL.Path, r.Header.Get("Authorization")
+		gotBody, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	checkpoint := []byte(`{"payloadType":"x"}`)
+	if err := (Publisher{Target: server.URL + "/bucket/checkpoint.json", Token: "secret"}).Publish(context.Background(), checkpoint); err != nil {
+		t.Fatal(err)
+	}
+
+	if gotMethod != http.MethodPut || gotPath != "/bucket/checkpoint.json" || gotAuth != "Bearer secret" || string(gotBody) != string(checkpoint) {
+		t.Errorf("unexpected request: %v %v %v %s", gotMethod, gotPath, gotAuth, gotBody)
+	}
+
+	if err := (Publisher{Target: "gist:abc", GitHubAPIURL: server.URL}).Publish(context.Background(), checkpoint); err != nil {
+		t.Fatal(err)
+	}
+
+	update := gistUpdateBody{}
+	if err := json.Unmarshal(gotBody, &update); err != nil {
+		t.Fatal(err)
+	}
+
+	if gotMethod != http.MethodPatch || gotPath != "/gists/abc" || update.Files[CheckpointFilename].Content != string(checkpoint) {
+		t.Errorf("unexpected gist request: %v %v %s", gotMethod, gotPath, gotBody)
+	}
+
+	if err := (Publisher{Target: "s3://bucket/key"}).Publish(context.Background(), checkpoint); err == nil {
+		t.Error("expected unsupported target to fail")
+	}
+}
+
+func TestPublishDue(t *testing.T) {
+	l, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	if due, err := l.PublishDue(time.Hour, now); err != nil || !due {
+		t.Fatalf("expected unpublished log to be due: %v %v", due, err)
+	}
+
+	if err := l.MarkPublished(now); err != nil {
+		t.Fatal(err)
+	}
+
+	if due, err := l.PublishDue(time.Hour, now.Add(30*time.Minute)); err != nil || due {
+		t.Errorf("expected publish within interval to not be due: %v %v", due, err)
+	}
+
+	if due, err := l.PublishDue(time.Hour, now.Add(2*time.Hour)); err != nil || !due {
+		t.Errorf("expected publish after interval to be due: %v %v", due, err)
+	}
+}
+
+type gistUpdateBody struct {
+	Files map[string]struct {
+		Content string `json:"content"`
+	} `json:"files"`
+}
+
+func newVerifier(t *testing.T) cryptoutil.Verifier {
+	return newVerifierFrom(t, newSigner(t))
+}
+
+func newVerifierFrom(t *testing.T, signer cryptoutil.Signer) cryptoutil.Verifier {
+	verifier, err := signer.Verifier()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return verifier
+}
@@ -0,0 +1,199 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tlog
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+)
+
+// Hash is a node of the Merkle tree.
+type Hash [sha256.Size]byte
+
+// LeafHash returns the hash of a leaf holding data, as defined by RFC 9162.
+func LeafHash(data []byte) Hash {
+	return sha256.Sum256(append([]byte{0}, data...))
+}
+
+func nodeHash(left, right Hash) Hash {
+	data := make([]byte, 0, 1+2*sha256.Size)
+	data = append(data, 1)
+	data = append(data, left[:]...)
+	data = append(data, right[:]...)
+	return sha256.Sum256(data)
+}
+
+// split returns the largest power of two smaller than n.
+func split(n uint64) uint64 {
+	k := uint64(1)
+	for k<<1 < n {
+		k <<= 1
+	}
+
+	return k
+}
+
+// RootHash returns the root of the tree with the leaves.
+func RootHash(leaves []Hash) Hash {
+	switch len(leaves) {
+	case 0:
+		return sha256.Sum256(nil)
+	case 1:
+		return leaves[0]
+	}
+
+	k := split(uint64(len(leaves)))
+	return nodeHash(RootHash(leaves[:k]), RootHash(leaves[k:]))
+}
+
+// InclusionProof returns the audit path of the leaf at index in the tree with the leaves.
+func InclusionProof(leaves []Hash, index uint64) ([]Hash, error) {
+	if index >= uint64(len(leaves)) {
+		return nil, fmt.Errorf("leaf %d is not in a tree of size %d", index, len(leaves))
+	}
+
+	return inclusionPath(leaves, index), nil
+}
+
+func inclusionPath(leaves []Hash, index uint64) []Hash {
+	if len(leaves) <= 1 {
+		return nil
+	}
+
+	k := split(uint64(len(leaves)))
+	if index < k {
+		return append(inclusionPath(leaves[:k], index), RootHash(leaves[k:]))
+	}
+
+	return append(inclusionPath(leaves[k:], index-k), RootHash(leaves[:k]))
+}
+
+// VerifyInclusion checks that the leaf is at index in the tree of size with the root.
+func VerifyInclusion(leaf Hash, index, size uint64, proof []Hash, root Hash) error {
+	if index >= size {
+		return fmt.Errorf("leaf %d is not in a tree of size %d", index, size)
+	}
+
+	fn, sn := index, size-1
+	r := leaf
+	for _, p := range proof {
+		if sn == 0 {
+			return fmt.Errorf("inclusion proof is too long")
+		}
+
+		if fn&1 == 1 || fn == sn {
+			r = nodeHash(p, r)
+			for fn&1 == 0 && fn != 0 {
+				fn >>= 1
+				sn >>= 1
+			}
+		} else {
+			r = nodeHash(r, p)
+		}
+
+		fn >>= 1
+		sn >>= 1
+	}
+
+	if sn != 0 || !bytes.Equal(r[:], root[:]) {
+		return fmt.Errorf("inclusion proof does not match the root")
+	}
+
+	return nil
+}
+
+// ConsistencyProof returns the proof that the tree with the first oldSize leaves is a prefix of the tree with all of
+// the leaves.
+func ConsistencyProof(leaves []Hash, oldSize uint64) ([]Hash, error) {
+	if oldSize == 0 || oldSize > uint64(len(leaves)) {
+		return nil, fmt.Errorf("can not prove consistency of a tree of size %d with a tree of size %d", oldSize, len(leaves))
+	}
+
+	return subproof(leaves, oldSize, true), nil
+}
+
+func subproof(leaves []Hash, m uint64, complete bool) []Hash {
+	n := uint64(len(leaves))
+	if m == n {
+		if complete {
+			return nil
+		}
+
+		return []Hash{RootHash(leaves)}
+	}
+
+	k := split(n)
+	if m <= k {
+		return append(subproof(leaves[:k], m, complete), RootHash(leaves[k:]))
+	}
+
+	return append(subproof(leaves[k:], m-k, false), RootHash(leaves[:k]))
+}
+
+// VerifyConsistency checks that the tree of oldSize with oldRoot is a prefix of the tree of newSize with newRoot.
+func VerifyConsistency(oldSize, newSize uint64, oldRoot, newRoot Hash, proof []Hash) error {
+	switch {
+	case oldSize == 0 || oldSize > newSize:
+		return fmt.Errorf("can not prove consistency of a tree of size %d with a tree of size %d", oldSize, newSize)
+	case oldSize == newSize:
+		if len(proof) != 0 || oldRoot != newRoot {
+			return fmt.Errorf("trees of the same size have different roots")
+		}
+
+		return nil
+	}
+
+	if oldSize&(oldSize-1) == 0 {
+		proof = append([]Hash{oldRoot}, proof...)
+	}
+
+	if len(proof) == 0 {
+		return fmt.Errorf("consistency proof is empty")
+	}
+
+	fn, sn := oldSize-1, newSize-1
+	for fn&1 == 1 {
+		fn >>= 1
+		sn >>= 1
+	}
+
+	fr, sr := proof[0], proof[0]
+	for _, c := range proof[1:] {
+		if sn == 0 {
+			return fmt.Errorf("consistency proof is too long")
+		}
+
+		if fn&1 == 1 || fn == sn {
+			fr = nodeHash(c, fr)
+			sr = nodeHash(c, sr)
+			for fn&1 == 0 && fn != 0 {
+				fn >>= 1
+				sn >>= 1
+			}
+		} else {
+			sr = nodeHash(sr, c)
+		}
+
+		fn >>= 1
+		sn >>= 1
+	}
+
+	if sn != 0 || fr != oldRoot || sr != newRoot {
+		return fmt.Errorf("consistency proof does not match the roots")
+	}
+
+	return nil
+}
@@ -0,0 +1,79 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tlog
+
+import (
+	"fmt"
+	"testing"
+)
+
+func testLeaves(n int) []Hash {
+	leaves := make([]Hash, 0, n)
+	for i := 0; i < n; i++ {
+		leaves = append(leaves, LeafHash([]byte(fmt.Sprintf("leaf %d", i))))
+	}
+
+	return leaves
+}
+
+func TestInclusion(t *testing.T) {
+	for size := 1; size <= 17; size++ {
+		leaves := testLeaves(size)
+		root := RootHash(leaves)
+		for index := 0; index < size; index++ {
+			proof, err := InclusionProof(leaves, uint64(index))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := VerifyInclusion(leaves[index], uint64(index), uint64(size), proof, root); err != nil {
+				t.Errorf("leaf %d of %d: %v", index, size, err)
+			}
+
+			other := (index + 1) % size
+			if other != index {
+				if err := VerifyInclusion(leaves[other], uint64(index), uint64(size), proof, root); err == nil {
+					t.Errorf("leaf %d of %d: expected proof for another leaf to fail", index, size)
+				}
+			}
+		}
+	}
+}
+
+func TestConsistency(t *testing.T) {
+	for newSize := 1; newSize <= 17; newSize++ {
+		leaves := testLeaves(newSize)
+		newRoot := RootHash(leaves)
+		for oldSize := 1; oldSize <= newSize; oldSize++ {
+			oldRoot := RootHash(leaves[:oldSize])
+			proof, err := ConsistencyProof(leaves, uint64(oldSize))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if err := VerifyConsistency(uint64(oldSize), uint64(newSize), oldRoot, newRoot, proof); err != nil {
+				t.Errorf("%d to %d: %v", oldSize, newSize, err)
+			}
+
+			if oldSize < newSize {
+				tampered := append([]Hash{}, leaves[:oldSize]...)
+				tampered[0] = LeafHash([]byte("rewritten"))
+				if err := VerifyConsistency(uint64(oldSize), uint64(newSize), RootHash(tampered), newRoot, proof); err == nil {
+					t.Errorf("%d to %d: expected rewritten history to fail", oldSize, newSize)
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,149 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tlog
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/dsse"
+)
+
+// Proof shows that an attestation is in the log as of a signed checkpoint. If OldSize is set it also shows that the
+// log at the checkpoint is an extension of the log at OldSize entries.
+type Proof struct {
+	Index       uint64        `json:"index"`
+	Digest      string        `json:"digest"`
+	Inclusion   []string      `json:"inclusion"`
+	Checkpoint  dsse.Envelope `json:"checkpoint"`
+	OldSize     uint64        `json:"oldSize,omitempty"`
+	Consistency []string      `json:"consistency,omitempty"`
+}
+
+// Prove returns a proof that the entry is included in the tree committed to by the signed checkpoint. If oldSize is
+// not 0 the proof includes a consistency proof from the tree of that size.
+func (l *Log) Prove(entry Entry, checkpointEnv dsse.Envelope, checkpoint Checkpoint, oldSize uint64) (Proof, error) {
+	if err := l.Audit(checkpoint); err != nil {
+		return Proof{}, err
+	}
+
+	leaves := l.leaves[:checkpoint.Size]
+	inclusion, err := InclusionProof(leaves, entry.Index)
+	if err != nil {
+		return Proof{}, err
+	}
+
+	proof := Proof{Index: entry.Index, Digest: entry.Digest, Inclusion: encodeHashes(inclusion), Checkpoint: checkpointEnv}
+	if oldSize > 0 {
+		consistency, err := ConsistencyProof(leaves, oldSize)
+		if err != nil {
+			return Proof{}, err
+		}
+
+		proof.OldSize = oldSize
+		proof.Consistency = encodeHashes(consistency)
+	}
+
+	return proof, nil
+}
+
+// Verify checks the checkpoint's signature and that the attestation in data is included in it.
+func (p Proof) Verify(data []byte, verifiers ...cryptoutil.Verifier) (Checkpoint, error) {
+	checkpoint, err := VerifyCheckpoint(p.Checkpoint, verifiers...)
+	if err != nil {
+		return Checkpoint{}, err
+	}
+
+	digest := sha256.Sum256(data)
+	if hex.EncodeToString(digest[:]) != p.Digest {
+		return Checkpoint{}, fmt.Errorf("attestation digest does not match the proof")
+	}
+
+	root, err := decodeHash(checkpoint.Root)
+	if err != nil {
+		return Checkpoint{}, err
+	}
+
+	inclusion, err := decodeHashes(p.Inclusion)
+	if err != nil {
+		return Checkpoint{}, err
+	}
+
+	if err := VerifyInclusion(LeafHash(data), p.Index, checkpoint.Size, inclusion, root); err != nil {
+		return Checkpoint{}, err
+	}
+
+	return checkpoint, nil
+}
+
+// VerifyConsistency checks that the proof's checkpoint extends the older checkpoint, which must already be verified.
+func (p Proof) VerifyConsistency(old, checkpoint Checkpoint) error {
+	if p.OldSize != old.Size {
+		return fmt.Errorf("proof is from a log of size %d, not the checkpoint of size %d", p.OldSize, old.Size)
+	}
+
+	oldRoot, err := decodeHash(old.Root)
+	if err != nil {
+		return err
+	}
+
+	newRoot, err := decodeHash(checkpoint.Root)
+	if err != nil {
+		return err
+	}
+
+	consistency, err := decodeHashes(p.Consistency)
+	if err != nil {
+		return err
+	}
+
+	return VerifyConsistency(old.Size, checkpoint.Size, oldRoot, newRoot, consistency)
+}
+
+func encodeHashes(hashes []Hash) []string {
+	encoded := make([]string, 0, len(hashes))
+	for _, h := range hashes {
+		encoded = append(encoded, hex.EncodeToString(h[:]))
+	}
+
+	return encoded
+}
+
+func decodeHash(encoded string) (Hash, error) {
+	h := Hash{}
+	decoded, err := hex.DecodeString(encoded)
+	if err != nil || len(decoded) != len(h) {
+		return h, fmt.Errorf("invalid hash %v", encoded)
+	}
+
+	copy(h[:], decoded)
+	return h, nil
+}
+
+func decodeHashes(encoded []string) ([]Hash, error) {
+	hashes := make([]Hash, 0, len(encoded))
+	for _, e := range encoded {
+		h, err := decodeHash(e)
+		if err != nil {
+			return nil, err
+		}
+
+		hashes = append(hashes, h)
+	}
+
+	return hashes, nil
+}
@@ -0,0 +1,119 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tlog
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/testifysec/witness/pkg/github"
+	"github.com/testifysec/witness/pkg/network"
+)
+
+const (
+	gistPrefix    = "gist:"
+	publishedFile = "published"
+
+	// CheckpointFilename is the name of the file checkpoints are published to in a gist.
+	CheckpointFilename = "witness-log-checkpoint.json"
+)
+
+// Publisher publishes checkpoints somewhere outside the log's directory, so a log that is rewritten after a checkpoint
+// is published can be detected by anyone holding the published checkpoint.
+type Publisher struct {
+	// Target is either gist:<id> to update a file in a GitHub gist, or an http(s) URL, such as a presigned S3 URL, that
+	// the checkpoint is PUT to.
+	Target string
+	// Token authorizes the request. It is sent as a bearer token.
+	Token string
+	// GitHubAPIURL overrides the GitHub API used for gist targets.
+	GitHubAPIURL string
+	HTTPClient   *http.Client
+}
+
+func (p Publisher) Publish(ctx context.Context, checkpoint []byte) error {
+	if err := network.Check("publishing a log checkpoint"); err != nil {
+		return err
+	}
+
+	if strings.HasPrefix(p.Target, gistPrefix) {
+		client := github.Client{APIURL: p.GitHubAPIURL, Token: p.Token, HTTPClient: p.HTTPClient}
+		return client.UpdateGistFile(ctx, strings.TrimPrefix(p.Target, gistPrefix), CheckpointFilename, string(checkpoint))
+	}
+
+	if !strings.HasPrefix(p.Target, "https://") && !strings.HasPrefix(p.Target, "http://") {
+		return fmt.Errorf("publish target must be gist:<id> or an http(s) url: %v", p.Target)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, p.Target, bytes.NewReader(checkpoint))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	if p.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+p.Token)
+	}
+
+	httpClient := p.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to publish checkpoint: %w", err)
+	}
+
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("failed to publish checkpoint: unexpected status %v", resp.Status)
+	}
+
+	return nil
+}
+
+// PublishDue returns true if no checkpoint of the log has been published within the interval.
+func (l *Log) PublishDue(interval time.Duration, now time.Time) (bool, error) {
+	data, err := os.ReadFile(filepath.Join(l.dir, publishedFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return true, nil
+	} else if err != nil {
+		return false, fmt.Errorf("failed to read last publish time: %w", err)
+	}
+
+	published, err := time.Parse(time.RFC3339, strings.TrimSpace(string(data)))
+	if err != nil {
+		return false, fmt.Errorf("failed to parse last publish time: %w", err)
+	}
+
+	return now.Sub(published) >= interval, nil
+}
+
+// MarkPublished records that a checkpoint of the log was published.
+func (l *Log) MarkPublished(now time.Time) error {
+	if err := os.WriteFile(filepath.Join(l.dir, publishedFile), []byte(now.UTC().Format(time.RFC3339)+"\n"), 0644); err != nil {
+		return fmt.Errorf("failed to record publish time: %w", err)
+	}
+
+	return nil
+}
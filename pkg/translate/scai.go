@@ -0,0 +1,97 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package translate
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"github.com/testifysec/go-witness/dsse"
+	"github.com/testifysec/go-witness/intoto"
+	"github.com/testifysec/witness/pkg/policy"
+)
+
+const (
+	SCAIFormat        = "scai"
+	SCAIPredicateType = "https://in-toto.io/attestation/scai/attribute-report/v0.2"
+)
+
+// ResourceDescriptor is an in-toto resource descriptor, used by SCAI to refer to targets, evidence, and producers.
+type ResourceDescriptor struct {
+	Name        string            `json:"name,omitempty"`
+	URI         string            `json:"uri,omitempty"`
+	Digest      map[string]string `json:"digest,omitempty"`
+	MediaType   string            `json:"mediaType,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// AttributeAssertion is a SCAI claim that the statement's subjects have an attribute, backed by evidence.
+type AttributeAssertion struct {
+	Attribute  string              `json:"attribute"`
+	Target     *ResourceDescriptor `json:"target,omitempty"`
+	Conditions json.RawMessage     `json:"conditions,omitempty"`
+	Evidence   *ResourceDescriptor `json:"evidence,omitempty"`
+}
+
+// AttributeReport is the predicate of a SCAI attribute report.
+type AttributeReport struct {
+	Attributes []AttributeAssertion `json:"attributes"`
+	Producer   *ResourceDescriptor  `json:"producer,omitempty"`
+}
+
+// SCAI translates a collection into a SCAI attribute report about the collection's subjects. Each attestation in the
+// collection becomes an attribute named by its attestation type, with the signed collection as its evidence. The
+// evidence's digest is of the envelope's payload, so the original collection can be found and verified by consumers
+// that trust witness signers.
+func SCAI(env dsse.Envelope, statement intoto.Statement, collection policy.Collection) (intoto.Statement, error) {
+	payloadDigest := sha256.Sum256(env.Payload)
+	evidence := &ResourceDescriptor{
+		Name:      collection.Name,
+		Digest:    map[string]string{"sha256": hex.EncodeToString(payloadDigest[:])},
+		MediaType: intoto.PayloadType,
+		Annotations: map[string]string{
+			"predicateType": statement.PredicateType,
+		},
+	}
+
+	report := AttributeReport{
+		Attributes: make([]AttributeAssertion, 0, len(collection.Attestations)),
+		Producer: &ResourceDescriptor{
+			Name:        collection.Name,
+			Annotations: map[string]string{"tool": "witness"},
+		},
+	}
+
+	for _, attestation := range collection.Attestations {
+		report.Attributes = append(report.Attributes, AttributeAssertion{
+			Attribute: attestation.Type,
+			Evidence:  evidence,
+		})
+	}
+
+	predicate, err := json.Marshal(report)
+	if err != nil {
+		return intoto.Statement{}, fmt.Errorf("failed to marshal attribute report: %w", err)
+	}
+
+	return intoto.Statement{
+		Type:          intoto.StatementType,
+		Subject:       statement.Subject,
+		PredicateType: SCAIPredicateType,
+		Predicate:     predicate,
+	}, nil
+}
@@ -0,0 +1,70 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package translate converts signed attestation collections into other supply chain metadata formats, so evidence
+// recorded once by witness can be given to consumers that don't read witness collections.
+package translate
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/testifysec/go-witness/dsse"
+	"github.com/testifysec/go-witness/intoto"
+	"github.com/testifysec/witness/pkg/policy"
+)
+
+// Translator converts the attestation collection signed in an envelope into an in-toto statement of another format.
+type Translator func(env dsse.Envelope, statement intoto.Statement, collection policy.Collection) (intoto.Statement, error)
+
+var translators = map[string]Translator{
+	SCAIFormat: SCAI,
+}
+
+// Formats returns the names of the formats collections can be translated to, sorted.
+func Formats() []string {
+	formats := make([]string, 0, len(translators))
+	for format := range translators {
+		formats = append(formats, format)
+	}
+
+	sort.Strings(formats)
+	return formats
+}
+
+// Translate converts the attestation collection signed in the envelope into the format. The envelope's signatures are
+// not checked.
+func Translate(format string, env dsse.Envelope) (intoto.Statement, error) {
+	translator, ok := translators[format]
+	if !ok {
+		return intoto.Statement{}, fmt.Errorf("unknown format %v, expected one of %v", format, Formats())
+	}
+
+	if env.PayloadType != intoto.PayloadType {
+		return intoto.Statement{}, fmt.Errorf("unexpected payload type %v", env.PayloadType)
+	}
+
+	statement := intoto.Statement{}
+	if err := json.Unmarshal(env.Payload, &statement); err != nil {
+		return intoto.Statement{}, fmt.Errorf("failed to unmarshal statement: %w", err)
+	}
+
+	collection, err := policy.CollectionFromEnvelope(env)
+	if err != nil {
+		return intoto.Statement{}, err
+	}
+
+	return translator(env, statement, collection)
+}
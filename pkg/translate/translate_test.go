@@ -0,0 +1,84 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package translate
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+
+	"github.com/testifysec/go-witness/dsse"
+	"github.com/testifysec/go-witness/intoto"
+	"github.com/testifysec/witness/pkg/policy"
+)
+
+func TestTranslateSCAI(t *testing.T) {
+	payload := []byte(`{
+		"_type": "https://in-toto.io/Statement/v0.1",
+		"subject": [{"name": "file:hello", "digest": {"sha256": "abcd"}}],
+		"predicateType": "` + policy.CollectionType + `",
+		"predicate": {
+			"name": "build",
+			"attestations": [
+				{"type": "https://witness.dev/attestations/material/v0.1", "attestation": {}},
+				{"type": "https://witness.dev/attestations/command-run/v0.1", "attestation": {"cmd": ["make"], "exitcode": 0}}
+			]
+		}
+	}`)
+
+	statement, err := Translate(SCAIFormat, dsse.Envelope{PayloadType: intoto.PayloadType, Payload: payload})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if statement.PredicateType != SCAIPredicateType || len(statement.Subject) != 1 || statement.Subject[0].Name != "file:hello" {
+		t.Fatalf("unexpected statement: %+v", statement)
+	}
+
+	report := AttributeReport{}
+	if err := json.Unmarshal(statement.Predicate, &report); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(report.Attributes) != 2 || report.Attributes[1].Attribute != "https://witness.dev/attestations/command-run/v0.1" {
+		t.Fatalf("unexpected attributes: %+v", report.Attributes)
+	}
+
+	digest := sha256.Sum256(payload)
+	evidence := report.Attributes[0].Evidence
+	if evidence == nil || evidence.Name != "build" || evidence.Digest["sha256"] != hex.EncodeToString(digest[:]) {
+		t.Fatalf("unexpected evidence: %+v", evidence)
+	}
+
+	if report.Producer == nil || report.Producer.Name != "build" {
+		t.Fatalf("unexpected producer: %+v", report.Producer)
+	}
+}
+
+func TestTranslateErrors(t *testing.T) {
+	if _, err := Translate("spdx", dsse.Envelope{PayloadType: intoto.PayloadType}); err == nil {
+		t.Fatal("expected unknown format to fail")
+	}
+
+	if _, err := Translate(SCAIFormat, dsse.Envelope{PayloadType: "text/plain", Payload: []byte(`{}`)}); err == nil {
+		t.Fatal("expected unexpected payload type to fail")
+	}
+
+	notCollection := []byte(`{"_type": "https://in-toto.io/Statement/v0.1", "predicateType": "https://slsa.dev/provenance/v0.2", "predicate": {}}`)
+	if _, err := Translate(SCAIFormat, dsse.Envelope{PayloadType: intoto.PayloadType, Payload: notCollection}); err == nil {
+		t.Fatal("expected statement without a collection to fail")
+	}
+}
@@ -0,0 +1,238 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transport
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/testifysec/go-witness/log"
+)
+
+const (
+	// failoverURL is the URL requests are made to through a Failover. The request is sent to each backend's own URL.
+	failoverURL         = "http://failover/"
+	healthCheckInterval = 30 * time.Second
+	// cooldown is how long a failed backend is only used as a last resort, unless a health check passes first.
+	cooldown = 30 * time.Second
+)
+
+type backend struct {
+	address   string
+	transport http.RoundTripper
+	url       *url.URL
+	failedAt  time.Time
+}
+
+// Failover is a round tripper that spreads requests across several backends, such as the instances of a collector,
+// in turn. A request that fails to connect, or gets a 5xx response, is retried on the next backend and the failed
+// backend is only used as a last resort until it passes a health check or its cooldown ends. Requests are sent to
+// each backend's own URL, with the path and query of the request's URL appended, so a request to the failover URL
+// goes to the backend's URL and a request to the failover URL's upload path goes to the backend's upload path.
+type Failover struct {
+	mu       sync.Mutex
+	backends []*backend
+	next     int
+	now      func() time.Time
+}
+
+// NewFailover returns a Failover across the addresses, each of which may be any address HTTPClient accepts other than
+// a list. The options apply to every backend.
+func NewFailover(addresses []string, opts ...Option) (*Failover, error) {
+	f := &Failover{now: time.Now}
+	for _, address := range addresses {
+		address = strings.TrimSpace(address)
+		if address == "" {
+			continue
+		}
+
+		if strings.Contains(address, ",") {
+			return nil, fmt.Errorf("invalid address %v", address)
+		}
+
+		client, rawURL, err := HTTPClient(context.Background(), address, opts...)
+		if err != nil {
+			return nil, err
+		}
+
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid address %v: %w", address, err)
+		}
+
+		f.backends = append(f.backends, &backend{address: address, transport: client.Transport, url: u})
+	}
+
+	if len(f.backends) == 0 {
+		return nil, fmt.Errorf("no addresses provided")
+	}
+
+	return f, nil
+}
+
+// resolve returns the backend's URL for a request made to u.
+func (b *backend) resolve(u *url.URL) *url.URL {
+	resolved := *b.url
+	if u.Path != "" && u.Path != "/" {
+		resolved.Path = strings.TrimSuffix(resolved.Path, "/") + u.Path
+		resolved.RawPath = ""
+	}
+
+	if u.RawQuery != "" {
+		if resolved.RawQuery != "" {
+			resolved.RawQuery += "&"
+		}
+
+		resolved.RawQuery += u.RawQuery
+	}
+
+	return &resolved
+}
+
+// order returns the backends to try for a request. Healthy backends come first, starting with the next in turn,
+// followed by backends that recently failed.
+func (f *Failover) order() []*backend {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	healthy := make([]*backend, 0, len(f.backends))
+	failed := make([]*backend, 0)
+	now := f.now()
+	for i := range f.backends {
+		b := f.backends[(f.next+i)%len(f.backends)]
+		if !b.failedAt.IsZero() && now.Sub(b.failedAt) < cooldown {
+			failed = append(failed, b)
+		} else {
+			healthy = append(healthy, b)
+		}
+	}
+
+	f.next = (f.next + 1) % len(f.backends)
+	return append(healthy, failed...)
+}
+
+func (f *Failover) markFailed(b *backend) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if b.failedAt.IsZero() {
+		log.Warnf("%v is unavailable, failing over", b.address)
+	}
+
+	b.failedAt = f.now()
+}
+
+func (f *Failover) markHealthy(b *backend) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !b.failedAt.IsZero() {
+		log.Infof("%v is available again", b.address)
+	}
+
+	b.failedAt = time.Time{}
+}
+
+func (f *Failover) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read request body: %w", err)
+		}
+	}
+
+	backends := f.order()
+	var lastErr error
+	for i, b := range backends {
+		backendReq := req.Clone(req.Context())
+		backendReq.URL = b.resolve(req.URL)
+		backendReq.Host = ""
+		if body != nil {
+			backendReq.Body = io.NopCloser(bytes.NewReader(body))
+			backendReq.ContentLength = int64(len(body))
+		}
+
+		resp, err := b.transport.RoundTrip(backendReq)
+		if err == nil && resp.StatusCode < 500 {
+			f.markHealthy(b)
+			return resp, nil
+		}
+
+		f.markFailed(b)
+		if err == nil {
+			if i == len(backends)-1 {
+				return resp, nil
+			}
+
+			resp.Body.Close()
+			err = fmt.Errorf("unexpected status %v", resp.Status)
+		}
+
+		lastErr = fmt.Errorf("%v: %w", b.address, err)
+	}
+
+	return nil, lastErr
+}
+
+// CheckHealth sends a GET request to each backend that has failed and marks it healthy again if it responds without
+// a 5xx status.
+func (f *Failover) CheckHealth(ctx context.Context) {
+	f.mu.Lock()
+	failed := make([]*backend, 0)
+	for _, b := range f.backends {
+		if !b.failedAt.IsZero() {
+			failed = append(failed, b)
+		}
+	}
+	f.mu.Unlock()
+
+	for _, b := range failed {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, b.url.String(), nil)
+		if err != nil {
+			continue
+		}
+
+		resp, err := b.transport.RoundTrip(req)
+		if err != nil {
+			continue
+		}
+
+		resp.Body.Close()
+		if resp.StatusCode < 500 {
+			f.markHealthy(b)
+		}
+	}
+}
+
+// Run checks the health of failed backends every interval until the context is cancelled.
+func (f *Failover) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			f.CheckHealth(ctx)
+		}
+	}
+}
@@ -0,0 +1,110 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package transport creates HTTP clients for the addresses witness sends data to.
+package transport
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/testifysec/witness/pkg/network"
+)
+
+const (
+	unixScheme     = "unix://"
+	abstractPrefix = "unix:@"
+	// socketBaseURL is the URL requests are made to over a unix socket. The host is ignored by the dialer.
+	socketBaseURL = "http://unix"
+)
+
+type config struct {
+	dialTimeout time.Duration
+	tlsConfig   *tls.Config
+}
+
+// Option customizes the clients HTTPClient returns.
+type Option func(*config)
+
+// WithDialTimeout limits how long connecting, including the TLS handshake, may take.
+func WithDialTimeout(timeout time.Duration) Option {
+	return func(c *config) {
+		c.dialTimeout = timeout
+	}
+}
+
+// WithTLSConfig sets the TLS config of connections to https addresses, such as one that authenticates with mTLS.
+func WithTLSConfig(tlsConfig *tls.Config) Option {
+	return func(c *config) {
+		c.tlsConfig = tlsConfig
+	}
+}
+
+// HTTPClient returns a client and the URL to send requests to for address. Addresses of the form
+// unix:///path/to/socket connect to a unix domain socket, and unix:@name connects to the abstract socket name on
+// Linux. Requests over a socket are plain HTTP and are sent to the root path. A comma separated list of addresses
+// returns a client that balances requests across them and fails over between them, see Failover. Its failed backends
+// are health checked until ctx is cancelled. Any other address is returned unchanged with a client that uses the
+// proxy and refuses to connect in offline mode, see network.NewTransport.
+func HTTPClient(ctx context.Context, address string, opts ...Option) (*http.Client, string, error) {
+	if strings.Contains(address, ",") {
+		failover, err := NewFailover(strings.Split(address, ","), opts...)
+		if err != nil {
+			return nil, "", err
+		}
+
+		go failover.Run(ctx, healthCheckInterval)
+		return &http.Client{Transport: failover}, failoverURL, nil
+	}
+
+	c := config{}
+	for _, opt := range opts {
+		opt(&c)
+	}
+
+	var socket string
+	switch {
+	case strings.HasPrefix(address, abstractPrefix):
+		// abstract sockets are named by a leading null byte, which go represents with @
+		socket = "@" + strings.TrimPrefix(address, abstractPrefix)
+
+	case strings.HasPrefix(address, unixScheme):
+		socket = strings.TrimPrefix(address, unixScheme)
+		if !strings.HasPrefix(socket, "/") {
+			return nil, "", fmt.Errorf("unix socket path must be absolute: %v", address)
+		}
+
+	default:
+		transport := network.NewTransport(c.dialTimeout)
+		transport.TLSClientConfig = c.tlsConfig
+		return &http.Client{Transport: transport}, address, nil
+	}
+
+	// sockets are local, so they are not affected by the proxy or offline mode
+	dialer := net.Dialer{Timeout: c.dialTimeout}
+	client := &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return dialer.DialContext(ctx, "unix", socket)
+			},
+		},
+	}
+
+	return client, socketBaseURL + "/", nil
+}
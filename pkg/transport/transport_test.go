@@ -0,0 +1,169 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transport
+
+import (
+	"context"
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestHTTPClientUnixSocket(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "collector.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(r.Method + " " + r.URL.Path))
+	})}
+
+	go func() { _ = server.Serve(listener) }()
+	defer server.Close()
+
+	client, url, err := HTTPClient(context.Background(), "unix://"+socket)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	resp, err := client.Post(url, "application/json", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(body) != "POST /" {
+		t.Errorf("unexpected response: %v", string(body))
+	}
+}
+
+func TestHTTPClientAddresses(t *testing.T) {
+	tlsConfig := &tls.Config{ServerName: "collector.example.com"}
+	client, url, err := HTTPClient(context.Background(), "https://collector.example.com/results", WithTLSConfig(tlsConfig))
+	if err != nil || url != "https://collector.example.com/results" {
+		t.Errorf("expected http address to be unchanged: %v %v", url, err)
+	}
+
+	if transport, ok := client.Transport.(*http.Transport); !ok || transport.TLSClientConfig != tlsConfig {
+		t.Errorf("expected the client to use the tls config")
+	}
+
+	if _, _, err := HTTPClient(context.Background(), "unix://relative.sock"); err == nil {
+		t.Error("expected error for relative socket path")
+	}
+
+	if _, url, err := HTTPClient(context.Background(), "unix:@collector"); err != nil || url != socketBaseURL+"/" {
+		t.Errorf("unexpected result for abstract socket: %v %v", url, err)
+	}
+}
+
+func TestFailover(t *testing.T) {
+	var mu sync.Mutex
+	hits := map[string]int{}
+	down := true
+	handler := func(name string) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			mu.Lock()
+			defer mu.Unlock()
+			if name == "a" && down {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+
+			if r.Method == http.MethodPost {
+				hits[name]++
+				body, _ := io.ReadAll(r.Body)
+				_, _ = w.Write(body)
+			}
+		})
+	}
+
+	a := httptest.NewServer(handler("a"))
+	defer a.Close()
+	b := httptest.NewServer(handler("b"))
+	defer b.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	client, url, err := HTTPClient(ctx, a.URL+","+b.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	failover := client.Transport.(*Failover)
+	for i := 0; i < 4; i++ {
+		resp, err := client.Post(url, "text/plain", strings.NewReader("result"))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK || string(body) != "result" {
+			t.Fatalf("unexpected response: %v %q", resp.Status, body)
+		}
+	}
+
+	if hits["a"] != 0 || hits["b"] != 4 {
+		t.Fatalf("expected every request to fail over to b: %v", hits)
+	}
+
+	mu.Lock()
+	down = false
+	mu.Unlock()
+	failover.CheckHealth(context.Background())
+	for i := 0; i < 4; i++ {
+		resp, err := client.Post(url, "text/plain", strings.NewReader("result"))
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		resp.Body.Close()
+	}
+
+	if hits["a"] != 2 || hits["b"] != 6 {
+		t.Fatalf("expected requests to be balanced once a recovered: %v", hits)
+	}
+}
+
+func TestFailoverAllDown(t *testing.T) {
+	failover, err := NewFailover([]string{"http://127.0.0.1:1", "http://127.0.0.1:2"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client := &http.Client{Transport: failover}
+	if _, err := client.Post(failoverURL, "text/plain", strings.NewReader("result")); err == nil {
+		t.Fatal("expected an error when every backend is down")
+	}
+
+	if _, err := NewFailover([]string{" ", ""}); err == nil {
+		t.Fatal("expected an error without addresses")
+	}
+}
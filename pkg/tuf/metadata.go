@@ -0,0 +1,291 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tuf
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/witness/pkg/sslib"
+)
+
+// ErrExpired is returned for metadata that expired before the time it was checked at.
+type ErrExpired struct {
+	Role    string
+	Expires time.Time
+}
+
+func (e ErrExpired) Error() string {
+	return fmt.Sprintf("%v metadata expired at %v", e.Role, e.Expires.Format(time.RFC3339))
+}
+
+// ErrRollback is returned for metadata older than the metadata already trusted.
+type ErrRollback struct {
+	Role    string
+	Trusted int64
+	Version int64
+}
+
+func (e ErrRollback) Error() string {
+	return fmt.Sprintf("%v metadata version %d is older than the trusted version %d", e.Role, e.Version, e.Trusted)
+}
+
+type signed struct {
+	Signed     json.RawMessage `json:"signed"`
+	Signatures []signature     `json:"signatures"`
+}
+
+type signature struct {
+	KeyID string `json:"keyid"`
+	Sig   string `json:"sig"`
+}
+
+type common struct {
+	Type    string    `json:"_type"`
+	Version int64     `json:"version"`
+	Expires time.Time `json:"expires"`
+}
+
+type role struct {
+	KeyIDs    []string `json:"keyids"`
+	Threshold int      `json:"threshold"`
+}
+
+type root struct {
+	common
+	ConsistentSnapshot bool                 `json:"consistent_snapshot"`
+	Keys               map[string]sslib.Key `json:"keys"`
+	Roles              map[string]role      `json:"roles"`
+}
+
+type metaFile struct {
+	Version int64             `json:"version"`
+	Length  int64             `json:"length,omitempty"`
+	Hashes  map[string]string `json:"hashes,omitempty"`
+}
+
+type timestamp struct {
+	common
+	Meta map[string]metaFile `json:"meta"`
+}
+
+type snapshot struct {
+	common
+	Meta map[string]metaFile `json:"meta"`
+}
+
+type targetFile struct {
+	Length int64             `json:"length"`
+	Hashes map[string]string `json:"hashes"`
+	Custom json.RawMessage   `json:"custom,omitempty"`
+}
+
+type targets struct {
+	common
+	Targets map[string]targetFile `json:"targets"`
+}
+
+// verify checks that the metadata is signed by the threshold of keys the root requires for the role, then decodes
+// what was signed into v. Signatures are made over the canonical json of the signed metadata, and a key listed under
+// several keyids only counts once.
+func (r root) verify(data []byte, roleName string, v interface{}) error {
+	meta := signed{}
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return fmt.Errorf("failed to unmarshal %v metadata: %w", roleName, err)
+	}
+
+	role, ok := r.Roles[roleName]
+	if !ok || role.Threshold < 1 {
+		return fmt.Errorf("root metadata does not define a threshold for the %v role", roleName)
+	}
+
+	canonical, err := sslib.CanonicalJSON(meta.Signed)
+	if err != nil {
+		return fmt.Errorf("failed to encode %v metadata as canonical json: %w", roleName, err)
+	}
+
+	allowed := make(map[string]bool, len(role.KeyIDs))
+	for _, keyID := range role.KeyIDs {
+		allowed[keyID] = true
+	}
+
+	verified := make(map[string]bool)
+	for _, sig := range meta.Signatures {
+		key, ok := r.Keys[sig.KeyID]
+		if !allowed[sig.KeyID] || !ok || verified[key.KeyVal["public"]] {
+			continue
+		}
+
+		if verifySignature(key, canonical, sig.Sig) == nil {
+			verified[key.KeyVal["public"]] = true
+		}
+	}
+
+	if len(verified) < role.Threshold {
+		return fmt.Errorf("%v metadata has %d of the %d valid signatures required", roleName, len(verified), role.Threshold)
+	}
+
+	header := common{}
+	if err := json.Unmarshal(meta.Signed, &header); err != nil {
+		return fmt.Errorf("failed to unmarshal %v metadata: %w", roleName, err)
+	}
+
+	if header.Type != roleName {
+		return fmt.Errorf("expected %v metadata, got %v", roleName, header.Type)
+	}
+
+	if err := json.Unmarshal(meta.Signed, v); err != nil {
+		return fmt.Errorf("failed to unmarshal %v metadata: %w", roleName, err)
+	}
+
+	return nil
+}
+
+func verifySignature(key sslib.Key, canonical []byte, sig string) error {
+	pub, err := key.PublicKey()
+	if err != nil {
+		return err
+	}
+
+	verifier, err := cryptoutil.NewVerifier(pub)
+	if err != nil {
+		return err
+	}
+
+	decoded, err := hex.DecodeString(sig)
+	if err != nil {
+		return fmt.Errorf("failed to decode signature: %w", err)
+	}
+
+	return verifier.Verify(bytes.NewReader(canonical), decoded)
+}
+
+// checkExpiry returns an error if the metadata expired before now.
+func (c common) checkExpiry(roleName string, now time.Time) error {
+	if !now.Before(c.Expires) {
+		return ErrExpired{Role: roleName, Expires: c.Expires}
+	}
+
+	return nil
+}
+
+// timestamp verifies the timestamp metadata.
+func (r root) timestamp(data []byte, now time.Time) (timestamp, error) {
+	ts := timestamp{}
+	if err := r.verify(data, "timestamp", &ts); err != nil {
+		return timestamp{}, err
+	}
+
+	if _, ok := ts.Meta["snapshot.json"]; !ok {
+		return timestamp{}, fmt.Errorf("timestamp metadata does not list snapshot.json")
+	}
+
+	return ts, ts.checkExpiry("timestamp", now)
+}
+
+// snapshot verifies the snapshot metadata is the one the timestamp lists.
+func (r root) snapshot(data []byte, ts timestamp, now time.Time) (snapshot, error) {
+	expected := ts.Meta["snapshot.json"]
+	if _, err := checkFile(data, expected.Length, expected.Hashes); err != nil {
+		return snapshot{}, fmt.Errorf("snapshot metadata does not match the timestamp: %w", err)
+	}
+
+	sn := snapshot{}
+	if err := r.verify(data, "snapshot", &sn); err != nil {
+		return snapshot{}, err
+	}
+
+	if sn.Version != expected.Version {
+		return snapshot{}, fmt.Errorf("snapshot metadata is version %d, the timestamp lists version %d", sn.Version, expected.Version)
+	}
+
+	if _, ok := sn.Meta["targets.json"]; !ok {
+		return snapshot{}, fmt.Errorf("snapshot metadata does not list targets.json")
+	}
+
+	return sn, sn.checkExpiry("snapshot", now)
+}
+
+// targets verifies the targets metadata is the one the snapshot lists.
+func (r root) targets(data []byte, sn snapshot, now time.Time) (targets, error) {
+	expected := sn.Meta["targets.json"]
+	if _, err := checkFile(data, expected.Length, expected.Hashes); err != nil {
+		return targets{}, fmt.Errorf("targets metadata does not match the snapshot: %w", err)
+	}
+
+	tg := targets{}
+	if err := r.verify(data, "targets", &tg); err != nil {
+		return targets{}, err
+	}
+
+	if tg.Version != expected.Version {
+		return targets{}, fmt.Errorf("targets metadata is version %d, the snapshot lists version %d", tg.Version, expected.Version)
+	}
+
+	return tg, tg.checkExpiry("targets", now)
+}
+
+// checkFile checks the data against a length and hex encoded digests, where they are given. It returns the number of
+// digests checked, since digests of unsupported algorithms are skipped.
+func checkFile(data []byte, length int64, hashes map[string]string) (int, error) {
+	if length > 0 && int64(len(data)) != length {
+		return 0, fmt.Errorf("length is %d, expected %d", len(data), length)
+	}
+
+	checked := 0
+	for algorithm, expected := range hashes {
+		var actual string
+		switch algorithm {
+		case "sha256":
+			digest := sha256.Sum256(data)
+			actual = hex.EncodeToString(digest[:])
+		case "sha512":
+			digest := sha512.Sum512(data)
+			actual = hex.EncodeToString(digest[:])
+		default:
+			continue
+		}
+
+		if actual != expected {
+			return 0, fmt.Errorf("%v digest is %v, expected %v", algorithm, actual, expected)
+		}
+
+		checked++
+	}
+
+	return checked, nil
+}
+
+// keysChanged returns true if the two roots trust different keys, or a different threshold, for the role.
+func keysChanged(prev, next root, roleName string) bool {
+	prevRole, nextRole := prev.Roles[roleName], next.Roles[roleName]
+	if prevRole.Threshold != nextRole.Threshold || len(prevRole.KeyIDs) != len(nextRole.KeyIDs) {
+		return true
+	}
+
+	for i, keyID := range prevRole.KeyIDs {
+		if nextRole.KeyIDs[i] != keyID || prev.Keys[keyID].KeyVal["public"] != next.Keys[keyID].KeyVal["public"] {
+			return true
+		}
+	}
+
+	return false
+}
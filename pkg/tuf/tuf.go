@@ -0,0 +1,531 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package tuf fetches trust material, such as policy signing keys and CA roots, from a TUF repository, so the material
+// can be rotated by publishing new repository metadata instead of re-signing every policy that trusts it. It follows
+// the TUF client workflow for the repository's top-level roles. Delegated targets roles are not supported.
+package tuf
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/testifysec/witness/pkg/fetch"
+)
+
+const (
+	// maxRootRotations limits how many root versions are fetched in one update, so a malicious repository can't
+	// keep the client fetching forever.
+	maxRootRotations = 32
+	// maxMetadataSize limits the size of metadata whose length isn't listed by other metadata.
+	maxMetadataSize = 1 << 20
+	schemePrefix    = "tuf://"
+)
+
+// Usage is what witness uses a target for, set in the target's custom metadata as
+// {"witness": {"usage": "<usage>", "name": "<name>"}}.
+type Usage string
+
+const (
+	// PolicyKey is a PEM encoded public key policies may be signed with.
+	PolicyKey Usage = "policy-key"
+	// CA is a PEM encoded CA certificate, followed by any intermediates, that policies refer to as a root by name.
+	CA Usage = "ca"
+	// Fulcio is a Fulcio instance's PEM encoded CA certificate chain, used like a CA.
+	Fulcio Usage = "fulcio"
+	// TimestampAuthority is a PEM encoded RFC 3161 timestamp authority certificate chain.
+	TimestampAuthority Usage = "timestamp-authority"
+	// Rekor is a Rekor log's PEM encoded public key.
+	Rekor Usage = "rekor"
+)
+
+// Target is trust material from the repository.
+type Target struct {
+	// Path is the target's path in the repository.
+	Path string
+	// Name is what policies call the material. It defaults to the target's file name without its extension.
+	Name  string
+	Usage Usage
+	Data  []byte
+}
+
+// TrustRoot is the trust material in a repository's targets.
+type TrustRoot struct {
+	Targets []Target
+	// Version is the version of the targets metadata the material is from.
+	Version int64
+	// Expires is when the targets metadata expires.
+	Expires time.Time
+}
+
+// ByUsage returns the targets with the usage.
+func (t TrustRoot) ByUsage(usage Usage) []Target {
+	found := make([]Target, 0)
+	for _, target := range t.Targets {
+		if target.Usage == usage {
+			found = append(found, target)
+		}
+	}
+
+	return found
+}
+
+// Options controls how the repository is read and cached.
+type Options struct {
+	// CacheDir holds the trusted metadata and targets between runs.
+	CacheDir string
+	// InitialRoot is the root metadata to trust when the cache holds none, obtained from the repository's owner.
+	InitialRoot []byte
+	// Refresh is how long metadata is used from the cache before the repository is checked for new metadata. 0
+	// checks the repository every time.
+	Refresh time.Duration
+	// Offline uses the cached metadata without contacting the repository, as long as it has not expired.
+	Offline bool
+	// Now is the time metadata expiry is checked at. Defaults to the current time.
+	Now   time.Time
+	Fetch fetch.Options
+}
+
+// RepositoryURL returns the URL of the repository a tuf://<host>/<path> reference refers to, which is served over
+// https. http and https URLs are returned as they are.
+func RepositoryURL(ref string) (string, error) {
+	switch {
+	case strings.HasPrefix(ref, schemePrefix):
+		return "https://" + strings.TrimSuffix(strings.TrimPrefix(ref, schemePrefix), "/"), nil
+	case fetch.IsURL(ref):
+		return strings.TrimSuffix(ref, "/"), nil
+	default:
+		return "", fmt.Errorf("unsupported trust root %v, expected tuf://<host>/<path>", ref)
+	}
+}
+
+// DefaultCacheDir returns the directory in the user's cache directory that the repository's metadata is cached in.
+func DefaultCacheDir(repositoryURL string) (string, error) {
+	cache, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to find the user cache directory: %w", err)
+	}
+
+	u, err := url.Parse(repositoryURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid repository url %v: %w", repositoryURL, err)
+	}
+
+	name := strings.NewReplacer("/", "_", ":", "_").Replace(strings.Trim(u.Host+u.Path, "/"))
+	return filepath.Join(cache, "witness", "tuf", name), nil
+}
+
+type client struct {
+	url  string
+	opts Options
+}
+
+type trustedMetadata struct {
+	timestamp timestamp
+	snapshot  snapshot
+	targets   targets
+}
+
+// Load returns the trust material in the repository's targets. Metadata cached less than the refresh interval ago is
+// used without contacting the repository. Otherwise the trusted root is updated through each new root version, each
+// signed by the threshold of both the previous root's keys and its own, and new timestamp, snapshot, and targets
+// metadata is fetched and checked against it and against rollback to older versions than the cache holds. Targets
+// are checked against the lengths and digests in the targets metadata.
+func Load(ctx context.Context, repositoryURL string, opts Options) (TrustRoot, error) {
+	if opts.CacheDir == "" {
+		return TrustRoot{}, fmt.Errorf("a cache directory is required")
+	}
+
+	if opts.Now.IsZero() {
+		opts.Now = time.Now()
+	}
+
+	c := client{url: strings.TrimSuffix(repositoryURL, "/"), opts: opts}
+	r, err := c.trustedRoot()
+	if err != nil {
+		return TrustRoot{}, err
+	}
+
+	if opts.Offline || c.fresh() {
+		cached, err := c.cachedMetadata(r)
+		if err == nil {
+			return c.trustRoot(ctx, r, cached.targets)
+		}
+
+		if opts.Offline {
+			return TrustRoot{}, fmt.Errorf("failed to use cached metadata for %v offline: %w", c.url, err)
+		}
+	}
+
+	updated, r, err := c.update(ctx, r)
+	if err != nil {
+		return TrustRoot{}, err
+	}
+
+	return c.trustRoot(ctx, r, updated.targets)
+}
+
+// trustedRoot returns the cached root metadata, or the initial root metadata if nothing is cached yet. The initial
+// root must be signed by its own keys.
+func (c client) trustedRoot() (root, error) {
+	r := root{}
+	data, err := c.read("root.json")
+	if err == nil {
+		return r, r.selfSigned(data)
+	}
+
+	if !errors.Is(err, os.ErrNotExist) {
+		return root{}, fmt.Errorf("failed to read cached root metadata: %w", err)
+	}
+
+	if len(c.opts.InitialRoot) == 0 {
+		return root{}, fmt.Errorf("no root metadata is cached for %v, so an initial root.json is required", c.url)
+	}
+
+	if err := r.selfSigned(c.opts.InitialRoot); err != nil {
+		return root{}, fmt.Errorf("failed to verify the initial root metadata: %w", err)
+	}
+
+	return r, c.write("root.json", c.opts.InitialRoot)
+}
+
+// selfSigned decodes root metadata into r after checking it is signed by the threshold of its own keys.
+func (r *root) selfSigned(data []byte) error {
+	unverified := signed{}
+	if err := json.Unmarshal(data, &unverified); err != nil {
+		return fmt.Errorf("failed to unmarshal root metadata: %w", err)
+	}
+
+	untrusted := root{}
+	if err := json.Unmarshal(unverified.Signed, &untrusted); err != nil {
+		return fmt.Errorf("failed to unmarshal root metadata: %w", err)
+	}
+
+	return untrusted.verify(data, "root", r)
+}
+
+// fresh returns true if the repository was checked less than the refresh interval ago.
+func (c client) fresh() bool {
+	if c.opts.Refresh <= 0 {
+		return false
+	}
+
+	info, err := os.Stat(filepath.Join(c.opts.CacheDir, "timestamp.json"))
+	return err == nil && c.opts.Now.Sub(info.ModTime()) < c.opts.Refresh
+}
+
+// cachedMetadata returns the cached timestamp, snapshot, and targets metadata, after checking them against the
+// trusted root and each other, as on the update they were fetched in.
+func (c client) cachedMetadata(r root) (trustedMetadata, error) {
+	m := trustedMetadata{}
+	if err := r.checkExpiry("root", c.opts.Now); err != nil {
+		return m, err
+	}
+
+	data, err := c.read("timestamp.json")
+	if err != nil {
+		return m, err
+	}
+
+	if m.timestamp, err = r.timestamp(data, c.opts.Now); err != nil {
+		return m, err
+	}
+
+	if data, err = c.read("snapshot.json"); err != nil {
+		return m, err
+	}
+
+	if m.snapshot, err = r.snapshot(data, m.timestamp, c.opts.Now); err != nil {
+		return m, err
+	}
+
+	if data, err = c.read("targets.json"); err != nil {
+		return m, err
+	}
+
+	if m.targets, err = r.targets(data, m.snapshot, c.opts.Now); err != nil {
+		return m, err
+	}
+
+	return m, nil
+}
+
+// update fetches new metadata from the repository, returning it and the updated root.
+func (c client) update(ctx context.Context, r root) (trustedMetadata, root, error) {
+	r, err := c.updateRoot(ctx, r)
+	if err != nil {
+		return trustedMetadata{}, root{}, err
+	}
+
+	m := trustedMetadata{}
+	data, err := c.fetch(ctx, "timestamp.json", maxMetadataSize)
+	if err != nil {
+		return m, root{}, err
+	}
+
+	if m.timestamp, err = r.timestamp(data, c.opts.Now); err != nil {
+		return m, root{}, err
+	}
+
+	// rollback is checked against the cached metadata that is still signed by the trusted keys, expired or not
+	cachedTimestamp := timestamp{}
+	if cached, err := c.read("timestamp.json"); err == nil && r.verify(cached, "timestamp", &cachedTimestamp) == nil {
+		if m.timestamp.Version < cachedTimestamp.Version {
+			return m, root{}, ErrRollback{Role: "timestamp", Trusted: cachedTimestamp.Version, Version: m.timestamp.Version}
+		}
+
+		trusted, latest := cachedTimestamp.Meta["snapshot.json"].Version, m.timestamp.Meta["snapshot.json"].Version
+		if latest < trusted {
+			return m, root{}, ErrRollback{Role: "snapshot", Trusted: trusted, Version: latest}
+		}
+	}
+
+	if err := c.write("timestamp.json", data); err != nil {
+		return m, root{}, err
+	}
+
+	snapshotMeta := m.timestamp.Meta["snapshot.json"]
+	if data, err = c.fetch(ctx, c.metadataName(r, "snapshot.json", snapshotMeta.Version), sizeLimit(snapshotMeta.Length)); err != nil {
+		return m, root{}, err
+	}
+
+	if m.snapshot, err = r.snapshot(data, m.timestamp, c.opts.Now); err != nil {
+		return m, root{}, err
+	}
+
+	cachedSnapshot := snapshot{}
+	if cached, err := c.read("snapshot.json"); err == nil && r.verify(cached, "snapshot", &cachedSnapshot) == nil {
+		for name, trusted := range cachedSnapshot.Meta {
+			latest, ok := m.snapshot.Meta[name]
+			if !ok {
+				return m, root{}, fmt.Errorf("snapshot metadata no longer lists %v", name)
+			}
+
+			if latest.Version < trusted.Version {
+				return m, root{}, ErrRollback{Role: strings.TrimSuffix(name, ".json"), Trusted: trusted.Version, Version: latest.Version}
+			}
+		}
+	}
+
+	if err := c.write("snapshot.json", data); err != nil {
+		return m, root{}, err
+	}
+
+	targetsMeta := m.snapshot.Meta["targets.json"]
+	if data, err = c.fetch(ctx, c.metadataName(r, "targets.json", targetsMeta.Version), sizeLimit(targetsMeta.Length)); err != nil {
+		return m, root{}, err
+	}
+
+	if m.targets, err = r.targets(data, m.snapshot, c.opts.Now); err != nil {
+		return m, root{}, err
+	}
+
+	if err := c.write("targets.json", data); err != nil {
+		return m, root{}, err
+	}
+
+	return m, r, nil
+}
+
+// updateRoot fetches each root version after the trusted one until the repository has no more. When the keys of the
+// timestamp or snapshot roles change, the cached metadata of the roles is discarded, so the repository can recover
+// from a compromise of those keys that left the cache holding versions newer than the repository's.
+func (c client) updateRoot(ctx context.Context, r root) (root, error) {
+	initial := r
+	for i := 0; i < maxRootRotations; i++ {
+		version := r.Version + 1
+		data, err := c.fetch(ctx, fmt.Sprintf("%d.root.json", version), maxMetadataSize)
+		if fetch.IsNotFound(err) {
+			break
+		} else if err != nil {
+			return root{}, err
+		}
+
+		next := root{}
+		if err := r.verify(data, "root", &next); err != nil {
+			return root{}, fmt.Errorf("failed to verify root version %d with the trusted root: %w", version, err)
+		}
+
+		if err := next.verify(data, "root", &next); err != nil {
+			return root{}, fmt.Errorf("failed to verify root version %d with its own keys: %w", version, err)
+		}
+
+		if next.Version != version {
+			return root{}, fmt.Errorf("root version %d has version %d", version, next.Version)
+		}
+
+		if err := c.write("root.json", data); err != nil {
+			return root{}, err
+		}
+
+		r = next
+	}
+
+	if err := r.checkExpiry("root", c.opts.Now); err != nil {
+		return root{}, err
+	}
+
+	for _, roleName := range []string{"timestamp", "snapshot"} {
+		if keysChanged(initial, r, roleName) {
+			for _, name := range []string{"timestamp.json", "snapshot.json"} {
+				if err := os.Remove(filepath.Join(c.opts.CacheDir, name)); err != nil && !errors.Is(err, os.ErrNotExist) {
+					return root{}, err
+				}
+			}
+		}
+	}
+
+	return r, nil
+}
+
+// metadataName returns the name of the role's metadata file, prefixed with its version if the repository publishes
+// consistent snapshots.
+func (c client) metadataName(r root, name string, version int64) string {
+	if r.ConsistentSnapshot {
+		return fmt.Sprintf("%d.%v", version, name)
+	}
+
+	return name
+}
+
+// trustRoot returns the targets with witness custom metadata, read from the cache or fetched from the repository.
+func (c client) trustRoot(ctx context.Context, r root, tg targets) (TrustRoot, error) {
+	paths := make([]string, 0, len(tg.Targets))
+	for targetPath := range tg.Targets {
+		paths = append(paths, targetPath)
+	}
+
+	sort.Strings(paths)
+	trustRoot := TrustRoot{Version: tg.Version, Expires: tg.Expires}
+	for _, targetPath := range paths {
+		t := tg.Targets[targetPath]
+		custom := struct {
+			Witness *struct {
+				Usage Usage  `json:"usage"`
+				Name  string `json:"name"`
+			} `json:"witness"`
+		}{}
+
+		if len(t.Custom) == 0 || json.Unmarshal(t.Custom, &custom) != nil || custom.Witness == nil {
+			continue
+		}
+
+		data, err := c.target(ctx, r, targetPath, t)
+		if err != nil {
+			return TrustRoot{}, err
+		}
+
+		name := custom.Witness.Name
+		if name == "" {
+			base := path.Base(targetPath)
+			name = strings.TrimSuffix(base, path.Ext(base))
+		}
+
+		trustRoot.Targets = append(trustRoot.Targets, Target{Path: targetPath, Name: name, Usage: custom.Witness.Usage, Data: data})
+	}
+
+	return trustRoot, nil
+}
+
+// target returns the target's contents, which are cached by digest.
+func (c client) target(ctx context.Context, r root, targetPath string, t targetFile) ([]byte, error) {
+	digest := t.Hashes["sha256"]
+	if digest == "" {
+		digest = t.Hashes["sha512"]
+	}
+
+	if digest == "" {
+		return nil, fmt.Errorf("target %v has no sha256 or sha512 digest", targetPath)
+	}
+
+	cacheName := path.Join("targets", digest)
+	if data, err := c.read(cacheName); err == nil {
+		if checked, err := checkFile(data, t.Length, t.Hashes); err == nil && checked > 0 {
+			return data, nil
+		}
+	}
+
+	if c.opts.Offline {
+		return nil, fmt.Errorf("target %v is not cached", targetPath)
+	}
+
+	name := targetPath
+	if r.ConsistentSnapshot {
+		name = path.Join(path.Dir(targetPath), digest+"."+path.Base(targetPath))
+	}
+
+	data, err := c.fetch(ctx, "targets/"+name, sizeLimit(t.Length))
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := checkFile(data, t.Length, t.Hashes); err != nil {
+		return nil, fmt.Errorf("target %v does not match the targets metadata: %w", targetPath, err)
+	}
+
+	return data, c.write(cacheName, data)
+}
+
+func sizeLimit(length int64) int64 {
+	if length > 0 {
+		return length
+	}
+
+	return maxMetadataSize
+}
+
+func (c client) fetch(ctx context.Context, name string, maxSize int64) ([]byte, error) {
+	opts := c.opts.Fetch
+	opts.MaxSize = maxSize
+	return fetch.Fetch(ctx, c.url+"/"+name, opts)
+}
+
+func (c client) read(name string) ([]byte, error) {
+	return os.ReadFile(filepath.Join(c.opts.CacheDir, filepath.FromSlash(name)))
+}
+
+// write replaces a cached file by renaming a temporary file over it, so an interrupted write never leaves a partial
+// file in the cache.
+func (c client) write(name string, data []byte) error {
+	dst := filepath.Join(c.opts.CacheDir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(dst), 0700); err != nil {
+		return fmt.Errorf("failed to create cache directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(dst), ".tuf-*")
+	if err != nil {
+		return fmt.Errorf("failed to write %v to the cache: %w", name, err)
+	}
+
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write %v to the cache: %w", name, err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write %v to the cache: %w", name, err)
+	}
+
+	return os.Rename(tmp.Name(), dst)
+}
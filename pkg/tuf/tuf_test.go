@@ -0,0 +1,377 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tuf
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/witness/pkg/fetch"
+	"github.com/testifysec/witness/pkg/sslib"
+)
+
+type testKey struct {
+	id     string
+	key    sslib.Key
+	signer cryptoutil.Signer
+}
+
+func newTestKey(t *testing.T) testKey {
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return newKeyFromSigner(t, priv.Public(), cryptoutil.NewED25519Signer(priv))
+}
+
+func newTestECDSAKey(t *testing.T) testKey {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return newKeyFromSigner(t, &priv.PublicKey, cryptoutil.NewECDSASigner(priv, crypto.SHA256))
+}
+
+func newKeyFromSigner(t *testing.T, pub interface{}, signer cryptoutil.Signer) testKey {
+	key, err := sslib.NewKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id, err := key.KeyID()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return testKey{id: id, key: key, signer: signer}
+}
+
+func signMetadata(t *testing.T, v interface{}, keys ...testKey) []byte {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	canonical, err := sslib.CanonicalJSON(json.RawMessage(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	meta := signed{Signed: raw, Signatures: []signature{}}
+	for _, k := range keys {
+		sig, err := k.signer.Sign(bytes.NewReader(canonical))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		meta.Signatures = append(meta.Signatures, signature{KeyID: k.id, Sig: hex.EncodeToString(sig)})
+	}
+
+	data, err := json.Marshal(meta)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return data
+}
+
+// testRepo is a TUF repository served over http with a root key and a key for each top-level role.
+type testRepo struct {
+	t        *testing.T
+	rootKeys []testKey
+	roleKeys map[string]testKey
+	expires  time.Time
+	version  int64
+	files    map[string][]byte
+	server   *httptest.Server
+}
+
+func newTestRepo(t *testing.T) *testRepo {
+	r := &testRepo{
+		t:        t,
+		rootKeys: []testKey{newTestKey(t)},
+		roleKeys: map[string]testKey{
+			"timestamp": newTestECDSAKey(t),
+			"snapshot":  newTestKey(t),
+			"targets":   newTestKey(t),
+		},
+		expires: time.Now().Add(24 * time.Hour).UTC().Truncate(time.Second),
+		files:   map[string][]byte{},
+	}
+
+	r.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		data, ok := r.files[strings.TrimPrefix(req.URL.Path, "/repo/")]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		_, _ = w.Write(data)
+	}))
+
+	t.Cleanup(r.server.Close)
+	r.files["1.root.json"] = signMetadata(t, r.rootMetadata(1, 1), r.rootKeys...)
+	return r
+}
+
+// rootMetadata returns root metadata trusting the repository's current keys.
+func (r *testRepo) rootMetadata(version int64, threshold int) root {
+	meta := root{
+		common: common{Type: "root", Version: version, Expires: r.expires},
+		Keys:   map[string]sslib.Key{},
+		Roles:  map[string]role{},
+	}
+
+	rootRole := role{Threshold: threshold}
+	for _, k := range r.rootKeys {
+		meta.Keys[k.id] = k.key
+		rootRole.KeyIDs = append(rootRole.KeyIDs, k.id)
+	}
+
+	meta.Roles["root"] = rootRole
+	for name, k := range r.roleKeys {
+		meta.Keys[k.id] = k.key
+		meta.Roles[name] = role{KeyIDs: []string{k.id}, Threshold: 1}
+	}
+
+	return meta
+}
+
+// publish serves new targets, snapshot, and timestamp metadata listing the files, with the custom metadata of each
+// path in custom.
+func (r *testRepo) publish(files map[string][]byte, custom map[string]string) {
+	r.version++
+	tg := targets{common: common{Type: "targets", Version: r.version, Expires: r.expires}, Targets: map[string]targetFile{}}
+	for path, data := range files {
+		digest := sha256.Sum256(data)
+		tf := targetFile{Length: int64(len(data)), Hashes: map[string]string{"sha256": hex.EncodeToString(digest[:])}}
+		if c, ok := custom[path]; ok {
+			tf.Custom = json.RawMessage(c)
+		}
+
+		tg.Targets[path] = tf
+		r.files["targets/"+path] = data
+	}
+
+	r.files["targets.json"] = signMetadata(r.t, tg, r.roleKeys["targets"])
+	sn := snapshot{
+		common: common{Type: "snapshot", Version: r.version, Expires: r.expires},
+		Meta:   map[string]metaFile{"targets.json": {Version: r.version}},
+	}
+
+	r.files["snapshot.json"] = signMetadata(r.t, sn, r.roleKeys["snapshot"])
+	digest := sha256.Sum256(r.files["snapshot.json"])
+	ts := timestamp{
+		common: common{Type: "timestamp", Version: r.version, Expires: r.expires},
+		Meta: map[string]metaFile{"snapshot.json": {
+			Version: r.version,
+			Length:  int64(len(r.files["snapshot.json"])),
+			Hashes:  map[string]string{"sha256": hex.EncodeToString(digest[:])},
+		}},
+	}
+
+	r.files["timestamp.json"] = signMetadata(r.t, ts, r.roleKeys["timestamp"])
+}
+
+func (r *testRepo) load(opts Options) (TrustRoot, error) {
+	opts.InitialRoot = r.files["1.root.json"]
+	opts.Fetch = fetch.Options{Client: r.server.Client()}
+	return Load(context.Background(), r.server.URL+"/repo", opts)
+}
+
+var testTargets = map[string][]byte{
+	"ca/acme.pem":   []byte("acme ca"),
+	"keys/policy":   []byte("policy key"),
+	"other/ignored": []byte("not witness material"),
+}
+
+var testCustom = map[string]string{
+	"ca/acme.pem": `{"witness": {"usage": "ca"}}`,
+	"keys/policy": `{"witness": {"usage": "policy-key", "name": "release"}}`,
+}
+
+func TestLoad(t *testing.T) {
+	repo := newTestRepo(t)
+	repo.publish(testTargets, testCustom)
+	cacheDir := t.TempDir()
+
+	trustRoot, err := repo.load(Options{CacheDir: cacheDir})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(trustRoot.Targets) != 2 || trustRoot.Version != 1 {
+		t.Fatalf("expected the two witness targets of version 1, got %+v", trustRoot)
+	}
+
+	cas := trustRoot.ByUsage(CA)
+	if len(cas) != 1 || cas[0].Name != "acme" || string(cas[0].Data) != "acme ca" {
+		t.Fatalf("unexpected ca targets %+v", cas)
+	}
+
+	keys := trustRoot.ByUsage(PolicyKey)
+	if len(keys) != 1 || keys[0].Name != "release" || string(keys[0].Data) != "policy key" {
+		t.Fatalf("unexpected policy key targets %+v", keys)
+	}
+
+	// fresh metadata and cached targets are used without contacting the repository
+	repo.server.Close()
+	if _, err := repo.load(Options{CacheDir: cacheDir, Refresh: time.Hour}); err != nil {
+		t.Fatalf("expected fresh cached metadata to be used: %v", err)
+	}
+
+	if _, err := repo.load(Options{CacheDir: cacheDir, Offline: true}); err != nil {
+		t.Fatalf("expected cached metadata to be used offline: %v", err)
+	}
+
+	if _, err := repo.load(Options{CacheDir: cacheDir}); err == nil {
+		t.Fatal("expected an error refreshing from a repository that is down")
+	}
+
+	if _, err := repo.load(Options{CacheDir: t.TempDir(), Offline: true}); err == nil {
+		t.Fatal("expected an error loading offline without cached metadata")
+	}
+}
+
+func TestLoadRootRotation(t *testing.T) {
+	repo := newTestRepo(t)
+	repo.publish(testTargets, testCustom)
+	cacheDir := t.TempDir()
+	if _, err := repo.load(Options{CacheDir: cacheDir}); err != nil {
+		t.Fatal(err)
+	}
+
+	// version 2 rotates the root and targets keys, and is signed by both the old and new root keys
+	oldRootKeys := repo.rootKeys
+	repo.rootKeys = []testKey{newTestKey(t)}
+	repo.roleKeys["targets"] = newTestKey(t)
+	repo.files["2.root.json"] = signMetadata(t, repo.rootMetadata(2, 1), append(oldRootKeys, repo.rootKeys...)...)
+	repo.publish(map[string][]byte{"ca/acme.pem": []byte("rotated ca")}, testCustom)
+
+	trustRoot, err := repo.load(Options{CacheDir: cacheDir})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cas := trustRoot.ByUsage(CA); len(cas) != 1 || string(cas[0].Data) != "rotated ca" {
+		t.Fatalf("expected the rotated ca, got %+v", cas)
+	}
+
+	// version 3 is not signed by the version 2 root keys
+	previous := repo.rootKeys
+	repo.rootKeys = []testKey{newTestKey(t)}
+	repo.files["3.root.json"] = signMetadata(t, repo.rootMetadata(3, 1), repo.rootKeys...)
+	if _, err := repo.load(Options{CacheDir: cacheDir}); err == nil || !strings.Contains(err.Error(), "trusted root") {
+		t.Fatalf("expected root version 3 to fail verification with the trusted root, got %v", err)
+	}
+
+	// version 3 requires two signatures of its own keys but only has one
+	repo.rootKeys = append(repo.rootKeys, newTestKey(t))
+	repo.files["3.root.json"] = signMetadata(t, repo.rootMetadata(3, 2), append(previous, repo.rootKeys[0])...)
+	if _, err := repo.load(Options{CacheDir: cacheDir}); err == nil || !strings.Contains(err.Error(), "its own keys") {
+		t.Fatalf("expected root version 3 to fail its own threshold, got %v", err)
+	}
+}
+
+func TestLoadExpired(t *testing.T) {
+	repo := newTestRepo(t)
+	repo.publish(testTargets, testCustom)
+	cacheDir := t.TempDir()
+	if _, err := repo.load(Options{CacheDir: cacheDir}); err != nil {
+		t.Fatal(err)
+	}
+
+	later := repo.expires.Add(time.Hour)
+	for _, opts := range []Options{{CacheDir: cacheDir, Now: later}, {CacheDir: cacheDir, Now: later, Offline: true}} {
+		_, err := repo.load(opts)
+		if !errors.As(err, &ErrExpired{}) {
+			t.Fatalf("expected expired metadata with %+v, got %v", opts, err)
+		}
+	}
+}
+
+func TestLoadRollback(t *testing.T) {
+	repo := newTestRepo(t)
+	repo.publish(testTargets, testCustom)
+	old := map[string][]byte{}
+	for _, name := range []string{"timestamp.json", "snapshot.json", "targets.json"} {
+		old[name] = repo.files[name]
+	}
+
+	repo.publish(testTargets, testCustom)
+	cacheDir := t.TempDir()
+	if _, err := repo.load(Options{CacheDir: cacheDir}); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, data := range old {
+		repo.files[name] = data
+	}
+
+	_, err := repo.load(Options{CacheDir: cacheDir})
+	rollback := ErrRollback{}
+	if !errors.As(err, &rollback) || rollback.Role != "timestamp" || rollback.Trusted != 2 || rollback.Version != 1 {
+		t.Fatalf("expected a timestamp rollback, got %v", err)
+	}
+}
+
+func TestLoadTargetMismatch(t *testing.T) {
+	repo := newTestRepo(t)
+	repo.publish(testTargets, testCustom)
+	repo.files["targets/ca/acme.pem"] = []byte("evil ca")
+	if _, err := repo.load(Options{CacheDir: t.TempDir()}); err == nil || !strings.Contains(err.Error(), "does not match the targets metadata") {
+		t.Fatalf("expected a target mismatch, got %v", err)
+	}
+
+	repo.files["targets/ca/acme.pem"] = testTargets["ca/acme.pem"]
+	repo.files["timestamp.json"] = signMetadata(t, timestamp{
+		common: common{Type: "timestamp", Version: 2, Expires: repo.expires},
+		Meta:   map[string]metaFile{"snapshot.json": {Version: 1}},
+	}, repo.roleKeys["snapshot"])
+
+	if _, err := repo.load(Options{CacheDir: t.TempDir()}); err == nil || !strings.Contains(err.Error(), "valid signatures") {
+		t.Fatalf("expected a timestamp signed by the wrong key to fail, got %v", err)
+	}
+}
+
+func TestRepositoryURL(t *testing.T) {
+	for ref, expected := range map[string]string{
+		"tuf://tuf.example.com/witness/": "https://tuf.example.com/witness",
+		"http://localhost:8080/repo":     "http://localhost:8080/repo",
+		"tuf.example.com":                "",
+	} {
+		actual, err := RepositoryURL(ref)
+		if actual != expected || (err != nil) != (expected == "") {
+			t.Fatalf("unexpected url for %v: %v %v", ref, actual, err)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package version parses and compares the versions of witness releases and attestation schemas, such as v0.1.12 or
+// the v0.1 at the end of https://witness.dev/attestations/command-run/v0.1.
+package version
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	versionPattern = regexp.MustCompile(`^v?(\d+)(?:\.(\d+))?(?:\.(\d+))?(?:[-+].*)?$`)
+	typePattern    = regexp.MustCompile(`^(.+)/(v\d+(?:\.\d+)*)$`)
+)
+
+// Version is a release or schema version. Missing minor and patch numbers are zero. Anything after the patch number,
+// such as the commit witness release builds append to their tag, is ignored.
+type Version struct {
+	Major, Minor, Patch int
+}
+
+// Parse parses a version such as v0.1.12, 0.3, or v0.1.12-4f3c2a1.
+func Parse(s string) (Version, error) {
+	match := versionPattern.FindStringSubmatch(strings.TrimSpace(s))
+	if match == nil {
+		return Version{}, fmt.Errorf("%q is not a release version", s)
+	}
+
+	numbers := [3]int{}
+	for i, part := range match[1:4] {
+		if part == "" {
+			continue
+		}
+
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return Version{}, fmt.Errorf("invalid version %q: %w", s, err)
+		}
+
+		numbers[i] = n
+	}
+
+	return Version{Major: numbers[0], Minor: numbers[1], Patch: numbers[2]}, nil
+}
+
+// Compare returns -1, 0, or 1 if v is older than, the same as, or newer than other.
+func (v Version) Compare(other Version) int {
+	for _, pair := range [][2]int{{v.Major, other.Major}, {v.Minor, other.Minor}, {v.Patch, other.Patch}} {
+		if pair[0] < pair[1] {
+			return -1
+		} else if pair[0] > pair[1] {
+			return 1
+		}
+	}
+
+	return 0
+}
+
+func (v Version) String() string {
+	return fmt.Sprintf("v%d.%d.%d", v.Major, v.Minor, v.Patch)
+}
+
+// SplitType splits an attestation type into the type without its schema version and the schema version, such as
+// https://witness.dev/attestations/command-run and v0.1. ok is false if the type does not end in a version.
+func SplitType(attestationType string) (base, schema string, ok bool) {
+	match := typePattern.FindStringSubmatch(attestationType)
+	if match == nil {
+		return attestationType, "", false
+	}
+
+	return match[1], match[2], true
+}
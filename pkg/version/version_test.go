@@ -0,0 +1,78 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package version
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	tests := map[string]Version{
+		"v0.1.12":         {0, 1, 12},
+		"0.3":             {0, 3, 0},
+		"v1":              {1, 0, 0},
+		"v0.1.12-4f3c2a1": {0, 1, 12},
+		"v2.0.0+build.7":  {2, 0, 0},
+	}
+
+	for s, expected := range tests {
+		v, err := Parse(s)
+		if err != nil {
+			t.Errorf("failed to parse %v: %v", s, err)
+			continue
+		}
+
+		if v != expected {
+			t.Errorf("expected %v to parse as %v, got %v", s, expected, v)
+		}
+	}
+
+	for _, s := range []string{"dev", "", "v", "latest-1.2"} {
+		if _, err := Parse(s); err == nil {
+			t.Errorf("expected %q to not parse", s)
+		}
+	}
+}
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		a, b     string
+		expected int
+	}{
+		{"v0.1.12", "v0.1.12", 0},
+		{"v0.3", "v0.3.0", 0},
+		{"v0.1", "v0.3", -1},
+		{"v0.10", "v0.9.9", 1},
+		{"v1.0.0", "v0.99.99", 1},
+		{"v0.1.11-4f3c2a1", "v0.1.12", -1},
+	}
+
+	for _, test := range tests {
+		a, _ := Parse(test.a)
+		b, _ := Parse(test.b)
+		if actual := a.Compare(b); actual != test.expected {
+			t.Errorf("expected %v compared to %v to be %v, got %v", test.a, test.b, test.expected, actual)
+		}
+	}
+}
+
+func TestSplitType(t *testing.T) {
+	base, schema, ok := SplitType("https://witness.dev/attestations/command-run/v0.1")
+	if !ok || base != "https://witness.dev/attestations/command-run" || schema != "v0.1" {
+		t.Errorf("unexpected split: %v %v %v", base, schema, ok)
+	}
+
+	if _, _, ok := SplitType("https://example.com/attestations/custom"); ok {
+		t.Errorf("expected a type without a version to not split")
+	}
+}
@@ -0,0 +1,105 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package vsa creates SLSA verification summary attestations, which record the outcome of verifying an artifact
+// against a policy so systems downstream of witness verify can act on the decision without verifying again.
+package vsa
+
+import (
+	"bytes"
+	"crypto"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/dsse"
+	"github.com/testifysec/go-witness/intoto"
+)
+
+const PredicateType = "https://slsa.dev/verification_summary/v0.2"
+
+const (
+	ResultPassed = "PASSED"
+	ResultFailed = "FAILED"
+)
+
+type Verifier struct {
+	ID string `json:"id"`
+}
+
+// ResourceDescriptor identifies a policy or attestation by its location and digest.
+type ResourceDescriptor struct {
+	URI    string            `json:"uri"`
+	Digest map[string]string `json:"digest,omitempty"`
+}
+
+// Summary is the predicate of a verification summary attestation.
+type Summary struct {
+	Verifier           Verifier             `json:"verifier"`
+	TimeVerified       time.Time            `json:"time_verified"`
+	ResourceURI        string               `json:"resource_uri"`
+	Policy             ResourceDescriptor   `json:"policy"`
+	InputAttestations  []ResourceDescriptor `json:"input_attestations"`
+	VerificationResult string               `json:"verification_result"`
+}
+
+// Describe returns a descriptor of the envelope at uri, with the sha256 digest of its json.
+func Describe(uri string, env dsse.Envelope) (ResourceDescriptor, error) {
+	data, err := json.Marshal(&env)
+	if err != nil {
+		return ResourceDescriptor{}, fmt.Errorf("failed to marshal envelope: %w", err)
+	}
+
+	ds, err := cryptoutil.CalculateDigestSetFromBytes(data, []crypto.Hash{crypto.SHA256})
+	if err != nil {
+		return ResourceDescriptor{}, fmt.Errorf("failed to digest envelope: %w", err)
+	}
+
+	digest, err := ds.ToNameMap()
+	if err != nil {
+		return ResourceDescriptor{}, err
+	}
+
+	return ResourceDescriptor{URI: uri, Digest: digest}, nil
+}
+
+// Statement returns an in-toto statement about the subjects with the summary as its predicate.
+func Statement(summary Summary, subjects map[string]cryptoutil.DigestSet) (intoto.Statement, error) {
+	if len(subjects) == 0 {
+		return intoto.Statement{}, fmt.Errorf("a verification summary needs at least one subject")
+	}
+
+	predicate, err := json.Marshal(&summary)
+	if err != nil {
+		return intoto.Statement{}, fmt.Errorf("failed to marshal verification summary: %w", err)
+	}
+
+	return intoto.NewStatement(PredicateType, predicate, subjects)
+}
+
+// Sign wraps a statement of the summary about the subjects in a DSSE envelope signed by signer.
+func Sign(summary Summary, subjects map[string]cryptoutil.DigestSet, signer cryptoutil.Signer) (dsse.Envelope, error) {
+	statement, err := Statement(summary, subjects)
+	if err != nil {
+		return dsse.Envelope{}, err
+	}
+
+	data, err := json.Marshal(&statement)
+	if err != nil {
+		return dsse.Envelope{}, fmt.Errorf("failed to marshal statement: %w", err)
+	}
+
+	return dsse.Sign(intoto.PayloadType, bytes.NewReader(data), signer)
+}
@@ -0,0 +1,86 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vsa
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/dsse"
+	"github.com/testifysec/go-witness/intoto"
+)
+
+func TestSign(t *testing.T) {
+	privKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signer := cryptoutil.NewRSASigner(privKey, crypto.SHA256)
+	verifier := cryptoutil.NewRSAVerifier(&privKey.PublicKey, crypto.SHA256)
+	policy, err := Describe("policy.signed.json", dsse.Envelope{Payload: []byte("policy"), PayloadType: "policy"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(policy.Digest["sha256"]) != 64 {
+		t.Errorf("expected a sha256 digest of the policy, got %v", policy.Digest)
+	}
+
+	summary := Summary{
+		Verifier:           Verifier{ID: "https://witness.dev"},
+		TimeVerified:       time.Now().UTC(),
+		ResourceURI:        "file:///build/app",
+		Policy:             policy,
+		VerificationResult: ResultPassed,
+	}
+
+	subjects := map[string]cryptoutil.DigestSet{"app": {crypto.SHA256: "abc123"}}
+	signed, err := Sign(summary, subjects, signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := signed.Verify(dsse.WithVerifiers([]cryptoutil.Verifier{verifier})); err != nil {
+		t.Fatalf("failed to verify summary: %v", err)
+	}
+
+	statement := intoto.Statement{}
+	if err := json.Unmarshal(signed.Payload, &statement); err != nil {
+		t.Fatal(err)
+	}
+
+	if statement.PredicateType != PredicateType || len(statement.Subject) != 1 || statement.Subject[0].Name != "app" {
+		t.Errorf("unexpected statement: %+v", statement)
+	}
+
+	decoded := Summary{}
+	if err := json.Unmarshal(statement.Predicate, &decoded); err != nil {
+		t.Fatal(err)
+	}
+
+	if decoded.VerificationResult != ResultPassed || decoded.Policy.URI != "policy.signed.json" {
+		t.Errorf("unexpected summary: %+v", decoded)
+	}
+
+	if _, err := Sign(summary, nil, signer); err == nil {
+		t.Error("expected a summary without subjects to be rejected")
+	}
+}
@@ -0,0 +1,116 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package witness
+
+import (
+	awsiid "github.com/testifysec/go-witness/attestation/aws-iid"
+	"github.com/testifysec/go-witness/attestation/commandrun"
+	"github.com/testifysec/go-witness/attestation/environment"
+	gcpiit "github.com/testifysec/go-witness/attestation/gcp-iit"
+	"github.com/testifysec/go-witness/attestation/git"
+	"github.com/testifysec/go-witness/attestation/gitlab"
+	"github.com/testifysec/go-witness/attestation/jwt"
+	"github.com/testifysec/go-witness/attestation/material"
+	"github.com/testifysec/go-witness/attestation/maven"
+	"github.com/testifysec/go-witness/attestation/oci"
+	"github.com/testifysec/go-witness/attestation/product"
+	"github.com/testifysec/go-witness/attestation/sarif"
+	"github.com/testifysec/go-witness/attestation/scorecard"
+	"github.com/testifysec/go-witness/attestation/syft"
+	"github.com/testifysec/witness/pkg/attestation/baseimage"
+	"github.com/testifysec/witness/pkg/attestation/branchprotection"
+	"github.com/testifysec/witness/pkg/attestation/buildcache"
+	"github.com/testifysec/witness/pkg/attestation/buildcounter"
+	"github.com/testifysec/witness/pkg/attestation/builderidentity"
+	"github.com/testifysec/witness/pkg/attestation/buildflags"
+	"github.com/testifysec/witness/pkg/attestation/buildtarget"
+	"github.com/testifysec/witness/pkg/attestation/container"
+	"github.com/testifysec/witness/pkg/attestation/deadline"
+	"github.com/testifysec/witness/pkg/attestation/fingerprint"
+	"github.com/testifysec/witness/pkg/attestation/fips"
+	"github.com/testifysec/witness/pkg/attestation/gitlabci"
+	"github.com/testifysec/witness/pkg/attestation/heartbeat"
+	"github.com/testifysec/witness/pkg/attestation/imagelayers"
+	"github.com/testifysec/witness/pkg/attestation/jenkins"
+	"github.com/testifysec/witness/pkg/attestation/keyattestation"
+	"github.com/testifysec/witness/pkg/attestation/labels"
+	"github.com/testifysec/witness/pkg/attestation/matrix"
+	"github.com/testifysec/witness/pkg/attestation/monorepo"
+	"github.com/testifysec/witness/pkg/attestation/normalizedarchive"
+	"github.com/testifysec/witness/pkg/attestation/obfuscate"
+	"github.com/testifysec/witness/pkg/attestation/ociimage"
+	"github.com/testifysec/witness/pkg/attestation/repositoryposture"
+	"github.com/testifysec/witness/pkg/attestation/sbom"
+	"github.com/testifysec/witness/pkg/attestation/sbomcompleteness"
+	"github.com/testifysec/witness/pkg/attestation/secretscan"
+	"github.com/testifysec/witness/pkg/attestation/slim"
+	"github.com/testifysec/witness/pkg/attestation/svid"
+	"github.com/testifysec/witness/pkg/attestation/syscalltrace"
+	"github.com/testifysec/witness/pkg/attestation/upload"
+	"github.com/testifysec/witness/pkg/attestation/versions"
+	"github.com/testifysec/witness/pkg/attestation/worktree"
+)
+
+// attestorNames are the attestors that ship with witness. Importing their packages runs their init functions, making
+// them available to library users. Attestors that only run on some platforms register themselves on those platforms,
+// and attestors_<platform>.go records why the others aren't.
+var attestorNames = []string{
+	awsiid.Name,
+	commandrun.Name,
+	environment.Name,
+	gcpiit.Name,
+	git.Name,
+	gitlab.Name,
+	jwt.Name,
+	material.Name,
+	maven.Name,
+	oci.Name,
+	product.Name,
+	sarif.Name,
+	scorecard.Name,
+	syft.Name,
+	baseimage.Name,
+	branchprotection.Name,
+	buildcache.Name,
+	builderidentity.Name,
+	buildcounter.Name,
+	buildflags.Name,
+	buildtarget.Name,
+	container.Name,
+	deadline.Name,
+	fingerprint.Name,
+	fips.Name,
+	gitlabci.Name,
+	heartbeat.Name,
+	imagelayers.Name,
+	jenkins.Name,
+	keyattestation.Name,
+	labels.Name,
+	matrix.Name,
+	monorepo.Name,
+	normalizedarchive.Name,
+	obfuscate.Name,
+	ociimage.Name,
+	repositoryposture.Name,
+	sbom.Name,
+	sbomcompleteness.Name,
+	secretscan.Name,
+	slim.Name,
+	svid.Name,
+	syscalltrace.Name,
+	upload.Name,
+	versions.Name,
+	worktree.Name,
+}
@@ -0,0 +1,88 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package witness
+
+import (
+	"fmt"
+	"runtime"
+	"sort"
+
+	"github.com/testifysec/go-witness/attestation"
+)
+
+// unsupported maps the attestors that ship with witness, but don't register themselves on the current platform, to the
+// reason they don't.
+var unsupported = map[string]string{}
+
+// Capability is implemented by attestors that need something of the host they run on, such as a file or socket that
+// not every machine has. Available returns an error describing what is missing.
+type Capability interface {
+	Available() error
+}
+
+// AttestorInfo describes whether an attestor can run on the current platform.
+type AttestorInfo struct {
+	Name      string `json:"name"`
+	Type      string `json:"type,omitempty"`
+	RunType   string `json:"runType,omitempty"`
+	Available bool   `json:"available"`
+	// Reason explains why the attestor is not available.
+	Reason string `json:"reason,omitempty"`
+}
+
+// Attestors reports the attestors that ship with witness, and those registered with RegisterAttestor, sorted by name.
+// Attestors that aren't supported on the current platform, or whose capability check fails, are not available.
+func Attestors() []AttestorInfo {
+	infos := make([]AttestorInfo, 0, len(attestorNames))
+	seen := make(map[string]bool)
+	for _, name := range attestorNames {
+		if seen[name] {
+			continue
+		}
+
+		seen[name] = true
+		infos = append(infos, attestorInfo(name))
+	}
+
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	return infos
+}
+
+// Available returns true if the attestor is registered and its capability check, if it has one, passes.
+func Available(name string) bool {
+	return attestorInfo(name).Available
+}
+
+func attestorInfo(name string) AttestorInfo {
+	factory, ok := attestation.FactoryByName(name)
+	if !ok {
+		if reason, ok := unsupported[name]; ok {
+			return AttestorInfo{Name: name, Reason: fmt.Sprintf("not supported on %v: %v", runtime.GOOS, reason)}
+		}
+
+		return AttestorInfo{Name: name, Reason: "attestor is not registered"}
+	}
+
+	attestor := factory()
+	info := AttestorInfo{Name: name, Type: attestor.Type(), RunType: attestor.RunType().String(), Available: true}
+	if capability, ok := attestor.(Capability); ok {
+		if err := capability.Available(); err != nil {
+			info.Available = false
+			info.Reason = err.Error()
+		}
+	}
+
+	return info
+}
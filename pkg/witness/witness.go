@@ -0,0 +1,204 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package witness is the Go API for embedding witness in other programs. It runs steps, signs and loads envelopes,
+// and verifies evidence against a policy the same way the witness command does, including the policy constraints
+// enforced by witness itself and the attestors that ship with it.
+//
+// The functions and types in this package follow semantic versioning. The other packages in this repository are
+// implementation details of the witness command and may change in any release.
+package witness
+
+import (
+	"crypto"
+	"fmt"
+	"io"
+
+	gowitness "github.com/testifysec/go-witness"
+	"github.com/testifysec/go-witness/attestation"
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/go-witness/dsse"
+	"github.com/testifysec/witness/pkg/groups"
+	"github.com/testifysec/witness/pkg/payload"
+	"github.com/testifysec/witness/pkg/policy"
+	"github.com/testifysec/witness/pkg/sslib"
+	"github.com/testifysec/witness/pkg/tektonchains"
+)
+
+type (
+	Signer             = cryptoutil.Signer
+	Verifier           = cryptoutil.Verifier
+	Envelope           = dsse.Envelope
+	CollectionEnvelope = gowitness.CollectionEnvelope
+	Collection         = attestation.Collection
+	Attestor           = attestation.Attestor
+	RunType            = attestation.RunType
+)
+
+// RunOptions configures Run. The zero value runs the environment and git attestors without a command.
+type RunOptions struct {
+	// Command is run and recorded with the command-run, material, and product attestors if set.
+	Command []string
+	// Attestors are the names or types of the attestors to run. Defaults to environment and git.
+	Attestors []string
+	// Tracing records the processes started by Command and the files they open. Linux only.
+	Tracing    bool
+	WorkingDir string
+	// Hashes are the algorithms used to calculate digests. Defaults to sha256.
+	Hashes []crypto.Hash
+}
+
+type RunResult struct {
+	Collection Collection
+	Envelope   Envelope
+}
+
+// VerifyOptions configures Verify.
+type VerifyOptions struct {
+	// PolicyVerifiers verify the policy's signature.
+	PolicyVerifiers []Verifier
+	// Evidence are the signed collections evaluated against the policy.
+	Evidence []CollectionEnvelope
+	// Groups resolves the groups of the policy's approval constraints.
+	Groups groups.Resolver
+}
+
+// Run runs the attestors for a step and returns the collection they recorded signed by signer.
+func Run(stepName string, signer Signer, opts RunOptions) (RunResult, error) {
+	runOpts := []gowitness.RunOption{gowitness.RunWithTracing(opts.Tracing)}
+	if len(opts.Command) > 0 {
+		runOpts = append(runOpts, gowitness.RunWithCommand(opts.Command))
+	}
+
+	if len(opts.Attestors) > 0 {
+		runOpts = append(runOpts, gowitness.RunWithAttestors(opts.Attestors))
+	}
+
+	contextOpts := make([]attestation.AttestationContextOption, 0)
+	if opts.WorkingDir != "" {
+		contextOpts = append(contextOpts, attestation.WithWorkingDir(opts.WorkingDir))
+	}
+
+	if len(opts.Hashes) > 0 {
+		contextOpts = append(contextOpts, attestation.WithHashes(opts.Hashes))
+	}
+
+	runOpts = append(runOpts, gowitness.RunWithAttestationOpts(contextOpts...))
+	result, err := gowitness.Run(stepName, signer, runOpts...)
+	if err != nil {
+		return RunResult{}, err
+	}
+
+	return RunResult{Collection: result.Collection, Envelope: result.SignedEnvelope}, nil
+}
+
+// Sign signs the data read from r as a DSSE envelope with the payload type dataType.
+func Sign(r io.Reader, dataType string, signers ...Signer) (Envelope, error) {
+	return dsse.Sign(dataType, r, signers...)
+}
+
+// LoadEnvelope reads a DSSE envelope. Envelopes written by witness and by securesystemslib are both accepted.
+func LoadEnvelope(r io.Reader) (Envelope, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return Envelope{}, fmt.Errorf("failed to read envelope: %w", err)
+	}
+
+	return sslib.ParseEnvelope(data)
+}
+
+// LoadPolicy reads a signed policy and checks that its payload is a policy witness can evaluate. The policy's
+// signature is checked by Verify.
+func LoadPolicy(r io.Reader) (Envelope, error) {
+	env, err := LoadEnvelope(r)
+	if err != nil {
+		return env, err
+	}
+
+	p, err := policy.Parse(env.Payload)
+	if err != nil {
+		return env, err
+	}
+
+	if len(p.Steps) == 0 {
+		return env, fmt.Errorf("policy does not contain any steps")
+	}
+
+	return env, nil
+}
+
+// TektonChainsAnnotations returns the TaskRun annotations Tekton Chains' tekton storage backend would store the
+// envelope in for key, such as taskrun-<uid>, so attestations recorded with witness can be stored with Chains.
+func TektonChainsAnnotations(env Envelope, key string) (map[string]string, error) {
+	return tektonchains.Annotations(env, key)
+}
+
+// LoadTektonChainsEnvelopes returns the DSSE envelopes stored in a TaskRun's Tekton Chains annotations, such as those
+// returned by TektonChainsAnnotations or written by Chains' in-toto format.
+func LoadTektonChainsEnvelopes(annotations map[string]string) ([]Envelope, error) {
+	return tektonchains.Envelopes(annotations)
+}
+
+// Verify checks the policy's signature and evaluates the evidence against it, returning the evidence that satisfied
+// the policy. Evidence whose payload type isn't an in-toto statement, such as a signed SBOM, is ignored.
+func Verify(policyEnvelope Envelope, opts VerifyOptions) ([]CollectionEnvelope, error) {
+	evidence := make([]CollectionEnvelope, 0, len(opts.Evidence))
+	for _, e := range opts.Evidence {
+		if payload.KindOf(e.Envelope.PayloadType) == payload.KindStatement {
+			evidence = append(evidence, e)
+		}
+	}
+
+	verified, err := gowitness.Verify(policyEnvelope, opts.PolicyVerifiers, gowitness.VerifyWithCollectionEnvelopes(evidence))
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := policy.Parse(policyEnvelope.Payload)
+	if err != nil {
+		return nil, err
+	}
+
+	envelopes := make([]dsse.Envelope, 0, len(verified))
+	for _, e := range verified {
+		envelopes = append(envelopes, e.Envelope)
+	}
+
+	p.Groups = opts.Groups
+	if _, err := p.Verify(envelopes); err != nil {
+		return nil, fmt.Errorf("failed to verify policy: %w", err)
+	}
+
+	return verified, nil
+}
+
+// NewAttestor returns a new instance of the registered attestor with the name or type.
+func NewAttestor(nameOrType string) (Attestor, error) {
+	if factory, ok := attestation.FactoryByName(nameOrType); ok {
+		return factory(), nil
+	}
+
+	if factory, ok := attestation.FactoryByType(nameOrType); ok {
+		return factory(), nil
+	}
+
+	return nil, attestation.ErrAttestationNotFound(nameOrType)
+}
+
+// RegisterAttestor makes an attestor available to Run and to verification by its name and type, and reports it in
+// Attestors.
+func RegisterAttestor(name, attestationType string, runType RunType, factory func() Attestor) {
+	attestation.RegisterAttestation(name, attestationType, runType, factory)
+	attestorNames = append(attestorNames, name)
+}
@@ -0,0 +1,109 @@
+// Copyright 2022 The Witness Contributors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package witness
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"testing"
+
+	"github.com/testifysec/go-witness/cryptoutil"
+	"github.com/testifysec/witness/pkg/attestation/imagelayers"
+)
+
+func TestNewAttestor(t *testing.T) {
+	for _, nameOrType := range []string{imagelayers.Name, imagelayers.Type} {
+		attestor, err := NewAttestor(nameOrType)
+		if err != nil {
+			t.Fatalf("expected %v to be registered: %v", nameOrType, err)
+		}
+
+		if attestor.Name() != imagelayers.Name {
+			t.Errorf("unexpected attestor %v for %v", attestor.Name(), nameOrType)
+		}
+	}
+
+	if _, err := NewAttestor("not-an-attestor"); err == nil {
+		t.Error("expected an error for an unknown attestor")
+	}
+}
+
+func TestSignAndLoadPolicy(t *testing.T) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signer := cryptoutil.NewECDSASigner(priv, crypto.SHA256)
+	env, err := Sign(bytes.NewReader([]byte(`{"steps":{"build":{"name":"build"}}}`)), "https://witness.testifysec.com/policy/v0.1", signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	envJSON, err := json.Marshal(env)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := LoadPolicy(bytes.NewReader(envJSON))
+	if err != nil {
+		t.Fatalf("unexpected error loading policy: %v", err)
+	}
+
+	if !bytes.Equal(loaded.Payload, env.Payload) || len(loaded.Signatures) != 1 {
+		t.Errorf("loaded policy does not match the signed policy")
+	}
+
+	empty, err := Sign(bytes.NewReader([]byte(`{}`)), "https://witness.testifysec.com/policy/v0.1", signer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	emptyJSON, err := json.Marshal(empty)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := LoadPolicy(bytes.NewReader(emptyJSON)); err == nil {
+		t.Error("expected an error for a policy without steps")
+	}
+}
+
+func TestAttestors(t *testing.T) {
+	attestors := Attestors()
+	found := false
+	for i, attestor := range attestors {
+		if i > 0 && attestors[i-1].Name >= attestor.Name {
+			t.Errorf("expected attestors sorted by unique name, got %v before %v", attestors[i-1].Name, attestor.Name)
+		}
+
+		if attestor.Name != imagelayers.Name {
+			continue
+		}
+
+		found = true
+		if !attestor.Available || attestor.Type != imagelayers.Type {
+			t.Errorf("expected %v to be available, got %+v", imagelayers.Name, attestor)
+		}
+	}
+
+	if !found {
+		t.Errorf("expected %v to be listed", imagelayers.Name)
+	}
+}